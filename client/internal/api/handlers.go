@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -65,23 +66,33 @@ func HandleConfirmPage(c echo.Context) error {
 		}
 	}
 
-	// 检查当前认证状态，防止重复打开页面
-	currentState, currentReqID := confirmation.GetCurrentState()
-	if currentReqID == request.ID {
-		switch currentState {
-		case confirmation.StateProcessing:
-			return renderErrorPage(c, http.StatusConflict, "认证进行中", "认证正在处理中，请稍候...")
-		case confirmation.StateCompleted:
-			return renderErrorPage(c, http.StatusConflict, "认证已完成", "认证已完成，请勿重复提交。")
-		}
-	} else if currentState != confirmation.StateIdle && currentState != confirmation.StateWaiting {
-		return renderErrorPage(c, http.StatusConflict, "认证冲突", "当前有其他认证请求正在处理，请稍后再试。")
+	// 只有通过客户端打开的带令牌链接才能访问认证页面
+	if !checkPageToken(c, confirmation.AuthScope(request.ID)) {
+		logger.Logger.Warn("拒绝缺少有效页面令牌的认证页面访问", "request_id", request.ID)
+		return renderErrorPage(c, http.StatusForbidden, "访问被拒绝", "链接无效或已过期，请使用客户端打开的页面进行操作。")
+	}
+
+	// 检查该请求的认证状态，防止重复打开页面
+	switch confirmation.GetState(request.ID) {
+	case confirmation.StateIdle:
+		return renderErrorPage(c, http.StatusGone, "请求已结束", "此认证请求已超时或已结束。")
+	case confirmation.StateProcessing:
+		return renderErrorPage(c, http.StatusConflict, "认证进行中", "认证正在处理中，请稍候...")
+	case confirmation.StateCompleted:
+		return renderErrorPage(c, http.StatusConflict, "认证已完成", "认证已完成，请勿重复提交。")
+	}
+
+	csrfToken, err := csrfTokens.Issue(confirmation.AuthScope(request.ID), time.Until(request.ExpiresAt))
+	if err != nil {
+		return renderErrorPage(c, http.StatusInternalServerError, "内部错误", "无法生成安全令牌。")
 	}
 
 	data := map[string]interface{}{
 		"Request":    *request,
 		"RawRequest": encodedRequest,
 		"Remaining":  int64(time.Until(request.ExpiresAt).Seconds()),
+		"CSRFToken":  csrfToken,
+		"Others":     otherPendingRequests(request.ID),
 	}
 
 	return c.Render(http.StatusOK, "auth.html", data)
@@ -107,26 +118,32 @@ func HandleConfirmAction(c echo.Context) error {
 		})
 	}
 
-	// 检查当前认证状态，防止重复提交
-	currentState, currentReqID := confirmation.GetCurrentState()
-	if currentReqID == request.ID {
-		switch currentState {
-		case confirmation.StateProcessing:
-			return c.JSON(http.StatusOK, ConfirmActionResponse{
-				Message:       "认证正在处理中，请稍候...",
-				Status:        ConfirmActionStatusError,
-				ConfirmStatus: false,
-			})
-		case confirmation.StateCompleted:
-			return c.JSON(http.StatusOK, ConfirmActionResponse{
-				Message:       "认证已完成，请勿重复提交",
-				Status:        ConfirmActionStatusError,
-				ConfirmStatus: false,
-			})
-		}
-	} else if currentState != confirmation.StateIdle && currentState != confirmation.StateWaiting {
+	if !checkCSRF(c, confirmation.AuthScope(request.ID)) {
+		logger.Logger.Warn("拒绝CSRF令牌无效的认证提交", "request_id", request.ID)
+		return c.JSON(http.StatusForbidden, ConfirmActionResponse{
+			Message:       "安全令牌无效，请刷新页面后重试",
+			Status:        ConfirmActionStatusError,
+			ConfirmStatus: false,
+		})
+	}
+
+	// 检查该请求的认证状态，防止重复提交
+	switch confirmation.GetState(request.ID) {
+	case confirmation.StateIdle:
+		return c.JSON(http.StatusOK, ConfirmActionResponse{
+			Message:       "认证请求已超时或已结束",
+			Status:        ConfirmActionStatusError,
+			ConfirmStatus: false,
+		})
+	case confirmation.StateProcessing:
+		return c.JSON(http.StatusOK, ConfirmActionResponse{
+			Message:       "认证正在处理中，请稍候...",
+			Status:        ConfirmActionStatusError,
+			ConfirmStatus: false,
+		})
+	case confirmation.StateCompleted:
 		return c.JSON(http.StatusOK, ConfirmActionResponse{
-			Message:       "当前有其他认证请求正在处理",
+			Message:       "认证已完成，请勿重复提交",
 			Status:        ConfirmActionStatusError,
 			ConfirmStatus: false,
 		})
@@ -151,33 +168,27 @@ func HandleConfirmAction(c echo.Context) error {
 		})
 	}
 
-	// 如果是确认认证且提供了PIN，需要进行PIN验证
-	if payload.PIN != "" {
-		if err := pin.ValidatePIN(payload.PIN); err != nil {
-			return c.JSON(http.StatusBadRequest, ConfirmActionResponse{
-				Message:       "PIN格式错误",
-				Status:        ConfirmActionStatusError,
-				ConfirmStatus: false,
-			})
-		}
-
-		// 将PIN发送到PIN管理器，供认证流程使用
-		if global.PinManager != nil {
-			global.PinManager.SendPIN(payload.PIN)
-		}
+	// 确认认证时必须提供PIN，PIN随确认结果交给该请求的认证流程
+	if err := pin.ValidatePIN(payload.PIN); err != nil {
+		return c.JSON(http.StatusBadRequest, ConfirmActionResponse{
+			Message:       "PIN格式错误",
+			Status:        ConfirmActionStatusError,
+			ConfirmStatus: false,
+		})
 	}
 
 	confirmResult := confirmation.AuthConfirmation{
 		RequestID: request.ID,
 		Confirmed: confirmed,
+		PIN:       payload.PIN,
 		Timestamp: time.Now(),
 	}
 
 	// 发送确认结果
 	confirmation.SendConfirmation(confirmResult)
 
-	// 等待WebSocket认证结果
-	result, err := confirmation.WaitForResult(60 * time.Second)
+	// 等待该请求的WebSocket认证结果
+	result, err := confirmation.WaitForResult(request.ID, 60*time.Second)
 	if err != nil {
 		logger.Logger.Error("等待认证结果超时", "requestID", request.ID, "error", err)
 		return c.JSON(http.StatusOK, ConfirmActionResponse{
@@ -200,6 +211,17 @@ func HandleConfirmAction(c echo.Context) error {
 	})
 }
 
+// otherPendingRequests 返回除当前请求外其他等待确认的请求
+func otherPendingRequests(currentID string) []confirmation.PendingRequest {
+	var others []confirmation.PendingRequest
+	for _, p := range confirmation.PendingRequests() {
+		if p.ID != currentID {
+			others = append(others, p)
+		}
+	}
+	return others
+}
+
 // renderErrorPage 渲染错误页面
 func renderErrorPage(c echo.Context, statusCode int, title, message string) error {
 	data := map[string]string{
@@ -212,11 +234,21 @@ func renderErrorPage(c echo.Context, statusCode int, title, message string) erro
 
 // HandlePINPage 处理PIN设置页面
 func HandlePINPage(c echo.Context) error {
+	if !checkPageToken(c, confirmation.ScopePIN) {
+		return renderErrorPage(c, http.StatusForbidden, "访问被拒绝", "链接无效或已过期，请使用客户端打开的页面进行操作。")
+	}
+
+	csrfToken, err := csrfTokens.Issue(confirmation.ScopePIN, 10*time.Minute)
+	if err != nil {
+		return renderErrorPage(c, http.StatusInternalServerError, "内部错误", "无法生成安全令牌。")
+	}
+
 	// 检查设备是否已初始化
 	isInitialized := identity.IsInitialized(global.SecureStoragePath)
 
 	data := map[string]interface{}{
 		"IsInitialized": isInitialized,
+		"CSRFToken":     csrfToken,
 	}
 
 	return c.Render(http.StatusOK, "pin.html", data)
@@ -232,6 +264,14 @@ func HandlePINSetup(c echo.Context) error {
 		})
 	}
 
+	if !checkCSRF(c, confirmation.ScopePIN) {
+		logger.Logger.Warn("拒绝CSRF令牌无效的PIN提交")
+		return c.JSON(http.StatusForbidden, PINSetupResponse{
+			Message: "安全令牌无效，请刷新页面后重试",
+			Status:  ConfirmActionStatusError,
+		})
+	}
+
 	// 验证PIN格式
 	if err := pin.ValidatePIN(payload.PIN); err != nil {
 		return c.JSON(http.StatusBadRequest, PINSetupResponse{
@@ -257,6 +297,9 @@ func HandlePINSetup(c echo.Context) error {
 			})
 		}
 
+		// PIN只需输入一次，成功后吊销页面和CSRF令牌
+		revokePINTokens()
+
 		// PIN验证成功，发送到PIN管理器
 		if global.PinManager != nil {
 			global.PinManager.SendPIN(payload.PIN)
@@ -269,12 +312,47 @@ func HandlePINSetup(c echo.Context) error {
 	}
 
 	// 设备未初始化，设置PIN用于初始化
+	revokePINTokens()
+	recoveryCode := strings.TrimSpace(payload.RecoveryCode)
+	global.SetRecoveryCode(recoveryCode)
 	if global.PinManager != nil {
 		global.PinManager.SendPIN(payload.PIN)
 	}
 
+	if recoveryCode != "" {
+		return c.JSON(http.StatusOK, PINSetupResponse{
+			Message: "PIN设置成功，正在使用恢复码接管原设备组...",
+			Status:  ConfirmActionStatusSuccess,
+		})
+	}
+
 	return c.JSON(http.StatusOK, PINSetupResponse{
 		Message: "PIN设置成功，正在初始化设备...",
 		Status:  ConfirmActionStatusSuccess,
 	})
 }
+
+// HandleRecoveryCodesPage 处理恢复码展示页面，恢复码只能查看一次
+func HandleRecoveryCodesPage(c echo.Context) error {
+	if !checkPageToken(c, confirmation.ScopeRecovery) {
+		return renderErrorPage(c, http.StatusForbidden, "访问被拒绝", "链接无效或已过期，请使用客户端打开的页面进行操作。")
+	}
+	confirmation.PageTokens.Revoke(confirmation.ScopeRecovery)
+
+	codes := confirmation.TakeRecoveryCodes()
+	if len(codes) == 0 {
+		return renderErrorPage(c, http.StatusGone, "恢复码已失效", "恢复码仅显示一次，如未保存请联系管理员重新生成。")
+	}
+
+	data := map[string]interface{}{
+		"Codes": codes,
+	}
+
+	return c.Render(http.StatusOK, "recovery.html", data)
+}
+
+// revokePINTokens 吊销PIN页面的访问令牌和CSRF令牌
+func revokePINTokens() {
+	confirmation.PageTokens.Revoke(confirmation.ScopePIN)
+	csrfTokens.Revoke(confirmation.ScopePIN)
+}
@@ -23,7 +23,8 @@ const (
 
 // PINSetupPayload PIN设置的请求体
 type PINSetupPayload struct {
-	PIN string `json:"pin"`
+	PIN          string `json:"pin"`
+	RecoveryCode string `json:"recovery_code,omitempty"` // 可选，未初始化设备使用恢复码接管原设备组
 }
 
 // PINSetupResponse PIN设置的响应
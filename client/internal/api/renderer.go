@@ -12,7 +12,13 @@ type TemplateRenderer struct {
 	Templates *template.Template
 }
 
-// Render 渲染方法
+// Render 渲染方法，自动注入当前请求的CSP nonce供内联脚本使用
 func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	switch d := data.(type) {
+	case map[string]interface{}:
+		d["Nonce"] = cspNonce(c)
+	case map[string]string:
+		d["Nonce"] = cspNonce(c)
+	}
 	return t.Templates.ExecuteTemplate(w, name, data)
 }
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/hang666/EasyUKey/client/internal/confirmation"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+const (
+	// CSRFHeader 页面提交表单时携带CSRF令牌的请求头
+	CSRFHeader = "X-CSRF-Token"
+
+	cspNonceKey = "csp_nonce"
+)
+
+// csrfTokens 页面渲染时签发的CSRF令牌，与页面令牌使用相同的作用范围
+var csrfTokens = confirmation.NewTokenStore()
+
+// securityHeaders 为所有响应添加安全响应头，内联脚本使用每次请求随机生成的nonce
+func securityHeaders() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			nonce, err := newNonce()
+			if err != nil {
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			c.Set(cspNonceKey, nonce)
+
+			h := c.Response().Header()
+			h.Set("Content-Security-Policy", fmt.Sprintf(
+				"default-src 'none'; "+
+					"script-src 'nonce-%s' 'unsafe-eval' https://cdn.tailwindcss.com https://cdn.jsdelivr.net; "+
+					"style-src 'unsafe-inline' https://cdnjs.cloudflare.com; "+
+					"font-src https://cdnjs.cloudflare.com; "+
+					"img-src 'self' data:; connect-src 'self'; "+
+					"base-uri 'none'; form-action 'self'; frame-ancestors 'none'", nonce))
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("Referrer-Policy", "no-referrer") // URL中的令牌不能通过Referer泄露给CDN
+			h.Set("Cross-Origin-Opener-Policy", "same-origin")
+			h.Set("Cross-Origin-Resource-Policy", "same-origin")
+			h.Set("Cache-Control", "no-store")
+			return next(c)
+		}
+	}
+}
+
+// originGuard 严格检查Host和Origin
+// Host必须是本机回环地址和当前端口，防止DNS重绑定；修改状态的请求必须来自同源页面且使用JSON
+func originGuard(port int) echo.MiddlewareFunc {
+	allowedHosts := map[string]bool{
+		fmt.Sprintf("127.0.0.1:%d", port): true,
+		fmt.Sprintf("localhost:%d", port): true,
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			if !allowedHosts[req.Host] {
+				logger.Logger.Warn("拒绝非本机Host的请求", "host", req.Host, "path", req.URL.Path)
+				return c.String(http.StatusForbidden, "forbidden")
+			}
+
+			// 浏览器声明的跨站请求一律拒绝
+			if site := req.Header.Get("Sec-Fetch-Site"); site == "cross-site" || site == "same-site" {
+				logger.Logger.Warn("拒绝跨站请求", "sec_fetch_site", site, "path", req.URL.Path)
+				return c.String(http.StatusForbidden, "forbidden")
+			}
+
+			if req.Method == http.MethodGet || req.Method == http.MethodHead {
+				return next(c)
+			}
+
+			origin := req.Header.Get("Origin")
+			if !strings.HasPrefix(origin, "http://") || !allowedHosts[strings.TrimPrefix(origin, "http://")] {
+				logger.Logger.Warn("拒绝来源不符的请求", "origin", origin, "path", req.URL.Path)
+				return c.String(http.StatusForbidden, "forbidden")
+			}
+
+			// 只接受JSON请求体，普通表单无法跨域提交
+			mediaType, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+			if err != nil || mediaType != echo.MIMEApplicationJSON {
+				return c.String(http.StatusUnsupportedMediaType, "unsupported media type")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// checkPageToken 检查URL中的页面令牌
+func checkPageToken(c echo.Context, scope string) bool {
+	return confirmation.PageTokens.Valid(c.QueryParam("token"), scope)
+}
+
+// checkCSRF 检查请求头中的CSRF令牌
+func checkCSRF(c echo.Context, scope string) bool {
+	return csrfTokens.Valid(c.Request().Header.Get(CSRFHeader), scope)
+}
+
+// cspNonce 获取当前请求的CSP nonce
+func cspNonce(c echo.Context) string {
+	nonce, _ := c.Get(cspNonceKey).(string)
+	return nonce
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
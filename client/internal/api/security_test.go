@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/hang666/EasyUKey/client/internal/confirmation"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+const testPort = 34567
+
+var testOrigin = "http://127.0.0.1:34567"
+
+func newTestServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	if logger.Logger == nil {
+		logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	e := echo.New()
+	e.Renderer = &TemplateRenderer{
+		Templates: template.Must(template.New("").ParseGlob("../../template/*.html")),
+	}
+	registerRoutes(e, testPort)
+	return e
+}
+
+func newTestAuthRequest(t *testing.T) (*confirmation.AuthRequest, string) {
+	t.Helper()
+	request := &confirmation.AuthRequest{
+		ID:        fmt.Sprintf("req-%d", time.Now().UnixNano()),
+		UserID:    "alice",
+		Challenge: "challenge",
+		Timestamp: time.Now(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err := confirmation.Enqueue(request); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return request, base64.URLEncoding.EncodeToString(data)
+}
+
+// confirmBody 构造拒绝操作的请求体，拒绝不会等待服务端结果
+func confirmBody(t *testing.T, encoded string) string {
+	t.Helper()
+	body, err := json.Marshal(ConfirmActionPayload{Action: "reject", Request: encoded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func doRequest(e *echo.Echo, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func newConfirmRequest(body, csrfToken string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/confirm", strings.NewReader(body))
+	req.Host = "127.0.0.1:34567"
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Origin", testOrigin)
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	if csrfToken != "" {
+		req.Header.Set(CSRFHeader, csrfToken)
+	}
+	return req
+}
+
+func TestConfirmAction_SameOriginWithCSRF(t *testing.T) {
+	e := newTestServer(t)
+	request, encoded := newTestAuthRequest(t)
+
+	token, err := csrfTokens.Issue(confirmation.AuthScope(request.ID), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doRequest(e, newConfirmRequest(confirmBody(t, encoded), token))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("同源且带CSRF令牌的请求应被接受, 状态码 %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestConfirmAction_RejectsCrossOrigin(t *testing.T) {
+	e := newTestServer(t)
+	request, encoded := newTestAuthRequest(t)
+	token, err := csrfTokens.Issue(confirmation.AuthScope(request.ID), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := confirmBody(t, encoded)
+
+	tests := []struct {
+		name   string
+		modify func(req *http.Request)
+		want   int
+	}{
+		{"恶意Origin", func(req *http.Request) { req.Header.Set("Origin", "https://evil.example.com") }, http.StatusForbidden},
+		{"其他端口的Origin", func(req *http.Request) { req.Header.Set("Origin", "http://127.0.0.1:8080") }, http.StatusForbidden},
+		{"缺少Origin", func(req *http.Request) { req.Header.Del("Origin") }, http.StatusForbidden},
+		{"null Origin", func(req *http.Request) { req.Header.Set("Origin", "null") }, http.StatusForbidden},
+		{"DNS重绑定Host", func(req *http.Request) { req.Host = "evil.example.com:34567" }, http.StatusForbidden},
+		{"跨站请求", func(req *http.Request) { req.Header.Set("Sec-Fetch-Site", "cross-site") }, http.StatusForbidden},
+		{"表单提交", func(req *http.Request) {
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		}, http.StatusUnsupportedMediaType},
+		{"text/plain提交", func(req *http.Request) {
+			req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+		}, http.StatusUnsupportedMediaType},
+		{"缺少CSRF令牌", func(req *http.Request) { req.Header.Del(CSRFHeader) }, http.StatusForbidden},
+		{"错误的CSRF令牌", func(req *http.Request) { req.Header.Set(CSRFHeader, "invalid") }, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newConfirmRequest(body, token)
+			tt.modify(req)
+			rec := doRequest(e, req)
+			if rec.Code != tt.want {
+				t.Errorf("状态码 %d, 期望 %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmAction_CSRFTokenScopedToRequest(t *testing.T) {
+	e := newTestServer(t)
+	other, _ := newTestAuthRequest(t)
+	_, encoded := newTestAuthRequest(t)
+
+	// 为其他认证请求签发的令牌不能用于当前请求
+	token, err := csrfTokens.Issue(confirmation.AuthScope(other.ID+"-other"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doRequest(e, newConfirmRequest(confirmBody(t, encoded), token))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("其他请求的CSRF令牌应被拒绝, 状态码 %d", rec.Code)
+	}
+}
+
+func TestPages_RequireToken(t *testing.T) {
+	e := newTestServer(t)
+	request, encoded := newTestAuthRequest(t)
+
+	pageToken, err := confirmation.PageTokens.Issue(confirmation.AuthScope(request.ID), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"认证页面缺少令牌", "/?request=" + encoded, http.StatusForbidden},
+		{"认证页面错误令牌", "/?request=" + encoded + "&token=invalid", http.StatusForbidden},
+		{"认证页面有效令牌", "/?request=" + encoded + "&token=" + pageToken, http.StatusOK},
+		{"PIN页面缺少令牌", "/pin", http.StatusForbidden},
+		{"PIN页面使用认证令牌", "/pin?token=" + pageToken, http.StatusForbidden},
+		{"恢复码页面缺少令牌", "/recovery-codes", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Host = "127.0.0.1:34567"
+			rec := doRequest(e, req)
+			if rec.Code != tt.want {
+				t.Errorf("状态码 %d, 期望 %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPages_SecurityHeaders(t *testing.T) {
+	e := newTestServer(t)
+	request, encoded := newTestAuthRequest(t)
+	pageToken, err := confirmation.PageTokens.Issue(confirmation.AuthScope(request.ID), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?request="+encoded+"&token="+pageToken, nil)
+	req.Host = "localhost:34567"
+	rec := doRequest(e, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 %d: %s", rec.Code, rec.Body.String())
+	}
+
+	h := rec.Header()
+	csp := h.Get("Content-Security-Policy")
+	for _, directive := range []string{"default-src 'none'", "frame-ancestors 'none'", "'nonce-"} {
+		if !strings.Contains(csp, directive) {
+			t.Errorf("CSP缺少 %q: %s", directive, csp)
+		}
+	}
+	expected := map[string]string{
+		"X-Frame-Options":        "DENY",
+		"X-Content-Type-Options": "nosniff",
+		"Referrer-Policy":        "no-referrer",
+		"Cache-Control":          "no-store",
+	}
+	for name, want := range expected {
+		if got := h.Get(name); got != want {
+			t.Errorf("%s = %q, 期望 %q", name, got, want)
+		}
+	}
+
+	// 内联脚本必须带上与CSP一致的nonce，页面中必须包含CSRF令牌
+	body := rec.Body.String()
+	start := strings.Index(csp, "'nonce-") + len("'nonce-")
+	nonce := csp[start : start+strings.Index(csp[start:], "'")]
+	if !strings.Contains(body, `nonce="`+nonce+`"`) {
+		t.Error("页面内联脚本未使用CSP nonce")
+	}
+	if !strings.Contains(body, "X-CSRF-Token") {
+		t.Error("页面未携带CSRF令牌请求头")
+	}
+}
+
+func TestConfirmPage_ListsOtherPendingRequests(t *testing.T) {
+	e := newTestServer(t)
+	request, encoded := newTestAuthRequest(t)
+	other, _ := newTestAuthRequest(t)
+	other.UserID = "bob"
+
+	pageToken, err := confirmation.PageTokens.Issue(confirmation.AuthScope(request.ID), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?request="+encoded+"&token="+pageToken, nil)
+	req.Host = "127.0.0.1:34567"
+	rec := doRequest(e, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码 %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "其他待确认的请求") || !strings.Contains(rec.Body.String(), "bob") {
+		t.Error("认证页面应列出其他待确认的请求")
+	}
+}
+
+func TestConfirmAction_UnknownRequest(t *testing.T) {
+	e := newTestServer(t)
+	request := &confirmation.AuthRequest{
+		ID:        "req-unknown",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	data, _ := json.Marshal(request)
+	token, err := csrfTokens.Issue(confirmation.AuthScope(request.ID), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doRequest(e, newConfirmRequest(confirmBody(t, base64.URLEncoding.EncodeToString(data)), token))
+	var resp ConfirmActionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != ConfirmActionStatusError {
+		t.Errorf("不在队列中的请求应返回错误: %+v", resp)
+	}
+}
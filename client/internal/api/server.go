@@ -3,9 +3,12 @@ package api
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
+	"net"
+	"net/http"
 	"os"
 	"time"
 
@@ -16,8 +19,8 @@ import (
 
 var httpServer *echo.Echo
 
-// StartHttpServer 启动HTTP服务器
-func StartHttpServer(port int, templateFS embed.FS) error {
+// StartHttpServer 在本机回环地址上启动HTTP服务器，port为0时随机分配端口，返回实际监听的端口
+func StartHttpServer(port int, templateFS embed.FS) (int, error) {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
@@ -34,6 +37,33 @@ func StartHttpServer(port int, templateFS embed.FS) error {
 	}
 	e.Renderer = t
 
+	// 只监听回环地址，不接受来自网络的连接
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return 0, err
+	}
+	port = listener.Addr().(*net.TCPAddr).Port
+	e.Listener = listener
+
+	registerRoutes(e, port)
+
+	httpServer = e
+
+	go func() {
+		if err := httpServer.Start(""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Logger.Error("HTTP服务器异常退出", "error", err)
+		}
+	}()
+
+	logger.Logger.Info("本地确认服务已启动", "port", port)
+	return port, nil
+}
+
+// registerRoutes 注册路由和安全中间件
+func registerRoutes(e *echo.Echo, port int) {
+	e.Use(securityHeaders())
+	e.Use(originGuard(port))
+
 	// 认证相关路由
 	e.GET("/", HandleConfirmPage)
 	e.POST("/confirm", HandleConfirmAction)
@@ -42,9 +72,8 @@ func StartHttpServer(port int, templateFS embed.FS) error {
 	e.GET("/pin", HandlePINPage)
 	e.POST("/pin-setup", HandlePINSetup)
 
-	httpServer = e
-
-	return httpServer.Start(fmt.Sprintf("localhost:%d", port))
+	// 恢复码展示路由
+	e.GET("/recovery-codes", HandleRecoveryCodesPage)
 }
 
 // StopHttpServer 停止HTTP服务器
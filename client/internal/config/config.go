@@ -3,8 +3,17 @@ package config
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 
 	"github.com/hang666/EasyUKey/client/utils/uid"
 )
@@ -12,7 +21,9 @@ import (
 // Config 客户端配置结构
 type Config struct {
 	// 服务器配置
-	ServerAddr string
+	ServerAddr      string
+	ServerPublicKey string // 服务端身份公钥（Base64），用于验证密钥交换签名
+	Proxy           string // 连接服务器使用的代理地址，为空时使用系统代理环境变量
 
 	// 安全配置
 	EncryptKey    []byte
@@ -23,11 +34,45 @@ type Config struct {
 	LogFile    string
 	LogConsole string
 
+	// 重连配置
+	Reconnect ReconnectConfig
+
+	// 交互配置
+	ConfirmMode string // 确认方式: browser, terminal
+
 	// 应用配置
-	Version  string
-	HTTPPort int
-	ExeDir   string
-	DevMode  string
+	Version    string
+	HTTPPort   int
+	ExeDir     string
+	DevMode    string
+	ConfigFile string // 实际加载的配置文件路径，未加载时为空
+}
+
+// ReconnectConfig 断线重连策略
+// 重连间隔按指数退避增长并加入随机抖动，避免大量客户端同时重连
+type ReconnectConfig struct {
+	Interval    time.Duration `mapstructure:"interval"`     // 首次重连间隔
+	MaxInterval time.Duration `mapstructure:"max_interval"` // 重连间隔上限
+	MaxAttempts int           `mapstructure:"max_attempts"` // 连续重连失败的最大次数，0表示不限制
+}
+
+// RuntimeConfig 可通过配置文件、环境变量和命令行参数覆盖的运行时配置
+// 加密密钥、服务端身份公钥和开发模式只能在编译时指定，不允许在U盘上修改
+type RuntimeConfig struct {
+	ServerAddr string          `mapstructure:"server_addr"`
+	HTTPPort   int             `mapstructure:"http_port"`
+	Proxy      string          `mapstructure:"proxy"`
+	Log        LogConfig       `mapstructure:"log"`
+	Reconnect  ReconnectConfig `mapstructure:"reconnect"`
+
+	ConfirmMode string `mapstructure:"confirm_mode"` // 确认方式: browser, terminal
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level   string `mapstructure:"level"`   // 日志级别: debug, info, warn, error
+	File    string `mapstructure:"file"`    // 日志文件路径
+	Console bool   `mapstructure:"console"` // 是否同时输出到控制台
 }
 
 // GlobalConfig 全局配置实例
@@ -35,12 +80,15 @@ var GlobalConfig *Config
 
 // 应用常量
 const (
-	ClientVersion = "0.0.1"
-	HttpPort      = 18765
+	ClientVersion  = "0.0.1"         // 默认客户端版本，可在编译时通过 VERSION 覆盖
+	HttpPort       = 0               // 本地HTTP服务端口，0表示每次启动时随机分配
+	ConfigFileName = "easyukey.yaml" // 配置文件名，位于可执行文件同目录
+	EnvPrefix      = "EASYUKEY_CLIENT"
 )
 
 // InitConfig 初始化配置
-func InitConfig(encryptKeyStr, serverAddr, logLevel, logFile, logConsole, devMode string) (*Config, error) {
+// 编译时注入的值作为默认值，依次被配置文件、环境变量和命令行参数覆盖
+func InitConfig(encryptKeyStr, serverAddr, serverPublicKey, version, logLevel, logFile, logConsole, devMode string, args []string) (*Config, error) {
 	exePath, err := os.Executable()
 	if err != nil {
 		return nil, err
@@ -54,6 +102,9 @@ func InitConfig(encryptKeyStr, serverAddr, logLevel, logFile, logConsole, devMod
 	if serverAddr == "" {
 		serverAddr = "http://127.0.0.1:8888"
 	}
+	if version == "" {
+		version = ClientVersion
+	}
 	if logLevel == "" {
 		logLevel = "info"
 	}
@@ -68,23 +119,197 @@ func InitConfig(encryptKeyStr, serverAddr, logLevel, logFile, logConsole, devMod
 	}
 	uid.DevMode = devMode
 
+	defaults := RuntimeConfig{
+		ServerAddr: serverAddr,
+		HTTPPort:   HttpPort,
+		Log: LogConfig{
+			Level:   logLevel,
+			File:    logFile,
+			Console: logConsole == "true",
+		},
+		Reconnect: ReconnectConfig{
+			Interval:    time.Second,
+			MaxInterval: 2 * time.Minute,
+		},
+		ConfirmMode: "browser",
+	}
+
+	runtime, configFile, err := LoadRuntimeConfig(defaults, exeDir, args)
+	if err != nil {
+		return nil, err
+	}
+
 	// 生成加密密钥
 	hash := md5.New()
 	hash.Write([]byte(encryptKeyStr))
 	encryptKey := []byte(hex.EncodeToString(hash.Sum(nil)))
 
 	GlobalConfig = &Config{
-		ServerAddr:    serverAddr,
-		EncryptKey:    encryptKey,
-		EncryptKeyStr: encryptKeyStr,
-		LogLevel:      logLevel,
-		LogFile:       logFile,
-		LogConsole:    logConsole,
-		Version:       ClientVersion,
-		HTTPPort:      HttpPort,
-		ExeDir:        exeDir,
-		DevMode:       devMode,
+		ServerAddr:      runtime.ServerAddr,
+		ServerPublicKey: serverPublicKey,
+		Proxy:           runtime.Proxy,
+		EncryptKey:      encryptKey,
+		EncryptKeyStr:   encryptKeyStr,
+		LogLevel:        runtime.Log.Level,
+		LogFile:         runtime.Log.File,
+		LogConsole:      strconv.FormatBool(runtime.Log.Console),
+		Reconnect:       runtime.Reconnect,
+		ConfirmMode:     runtime.ConfirmMode,
+		Version:         version,
+		HTTPPort:        runtime.HTTPPort,
+		ExeDir:          exeDir,
+		DevMode:         devMode,
+		ConfigFile:      configFile,
 	}
 
 	return GlobalConfig, nil
 }
+
+// LoadRuntimeConfig 加载运行时配置，返回配置和实际加载的配置文件路径
+// 优先级: 命令行参数 > 环境变量 > 配置文件 > defaults
+func LoadRuntimeConfig(defaults RuntimeConfig, exeDir string, args []string) (*RuntimeConfig, string, error) {
+	flags := newFlagSet(defaults)
+	if err := flags.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	v := viper.New()
+	setDefaults(v, defaults)
+
+	// 设置环境变量前缀
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AllowEmptyEnv(false)
+	v.AutomaticEnv()
+
+	// 绑定命令行参数，只有显式指定的参数才会覆盖其他来源
+	for key, name := range flagKeys {
+		if err := v.BindPFlag(key, flags.Lookup(name)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// 读取配置文件，默认位于可执行文件同目录，不存在时忽略
+	configFile, _ := flags.GetString("config")
+	explicit := configFile != ""
+	if !explicit {
+		configFile = filepath.Join(exeDir, ConfigFileName)
+	}
+	v.SetConfigFile(configFile)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		if explicit || !errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		configFile = ""
+	}
+
+	config := &RuntimeConfig{}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, "", fmt.Errorf("解析配置失败: %w", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, "", fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	return config, configFile, nil
+}
+
+// flagKeys 配置项与命令行参数的对应关系
+var flagKeys = map[string]string{
+	"server_addr":            "server-addr",
+	"http_port":              "http-port",
+	"proxy":                  "proxy",
+	"log.level":              "log-level",
+	"log.file":               "log-file",
+	"log.console":            "log-console",
+	"reconnect.interval":     "reconnect-interval",
+	"reconnect.max_interval": "reconnect-max-interval",
+	"reconnect.max_attempts": "reconnect-max-attempts",
+	"confirm_mode":           "confirm-mode",
+}
+
+// newFlagSet 创建命令行参数集合
+func newFlagSet(defaults RuntimeConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("easyukey-client", pflag.ContinueOnError)
+	flags.String("config", "", "配置文件路径（默认为可执行文件同目录下的 "+ConfigFileName+"）")
+	flags.String("server-addr", defaults.ServerAddr, "服务器地址")
+	flags.Int("http-port", defaults.HTTPPort, "本地HTTP服务端口，0表示随机分配（只监听127.0.0.1）")
+	flags.String("proxy", defaults.Proxy, "连接服务器使用的代理地址（http、https或socks5）")
+	flags.String("log-level", defaults.Log.Level, "日志级别: debug, info, warn, error")
+	flags.String("log-file", defaults.Log.File, "日志文件路径")
+	flags.Bool("log-console", defaults.Log.Console, "是否同时输出日志到控制台")
+	flags.Duration("reconnect-interval", defaults.Reconnect.Interval, "首次断线重连间隔，之后按指数退避增长")
+	flags.Duration("reconnect-max-interval", defaults.Reconnect.MaxInterval, "断线重连间隔上限")
+	flags.Int("reconnect-max-attempts", defaults.Reconnect.MaxAttempts, "连续重连失败的最大次数，0表示不限制")
+	flags.String("confirm-mode", defaults.ConfirmMode, "确认方式: browser（浏览器页面）, terminal（终端交互）")
+	return flags
+}
+
+// setDefaults 设置默认配置值
+func setDefaults(v *viper.Viper, defaults RuntimeConfig) {
+	v.SetDefault("server_addr", defaults.ServerAddr)
+	v.SetDefault("http_port", defaults.HTTPPort)
+	v.SetDefault("proxy", defaults.Proxy)
+	v.SetDefault("log.level", defaults.Log.Level)
+	v.SetDefault("log.file", defaults.Log.File)
+	v.SetDefault("log.console", defaults.Log.Console)
+	v.SetDefault("reconnect.interval", defaults.Reconnect.Interval)
+	v.SetDefault("reconnect.max_interval", defaults.Reconnect.MaxInterval)
+	v.SetDefault("reconnect.max_attempts", defaults.Reconnect.MaxAttempts)
+	v.SetDefault("confirm_mode", defaults.ConfirmMode)
+}
+
+// Validate 验证配置有效性
+func (c *RuntimeConfig) Validate() error {
+	// 验证服务器配置
+	u, err := url.Parse(c.ServerAddr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("服务器地址必须是有效的http或https地址: %s", c.ServerAddr)
+	}
+	if c.HTTPPort < 0 || c.HTTPPort > 65535 {
+		return fmt.Errorf("本地HTTP服务端口必须在0-65535范围内，0表示随机分配")
+	}
+	if c.Proxy != "" {
+		p, err := url.Parse(c.Proxy)
+		if err != nil || p.Host == "" {
+			return fmt.Errorf("代理地址无效: %s", c.Proxy)
+		}
+		switch p.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("不支持的代理协议: %s", p.Scheme)
+		}
+	}
+
+	// 验证日志配置
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("日志级别必须是 debug, info, warn, error 之一")
+	}
+	if c.Log.File == "" {
+		return fmt.Errorf("日志文件路径不能为空")
+	}
+
+	// 验证重连配置
+	if c.Reconnect.Interval <= 0 {
+		return fmt.Errorf("重连间隔必须大于0")
+	}
+	if c.Reconnect.MaxInterval < c.Reconnect.Interval {
+		return fmt.Errorf("重连间隔上限不能小于首次重连间隔")
+	}
+	if c.Reconnect.MaxAttempts < 0 {
+		return fmt.Errorf("最大重连次数不能小于0")
+	}
+
+	// 验证交互配置
+	switch c.ConfirmMode {
+	case "browser", "terminal":
+	default:
+		return fmt.Errorf("确认方式必须是 browser 或 terminal")
+	}
+
+	return nil
+}
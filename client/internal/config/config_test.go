@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testDefaults() RuntimeConfig {
+	return RuntimeConfig{
+		ServerAddr: "http://127.0.0.1:8888",
+		HTTPPort:   HttpPort,
+		Log:        LogConfig{Level: "info", File: "logs/client.log", Console: true},
+		Reconnect:  ReconnectConfig{Interval: 5 * time.Second, MaxInterval: time.Minute},
+
+		ConfirmMode: "browser",
+	}
+}
+
+func writeConfigFile(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRuntimeConfigDefaults(t *testing.T) {
+	cfg, file, err := LoadRuntimeConfig(testDefaults(), t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != "" {
+		t.Errorf("配置文件不存在时不应返回路径: %s", file)
+	}
+	if cfg.ServerAddr != "http://127.0.0.1:8888" || cfg.HTTPPort != HttpPort || cfg.Reconnect.Interval != 5*time.Second {
+		t.Errorf("应使用编译时默认值: %+v", cfg)
+	}
+}
+
+func TestLoadRuntimeConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, dir, `
+server_addr: "https://file.example.com"
+http_port: 20000
+log:
+  level: "debug"
+reconnect:
+  interval: "3s"
+  max_attempts: 4
+`)
+	t.Setenv("EASYUKEY_CLIENT_HTTP_PORT", "21000")
+	t.Setenv("EASYUKEY_CLIENT_LOG_LEVEL", "warn")
+
+	cfg, file, err := LoadRuntimeConfig(testDefaults(), dir, []string{"--log-level", "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != filepath.Join(dir, ConfigFileName) {
+		t.Errorf("配置文件路径不符: %s", file)
+	}
+	if cfg.ServerAddr != "https://file.example.com" {
+		t.Errorf("配置文件应覆盖默认值: %s", cfg.ServerAddr)
+	}
+	if cfg.HTTPPort != 21000 {
+		t.Errorf("环境变量应覆盖配置文件: %d", cfg.HTTPPort)
+	}
+	if cfg.Log.Level != "error" {
+		t.Errorf("命令行参数应覆盖环境变量: %s", cfg.Log.Level)
+	}
+	if cfg.Log.File != "logs/client.log" || !cfg.Log.Console {
+		t.Errorf("未配置的项应保留默认值: %+v", cfg.Log)
+	}
+	if cfg.Reconnect.Interval != 3*time.Second || cfg.Reconnect.MaxAttempts != 4 {
+		t.Errorf("重连配置不符: %+v", cfg.Reconnect)
+	}
+}
+
+func TestLoadRuntimeConfigValidation(t *testing.T) {
+	cases := map[string][]string{
+		"无效服务器地址": {"--server-addr", "ftp://example.com"},
+		"端口超出范围":  {"--http-port", "70000"},
+		"无效日志级别":  {"--log-level", "verbose"},
+		"无效重连间隔":  {"--reconnect-interval", "0s"},
+		"重连上限过小":  {"--reconnect-interval", "10s", "--reconnect-max-interval", "5s"},
+		"不支持的代理":  {"--proxy", "ftp://proxy:21"},
+		"无效确认方式":  {"--confirm-mode", "gui"},
+	}
+	for name, args := range cases {
+		if _, _, err := LoadRuntimeConfig(testDefaults(), t.TempDir(), args); err == nil {
+			t.Errorf("%s: 期望验证失败", name)
+		}
+	}
+}
+
+func TestLoadRuntimeConfigMissingExplicitFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if _, _, err := LoadRuntimeConfig(testDefaults(), t.TempDir(), []string{"--config", missing}); err == nil {
+		t.Fatal("显式指定的配置文件不存在时应返回错误")
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/hang666/EasyUKey/shared/pkg/logger"
 )
 
+// browserConfirmer 通过本地HTTP服务和浏览器页面与用户交互
+type browserConfirmer struct{}
+
 // OpenBrowser 打开浏览器
 func OpenBrowser(url string) error {
 	var cmd string
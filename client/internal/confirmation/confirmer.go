@@ -0,0 +1,95 @@
+package confirmation
+
+import (
+	"fmt"
+	"time"
+)
+
+// 确认方式
+const (
+	ModeBrowser  = "browser"  // 浏览器页面
+	ModeTerminal = "terminal" // 终端交互，适用于服务器、SSH和自动化测试
+)
+
+// Confirmer 与用户交互的方式，负责PIN输入、认证确认和恢复码展示
+type Confirmer interface {
+	// PromptPIN 提示用户输入启动PIN，输入的PIN投递给PIN管理器
+	PromptPIN() error
+	// ShowAuthRequest 向用户展示认证请求
+	ShowAuthRequest(request *AuthRequest) error
+	// WaitForConfirmation 等待用户确认指定的请求，确认时输入的PIN随结果返回
+	WaitForConfirmation(request *AuthRequest, timeout time.Duration) (AuthConfirmation, error)
+	// SendResult 通知用户认证的最终结果
+	SendResult(requestID string, success bool, message string)
+	// ShowRecoveryCodes 向用户展示恢复码
+	ShowRecoveryCodes(codes []string) error
+}
+
+// current 当前使用的确认方式
+var current Confirmer = &browserConfirmer{}
+
+// Init 按确认方式初始化，port 为浏览器模式使用的本地HTTP服务端口
+func Init(mode string, port int) error {
+	serverPort = port
+
+	switch mode {
+	case ModeBrowser, "":
+		current = &browserConfirmer{}
+	case ModeTerminal:
+		current = NewTerminalConfirmer()
+	default:
+		return fmt.Errorf("不支持的确认方式: %s", mode)
+	}
+	return nil
+}
+
+// PromptPIN 提示用户输入启动PIN
+func PromptPIN() error {
+	return current.PromptPIN()
+}
+
+// ShowAuthRequest 将认证请求加入队列并展示给用户
+func ShowAuthRequest(request *AuthRequest) error {
+	if err := Enqueue(request); err != nil {
+		return err
+	}
+	if err := current.ShowAuthRequest(request); err != nil {
+		queue.remove(request.ID)
+		return err
+	}
+	return nil
+}
+
+// WaitForConfirmation 等待用户确认指定的认证请求
+func WaitForConfirmation(requestID string, timeout time.Duration) (AuthConfirmation, error) {
+	item := queue.lookup(requestID)
+	if item == nil {
+		return AuthConfirmation{}, fmt.Errorf("认证请求不存在")
+	}
+
+	confirmation, err := current.WaitForConfirmation(item.request, timeout)
+	if err != nil {
+		queue.remove(requestID)
+		return AuthConfirmation{}, err
+	}
+	queue.setState(requestID, StateProcessing)
+	return confirmation, nil
+}
+
+// SendResult 将认证结果投递给对应的请求并通知用户
+func SendResult(requestID string, success bool, message string) {
+	if item := queue.lookup(requestID); item != nil {
+		queue.setState(requestID, StateCompleted)
+		select {
+		case item.resultCh <- AuthResult{Success: success, Message: message, Timestamp: time.Now()}:
+		default:
+			// 结果已投递
+		}
+	}
+	current.SendResult(requestID, success, message)
+}
+
+// ShowRecoveryCodes 向用户展示恢复码
+func ShowRecoveryCodes(codes []string) error {
+	return current.ShowRecoveryCodes(codes)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"sync"
 	"time"
 )
@@ -22,6 +23,7 @@ type AuthRequest struct {
 type AuthConfirmation struct {
 	RequestID string    `json:"request_id"`
 	Confirmed bool      `json:"confirmed"`
+	PIN       string    `json:"-"` // 确认时输入的PIN，只在本进程内传递
 	Timestamp time.Time `json:"timestamp"`
 }
 
@@ -36,36 +38,22 @@ type AuthResult struct {
 type AuthState int
 
 const (
-	StateIdle       AuthState = iota // 空闲状态
+	StateIdle       AuthState = iota // 不在队列中
 	StateWaiting                     // 等待用户确认
 	StateProcessing                  // 正在处理认证
 	StateCompleted                   // 认证完成
 )
 
 var (
-	confirmChan  chan AuthConfirmation
-	resultChan   chan AuthResult
-	serverPort   int
-	currentState AuthState
-	currentReqID string
-	stateMutex   sync.RWMutex
-)
-
-// Init initializes the confirmation manager with the http server port.
-func Init(port int) {
-	confirmChan = make(chan AuthConfirmation, 1)
-	resultChan = make(chan AuthResult, 1)
-	serverPort = port
-	currentState = StateIdle
-}
+	serverPort int
 
-// ShowAuthRequest 显示认证请求（打开浏览器）
-func ShowAuthRequest(request *AuthRequest) error {
-	stateMutex.Lock()
-	currentState = StateWaiting
-	currentReqID = request.ID
-	stateMutex.Unlock()
+	// 待展示的恢复码，页面读取一次后即清空
+	pendingRecoveryCodes []string
+	recoveryMutex        sync.Mutex
+)
 
+// ShowAuthRequest 打开浏览器显示认证请求页面，每个请求有独立的页面
+func (b *browserConfirmer) ShowAuthRequest(request *AuthRequest) error {
 	// 将请求序列化为JSON
 	jsonData, err := json.Marshal(request)
 	if err != nil {
@@ -75,118 +63,74 @@ func ShowAuthRequest(request *AuthRequest) error {
 	// Base64编码
 	encodedData := base64.URLEncoding.EncodeToString(jsonData)
 
-	url := fmt.Sprintf("http://localhost:%d?request=%s", serverPort, encodedData)
-	return OpenBrowser(url)
+	token, err := PageTokens.Issue(AuthScope(request.ID), time.Until(request.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("无法生成页面令牌: %w", err)
+	}
+
+	link := pagePath("/", url.Values{"request": {encodedData}, "token": {token}})
+	queue.setLink(request.ID, link)
+
+	return OpenBrowser(pageURL(link))
 }
 
-// WaitForConfirmation 等待用户确认
-func WaitForConfirmation(timeout time.Duration) (AuthConfirmation, error) {
+// WaitForConfirmation 等待用户在该请求的页面上确认
+func (b *browserConfirmer) WaitForConfirmation(request *AuthRequest, timeout time.Duration) (AuthConfirmation, error) {
+	item := queue.lookup(request.ID)
+	if item == nil {
+		return AuthConfirmation{}, fmt.Errorf("认证请求不存在")
+	}
+
 	select {
-	case confirmation := <-confirmChan:
-		stateMutex.Lock()
-		if currentState == StateWaiting {
-			currentState = StateProcessing
-		}
-		stateMutex.Unlock()
+	case confirmation := <-item.confirmCh:
 		return confirmation, nil
 	case <-time.After(timeout):
-		stateMutex.Lock()
-		currentState = StateIdle
-		currentReqID = ""
-		stateMutex.Unlock()
 		return AuthConfirmation{}, fmt.Errorf("认证超时")
 	}
 }
 
-// SendConfirmation 发送认证确认结果
-func SendConfirmation(confirmation AuthConfirmation) {
-	stateMutex.RLock()
-	state := currentState
-	reqID := currentReqID
-	stateMutex.RUnlock()
-
-	// 检查当前状态和请求ID
-	if state != StateWaiting {
-		// 如果不是等待状态，忽略此确认
-		return
+// PromptPIN 显示PIN设置页面
+func (b *browserConfirmer) PromptPIN() error {
+	token, err := PageTokens.Issue(ScopePIN, pinTokenTTL)
+	if err != nil {
+		return fmt.Errorf("无法生成页面令牌: %w", err)
 	}
+	return OpenBrowser(pageURL(pagePath("/pin", url.Values{"token": {token}})))
+}
 
-	if reqID != confirmation.RequestID {
-		// 请求ID不匹配，忽略此确认
-		return
-	}
+// ShowRecoveryCodes 显示恢复码页面（打开浏览器），恢复码只会展示一次
+func (b *browserConfirmer) ShowRecoveryCodes(codes []string) error {
+	recoveryMutex.Lock()
+	pendingRecoveryCodes = codes
+	recoveryMutex.Unlock()
 
-	select {
-	case confirmChan <- confirmation:
-		// 成功发送
-	default:
-		// 通道阻塞或关闭，可能认证已超时
+	token, err := PageTokens.Issue(ScopeRecovery, recoveryTokenTTL)
+	if err != nil {
+		return fmt.Errorf("无法生成页面令牌: %w", err)
 	}
+	return OpenBrowser(pageURL(pagePath("/recovery-codes", url.Values{"token": {token}})))
 }
 
-// ShowPINSetupPage 显示PIN设置页面
-func ShowPINSetupPage() error {
-	url := fmt.Sprintf("http://localhost:%d/pin", serverPort)
-	return OpenBrowser(url)
-}
-
-// resetState 重置认证状态的辅助函数
-func resetState() {
-	stateMutex.Lock()
-	currentState = StateIdle
-	currentReqID = ""
-	stateMutex.Unlock()
+// pagePath 构造本地页面的相对地址
+func pagePath(path string, query url.Values) string {
+	u := url.URL{Path: path, RawQuery: query.Encode()}
+	return u.String()
 }
 
-// WaitForResult 等待认证结果
-func WaitForResult(timeout time.Duration) (AuthResult, error) {
-	deadline := time.Now().Add(timeout)
-
-	for {
-		select {
-		case result := <-resultChan:
-			if time.Since(result.Timestamp) > 10*time.Second {
-				continue // 数据已过期，继续等待
-			}
-			return result, nil
-		case <-time.After(time.Until(deadline)):
-			resetState()
-			return AuthResult{}, fmt.Errorf("认证超时")
-		}
-	}
+// pageURL 构造本地页面的完整地址，只使用回环地址
+func pageURL(link string) string {
+	return fmt.Sprintf("http://127.0.0.1:%d%s", serverPort, link)
 }
 
-// SendResult 发送认证结果
-func SendResult(success bool, message string) {
-	result := AuthResult{
-		Success:   success,
-		Message:   message,
-		Timestamp: time.Now(),
-	}
-
-	stateMutex.Lock()
-	currentState = StateCompleted
-	stateMutex.Unlock()
-
-	select {
-	case resultChan <- result:
-		// 成功发送
-	default:
-		// 防止阻塞
-	}
-}
+// TakeRecoveryCodes 取出待展示的恢复码并清空
+func TakeRecoveryCodes() []string {
+	recoveryMutex.Lock()
+	defer recoveryMutex.Unlock()
 
-// GetCurrentState 获取当前认证状态
-func GetCurrentState() (AuthState, string) {
-	stateMutex.RLock()
-	defer stateMutex.RUnlock()
-	return currentState, currentReqID
+	codes := pendingRecoveryCodes
+	pendingRecoveryCodes = nil
+	return codes
 }
 
-// ResetState 重置认证状态
-func ResetState() {
-	stateMutex.Lock()
-	defer stateMutex.Unlock()
-	currentState = StateIdle
-	currentReqID = ""
-}
+// SendResult 认证结果通过队列投递给等待中的页面，浏览器模式无需额外处理
+func (b *browserConfirmer) SendResult(requestID string, success bool, message string) {}
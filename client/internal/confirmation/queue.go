@@ -0,0 +1,174 @@
+package confirmation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// resultRetention 认证结束后请求在队列中保留的时间，用于拒绝重复提交
+const resultRetention = time.Minute
+
+// pendingAuth 队列中的认证请求，每个请求有独立的确认和结果通道
+type pendingAuth struct {
+	request   *AuthRequest
+	state     AuthState
+	link      string // 浏览器模式下该请求页面的相对地址
+	seq       uint64 // 到达顺序
+	confirmCh chan AuthConfirmation
+	resultCh  chan AuthResult
+}
+
+// PendingRequest 等待用户确认的认证请求，供页面展示
+type PendingRequest struct {
+	AuthRequest
+	Link      string
+	Remaining int64
+}
+
+// authQueue 按请求ID索引的认证请求队列
+type authQueue struct {
+	mu    sync.Mutex
+	items map[string]*pendingAuth
+	seq   uint64
+}
+
+var queue = &authQueue{items: make(map[string]*pendingAuth)}
+
+// Enqueue 将认证请求加入队列，同一请求ID只能加入一次
+func Enqueue(request *AuthRequest) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	queue.purgeLocked()
+	if _, ok := queue.items[request.ID]; ok {
+		return fmt.Errorf("认证请求已存在: %s", request.ID)
+	}
+	queue.seq++
+	queue.items[request.ID] = &pendingAuth{
+		request:   request,
+		state:     StateWaiting,
+		seq:       queue.seq,
+		confirmCh: make(chan AuthConfirmation, 1),
+		resultCh:  make(chan AuthResult, 1),
+	}
+	return nil
+}
+
+// lookup 查找队列中的认证请求
+func (q *authQueue) lookup(requestID string) *pendingAuth {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items[requestID]
+}
+
+// remove 从队列中移除认证请求
+func (q *authQueue) remove(requestID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.items, requestID)
+}
+
+// setState 更新认证请求状态
+func (q *authQueue) setState(requestID string, state AuthState) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if item, ok := q.items[requestID]; ok {
+		item.state = state
+	}
+}
+
+// setLink 记录认证请求页面地址
+func (q *authQueue) setLink(requestID, link string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if item, ok := q.items[requestID]; ok {
+		item.link = link
+	}
+}
+
+// purgeLocked 清理过期且超过保留时间的请求
+func (q *authQueue) purgeLocked() {
+	now := time.Now()
+	for id, item := range q.items {
+		if now.After(item.request.ExpiresAt.Add(resultRetention)) {
+			delete(q.items, id)
+		}
+	}
+}
+
+// GetState 获取指定认证请求的状态，不在队列中的请求返回 StateIdle
+func GetState(requestID string) AuthState {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if item, ok := queue.items[requestID]; ok {
+		return item.state
+	}
+	return StateIdle
+}
+
+// PendingRequests 返回所有等待用户确认的请求，按到达顺序排列
+func PendingRequests() []PendingRequest {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	queue.purgeLocked()
+	now := time.Now()
+	items := make([]*pendingAuth, 0, len(queue.items))
+	for _, item := range queue.items {
+		if item.state == StateWaiting && now.Before(item.request.ExpiresAt) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].seq < items[j].seq })
+
+	pending := make([]PendingRequest, 0, len(items))
+	for _, item := range items {
+		pending = append(pending, PendingRequest{
+			AuthRequest: *item.request,
+			Link:        item.link,
+			Remaining:   int64(item.request.ExpiresAt.Sub(now).Seconds()),
+		})
+	}
+	return pending
+}
+
+// SendConfirmation 将页面上的确认结果投递给对应的认证请求
+func SendConfirmation(confirmation AuthConfirmation) {
+	item := queue.lookup(confirmation.RequestID)
+	if item == nil {
+		return
+	}
+
+	queue.mu.Lock()
+	state := item.state
+	queue.mu.Unlock()
+
+	// 只有等待确认的请求才接受确认
+	if state != StateWaiting {
+		return
+	}
+
+	select {
+	case item.confirmCh <- confirmation:
+		// 成功发送
+	default:
+		// 已有确认结果，忽略重复提交
+	}
+}
+
+// WaitForResult 等待指定认证请求的最终结果
+func WaitForResult(requestID string, timeout time.Duration) (AuthResult, error) {
+	item := queue.lookup(requestID)
+	if item == nil {
+		return AuthResult{}, fmt.Errorf("认证请求不存在")
+	}
+
+	select {
+	case result := <-item.resultCh:
+		return result, nil
+	case <-time.After(timeout):
+		return AuthResult{}, fmt.Errorf("认证超时")
+	}
+}
@@ -0,0 +1,124 @@
+package confirmation
+
+import (
+	"testing"
+	"time"
+)
+
+func newQueuedRequest(t *testing.T, id string, ttl time.Duration) *AuthRequest {
+	t.Helper()
+	request := &AuthRequest{
+		ID:        id,
+		UserID:    "user-" + id,
+		Timestamp: time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := Enqueue(request); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queue.remove(id) })
+	return request
+}
+
+func TestQueueConcurrentRequests(t *testing.T) {
+	current = &browserConfirmer{}
+	newQueuedRequest(t, "q-1", time.Minute)
+	newQueuedRequest(t, "q-2", time.Minute)
+
+	type outcome struct {
+		confirmation AuthConfirmation
+		err          error
+	}
+	results := map[string]chan outcome{"q-1": make(chan outcome, 1), "q-2": make(chan outcome, 1)}
+	for id, ch := range results {
+		go func(id string, ch chan outcome) {
+			c, err := WaitForConfirmation(id, time.Second)
+			ch <- outcome{c, err}
+		}(id, ch)
+	}
+
+	// 第二个请求先被确认，不应影响第一个请求
+	SendConfirmation(AuthConfirmation{RequestID: "q-2", Confirmed: true, PIN: "222222"})
+	SendConfirmation(AuthConfirmation{RequestID: "q-1", Confirmed: false})
+
+	r2 := <-results["q-2"]
+	r1 := <-results["q-1"]
+	if r2.err != nil || !r2.confirmation.Confirmed || r2.confirmation.PIN != "222222" {
+		t.Fatalf("q-2 确认结果不符: %+v", r2)
+	}
+	if r1.err != nil || r1.confirmation.Confirmed {
+		t.Fatalf("q-1 确认结果不符: %+v", r1)
+	}
+
+	if GetState("q-1") != StateProcessing || GetState("q-2") != StateProcessing {
+		t.Fatal("确认后请求应进入处理中状态")
+	}
+
+	// 每个请求有独立的结果通道
+	SendResult("q-1", false, "用户拒绝认证")
+	SendResult("q-2", true, "认证成功")
+
+	res2, err := WaitForResult("q-2", time.Second)
+	if err != nil || !res2.Success {
+		t.Fatalf("q-2 结果不符: %+v %v", res2, err)
+	}
+	res1, err := WaitForResult("q-1", time.Second)
+	if err != nil || res1.Success {
+		t.Fatalf("q-1 结果不符: %+v %v", res1, err)
+	}
+	if GetState("q-1") != StateCompleted {
+		t.Fatal("发送结果后请求应为完成状态")
+	}
+}
+
+func TestQueueRejectsDuplicateAndUnknown(t *testing.T) {
+	request := newQueuedRequest(t, "q-dup", time.Minute)
+	if err := Enqueue(request); err == nil {
+		t.Fatal("重复的请求ID应被拒绝")
+	}
+
+	// 未知请求的确认被忽略
+	SendConfirmation(AuthConfirmation{RequestID: "q-unknown", Confirmed: true})
+	if GetState("q-unknown") != StateIdle {
+		t.Fatal("未知请求不应出现在队列中")
+	}
+	if _, err := WaitForConfirmation("q-unknown", 10*time.Millisecond); err == nil {
+		t.Fatal("等待未知请求应返回错误")
+	}
+}
+
+func TestQueueTimeoutRemovesRequest(t *testing.T) {
+	current = &browserConfirmer{}
+	newQueuedRequest(t, "q-timeout", time.Minute)
+
+	if _, err := WaitForConfirmation("q-timeout", 20*time.Millisecond); err == nil {
+		t.Fatal("无确认时应超时")
+	}
+	if GetState("q-timeout") != StateIdle {
+		t.Fatal("超时的请求应从队列移除")
+	}
+}
+
+func TestPendingRequestsOrderAndFilter(t *testing.T) {
+	newQueuedRequest(t, "p-1", time.Minute)
+	newQueuedRequest(t, "p-2", time.Minute)
+	newQueuedRequest(t, "p-3", time.Minute)
+	queue.setState("p-2", StateProcessing)
+	queue.setLink("p-3", "/?token=abc")
+
+	pending := PendingRequests()
+	var ids []string
+	for _, p := range pending {
+		if p.ID[0] == 'p' {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) != 2 || ids[0] != "p-1" || ids[1] != "p-3" {
+		t.Fatalf("待确认列表不符: %v", ids)
+	}
+	for _, p := range pending {
+		if p.ID == "p-3" && (p.Link != "/?token=abc" || p.Remaining <= 0) {
+			t.Fatalf("待确认请求信息不符: %+v", p)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package confirmation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/hang666/EasyUKey/client/internal/global"
+	"github.com/hang666/EasyUKey/client/internal/pin"
+	"github.com/hang666/EasyUKey/shared/pkg/identity"
+)
+
+// pinPromptTimeout 启动时输入PIN的超时时间，与PIN管理器保持一致
+const pinPromptTimeout = 60 * time.Second
+
+var errInputTimeout = errors.New("输入超时")
+
+// terminalConfirmer 通过终端与用户交互，从TTY读取确认和PIN
+type terminalConfirmer struct {
+	out        io.Writer
+	in         *bufio.Reader
+	readSecret func() (string, error) // 不回显读取，输入不是终端时为nil
+
+	requests chan readRequest
+	once     sync.Once
+
+	promptMu sync.Mutex // 同一时间只进行一个交互，多个认证请求依次处理
+}
+
+type readRequest struct {
+	secret bool
+	result chan readResult
+}
+
+type readResult struct {
+	line string
+	err  error
+}
+
+// NewTerminalConfirmer 创建使用标准输入输出的终端确认方式
+func NewTerminalConfirmer() Confirmer {
+	var readSecret func() (string, error)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		readSecret = func() (string, error) {
+			b, err := term.ReadPassword(fd)
+			fmt.Fprintln(os.Stdout)
+			return string(b), err
+		}
+	}
+	return newTerminalConfirmer(os.Stdin, os.Stdout, readSecret)
+}
+
+func newTerminalConfirmer(in io.Reader, out io.Writer, readSecret func() (string, error)) *terminalConfirmer {
+	return &terminalConfirmer{
+		out:        out,
+		in:         bufio.NewReader(in),
+		readSecret: readSecret,
+		requests:   make(chan readRequest),
+	}
+}
+
+// PromptPIN 在终端中输入启动PIN，已初始化的设备会立即校验PIN
+func (t *terminalConfirmer) PromptPIN() error {
+	t.promptMu.Lock()
+	defer t.promptMu.Unlock()
+
+	deadline := time.Now().Add(pinPromptTimeout)
+
+	if identity.IsInitialized(global.SecureStoragePath) {
+		code, err := t.readPIN("请输入PIN: ", deadline)
+		if err != nil {
+			return err
+		}
+		if _, err := identity.GetTOTPSecret(code, global.Config.EncryptKeyStr, global.SecureStoragePath); err != nil {
+			fmt.Fprintln(t.out, "PIN验证失败，请检查PIN是否正确")
+			return fmt.Errorf("PIN验证失败: %w", err)
+		}
+		global.PinManager.SendPIN(code)
+		fmt.Fprintln(t.out, "PIN验证成功，正在连接服务器...")
+		return nil
+	}
+
+	// 设备未初始化，设置新PIN并可选输入恢复码
+	fmt.Fprintln(t.out, "设备尚未初始化，请设置6位数字PIN")
+	var code string
+	for {
+		first, err := t.readPIN("请设置PIN: ", deadline)
+		if err != nil {
+			return err
+		}
+		second, err := t.readLine("请再次输入PIN: ", true, deadline)
+		if err != nil {
+			return err
+		}
+		if first == second {
+			code = first
+			break
+		}
+		fmt.Fprintln(t.out, "两次输入的PIN不一致，请重新输入")
+	}
+
+	recoveryCode, err := t.readLine("如需使用恢复码接管原设备组请输入恢复码，否则直接回车: ", false, deadline)
+	if err != nil {
+		return err
+	}
+
+	global.SetRecoveryCode(recoveryCode)
+	global.PinManager.SendPIN(code)
+
+	if recoveryCode != "" {
+		fmt.Fprintln(t.out, "PIN设置成功，正在使用恢复码接管原设备组...")
+	} else {
+		fmt.Fprintln(t.out, "PIN设置成功，正在初始化设备...")
+	}
+	return nil
+}
+
+// ShowAuthRequest 提示收到新的认证请求，详情在轮到该请求时显示
+func (t *terminalConfirmer) ShowAuthRequest(request *AuthRequest) error {
+	fmt.Fprintf(t.out, "\n收到认证请求 %s（用户 %s），等待处理\n", request.ID, request.UserID)
+	return nil
+}
+
+// WaitForConfirmation 显示请求详情，读取y/n确认，确认后读取PIN
+func (t *terminalConfirmer) WaitForConfirmation(request *AuthRequest, timeout time.Duration) (AuthConfirmation, error) {
+	deadline := time.Now().Add(timeout)
+	if request.ExpiresAt.Before(deadline) {
+		deadline = request.ExpiresAt
+	}
+
+	t.promptMu.Lock()
+	defer t.promptMu.Unlock()
+
+	// 排队等待期间请求可能已过期
+	if !time.Now().Before(deadline) {
+		return AuthConfirmation{}, t.inputError(errInputTimeout)
+	}
+
+	fmt.Fprintln(t.out)
+	fmt.Fprintln(t.out, "========== EasyUKey 认证请求 ==========")
+	fmt.Fprintf(t.out, "请求ID: %s\n", request.ID)
+	fmt.Fprintf(t.out, "用户:   %s\n", request.UserID)
+	if request.Message != "" {
+		fmt.Fprintf(t.out, "说明:   %s\n", request.Message)
+	}
+	fmt.Fprintf(t.out, "有效期: %d秒（%s 前）\n",
+		int64(time.Until(request.ExpiresAt).Seconds()), request.ExpiresAt.Format("15:04:05"))
+	fmt.Fprintln(t.out, "=======================================")
+
+	answer, err := t.readLine("是否确认该认证请求？[y/N]: ", false, deadline)
+	if err != nil {
+		return AuthConfirmation{}, t.inputError(err)
+	}
+
+	result := AuthConfirmation{
+		RequestID: request.ID,
+		Timestamp: time.Now(),
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+	default:
+		fmt.Fprintln(t.out, "已拒绝认证请求")
+		return result, nil
+	}
+
+	code, err := t.readPIN("请输入PIN: ", deadline)
+	if err != nil {
+		return AuthConfirmation{}, t.inputError(err)
+	}
+
+	fmt.Fprintln(t.out, "正在认证，请稍候...")
+
+	result.Confirmed = true
+	result.PIN = code
+	result.Timestamp = time.Now()
+	return result, nil
+}
+
+// inputError 提示并转换输入错误
+func (t *terminalConfirmer) inputError(err error) error {
+	if errors.Is(err, errInputTimeout) {
+		fmt.Fprintln(t.out, "\n认证请求已超时")
+		return fmt.Errorf("认证超时")
+	}
+	return fmt.Errorf("读取终端输入失败: %w", err)
+}
+
+// SendResult 在终端中显示认证结果
+func (t *terminalConfirmer) SendResult(requestID string, success bool, message string) {
+	if success {
+		fmt.Fprintf(t.out, "认证请求 %s 成功: %s\n", requestID, message)
+	} else {
+		fmt.Fprintf(t.out, "认证请求 %s 失败: %s\n", requestID, message)
+	}
+}
+
+// ShowRecoveryCodes 在终端中显示恢复码
+func (t *terminalConfirmer) ShowRecoveryCodes(codes []string) error {
+	fmt.Fprintln(t.out)
+	fmt.Fprintln(t.out, "========== 设备组恢复码 ==========")
+	fmt.Fprintln(t.out, "U盘丢失时可使用恢复码在新U盘上接管设备组，每个恢复码只能使用一次。")
+	fmt.Fprintln(t.out, "恢复码仅显示这一次，请立即妥善保存：")
+	for i, code := range codes {
+		fmt.Fprintf(t.out, "  %2d. %s\n", i+1, code)
+	}
+	fmt.Fprintln(t.out, "==================================")
+	return nil
+}
+
+// readPIN 读取PIN，格式错误时在截止时间前重新提示
+func (t *terminalConfirmer) readPIN(prompt string, deadline time.Time) (string, error) {
+	for {
+		code, err := t.readLine(prompt, true, deadline)
+		if err != nil {
+			return "", err
+		}
+		if err := pin.ValidatePIN(code); err != nil {
+			fmt.Fprintln(t.out, err.Error())
+			continue
+		}
+		return code, nil
+	}
+}
+
+// readLine 显示提示并读取一行输入，secret为true时不回显
+// 所有读取由同一个goroutine完成；超时后该次读取的输入会被丢弃
+func (t *terminalConfirmer) readLine(prompt string, secret bool, deadline time.Time) (string, error) {
+	t.once.Do(func() { go t.readLoop() })
+
+	fmt.Fprint(t.out, prompt)
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	req := readRequest{secret: secret, result: make(chan readResult, 1)}
+	select {
+	case t.requests <- req:
+	case <-timer.C:
+		return "", errInputTimeout
+	}
+
+	select {
+	case res := <-req.result:
+		return res.line, res.err
+	case <-timer.C:
+		return "", errInputTimeout
+	}
+}
+
+// readLoop 按请求从终端读取输入
+func (t *terminalConfirmer) readLoop() {
+	for req := range t.requests {
+		var line string
+		var err error
+		if req.secret && t.readSecret != nil {
+			line, err = t.readSecret()
+		} else {
+			line, err = t.in.ReadString('\n')
+			if err == io.EOF && line != "" {
+				err = nil
+			}
+		}
+		req.result <- readResult{line: strings.TrimSpace(line), err: err}
+	}
+}
@@ -0,0 +1,109 @@
+package confirmation
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRequest(ttl time.Duration) *AuthRequest {
+	return &AuthRequest{
+		ID:        "req-1",
+		UserID:    "alice",
+		Message:   "登录管理后台",
+		Timestamp: time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
+
+func TestTerminalConfirmAndPIN(t *testing.T) {
+	var out bytes.Buffer
+	tc := newTerminalConfirmer(strings.NewReader("y\n12ab\n123456\n"), &out, nil)
+
+	request := newTestRequest(time.Minute)
+	if err := tc.ShowAuthRequest(request); err != nil {
+		t.Fatal(err)
+	}
+	result, err := tc.WaitForConfirmation(request, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Confirmed || result.RequestID != "req-1" {
+		t.Fatalf("确认结果不符: %+v", result)
+	}
+	if result.PIN != "123456" {
+		t.Fatalf("PIN应随确认结果返回: %q", result.PIN)
+	}
+	if !strings.Contains(out.String(), "alice") || !strings.Contains(out.String(), "登录管理后台") {
+		t.Errorf("应显示请求详情: %s", out.String())
+	}
+}
+
+func TestTerminalReject(t *testing.T) {
+	tc := newTerminalConfirmer(strings.NewReader("n\n"), io.Discard, nil)
+
+	request := newTestRequest(time.Minute)
+	tc.ShowAuthRequest(request)
+	result, err := tc.WaitForConfirmation(request, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Confirmed {
+		t.Fatal("输入n应拒绝认证")
+	}
+}
+
+func TestTerminalTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	tc := newTerminalConfirmer(r, io.Discard, nil)
+
+	request := newTestRequest(time.Minute)
+	tc.ShowAuthRequest(request)
+	start := time.Now()
+	if _, err := tc.WaitForConfirmation(request, 50*time.Millisecond); err == nil {
+		t.Fatal("无输入时应超时")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("超时未生效")
+	}
+}
+
+func TestTerminalRespectsRequestExpiry(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	tc := newTerminalConfirmer(r, io.Discard, nil)
+
+	// 请求的过期时间早于传入的超时时间时，以请求过期时间为准
+	request := newTestRequest(50 * time.Millisecond)
+	tc.ShowAuthRequest(request)
+	start := time.Now()
+	if _, err := tc.WaitForConfirmation(request, time.Minute); err == nil {
+		t.Fatal("请求过期后应超时")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("未按请求过期时间超时")
+	}
+}
+
+func TestTerminalHandlesQueuedRequestsInOrder(t *testing.T) {
+	var out bytes.Buffer
+	tc := newTerminalConfirmer(strings.NewReader("n\ny\n654321\n"), &out, nil)
+
+	first := newTestRequest(time.Minute)
+	second := newTestRequest(time.Minute)
+	second.ID = "req-2"
+	tc.ShowAuthRequest(first)
+	tc.ShowAuthRequest(second)
+
+	r1, err := tc.WaitForConfirmation(first, time.Minute)
+	if err != nil || r1.Confirmed || r1.RequestID != "req-1" {
+		t.Fatalf("第一个请求应被拒绝: %+v %v", r1, err)
+	}
+	r2, err := tc.WaitForConfirmation(second, time.Minute)
+	if err != nil || !r2.Confirmed || r2.RequestID != "req-2" || r2.PIN != "654321" {
+		t.Fatalf("第二个请求应被确认: %+v %v", r2, err)
+	}
+}
@@ -0,0 +1,107 @@
+package confirmation
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// 页面令牌的作用范围
+const (
+	ScopePIN      = "pin"      // PIN设置页面
+	ScopeRecovery = "recovery" // 恢复码展示页面
+)
+
+// 页面令牌有效期
+const (
+	pinTokenTTL      = 10 * time.Minute
+	recoveryTokenTTL = 10 * time.Minute
+)
+
+// AuthScope 认证请求页面令牌的作用范围，令牌只对指定请求有效
+func AuthScope(requestID string) string {
+	return "auth:" + requestID
+}
+
+// PageTokens 嵌入在浏览器打开的URL中的访问令牌，其他网页无法猜测，也就无法直接访问本地页面
+var PageTokens = NewTokenStore()
+
+// TokenStore 带作用范围和有效期的随机令牌存储
+type TokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]scopedToken
+}
+
+type scopedToken struct {
+	scope     string
+	expiresAt time.Time
+}
+
+// NewTokenStore 创建令牌存储
+func NewTokenStore() *TokenStore {
+	return &TokenStore{tokens: make(map[string]scopedToken)}
+}
+
+// Issue 签发指定作用范围的令牌
+func (s *TokenStore) Issue(scope string, ttl time.Duration) (string, error) {
+	token, err := NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeLocked()
+	s.tokens[token] = scopedToken{scope: scope, expiresAt: time.Now().Add(ttl)}
+	return token, nil
+}
+
+// Valid 检查令牌是否在有效期内且属于指定作用范围
+func (s *TokenStore) Valid(token, scope string) bool {
+	if token == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok || time.Now().After(t.expiresAt) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(t.scope), []byte(scope)) == 1
+}
+
+// Revoke 吊销指定作用范围的所有令牌
+func (s *TokenStore) Revoke(scope string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, t := range s.tokens {
+		if t.scope == scope {
+			delete(s.tokens, token)
+		}
+	}
+}
+
+// purgeLocked 清理过期令牌
+func (s *TokenStore) purgeLocked() {
+	now := time.Now()
+	for token, t := range s.tokens {
+		if now.After(t.expiresAt) {
+			delete(s.tokens, token)
+		}
+	}
+}
+
+// NewRandomToken 生成256位URL安全的随机令牌
+func NewRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -1,9 +1,7 @@
 package device
 
 import (
-	"bufio"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -15,6 +13,10 @@ import (
 
 const (
 	deviceCheckInterval = 10 * time.Second
+	// deviceFallbackInterval 有设备事件通知时的兜底检测间隔
+	deviceFallbackInterval = time.Minute
+	// deviceEventDebounce 设备插拔会连续产生多条事件，合并后再检测
+	deviceEventDebounce = 500 * time.Millisecond
 	maxUnfindTime       = 10
 )
 
@@ -22,6 +24,12 @@ var (
 	unfindTime int
 	DeviceInfo DeviceInfoType
 	doneChan   chan struct{}
+
+	// 拔出状态和回调
+	removalMu  sync.Mutex
+	removed    bool
+	onRemoved  func()
+	onRestored func()
 )
 
 // DeviceInfoType 设备信息类型，提供线程安全的设备信息访问
@@ -46,23 +54,139 @@ func init() {
 	doneChan = make(chan struct{})
 }
 
-// StartTimer 启动设备检测定时器
+// SetRemovalHandlers 设置U盘被拔出和重新插入时的回调，回调在设备检测协程中同步执行
+func SetRemovalHandlers(removedFn, restoredFn func()) {
+	removalMu.Lock()
+	defer removalMu.Unlock()
+	onRemoved = removedFn
+	onRestored = restoredFn
+}
+
+// IsRemoved 判断U盘当前是否处于拔出状态
+func IsRemoved() bool {
+	removalMu.Lock()
+	defer removalMu.Unlock()
+	return removed
+}
+
+// markRemoved 进入拔出状态，只在状态变化时触发回调
+func markRemoved() {
+	removalMu.Lock()
+	if removed || DeviceInfo.GetDevice() == nil {
+		removalMu.Unlock()
+		return
+	}
+	removed = true
+	fn := onRemoved
+	removalMu.Unlock()
+
+	logger.Logger.Warn("U盘已拔出")
+	if fn != nil {
+		fn()
+	}
+}
+
+// markPresent 找到设备，首次找到时记录设备信息，拔出后重新插入同一设备时触发回调
+// 返回 false 表示找到的不是启动时的设备
+func markPresent(device *uid.USBDevice) bool {
+	current := DeviceInfo.GetDevice()
+	if current == nil {
+		DeviceInfo.SetDevice(device)
+		return true
+	}
+	if current.SerialNumber != device.SerialNumber || current.VolumeSerialNumber != device.VolumeSerialNumber {
+		return false
+	}
+
+	removalMu.Lock()
+	if !removed {
+		removalMu.Unlock()
+		return true
+	}
+	removed = false
+	fn := onRestored
+	removalMu.Unlock()
+
+	logger.Logger.Info("U盘已重新插入")
+	if fn != nil {
+		fn()
+	}
+	return true
+}
+
+// isOwnDevice 判断事件中的设备节点是否为当前设备或其所在的磁盘，如 /dev/sdb 对应 /dev/sdb1
+func isOwnDevice(deviceNode, eventNode string) bool {
+	if deviceNode == "" || eventNode == "" {
+		return false
+	}
+	if deviceNode == eventNode {
+		return true
+	}
+
+	suffix, ok := strings.CutPrefix(deviceNode, eventNode)
+	if !ok {
+		return false
+	}
+	// 分区号可能带 p 前缀，如 /dev/mmcblk0p1、/dev/nvme0n1p1
+	suffix = strings.TrimPrefix(suffix, "p")
+	return suffix != "" && strings.Trim(suffix, "0123456789") == ""
+}
+
+// StartTimer 启动设备检测，优先由设备变化事件驱动，不支持事件通知时定时检测
 func StartTimer(exeDir string) {
-	ticker := time.NewTicker(deviceCheckInterval)
+	CheckDevice(exeDir)
+
+	events, err := uid.WatchDevices(doneChan)
+	if err != nil {
+		logger.Logger.Warn("设备变化监听不可用，使用定时检测", "error", err)
+	}
+
+	ticker := time.NewTicker(checkInterval(events))
 	defer ticker.Stop()
 
-	CheckDevice(exeDir)
+	debounce := time.NewTimer(deviceEventDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
 
 	for {
 		select {
+		case event, ok := <-events:
+			if !ok {
+				// 事件来源已关闭，退回定时检测
+				events = nil
+				ticker.Reset(checkInterval(events))
+				continue
+			}
+			logger.Logger.Debug("检测到设备变化", "action", event.Action, "device", event.DeviceNode)
+
+			// 拔出时 sysfs 可能尚未更新，收到当前设备的移除事件直接进入拔出状态
+			dev := DeviceInfo.GetDevice()
+			if event.Action == uid.DeviceRemoved && dev != nil && isOwnDevice(dev.DeviceNode, event.DeviceNode) {
+				markRemoved()
+				ticker.Reset(checkInterval(events))
+				continue
+			}
+			debounce.Reset(deviceEventDebounce)
+		case <-debounce.C:
+			CheckDevice(exeDir)
+			ticker.Reset(checkInterval(events))
 		case <-ticker.C:
 			CheckDevice(exeDir)
+			ticker.Reset(checkInterval(events))
 		case <-doneChan:
 			return
 		}
 	}
 }
 
+// checkInterval 定时检测间隔，有事件通知时只需兜底检测，拔出期间需要定时检测以发现重新插入或超时退出
+func checkInterval(events <-chan uid.DeviceEvent) time.Duration {
+	if events == nil || IsRemoved() {
+		return deviceCheckInterval
+	}
+	return deviceFallbackInterval
+}
+
 // StopTimer 停止设备检测定时器
 func StopTimer() {
 	close(doneChan)
@@ -82,7 +206,9 @@ func CheckDevice(exeDir string) {
 		device, err = uid.FindDeviceByDrive(drive)
 	}
 
-	if err != nil || device == nil || device.SerialNumber == "" {
+	if err != nil || device == nil || device.SerialNumber == "" || !markPresent(device) {
+		// 设备消失即锁定，超过次数仍未重新插入时退出
+		markRemoved()
 		unfindTime++
 		if unfindTime > maxUnfindTime {
 			logger.Logger.Error("未找到设备，程序退出", "unfind_time", unfindTime, "max_time", maxUnfindTime)
@@ -91,9 +217,6 @@ func CheckDevice(exeDir string) {
 		return
 	}
 
-	if DeviceInfo.GetDevice() == nil {
-		DeviceInfo.SetDevice(device)
-	}
 	unfindTime = 0
 }
 
@@ -105,47 +228,6 @@ func findDeviceByExecutablePath(exePath string) (*uid.USBDevice, error) {
 		return nil, err
 	}
 
-	// 使用 df 命令获取路径对应的文件系统设备
-	devicePath, err := getDevicePathByDF(absPath)
-	if err != nil {
-		return nil, err
-	}
-
-	// 根据设备路径查找USB设备
-	return uid.FindDeviceByDevicePath(devicePath)
-}
-
-// getDevicePathByDF 使用 df 命令获取指定路径的文件系统设备路径
-func getDevicePathByDF(path string) (string, error) {
-	// 使用 df 命令获取路径信息，输出格式：设备 大小 已用 可用 使用率 挂载点
-	cmd := exec.Command("df", path)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-
-	// 解析 df 输出
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	lineCount := 0
-	for scanner.Scan() {
-		lineCount++
-		if lineCount == 1 {
-			// 跳过标题行
-			continue
-		}
-
-		line := scanner.Text()
-		fields := strings.Fields(line)
-
-		// df 输出可能因为设备名太长而换行，需要处理这种情况
-		if len(fields) == 1 {
-			// 设备名在单独一行，返回设备路径
-			return fields[0], nil
-		} else if len(fields) >= 6 {
-			// 正常情况，设备路径在第1个字段
-			return fields[0], nil
-		}
-	}
-
-	return "", os.ErrNotExist
+	// 根据挂载点查找路径所在的USB设备
+	return uid.FindDeviceByPath(absPath)
 }
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/hang666/EasyUKey/client/utils/uid"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+func TestIsOwnDevice(t *testing.T) {
+	tests := []struct {
+		device, event string
+		want          bool
+	}{
+		{"/dev/sdb1", "/dev/sdb1", true},
+		{"/dev/sdb1", "/dev/sdb", true},
+		{"/dev/mmcblk0p1", "/dev/mmcblk0", true},
+		{"/dev/nvme0n1p2", "/dev/nvme0n1", true},
+		{"/dev/sdb1", "/dev/sda", false},
+		{"/dev/sdb10", "/dev/sdb1", true}, // 分区号只能按前缀判断，宁可多锁定也不漏掉
+		{"/dev/sdbb1", "/dev/sdb", false},
+		{"/dev/sdb1", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := isOwnDevice(tt.device, tt.event); got != tt.want {
+			t.Errorf("isOwnDevice(%q, %q) = %v, 期望 %v", tt.device, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestRemovalHandlers(t *testing.T) {
+	if logger.Logger == nil {
+		logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	original := &uid.USBDevice{SerialNumber: "SN1", VolumeSerialNumber: "VOL1", DeviceNode: "/dev/sdb1"}
+	DeviceInfo.SetDevice(original)
+	defer func() {
+		DeviceInfo.SetDevice(nil)
+		SetRemovalHandlers(nil, nil)
+		removed = false
+	}()
+
+	var removedCalls, restoredCalls int
+	SetRemovalHandlers(func() { removedCalls++ }, func() { restoredCalls++ })
+
+	markRemoved()
+	markRemoved()
+	if !IsRemoved() || removedCalls != 1 {
+		t.Fatalf("拔出回调应只触发一次, 实际 %d", removedCalls)
+	}
+
+	// 插入其他U盘不能解除锁定
+	if markPresent(&uid.USBDevice{SerialNumber: "SN2", VolumeSerialNumber: "VOL2"}) {
+		t.Error("其他设备不应被视为原设备")
+	}
+	if !IsRemoved() || restoredCalls != 0 {
+		t.Fatal("插入其他设备后应保持锁定")
+	}
+
+	if !markPresent(&uid.USBDevice{SerialNumber: "SN1", VolumeSerialNumber: "VOL1", DeviceNode: "/dev/sdc1"}) {
+		t.Fatal("重新插入原设备应被识别")
+	}
+	if IsRemoved() || restoredCalls != 1 {
+		t.Errorf("重新插入后应解除锁定并触发回调, 实际 %d", restoredCalls)
+	}
+}
@@ -1,6 +1,8 @@
 package global
 
 import (
+	"sync"
+
 	"github.com/hang666/EasyUKey/client/internal/config"
 	"github.com/hang666/EasyUKey/client/internal/pin"
 )
@@ -13,3 +15,22 @@ var PinManager *pin.PINManager
 
 // SecureStoragePath 全局安全存储路径
 var SecureStoragePath string
+
+var (
+	recoveryCodeMu sync.Mutex
+	recoveryCode   string // 初始化时输入的恢复码，用于接管丢失U盘的原设备组
+)
+
+// SetRecoveryCode 保存初始化时输入的恢复码，由本地确认页面或终端写入
+func SetRecoveryCode(code string) {
+	recoveryCodeMu.Lock()
+	defer recoveryCodeMu.Unlock()
+	recoveryCode = code
+}
+
+// RecoveryCode 返回初始化时输入的恢复码，未输入时为空
+func RecoveryCode() string {
+	recoveryCodeMu.Lock()
+	defer recoveryCodeMu.Unlock()
+	return recoveryCode
+}
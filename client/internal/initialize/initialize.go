@@ -7,9 +7,10 @@ import (
 )
 
 // InitAll 初始化所有组件
-func InitAll(encryptKeyStr, serverAddr, logLevel, logFile, logConsole, devMode string) error {
+// args 为命令行参数，用于覆盖配置文件和编译时注入的默认值
+func InitAll(encryptKeyStr, serverAddr, serverPublicKey, version, logLevel, logFile, logConsole, devMode string, args []string) error {
 	// 初始化配置
-	cfg, err := config.InitConfig(encryptKeyStr, serverAddr, logLevel, logFile, logConsole, devMode)
+	cfg, err := config.InitConfig(encryptKeyStr, serverAddr, serverPublicKey, version, logLevel, logFile, logConsole, devMode, args)
 	if err != nil {
 		return err
 	}
@@ -21,7 +22,10 @@ func InitAll(encryptKeyStr, serverAddr, logLevel, logFile, logConsole, devMode s
 		return err
 	}
 
-	logger.Logger.Info("客户端初始化完成")
+	if cfg.ConfigFile != "" {
+		logger.Logger.Info("已加载配置文件", "file", cfg.ConfigFile)
+	}
+	logger.Logger.Info("客户端初始化完成", "version", cfg.Version)
 
 	return nil
 }
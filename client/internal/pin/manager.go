@@ -6,44 +6,60 @@ import (
 )
 
 // PINManager PIN管理器，负责PIN的临时传递和超时控制
+// PIN以字节切片保存，取出或清除后立即清零
 type PINManager struct {
-	pinChan chan string
+	pinChan chan []byte
 	timeout time.Duration
 }
 
 // NewPINManager 创建新的PIN管理器
 func NewPINManager() *PINManager {
 	return &PINManager{
-		pinChan: make(chan string, 1),
+		pinChan: make(chan []byte, 1),
 		timeout: 60 * time.Second,
 	}
 }
 
 // SendPIN 发送PIN到通道
 func (pm *PINManager) SendPIN(pin string) {
+	b := []byte(pin)
 	select {
-	case pm.pinChan <- pin:
+	case pm.pinChan <- b:
 		// PIN发送成功
 	default:
 		// 通道已满，丢弃之前的PIN
-		select {
-		case <-pm.pinChan:
-		default:
-		}
-		pm.pinChan <- pin
+		pm.Wipe()
+		pm.pinChan <- b
 	}
 }
 
 // WaitPIN 等待PIN输入，带超时控制
 func (pm *PINManager) WaitPIN() (string, error) {
 	select {
-	case pin := <-pm.pinChan:
+	case b := <-pm.pinChan:
+		pin := string(b)
+		clear(b)
 		return pin, nil
 	case <-time.After(pm.timeout):
 		return "", fmt.Errorf("PIN输入超时")
 	}
 }
 
+// Wipe 清除并清零尚未取出的PIN，U盘被拔出时调用
+func (pm *PINManager) Wipe() {
+	for {
+		select {
+		case b, ok := <-pm.pinChan:
+			if !ok {
+				return
+			}
+			clear(b)
+		default:
+			return
+		}
+	}
+}
+
 // Close 关闭PIN管理器
 func (pm *PINManager) Close() {
 	close(pm.pinChan)
@@ -0,0 +1,49 @@
+package pin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWipeClearsPendingPIN(t *testing.T) {
+	pm := NewPINManager()
+	pm.timeout = 10 * time.Millisecond
+
+	pm.SendPIN("123456")
+	b := <-pm.pinChan
+	pm.pinChan <- b
+
+	pm.Wipe()
+
+	for _, c := range b {
+		if c != 0 {
+			t.Fatalf("PIN未被清零: %q", b)
+		}
+	}
+	if _, err := pm.WaitPIN(); err == nil {
+		t.Fatal("清除后不应再取到PIN")
+	}
+}
+
+func TestWaitPINClearsBuffer(t *testing.T) {
+	pm := NewPINManager()
+	pm.SendPIN("654321")
+	b := <-pm.pinChan
+	pm.pinChan <- b
+
+	pin, err := pm.WaitPIN()
+	if err != nil || pin != "654321" {
+		t.Fatalf("WaitPIN() = %q, %v", pin, err)
+	}
+	for _, c := range b {
+		if c != 0 {
+			t.Fatalf("取出后缓冲区未被清零: %q", b)
+		}
+	}
+}
+
+func TestWipeAfterClose(t *testing.T) {
+	pm := NewPINManager()
+	pm.Close()
+	pm.Wipe() // 不应阻塞或死循环
+}
@@ -0,0 +1,24 @@
+package ws
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// backoffDelay 计算第 attempt 次（从0开始）重连前的等待时间
+// 间隔按 base*2^attempt 增长且不超过 max，实际等待时间在该间隔的一半到全部之间随机取值
+func backoffDelay(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(d-half+1)
+}
@@ -0,0 +1,39 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelayGrowsWithinBounds(t *testing.T) {
+	base, max := time.Second, 30*time.Second
+
+	for attempt := 0; attempt < 10; attempt++ {
+		ceiling := base << attempt
+		if ceiling > max {
+			ceiling = max
+		}
+		for i := 0; i < 100; i++ {
+			d := backoffDelay(attempt, base, max)
+			if d < ceiling/2 || d > ceiling {
+				t.Fatalf("第%d次重连等待 %v 超出范围 [%v, %v]", attempt, d, ceiling/2, ceiling)
+			}
+		}
+	}
+}
+
+func TestBackoffDelayJitter(t *testing.T) {
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 50; i++ {
+		seen[backoffDelay(3, time.Second, time.Minute)] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("重连等待时间应带随机抖动")
+	}
+}
+
+func TestBackoffDelayLargeAttemptDoesNotOverflow(t *testing.T) {
+	if d := backoffDelay(1000, time.Second, time.Minute); d <= 0 || d > time.Minute {
+		t.Fatalf("重连次数很大时应保持在上限内: %v", d)
+	}
+}
@@ -1,13 +1,18 @@
 package ws
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/hang666/EasyUKey/client/internal/confirmation"
+	"github.com/hang666/EasyUKey/client/internal/global"
 	"github.com/hang666/EasyUKey/shared/pkg/errs"
 	"github.com/hang666/EasyUKey/shared/pkg/identity"
 	"github.com/hang666/EasyUKey/shared/pkg/logger"
@@ -16,9 +21,11 @@ import (
 )
 
 const (
-	reconnectInterval = 5 * time.Second
-	pingInterval      = 30 * time.Second
-	WsPath            = "/ws"
+	pingInterval       = 30 * time.Second
+	helloTimeout       = 10 * time.Second
+	handshakeTimeout   = 30 * time.Second
+	connectionCheckGap = time.Second // 连接正常时检查连接状态的间隔
+	WsPath             = "/ws"
 )
 
 var (
@@ -28,76 +35,191 @@ var (
 	isFirstConnection bool = true // 标记是否为首次连接
 
 	serverAddr          string
+	serverPublicKey     ed25519.PublicKey
 	isDeviceInitialized bool
 
 	// 加密相关
 	keyExchange     *identity.KeyExchange
-	encryptor       *identity.Encryptor
+	session         *identity.SecureSession
 	handshakeStatus messages.HandshakeStatus
+	handshakeDone   chan messages.HandshakeStatus
+
+	// 会话恢复令牌，短暂断线后凭此令牌重连，无需重新输入PIN
+	resumeToken   string
+	resumeExpires time.Time
+	resumeMu      sync.Mutex
+
+	// 版本协商
+	helloResult  chan *messages.HelloResponseMessage
+	capabilities messages.Capabilities
+
+	// U盘被拔出后锁定，锁定期间不重连
+	locked bool
 )
 
 // Init 初始化WebSocket客户端模块
-func Init(addr string, initialized bool) {
+// publicKey 为编译时嵌入的服务端身份公钥，为空时仅在开发模式下允许跳过服务端身份验证
+func Init(addr string, publicKey string, devMode bool, initialized bool) error {
 	serverAddr = addr
 	isDeviceInitialized = initialized
+
+	if publicKey == "" {
+		if !devMode {
+			return errs.ErrServerIdentityInvalid
+		}
+		logger.Logger.Warn("未配置服务端身份公钥，开发模式下将跳过服务端身份验证")
+		return nil
+	}
+
+	key, err := identity.ParseServerPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	serverPublicKey = key
+	return nil
 }
 
-// Connect 连接到WebSocket服务器
+// Connect 连接到WebSocket服务器，失败时关闭连接并返回错误，由调用方决定是否重试
 func Connect() error {
+	if isLocked() {
+		return errs.ErrDeviceNotAvailable
+	}
+
 	wsURL, err := wsutil.ConvertHTTPToWS(serverAddr)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errs.ErrConvertWSURLFailed, err)
 	}
 	wsURL += WsPath
 
-	conn, _, err = websocket.DefaultDialer.Dial(wsURL, nil)
+	dialer, err := newDialer(global.Config.Proxy)
+	if err != nil {
+		return err
+	}
+
+	// 关闭可能残留的旧连接
+	Disconnect()
+
+	newConn, _, err := dialer.Dial(wsURL, nil)
 	if err != nil {
 		return fmt.Errorf("%w: %v", errs.ErrWSConnectFailed, err)
 	}
 
+	mu.Lock()
+	conn = newConn
+	mu.Unlock()
+
 	setConnected(true)
 	handshakeStatus = messages.HandshakeStatusPending
+	handshakeDone = make(chan messages.HandshakeStatus, 1)
+	session = nil
+	helloResult = make(chan *messages.HelloResponseMessage, 1)
 
 	// 启动消息监听
-	go processMessages()
+	go processMessages(newConn)
 
 	// 启动心跳
-	go heartbeat()
+	go heartbeat(newConn)
 
 	// 首先进行密钥协商
 	if err := SendKeyExchangeRequest(); err != nil {
+		Disconnect()
 		return err
 	}
 
 	// 等待密钥协商完成
-	for i := 0; i < 30; i++ { // 最多等待30秒
-		if handshakeStatus == messages.HandshakeStatusCompleted {
-			break
-		}
-		if handshakeStatus == messages.HandshakeStatusFailed {
+	select {
+	case status := <-handshakeDone:
+		if status != messages.HandshakeStatusCompleted {
+			// 握手失败（包括服务端身份验证失败）时立即断开，不再发送任何数据
+			Disconnect()
 			return errs.ErrKeyExchangeFailed
 		}
-		time.Sleep(1 * time.Second)
+	case <-time.After(handshakeTimeout):
+		Disconnect()
+		return errs.ErrKeyExchangeTimeout
 	}
 
-	if handshakeStatus != messages.HandshakeStatusCompleted {
-		return errs.ErrKeyExchangeTimeout
+	// 握手完成后进行版本与能力协商
+	if err := negotiateVersion(); err != nil {
+		Disconnect()
+		return err
+	}
+
+	if err := sendDeviceRequest(); err != nil {
+		logger.Logger.Error("发送设备请求失败", "error", err)
+		Disconnect()
+		return err
 	}
 
-	// 根据设备初始化状态和连接状态发送对应请求
+	return nil
+}
+
+// sendDeviceRequest 根据设备状态发送初始化、会话恢复或完整的设备连接请求
+func sendDeviceRequest() error {
 	if !isDeviceInitialized {
-		err = SendDeviceInitRequest()
-	} else if isFirstConnection {
-		// 首次连接发送正常连接消息
-		err = SendDeviceConnection()
+		// 初始化使用启动时输入的PIN
+		isFirstConnection = false
+		return SendDeviceInitRequest()
+	}
+
+	// 有可用的恢复令牌时直接恢复会话
+	if token := takeResumeToken(); token != "" {
+		return SendDeviceReconnect(token)
+	}
+
+	// 首次连接使用启动时输入的PIN，之后的完整连接需要重新输入PIN
+	if isFirstConnection {
 		isFirstConnection = false
 	} else {
-		// 重连时发送重连消息
-		err = SendDeviceReconnect()
+		logger.Logger.Info("会话无法恢复，请重新输入PIN以连接服务器")
+		if err := confirmation.PromptPIN(); err != nil {
+			return err
+		}
 	}
-	if err != nil {
-		logger.Logger.Error("发送设备请求失败", "error", err)
-		os.Exit(1)
+	return SendDeviceConnection()
+}
+
+// setResumeToken 保存服务端签发的会话恢复令牌
+func setResumeToken(token string, ttl int) {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	resumeToken = token
+	resumeExpires = time.Now().Add(time.Duration(ttl) * time.Second)
+}
+
+// takeResumeToken 取出未过期的会话恢复令牌，令牌只能使用一次
+func takeResumeToken() string {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+
+	token := resumeToken
+	resumeToken = ""
+	if token == "" || time.Now().After(resumeExpires) {
+		return ""
+	}
+	return token
+}
+
+// negotiateVersion 发送版本与能力声明并等待服务端协商结果
+// 服务端不支持版本协商时按当前版本的默认能力继续，以兼容旧版服务端
+func negotiateVersion() error {
+	if err := SendHello(); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-helloResult:
+		if resp.UpgradeRequired {
+			logger.Logger.Error("服务端拒绝当前客户端版本", "message", resp.Message,
+				"client_version", global.Config.Version, "min_client_version", resp.MinClientVersion)
+			return errs.ErrClientVersionTooOld
+		}
+		capabilities = resp.Capabilities
+		logger.Logger.Info("版本协商完成", "protocol_version", resp.ProtocolVersion,
+			"encryption_suites", resp.Capabilities.EncryptionSuites, "auth_token_formats", resp.Capabilities.AuthTokenFormats)
+	case <-time.After(helloTimeout):
+		capabilities = messages.DefaultCapabilities()
+		logger.Logger.Warn("服务端未响应版本协商，按默认能力继续")
 	}
 
 	return nil
@@ -118,25 +240,98 @@ func Disconnect() {
 		conn = nil
 	}
 
-	if isConnected {
-		setConnected(false)
+	// 断开后会话密钥不再使用，立即清零
+	if session != nil {
+		session.Close()
+		session = nil
+	}
+	keyExchange = nil
+
+	// 已持有 mu，直接修改状态，不能调用 setConnected
+	isConnected = false
+}
+
+// LockDevice U盘被拔出时调用：上报拔出状态，丢弃恢复令牌并断开连接，锁定期间不再重连
+// 重新插入后需要重新输入PIN才能连接
+func LockDevice() {
+	mu.Lock()
+	locked = true
+	isFirstConnection = false
+	mu.Unlock()
+
+	// 拔出后不允许凭令牌恢复会话
+	takeResumeToken()
+
+	if IsConnected() {
+		if err := SendDeviceRemoved(); err != nil {
+			logger.Logger.Warn("上报设备拔出状态失败", "error", err)
+		}
 	}
+	Disconnect()
 }
 
-// MonitorConnection 监控连接并在连接丢失时尝试重新连接
+// UnlockDevice U盘重新插入后解除锁定，由 MonitorConnection 重新连接
+func UnlockDevice() {
+	mu.Lock()
+	defer mu.Unlock()
+	locked = false
+}
+
+// isLocked 判断是否处于锁定状态
+func isLocked() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return locked
+}
+
+// MonitorConnection 监控连接，连接丢失时按指数退避加随机抖动重新连接
 func MonitorConnection() {
-	ticker := time.NewTicker(reconnectInterval)
-	defer ticker.Stop()
+	policy := global.Config.Reconnect
 
-	for range ticker.C {
-		if !IsConnected() {
-			if err := Connect(); err != nil {
-				logger.Logger.Error("重新连接WebSocket失败", "error", err)
+	failures := 0
+	for {
+		if IsConnected() || isLocked() {
+			failures = 0
+			time.Sleep(connectionCheckGap)
+			continue
+		}
+
+		delay := backoffDelay(failures, policy.Interval, policy.MaxInterval)
+		logger.Logger.Info("WebSocket连接已断开，等待后重新连接", "delay", delay, "attempt", failures+1)
+		time.Sleep(delay)
+
+		if err := Connect(); err != nil {
+			failures++
+			logger.Logger.Error("重新连接WebSocket失败", "error", err, "attempt", failures)
+			if errors.Is(err, errs.ErrClientVersionTooOld) {
+				// 版本过低时重连没有意义，停止重连等待用户升级
+				return
 			}
+			if policy.MaxAttempts > 0 && failures >= policy.MaxAttempts {
+				logger.Logger.Error("连续重连失败次数已达上限，停止重连", "max_attempts", policy.MaxAttempts)
+				return
+			}
+			continue
 		}
+
+		logger.Logger.Info("WebSocket重新连接成功", "attempts", failures+1)
+		failures = 0
 	}
 }
 
+// newDialer 创建WebSocket拨号器，proxyAddr为空时使用系统代理环境变量
+func newDialer(proxyAddr string) (*websocket.Dialer, error) {
+	dialer := *websocket.DefaultDialer
+	if proxyAddr != "" {
+		proxyURL, err := url.Parse(proxyAddr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrWSConnectFailed, err)
+		}
+		dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &dialer, nil
+}
+
 func IsConnected() bool {
 	mu.Lock()
 	defer mu.Unlock()
@@ -149,13 +344,30 @@ func setConnected(state bool) {
 	isConnected = state
 }
 
-func heartbeat() {
+// isCurrentConn 判断c是否为当前使用的连接
+func isCurrentConn(c *websocket.Conn) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return conn == c
+}
+
+// markDisconnected 连接c结束时调用，c已被新连接替换时不影响当前连接状态
+func markDisconnected(c *websocket.Conn) {
+	mu.Lock()
+	defer mu.Unlock()
+	if conn == c || conn == nil {
+		isConnected = false
+	}
+}
+
+// heartbeat 定期发送心跳，连接c被关闭或替换后退出
+func heartbeat(c *websocket.Conn) {
 	ticker := time.NewTicker(pingInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		if !IsConnected() {
-			return // 连接已关闭，退出心跳
+		if !isCurrentConn(c) || !IsConnected() {
+			return // 连接已关闭或已被替换，退出心跳
 		}
 
 		if err := SendPingMessage(); err != nil {
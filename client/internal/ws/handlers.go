@@ -2,6 +2,7 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"time"
 
@@ -28,6 +29,12 @@ func handleAuthRequest(message messages.WSMessage) {
 		return
 	}
 
+	// 重连后服务端会重新下发仍在等待的请求，已在处理中的请求忽略即可
+	if confirmation.GetState(authReq.RequestID) != confirmation.StateIdle {
+		logger.Logger.Debug("忽略重复下发的认证请求", "request_id", authReq.RequestID)
+		return
+	}
+
 	// 检查设备状态
 	dev := device.DeviceInfo.GetDevice()
 	if dev == nil {
@@ -51,33 +58,31 @@ func handleAuthRequest(message messages.WSMessage) {
 		return
 	}
 
-	// 等待用户确认，调用confirmation包
+	// 等待用户确认该请求，调用confirmation包
 	timeout := time.Duration(authReq.Timeout) * time.Second
-	confirmResult, err := confirmation.WaitForConfirmation(timeout)
+	confirmResult, err := confirmation.WaitForConfirmation(request.ID, timeout)
 	if err != nil {
-		confirmation.SendResult(false, "认证超时")
+		confirmation.SendResult(request.ID, false, "认证超时")
 		SendAuthResponse(authReq.RequestID, false, "", "", dev.SerialNumber, dev.VolumeSerialNumber, errs.ErrWaitConfirmFailed.Error())
 		return
 	}
 
 	if !confirmResult.Confirmed {
-		confirmation.SendResult(false, "用户拒绝认证")
+		confirmation.SendResult(request.ID, false, "用户拒绝认证")
 		SendAuthResponse(authReq.RequestID, false, "", "", dev.SerialNumber, dev.VolumeSerialNumber, errs.ErrUserRejected.Error())
 		return
 	}
 
-	// 等待PIN输入
-	pin, err := global.PinManager.WaitPIN()
-	if err != nil {
-		confirmation.SendResult(false, "PIN验证失败")
-		SendAuthResponse(authReq.RequestID, false, "", "", dev.SerialNumber, dev.VolumeSerialNumber, "PIN输入超时")
-		return
-	}
+	// OnceKey每次认证后轮换，多个请求必须依次完成 读取-提交-更新
+	onceKeyMu.Lock()
+	defer onceKeyMu.Unlock()
+
+	pin := confirmResult.PIN
 
 	// 使用PIN获取当前OnceKey
 	currentOnceKey, err := identity.GetOnceKey(pin, global.Config.EncryptKeyStr, global.SecureStoragePath)
 	if err != nil {
-		confirmation.SendResult(false, "PIN验证失败")
+		confirmation.SendResult(request.ID, false, "PIN验证失败")
 		SendAuthResponse(authReq.RequestID, false, "", "", dev.SerialNumber, dev.VolumeSerialNumber, "PIN验证失败")
 		return
 	}
@@ -92,17 +97,53 @@ func handleAuthRequest(message messages.WSMessage) {
 		global.SecureStoragePath,
 	)
 	if err != nil {
-		confirmation.SendResult(false, "认证token生成失败")
+		confirmation.SendResult(request.ID, false, "认证token生成失败")
 		SendAuthResponse(authReq.RequestID, false, "", "", dev.SerialNumber, dev.VolumeSerialNumber, "认证token生成失败")
 		return
 	}
 
-	// 保存PIN以供后续更新OnceKey使用
-	global.PinManager.SendPIN(pin)
+	// 先登记等待，再发送响应，避免服务端的结果先于登记到达
+	resultCh := awaitAuthSuccess(authReq.RequestID)
+	defer cancelAuthSuccess(authReq.RequestID)
 
 	SendAuthResponse(authReq.RequestID, true, authKey, currentOnceKey, dev.SerialNumber, dev.VolumeSerialNumber, "")
 
-	// 认证响应已发送，等待服务端的 auth_success_response 消息来确定最终结果
+	// 等待服务端的 auth_success_response 消息来确定最终结果
+	var resp messages.AuthSuccessResponseMessage
+	select {
+	case resp = <-resultCh:
+	case <-time.After(authSuccessTimeout):
+		logger.Logger.Warn("等待服务端认证结果超时", "request_id", authReq.RequestID)
+		confirmation.SendResult(request.ID, false, "等待服务端认证结果超时")
+		return
+	}
+
+	if !resp.Success {
+		// 服务端认证失败，通知页面
+		confirmation.SendResult(request.ID, false, "服务端认证验证失败")
+		SendOnceKeyUpdateConfirm(resp.RequestID, false, "客户端收到错误响应")
+		return
+	}
+
+	// 服务端为设备组下发了独立的HMAC密钥，保存后下次认证即使用该密钥
+	// 保存失败不影响本次认证，服务端在设备使用新密钥前会继续接受旧密钥并重新下发
+	if resp.AuthSecret != "" {
+		if err := identity.SetAuthSecret(pin, global.Config.EncryptKeyStr, resp.AuthSecret, global.SecureStoragePath); err != nil {
+			logger.Logger.Error("保存设备组认证密钥失败", "error", err)
+		}
+	}
+
+	// 使用PIN更新OnceKey
+	if err := identity.SetOnceKey(pin, global.Config.EncryptKeyStr, resp.NewOnceKey, global.SecureStoragePath); err != nil {
+		confirmation.SendResult(request.ID, false, "保存新Key失败")
+		SendOnceKeyUpdateConfirm(resp.RequestID, false, "保存新Key失败")
+		return
+	}
+
+	SendOnceKeyUpdateConfirm(resp.RequestID, true, "")
+
+	// 在确认收到服务端成功响应并完成OnceKey更新后，通知页面认证成功
+	confirmation.SendResult(request.ID, true, "认证成功")
 }
 
 // handleDeviceInitResponse 处理设备初始化响应
@@ -117,6 +158,9 @@ func handleDeviceInitResponse(message messages.WSMessage) {
 		return
 	}
 
+	// 恢复码只使用一次，无论成功与否都清除
+	global.SetRecoveryCode("")
+
 	if !resp.Success {
 		logger.Logger.Error("设备初始化失败", "error", resp.Error, "message", resp.Message)
 		return
@@ -131,16 +175,32 @@ func handleDeviceInitResponse(message messages.WSMessage) {
 	}
 
 	// 使用PIN保存初始密钥
-	if err := identity.SaveInitialKeys(pin, global.Config.EncryptKeyStr, resp.OnceKey, resp.TOTPURI, global.SecureStoragePath); err != nil {
+	if err := identity.SaveInitialKeys(pin, global.Config.EncryptKeyStr, resp.OnceKey, resp.TOTPURI, resp.AuthSecret, global.SecureStoragePath); err != nil {
 		logger.Logger.Error("保存初始密钥失败")
 		os.Exit(1)
 		return
 	}
 
 	isDeviceInitialized = true
+	if resp.ResumeToken != "" {
+		setResumeToken(resp.ResumeToken, resp.ResumeTTL)
+	}
+
+	if resp.Recovered {
+		logger.Logger.Info("已通过恢复码接管原设备组，旧设备已被吊销", "message", resp.Message)
+		return
+	}
+
+	// 恢复码只在初始化时下发一次，提示用户立即保存
+	if len(resp.RecoveryCodes) > 0 {
+		logger.Logger.Info("设备初始化成功，请立即保存恢复码，恢复码仅显示一次")
+		if err := confirmation.ShowRecoveryCodes(resp.RecoveryCodes); err != nil {
+			logger.Logger.Error("显示恢复码失败", "error", err)
+		}
+	}
 }
 
-// handleAuthSuccessResponse 处理认证成功后服务端返回的新OnceKey
+// handleAuthSuccessResponse 将服务端返回的认证结果交给等待该请求的认证流程
 func handleAuthSuccessResponse(message messages.WSMessage) {
 	dataBytes, err := json.Marshal(message.Data)
 	if err != nil {
@@ -152,31 +212,9 @@ func handleAuthSuccessResponse(message messages.WSMessage) {
 		return
 	}
 
-	if !resp.Success {
-		// 服务端认证失败，通知页面
-		confirmation.SendResult(false, "服务端认证验证失败")
-		SendOnceKeyUpdateConfirm(resp.RequestID, false, "客户端收到错误响应")
-		return
+	if !deliverAuthSuccess(resp) {
+		logger.Logger.Debug("收到无等待者的认证结果", "request_id", resp.RequestID, "success", resp.Success)
 	}
-
-	// 等待PIN输入（复用之前保存的PIN）
-	pin, err := global.PinManager.WaitPIN()
-	if err != nil {
-		SendOnceKeyUpdateConfirm(resp.RequestID, false, "PIN获取失败")
-		return
-	}
-
-	// 使用PIN更新OnceKey
-	err = identity.SetOnceKey(pin, global.Config.EncryptKeyStr, resp.NewOnceKey, global.SecureStoragePath)
-	if err != nil {
-		SendOnceKeyUpdateConfirm(resp.RequestID, false, "保存新Key失败")
-		return
-	}
-
-	SendOnceKeyUpdateConfirm(resp.RequestID, true, "")
-
-	// 在确认收到服务端成功响应并完成OnceKey更新后，通知页面认证成功
-	confirmation.SendResult(true, "认证成功")
 }
 
 // handleDeviceConnectionResponse 处理设备连接响应
@@ -191,12 +229,26 @@ func handleDeviceConnectionResponse(message messages.WSMessage) {
 		return
 	}
 
+	if resp.Status == messages.DeviceStatusResumeRejected {
+		// 恢复令牌失效，断开后由 MonitorConnection 重新进行完整的设备连接
+		logger.Logger.Warn("会话无法恢复，将重新连接设备", "message", resp.Message)
+		Disconnect()
+		return
+	}
+
 	if !resp.Success {
 		logger.Logger.Error("设备连接失败", "error", resp.Error, "message", resp.Message)
 		return
 	}
 
-	if resp.Status == "pending_activation" {
+	if resp.ResumeToken != "" {
+		setResumeToken(resp.ResumeToken, resp.ResumeTTL)
+	}
+
+	switch resp.Status {
+	case messages.DeviceStatusResumed:
+		logger.Logger.Info("会话已恢复")
+	case messages.DeviceStatusPendingActivation:
 		logger.Logger.Info("跨平台设备识别成功，等待管理员激活")
 	}
 }
@@ -239,8 +291,47 @@ func handleForceLogout(message messages.WSMessage) {
 	os.Exit(0)
 }
 
+// handleHelloResponse 处理服务端版本协商结果
+func handleHelloResponse(message messages.WSMessage) {
+	dataBytes, err := json.Marshal(message.Data)
+	if err != nil {
+		return
+	}
+
+	var helloResp messages.HelloResponseMessage
+	if err := json.Unmarshal(dataBytes, &helloResp); err != nil {
+		logger.Logger.Error("解析版本协商响应失败", "error", err)
+		return
+	}
+
+	select {
+	case helloResult <- &helloResp:
+	default:
+		logger.Logger.Warn("忽略非预期的版本协商响应")
+	}
+}
+
 // handleKeyExchangeResponse 处理密钥交换响应
 func handleKeyExchangeResponse(message messages.WSMessage) {
+	// 每个连接只接受一次握手响应，避免已建立的会话被替换
+	kx := keyExchange
+	if handshakeStatus != messages.HandshakeStatusPending || kx == nil {
+		logger.Logger.Warn("忽略非预期的密钥交换响应")
+		return
+	}
+
+	// 无论成功与否都通知等待握手的 Connect，握手结束后临时密钥不再需要，立即清零
+	defer func() {
+		kx.Destroy()
+		if keyExchange == kx {
+			keyExchange = nil
+		}
+		select {
+		case handshakeDone <- handshakeStatus:
+		default:
+		}
+	}()
+
 	// 解析密钥交换响应数据
 	dataBytes, err := json.Marshal(message.Data)
 	if err != nil {
@@ -260,23 +351,43 @@ func handleKeyExchangeResponse(message messages.WSMessage) {
 		return
 	}
 
+	// 构造握手记录，必须与服务端签名的内容完全一致
+	transcript, err := identity.BuildHandshakeTranscript(
+		kx.GetPublicKeyBase64(), kx.GetNonceBase64(),
+		keyExchResp.PublicKey, keyExchResp.Nonce,
+	)
+	if err != nil {
+		logger.Logger.Error("服务端密钥交换响应无效", "error", err)
+		handshakeStatus = messages.HandshakeStatusFailed
+		return
+	}
+
+	// 验证服务端身份签名，签名不匹配说明连接可能被中间人劫持，立即中止
+	if serverPublicKey != nil {
+		if err := identity.VerifyTranscriptSignature(serverPublicKey, transcript, keyExchResp.Signature); err != nil {
+			logger.Logger.Error("服务端身份验证失败，已中止连接", "error", err)
+			handshakeStatus = messages.HandshakeStatusFailed
+			return
+		}
+	}
+
 	// 计算共享密钥
-	if err := keyExchange.ComputeSharedKey(keyExchResp.PublicKey); err != nil {
+	if err := kx.ComputeSharedKey(keyExchResp.PublicKey, transcript); err != nil {
 		logger.Logger.Error("计算共享密钥失败", "error", err)
 		handshakeStatus = messages.HandshakeStatusFailed
 		return
 	}
 
-	// 创建加密器
-	enc, err := keyExchange.CreateEncryptor()
+	// 创建加密会话
+	sess, err := kx.CreateSecureSession(identity.RoleClient, identity.DefaultRekeyPolicy)
 	if err != nil {
-		logger.Logger.Error("创建加密器失败", "error", err)
+		logger.Logger.Error("创建加密会话失败", "error", err)
 		handshakeStatus = messages.HandshakeStatusFailed
 		return
 	}
 
 	// 更新全局状态
-	encryptor = enc
+	session = sess
 	handshakeStatus = messages.HandshakeStatusCompleted
 }
 
@@ -287,7 +398,7 @@ func handleEncryptedMessage(message messages.WSMessage) {
 		return
 	}
 
-	if encryptor == nil {
+	if session == nil {
 		return
 	}
 
@@ -302,9 +413,13 @@ func handleEncryptedMessage(message messages.WSMessage) {
 		return
 	}
 
-	// 解密消息
-	decryptedData, err := encryptor.DecryptMessage(encryptedMsg.Payload, encryptedMsg.Nonce)
+	// 解密消息，重复或超出窗口的消息直接丢弃
+	decryptedData, err := session.Open(&encryptedMsg)
 	if err != nil {
+		if errors.Is(err, errs.ErrReplayDetected) || errors.Is(err, errs.ErrMessageOutOfWindow) || errors.Is(err, errs.ErrInvalidEpoch) {
+			logger.Logger.Warn("丢弃重放或乱序的加密消息", "error", err, "seq", encryptedMsg.Seq, "epoch", encryptedMsg.Epoch)
+			return
+		}
 		logger.Logger.Error("解密消息失败", "error", err)
 		return
 	}
@@ -316,5 +431,5 @@ func handleEncryptedMessage(message messages.WSMessage) {
 	}
 
 	// 递归处理解密后的消息
-	dispatchMessage(decryptedMsg)
+	dispatchMessage(decryptedMsg, true)
 }
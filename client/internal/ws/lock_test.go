@@ -0,0 +1,30 @@
+package ws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+func TestLockDeviceDiscardsResumeToken(t *testing.T) {
+	setResumeToken("token", 60)
+
+	LockDevice()
+	defer UnlockDevice()
+
+	if !isLocked() {
+		t.Fatal("拔出后应处于锁定状态")
+	}
+	if token := takeResumeToken(); token != "" {
+		t.Errorf("拔出后不应保留会话恢复令牌: %q", token)
+	}
+	if err := Connect(); !errors.Is(err, errs.ErrDeviceNotAvailable) {
+		t.Errorf("锁定期间不应连接，实际: %v", err)
+	}
+
+	UnlockDevice()
+	if isLocked() {
+		t.Error("重新插入后应解除锁定")
+	}
+}
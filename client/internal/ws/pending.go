@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// authSuccessTimeout 发送认证响应后等待服务端结果的最长时间
+const authSuccessTimeout = 30 * time.Second
+
+var (
+	// onceKeyMu 串行化OnceKey的读取、提交和更新
+	onceKeyMu sync.Mutex
+
+	// authWaiters 按请求ID等待服务端 auth_success_response 的认证流程
+	authWaiters   = make(map[string]chan messages.AuthSuccessResponseMessage)
+	authWaitersMu sync.Mutex
+)
+
+// awaitAuthSuccess 登记等待指定请求的服务端认证结果
+func awaitAuthSuccess(requestID string) <-chan messages.AuthSuccessResponseMessage {
+	ch := make(chan messages.AuthSuccessResponseMessage, 1)
+	authWaitersMu.Lock()
+	authWaiters[requestID] = ch
+	authWaitersMu.Unlock()
+	return ch
+}
+
+// cancelAuthSuccess 取消登记
+func cancelAuthSuccess(requestID string) {
+	authWaitersMu.Lock()
+	delete(authWaiters, requestID)
+	authWaitersMu.Unlock()
+}
+
+// deliverAuthSuccess 将服务端认证结果投递给对应请求，没有等待者时返回false
+func deliverAuthSuccess(resp messages.AuthSuccessResponseMessage) bool {
+	authWaitersMu.Lock()
+	ch, ok := authWaiters[resp.RequestID]
+	delete(authWaiters, resp.RequestID)
+	authWaitersMu.Unlock()
+
+	if !ok {
+		return false
+	}
+	ch <- resp
+	return true
+}
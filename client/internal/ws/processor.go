@@ -7,34 +7,25 @@ import (
 	"github.com/hang666/EasyUKey/shared/pkg/messages"
 )
 
-// processMessages is the main loop for reading and dispatching incoming WebSocket messages.
-func processMessages() {
+// processMessages is the main loop for reading and dispatching incoming WebSocket messages on c.
+func processMessages(c *websocket.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Logger.Error("WebSocket消息处理异常", "error", r)
 		}
-		// When the loop exits, we are disconnected.
-		setConnected(false)
+		// When the loop exits, this connection is gone. A newer connection may already be active,
+		// so only mark the client as disconnected if c is still the current one.
+		markDisconnected(c)
 	}()
 
 	for {
-		// Make a local copy of the connection for the read operation
-		mu.Lock()
-		localConn := conn
-		mu.Unlock()
-
-		if localConn == nil {
-			// Connection is closed, exit the loop
-			break
-		}
-
 		var message messages.WSMessage
-		err := localConn.ReadJSON(&message)
+		err := c.ReadJSON(&message)
 		if err != nil {
 			// Check if this is a clean close signal
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				logger.Logger.Info("WebSocket连接正常关闭")
-			} else if IsConnected() {
+			} else if isCurrentConn(c) && IsConnected() {
 				// Only log errors if we were expecting the connection to be alive
 				logger.Logger.Error("读取WebSocket消息失败", "error", err)
 			}
@@ -43,17 +34,29 @@ func processMessages() {
 		}
 
 		// Process the message
-		dispatchMessage(message)
+		dispatchMessage(message, false)
 	}
 }
 
 // dispatchMessage routes a message to its corresponding handler.
-func dispatchMessage(message messages.WSMessage) {
+// encrypted reports whether the message was unwrapped from an encrypted envelope.
+func dispatchMessage(message messages.WSMessage, encrypted bool) {
+	if handshakeStatus != messages.HandshakeStatusCompleted && !messages.AllowedBeforeHandshake(message.Type) {
+		logger.Logger.Warn("握手完成前收到不允许的消息，已丢弃", "type", message.Type)
+		return
+	}
+	if err := messages.CheckEncryptionPolicy(message.Type, encrypted); err != nil {
+		logger.Logger.Warn("丢弃违反加密策略的消息", "type", message.Type, "encrypted", encrypted, "error", err)
+		return
+	}
+
 	switch message.Type {
 	case "key_exchange_response":
 		handleKeyExchangeResponse(message)
 	case "encrypted":
 		handleEncryptedMessage(message)
+	case "hello_response":
+		handleHelloResponse(message)
 	case "auth_request":
 		go handleAuthRequest(message) // Run in a goroutine to not block the read loop
 	case "device_init_response":
@@ -69,6 +72,7 @@ func dispatchMessage(message messages.WSMessage) {
 	case "force_logout":
 		handleForceLogout(message)
 	default:
-		logger.Logger.Warn("收到未知消息类型", "type", message.Type)
+		// Ignore unknown types so that newer servers can add messages without breaking older clients.
+		logger.Logger.Debug("忽略未知消息类型", "type", message.Type)
 	}
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/hang666/EasyUKey/client/internal/device"
 	"github.com/hang666/EasyUKey/client/internal/global"
+	"github.com/hang666/EasyUKey/client/utils/uid"
 	"github.com/hang666/EasyUKey/shared/pkg/errs"
 	"github.com/hang666/EasyUKey/shared/pkg/identity"
 	"github.com/hang666/EasyUKey/shared/pkg/logger"
@@ -22,25 +23,35 @@ func sendWSMessage(msgType string, data interface{}) error {
 		return errs.ErrWSNotConnected
 	}
 
-	// 检查是否需要加密
-	if handshakeStatus == messages.HandshakeStatusCompleted && encryptor != nil {
-		return sendEncryptedMessage(msgType, data)
+	// 按加密策略发送，需要加密的消息在握手完成前不会以明文发出
+	encrypted := handshakeStatus == messages.HandshakeStatusCompleted && session != nil
+	switch messages.GetEncryptionPolicy(msgType) {
+	case messages.PlaintextOnly:
+		return wsutil.SendVersionedMessage(conn, msgType, data, global.Config.Version)
+	case messages.EncryptionRequired:
+		if !encrypted {
+			return errs.ErrMessageNotEncrypted
+		}
 	}
 
-	return wsutil.SendMessage(conn, msgType, data)
+	if encrypted {
+		return sendEncryptedMessage(msgType, data)
+	}
+	return wsutil.SendVersionedMessage(conn, msgType, data, global.Config.Version)
 }
 
 // sendEncryptedMessage 发送加密消息
 func sendEncryptedMessage(msgType string, data interface{}) error {
-	if encryptor == nil {
+	if session == nil {
 		return errs.ErrWSNotConnected
 	}
 
 	// 创建原始消息
 	originalMsg := &messages.WSMessage{
-		Type:      msgType,
-		Data:      data,
-		Timestamp: time.Now(),
+		Type:          msgType,
+		Data:          data,
+		Timestamp:     time.Now(),
+		ClientVersion: global.Config.Version,
 	}
 
 	// 序列化原始消息
@@ -49,20 +60,14 @@ func sendEncryptedMessage(msgType string, data interface{}) error {
 		return err
 	}
 
-	// 加密消息
-	encryptedPayload, nonce, err := encryptor.EncryptMessage(originalData)
+	// 加密消息，分配序列号并在需要时轮换密钥
+	encryptedMsg, err := session.Seal(originalData)
 	if err != nil {
 		return err
 	}
 
-	// 创建加密消息
-	encryptedMsg := &messages.EncryptedMessage{
-		Payload: encryptedPayload,
-		Nonce:   nonce,
-	}
-
 	// 直接发送加密消息（不再次加密）
-	return wsutil.SendMessage(conn, "encrypted", encryptedMsg)
+	return wsutil.SendVersionedMessage(conn, "encrypted", encryptedMsg, global.Config.Version)
 }
 
 // SendDeviceConnection 发送设备连接消息
@@ -114,13 +119,38 @@ func SendDeviceConnection() error {
 		DevicePath:         dev.DevicePath,
 		Vendor:             dev.Vendor,
 		Model:              dev.Model,
+		Fingerprint:        deviceFingerprint(dev),
 	}
 
 	return sendWSMessage("device_connection", connection)
 }
 
-// SendDeviceReconnect 发送设备重连消息
-func SendDeviceReconnect() error {
+// deviceFingerprint 收集设备硬件指纹，锚点文件不可用时（如只读介质）省略锚点信息
+func deviceFingerprint(dev *uid.USBDevice) *messages.DeviceFingerprint {
+	fingerprint := &messages.DeviceFingerprint{
+		VendorID:        dev.VendorID,
+		ProductID:       dev.ProductID,
+		Capacity:        dev.Capacity,
+		FilesystemUUID:  dev.FilesystemUUID,
+		PartitionLayout: dev.PartitionLayout,
+	}
+
+	anchor, err := uid.ReadAnchor(global.Config.ExeDir)
+	if err != nil {
+		logger.Logger.Warn("读取锚点文件失败，硬件指纹将不包含锚点信息", "error", err)
+		return fingerprint
+	}
+	fingerprint.Anchor = &messages.AnchorFile{
+		FileID:    anchor.FileID,
+		Size:      anchor.Size,
+		ModTime:   anchor.ModTime,
+		Signature: anchor.Signature,
+	}
+	return fingerprint
+}
+
+// SendDeviceReconnect 携带会话恢复令牌发送设备重连消息
+func SendDeviceReconnect(token string) error {
 	dev := device.DeviceInfo.GetDevice()
 	if dev == nil {
 		return errs.ErrDeviceNotAvailable
@@ -132,6 +162,8 @@ func SendDeviceReconnect() error {
 		DevicePath:         dev.DevicePath,
 		Vendor:             dev.Vendor,
 		Model:              dev.Model,
+		ResumeToken:        token,
+		Fingerprint:        deviceFingerprint(dev),
 	}
 
 	return sendWSMessage("device_reconnect", reconnect)
@@ -150,6 +182,8 @@ func SendDeviceInitRequest() error {
 		DevicePath:         dev.DevicePath,
 		Vendor:             dev.Vendor,
 		Model:              dev.Model,
+		RecoveryCode:       global.RecoveryCode(),
+		Fingerprint:        deviceFingerprint(dev),
 	}
 
 	return sendWSMessage("device_init_request", initRequest)
@@ -208,6 +242,26 @@ func SendDeviceStatusResponse(status string, serialNumber string, volumeSerialNu
 	}
 }
 
+// SendDeviceRemoved 上报U盘已被拔出，服务端据此立即将设备标记为离线
+func SendDeviceRemoved() error {
+	status := messages.DeviceStatusMessage{Status: messages.DeviceStatusRemoved}
+	if dev := device.DeviceInfo.GetDevice(); dev != nil {
+		status.SerialNumber = dev.SerialNumber
+		status.VolumeSerialNumber = dev.VolumeSerialNumber
+	}
+	return sendWSMessage("device_status", status)
+}
+
+// SendHello 发送版本与能力声明
+func SendHello() error {
+	hello := &messages.HelloMessage{
+		ProtocolVersion: messages.ProtocolVersion,
+		ClientVersion:   global.Config.Version,
+		Capabilities:    messages.DefaultCapabilities(),
+	}
+	return sendWSMessage("hello", hello)
+}
+
 // SendKeyExchangeRequest 发送密钥交换请求
 func SendKeyExchangeRequest() error {
 	// 创建密钥交换器
@@ -222,6 +276,7 @@ func SendKeyExchangeRequest() error {
 	// 创建密钥交换请求
 	keyExchReq := &messages.KeyExchangeRequestMessage{
 		PublicKey: kx.GetPublicKeyBase64(),
+		Nonce:     kx.GetNonceBase64(),
 	}
 
 	// 密钥交换请求不能加密，必须直接发送
@@ -230,5 +285,5 @@ func SendKeyExchangeRequest() error {
 	if conn == nil {
 		return errs.ErrWSNotConnected
 	}
-	return wsutil.SendMessage(conn, "key_exchange_request", keyExchReq)
+	return wsutil.SendVersionedMessage(conn, "key_exchange_request", keyExchReq, global.Config.Version)
 }
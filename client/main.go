@@ -2,12 +2,15 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/spf13/pflag"
+
 	"github.com/hang666/EasyUKey/client/internal/api"
 	"github.com/hang666/EasyUKey/client/internal/confirmation"
 	"github.com/hang666/EasyUKey/client/internal/device"
@@ -15,6 +18,7 @@ import (
 	"github.com/hang666/EasyUKey/client/internal/initialize"
 	"github.com/hang666/EasyUKey/client/internal/pin"
 	"github.com/hang666/EasyUKey/client/internal/ws"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
 	"github.com/hang666/EasyUKey/shared/pkg/identity"
 	"github.com/hang666/EasyUKey/shared/pkg/logger"
 )
@@ -24,16 +28,21 @@ var TemplateFS embed.FS
 
 // 编译时注入的配置变量
 var (
-	EncryptKeyStr string
-	ServerAddr    string
-	LogLevel      string
-	LogFile       string
-	LogConsole    string
-	DevMode       string
+	EncryptKeyStr   string
+	ServerAddr      string
+	ServerPublicKey string
+	Version         string
+	LogLevel        string
+	LogFile         string
+	LogConsole      string
+	DevMode         string
 )
 
 func main() {
-	if err := initialize.InitAll(EncryptKeyStr, ServerAddr, LogLevel, LogFile, LogConsole, DevMode); err != nil {
+	if err := initialize.InitAll(EncryptKeyStr, ServerAddr, ServerPublicKey, Version, LogLevel, LogFile, LogConsole, DevMode, os.Args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			os.Exit(0)
+		}
 		panic("客户端初始化失败: " + err.Error())
 	}
 
@@ -67,18 +76,26 @@ func startServices() {
 	// 初始化PIN管理器
 	global.PinManager = pin.NewPINManager()
 
-	confirmation.Init(global.Config.HTTPPort)
-	go func() {
-		if err := api.StartHttpServer(global.Config.HTTPPort, TemplateFS); err != nil {
+	// 终端模式下不需要本地页面；浏览器模式先启动服务以获得实际监听端口
+	port := 0
+	if global.Config.ConfirmMode != confirmation.ModeTerminal {
+		var err error
+		port, err = api.StartHttpServer(global.Config.HTTPPort, TemplateFS)
+		if err != nil {
 			logger.Logger.Error("HTTP服务器启动失败", "error", err)
 			os.Exit(1)
 		}
-	}()
+	}
+
+	if err := confirmation.Init(global.Config.ConfirmMode, port); err != nil {
+		logger.Logger.Error("初始化确认方式失败", "error", err)
+		os.Exit(1)
+	}
 
-	// 无论设备是否初始化，都先显示PIN输入页面获取PIN
+	// 无论设备是否初始化，都先获取PIN
 	logger.Logger.Info("请输入PIN以继续")
-	if err := confirmation.ShowPINSetupPage(); err != nil {
-		logger.Logger.Error("显示PIN输入页面失败", "error", err)
+	if err := confirmation.PromptPIN(); err != nil {
+		logger.Logger.Error("获取PIN失败", "error", err)
 		os.Exit(1)
 	}
 
@@ -99,17 +116,40 @@ func startServices() {
 	}
 
 	// 初始化WebSocket客户端
-	ws.Init(global.Config.ServerAddr, isInitialized)
+	if err := ws.Init(global.Config.ServerAddr, global.Config.ServerPublicKey, global.Config.DevMode == "true", isInitialized); err != nil {
+		logger.Logger.Error("服务端身份公钥无效，请在编译时通过 SERVER_PUBLIC_KEY 嵌入", "error", err)
+		os.Exit(1)
+	}
+
+	// U盘拔出时立即锁定，重新插入后重新连接
+	device.SetRemovalHandlers(lockOnRemoval, unlockOnRestore)
+	if device.IsRemoved() {
+		lockOnRemoval()
+	}
 
-	// 启动WebSocket连接
+	// 启动WebSocket连接，连接失败或断开后由 MonitorConnection 自动重连
 	go func() {
 		if err := ws.Connect(); err != nil {
-			logger.Logger.Error("WebSocket连接失败", "error", err)
-			os.Exit(1)
+			logger.Logger.Error("WebSocket连接失败，将自动重试", "error", err)
+			if errors.Is(err, errs.ErrClientVersionTooOld) {
+				return
+			}
 		}
+		ws.MonitorConnection()
 	}()
+}
+
+// lockOnRemoval U盘被拔出时清除内存中的PIN和会话密钥，上报服务端并断开连接
+func lockOnRemoval() {
+	logger.Logger.Warn("U盘已拔出，已清除PIN并断开连接，重新插入后需要重新输入PIN")
+	global.PinManager.Wipe()
+	ws.LockDevice()
+}
 
-	go ws.MonitorConnection()
+// unlockOnRestore U盘重新插入后解除锁定，重新连接时会提示输入PIN
+func unlockOnRestore() {
+	logger.Logger.Info("U盘已重新插入，正在重新连接")
+	ws.UnlockDevice()
 }
 
 // shutdown 优雅关闭服务
@@ -0,0 +1,89 @@
+package uid
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AnchorFileName 锚点文件名，位于客户端所在目录
+const AnchorFileName = ".easyukey-anchor"
+
+// anchorContentSize 锚点文件随机内容的长度
+const anchorContentSize = 64
+
+// AnchorInfo 锚点文件元数据
+// 锚点文件在首次使用时写入随机内容，之后不再修改；整盘复制到其他U盘后内容相同但文件ID通常会变化
+type AnchorInfo struct {
+	// FileID 文件系统中的文件标识（Unix为inode，Windows为文件索引）
+	FileID string
+	// Size 文件大小（字节）
+	Size int64
+	// ModTime 修改时间（Unix秒）
+	ModTime int64
+	// Signature 文件内容的SHA-256摘要（十六进制）
+	Signature string
+}
+
+// ReadAnchor 读取 dir 下的锚点文件元数据，文件不存在时创建
+func ReadAnchor(dir string) (*AnchorInfo, error) {
+	path := filepath.Join(dir, AnchorFileName)
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		content, err = createAnchor(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取锚点文件失败: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取锚点文件信息失败: %v", err)
+	}
+
+	fileID, err := fileIdentifier(path, info)
+	if err != nil {
+		return nil, fmt.Errorf("读取锚点文件标识失败: %v", err)
+	}
+
+	sum := sha256.Sum256(content)
+	return &AnchorInfo{
+		FileID:    fileID,
+		Size:      info.Size(),
+		ModTime:   info.ModTime().Unix(),
+		Signature: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// createAnchor 创建写入随机内容的锚点文件，文件已存在时不覆盖
+func createAnchor(path string) ([]byte, error) {
+	content := make([]byte, anchorContentSize)
+	if _, err := rand.Read(content); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		// 并发创建时使用已写入的文件
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return content, nil
+}
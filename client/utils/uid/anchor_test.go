@@ -0,0 +1,56 @@
+package uid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadAnchor_CreatesOnceAndIsStable(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := ReadAnchor(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Size != anchorContentSize || len(first.Signature) != 64 {
+		t.Errorf("锚点文件信息不符: %+v", first)
+	}
+
+	second, err := ReadAnchor(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *first != *second {
+		t.Errorf("已存在的锚点文件不应被重写\n第一次 %+v\n第二次 %+v", first, second)
+	}
+}
+
+func TestReadAnchor_CopyKeepsSignatureOnly(t *testing.T) {
+	src := t.TempDir()
+	original, err := ReadAnchor(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟复制到另一个U盘：内容相同，文件ID不同
+	content, err := os.ReadFile(filepath.Join(src, AnchorFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dst, AnchorFileName), content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := ReadAnchor(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.Signature != original.Signature {
+		t.Error("复制后的文件内容摘要应相同")
+	}
+	if copied.FileID == original.FileID {
+		t.Error("复制后的文件标识应不同")
+	}
+}
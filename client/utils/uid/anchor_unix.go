@@ -0,0 +1,18 @@
+//go:build !windows
+
+package uid
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileIdentifier 返回文件的 inode 号
+func fileIdentifier(_ string, info os.FileInfo) (string, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", nil
+	}
+	return strconv.FormatUint(uint64(stat.Ino), 10), nil
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package uid
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileIdentifier 返回 NTFS/FAT 文件索引，同一卷内唯一
+func fileIdentifier(path string, _ os.FileInfo) (string, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+
+	handle, err := syscall.CreateFile(pathPtr, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(handle)
+
+	var data syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(handle, &data); err != nil {
+		return "", err
+	}
+	index := uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow)
+	return strconv.FormatUint(index, 10), nil
+}
@@ -1,6 +1,12 @@
 package uid
 
-import "fmt"
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
 
 // DevMode 开发模式标志，通过构建时注入
 var DevMode = "false"
@@ -25,6 +31,72 @@ type USBDevice struct {
 	Vendor string `json:"vendor"`
 	// Model 型号
 	Model string `json:"model"`
+	// VendorID USB厂商ID（VID），无法获取时为空
+	VendorID string `json:"vendor_id"`
+	// ProductID USB产品ID（PID），无法获取时为空
+	ProductID string `json:"product_id"`
+	// Capacity 整个磁盘的容量（字节），Size 为所在分区的大小
+	Capacity uint64 `json:"capacity"`
+	// FilesystemUUID 文件系统UUID
+	FilesystemUUID string `json:"filesystem_uuid"`
+	// PartitionLayout 分区布局，每个分区为"起始偏移:大小"（字节），按偏移排序并以分号分隔
+	PartitionLayout string `json:"partition_layout"`
+}
+
+// partitionExtent 分区在磁盘上的位置（字节）
+type partitionExtent struct {
+	offset uint64
+	size   uint64
+}
+
+// normalizeUSBID 将 VID/PID 统一为4位小写十六进制，如 "0x0781  (SanDisk Corporation)" 转换为 "0781"
+func normalizeUSBID(id string) string {
+	fields := strings.Fields(id)
+	if len(fields) == 0 {
+		return ""
+	}
+	hex := strings.ToLower(fields[0])
+	hex = strings.TrimPrefix(hex, "0x")
+	if len(hex) < 4 {
+		hex = strings.Repeat("0", 4-len(hex)) + hex
+	}
+	return hex
+}
+
+// formatPartitionLayout 将分区位置格式化为与平台无关的布局字符串
+func formatPartitionLayout(extents []partitionExtent) string {
+	sorted := slices.Clone(extents)
+	slices.SortFunc(sorted, func(a, b partitionExtent) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	parts := make([]string, 0, len(sorted))
+	for _, e := range sorted {
+		parts = append(parts, fmt.Sprintf("%d:%d", e.offset, e.size))
+	}
+	return strings.Join(parts, ";")
+}
+
+// DeviceEventAction 设备变化类型
+type DeviceEventAction string
+
+const (
+	DeviceAdded   DeviceEventAction = "add"    // 设备插入
+	DeviceRemoved DeviceEventAction = "remove" // 设备移除
+	DeviceChanged DeviceEventAction = "change" // 设备属性或挂载状态变化
+)
+
+// DeviceEvent 设备变化通知
+type DeviceEvent struct {
+	Action DeviceEventAction
+	// DeviceNode 发生变化的设备节点，挂载表变化时为空
+	DeviceNode string
+}
+
+// WatchDevices 订阅存储设备的插入、移除和挂载变化，done 关闭后停止监听并关闭返回的通道
+// 当前平台不支持事件通知时返回错误，调用方应退回定时检测
+func WatchDevices(done <-chan struct{}) (<-chan DeviceEvent, error) {
+	return watchDevices(done)
 }
 
 // GetUSBDevices 获取所有 USB 存储设备列表
@@ -98,3 +170,38 @@ func FindDeviceByDevicePath(devicePath string) (*USBDevice, error) {
 	}
 	return nil, fmt.Errorf("未找到设备: %s", devicePath)
 }
+
+// FindDeviceByPath 查找包含指定路径的 USB 设备，多个挂载点匹配时取最长的挂载点
+func FindDeviceByPath(path string) (*USBDevice, error) {
+	devices, err := GetUSBDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	path = filepath.Clean(path)
+	var found *USBDevice
+	for i := range devices {
+		mountPoint := filepath.Clean(devices[i].DevicePath)
+		if devices[i].DevicePath == "" || !isSubPath(mountPoint, path) {
+			continue
+		}
+		if found == nil || len(mountPoint) > len(filepath.Clean(found.DevicePath)) {
+			found = &devices[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("未找到设备: %s", path)
+	}
+	return found, nil
+}
+
+// isSubPath 判断 path 是否为 dir 本身或其子路径
+func isSubPath(dir, path string) bool {
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
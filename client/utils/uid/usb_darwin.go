@@ -217,22 +217,26 @@ func buildUSBDeviceFromDiskInfo(info DiskutilInfo, usbDevicesMap map[string]USBD
 		VolumeSerialNumber: info.VolumeUUID,
 		Vendor:             info.VendorName,
 		Model:              info.ProductName,
+		Capacity:           info.TotalSize,
+		FilesystemUUID:     info.VolumeUUID,
 	}
 
-	// 如果磁盘信息中没有序列号，尝试从USB设备信息中获取
-	if device.SerialNumber == "" {
-		for deviceName, usbInfo := range usbDevicesMap {
-			if strings.Contains(deviceName, info.MediaName) ||
-				strings.Contains(info.MediaName, usbInfo.ProductName) {
+	// 从USB设备信息中补全序列号、厂商和型号，并记录VID/PID用于硬件指纹
+	for deviceName, usbInfo := range usbDevicesMap {
+		if strings.Contains(deviceName, info.MediaName) ||
+			strings.Contains(info.MediaName, usbInfo.ProductName) {
+			if device.SerialNumber == "" {
 				device.SerialNumber = usbInfo.SerialNumber
-				if device.Vendor == "" {
-					device.Vendor = usbInfo.Manufacturer
-				}
-				if device.Model == "" {
-					device.Model = usbInfo.ProductName
-				}
-				break
 			}
+			if device.Vendor == "" {
+				device.Vendor = usbInfo.Manufacturer
+			}
+			if device.Model == "" {
+				device.Model = usbInfo.ProductName
+			}
+			device.VendorID = normalizeUSBID(usbInfo.VendorID)
+			device.ProductID = normalizeUSBID(usbInfo.ProductID)
+			break
 		}
 	}
 
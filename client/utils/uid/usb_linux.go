@@ -4,177 +4,209 @@ package uid
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
-	"unicode"
 )
 
-// LinuxBlockDevice lsblk 输出的块设备信息
-type LinuxBlockDevice struct {
-	Name       string             `json:"name"`
-	MountPoint string             `json:"mountpoint"`
-	Label      string             `json:"label"`
-	UUID       string             `json:"uuid"`
-	FsType     string             `json:"fstype"`
-	Size       string             `json:"size"` // lsblk 返回的是字符串格式如 "1G", "512M"
-	Type       string             `json:"type"`
-	Removable  bool               `json:"rm"`
-	Vendor     string             `json:"vendor"`
-	Model      string             `json:"model"`
-	Serial     string             `json:"serial"`
-	Tran       string             `json:"tran"`     // 传输类型：usb, sata, etc.
-	Children   []LinuxBlockDevice `json:"children"` // 子设备（分区）
+// linuxSource 设备信息来源，直接读取 sysfs、mountinfo 和 udev 数据库，不依赖外部命令
+// 各路径可替换，便于在测试中使用伪造的目录树
+type linuxSource struct {
+	sysDir    string // sysfs 挂载点，通常为 /sys
+	mountInfo string // 挂载信息文件，通常为 /proc/self/mountinfo
+	udevDir   string // udev 数据库目录，通常为 /run/udev/data
+	devDir    string // 设备目录，通常为 /dev
+}
+
+var hostSource = linuxSource{
+	sysDir:    "/sys",
+	mountInfo: "/proc/self/mountinfo",
+	udevDir:   "/run/udev/data",
+	devDir:    "/dev",
+}
+
+// mountEntry mountinfo 中的一条挂载记录
+type mountEntry struct {
+	mountPoint string
+	fsType     string
+}
+
+// linuxBlockDevice sysfs 中的块设备（磁盘或分区）
+type linuxBlockDevice struct {
+	name   string // 设备名，如 sdb、sdb1
+	dir    string // sysfs 中的目录
+	devNum string // 主次设备号，如 8:17
+	size   uint64 // 字节
 }
 
 func getUSBDevices() ([]USBDevice, error) {
-	var devices []USBDevice
+	return hostSource.usbDevices()
+}
+
+// usbDevices 枚举已挂载的 USB 存储设备
+func (s linuxSource) usbDevices() ([]USBDevice, error) {
+	mounts, err := s.readMounts()
+	if err != nil {
+		return nil, fmt.Errorf("读取挂载信息失败: %v", err)
+	}
 
-	// 1. 使用 lsblk 获取所有块设备信息
-	blockDevices, err := getBlockDevices()
+	entries, err := os.ReadDir(filepath.Join(s.sysDir, "block"))
 	if err != nil {
-		return nil, fmt.Errorf("获取块设备信息失败: %v", err)
+		return nil, fmt.Errorf("读取块设备信息失败: %v", err)
 	}
 
-	// 2. 过滤并转换为 USBDevice 格式
-	for _, bd := range blockDevices {
+	var devices []USBDevice
+	for _, entry := range entries {
+		disk, ok := s.readBlockDevice(entry.Name(), filepath.Join(s.sysDir, "block", entry.Name()))
+		if !ok {
+			continue
+		}
+
 		// 开发模式显示所有设备，生产模式只显示USB设备
-		if DevMode != "true" && bd.Tran != "usb" {
+		usbDir := s.findUSBDeviceDir(disk.dir)
+		if DevMode != "true" && usbDir == "" {
 			continue
 		}
 
-		// 检查主设备是否有挂载点的分区
+		// 优先使用已挂载的分区，没有已挂载分区时再看磁盘本身
 		foundMountedPartition := false
-
-		// 检查子设备（分区）是否有挂载点
-		for _, child := range bd.Children {
-			if child.MountPoint != "" {
-				// 使用父设备的基本信息构建USB设备，但使用子设备的挂载信息
-				device, err := buildUSBDeviceFromPartition(bd, child)
-				if err != nil {
-					// 记录错误但继续处理其他设备
-					continue
-				}
-				devices = append(devices, device)
+		for _, part := range s.readPartitions(disk) {
+			if mount, ok := mounts[part.devNum]; ok {
+				devices = append(devices, s.buildUSBDevice(disk, part, usbDir, mount))
 				foundMountedPartition = true
 			}
 		}
 
-		// 如果没有找到已挂载的分区，但主设备本身有挂载点，则处理主设备
-		if !foundMountedPartition && bd.MountPoint != "" {
-			device, err := buildUSBDevice(bd)
-			if err != nil {
-				// 记录错误但继续处理其他设备
-				continue
+		if !foundMountedPartition {
+			if mount, ok := mounts[disk.devNum]; ok {
+				devices = append(devices, s.buildUSBDevice(disk, disk, usbDir, mount))
 			}
-			devices = append(devices, device)
 		}
 	}
 
 	return devices, nil
 }
 
-// getBlockDevices 使用 lsblk 获取块设备信息
-func getBlockDevices() ([]LinuxBlockDevice, error) {
-	// 使用 lsblk 命令获取设备信息，输出为 JSON 格式
-	cmd := exec.Command("lsblk", "-J", "-o", "NAME,MOUNTPOINT,LABEL,UUID,FSTYPE,SIZE,TYPE,RM,VENDOR,MODEL,SERIAL,TRAN")
-	output, err := cmd.Output()
+// readBlockDevice 读取 sysfs 中的块设备，dir 可以是符号链接
+func (s linuxSource) readBlockDevice(name, dir string) (linuxBlockDevice, bool) {
+	realDir, err := filepath.EvalSymlinks(dir)
 	if err != nil {
-		return nil, fmt.Errorf("执行 lsblk 命令失败: %v", err)
+		return linuxBlockDevice{}, false
 	}
 
-	// 解析 JSON 输出
-	var result struct {
-		BlockDevices []LinuxBlockDevice `json:"blockdevices"`
+	devNum := readSysfsValue(filepath.Join(realDir, "dev"))
+	if devNum == "" {
+		return linuxBlockDevice{}, false
 	}
 
-	if err := json.Unmarshal(output, &result); err != nil {
-		return nil, fmt.Errorf("解析 lsblk 输出失败: %v", err)
-	}
+	return linuxBlockDevice{
+		name:   name,
+		dir:    realDir,
+		devNum: devNum,
+		size:   readSectors(filepath.Join(realDir, "size")),
+	}, true
+}
 
-	// 展开嵌套的设备（包括分区）
-	var allDevices []LinuxBlockDevice
-	for _, device := range result.BlockDevices {
-		allDevices = append(allDevices, device)
+// readPartitions 读取磁盘下的分区，分区目录中包含 partition 文件
+func (s linuxSource) readPartitions(disk linuxBlockDevice) []linuxBlockDevice {
+	entries, err := os.ReadDir(disk.dir)
+	if err != nil {
+		return nil
 	}
 
-	return allDevices, nil
-}
-
-// buildUSBDeviceFromPartition 从主设备和分区信息构建 USBDevice 结构
-func buildUSBDeviceFromPartition(parent LinuxBlockDevice, partition LinuxBlockDevice) (USBDevice, error) {
-	device := USBDevice{
-		DevicePath:         partition.MountPoint,                // 使用分区的挂载点
-		DeviceNode:         "/dev/" + partition.Name,            // 使用分区的设备节点
-		SerialNumber:       strings.TrimSpace(parent.Serial),    // 使用主设备的序列号
-		Label:              strings.TrimSpace(partition.Label),  // 使用分区的标签
-		FileSystem:         strings.TrimSpace(partition.FsType), // 使用分区的文件系统
-		VolumeSerialNumber: strings.TrimSpace(partition.UUID),   // 使用分区的UUID
-		Vendor:             strings.TrimSpace(parent.Vendor),    // 使用主设备的厂商
-		Model:              strings.TrimSpace(parent.Model),     // 使用主设备的型号
-		Size:               parseSizeString(partition.Size),     // 使用分区的大小
+	var partitions []linuxBlockDevice
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), disk.name) {
+			continue
+		}
+		dir := filepath.Join(disk.dir, entry.Name())
+		if !fileExists(filepath.Join(dir, "partition")) {
+			continue
+		}
+		if part, ok := s.readBlockDevice(entry.Name(), dir); ok {
+			partitions = append(partitions, part)
+		}
 	}
+	return partitions
+}
 
-	// 如果分区没有大小信息，使用主设备的大小
-	if device.Size == 0 {
-		device.Size = parseSizeString(parent.Size)
+// partitionLayout 读取磁盘的分区布局，分区目录中的 start 为起始扇区
+func (s linuxSource) partitionLayout(disk linuxBlockDevice) string {
+	var extents []partitionExtent
+	for _, part := range s.readPartitions(disk) {
+		extents = append(extents, partitionExtent{
+			offset: readSectors(filepath.Join(part.dir, "start")),
+			size:   part.size,
+		})
 	}
+	return formatPartitionLayout(extents)
+}
 
-	// 如果没有序列号，尝试从 udev 属性获取
-	if device.SerialNumber == "" {
-		deviceName := "/dev/" + parent.Name
-		if serialFromUdev := getDeviceSerialFromUdev(deviceName); serialFromUdev != "" {
-			device.SerialNumber = serialFromUdev
+// findUSBDeviceDir 沿 sysfs 设备路径向上查找所属的 USB 设备目录（包含 idVendor 文件），不是USB设备时返回空
+func (s linuxSource) findUSBDeviceDir(blockDir string) string {
+	devicesDir := filepath.Join(s.sysDir, "devices")
+	for dir := filepath.Dir(blockDir); strings.HasPrefix(dir, devicesDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if fileExists(filepath.Join(dir, "idVendor")) {
+			return dir
 		}
 	}
+	return ""
+}
 
-	// 如果没有厂商信息，尝试从 Model 中提取
-	if device.Vendor == "" && device.Model != "" {
-		if parts := strings.Fields(device.Model); len(parts) > 0 {
-			device.Vendor = parts[0]
-		}
+// buildUSBDevice 构建 USBDevice 结构，disk 为磁盘，volume 为挂载的分区（没有分区时与 disk 相同）
+func (s linuxSource) buildUSBDevice(disk, volume linuxBlockDevice, usbDir string, mount mountEntry) USBDevice {
+	diskProps := s.readUdevProperties(disk.devNum)
+	volumeProps := s.readUdevProperties(volume.devNum)
+
+	device := USBDevice{
+		DevicePath:         mount.mountPoint,
+		DeviceNode:         filepath.Join(s.devDir, volume.name),
+		Label:              volumeProps["ID_FS_LABEL"],
+		FileSystem:         volumeProps["ID_FS_TYPE"],
+		VolumeSerialNumber: volumeProps["ID_FS_UUID"],
+		Vendor:             readSysfsValue(filepath.Join(disk.dir, "device", "vendor")),
+		Model:              readSysfsValue(filepath.Join(disk.dir, "device", "model")),
+		Size:               volume.size,
+		Capacity:           disk.size,
+		FilesystemUUID:     volumeProps["ID_FS_UUID"],
+		PartitionLayout:    s.partitionLayout(disk),
 	}
 
-	// 获取设备大小（如果都没有提供）
-	if device.Size == 0 {
-		if size := getDeviceSize(partition.MountPoint); size > 0 {
-			device.Size = size
-		}
+	if usbDir != "" {
+		device.VendorID = readSysfsValue(filepath.Join(usbDir, "idVendor"))
+		device.ProductID = readSysfsValue(filepath.Join(usbDir, "idProduct"))
 	}
 
-	// 如果最终还是没有序列号，设置为"null"
+	// 序列号优先读取USB设备描述符，其次使用 udev 属性
+	if usbDir != "" {
+		device.SerialNumber = readSysfsValue(filepath.Join(usbDir, "serial"))
+	}
 	if device.SerialNumber == "" {
-		device.SerialNumber = "null"
+		device.SerialNumber = serialFromUdev(diskProps)
 	}
 
-	return device, nil
-}
+	// 没有 udev 数据库时，从挂载信息和 /dev/disk 链接中补全文件系统信息
+	if device.FileSystem == "" {
+		device.FileSystem = mount.fsType
+	}
+	if device.VolumeSerialNumber == "" {
+		device.VolumeSerialNumber = s.lookupDiskLink("by-uuid", volume.name)
+	}
+	if device.FilesystemUUID == "" {
+		device.FilesystemUUID = device.VolumeSerialNumber
+	}
+	if device.Label == "" {
+		device.Label = unescapeUdev(s.lookupDiskLink("by-label", volume.name))
+	}
 
-// buildUSBDevice 构建 USBDevice 结构
-func buildUSBDevice(bd LinuxBlockDevice) (USBDevice, error) {
-	device := USBDevice{
-		DevicePath:         bd.MountPoint,
-		DeviceNode:         "/dev/" + bd.Name, // 添加设备节点
-		SerialNumber:       strings.TrimSpace(bd.Serial),
-		Label:              strings.TrimSpace(bd.Label),
-		FileSystem:         strings.TrimSpace(bd.FsType),
-		VolumeSerialNumber: strings.TrimSpace(bd.UUID),
-		Vendor:             strings.TrimSpace(bd.Vendor),
-		Model:              strings.TrimSpace(bd.Model),
-		Size:               parseSizeString(bd.Size),
-	}
-
-	// 如果没有序列号，尝试从 udev 属性获取
-	if device.SerialNumber == "" {
-		deviceName := "/dev/" + bd.Name
-		if serialFromUdev := getDeviceSerialFromUdev(deviceName); serialFromUdev != "" {
-			device.SerialNumber = serialFromUdev
-		}
+	// SCSI 信息缺失时使用USB描述符中的厂商和产品名
+	if device.Vendor == "" && usbDir != "" {
+		device.Vendor = readSysfsValue(filepath.Join(usbDir, "manufacturer"))
+	}
+	if device.Model == "" && usbDir != "" {
+		device.Model = readSysfsValue(filepath.Join(usbDir, "product"))
 	}
 
 	// 如果没有厂商信息，尝试从 Model 中提取
@@ -184,11 +216,9 @@ func buildUSBDevice(bd LinuxBlockDevice) (USBDevice, error) {
 		}
 	}
 
-	// 获取设备大小（如果 lsblk 没有提供）
+	// 如果分区没有大小信息，使用主设备的大小
 	if device.Size == 0 {
-		if size := getDeviceSize(bd.MountPoint); size > 0 {
-			device.Size = size
-		}
+		device.Size = disk.size
 	}
 
 	// 如果最终还是没有序列号，设置为"null"
@@ -196,141 +226,161 @@ func buildUSBDevice(bd LinuxBlockDevice) (USBDevice, error) {
 		device.SerialNumber = "null"
 	}
 
-	return device, nil
+	return device
 }
 
-// getDeviceSerialFromUdev 从 udev 属性获取设备序列号
-func getDeviceSerialFromUdev(devicePath string) string {
-	cmd := exec.Command("udevadm", "info", "--query=property", "--name="+devicePath)
-	output, err := cmd.Output()
+// readMounts 解析 mountinfo，按主次设备号索引挂载点，同一设备多次挂载时取第一个
+func (s linuxSource) readMounts() (map[string]mountEntry, error) {
+	file, err := os.Open(s.mountInfo)
 	if err != nil {
-		return ""
+		return nil, err
 	}
+	defer file.Close()
 
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	mounts := make(map[string]mountEntry)
+	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		// 查找序列号相关的属性
-		if strings.HasPrefix(line, "ID_SERIAL_SHORT=") {
-			return strings.TrimPrefix(line, "ID_SERIAL_SHORT=")
+		devNum, entry, ok := parseMountInfoLine(scanner.Text())
+		if !ok {
+			continue
 		}
-		if strings.HasPrefix(line, "ID_SERIAL=") {
-			serial := strings.TrimPrefix(line, "ID_SERIAL=")
-			// 移除厂商前缀（如果存在）
-			if parts := strings.Split(serial, "_"); len(parts) > 1 {
-				return parts[len(parts)-1]
-			}
-			return serial
+		if _, exists := mounts[devNum]; !exists {
+			mounts[devNum] = entry
 		}
 	}
-
-	return ""
+	return mounts, scanner.Err()
 }
 
-// getDeviceSize 获取挂载点对应的设备大小
-func getDeviceSize(mountPoint string) uint64 {
-	// 读取 /proc/mounts 找到对应的设备
-	file, err := os.Open("/proc/mounts")
-	if err != nil {
-		return 0
+// parseMountInfoLine 解析一行 mountinfo
+// 格式：挂载ID 父ID 主:次 根 挂载点 挂载选项 [可选字段...] - 文件系统类型 挂载源 超级块选项
+func parseMountInfoLine(line string) (string, mountEntry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 7 {
+		return "", mountEntry{}, false
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
-		if len(fields) >= 2 && fields[1] == mountPoint {
-			devicePath := fields[0]
-			// 获取设备大小
-			return getBlockDeviceSize(devicePath)
+	entry := mountEntry{mountPoint: unescapeMountInfo(fields[4])}
+	for i := 6; i < len(fields)-1; i++ {
+		if fields[i] == "-" {
+			entry.fsType = fields[i+1]
+			break
 		}
 	}
-
-	return 0
+	return fields[2], entry, true
 }
 
-// getBlockDeviceSize 获取块设备的大小
-func getBlockDeviceSize(devicePath string) uint64 {
-	// 从设备路径提取设备名
-	deviceName := filepath.Base(devicePath)
-
-	// 尝试从 /sys/block/ 读取大小
-	sizePath := fmt.Sprintf("/sys/block/%s/size", deviceName)
-	if !fileExists(sizePath) {
-		// 如果是分区，尝试父设备
-		re := regexp.MustCompile(`(\w+)\d+$`)
-		if matches := re.FindStringSubmatch(deviceName); len(matches) > 1 {
-			parentDevice := matches[1]
-			sizePath = fmt.Sprintf("/sys/block/%s/%s/size", parentDevice, deviceName)
-		}
+// unescapeMountInfo 还原 mountinfo 中八进制转义的字符（如空格为 \040）
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
 	}
 
-	if fileExists(sizePath) {
-		if content, err := os.ReadFile(sizePath); err == nil {
-			if size, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64); err == nil {
-				// /sys/block/*/size 的值是以512字节为单位的扇区数
-				return size * 512
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
 			}
 		}
+		b.WriteByte(s[i])
 	}
-
-	return 0
+	return b.String()
 }
 
-// fileExists 检查文件是否存在
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
+// readUdevProperties 读取 udev 数据库中的设备属性（E: 开头的行）
+func (s linuxSource) readUdevProperties(devNum string) map[string]string {
+	props := make(map[string]string)
+	content, err := os.ReadFile(filepath.Join(s.udevDir, "b"+devNum))
+	if err != nil {
+		return props
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(line, "E:") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line[2:], "="); ok {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	return props
 }
 
-// parseSizeString 解析lsblk返回的大小字符串（如"1G", "512M", "2048K"）
-func parseSizeString(sizeStr string) uint64 {
-	if sizeStr == "" {
-		return 0
+// serialFromUdev 从 udev 属性中获取序列号
+func serialFromUdev(props map[string]string) string {
+	if serial := props["ID_SERIAL_SHORT"]; serial != "" {
+		return serial
+	}
+	if serial := props["ID_SERIAL"]; serial != "" {
+		// 移除厂商前缀（如果存在）
+		if parts := strings.Split(serial, "_"); len(parts) > 1 {
+			return parts[len(parts)-1]
+		}
+		return serial
 	}
+	return ""
+}
 
-	sizeStr = strings.TrimSpace(sizeStr)
-	if sizeStr == "" {
-		return 0
+// lookupDiskLink 在 /dev/disk/<kind> 中查找指向设备的链接名
+func (s linuxSource) lookupDiskLink(kind, devName string) string {
+	dir := filepath.Join(s.devDir, "disk", kind)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
 	}
 
-	// 找到最后一个数字的位置
-	var numberPart string
-	var unitPart string
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err == nil && filepath.Base(target) == devName {
+			return entry.Name()
+		}
+	}
+	return ""
+}
 
-	i := len(sizeStr) - 1
-	for i >= 0 && !unicode.IsDigit(rune(sizeStr[i])) {
-		i--
+// unescapeUdev 还原 udev 链接名中 \xNN 形式的转义字符
+func unescapeUdev(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
 	}
 
-	if i >= 0 {
-		numberPart = sizeStr[:i+1]
-		unitPart = strings.ToUpper(sizeStr[i+1:])
-	} else {
-		return 0
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if v, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
 	}
+	return b.String()
+}
 
-	// 解析数字部分
-	var multiplier uint64 = 1
-	switch unitPart {
-	case "K", "KB":
-		multiplier = 1024
-	case "M", "MB":
-		multiplier = 1024 * 1024
-	case "G", "GB":
-		multiplier = 1024 * 1024 * 1024
-	case "T", "TB":
-		multiplier = 1024 * 1024 * 1024 * 1024
-	case "P", "PB":
-		multiplier = 1024 * 1024 * 1024 * 1024 * 1024
-	case "B", "":
-		multiplier = 1
+// readSysfsValue 读取 sysfs 属性文件并去除首尾空白
+func readSysfsValue(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
 	}
+	return strings.TrimSpace(string(content))
+}
 
-	// 解析可能包含小数点的数字
-	if number, err := strconv.ParseFloat(numberPart, 64); err == nil {
-		return uint64(number * float64(multiplier))
+// readSectors 读取 sysfs 中以扇区为单位的属性（size、start）并转换为字节
+func readSectors(path string) uint64 {
+	size, err := strconv.ParseUint(readSysfsValue(path), 10, 64)
+	if err != nil {
+		return 0
 	}
+	// sysfs 中的扇区数始终以512字节为单位，与设备实际扇区大小无关
+	return size * 512
+}
 
-	return 0
+// fileExists 检查文件是否存在
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
 }
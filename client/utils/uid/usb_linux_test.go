@@ -0,0 +1,318 @@
+package uid
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSysfs 在临时目录中构造的 sysfs、mountinfo、udev 目录树
+type fakeSysfs struct {
+	t      *testing.T
+	root   string
+	source linuxSource
+	mounts []string
+}
+
+func newFakeSysfs(t *testing.T) *fakeSysfs {
+	t.Helper()
+	root := t.TempDir()
+	f := &fakeSysfs{
+		t:    t,
+		root: root,
+		source: linuxSource{
+			sysDir:    filepath.Join(root, "sys"),
+			mountInfo: filepath.Join(root, "proc", "self", "mountinfo"),
+			udevDir:   filepath.Join(root, "run", "udev", "data"),
+			devDir:    filepath.Join(root, "dev"),
+		},
+	}
+	for _, dir := range []string{"sys/block", "proc/self", "run/udev/data", "dev"} {
+		f.mkdir(filepath.Join(root, dir))
+	}
+	return f
+}
+
+func (f *fakeSysfs) mkdir(dir string) {
+	f.t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeSysfs) write(path, content string) {
+	f.t.Helper()
+	f.mkdir(filepath.Dir(path))
+	if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeSysfs) symlink(target, link string) {
+	f.t.Helper()
+	f.mkdir(filepath.Dir(link))
+	if err := os.Symlink(target, link); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// addDisk 添加一个磁盘，parentDir 为 /sys/devices 下的父设备路径，返回磁盘在 sysfs 中的目录
+func (f *fakeSysfs) addDisk(parentDir, name, devNum string, sectors string) string {
+	diskDir := filepath.Join(f.source.sysDir, "devices", parentDir, "block", name)
+	f.write(filepath.Join(diskDir, "dev"), devNum)
+	f.write(filepath.Join(diskDir, "size"), sectors)
+	f.symlink(diskDir, filepath.Join(f.source.sysDir, "block", name))
+	return diskDir
+}
+
+func (f *fakeSysfs) addPartition(diskDir, name, devNum, start, sectors string) {
+	partDir := filepath.Join(diskDir, name)
+	f.write(filepath.Join(partDir, "dev"), devNum)
+	f.write(filepath.Join(partDir, "start"), start)
+	f.write(filepath.Join(partDir, "size"), sectors)
+	f.write(filepath.Join(partDir, "partition"), "1")
+}
+
+func (f *fakeSysfs) mount(devNum, mountPoint, fsType string) {
+	f.mounts = append(f.mounts, strings.Join([]string{
+		"36", "28", devNum, "/", mountPoint, "rw,nosuid", "shared:1", "-", fsType, "/dev/x", "rw",
+	}, " "))
+	f.write(f.source.mountInfo, strings.Join(f.mounts, "\n"))
+}
+
+// addUSBStick 构造一个挂载在 /media/user/MY USB 的U盘 sdb，分区 sdb1
+func (f *fakeSysfs) addUSBStick() {
+	usbDir := "pci0000:00/0000:00:14.0/usb2/2-1"
+	f.write(filepath.Join(f.source.sysDir, "devices", usbDir, "idVendor"), "0781")
+	f.write(filepath.Join(f.source.sysDir, "devices", usbDir, "idProduct"), "5581")
+	f.write(filepath.Join(f.source.sysDir, "devices", usbDir, "serial"), "4C530001230")
+	f.write(filepath.Join(f.source.sysDir, "devices", usbDir, "manufacturer"), "SanDisk")
+
+	scsiDir := usbDir + "/2-1:1.0/host6/target6:0:0/6:0:0:0"
+	f.write(filepath.Join(f.source.sysDir, "devices", scsiDir, "vendor"), "SanDisk ")
+	f.write(filepath.Join(f.source.sysDir, "devices", scsiDir, "model"), "Ultra           ")
+
+	diskDir := f.addDisk(scsiDir, "sdb", "8:16", "2048")
+	f.symlink(filepath.Join(f.source.sysDir, "devices", scsiDir), filepath.Join(diskDir, "device"))
+	f.addPartition(diskDir, "sdb1", "8:17", "1024", "1024")
+	f.mount("8:17", `/media/user/MY\040USB`, "vfat")
+}
+
+func TestUSBDevices_FindsMountedUSBPartition(t *testing.T) {
+	DevMode = "false"
+	f := newFakeSysfs(t)
+	f.addUSBStick()
+	f.write(filepath.Join(f.source.udevDir, "b8:17"), "S:disk/by-uuid/1234-ABCD\nE:ID_FS_UUID=1234-ABCD\nE:ID_FS_LABEL=MY_USB\nE:ID_FS_TYPE=vfat")
+
+	// 系统盘不是USB设备，生产模式下不应出现
+	sysDisk := f.addDisk("pci0000:00/0000:00:03.0/virtio1", "vda", "253:0", "4096")
+	f.addPartition(sysDisk, "vda1", "253:1", "0", "4096")
+	f.mount("253:1", "/", "ext4")
+
+	devices, err := f.source.usbDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("应找到1个USB设备, 实际 %d: %+v", len(devices), devices)
+	}
+
+	want := USBDevice{
+		DevicePath:         "/media/user/MY USB",
+		DeviceNode:         filepath.Join(f.source.devDir, "sdb1"),
+		SerialNumber:       "4C530001230",
+		Label:              "MY_USB",
+		Size:               1024 * 512,
+		FileSystem:         "vfat",
+		VolumeSerialNumber: "1234-ABCD",
+		Vendor:             "SanDisk",
+		Model:              "Ultra",
+		VendorID:           "0781",
+		ProductID:          "5581",
+		Capacity:           2048 * 512,
+		FilesystemUUID:     "1234-ABCD",
+		PartitionLayout:    "524288:524288",
+	}
+	if devices[0] != want {
+		t.Errorf("设备信息不符\n实际 %+v\n期望 %+v", devices[0], want)
+	}
+}
+
+func TestUSBDevices_DevModeIncludesAllDisks(t *testing.T) {
+	DevMode = "true"
+	defer func() { DevMode = "false" }()
+
+	f := newFakeSysfs(t)
+	f.addUSBStick()
+	sysDisk := f.addDisk("pci0000:00/0000:00:03.0/virtio1", "vda", "253:0", "4096")
+	f.addPartition(sysDisk, "vda1", "253:1", "0", "4096")
+	f.mount("253:1", "/", "ext4")
+
+	devices, err := f.source.usbDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("开发模式应包含所有已挂载的磁盘, 实际 %d", len(devices))
+	}
+}
+
+func TestUSBDevices_FallbacksWithoutUdev(t *testing.T) {
+	DevMode = "false"
+	f := newFakeSysfs(t)
+
+	// 没有分区、没有SCSI信息和序列号的U盘，文件系统信息来自 /dev/disk 链接和 mountinfo
+	usbDir := "pci0000:00/0000:00:14.0/usb1/1-3"
+	f.write(filepath.Join(f.source.sysDir, "devices", usbDir, "idVendor"), "abcd")
+	f.write(filepath.Join(f.source.sysDir, "devices", usbDir, "product"), "Generic Flash")
+	f.addDisk(usbDir+"/1-3:1.0/host7/target7:0:0/7:0:0:0", "sdc", "8:32", "8192")
+	f.mount("8:32", "/mnt/key", "exfat")
+	f.symlink("../../sdc", filepath.Join(f.source.devDir, "disk", "by-uuid", "ABCD-0001"))
+	f.symlink("../../sdc", filepath.Join(f.source.devDir, "disk", "by-label", `EASY\x20KEY`))
+
+	devices, err := f.source.usbDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("应找到1个USB设备, 实际 %d", len(devices))
+	}
+
+	d := devices[0]
+	if d.DevicePath != "/mnt/key" || d.FileSystem != "exfat" || d.VolumeSerialNumber != "ABCD-0001" || d.Label != "EASY KEY" {
+		t.Errorf("文件系统信息不符: %+v", d)
+	}
+	if d.Model != "Generic Flash" || d.Vendor != "Generic" || d.Size != 8192*512 {
+		t.Errorf("设备信息不符: %+v", d)
+	}
+	if d.VendorID != "abcd" || d.Capacity != 8192*512 || d.FilesystemUUID != "ABCD-0001" || d.PartitionLayout != "" {
+		t.Errorf("指纹信息不符: %+v", d)
+	}
+	if d.SerialNumber != "null" {
+		t.Errorf("缺少序列号时应为 null, 实际 %q", d.SerialNumber)
+	}
+}
+
+func TestUSBDevices_SkipsUnmounted(t *testing.T) {
+	DevMode = "false"
+	f := newFakeSysfs(t)
+	f.addUSBStick()
+	f.write(f.source.mountInfo, "")
+
+	devices, err := f.source.usbDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("未挂载的设备不应出现: %+v", devices)
+	}
+}
+
+func TestFindDeviceByPath(t *testing.T) {
+	DevMode = "false"
+	f := newFakeSysfs(t)
+	f.addUSBStick()
+
+	original := hostSource
+	hostSource = f.source
+	defer func() { hostSource = original }()
+
+	device, err := FindDeviceByPath("/media/user/MY USB/easyukey/client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.DeviceNode != filepath.Join(f.source.devDir, "sdb1") {
+		t.Errorf("设备节点不符: %s", device.DeviceNode)
+	}
+
+	// 名称前缀相同的其他目录不属于该设备
+	if _, err := FindDeviceByPath("/media/user/MY USB2/client"); err == nil {
+		t.Error("不在挂载点下的路径不应匹配")
+	}
+}
+
+func TestFormatPartitionLayout(t *testing.T) {
+	got := formatPartitionLayout([]partitionExtent{
+		{offset: 1048576, size: 2048},
+		{offset: 512, size: 1024},
+	})
+	if got != "512:1024;1048576:2048" {
+		t.Errorf("分区布局应按偏移排序, 实际 %q", got)
+	}
+}
+
+func TestParseMountInfoLine(t *testing.T) {
+	devNum, entry, ok := parseMountInfoLine(`40 28 8:17 / /media/a\040b\011c rw,relatime shared:3 master:1 - vfat /dev/sdb1 rw`)
+	if !ok {
+		t.Fatal("解析失败")
+	}
+	if devNum != "8:17" || entry.mountPoint != "/media/a b\tc" || entry.fsType != "vfat" {
+		t.Errorf("解析结果不符: %s %+v", devNum, entry)
+	}
+
+	if _, _, ok := parseMountInfoLine("invalid"); ok {
+		t.Error("格式错误的行应被忽略")
+	}
+}
+
+func TestParseUevent(t *testing.T) {
+	msg := func(fields ...string) []byte {
+		return []byte(strings.Join(fields, "\x00"))
+	}
+
+	tests := []struct {
+		name   string
+		msg    []byte
+		want   DeviceEvent
+		wantOK bool
+	}{
+		{
+			name:   "分区插入",
+			msg:    msg("add@/devices/x/block/sdb/sdb1", "ACTION=add", "DEVPATH=/devices/x/block/sdb/sdb1", "SUBSYSTEM=block", "DEVNAME=sdb1", "DEVTYPE=partition"),
+			want:   DeviceEvent{Action: DeviceAdded, DeviceNode: "sdb1"},
+			wantOK: true,
+		},
+		{
+			name:   "磁盘移除",
+			msg:    msg("remove@/devices/x/block/sdb", "ACTION=remove", "SUBSYSTEM=block", "DEVNAME=sdb", "DEVTYPE=disk"),
+			want:   DeviceEvent{Action: DeviceRemoved, DeviceNode: "sdb"},
+			wantOK: true,
+		},
+		{
+			name: "USB接口事件",
+			msg:  msg("add@/devices/x/usb2/2-1", "ACTION=add", "SUBSYSTEM=usb", "DEVTYPE=usb_device"),
+		},
+		{
+			name: "不关心的动作",
+			msg:  msg("bind@/devices/x/block/sdb", "ACTION=bind", "SUBSYSTEM=block", "DEVNAME=sdb", "DEVTYPE=disk"),
+		},
+		{
+			name: "udev格式消息",
+			msg:  []byte("libudev\x00\xfe\xed\xca\xfe"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseUevent(tt.msg)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseUevent() = %+v, %v, 期望 %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNormalizeUSBID(t *testing.T) {
+	tests := map[string]string{
+		"0x0781  (SanDisk Corporation)": "0781",
+		"0x5a1":                         "05a1",
+		"ABCD":                          "abcd",
+		"":                              "",
+	}
+	for input, want := range tests {
+		if got := normalizeUSBID(input); got != want {
+			t.Errorf("normalizeUSBID(%q) = %q, 期望 %q", input, got, want)
+		}
+	}
+}
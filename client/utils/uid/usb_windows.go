@@ -106,6 +106,13 @@ func getUSBDevices() ([]USBDevice, error) {
 			}
 		}
 
+		// 分区布局用于硬件指纹，WMI 中的偏移和大小均为字节
+		extents := make([]partitionExtent, 0, len(drivePartitions))
+		for _, partition := range drivePartitions {
+			extents = append(extents, partitionExtent{offset: partition.StartingOffset, size: partition.Size})
+		}
+		layout := formatPartitionLayout(extents)
+
 		// 如果没有分区，创建一个基本设备信息
 		if len(drivePartitions) == 0 {
 			device := USBDevice{
@@ -117,6 +124,7 @@ func getUSBDevices() ([]USBDevice, error) {
 				FileSystem:         "",
 				Label:              "",
 				VolumeSerialNumber: "",
+				Capacity:           drive.Size,
 			}
 
 			// 从型号中提取厂商信息
@@ -147,6 +155,9 @@ func getUSBDevices() ([]USBDevice, error) {
 					FileSystem:         logicalDisk.FileSystem,
 					Model:              sanitizeString(drive.Model),
 					VolumeSerialNumber: sanitizeString(logicalDisk.VolumeSerialNumber),
+					Capacity:           drive.Size,
+					FilesystemUUID:     sanitizeString(logicalDisk.VolumeSerialNumber),
+					PartitionLayout:    layout,
 				}
 
 				// 从型号中提取厂商信息
@@ -0,0 +1,196 @@
+package uid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// watchPollTimeout 阻塞读取的超时时间，用于定期检查是否需要停止监听
+	watchPollTimeout = time.Second
+	// ueventBufferSize 单条内核 uevent 消息的最大长度
+	ueventBufferSize = 64 * 1024
+	// ueventKernelGroup 内核直接广播 uevent 的 netlink 组播组
+	ueventKernelGroup = 1
+)
+
+func watchDevices(done <-chan struct{}) (<-chan DeviceEvent, error) {
+	return hostSource.watch(done)
+}
+
+// watch 同时监听内核 uevent（设备插拔）和 mountinfo（挂载变化），任一来源可用即可
+func (s linuxSource) watch(done <-chan struct{}) (<-chan DeviceEvent, error) {
+	fd, ueventErr := openUeventSocket()
+	mountFile, mountErr := os.Open(s.mountInfo)
+	if ueventErr != nil && mountErr != nil {
+		return nil, fmt.Errorf("监听设备变化失败: %v", errors.Join(ueventErr, mountErr))
+	}
+
+	events := make(chan DeviceEvent, 16)
+	var wg sync.WaitGroup
+
+	if ueventErr == nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer unix.Close(fd)
+			s.readUevents(fd, done, events)
+		}()
+	}
+
+	if mountErr == nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer mountFile.Close()
+			watchMountInfo(mountFile, done, events)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
+
+// openUeventSocket 打开接收内核 uevent 的 netlink 套接字
+func openUeventSocket() (int, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return -1, fmt.Errorf("创建uevent套接字失败: %v", err)
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: ueventKernelGroup}); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("绑定uevent套接字失败: %v", err)
+	}
+
+	tv := unix.NsecToTimeval(int64(watchPollTimeout))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("设置uevent套接字超时失败: %v", err)
+	}
+
+	return fd, nil
+}
+
+// readUevents 读取内核 uevent 并转换为设备事件，直到 done 关闭或套接字出错
+func (s linuxSource) readUevents(fd int, done <-chan struct{}, events chan<- DeviceEvent) {
+	buf := make([]byte, ueventBufferSize)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		switch {
+		case err == nil:
+		case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.ENOBUFS):
+			// 接收缓冲区溢出，有事件丢失，通知调用方重新检测
+			if !sendEvent(events, done, DeviceEvent{Action: DeviceChanged}) {
+				return
+			}
+			continue
+		default:
+			return
+		}
+
+		event, ok := parseUevent(buf[:n])
+		if !ok {
+			continue
+		}
+		if event.DeviceNode != "" {
+			event.DeviceNode = filepath.Join(s.devDir, event.DeviceNode)
+		}
+		if !sendEvent(events, done, event) {
+			return
+		}
+	}
+}
+
+// parseUevent 解析内核 uevent 消息，只关心块设备的磁盘和分区
+// 消息格式：ACTION@DEVPATH\0KEY=VALUE\0KEY=VALUE\0...
+func parseUevent(msg []byte) (DeviceEvent, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) < 2 || !bytes.Contains(fields[0], []byte("@")) {
+		return DeviceEvent{}, false
+	}
+
+	props := make(map[string]string, len(fields))
+	for _, field := range fields[1:] {
+		if key, value, ok := bytes.Cut(field, []byte("=")); ok {
+			props[string(key)] = string(value)
+		}
+	}
+
+	if props["SUBSYSTEM"] != "block" {
+		return DeviceEvent{}, false
+	}
+	if devType := props["DEVTYPE"]; devType != "disk" && devType != "partition" {
+		return DeviceEvent{}, false
+	}
+
+	var action DeviceEventAction
+	switch props["ACTION"] {
+	case "add":
+		action = DeviceAdded
+	case "remove":
+		action = DeviceRemoved
+	case "change", "move":
+		action = DeviceChanged
+	default:
+		return DeviceEvent{}, false
+	}
+
+	return DeviceEvent{Action: action, DeviceNode: props["DEVNAME"]}, true
+}
+
+// watchMountInfo 监听挂载表变化，内核在挂载或卸载时对 mountinfo 触发 POLLPRI
+func watchMountInfo(file *os.File, done <-chan struct{}, events chan<- DeviceEvent) {
+	fds := []unix.PollFd{{Fd: int32(file.Fd()), Events: unix.POLLPRI}}
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		n, err := unix.Poll(fds, int(watchPollTimeout/time.Millisecond))
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return
+		}
+		if n == 0 || fds[0].Revents&(unix.POLLPRI|unix.POLLERR) == 0 {
+			continue
+		}
+
+		if !sendEvent(events, done, DeviceEvent{Action: DeviceChanged}) {
+			return
+		}
+	}
+}
+
+// sendEvent 投递事件，done 关闭时放弃并返回 false
+func sendEvent(events chan<- DeviceEvent, done <-chan struct{}, event DeviceEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-done:
+		return false
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package uid
+
+import "errors"
+
+// watchDevices 当前平台没有设备事件来源，由调用方定时检测
+func watchDevices(done <-chan struct{}) (<-chan DeviceEvent, error) {
+	return nil, errors.ErrUnsupported
+}
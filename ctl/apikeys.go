@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hang666/EasyUKey/sdk"
+	"github.com/hang666/EasyUKey/sdk/request"
+)
+
+// apiKeysCommand API密钥管理命令
+func apiKeysCommand() *command {
+	return &command{
+		name:  "apikeys",
+		short: "管理API密钥",
+		sub: []*command{
+			{name: "list", usage: "[--page <页码>] [--page-size <数量>]", short: "列出API密钥", run: runAPIKeysList},
+			{name: "create", usage: "<名称> [--description <描述>] [--expires-at <RFC3339时间>]", short: "创建API密钥", run: runAPIKeysCreate},
+			{name: "delete", usage: "<密钥ID>", short: "删除API密钥", run: runAPIKeysDelete},
+		},
+	}
+}
+
+func runAPIKeysList(ctx *cliContext, args []string) error {
+	flags := newFlagSet("apikeys list", "[参数]")
+	page, pageSize := pageFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	keys, total, err := client.GetAPIKeys(*page, *pageSize)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.render(keys, apiKeyHeaders, apiKeyRows(keys)); err != nil {
+		return err
+	}
+	return ctx.renderTotal(total, len(keys))
+}
+
+func runAPIKeysCreate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("apikeys create", "<名称> [参数]")
+	description := flags.String("description", "", "描述")
+	expiresAt := flags.String("expires-at", "", "过期时间，RFC3339 格式，默认不过期")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("用法: apikeys create <名称> [参数]")
+	}
+	if *expiresAt != "" {
+		if _, err := time.Parse(time.RFC3339, *expiresAt); err != nil {
+			return fmt.Errorf("无效的过期时间: %s", *expiresAt)
+		}
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	key, err := client.CreateAPIKey(&request.CreateAPIKeyRequest{Name: flags.Arg(0), Description: *description, ExpiresAt: *expiresAt})
+	if err != nil {
+		return err
+	}
+
+	// 密钥明文只在创建时返回
+	return ctx.render(key, []string{"ID", "NAME", "API KEY", "EXPIRES"}, [][]string{{
+		strconv.FormatUint(uint64(key.ID), 10),
+		key.Name,
+		key.Key,
+		formatTime(key.ExpiresAt),
+	}})
+}
+
+func runAPIKeysDelete(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "apikeys delete <密钥ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteAPIKey(id); err != nil {
+		return err
+	}
+	return ctx.renderMessage(map[string]uint{"deleted_api_key_id": id}, fmt.Sprintf("API密钥 %d 已删除", id))
+}
+
+var apiKeyHeaders = []string{"ID", "NAME", "DESCRIPTION", "ADMIN", "ACTIVE", "EXPIRES", "CREATED"}
+
+// apiKeyRows API密钥表格行，不显示密钥明文
+func apiKeyRows(keys []sdk.APIKey) [][]string {
+	rows := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(k.ID), 10),
+			k.Name,
+			k.Description,
+			strconv.FormatBool(k.IsAdmin),
+			strconv.FormatBool(k.IsActive),
+			formatTime(k.ExpiresAt),
+			formatTime(k.CreatedAt),
+		})
+	}
+	return rows
+}
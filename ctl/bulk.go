@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hang666/EasyUKey/sdk/response"
+)
+
+// runImport 批量导入用户或设备组分配
+func runImport(ctx *cliContext, args []string) error {
+	flags := newFlagSet("import", "users|device-groups <文件> [参数]")
+	format := flags.String("format", "", "数据格式 csv 或 json，默认根据文件扩展名判断")
+	dryRun := flags.Bool("dry-run", false, "只校验数据，不写入")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("用法: import users|device-groups <文件>")
+	}
+
+	kind, path := flags.Arg(0), flags.Arg(1)
+	if *format == "" {
+		*format = formatFromPath(path)
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var result *response.BulkImportResult
+	switch kind {
+	case "users":
+		result, err = client.ImportUsers(*format, f, *dryRun)
+	case "device-groups":
+		result, err = client.ImportDeviceGroups(*format, f, *dryRun)
+	default:
+		return fmt.Errorf("未知的导入类型: %s", kind)
+	}
+
+	if result != nil {
+		if renderErr := ctx.renderImportResult(result); renderErr != nil && err == nil {
+			err = renderErr
+		}
+	}
+	return err
+}
+
+// runExport 导出用户或设备组分配
+func runExport(ctx *cliContext, args []string) error {
+	flags := newFlagSet("export", "users|device-groups [参数]")
+	format := flags.String("format", "", "数据格式 csv 或 json，默认根据输出文件扩展名判断，输出到标准输出时为 csv")
+	output := flags.StringP("output", "o", "", "输出文件，默认输出到标准输出")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("用法: export users|device-groups")
+	}
+
+	if *format == "" {
+		*format = "csv"
+		if *output != "" {
+			*format = formatFromPath(*output)
+		}
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+
+	var export func(string, io.Writer) error
+	switch kind := flags.Arg(0); kind {
+	case "users":
+		export = client.ExportUsers
+	case "device-groups":
+		export = client.ExportDeviceGroups
+	default:
+		return fmt.Errorf("未知的导出类型: %s", kind)
+	}
+
+	if *output == "" {
+		return export(*format, ctx.out)
+	}
+	return writeFileAtomic(*output, func(w io.Writer) error { return export(*format, w) })
+}
+
+// writeFileAtomic 先写入同目录的临时文件再重命名，写入失败时不覆盖已有文件
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".easyukeyctl-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// formatFromPath 根据文件扩展名判断数据格式，无法识别时为 csv
+func formatFromPath(path string) string {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json", ".jsonl":
+		return ext[1:]
+	default:
+		return "csv"
+	}
+}
+
+// renderImportResult 按输出格式输出导入结果
+func (ctx *cliContext) renderImportResult(result *response.BulkImportResult) error {
+	if ctx.opts.output != "table" {
+		return ctx.render(result, nil, nil)
+	}
+	printImportResult(ctx.out, result)
+	return nil
+}
+
+// printImportResult 输出导入结果和行级错误
+func printImportResult(w io.Writer, result *response.BulkImportResult) {
+	mode := "已写入"
+	if result.DryRun || len(result.Errors) > 0 {
+		mode = "未写入"
+	}
+	fmt.Fprintf(w, "共 %d 行：新建 %d，更新 %d，未变化 %d（%s）\n",
+		result.Total, result.Created, result.Updated, result.Unchanged, mode)
+
+	for _, e := range result.Errors {
+		if e.Field != "" {
+			fmt.Fprintf(w, "第 %d 行 %s: %s\n", e.Row, e.Field, e.Message)
+		} else {
+			fmt.Fprintf(w, "第 %d 行: %s\n", e.Row, e.Message)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hang666/EasyUKey/sdk/response"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	cases := map[string]string{
+		"users.csv":       "csv",
+		"users.JSON":      "json",
+		"dir/groups.json": "json",
+		"noext":           "csv",
+	}
+	for path, want := range cases {
+		if got := formatFromPath(path); got != want {
+			t.Errorf("formatFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestPrintImportResult(t *testing.T) {
+	var buf bytes.Buffer
+	printImportResult(&buf, &response.BulkImportResult{
+		Total:   2,
+		Created: 1,
+		Errors:  []response.BulkRowError{{Row: 2, Field: "username", Message: "用户名与第1行重复"}},
+	})
+
+	out := buf.String()
+	if !strings.Contains(out, "未写入") {
+		t.Errorf("存在行级错误时应提示未写入: %s", out)
+	}
+	if !strings.Contains(out, "第 2 行 username: 用户名与第1行重复") {
+		t.Errorf("缺少行级错误: %s", out)
+	}
+}
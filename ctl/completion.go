@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// runCompletion 输出命令补全脚本，脚本内容由命令树生成
+func runCompletion(ctx *cliContext, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: completion bash|zsh|fish")
+	}
+
+	root := rootCommand()
+	globals := globalFlagNames()
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(ctx.out, root, globals)
+	case "zsh":
+		// zsh 通过 bashcompinit 复用 bash 补全函数
+		fmt.Fprintln(ctx.out, "#compdef easyukeyctl")
+		fmt.Fprintln(ctx.out, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(ctx.out, root, globals)
+	case "fish":
+		writeFishCompletion(ctx.out, root)
+	default:
+		return fmt.Errorf("不支持的 shell: %s", args[0])
+	}
+	return nil
+}
+
+// globalFlagNames 全局参数名列表
+func globalFlagNames() []string {
+	var names []string
+	globalFlagSet(&globalOptions{}).VisitAll(func(f *pflag.Flag) {
+		names = append(names, "--"+f.Name)
+		if f.Shorthand != "" {
+			names = append(names, "-"+f.Shorthand)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+// completionNode 补全节点，words 为该命令路径之后可输入的词
+type completionNode struct {
+	path  string
+	words []string
+	leaf  bool // 叶子命令，words 为首个参数的可选值
+}
+
+// completionNodes 按深度优先顺序返回所有可补全的命令路径，
+// 叶子命令用法中以 | 分隔的首个参数（如 users|device-groups）作为可选值
+func completionNodes(root *command) []completionNode {
+	var nodes []completionNode
+	var walk func(c *command, path string)
+	walk = func(c *command, path string) {
+		if len(c.sub) == 0 {
+			if choices := usageChoices(c.usage); len(choices) > 0 {
+				nodes = append(nodes, completionNode{path: path, words: choices, leaf: true})
+			}
+			return
+		}
+		words := make([]string, 0, len(c.sub))
+		for _, sub := range c.sub {
+			words = append(words, sub.name)
+		}
+		nodes = append(nodes, completionNode{path: path, words: words})
+		for _, sub := range c.sub {
+			walk(sub, strings.TrimSpace(path+" "+sub.name))
+		}
+	}
+	walk(root, "")
+	return nodes
+}
+
+// usageChoices 解析用法中首个参数的可选值
+func usageChoices(usage string) []string {
+	first, _, _ := strings.Cut(usage, " ")
+	if !strings.Contains(first, "|") || strings.ContainsAny(first[:1], "<[-") {
+		return nil
+	}
+	return strings.Split(first, "|")
+}
+
+// writeBashCompletion 输出 bash 补全函数，跳过参数后按已输入的命令路径补全子命令
+func writeBashCompletion(w io.Writer, root *command, globals []string) {
+	nodes := completionNodes(root)
+
+	// 已识别的命令路径，包括叶子命令选定可选值后的路径，其后的参数按文件名补全
+	var known []string
+	for _, node := range nodes[1:] {
+		known = append(known, fmt.Sprintf("%q", node.path))
+		if node.leaf {
+			for _, choice := range node.words {
+				known = append(known, fmt.Sprintf("%q", node.path+" "+choice))
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "_easyukeyctl() {")
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" path="" next word i`)
+	fmt.Fprintln(w, `	for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `		word="${COMP_WORDS[i]}"`)
+	fmt.Fprintln(w, `		next="${path:+$path }$word"`)
+	fmt.Fprintln(w, `		case "$next" in`)
+	fmt.Fprintf(w, "\t\t%s) path=\"$next\" ;;\n", strings.Join(known, "|"))
+	fmt.Fprintln(w, `		esac`)
+	fmt.Fprintln(w, `	done`)
+	fmt.Fprintln(w, `	case "$path" in`)
+	for _, node := range nodes {
+		words := node.words
+		if node.path == "" {
+			words = append(words, globals...)
+		}
+		fmt.Fprintf(w, "\t%q) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", node.path, strings.Join(words, " "))
+	}
+	fmt.Fprintln(w, `	*) COMPREPLY=($(compgen -f -- "$cur")) ;;`)
+	fmt.Fprintln(w, `	esac`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _easyukeyctl easyukeyctl")
+}
+
+// writeFishCompletion 输出 fish 补全定义
+func writeFishCompletion(w io.Writer, root *command) {
+	globalFlagSet(&globalOptions{}).VisitAll(func(f *pflag.Flag) {
+		short := ""
+		if f.Shorthand != "" {
+			short = " -s " + f.Shorthand
+		}
+		fmt.Fprintf(w, "complete -c easyukeyctl -n '__fish_use_subcommand' -l %s%s -r -d %s\n", f.Name, short, fishQuote(f.Usage))
+	})
+	for _, sub := range root.sub {
+		fmt.Fprintf(w, "complete -c easyukeyctl -f -n '__fish_use_subcommand' -a %s -d %s\n", sub.name, fishQuote(sub.short))
+	}
+	for _, node := range completionNodes(root)[1:] {
+		cond := fmt.Sprintf("__fish_seen_subcommand_from %s; and not __fish_seen_subcommand_from %s", node.path, strings.Join(node.words, " "))
+		parent := root.child(node.path)
+		for _, word := range node.words {
+			desc := ""
+			if leaf := parent.child(word); leaf != nil {
+				desc = " -d " + fishQuote(leaf.short)
+			}
+			fmt.Fprintf(w, "complete -c easyukeyctl -f -n '%s' -a %s%s\n", cond, word, desc)
+		}
+	}
+}
+
+// fishQuote 转义为 fish 单引号字符串
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
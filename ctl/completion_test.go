@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	cases := map[string][]string{
+		"bash": {
+			"complete -F _easyukeyctl easyukeyctl",
+			`"users") COMPREPLY=($(compgen -W "list get create update delete devices"`,
+			`"import") COMPREPLY=($(compgen -W "users device-groups"`,
+			"--admin-key-file",
+		},
+		"zsh": {"#compdef easyukeyctl", "bashcompinit", "complete -F _easyukeyctl easyukeyctl"},
+		"fish": {
+			"-l output -s o",
+			"-a groups -d '管理设备组'",
+			"__fish_seen_subcommand_from groups; and not __fish_seen_subcommand_from list get update link unlink rekey' -a rekey",
+		},
+	}
+	for shell, want := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"completion", shell}, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: 退出码 %d: %s", shell, code, stderr.String())
+		}
+		for _, s := range want {
+			if !strings.Contains(stdout.String(), s) {
+				t.Errorf("%s 补全脚本缺少 %q", shell, s)
+			}
+		}
+	}
+
+	if code, _ := runForTest(t, "completion", "powershell"); code != 1 {
+		t.Errorf("不支持的 shell 退出码 = %d, want 1", code)
+	}
+}
+
+func TestUsageChoices(t *testing.T) {
+	cases := map[string]string{
+		"bash|zsh|fish": "bash zsh fish",
+		"users|device-groups <文件> [--dry-run]": "users device-groups",
+		"<用户ID>":              "",
+		"[--format csv|json]": "",
+		"":                    "",
+	}
+	for usage, want := range cases {
+		if got := strings.Join(usageChoices(usage), " "); got != want {
+			t.Errorf("usageChoices(%q) = %q, want %q", usage, got, want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultServer 未配置服务端地址时使用的默认地址
+const defaultServer = "http://localhost:8888"
+
+// Config easyukeyctl 配置文件，保存多个服务端的连接档案
+type Config struct {
+	CurrentProfile string              `yaml:"current_profile,omitempty"`
+	Profiles       map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile 服务端连接档案，管理员密钥不写入配置文件，只记录读取位置
+type Profile struct {
+	Server       string `yaml:"server"`
+	AdminKeyFile string `yaml:"admin_key_file,omitempty"` // 保存管理员密钥的文件
+	AdminKeyEnv  string `yaml:"admin_key_env,omitempty"`  // 保存管理员密钥的环境变量名
+}
+
+// defaultConfigPath 默认配置文件路径
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("获取用户配置目录失败: %w", err)
+	}
+	return filepath.Join(dir, "easyukeyctl", "config.yaml"), nil
+}
+
+// defaultConfigPathHint 用于帮助信息的默认配置文件路径
+func defaultConfigPathHint() string {
+	if path, err := defaultConfigPath(); err == nil {
+		return path
+	}
+	return "<用户配置目录>/easyukeyctl/config.yaml"
+}
+
+// loadConfig 读取配置文件，文件不存在时返回空配置
+func loadConfig(path string) (*Config, error) {
+	if path == "" {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &Config{Profiles: map[string]*Profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	return cfg, nil
+}
+
+// saveConfig 写入配置文件，仅当前用户可读写
+func saveConfig(path string, cfg *Config) error {
+	if path == "" {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			return err
+		}
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("序列化配置失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	return nil
+}
+
+// resolveConnection 确定服务端地址和管理员密钥
+// 地址优先级：--server > EASYUKEY_SERVER > 配置档案 > 默认地址
+// 密钥优先级：--admin-key > --admin-key-file > EASYUKEY_ADMIN_KEY > 配置档案的环境变量 > 配置档案的密钥文件
+func resolveConnection(cfg *Config, opts globalOptions, getenv func(string) string, readFile func(string) ([]byte, error)) (string, string, error) {
+	var profile *Profile
+	name := opts.profile
+	if name == "" {
+		name = cfg.CurrentProfile
+	}
+	if name != "" {
+		var ok bool
+		if profile, ok = cfg.Profiles[name]; !ok {
+			return "", "", fmt.Errorf("配置档案不存在: %s", name)
+		}
+	}
+
+	server := firstNonEmpty(opts.server, getenv("EASYUKEY_SERVER"))
+	if server == "" && profile != nil {
+		server = profile.Server
+	}
+	if server == "" {
+		server = defaultServer
+	}
+
+	if opts.adminKey != "" {
+		return server, opts.adminKey, nil
+	}
+	if opts.adminKeyFile != "" {
+		key, err := readKeyFile(opts.adminKeyFile, readFile)
+		return server, key, err
+	}
+	if key := getenv("EASYUKEY_ADMIN_KEY"); key != "" {
+		return server, key, nil
+	}
+	if profile != nil {
+		if profile.AdminKeyEnv != "" {
+			if key := getenv(profile.AdminKeyEnv); key != "" {
+				return server, key, nil
+			}
+		}
+		if profile.AdminKeyFile != "" {
+			key, err := readKeyFile(profile.AdminKeyFile, readFile)
+			return server, key, err
+		}
+	}
+
+	return "", "", errors.New("缺少管理员API密钥，请使用 --admin-key-file、环境变量 EASYUKEY_ADMIN_KEY 或在配置档案中设置密钥来源")
+}
+
+// readKeyFile 读取密钥文件，支持 ~ 开头的路径，去除首尾空白
+func readKeyFile(path string, readFile func(string) ([]byte, error)) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("获取用户主目录失败: %w", err)
+		}
+		path = filepath.Join(home, rest)
+	}
+
+	data, err := readFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("密钥文件为空: %s", path)
+	}
+	return key, nil
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// profileCommand 配置档案管理命令
+func profileCommand() *command {
+	return &command{
+		name:  "profile",
+		short: "管理服务端连接档案",
+		sub: []*command{
+			{name: "list", short: "列出配置档案", run: runProfileList},
+			{name: "set", usage: "<名称> [--server <地址>] [--admin-key-file <文件>] [--admin-key-env <变量名>] [--use]", short: "新建或修改配置档案", run: runProfileSet},
+			{name: "use", usage: "<名称>", short: "切换默认配置档案", run: runProfileUse},
+			{name: "delete", usage: "<名称>", short: "删除配置档案", run: runProfileDelete},
+		},
+	}
+}
+
+// runProfileList 列出配置档案
+func runProfileList(ctx *cliContext, args []string) error {
+	cfg, err := loadConfig(ctx.opts.configPath)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type profileView struct {
+		Name         string `json:"name"`
+		Current      bool   `json:"current"`
+		Server       string `json:"server"`
+		AdminKeyFile string `json:"admin_key_file,omitempty"`
+		AdminKeyEnv  string `json:"admin_key_env,omitempty"`
+	}
+	views := make([]profileView, 0, len(names))
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		p := cfg.Profiles[name]
+		view := profileView{Name: name, Current: name == cfg.CurrentProfile, Server: p.Server, AdminKeyFile: p.AdminKeyFile, AdminKeyEnv: p.AdminKeyEnv}
+		views = append(views, view)
+
+		current := ""
+		if view.Current {
+			current = "*"
+		}
+		rows = append(rows, []string{current, name, p.Server, p.AdminKeyFile, p.AdminKeyEnv})
+	}
+
+	return ctx.render(views, []string{"CURRENT", "NAME", "SERVER", "ADMIN KEY FILE", "ADMIN KEY ENV"}, rows)
+}
+
+// runProfileSet 新建或修改配置档案
+func runProfileSet(ctx *cliContext, args []string) error {
+	flags := newFlagSet("profile set", "<名称> [参数]")
+	server := flags.String("server", "", "服务端地址")
+	keyFile := flags.String("admin-key-file", "", "保存管理员密钥的文件")
+	keyEnv := flags.String("admin-key-env", "", "保存管理员密钥的环境变量名")
+	use := flags.Bool("use", false, "设为默认配置档案")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("用法: profile set <名称> [参数]")
+	}
+	name := flags.Arg(0)
+
+	cfg, err := loadConfig(ctx.opts.configPath)
+	if err != nil {
+		return err
+	}
+
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		profile = &Profile{}
+		cfg.Profiles[name] = profile
+	}
+	if flags.Changed("server") {
+		profile.Server = *server
+	}
+	if flags.Changed("admin-key-file") {
+		profile.AdminKeyFile = *keyFile
+	}
+	if flags.Changed("admin-key-env") {
+		profile.AdminKeyEnv = *keyEnv
+	}
+	if *use || cfg.CurrentProfile == "" {
+		cfg.CurrentProfile = name
+	}
+
+	if err := saveConfig(ctx.opts.configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "配置档案 %s 已保存\n", name)
+	return nil
+}
+
+// runProfileUse 切换默认配置档案
+func runProfileUse(ctx *cliContext, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: profile use <名称>")
+	}
+
+	cfg, err := loadConfig(ctx.opts.configPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Profiles[args[0]]; !ok {
+		return fmt.Errorf("配置档案不存在: %s", args[0])
+	}
+	cfg.CurrentProfile = args[0]
+
+	if err := saveConfig(ctx.opts.configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "已切换到配置档案 %s\n", args[0])
+	return nil
+}
+
+// runProfileDelete 删除配置档案
+func runProfileDelete(ctx *cliContext, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: profile delete <名称>")
+	}
+
+	cfg, err := loadConfig(ctx.opts.configPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Profiles[args[0]]; !ok {
+		return fmt.Errorf("配置档案不存在: %s", args[0])
+	}
+	delete(cfg.Profiles, args[0])
+	if cfg.CurrentProfile == args[0] {
+		cfg.CurrentProfile = ""
+	}
+
+	if err := saveConfig(ctx.opts.configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "配置档案 %s 已删除\n", args[0])
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveConnection(t *testing.T) {
+	cfg := &Config{
+		CurrentProfile: "prod",
+		Profiles: map[string]*Profile{
+			"prod":    {Server: "https://prod.example.com", AdminKeyEnv: "PROD_KEY", AdminKeyFile: "/keys/prod"},
+			"staging": {Server: "https://staging.example.com", AdminKeyFile: "/keys/staging"},
+		},
+	}
+	files := map[string]string{"/keys/prod": "prod-file-key\n", "/keys/staging": "  staging-key  ", "/keys/flag": "flag-file-key"}
+	readFile := func(path string) ([]byte, error) {
+		if v, ok := files[path]; ok {
+			return []byte(v), nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	cases := []struct {
+		name       string
+		opts       globalOptions
+		env        map[string]string
+		wantServer string
+		wantKey    string
+	}{
+		{name: "当前档案的环境变量优先于密钥文件", env: map[string]string{"PROD_KEY": "prod-env-key"}, wantServer: "https://prod.example.com", wantKey: "prod-env-key"},
+		{name: "档案环境变量为空时读取密钥文件", wantServer: "https://prod.example.com", wantKey: "prod-file-key"},
+		{name: "指定档案", opts: globalOptions{profile: "staging"}, wantServer: "https://staging.example.com", wantKey: "staging-key"},
+		{name: "通用环境变量优先于档案", env: map[string]string{"EASYUKEY_ADMIN_KEY": "env-key", "EASYUKEY_SERVER": "http://env:8888", "PROD_KEY": "prod-env-key"}, wantServer: "http://env:8888", wantKey: "env-key"},
+		{name: "参数优先于环境变量", opts: globalOptions{server: "http://flag:8888", adminKeyFile: "/keys/flag"}, env: map[string]string{"EASYUKEY_ADMIN_KEY": "env-key", "EASYUKEY_SERVER": "http://env:8888"}, wantServer: "http://flag:8888", wantKey: "flag-file-key"},
+		{name: "--admin-key 优先级最高", opts: globalOptions{adminKey: "flag-key", adminKeyFile: "/keys/flag"}, wantServer: "https://prod.example.com", wantKey: "flag-key"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			getenv := func(name string) string { return tc.env[name] }
+			server, key, err := resolveConnection(cfg, tc.opts, getenv, readFile)
+			if err != nil {
+				t.Fatalf("resolveConnection() error = %v", err)
+			}
+			if server != tc.wantServer || key != tc.wantKey {
+				t.Errorf("resolveConnection() = %q, %q, want %q, %q", server, key, tc.wantServer, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestResolveConnectionErrors(t *testing.T) {
+	getenv := func(string) string { return "" }
+	readFile := func(string) ([]byte, error) { return []byte("\n"), nil }
+
+	if _, _, err := resolveConnection(&Config{}, globalOptions{profile: "missing"}, getenv, readFile); err == nil {
+		t.Error("档案不存在时应返回错误")
+	}
+
+	server, _, err := resolveConnection(&Config{}, globalOptions{}, getenv, readFile)
+	if err == nil {
+		t.Error("没有密钥来源时应返回错误")
+	}
+	if server != "" {
+		t.Errorf("出错时不应返回服务端地址: %q", server)
+	}
+
+	if _, _, err := resolveConnection(&Config{}, globalOptions{adminKeyFile: "/empty"}, getenv, readFile); err == nil {
+		t.Error("密钥文件为空时应返回错误")
+	}
+
+	failing := func(string) ([]byte, error) { return nil, errors.New("permission denied") }
+	if _, _, err := resolveConnection(&Config{}, globalOptions{adminKeyFile: "/key"}, getenv, failing); err == nil {
+		t.Error("读取密钥文件失败时应返回错误")
+	}
+}
+
+func TestResolveConnectionDefaultServer(t *testing.T) {
+	getenv := func(string) string { return "" }
+	server, key, err := resolveConnection(&Config{}, globalOptions{adminKey: "k"}, getenv, os.ReadFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server != defaultServer || key != "k" {
+		t.Errorf("resolveConnection() = %q, %q", server, key)
+	}
+}
+
+func TestProfileCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config.yaml")
+
+	for _, args := range [][]string{
+		{"--config", path, "profile", "set", "prod", "--server", "https://prod.example.com", "--admin-key-file", "~/.easyukey/prod.key"},
+		{"--config", path, "profile", "set", "dev", "--server", "http://localhost:8888", "--admin-key-env", "DEV_KEY"},
+		{"--config", path, "profile", "use", "dev"},
+	} {
+		if code, stderr := runForTest(t, args...); code != 0 {
+			t.Fatalf("%v 退出码 %d: %s", args, code, stderr)
+		}
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentProfile != "dev" {
+		t.Errorf("CurrentProfile = %q, want dev", cfg.CurrentProfile)
+	}
+	if p := cfg.Profiles["prod"]; p == nil || p.Server != "https://prod.example.com" || p.AdminKeyFile != "~/.easyukey/prod.key" {
+		t.Errorf("prod 档案不正确: %+v", p)
+	}
+	if p := cfg.Profiles["dev"]; p == nil || p.AdminKeyEnv != "DEV_KEY" {
+		t.Errorf("dev 档案不正确: %+v", p)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("配置文件权限 = %o, want 600", perm)
+	}
+
+	if code, stderr := runForTest(t, "--config", path, "profile", "delete", "dev"); code != 0 {
+		t.Fatalf("profile delete 退出码 %d: %s", code, stderr)
+	}
+	if cfg, _ = loadConfig(path); cfg.CurrentProfile != "" || cfg.Profiles["dev"] != nil {
+		t.Errorf("删除当前档案后应清空默认档案: %+v", cfg)
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Profiles == nil || len(cfg.Profiles) != 0 {
+		t.Errorf("配置文件不存在时应返回空配置: %+v", cfg)
+	}
+}
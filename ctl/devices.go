@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hang666/EasyUKey/sdk"
+	"github.com/hang666/EasyUKey/sdk/request"
+)
+
+// devicesCommand 设备管理命令
+func devicesCommand() *command {
+	return &command{
+		name:  "devices",
+		short: "管理设备",
+		sub: []*command{
+			{name: "list", usage: "[--online|--offline] [--active=true|false] [--group <设备组ID>] [--name <名称>] [--page <页码>] [--page-size <数量>]", short: "列出设备", run: runDevicesList},
+			{name: "get", usage: "<设备ID>", short: "查看设备", run: runDevicesGet},
+			{name: "update", usage: "<设备ID> [--name <名称>] [--remark <备注>] [--active=true|false]", short: "修改设备", run: runDevicesUpdate},
+			{name: "delete", usage: "<设备ID>", short: "删除设备", run: runDevicesDelete},
+			{name: "offline", usage: "<设备ID>", short: "强制设备下线", run: runDevicesOffline},
+			{name: "pending", short: "列出待激活设备", run: runDevicesPending},
+		},
+	}
+}
+
+func runDevicesList(ctx *cliContext, args []string) error {
+	flags := newFlagSet("devices list", "[参数]")
+	online := flags.Bool("online", false, "只显示在线设备")
+	offline := flags.Bool("offline", false, "只显示离线设备")
+	active := flags.Bool("active", false, "按启用状态过滤")
+	group := flags.Uint("group", 0, "按设备组ID过滤")
+	name := flags.String("name", "", "按设备名称模糊匹配")
+	page, pageSize := pageFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *online && *offline {
+		return errors.New("--online 和 --offline 不能同时使用")
+	}
+
+	filter := &request.DeviceFilter{Name: *name, OnlineOnly: *online, OfflineOnly: *offline}
+	if flags.Changed("active") {
+		filter.IsActive = active
+	}
+	if flags.Changed("group") {
+		filter.DeviceGroupID = group
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	devices, total, err := client.GetDevices(*page, *pageSize, filter)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.render(devices, deviceHeaders, deviceRows(devices)); err != nil {
+		return err
+	}
+	return ctx.renderTotal(total, len(devices))
+}
+
+func runDevicesGet(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "devices get <设备ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	device, err := client.GetDevice(id)
+	if err != nil {
+		return err
+	}
+	return ctx.render(device, deviceHeaders, deviceRows([]sdk.Device{*device}))
+}
+
+func runDevicesUpdate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("devices update", "<设备ID> [参数]")
+	name := flags.String("name", "", "设备名称")
+	remark := flags.String("remark", "", "备注")
+	active := flags.Bool("active", false, "启用或停用设备")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := singleID(flags.Args(), "devices update <设备ID> [参数]")
+	if err != nil {
+		return err
+	}
+
+	req := &request.UpdateDeviceRequest{Name: *name, Remark: *remark}
+	if flags.Changed("active") {
+		req.IsActive = active
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	device, err := client.UpdateDevice(id, req)
+	if err != nil {
+		return err
+	}
+	return ctx.render(device, deviceHeaders, deviceRows([]sdk.Device{*device}))
+}
+
+func runDevicesDelete(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "devices delete <设备ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteDevice(id); err != nil {
+		return err
+	}
+	return ctx.renderMessage(map[string]uint{"deleted_device_id": id}, fmt.Sprintf("设备 %d 已删除", id))
+}
+
+func runDevicesOffline(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "devices offline <设备ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	device, err := client.OfflineDevice(id)
+	if err != nil {
+		return err
+	}
+	return ctx.render(device, deviceHeaders, deviceRows([]sdk.Device{*device}))
+}
+
+func runDevicesPending(ctx *cliContext, args []string) error {
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	devices, err := client.GetPendingActivationDevices()
+	if err != nil {
+		return err
+	}
+	return ctx.render(devices, deviceHeaders, deviceRows(devices))
+}
+
+// runStats 查看设备统计
+func runStats(ctx *cliContext, args []string) error {
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	stats, err := client.GetDeviceStatistics()
+	if err != nil {
+		return err
+	}
+
+	return ctx.render(stats, []string{"TOTAL", "ONLINE", "OFFLINE", "ACTIVE", "BOUND"}, [][]string{{
+		strconv.FormatInt(stats.TotalDevices, 10),
+		strconv.FormatInt(stats.OnlineDevices, 10),
+		strconv.FormatInt(stats.OfflineDevices, 10),
+		strconv.FormatInt(stats.ActiveDevices, 10),
+		strconv.FormatInt(stats.BoundDevices, 10),
+	}})
+}
+
+var deviceHeaders = []string{"ID", "NAME", "SERIAL", "USER", "GROUP", "ONLINE", "ACTIVE", "LAST SEEN"}
+
+// deviceRows 设备表格行
+func deviceRows(devices []sdk.Device) [][]string {
+	rows := make([][]string, 0, len(devices))
+	for _, d := range devices {
+		user := d.Username
+		if user == "" {
+			user = formatUintPtr(d.UserID)
+		}
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(d.ID), 10),
+			d.Name,
+			d.SerialNumber,
+			user,
+			formatUintPtr(d.DeviceGroupID),
+			strconv.FormatBool(d.IsOnline),
+			strconv.FormatBool(d.IsActive),
+			formatTime(d.LastSeen),
+		})
+	}
+	return rows
+}
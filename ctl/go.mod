@@ -0,0 +1,11 @@
+module github.com/hang666/EasyUKey/ctl
+
+go 1.24.5
+
+replace github.com/hang666/EasyUKey/sdk => ../sdk
+
+require (
+	github.com/hang666/EasyUKey/sdk v0.0.0
+	github.com/spf13/pflag v1.0.7
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hang666/EasyUKey/sdk"
+	"github.com/hang666/EasyUKey/sdk/request"
+)
+
+// groupsCommand 设备组管理命令
+func groupsCommand() *command {
+	return &command{
+		name:  "groups",
+		short: "管理设备组",
+		sub: []*command{
+			{name: "list", short: "列出设备组", run: runGroupsList},
+			{name: "get", usage: "<设备组ID>", short: "查看设备组", run: runGroupsGet},
+			{name: "update", usage: "<设备组ID> [--name <名称>] [--description <描述>] [--permissions <权限,...>] [--active=true|false]", short: "修改设备组", run: runGroupsUpdate},
+			{name: "link", usage: "<设备组ID> <用户ID>", short: "将设备组关联到用户", run: runGroupsLink},
+			{name: "unlink", usage: "<设备组ID>", short: "取消设备组的用户关联", run: runGroupsUnlink},
+			{name: "rekey", usage: "<设备组ID>", short: "重置被隔离设备组的密钥", run: runGroupsRekey},
+		},
+	}
+}
+
+func runGroupsList(ctx *cliContext, args []string) error {
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	groups, err := client.GetDeviceGroups()
+	if err != nil {
+		return err
+	}
+	return ctx.render(groups, groupHeaders, groupRows(groups))
+}
+
+func runGroupsGet(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "groups get <设备组ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	group, err := client.GetDeviceGroup(id)
+	if err != nil {
+		return err
+	}
+	return ctx.render(group, groupHeaders, groupRows([]sdk.DeviceGroup{*group}))
+}
+
+func runGroupsUpdate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("groups update", "<设备组ID> [参数]")
+	name := flags.String("name", "", "设备组名称")
+	description := flags.String("description", "", "描述")
+	permissions := flags.StringSlice("permissions", nil, "权限列表，逗号分隔")
+	active := flags.Bool("active", false, "启用或停用设备组")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := singleID(flags.Args(), "groups update <设备组ID> [参数]")
+	if err != nil {
+		return err
+	}
+
+	req := &request.UpdateDeviceGroupRequest{Name: *name, Description: *description, Permissions: *permissions}
+	if flags.Changed("active") {
+		req.IsActive = active
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	group, err := client.UpdateDeviceGroup(id, req)
+	if err != nil {
+		return err
+	}
+	return ctx.render(group, groupHeaders, groupRows([]sdk.DeviceGroup{*group}))
+}
+
+func runGroupsLink(ctx *cliContext, args []string) error {
+	if len(args) != 2 {
+		return errors.New("用法: groups link <设备组ID> <用户ID>")
+	}
+	groupID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	userID, err := parseID(args[1])
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	if err := client.LinkDeviceGroupUser(groupID, &userID); err != nil {
+		return err
+	}
+	return ctx.renderMessage(map[string]uint{"device_group_id": groupID, "user_id": userID},
+		fmt.Sprintf("设备组 %d 已关联到用户 %d", groupID, userID))
+}
+
+func runGroupsUnlink(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "groups unlink <设备组ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	if err := client.LinkDeviceGroupUser(id, nil); err != nil {
+		return err
+	}
+	return ctx.renderMessage(map[string]interface{}{"device_group_id": id, "user_id": nil},
+		fmt.Sprintf("设备组 %d 已取消用户关联", id))
+}
+
+func runGroupsRekey(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "groups rekey <设备组ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	codes, err := client.RekeyDeviceGroup(id)
+	if err != nil {
+		return err
+	}
+
+	// 恢复码只下发这一次，表格格式下逐行输出便于保存
+	rows := make([][]string, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, []string{code})
+	}
+	return ctx.render(map[string]interface{}{"device_group_id": id, "recovery_codes": codes}, []string{"RECOVERY CODE"}, rows)
+}
+
+var groupHeaders = []string{"ID", "NAME", "USER", "PERMISSIONS", "ACTIVE", "QUARANTINED", "DEVICES"}
+
+// groupRows 设备组表格行
+func groupRows(groups []sdk.DeviceGroup) [][]string {
+	rows := make([][]string, 0, len(groups))
+	for _, g := range groups {
+		user := formatUintPtr(g.UserID)
+		if g.User != nil {
+			user = g.User.Username
+		}
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(g.ID), 10),
+			g.Name,
+			user,
+			strings.Join(g.Permissions, ","),
+			strconv.FormatBool(g.IsActive),
+			strconv.FormatBool(g.Quarantined),
+			strconv.Itoa(len(g.Devices)),
+		})
+	}
+	return rows
+}
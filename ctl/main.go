@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/hang666/EasyUKey/sdk"
+)
+
+// command 命令树节点，有子命令的节点只负责分发
+type command struct {
+	name  string
+	usage string // 参数说明，不含命令路径
+	short string
+	sub   []*command
+	run   func(ctx *cliContext, args []string) error
+}
+
+// cliContext 命令执行上下文
+type cliContext struct {
+	opts   globalOptions
+	out    io.Writer
+	client *sdk.AdminClient
+}
+
+// globalOptions 全局参数
+type globalOptions struct {
+	configPath   string
+	profile      string
+	server       string
+	adminKey     string
+	adminKeyFile string
+	output       string
+}
+
+// adminClient 按全局参数和配置档案创建管理员客户端
+func (ctx *cliContext) adminClient() (*sdk.AdminClient, error) {
+	if ctx.client != nil {
+		return ctx.client, nil
+	}
+
+	cfg, err := loadConfig(ctx.opts.configPath)
+	if err != nil {
+		return nil, err
+	}
+	server, key, err := resolveConnection(cfg, ctx.opts, os.Getenv, os.ReadFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.client = sdk.NewAdminClient(server, key)
+	return ctx.client, nil
+}
+
+// rootCommand 构建完整的命令树
+func rootCommand() *command {
+	return &command{
+		name: "easyukeyctl",
+		sub: []*command{
+			usersCommand(),
+			devicesCommand(),
+			groupsCommand(),
+			apiKeysCommand(),
+			oidcClientsCommand(),
+			sessionsCommand(),
+			{name: "stats", short: "查看设备统计", run: runStats},
+			{name: "import", usage: "users|device-groups <文件> [--format csv|json] [--dry-run]", short: "批量导入用户或设备组分配", run: runImport},
+			{name: "export", usage: "users|device-groups [--format csv|json] [-o <文件>]", short: "导出用户或设备组分配", run: runExport},
+			profileCommand(),
+			{name: "completion", usage: "bash|zsh|fish", short: "输出命令补全脚本", run: runCompletion},
+		},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 解析全局参数并执行命令，返回进程退出码
+func run(args []string, stdout, stderr io.Writer) int {
+	root := rootCommand()
+	ctx := &cliContext{out: stdout}
+
+	flags := globalFlagSet(&ctx.opts)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { printUsage(stderr, root, nil, flags) }
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if !isOutputFormat(ctx.opts.output) {
+		fmt.Fprintf(stderr, "不支持的输出格式: %s\n", ctx.opts.output)
+		return 2
+	}
+
+	cmd, path, rest := root.find(flags.Args())
+	if cmd.run == nil {
+		if len(rest) > 0 {
+			fmt.Fprintf(stderr, "未知命令: %s\n", strings.Join(append(path, rest[0]), " "))
+		}
+		printUsage(stderr, cmd, path, flags)
+		return 2
+	}
+
+	if err := cmd.run(ctx, rest); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(stderr, "错误:", err)
+		return 1
+	}
+	return 0
+}
+
+// globalFlagSet 创建全局参数集，命令补全也据此生成全局参数列表
+func globalFlagSet(opts *globalOptions) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("easyukeyctl", pflag.ContinueOnError)
+	flags.SetInterspersed(false)
+	flags.StringVar(&opts.configPath, "config", "", "配置文件路径，默认 "+defaultConfigPathHint())
+	flags.StringVarP(&opts.profile, "profile", "p", os.Getenv("EASYUKEY_PROFILE"), "使用的配置档案（环境变量 EASYUKEY_PROFILE）")
+	flags.StringVar(&opts.server, "server", "", "服务端地址，覆盖配置档案（环境变量 EASYUKEY_SERVER）")
+	flags.StringVar(&opts.adminKey, "admin-key", "", "管理员API密钥，建议改用 --admin-key-file 或环境变量 EASYUKEY_ADMIN_KEY")
+	flags.StringVar(&opts.adminKeyFile, "admin-key-file", "", "从文件读取管理员API密钥")
+	flags.StringVarP(&opts.output, "output", "o", "table", "输出格式：table、json 或 yaml")
+	return flags
+}
+
+// find 沿命令树查找要执行的命令，返回命令、命令路径和剩余参数
+func (c *command) find(args []string) (*command, []string, []string) {
+	cmd := c
+	var path []string
+	for len(args) > 0 && len(cmd.sub) > 0 {
+		next := cmd.child(args[0])
+		if next == nil {
+			break
+		}
+		cmd = next
+		path = append(path, next.name)
+		args = args[1:]
+	}
+	return cmd, path, args
+}
+
+// child 按名称查找子命令
+func (c *command) child(name string) *command {
+	for _, sub := range c.sub {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// printUsage 输出命令用法
+func printUsage(w io.Writer, cmd *command, path []string, global *pflag.FlagSet) {
+	prefix := strings.Join(append([]string{"easyukeyctl"}, path...), " ")
+	if len(cmd.sub) == 0 {
+		fmt.Fprintf(w, "用法: %s %s\n", prefix, cmd.usage)
+		return
+	}
+
+	fmt.Fprintf(w, "用法: %s <命令> [参数]\n\n命令:\n", prefix)
+	for _, sub := range cmd.sub {
+		fmt.Fprintf(w, "  %-12s %s\n", sub.name, sub.short)
+	}
+	if len(path) == 0 {
+		fmt.Fprintln(w, "\n全局参数:")
+		global.PrintDefaults()
+	}
+}
+
+// newFlagSet 创建子命令参数集，用法中带上命令路径
+func newFlagSet(name, usage string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: easyukeyctl %s %s\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// pageFlags 注册分页参数
+func pageFlags(flags *pflag.FlagSet) (*int, *int) {
+	page := flags.Int("page", 1, "页码")
+	pageSize := flags.Int("page-size", 20, "每页数量，最大 100")
+	return page, pageSize
+}
+
+// singleID 解析唯一的ID参数
+func singleID(args []string, usage string) (uint, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("用法: %s", usage)
+	}
+	return parseID(args[0])
+}
+
+// parseID 解析正整数ID
+func parseID(value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("无效的ID: %s", value)
+	}
+	return uint(id), nil
+}
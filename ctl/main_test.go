@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runForTest 执行命令并返回退出码和标准错误输出
+func runForTest(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stderr.String()
+}
+
+// fakeServer 模拟管理接口，记录收到的请求
+func fakeServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "无效的API密钥"})
+			return
+		}
+		requests = append(requests, r)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func writeData(w http.ResponseWriter, data interface{}, total *int64) {
+	body := map[string]interface{}{"success": true, "message": "ok", "data": data}
+	if total != nil {
+		body["total"] = *total
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestUsersListOutputFormats(t *testing.T) {
+	total := int64(42)
+	srv, requests := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, []map[string]interface{}{
+			{"id": 1, "username": "alice", "permissions": []string{"read", "write"}, "is_active": true, "created_at": time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		}, &total)
+	})
+
+	cases := map[string][]string{
+		"table": {"ID", "alice", "read,write", "true", "共 42 条"},
+		"json":  {`"username": "alice"`, `"is_active": true`},
+		"yaml":  {"- created_at:", "  username: alice", "    - read"},
+	}
+	for format, want := range cases {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"--server", srv.URL, "--admin-key", "test-key", "-o", format, "users", "list", "--page", "2"}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("%s: 退出码 %d: %s", format, code, stderr.String())
+		}
+		for _, s := range want {
+			if !strings.Contains(stdout.String(), s) {
+				t.Errorf("%s 输出缺少 %q:\n%s", format, s, stdout.String())
+			}
+		}
+	}
+
+	if got := (*requests)[0].URL.Query().Get("page"); got != "2" {
+		t.Errorf("page = %q, want 2", got)
+	}
+}
+
+func TestDevicesListFilters(t *testing.T) {
+	srv, requests := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		total := int64(0)
+		writeData(w, []interface{}{}, &total)
+	})
+
+	code, stderr := runForTest(t, "--server", srv.URL, "--admin-key", "test-key", "devices", "list", "--offline", "--active=false", "--group", "7", "--name", "key")
+	if code != 0 {
+		t.Fatalf("退出码 %d: %s", code, stderr)
+	}
+
+	q := (*requests)[0].URL.Query()
+	for name, want := range map[string]string{"offline_only": "true", "is_active": "false", "device_group_id": "7", "name": "key"} {
+		if got := q.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if q.Has("online_only") {
+		t.Error("未指定 --online 时不应发送 online_only")
+	}
+
+	if code, _ := runForTest(t, "--server", srv.URL, "--admin-key", "test-key", "devices", "list", "--online", "--offline"); code != 1 {
+		t.Errorf("--online 和 --offline 同时使用时退出码 = %d, want 1", code)
+	}
+}
+
+func TestGroupsUnlinkSendsNullUser(t *testing.T) {
+	var body map[string]interface{}
+	srv, requests := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		writeData(w, nil, nil)
+	})
+
+	code, stderr := runForTest(t, "--server", srv.URL, "--admin-key", "test-key", "groups", "unlink", "3")
+	if code != 0 {
+		t.Fatalf("退出码 %d: %s", code, stderr)
+	}
+
+	r := (*requests)[0]
+	if r.Method != http.MethodPut || r.URL.Path != "/api/v1/admin/device-groups/3/user" {
+		t.Errorf("请求 = %s %s", r.Method, r.URL.Path)
+	}
+	if v, ok := body["user_id"]; !ok || v != nil {
+		t.Errorf("取消关联应发送 user_id: null，实际 %v", body)
+	}
+}
+
+func TestOIDCClientsCreateRepeatsRedirectURI(t *testing.T) {
+	var body map[string]interface{}
+	srv, requests := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		writeData(w, map[string]interface{}{"id": 1, "name": "wiki", "client_id": "abc", "api_key_id": 5}, nil)
+	})
+
+	code, stderr := runForTest(t, "--server", srv.URL, "--admin-key", "test-key", "oidc-clients", "create", "wiki",
+		"--api-key-id", "5", "--redirect-uri", "https://wiki.example.com/cb,legacy", "--redirect-uri", "http://localhost:8080/cb")
+	if code != 0 {
+		t.Fatalf("退出码 %d: %s", code, stderr)
+	}
+
+	r := (*requests)[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/api/v1/admin/oidc-clients" {
+		t.Errorf("请求 = %s %s", r.Method, r.URL.Path)
+	}
+	uris, _ := body["redirect_uris"].([]interface{})
+	if len(uris) != 2 || uris[0] != "https://wiki.example.com/cb,legacy" {
+		t.Errorf("回调地址不应按逗号拆分: %v", body["redirect_uris"])
+	}
+	if body["api_key_id"] != float64(5) {
+		t.Errorf("api_key_id = %v, want 5", body["api_key_id"])
+	}
+
+	if code, _ := runForTest(t, "--server", srv.URL, "--admin-key", "test-key", "oidc-clients", "create", "wiki", "--api-key-id", "5"); code != 1 {
+		t.Errorf("缺少 --redirect-uri 时退出码 = %d, want 1", code)
+	}
+}
+
+func TestAPIErrorExitCode(t *testing.T) {
+	srv, _ := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	code, stderr := runForTest(t, "--server", srv.URL, "--admin-key", "wrong-key", "stats")
+	if code != 1 {
+		t.Errorf("退出码 = %d, want 1", code)
+	}
+	if !strings.Contains(stderr, "错误") {
+		t.Errorf("应输出错误信息: %s", stderr)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"unknown"},
+		{"users"},
+		{"-o", "xml", "stats"},
+	} {
+		if code, _ := runForTest(t, args...); code != 2 {
+			t.Errorf("%v 退出码 = %d, want 2", args, code)
+		}
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	got, err := parseTimeFlag("24h", now)
+	if err != nil || !got.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("parseTimeFlag(24h) = %v, %v", got, err)
+	}
+	got, err = parseTimeFlag("2025-05-01T00:00:00Z", now)
+	if err != nil || !got.Equal(time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseTimeFlag(RFC3339) = %v, %v", got, err)
+	}
+	if got, err := parseTimeFlag("", now); got != nil || err != nil {
+		t.Errorf("parseTimeFlag(\"\") = %v, %v", got, err)
+	}
+	for _, bad := range []string{"yesterday", "-1h"} {
+		if _, err := parseTimeFlag(bad, now); err == nil {
+			t.Errorf("parseTimeFlag(%q) 应返回错误", bad)
+		}
+	}
+}
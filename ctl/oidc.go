@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hang666/EasyUKey/sdk"
+	"github.com/hang666/EasyUKey/sdk/request"
+)
+
+// oidcClientsCommand OIDC客户端管理命令
+func oidcClientsCommand() *command {
+	return &command{
+		name:  "oidc-clients",
+		short: "管理OIDC客户端",
+		sub: []*command{
+			{name: "list", short: "列出OIDC客户端", run: runOIDCClientsList},
+			{name: "create", usage: "<名称> --api-key-id <密钥ID> --redirect-uri <回调地址>... [--action <操作>]", short: "注册OIDC客户端", run: runOIDCClientsCreate},
+			{name: "update", usage: "<客户端ID> [--name <名称>] [--redirect-uri <回调地址>...] [--action <操作>] [--active=true|false]", short: "修改OIDC客户端", run: runOIDCClientsUpdate},
+			{name: "delete", usage: "<客户端ID>", short: "删除OIDC客户端", run: runOIDCClientsDelete},
+		},
+	}
+}
+
+func runOIDCClientsList(ctx *cliContext, args []string) error {
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	clients, err := client.GetOIDCClients()
+	if err != nil {
+		return err
+	}
+	return ctx.render(clients, oidcClientHeaders, oidcClientRows(clients))
+}
+
+func runOIDCClientsCreate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("oidc-clients create", "<名称> [参数]")
+	apiKeyID := flags.Uint("api-key-id", 0, "关联的非管理员API密钥ID，其值即 client_secret")
+	redirectURIs := flags.StringArray("redirect-uri", nil, "允许的回调地址，可重复指定")
+	action := flags.String("action", "", "登录时请求的操作，为空表示不限制")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *apiKeyID == 0 || len(*redirectURIs) == 0 {
+		return errors.New("用法: oidc-clients create <名称> --api-key-id <密钥ID> --redirect-uri <回调地址>... [--action <操作>]")
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	created, err := client.CreateOIDCClient(&request.CreateOIDCClientRequest{
+		Name:         flags.Arg(0),
+		APIKeyID:     *apiKeyID,
+		RedirectURIs: *redirectURIs,
+		Action:       *action,
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.render(created, oidcClientHeaders, oidcClientRows([]sdk.OIDCClient{*created}))
+}
+
+func runOIDCClientsUpdate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("oidc-clients update", "<客户端ID> [参数]")
+	name := flags.String("name", "", "新名称")
+	redirectURIs := flags.StringArray("redirect-uri", nil, "替换允许的回调地址，可重复指定")
+	action := flags.String("action", "", "登录时请求的操作，传空字符串表示不限制")
+	active := flags.Bool("active", false, "启用或停用客户端，停用时作废进行中的授权")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := singleID(flags.Args(), "oidc-clients update <客户端ID> [参数]")
+	if err != nil {
+		return err
+	}
+
+	req := &request.UpdateOIDCClientRequest{Name: *name, RedirectURIs: *redirectURIs}
+	if flags.Changed("action") {
+		req.Action = action
+	}
+	if flags.Changed("active") {
+		req.IsActive = active
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	updated, err := client.UpdateOIDCClient(id, req)
+	if err != nil {
+		return err
+	}
+	return ctx.render(updated, oidcClientHeaders, oidcClientRows([]sdk.OIDCClient{*updated}))
+}
+
+func runOIDCClientsDelete(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "oidc-clients delete <客户端ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteOIDCClient(id); err != nil {
+		return err
+	}
+	return ctx.renderMessage(map[string]uint{"deleted_oidc_client_id": id}, fmt.Sprintf("OIDC客户端 %d 已删除", id))
+}
+
+var oidcClientHeaders = []string{"ID", "NAME", "CLIENT ID", "API KEY ID", "REDIRECT URIS", "ACTION", "ACTIVE", "CREATED"}
+
+// oidcClientRows OIDC客户端表格行
+func oidcClientRows(clients []sdk.OIDCClient) [][]string {
+	rows := make([][]string, 0, len(clients))
+	for _, c := range clients {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(c.ID), 10),
+			c.Name,
+			c.ClientID,
+			strconv.FormatUint(uint64(c.APIKeyID), 10),
+			strings.Join(c.RedirectURIs, ","),
+			c.Action,
+			strconv.FormatBool(c.IsActive),
+			formatTime(c.CreatedAt),
+		})
+	}
+	return rows
+}
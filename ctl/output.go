@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isOutputFormat 判断是否为支持的输出格式
+func isOutputFormat(format string) bool {
+	return format == "table" || format == "json" || format == "yaml"
+}
+
+// render 按输出格式输出数据，table 格式使用 headers 和 rows，json/yaml 格式输出 data
+func (ctx *cliContext) render(data interface{}, headers []string, rows [][]string) error {
+	switch ctx.opts.output {
+	case "json":
+		enc := json.NewEncoder(ctx.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case "yaml":
+		// 先转换为 JSON 再输出，保证字段名与 JSON 输出和 API 一致
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(ctx.out)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		w := tabwriter.NewWriter(ctx.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	}
+}
+
+// renderMessage 输出操作结果，json/yaml 格式下输出 data
+func (ctx *cliContext) renderMessage(data interface{}, message string) error {
+	if ctx.opts.output == "table" {
+		_, err := fmt.Fprintln(ctx.out, message)
+		return err
+	}
+	return ctx.render(data, nil, nil)
+}
+
+// renderTotal 表格格式下输出分页统计
+func (ctx *cliContext) renderTotal(total int64, shown int) error {
+	if ctx.opts.output != "table" {
+		return nil
+	}
+	_, err := fmt.Fprintf(ctx.out, "\n共 %d 条，本页 %d 条\n", total, shown)
+	return err
+}
+
+// formatTime 格式化表格中的时间，零值显示为 -
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatTimePtr 格式化可为空的时间
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return formatTime(*t)
+}
+
+// formatUintPtr 格式化可为空的ID
+func formatUintPtr(v *uint) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.FormatUint(uint64(*v), 10)
+}
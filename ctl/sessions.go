@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/hang666/EasyUKey/sdk"
+	"github.com/hang666/EasyUKey/sdk/request"
+)
+
+// sessionsCommand 认证会话查询命令
+func sessionsCommand() *command {
+	return &command{
+		name:  "sessions",
+		short: "查询和导出认证会话",
+		sub: []*command{
+			{name: "list", usage: "[过滤参数] [--page <页码>] [--page-size <数量>]", short: "列出认证会话", run: runSessionsList},
+			{name: "export", usage: "[过滤参数] [--format csv|jsonl] [-o <文件>]", short: "导出全部匹配的认证会话", run: runSessionsExport},
+		},
+	}
+}
+
+// sessionFilterFlags 注册认证会话过滤参数，解析后调用返回的函数得到过滤条件
+func sessionFilterFlags(flags *pflag.FlagSet) func(now time.Time) (*request.AuthSessionFilter, error) {
+	filter := &request.AuthSessionFilter{}
+	flags.StringVar(&filter.Status, "status", "", "会话状态")
+	flags.StringVar(&filter.Username, "username", "", "按用户名模糊匹配")
+	flags.StringVar(&filter.Action, "action", "", "认证操作")
+	flags.StringVar(&filter.ClientIP, "client-ip", "", "客户端IP")
+	flags.StringVar(&filter.Search, "search", "", "在挑战码和认证说明中搜索")
+	flags.StringVar(&filter.SortBy, "sort-by", "", "排序字段：created_at、updated_at、expires_at、status 或 action")
+	flags.StringVar(&filter.SortOrder, "sort-order", "", "排序方向：asc 或 desc")
+	apiKeyID := flags.Uint("api-key", 0, "按API密钥ID过滤")
+	deviceID := flags.Uint("device", 0, "按响应认证的设备ID过滤")
+	groupID := flags.Uint("group", 0, "按响应认证的设备所属设备组过滤")
+	since := flags.String("since", "", "创建时间不早于，RFC3339 时间或相对时长（如 24h）")
+	until := flags.String("until", "", "创建时间早于，RFC3339 时间或相对时长")
+
+	return func(now time.Time) (*request.AuthSessionFilter, error) {
+		if flags.Changed("api-key") {
+			filter.APIKeyID = apiKeyID
+		}
+		if flags.Changed("device") {
+			filter.DeviceID = deviceID
+		}
+		if flags.Changed("group") {
+			filter.DeviceGroupID = groupID
+		}
+
+		var err error
+		if filter.StartTime, err = parseTimeFlag(*since, now); err != nil {
+			return nil, fmt.Errorf("无效的 --since: %w", err)
+		}
+		if filter.EndTime, err = parseTimeFlag(*until, now); err != nil {
+			return nil, fmt.Errorf("无效的 --until: %w", err)
+		}
+		return filter, nil
+	}
+}
+
+// parseTimeFlag 解析 RFC3339 时间或相对当前时间的时长，为空时返回 nil
+func parseTimeFlag(value string, now time.Time) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return nil, errors.New("应为 RFC3339 时间或正的时长")
+	}
+	t := now.Add(-d)
+	return &t, nil
+}
+
+func runSessionsList(ctx *cliContext, args []string) error {
+	flags := newFlagSet("sessions list", "[参数]")
+	buildFilter := sessionFilterFlags(flags)
+	page, pageSize := pageFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("用法: sessions list [参数]")
+	}
+	filter, err := buildFilter(time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	sessions, total, err := client.GetAuthSessions(*page, *pageSize, filter)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.render(sessions, sessionHeaders, sessionRows(sessions)); err != nil {
+		return err
+	}
+	return ctx.renderTotal(total, len(sessions))
+}
+
+func runSessionsExport(ctx *cliContext, args []string) error {
+	flags := newFlagSet("sessions export", "[参数]")
+	buildFilter := sessionFilterFlags(flags)
+	format := flags.String("format", "", "导出格式 csv 或 jsonl，默认根据输出文件扩展名判断，输出到标准输出时为 csv")
+	output := flags.StringP("output", "o", "", "输出文件，默认输出到标准输出")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("用法: sessions export [参数]")
+	}
+	filter, err := buildFilter(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = "csv"
+		if *output != "" && formatFromPath(*output) == "jsonl" {
+			*format = "jsonl"
+		}
+	}
+	if *format != "csv" && *format != "jsonl" {
+		return fmt.Errorf("不支持的导出格式: %s", *format)
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	// 导出全部匹配数据可能耗时较长
+	client.SetTimeout(10 * time.Minute)
+
+	export := func(w io.Writer) error { return client.ExportAuthSessions(*format, filter, w) }
+	if *output == "" {
+		return export(ctx.out)
+	}
+	return writeFileAtomic(*output, export)
+}
+
+var sessionHeaders = []string{"ID", "STATUS", "USER", "ACTION", "DEVICE", "CLIENT IP", "CREATED"}
+
+// sessionRows 认证会话表格行
+func sessionRows(sessions []sdk.AuthSession) [][]string {
+	rows := make([][]string, 0, len(sessions))
+	for _, s := range sessions {
+		user := fmt.Sprint(s.UserID)
+		if s.User != nil {
+			user = s.User.Username
+		}
+		rows = append(rows, []string{
+			s.ID,
+			s.Status,
+			user,
+			s.Action,
+			formatUintPtr(s.RespondingDeviceID),
+			s.ClientIP,
+			formatTime(s.CreatedAt),
+		})
+	}
+	return rows
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hang666/EasyUKey/sdk"
+	"github.com/hang666/EasyUKey/sdk/request"
+)
+
+// usersCommand 用户管理命令
+func usersCommand() *command {
+	return &command{
+		name:  "users",
+		short: "管理用户",
+		sub: []*command{
+			{name: "list", usage: "[--page <页码>] [--page-size <数量>]", short: "列出用户", run: runUsersList},
+			{name: "get", usage: "<用户ID>", short: "查看用户", run: runUsersGet},
+			{name: "create", usage: "<用户名> [--permissions <权限,...>]", short: "创建用户", run: runUsersCreate},
+			{name: "update", usage: "<用户ID> [--username <用户名>] [--permissions <权限,...>] [--active=true|false]", short: "修改用户", run: runUsersUpdate},
+			{name: "delete", usage: "<用户ID>", short: "删除用户", run: runUsersDelete},
+			{name: "devices", usage: "<用户名>", short: "列出用户的设备", run: runUsersDevices},
+		},
+	}
+}
+
+func runUsersList(ctx *cliContext, args []string) error {
+	flags := newFlagSet("users list", "[参数]")
+	page, pageSize := pageFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	users, total, err := client.GetUsers(*page, *pageSize)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.render(users, userHeaders, userRows(users)); err != nil {
+		return err
+	}
+	return ctx.renderTotal(total, len(users))
+}
+
+func runUsersGet(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "users get <用户ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	user, err := client.GetUser(id)
+	if err != nil {
+		return err
+	}
+	return ctx.render(user, userHeaders, userRows([]sdk.User{*user}))
+}
+
+func runUsersCreate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("users create", "<用户名> [参数]")
+	permissions := flags.StringSlice("permissions", nil, "权限列表，逗号分隔")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("用法: users create <用户名> [--permissions <权限,...>]")
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	user, err := client.CreateUser(&request.CreateUserRequest{Username: flags.Arg(0), Permissions: *permissions})
+	if err != nil {
+		return err
+	}
+	return ctx.render(user, userHeaders, userRows([]sdk.User{*user}))
+}
+
+func runUsersUpdate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("users update", "<用户ID> [参数]")
+	username := flags.String("username", "", "新用户名")
+	permissions := flags.StringSlice("permissions", nil, "权限列表，逗号分隔")
+	active := flags.Bool("active", false, "启用或停用用户")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := singleID(flags.Args(), "users update <用户ID> [参数]")
+	if err != nil {
+		return err
+	}
+
+	req := &request.UpdateUserRequest{Username: *username, Permissions: *permissions}
+	if flags.Changed("active") {
+		req.IsActive = active
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	user, err := client.UpdateUser(id, req)
+	if err != nil {
+		return err
+	}
+	return ctx.render(user, userHeaders, userRows([]sdk.User{*user}))
+}
+
+func runUsersDelete(ctx *cliContext, args []string) error {
+	id, err := singleID(args, "users delete <用户ID>")
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteUser(id); err != nil {
+		return err
+	}
+	return ctx.renderMessage(map[string]uint{"deleted_user_id": id}, fmt.Sprintf("用户 %d 已删除", id))
+}
+
+func runUsersDevices(ctx *cliContext, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: users devices <用户名>")
+	}
+
+	client, err := ctx.adminClient()
+	if err != nil {
+		return err
+	}
+	devices, err := client.GetUserDevices(args[0])
+	if err != nil {
+		return err
+	}
+	return ctx.render(devices, deviceHeaders, deviceRows(devices))
+}
+
+var userHeaders = []string{"ID", "USERNAME", "PERMISSIONS", "ACTIVE", "CREATED"}
+
+// userRows 用户表格行
+func userRows(users []sdk.User) [][]string {
+	rows := make([][]string, 0, len(users))
+	for _, u := range users {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(u.ID), 10),
+			u.Username,
+			strings.Join(u.Permissions, ","),
+			strconv.FormatBool(u.IsActive),
+			formatTime(u.CreatedAt),
+		})
+	}
+	return rows
+}
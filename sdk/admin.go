@@ -1,9 +1,12 @@
 package sdk
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/hang666/EasyUKey/sdk/errs"
 	"github.com/hang666/EasyUKey/sdk/request"
@@ -24,8 +27,7 @@ func NewAdminClient(baseURL, adminKey string) *AdminClient {
 
 // VerifyAdminKey 验证管理员密钥
 func (c *AdminClient) VerifyAdminKey() error {
-	req := map[string]string{"admin_key": c.apiKey}
-	_, err := c.request("POST", "/api/v1/admin/verify", req)
+	_, err := c.request("GET", "/api/v1/admin/me", nil)
 	return err
 }
 
@@ -117,7 +119,7 @@ func (c *AdminClient) DeleteUser(userID uint) error {
 
 // GetUserDevices 获取用户设备列表
 func (c *AdminClient) GetUserDevices(username string) ([]Device, error) {
-	path := fmt.Sprintf("/api/v1/admin/users/%s/devices", username)
+	path := fmt.Sprintf("/api/v1/admin/users/%s/devices", url.PathEscape(username))
 	resp, err := c.request("GET", path, nil)
 	if err != nil {
 		return nil, err
@@ -154,6 +156,9 @@ func (c *AdminClient) GetDevices(page, pageSize int, filter *request.DeviceFilte
 		if filter.Name != "" {
 			params.Set("name", filter.Name)
 		}
+		if filter.DeviceGroupID != nil {
+			params.Set("device_group_id", strconv.FormatUint(uint64(*filter.DeviceGroupID), 10))
+		}
 		if filter.OnlineOnly {
 			params.Set("online_only", "true")
 		}
@@ -229,6 +234,13 @@ func (c *AdminClient) OfflineDevice(deviceID uint) (*Device, error) {
 	return &device, nil
 }
 
+// DeleteDevice 删除设备
+func (c *AdminClient) DeleteDevice(deviceID uint) error {
+	path := fmt.Sprintf("/api/v1/admin/devices/%d", deviceID)
+	_, err := c.request("DELETE", path, nil)
+	return err
+}
+
 // GetDeviceStatistics 获取设备统计信息
 func (c *AdminClient) GetDeviceStatistics() (*response.DeviceStatistics, error) {
 	resp, err := c.request("GET", "/api/v1/admin/devices/statistics", nil)
@@ -291,6 +303,29 @@ func (c *AdminClient) UpdateDeviceGroup(groupID uint, req *request.UpdateDeviceG
 	return &deviceGroup, nil
 }
 
+// LinkDeviceGroupUser 将设备组关联到用户，userID 为空时取消关联
+func (c *AdminClient) LinkDeviceGroupUser(groupID uint, userID *uint) error {
+	path := fmt.Sprintf("/api/v1/admin/device-groups/%d/user", groupID)
+	_, err := c.request("PUT", path, &request.LinkDeviceGroupUserRequest{UserID: userID})
+	return err
+}
+
+// RekeyDeviceGroup 重置被隔离设备组的密钥，返回仅此一次下发的新恢复码
+func (c *AdminClient) RekeyDeviceGroup(groupID uint) ([]string, error) {
+	path := fmt.Sprintf("/api/v1/admin/device-groups/%d/rekey", groupID)
+	resp, err := c.request("POST", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result response.RekeyDeviceGroupResponse
+	if err := mapToStruct(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return result.RecoveryCodes, nil
+}
+
 // GetPendingActivationDevices 获取待激活设备列表
 func (c *AdminClient) GetPendingActivationDevices() ([]Device, error) {
 	resp, err := c.request("GET", "/api/v1/admin/devices/pending-activation", nil)
@@ -352,3 +387,404 @@ func (c *AdminClient) GetAPIKeys(page, pageSize int) ([]APIKey, int64, error) {
 
 	return apiKeys, total, nil
 }
+
+// DeleteAPIKey 删除API密钥
+func (c *AdminClient) DeleteAPIKey(apiKeyID uint) error {
+	path := fmt.Sprintf("/api/v1/admin/apikeys/%d", apiKeyID)
+	_, err := c.request("DELETE", path, nil)
+	return err
+}
+
+// GetSecurityEvents 获取安全事件列表
+func (c *AdminClient) GetSecurityEvents(page, pageSize int, filter *request.SecurityEventFilter) ([]SecurityEvent, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(page))
+	params.Set("page_size", strconv.Itoa(pageSize))
+
+	if filter != nil {
+		if filter.Type != "" {
+			params.Set("type", filter.Type)
+		}
+		if filter.DeviceGroupID != nil {
+			params.Set("device_group_id", strconv.FormatUint(uint64(*filter.DeviceGroupID), 10))
+		}
+	}
+
+	path := "/api/v1/admin/security-events?" + params.Encode()
+	resp, err := c.request("GET", path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var events []SecurityEvent
+	if err := mapToStruct(resp.Data, &events); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	total := int64(0)
+	if resp.Total != nil {
+		total = *resp.Total
+	}
+
+	return events, total, nil
+}
+
+// GetAuthSessions 获取认证会话列表
+func (c *AdminClient) GetAuthSessions(page, pageSize int, filter *request.AuthSessionFilter) ([]AuthSession, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	params := authSessionFilterParams(filter)
+	params.Set("page", strconv.Itoa(page))
+	params.Set("page_size", strconv.Itoa(pageSize))
+
+	path := "/api/v1/admin/sessions?" + params.Encode()
+	resp, err := c.request("GET", path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var sessions []AuthSession
+	if err := mapToStruct(resp.Data, &sessions); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	total := int64(0)
+	if resp.Total != nil {
+		total = *resp.Total
+	}
+
+	return sessions, total, nil
+}
+
+// ExportAuthSessions 导出全部匹配的认证会话并写入 w，format 为 csv 或 jsonl
+// 导出数据量较大时可先通过 SetTimeout 延长请求超时
+func (c *AdminClient) ExportAuthSessions(format string, filter *request.AuthSessionFilter, w io.Writer) error {
+	params := authSessionFilterParams(filter)
+	params.Set("format", format)
+
+	return c.download("/api/v1/admin/sessions?"+params.Encode(), w)
+}
+
+// authSessionFilterParams 将认证会话过滤条件转换为查询参数
+func authSessionFilterParams(filter *request.AuthSessionFilter) url.Values {
+	params := url.Values{}
+	if filter == nil {
+		return params
+	}
+
+	for name, value := range map[string]string{
+		"status":     filter.Status,
+		"username":   filter.Username,
+		"action":     filter.Action,
+		"client_ip":  filter.ClientIP,
+		"search":     filter.Search,
+		"sort_by":    filter.SortBy,
+		"sort_order": filter.SortOrder,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	for name, value := range map[string]*uint{
+		"api_key_id":      filter.APIKeyID,
+		"device_id":       filter.DeviceID,
+		"device_group_id": filter.DeviceGroupID,
+	} {
+		if value != nil {
+			params.Set(name, strconv.FormatUint(uint64(*value), 10))
+		}
+	}
+	if filter.StartTime != nil {
+		params.Set("start_time", filter.StartTime.Format(time.RFC3339))
+	}
+	if filter.EndTime != nil {
+		params.Set("end_time", filter.EndTime.Format(time.RFC3339))
+	}
+
+	return params
+}
+
+// GetNotificationChannels 获取服务端配置的通知渠道
+func (c *AdminClient) GetNotificationChannels() ([]NotificationChannel, error) {
+	resp, err := c.request("GET", "/api/v1/admin/notifications/channels", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []NotificationChannel
+	if err := mapToStruct(resp.Data, &channels); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return channels, nil
+}
+
+// TestNotificationChannel 向通知渠道发送测试通知
+func (c *AdminClient) TestNotificationChannel(name string) error {
+	path := fmt.Sprintf("/api/v1/admin/notifications/channels/%s/test", url.PathEscape(name))
+	_, err := c.request("POST", path, nil)
+	return err
+}
+
+// GetNotificationSubscriptions 获取通知订阅列表，包括配置文件中的订阅
+func (c *AdminClient) GetNotificationSubscriptions() ([]NotificationSubscription, error) {
+	resp, err := c.request("GET", "/api/v1/admin/notifications/subscriptions", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []NotificationSubscription
+	if err := mapToStruct(resp.Data, &subs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return subs, nil
+}
+
+// CreateNotificationSubscription 添加通知订阅
+func (c *AdminClient) CreateNotificationSubscription(req *request.NotificationSubscriptionRequest) (*NotificationSubscription, error) {
+	resp, err := c.request("POST", "/api/v1/admin/notifications/subscriptions", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var sub NotificationSubscription
+	if err := mapToStruct(resp.Data, &sub); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return &sub, nil
+}
+
+// UpdateNotificationSubscription 更新管理后台添加的通知订阅
+func (c *AdminClient) UpdateNotificationSubscription(subID uint, req *request.NotificationSubscriptionRequest) (*NotificationSubscription, error) {
+	path := fmt.Sprintf("/api/v1/admin/notifications/subscriptions/%d", subID)
+	resp, err := c.request("PUT", path, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var sub NotificationSubscription
+	if err := mapToStruct(resp.Data, &sub); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return &sub, nil
+}
+
+// DeleteNotificationSubscription 删除管理后台添加的通知订阅
+func (c *AdminClient) DeleteNotificationSubscription(subID uint) error {
+	path := fmt.Sprintf("/api/v1/admin/notifications/subscriptions/%d", subID)
+	_, err := c.request("DELETE", path, nil)
+	return err
+}
+
+// GetAdminAccounts 获取管理员账号列表
+func (c *AdminClient) GetAdminAccounts() ([]AdminAccount, error) {
+	resp, err := c.request("GET", "/api/v1/admin/accounts", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []AdminAccount
+	if err := mapToStruct(resp.Data, &accounts); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return accounts, nil
+}
+
+// CreateAdminAccount 将用户设为管理员，用户需通过U盘确认登录管理后台
+func (c *AdminClient) CreateAdminAccount(req *request.CreateAdminAccountRequest) (*AdminAccount, error) {
+	resp, err := c.request("POST", "/api/v1/admin/accounts", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var account AdminAccount
+	if err := mapToStruct(resp.Data, &account); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return &account, nil
+}
+
+// UpdateAdminAccount 启用或停用管理员账号
+func (c *AdminClient) UpdateAdminAccount(accountID uint, req *request.UpdateAdminAccountRequest) (*AdminAccount, error) {
+	path := fmt.Sprintf("/api/v1/admin/accounts/%d", accountID)
+	resp, err := c.request("PUT", path, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var account AdminAccount
+	if err := mapToStruct(resp.Data, &account); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return &account, nil
+}
+
+// DeleteAdminAccount 删除管理员账号
+func (c *AdminClient) DeleteAdminAccount(accountID uint) error {
+	path := fmt.Sprintf("/api/v1/admin/accounts/%d", accountID)
+	_, err := c.request("DELETE", path, nil)
+	return err
+}
+
+// GetAdminAuditLogs 获取管理操作审计日志，adminAccountID 为空时返回全部记录
+func (c *AdminClient) GetAdminAuditLogs(page, pageSize int, adminAccountID *uint) ([]AdminAuditLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(page))
+	params.Set("page_size", strconv.Itoa(pageSize))
+	if adminAccountID != nil {
+		params.Set("admin_account_id", strconv.FormatUint(uint64(*adminAccountID), 10))
+	}
+
+	path := "/api/v1/admin/audit-logs?" + params.Encode()
+	resp, err := c.request("GET", path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var logs []AdminAuditLog
+	if err := mapToStruct(resp.Data, &logs); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	total := int64(0)
+	if resp.Total != nil {
+		total = *resp.Total
+	}
+
+	return logs, total, nil
+}
+
+// GetOIDCClients 获取OIDC客户端列表
+func (c *AdminClient) GetOIDCClients() ([]OIDCClient, error) {
+	resp, err := c.request("GET", "/api/v1/admin/oidc-clients", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []OIDCClient
+	if err := mapToStruct(resp.Data, &clients); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return clients, nil
+}
+
+// CreateOIDCClient 注册OIDC客户端
+func (c *AdminClient) CreateOIDCClient(req *request.CreateOIDCClientRequest) (*OIDCClient, error) {
+	resp, err := c.request("POST", "/api/v1/admin/oidc-clients", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var client OIDCClient
+	if err := mapToStruct(resp.Data, &client); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return &client, nil
+}
+
+// UpdateOIDCClient 更新OIDC客户端
+func (c *AdminClient) UpdateOIDCClient(clientID uint, req *request.UpdateOIDCClientRequest) (*OIDCClient, error) {
+	path := fmt.Sprintf("/api/v1/admin/oidc-clients/%d", clientID)
+	resp, err := c.request("PUT", path, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var client OIDCClient
+	if err := mapToStruct(resp.Data, &client); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return &client, nil
+}
+
+// DeleteOIDCClient 删除OIDC客户端
+func (c *AdminClient) DeleteOIDCClient(clientID uint) error {
+	path := fmt.Sprintf("/api/v1/admin/oidc-clients/%d", clientID)
+	_, err := c.request("DELETE", path, nil)
+	return err
+}
+
+// ImportUsers 批量导入用户，format 为 csv 或 json；dryRun 为 true 时只校验不写入
+// 存在行级校验错误时同时返回导入结果和错误，可从结果的 Errors 查看各行错误
+func (c *AdminClient) ImportUsers(format string, data io.Reader, dryRun bool) (*response.BulkImportResult, error) {
+	return c.bulkImport("/api/v1/admin/bulk/users", format, data, dryRun)
+}
+
+// ExportUsers 导出全部用户并写入 w，format 为 csv 或 json，导出结果可直接用于 ImportUsers
+func (c *AdminClient) ExportUsers(format string, w io.Writer) error {
+	return c.download("/api/v1/admin/bulk/users?format="+url.QueryEscape(format), w)
+}
+
+// ImportDeviceGroups 批量导入设备组的用户关联、名称和权限，用户需已存在
+func (c *AdminClient) ImportDeviceGroups(format string, data io.Reader, dryRun bool) (*response.BulkImportResult, error) {
+	return c.bulkImport("/api/v1/admin/bulk/device-groups", format, data, dryRun)
+}
+
+// ExportDeviceGroups 导出全部设备组的用户关联、名称和权限并写入 w
+func (c *AdminClient) ExportDeviceGroups(format string, w io.Writer) error {
+	return c.download("/api/v1/admin/bulk/device-groups?format="+url.QueryEscape(format), w)
+}
+
+// bulkImport 上传批量导入数据
+func (c *AdminClient) bulkImport(path, format string, data io.Reader, dryRun bool) (*response.BulkImportResult, error) {
+	params := url.Values{}
+	params.Set("format", format)
+	params.Set("dry_run", strconv.FormatBool(dryRun))
+
+	contentType := "application/json"
+	if format == "csv" {
+		contentType = "text/csv"
+	}
+
+	resp, err := c.requestRaw("POST", path+"?"+params.Encode(), contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result response.Response
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrResponseParseFailed, err)
+	}
+
+	var importResult *response.BulkImportResult
+	if result.Data != nil {
+		importResult = &response.BulkImportResult{}
+		if err := mapToStruct(result.Data, importResult); err != nil {
+			return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+		}
+	}
+
+	if !result.Success {
+		return importResult, fmt.Errorf("%w: %s", errs.ErrAPIError, result.Message)
+	}
+	return importResult, nil
+}
@@ -84,6 +84,49 @@ func (c *APIClient) request(method, path string, body interface{}) (*response.Re
 	return &result, nil
 }
 
+// requestRaw 发送原始请求体的HTTP请求，用于导入导出等非JSON数据，调用方负责关闭响应体
+func (c *APIClient) requestRaw(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrRequestCreationFailed, err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrRequestFailed, err)
+	}
+	return resp, nil
+}
+
+// download 下载导出文件并写入 w
+func (c *APIClient) download(path string, w io.Writer) error {
+	resp, err := c.requestRaw("GET", path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result response.Response
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("%w: HTTP %d", errs.ErrAPIError, resp.StatusCode)
+		}
+		return fmt.Errorf("%w: %s", errs.ErrAPIError, result.Message)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrRequestFailed, err)
+	}
+	return nil
+}
+
 // StartAuth 发起用户认证
 func (c *APIClient) StartAuth(req *request.AuthRequest) (*response.AuthData, error) {
 	resp, err := c.request("POST", "/api/v1/auth", req)
@@ -129,6 +172,43 @@ func (c *APIClient) Health() (map[string]string, error) {
 	return data, nil
 }
 
+// CreateEventSubscription 为当前API密钥注册事件订阅，事件使用该API密钥签名
+func (c *APIClient) CreateEventSubscription(req *request.EventSubscriptionRequest) (*EventSubscription, error) {
+	resp, err := c.request("POST", "/api/v1/event-subscriptions", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var sub EventSubscription
+	if err := mapToStruct(resp.Data, &sub); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return &sub, nil
+}
+
+// GetEventSubscriptions 获取当前API密钥的事件订阅列表
+func (c *APIClient) GetEventSubscriptions() ([]EventSubscription, error) {
+	resp, err := c.request("GET", "/api/v1/event-subscriptions", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []EventSubscription
+	if err := mapToStruct(resp.Data, &subs); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrDataParseFailed, err)
+	}
+
+	return subs, nil
+}
+
+// DeleteEventSubscription 删除当前API密钥的事件订阅
+func (c *APIClient) DeleteEventSubscription(subID uint) error {
+	path := fmt.Sprintf("/api/v1/event-subscriptions/%d", subID)
+	_, err := c.request("DELETE", path, nil)
+	return err
+}
+
 // 数据类型转换辅助函数
 func mapToStruct(data interface{}, target interface{}) error {
 	jsonData, err := json.Marshal(data)
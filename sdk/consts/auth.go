@@ -11,6 +11,12 @@ const (
 	AuthStatusRejected          = "rejected"           // 被拒绝
 )
 
+// 内置认证操作常量，设备组需具备相应权限才能响应
+const (
+	AuthActionAdminLogin  = "admin:login"  // 登录管理后台
+	AuthActionRADIUSLogin = "radius:login" // 通过RADIUS登录VPN或网络设备
+)
+
 // 认证结果常量
 const (
 	AuthResultSuccess = "success" // 成功
@@ -0,0 +1,23 @@
+package consts
+
+// 集成方事件类型常量
+const (
+	EventDeviceOnline      = "device.online"      // 设备上线
+	EventDeviceOffline     = "device.offline"     // 设备下线
+	EventDeviceActivated   = "device.activated"   // 设备被管理员激活
+	EventDeviceDeactivated = "device.deactivated" // 设备被管理员停用
+	EventDeviceRevoked     = "device.revoked"     // 设备被删除或因重置密钥被吊销
+	EventDeviceLinked      = "device.linked"      // 设备所在设备组关联到用户
+	EventDeviceUnlinked    = "device.unlinked"    // 设备所在设备组取消关联用户
+)
+
+// EventTypes 全部事件类型
+var EventTypes = []string{
+	EventDeviceOnline,
+	EventDeviceOffline,
+	EventDeviceActivated,
+	EventDeviceDeactivated,
+	EventDeviceRevoked,
+	EventDeviceLinked,
+	EventDeviceUnlinked,
+}
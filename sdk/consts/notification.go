@@ -0,0 +1,17 @@
+package consts
+
+// 管理员通知类别常量
+const (
+	NotifyDevicePendingActivation = "device_pending_activation" // 新设备或指纹变化的设备等待管理员激活
+	NotifyCloneDetected           = "clone_detected"            // 检测到U盘被克隆，设备组已隔离
+	NotifyAuthFailures            = "auth_failures"             // 同一用户短时间内多次认证失败
+	NotifyAPIKeyExpiring          = "apikey_expiring"           // API密钥即将过期
+)
+
+// NotifyCategories 全部通知类别
+var NotifyCategories = []string{
+	NotifyDevicePendingActivation,
+	NotifyCloneDetected,
+	NotifyAuthFailures,
+	NotifyAPIKeyExpiring,
+}
@@ -0,0 +1,9 @@
+package consts
+
+// 安全事件类型常量
+const (
+	SecurityEventOnceKeyReplay     = "once_key_replay"    // 出示了已轮换掉的旧OnceKey
+	SecurityEventConcurrentSerials = "concurrent_serials" // 同一设备组同时从不同序列号的设备连接
+	SecurityEventOnceKeyMismatch   = "once_key_mismatch"  // 现有设备连接时未出示或出示了不属于设备组的OnceKey
+	SecurityEventGroupRekeyed      = "group_rekeyed"      // 管理员重置设备组密钥
+)
@@ -36,6 +36,12 @@ var (
 	ErrInvalidSignature         = errors.New("签名无效")
 	ErrCallbackValidationFailed = errors.New("回调验证失败")
 	ErrUnknownCallbackStatus    = errors.New("未知回调状态")
+
+	// 事件错误
+	ErrMissingEventID        = errors.New("缺少事件ID")
+	ErrMissingEventType      = errors.New("缺少事件类型")
+	ErrEventValidationFailed = errors.New("事件验证失败")
+	ErrUnknownEventType      = errors.New("未知事件类型")
 )
 
 // HTTPStatusMap 定义错误对应的HTTP状态码
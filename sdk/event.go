@@ -0,0 +1,131 @@
+package sdk
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/errs"
+	"github.com/hang666/EasyUKey/sdk/request"
+)
+
+// ParseEvent 解析并验证事件推送，secret 为注册订阅时使用的API密钥
+func ParseEvent(data []byte, secret string) (*request.EventRequest, error) {
+	var req request.EventRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidJSON, err)
+	}
+
+	// 验证必需字段
+	if req.EventID == "" {
+		return nil, errs.ErrMissingEventID
+	}
+	if req.Type == "" {
+		return nil, errs.ErrMissingEventType
+	}
+	if req.Timestamp == 0 {
+		return nil, errs.ErrMissingTimestamp
+	}
+	if req.Signature == "" {
+		return nil, errs.ErrMissingSignature
+	}
+
+	// 验证签名
+	if !hmac.Equal([]byte(req.Signature), []byte(generateEventSignature(&req, secret))) {
+		return nil, errs.ErrInvalidSignature
+	}
+
+	// 验证时间戳（防重放攻击）
+	now := time.Now().Unix()
+	if req.Timestamp < now-300 || req.Timestamp > now+300 { // 5分钟窗口
+		return nil, errs.ErrTimestampOutOfRange
+	}
+
+	return &req, nil
+}
+
+// generateEventSignature 生成事件推送签名
+func generateEventSignature(req *request.EventRequest, secret string) string {
+	// 构建签名字符串
+	data := map[string]string{
+		"event_id":        req.EventID,
+		"type":            req.Type,
+		"device_id":       fmt.Sprintf("%d", req.DeviceID),
+		"device_group_id": fmt.Sprintf("%d", req.DeviceGroupID),
+		"user_id":         fmt.Sprintf("%d", req.UserID),
+		"username":        req.Username,
+		"timestamp":       fmt.Sprintf("%d", req.Timestamp),
+	}
+
+	// 按字母顺序排序键值对
+	var keys []string
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, k+"="+data[k])
+	}
+
+	// 计算HMAC-SHA256
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strings.Join(parts, "&")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// EventHandler 事件处理器接口，只关心部分事件时可嵌入 NopEventHandler
+type EventHandler interface {
+	OnDeviceOnline(event *request.EventRequest) error
+	OnDeviceOffline(event *request.EventRequest) error
+	OnDeviceActivated(event *request.EventRequest) error
+	OnDeviceDeactivated(event *request.EventRequest) error
+	OnDeviceRevoked(event *request.EventRequest) error
+	OnDeviceLinked(event *request.EventRequest) error
+	OnDeviceUnlinked(event *request.EventRequest) error
+}
+
+// NopEventHandler 忽略全部事件的处理器
+type NopEventHandler struct{}
+
+func (NopEventHandler) OnDeviceOnline(*request.EventRequest) error      { return nil }
+func (NopEventHandler) OnDeviceOffline(*request.EventRequest) error     { return nil }
+func (NopEventHandler) OnDeviceActivated(*request.EventRequest) error   { return nil }
+func (NopEventHandler) OnDeviceDeactivated(*request.EventRequest) error { return nil }
+func (NopEventHandler) OnDeviceRevoked(*request.EventRequest) error     { return nil }
+func (NopEventHandler) OnDeviceLinked(*request.EventRequest) error      { return nil }
+func (NopEventHandler) OnDeviceUnlinked(*request.EventRequest) error    { return nil }
+
+// HandleEvent 验证事件推送并分发给处理器
+func HandleEvent(data []byte, secret string, handler EventHandler) error {
+	event, err := ParseEvent(data, secret)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrEventValidationFailed, err)
+	}
+
+	switch event.Type {
+	case consts.EventDeviceOnline:
+		return handler.OnDeviceOnline(event)
+	case consts.EventDeviceOffline:
+		return handler.OnDeviceOffline(event)
+	case consts.EventDeviceActivated:
+		return handler.OnDeviceActivated(event)
+	case consts.EventDeviceDeactivated:
+		return handler.OnDeviceDeactivated(event)
+	case consts.EventDeviceRevoked:
+		return handler.OnDeviceRevoked(event)
+	case consts.EventDeviceLinked:
+		return handler.OnDeviceLinked(event)
+	case consts.EventDeviceUnlinked:
+		return handler.OnDeviceUnlinked(event)
+	default:
+		return fmt.Errorf("%w: %s", errs.ErrUnknownEventType, event.Type)
+	}
+}
@@ -1,5 +1,7 @@
 package request
 
+import "time"
+
 // AuthRequest 认证请求
 type AuthRequest struct {
 	Username    string `json:"username"`
@@ -22,6 +24,18 @@ type CallbackRequest struct {
 	Signature string `json:"signature"`
 }
 
+// EventRequest 事件推送数据结构
+type EventRequest struct {
+	EventID       string `json:"event_id"`
+	Type          string `json:"type"`
+	DeviceID      uint   `json:"device_id"`
+	DeviceGroupID uint   `json:"device_group_id"`
+	UserID        uint   `json:"user_id"`
+	Username      string `json:"username"`
+	Timestamp     int64  `json:"timestamp"`
+	Signature     string `json:"signature"`
+}
+
 // VerifyAuthRequest 验证认证请求
 type VerifyAuthRequest struct {
 	SessionID string `json:"session_id"`
@@ -76,3 +90,86 @@ type UpdateDeviceGroupRequest struct {
 type LinkDeviceGroupUserRequest struct {
 	UserID *uint `json:"user_id"` // null表示取消关联
 }
+
+// SecurityEventFilter 安全事件过滤条件
+type SecurityEventFilter struct {
+	Type          string `json:"type,omitempty"`
+	DeviceGroupID *uint  `json:"device_group_id,omitempty"`
+}
+
+// AuthSessionFilter 认证会话过滤条件
+type AuthSessionFilter struct {
+	Status        string     `json:"status,omitempty"`
+	Username      string     `json:"username,omitempty"` // 按用户名模糊匹配
+	Action        string     `json:"action,omitempty"`
+	APIKeyID      *uint      `json:"api_key_id,omitempty"`
+	DeviceID      *uint      `json:"device_id,omitempty"`       // 响应认证的设备ID
+	DeviceGroupID *uint      `json:"device_group_id,omitempty"` // 响应认证的设备所属设备组
+	ClientIP      string     `json:"client_ip,omitempty"`
+	StartTime     *time.Time `json:"start_time,omitempty"` // 创建时间不早于
+	EndTime       *time.Time `json:"end_time,omitempty"`   // 创建时间早于
+	Search        string     `json:"search,omitempty"`     // 在挑战码和认证说明中搜索
+	SortBy        string     `json:"sort_by,omitempty"`    // created_at, updated_at, expires_at, status, action，默认 created_at
+	SortOrder     string     `json:"sort_order,omitempty"` // asc 或 desc，默认 desc
+}
+
+// BulkUserRecord 批量导入导出的用户记录，按用户名匹配已有用户
+type BulkUserRecord struct {
+	Username    string   `json:"username"`
+	Permissions []string `json:"permissions"`         // 为 null 时已有用户的权限保持不变
+	IsActive    *bool    `json:"is_active,omitempty"` // 为空时新用户默认启用，已有用户保持不变
+}
+
+// BulkDeviceGroupRecord 批量导入导出的设备组分配记录，按设备组ID匹配
+type BulkDeviceGroupRecord struct {
+	DeviceGroupID uint     `json:"device_group_id"`
+	Name          string   `json:"name,omitempty"` // 为空时保持不变
+	Username      string   `json:"username"`       // 关联的用户，为空表示取消关联
+	Permissions   []string `json:"permissions"`    // 为 null 时保持不变
+}
+
+// NotificationSubscriptionRequest 创建或更新通知订阅请求
+type NotificationSubscriptionRequest struct {
+	Channel               string   `json:"channel"`                 // 渠道名称，需在服务端配置中定义
+	Categories            []string `json:"categories,omitempty"`    // 订阅的通知类别，为空表示全部类别
+	ThrottleSeconds       int      `json:"throttle_seconds"`        // 同一类别两次即时发送的最小间隔（秒），0表示不节流
+	DigestIntervalSeconds int      `json:"digest_interval_seconds"` // 大于0时按该间隔（秒）汇总发送
+	IsActive              *bool    `json:"is_active,omitempty"`
+}
+
+// EventSubscriptionRequest 创建事件订阅请求
+type EventSubscriptionRequest struct {
+	URL        string   `json:"url"`                   // 接收事件的地址
+	EventTypes []string `json:"event_types,omitempty"` // 订阅的事件类型，为空表示全部类型
+}
+
+// AdminLoginRequest 管理后台登录请求
+type AdminLoginRequest struct {
+	Username string `json:"username"`
+}
+
+// CreateAdminAccountRequest 创建管理员账号请求
+type CreateAdminAccountRequest struct {
+	UserID uint `json:"user_id"`
+}
+
+// UpdateAdminAccountRequest 更新管理员账号请求
+type UpdateAdminAccountRequest struct {
+	IsActive *bool `json:"is_active,omitempty"`
+}
+
+// CreateOIDCClientRequest 注册OIDC客户端请求
+type CreateOIDCClientRequest struct {
+	Name         string   `json:"name"`
+	APIKeyID     uint     `json:"api_key_id"`       // 关联的API密钥，其值作为 client_secret
+	RedirectURIs []string `json:"redirect_uris"`    // 允许的回调地址
+	Action       string   `json:"action,omitempty"` // 登录时请求的操作，为空表示不限制
+}
+
+// UpdateOIDCClientRequest 更新OIDC客户端请求，未设置的字段保持不变
+type UpdateOIDCClientRequest struct {
+	Name         string   `json:"name,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	Action       *string  `json:"action,omitempty"`
+	IsActive     *bool    `json:"is_active,omitempty"`
+}
@@ -37,16 +37,19 @@ type DeviceStatistics struct {
 
 // DeviceGroupResponse 设备组响应结构（排除敏感字段）
 type DeviceGroupResponse struct {
-	ID          uint             `json:"id"`
-	UserID      *uint            `json:"user_id"`
-	Name        string           `json:"name"`
-	Description string           `json:"description"`
-	Permissions []string         `json:"permissions"`
-	IsActive    bool             `json:"is_active"`
-	CreatedAt   time.Time        `json:"created_at"`
-	UpdatedAt   time.Time        `json:"updated_at"`
-	User        *UserResponse    `json:"user,omitempty"`
-	Devices     []DeviceResponse `json:"devices,omitempty"`
+	ID               uint             `json:"id"`
+	UserID           *uint            `json:"user_id"`
+	Name             string           `json:"name"`
+	Description      string           `json:"description"`
+	Permissions      []string         `json:"permissions"`
+	IsActive         bool             `json:"is_active"`
+	Quarantined      bool             `json:"quarantined"`       // 疑似被克隆已被隔离
+	QuarantinedAt    *time.Time       `json:"quarantined_at"`    // 隔离时间
+	QuarantineReason string           `json:"quarantine_reason"` // 隔离原因
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	User             *UserResponse    `json:"user,omitempty"`
+	Devices          []DeviceResponse `json:"devices,omitempty"`
 }
 
 // DeviceResponse 设备响应结构（排除敏感字段）
@@ -65,6 +68,8 @@ type DeviceResponse struct {
 	LastOnlineAt       *time.Time           `json:"last_online_at"`
 	LastOfflineAt      *time.Time           `json:"last_offline_at"`
 	HeartbeatInterval  int                  `json:"heartbeat_interval"`
+	FingerprintScore   int                  `json:"fingerprint_score"`   // 最近一次连接的硬件指纹相似度（0-100）
+	FingerprintPending bool                 `json:"fingerprint_pending"` // 硬件指纹发生变化，等待管理员重新激活
 	CreatedAt          time.Time            `json:"created_at"`
 	UpdatedAt          time.Time            `json:"updated_at"`
 	DeviceGroup        *DeviceGroupResponse `json:"device_group,omitempty"`
@@ -78,3 +83,108 @@ type UserResponse struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// RekeyDeviceGroupResponse 重置设备组密钥响应
+type RekeyDeviceGroupResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // 新的恢复码，仅此一次返回，设备持有人需用其重新初始化U盘
+}
+
+// NotificationChannelResponse 通知渠道响应结构（不包含地址和凭据）
+type NotificationChannelResponse struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // webhook 或 smtp
+}
+
+// NotificationSubscriptionResponse 通知订阅响应结构
+type NotificationSubscriptionResponse struct {
+	ID                    uint      `json:"id"`     // 配置文件中定义的订阅为0
+	Source                string    `json:"source"` // config: 配置文件定义，只读；admin: 管理后台添加
+	Channel               string    `json:"channel"`
+	Categories            []string  `json:"categories"`
+	ThrottleSeconds       int       `json:"throttle_seconds"`
+	DigestIntervalSeconds int       `json:"digest_interval_seconds"`
+	IsActive              bool      `json:"is_active"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// EventSubscriptionResponse 事件订阅响应结构
+type EventSubscriptionResponse struct {
+	ID         uint      `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AdminAccountResponse 管理员账号响应结构
+type AdminAccountResponse struct {
+	ID          uint       `json:"id"`
+	UserID      uint       `json:"user_id"`
+	Username    string     `json:"username"`
+	IsActive    bool       `json:"is_active"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AdminLoginResponse 发起管理后台登录响应
+type AdminLoginResponse struct {
+	SessionID string    `json:"session_id"` // 下发到U盘的认证会话ID，可与客户端显示的请求核对
+	ExpiresAt time.Time `json:"expires_at"` // 等待U盘确认的截止时间
+}
+
+// AdminLoginStatusResponse 管理后台登录状态响应
+type AdminLoginStatusResponse struct {
+	Status string                `json:"status"`          // 认证状态，completed 表示已登录
+	Admin  *AdminAccountResponse `json:"admin,omitempty"` // 登录成功后的管理员账号
+}
+
+// OIDCLoginResponse OIDC登录页发起U盘确认的响应
+type OIDCLoginResponse struct {
+	LoginID   string    `json:"login_id"`   // 查询登录进度使用的令牌
+	SessionID string    `json:"session_id"` // 下发到U盘的认证会话ID
+	ExpiresAt time.Time `json:"expires_at"` // 等待U盘确认的截止时间
+}
+
+// OIDCLoginStatusResponse OIDC登录进度响应
+type OIDCLoginStatusResponse struct {
+	Status      string `json:"status"`                 // 认证状态，pending 表示仍在等待U盘确认
+	RedirectURL string `json:"redirect_url,omitempty"` // 登录结束后浏览器需跳转的回调地址
+}
+
+// AuthSessionRecord 认证会话导出记录（JSON Lines 每行一条，CSV 列顺序与字段顺序一致）
+type AuthSessionRecord struct {
+	ID                 string    `json:"id"`
+	Status             string    `json:"status"`
+	Result             string    `json:"result"`
+	Action             string    `json:"action"`
+	Message            string    `json:"message"`
+	Challenge          string    `json:"challenge"`
+	UserID             uint      `json:"user_id"`
+	Username           string    `json:"username"`
+	APIKeyID           *uint     `json:"api_key_id"`
+	APIKeyName         string    `json:"api_key_name"`
+	RespondingDeviceID *uint     `json:"responding_device_id"`
+	DeviceSerialNumber string    `json:"device_serial_number"`
+	DeviceGroupID      *uint     `json:"device_group_id"`
+	ClientIP           string    `json:"client_ip"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+// BulkImportResult 批量导入结果，存在行级错误时不写入任何数据
+type BulkImportResult struct {
+	DryRun    bool           `json:"dry_run"`
+	Total     int            `json:"total"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Errors    []BulkRowError `json:"errors,omitempty"`
+}
+
+// BulkRowError 批量导入的行级错误
+type BulkRowError struct {
+	Row     int    `json:"row"` // 数据行号，从1开始，不含CSV表头
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
@@ -32,16 +32,68 @@ type Device struct {
 
 // DeviceGroup 设备组信息
 type DeviceGroup struct {
-	ID          uint      `json:"id"`
-	UserID      *uint     `json:"user_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Permissions []string  `json:"permissions"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	User        *User     `json:"user,omitempty"`
-	Devices     []Device  `json:"devices,omitempty"`
+	ID               uint       `json:"id"`
+	UserID           *uint      `json:"user_id"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Permissions      []string   `json:"permissions"`
+	IsActive         bool       `json:"is_active"`
+	Quarantined      bool       `json:"quarantined"`
+	QuarantinedAt    *time.Time `json:"quarantined_at"`
+	QuarantineReason string     `json:"quarantine_reason"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	User             *User      `json:"user,omitempty"`
+	Devices          []Device   `json:"devices,omitempty"`
+}
+
+// AuthSession 认证会话
+type AuthSession struct {
+	ID                 string    `json:"id"`
+	UserID             uint      `json:"user_id"`
+	APIKeyID           *uint     `json:"api_key_id"`
+	RespondingDeviceID *uint     `json:"responding_device_id"`
+	Challenge          string    `json:"challenge"`
+	Action             string    `json:"action"`
+	Message            string    `json:"message"`
+	Status             string    `json:"status"`
+	Result             string    `json:"result"`
+	ClientIP           string    `json:"client_ip"`
+	CreatedAt          time.Time `json:"created_at"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	User               *User     `json:"user,omitempty"`
+	RespondingDevice   *Device   `json:"responding_device,omitempty"`
+}
+
+// SecurityEvent 安全事件
+type SecurityEvent struct {
+	ID            uint      `json:"id"`
+	Type          string    `json:"type"`
+	DeviceGroupID *uint     `json:"device_group_id"`
+	DeviceID      *uint     `json:"device_id"`
+	SerialNumber  string    `json:"serial_number"`
+	Detail        string    `json:"detail"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NotificationChannel 通知渠道
+type NotificationChannel struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// NotificationSubscription 通知订阅
+type NotificationSubscription struct {
+	ID                    uint      `json:"id"`
+	Source                string    `json:"source"`
+	Channel               string    `json:"channel"`
+	Categories            []string  `json:"categories"`
+	ThrottleSeconds       int       `json:"throttle_seconds"`
+	DigestIntervalSeconds int       `json:"digest_interval_seconds"`
+	IsActive              bool      `json:"is_active"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 // APIKey API密钥信息
@@ -49,8 +101,54 @@ type APIKey struct {
 	ID          uint      `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
-	Key         string    `json:"key"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	Key         string    `json:"api_key"`
+	IsActive    bool      `json:"is_active"`
+	IsAdmin     bool      `json:"is_admin"`
+	ExpiresAt   time.Time `json:"expires_at"` // 为零值表示不过期
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// EventSubscription 事件订阅
+type EventSubscription struct {
+	ID         uint      `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OIDCClient OIDC客户端，client_secret 为关联API密钥的值
+type OIDCClient struct {
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	ClientID     string    `json:"client_id"`
+	APIKeyID     uint      `json:"api_key_id"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Action       string    `json:"action"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AdminAccount 管理员账号
+type AdminAccount struct {
+	ID          uint       `json:"id"`
+	UserID      uint       `json:"user_id"`
+	Username    string     `json:"username"`
+	IsActive    bool       `json:"is_active"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AdminAuditLog 管理操作审计日志
+type AdminAuditLog struct {
+	ID             uint      `json:"id"`
+	AdminAccountID *uint     `json:"admin_account_id"`
+	APIKeyID       *uint     `json:"api_key_id"`
+	Actor          string    `json:"actor"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	StatusCode     int       `json:"status_code"`
+	ClientIP       string    `json:"client_ip"`
+	CreatedAt      time.Time `json:"created_at"`
+}
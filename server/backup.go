@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/hang666/EasyUKey/server/internal/backup"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/initialize"
+)
+
+// passphraseEnv 保存备份口令的环境变量
+const passphraseEnv = "EASYUKEY_BACKUP_PASSPHRASE"
+
+// runBackup 导出全部数据到加密备份文件
+func runBackup(args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径")
+	passphraseFile := flags.String("passphrase-file", "", "从文件读取备份口令，默认读取环境变量 "+passphraseEnv+" 或在终端输入")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: easyukey-server backup [参数] [备份文件]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	output := flags.Arg(0)
+	if output == "" {
+		output = "easyukey-backup-" + time.Now().Format("20060102-150405") + ".eukb"
+	}
+
+	passphrase, err := readPassphrase(*passphraseFile, true)
+	if err != nil {
+		return fail(err)
+	}
+	if err := initialize.InitForMaintenance(*configPath); err != nil {
+		return fail(err)
+	}
+
+	// 先写入同目录的临时文件，完成后再重命名，避免留下不完整的备份
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".easyukey-backup-*")
+	if err != nil {
+		return fail(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	summary, err := backup.Create(global.DB, tmp, passphrase)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), output)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("创建备份失败: %w", err))
+	}
+
+	fmt.Printf("备份已写入 %s\n", output)
+	printSummary(summary)
+	return 0
+}
+
+// runRestore 校验备份文件并恢复到数据库
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径")
+	passphraseFile := flags.String("passphrase-file", "", "从文件读取备份口令，默认读取环境变量 "+passphraseEnv+" 或在终端输入")
+	force := flags.Bool("force", false, "数据库不为空时清空全部数据后恢复")
+	verifyOnly := flags.Bool("verify-only", false, "只校验备份文件和密钥环，不连接数据库")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: easyukey-server restore [参数] <备份文件>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	input := flags.Arg(0)
+
+	passphrase, err := readPassphrase(*passphraseFile, false)
+	if err != nil {
+		return fail(err)
+	}
+
+	// 恢复后的设备组认证密钥需要用配置的密钥环解密，校验时一并确认
+	if err := initialize.InitForVerify(*configPath); err != nil {
+		return fail(err)
+	}
+
+	// 写入数据库之前完整校验一遍，备份损坏、口令错误或密钥环不匹配时不触碰数据库
+	summary, err := verifyFile(input, passphrase)
+	if err != nil {
+		return fail(fmt.Errorf("备份校验失败: %w", err))
+	}
+	fmt.Printf("备份校验通过，创建于 %s\n", summary.CreatedAt.Local().Format(time.DateTime))
+	if *verifyOnly {
+		printSummary(summary)
+		return 0
+	}
+
+	if err := initialize.InitMaintenanceDatabase(); err != nil {
+		return fail(err)
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return fail(err)
+	}
+	defer f.Close()
+
+	summary, err = backup.Restore(global.DB, f, passphrase, global.Keyring, *force)
+	if err != nil {
+		return fail(fmt.Errorf("恢复失败，数据库未做任何修改: %w", err))
+	}
+
+	fmt.Println("恢复完成")
+	printSummary(summary)
+	return 0
+}
+
+// verifyFile 完整读取并校验备份文件
+func verifyFile(path, passphrase string) (*backup.Summary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return backup.Verify(f, passphrase, global.Keyring)
+}
+
+// readPassphrase 依次从口令文件、环境变量和终端读取备份口令，confirm 为 true 时终端输入需要确认
+func readPassphrase(file string, confirm bool) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("读取口令文件失败: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("缺少备份口令，请使用 -passphrase-file 或环境变量 " + passphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "请输入备份口令: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "请再次输入备份口令: ")
+		second, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(first) != string(second) {
+			return "", errors.New("两次输入的口令不一致")
+		}
+	}
+	return string(first), nil
+}
+
+// printSummary 输出所需的密钥环主密钥和各表行数
+func printSummary(summary *backup.Summary) {
+	if len(summary.KeyIDs) > 0 {
+		fmt.Printf("  需要的密钥环主密钥: %s\n", strings.Join(summary.KeyIDs, ", "))
+	}
+	for _, t := range summary.Tables {
+		fmt.Printf("  %-28s %d\n", t.Name, t.Rows)
+	}
+}
+
+// fail 输出错误并返回退出码
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, "错误:", err)
+	return 1
+}
@@ -2,10 +2,15 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
 	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/middleware"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
 	"github.com/hang666/EasyUKey/server/internal/service"
 	"github.com/hang666/EasyUKey/shared/pkg/errs"
 )
@@ -45,3 +50,215 @@ func VerifyAdminKey(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, &response.Response{Success: true, Message: "管理员身份验证成功", Data: &result})
 }
+
+// setAdminSessionCookie 写入管理后台会话Cookie，value 为空时清除
+func setAdminSessionCookie(c echo.Context, value string) {
+	cookie := &http.Cookie{
+		Name:     middleware.AdminSessionCookie,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteStrictMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	c.SetCookie(cookie)
+}
+
+// AdminLogin 发起管理后台登录，向管理员的U盘推送 admin:login 认证请求
+func AdminLogin(c echo.Context) error {
+	var req request.AdminLoginRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if req.Username == "" {
+		return errs.ErrMissingUsername
+	}
+
+	token, session, err := service.StartAdminLogin(req.Username, c.RealIP(), c.Request().UserAgent())
+	if err != nil {
+		return err
+	}
+
+	setAdminSessionCookie(c, token)
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "已向U盘发送登录确认请求",
+		Data: &response.AdminLoginResponse{
+			SessionID: session.ID,
+			ExpiresAt: session.ExpiresAt,
+		},
+	})
+}
+
+// AdminLoginStatus 查询管理后台登录进度
+func AdminLoginStatus(c echo.Context) error {
+	cookie, err := c.Cookie(middleware.AdminSessionCookie)
+	if err != nil || cookie.Value == "" {
+		return errs.ErrAdminSessionInvalid
+	}
+
+	status, account, err := service.CheckAdminLogin(cookie.Value)
+	if err != nil {
+		setAdminSessionCookie(c, "")
+		return err
+	}
+
+	if status != consts.AuthStatusCompleted && status != consts.AuthStatusPending {
+		setAdminSessionCookie(c, "")
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Data: &response.AdminLoginStatusResponse{
+			Status: status,
+			Admin:  service.ConvertToAdminAccountResponse(account),
+		},
+	})
+}
+
+// AdminLogout 退出管理后台
+func AdminLogout(c echo.Context) error {
+	if cookie, err := c.Cookie(middleware.AdminSessionCookie); err == nil {
+		if err := service.AdminLogout(cookie.Value); err != nil {
+			return err
+		}
+	}
+
+	setAdminSessionCookie(c, "")
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "已退出登录",
+	})
+}
+
+// GetCurrentAdmin 获取当前登录的管理员，使用管理员API密钥访问时返回密钥名称
+func GetCurrentAdmin(c echo.Context) error {
+	var current *response.AdminAccountResponse
+	if account, ok := c.Get("admin_account").(*entity.AdminAccount); ok {
+		current = service.ConvertToAdminAccountResponse(account)
+	} else if key, ok := c.Get("api_key").(*entity.APIKey); ok {
+		current = &response.AdminAccountResponse{Username: key.Name, IsActive: key.IsActive, CreatedAt: key.CreatedAt}
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Data:    current,
+	})
+}
+
+// GetAdminAccounts 获取管理员账号列表
+func GetAdminAccounts(c echo.Context) error {
+	accounts, err := service.GetAdminAccounts()
+	if err != nil {
+		return err
+	}
+
+	result := make([]response.AdminAccountResponse, 0, len(accounts))
+	for i := range accounts {
+		result = append(result, *service.ConvertToAdminAccountResponse(&accounts[i]))
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// CreateAdminAccount 将用户设为管理员
+func CreateAdminAccount(c echo.Context) error {
+	var req request.CreateAdminAccountRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	account, err := service.CreateAdminAccount(&req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, &response.Response{
+		Success: true,
+		Message: "管理员账号创建成功",
+		Data:    service.ConvertToAdminAccountResponse(account),
+	})
+}
+
+// UpdateAdminAccount 启用或停用管理员账号
+func UpdateAdminAccount(c echo.Context) error {
+	accountID, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var req request.UpdateAdminAccountRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	account, err := service.UpdateAdminAccount(accountID, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "管理员账号更新成功",
+		Data:    service.ConvertToAdminAccountResponse(account),
+	})
+}
+
+// DeleteAdminAccount 删除管理员账号
+func DeleteAdminAccount(c echo.Context) error {
+	accountID, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := service.DeleteAdminAccount(accountID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "管理员账号删除成功",
+	})
+}
+
+// GetAdminAuditLogs 获取管理操作审计日志
+func GetAdminAuditLogs(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(c.QueryParam("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var accountID *uint
+	if idStr := c.QueryParam("admin_account_id"); idStr != "" {
+		if id, err := strconv.ParseUint(idStr, 10, 32); err == nil {
+			idUint := uint(id)
+			accountID = &idUint
+		}
+	}
+
+	logs, total, err := service.GetAdminAuditLogs(page, pageSize, accountID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "获取审计日志列表成功",
+		Data:    &logs,
+		Total:   &total,
+	})
+}
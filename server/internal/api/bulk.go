@@ -0,0 +1,324 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/service"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// bulkPermissionSeparator CSV 中多个权限之间的分隔符
+const bulkPermissionSeparator = ";"
+
+var (
+	bulkUserCSVHeader        = []string{"username", "permissions", "is_active"}
+	bulkDeviceGroupCSVHeader = []string{"device_group_id", "name", "username", "permissions"}
+)
+
+// ImportUsers 批量导入用户，支持 CSV 和 JSON，dry_run=true 时只校验不写入
+func ImportUsers(c echo.Context) error {
+	format, err := bulkFormat(c)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return errs.ErrBulkFormatInvalid
+	}
+
+	var records []request.BulkUserRecord
+	var rowErrs []response.BulkRowError
+	if format == "csv" {
+		records, rowErrs, err = decodeBulkUsersCSV(data)
+	} else {
+		err = decodeBulkJSON(data, &records)
+	}
+	if err != nil {
+		return err
+	}
+
+	dryRun := c.QueryParam("dry_run") == "true"
+	result, err := service.ImportUsers(records, dryRun || len(rowErrs) > 0)
+	if err != nil {
+		return err
+	}
+	return bulkImportResponse(c, result, dryRun, rowErrs)
+}
+
+// ExportUsers 导出全部用户，格式与批量导入一致
+func ExportUsers(c echo.Context) error {
+	format, err := bulkFormat(c)
+	if err != nil {
+		return err
+	}
+	records, err := service.ExportUsers()
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		return writeBulkJSON(c, "users", records)
+	}
+
+	rows := make([][]string, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, []string{
+			r.Username,
+			strings.Join(r.Permissions, bulkPermissionSeparator),
+			strconv.FormatBool(r.IsActive != nil && *r.IsActive),
+		})
+	}
+	return writeBulkCSV(c, "users", bulkUserCSVHeader, rows)
+}
+
+// ImportDeviceGroups 批量导入设备组的用户关联、名称和权限，支持 CSV 和 JSON，dry_run=true 时只校验不写入
+func ImportDeviceGroups(c echo.Context) error {
+	format, err := bulkFormat(c)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return errs.ErrBulkFormatInvalid
+	}
+
+	var records []request.BulkDeviceGroupRecord
+	var rowErrs []response.BulkRowError
+	if format == "csv" {
+		records, rowErrs, err = decodeBulkDeviceGroupsCSV(data)
+	} else {
+		err = decodeBulkJSON(data, &records)
+	}
+	if err != nil {
+		return err
+	}
+
+	dryRun := c.QueryParam("dry_run") == "true"
+	result, err := service.ImportDeviceGroups(records, dryRun || len(rowErrs) > 0)
+	if err != nil {
+		return err
+	}
+	return bulkImportResponse(c, result, dryRun, rowErrs)
+}
+
+// ExportDeviceGroups 导出全部设备组的用户关联、名称和权限，格式与批量导入一致
+func ExportDeviceGroups(c echo.Context) error {
+	format, err := bulkFormat(c)
+	if err != nil {
+		return err
+	}
+	records, err := service.ExportDeviceGroups()
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		return writeBulkJSON(c, "device_groups", records)
+	}
+
+	rows := make([][]string, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(r.DeviceGroupID), 10),
+			r.Name,
+			r.Username,
+			strings.Join(r.Permissions, bulkPermissionSeparator),
+		})
+	}
+	return writeBulkCSV(c, "device_groups", bulkDeviceGroupCSVHeader, rows)
+}
+
+// bulkFormat 获取批量导入导出的数据格式，未指定时根据 Content-Type 判断，默认为 JSON
+func bulkFormat(c echo.Context) (string, error) {
+	switch format := c.QueryParam("format"); format {
+	case "csv", "json":
+		return format, nil
+	case "":
+		if strings.Contains(c.Request().Header.Get(echo.HeaderContentType), "csv") {
+			return "csv", nil
+		}
+		return "json", nil
+	default:
+		return "", errs.ErrExportFormatInvalid
+	}
+}
+
+// bulkImportResponse 合并解析和校验的行级错误后返回导入结果
+func bulkImportResponse(c echo.Context, result *response.BulkImportResult, dryRun bool, rowErrs []response.BulkRowError) error {
+	result.DryRun = dryRun
+	if len(rowErrs) > 0 {
+		result.Errors = append(rowErrs, result.Errors...)
+		slices.SortStableFunc(result.Errors, func(a, b response.BulkRowError) int { return a.Row - b.Row })
+	}
+
+	if len(result.Errors) > 0 {
+		return c.JSON(http.StatusBadRequest, &response.Response{
+			Success: false,
+			Message: errs.ErrBulkValidationFailed.Error(),
+			Data:    result,
+		})
+	}
+
+	message := "批量导入成功"
+	if dryRun {
+		message = "校验通过，未写入数据"
+	}
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
+
+// decodeBulkJSON 解析 JSON 数组格式的导入数据
+func decodeBulkJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errs.ErrBulkFormatInvalid
+	}
+	return nil
+}
+
+// decodeBulkUsersCSV 解析用户 CSV，单元格格式错误记为行级错误，该行其余字段仍参与校验
+func decodeBulkUsersCSV(data []byte) ([]request.BulkUserRecord, []response.BulkRowError, error) {
+	columns, rows, err := readBulkCSV(data, "username")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := make([]request.BulkUserRecord, len(rows))
+	var rowErrs []response.BulkRowError
+	for i, row := range rows {
+		record := &records[i]
+		record.Username = cell(row, columns, "username")
+		record.Permissions = parsePermissionsCell(row, columns)
+
+		if value := cell(row, columns, "is_active"); value != "" {
+			isActive, err := strconv.ParseBool(value)
+			if err != nil {
+				rowErrs = append(rowErrs, response.BulkRowError{Row: i + 1, Field: "is_active", Message: "应为 true 或 false"})
+				continue
+			}
+			record.IsActive = &isActive
+		}
+	}
+	return records, rowErrs, nil
+}
+
+// decodeBulkDeviceGroupsCSV 解析设备组 CSV，单元格格式错误记为行级错误
+func decodeBulkDeviceGroupsCSV(data []byte) ([]request.BulkDeviceGroupRecord, []response.BulkRowError, error) {
+	columns, rows, err := readBulkCSV(data, "device_group_id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := make([]request.BulkDeviceGroupRecord, len(rows))
+	var rowErrs []response.BulkRowError
+	for i, row := range rows {
+		record := &records[i]
+		record.Name = cell(row, columns, "name")
+		record.Username = cell(row, columns, "username")
+		record.Permissions = parsePermissionsCell(row, columns)
+
+		id, err := strconv.ParseUint(cell(row, columns, "device_group_id"), 10, 32)
+		if err != nil || id == 0 {
+			rowErrs = append(rowErrs, response.BulkRowError{Row: i + 1, Field: "device_group_id", Message: "应为正整数"})
+			continue
+		}
+		record.DeviceGroupID = uint(id)
+	}
+	return records, rowErrs, nil
+}
+
+// readBulkCSV 读取带表头的 CSV，返回列名到下标的映射和数据行
+func readBulkCSV(data []byte, required string) (map[string]int, [][]string, error) {
+	// 兼容 Excel 保存的带 BOM 的 UTF-8 文件
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, errs.ErrBulkFormatInvalid
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns[required]; !ok {
+		return nil, nil, errs.ErrBulkFormatInvalid
+	}
+
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, errs.ErrBulkFormatInvalid
+		}
+		if len(rows) >= service.MaxBulkImportRows {
+			return nil, nil, errs.ErrBulkTooManyRows
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// cell 获取数据行中指定列的值，列不存在时返回空字符串
+func cell(row []string, columns map[string]int, name string) string {
+	if i, ok := columns[name]; ok && i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// parsePermissionsCell 解析权限列，缺少该列时返回 nil 表示保持不变，空单元格表示清空权限
+func parsePermissionsCell(row []string, columns map[string]int) []string {
+	if _, ok := columns["permissions"]; !ok {
+		return nil
+	}
+	value := cell(row, columns, "permissions")
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, bulkPermissionSeparator)
+}
+
+// writeBulkCSV 以附件形式输出 CSV
+func writeBulkCSV(c echo.Context, name string, header []string, rows [][]string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+
+	setAttachment(c, name, "csv")
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// writeBulkJSON 以附件形式输出 JSON 数组
+func writeBulkJSON(c echo.Context, name string, records interface{}) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	setAttachment(c, name, "json")
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, append(data, '\n'))
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
@@ -30,3 +32,9 @@ func parseUintParam(c echo.Context, paramName string) (uint, error) {
 
 	return uint(value), nil
 }
+
+// setAttachment 设置下载文件名，文件名带有导出时间
+func setAttachment(c echo.Context, name, ext string) {
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102150405"), ext)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+}
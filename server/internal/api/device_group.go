@@ -106,6 +106,25 @@ func LinkDeviceGroupUser(c echo.Context) error {
 	})
 }
 
+// RekeyDeviceGroup 重置设备组密钥并解除隔离
+func RekeyDeviceGroup(c echo.Context) error {
+	groupID, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	recoveryCodes, err := service.RekeyDeviceGroup(groupID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "设备组密钥已重置，请使用新的恢复码重新初始化U盘",
+		Data:    &response.RekeyDeviceGroupResponse{RecoveryCodes: recoveryCodes},
+	})
+}
+
 // GetPendingActivationDevices 获取待激活设备列表
 func GetPendingActivationDevices(c echo.Context) error {
 	devices, err := service.GetPendingActivationDevices()
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/server/internal/service"
+)
+
+// CreateEventSubscription 为当前API密钥注册事件订阅
+func CreateEventSubscription(c echo.Context) error {
+	var req request.EventSubscriptionRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	apiKey := c.Get("api_key").(*entity.APIKey)
+	sub, err := service.CreateEventSubscription(apiKey.ID, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, &response.Response{
+		Success: true,
+		Message: "事件订阅创建成功",
+		Data:    service.ConvertToEventSubscriptionResponse(sub),
+	})
+}
+
+// GetEventSubscriptions 获取当前API密钥的事件订阅列表
+func GetEventSubscriptions(c echo.Context) error {
+	apiKey := c.Get("api_key").(*entity.APIKey)
+	subs, err := service.GetEventSubscriptions(apiKey.ID)
+	if err != nil {
+		return err
+	}
+
+	result := make([]response.EventSubscriptionResponse, 0, len(subs))
+	for i := range subs {
+		result = append(result, *service.ConvertToEventSubscriptionResponse(&subs[i]))
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// DeleteEventSubscription 删除当前API密钥的事件订阅
+func DeleteEventSubscription(c echo.Context) error {
+	subID, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	apiKey := c.Get("api_key").(*entity.APIKey)
+	if err := service.DeleteEventSubscription(apiKey.ID, subID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "事件订阅删除成功",
+	})
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/initialize"
+	"github.com/hang666/EasyUKey/shared/pkg/keyring"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	os.Exit(m.Run())
+}
+
+// setupTestDB 使用临时目录中的SQLite数据库替换 global.DB 并迁移全部表结构，同时设置测试所需的配置和密钥环
+// 测试结束时恢复原来的全局状态
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)"),
+		&gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := keyring.New("test", map[string][]byte{"test": bytes.Repeat([]byte{7}, keyring.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevDB, prevConfig, prevKeyring := global.DB, global.Config, global.Keyring
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		global.DB, global.Config, global.Keyring = prevDB, prevConfig, prevKeyring
+	})
+
+	global.DB = db
+	global.Keyring = ring
+	global.Config = &config.Config{
+		Security: config.SecurityConfig{
+			EncryptionKey:   "test-encryption-key",
+			RecoveryCodeKey: "test-recovery-code-key",
+		},
+	}
+
+	if err := initialize.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestContext 创建请求 target 的 echo 上下文和响应记录器
+func newTestContext(method, target string, body io.Reader) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, body)
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/service"
+)
+
+// GetNotificationChannels 获取通知渠道列表
+func GetNotificationChannels(c echo.Context) error {
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Data:    service.GetNotificationChannels(),
+	})
+}
+
+// TestNotificationChannel 向通知渠道发送测试通知
+func TestNotificationChannel(c echo.Context) error {
+	if err := service.TestNotificationChannel(c.Param("name")); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "测试通知发送成功",
+	})
+}
+
+// GetNotificationSubscriptions 获取通知订阅列表
+func GetNotificationSubscriptions(c echo.Context) error {
+	subs, err := service.GetNotificationSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Data:    subs,
+	})
+}
+
+// CreateNotificationSubscription 添加通知订阅
+func CreateNotificationSubscription(c echo.Context) error {
+	var req request.NotificationSubscriptionRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	sub, err := service.CreateNotificationSubscription(&req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, &response.Response{
+		Success: true,
+		Message: "通知订阅添加成功",
+		Data:    service.ConvertToNotificationSubscriptionResponse(sub),
+	})
+}
+
+// UpdateNotificationSubscription 更新通知订阅
+func UpdateNotificationSubscription(c echo.Context) error {
+	subID, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var req request.NotificationSubscriptionRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	sub, err := service.UpdateNotificationSubscription(subID, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "通知订阅更新成功",
+		Data:    service.ConvertToNotificationSubscriptionResponse(sub),
+	})
+}
+
+// DeleteNotificationSubscription 删除通知订阅
+func DeleteNotificationSubscription(c echo.Context) error {
+	subID, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := service.DeleteNotificationSubscription(subID); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "通知订阅删除成功",
+	})
+}
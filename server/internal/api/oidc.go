@@ -0,0 +1,251 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/service"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// oidcPageData OIDC登录页模板数据
+type oidcPageData struct {
+	Nonce      string                        // 内联脚本和样式的CSP nonce
+	ClientName string                        // 发起登录的应用名称
+	Request    *service.OIDCAuthorizeRequest // 授权请求参数，登录页提交用户名时原样带回
+	Error      string                        // 无法继续登录时的错误信息
+}
+
+// renderOIDCPage 渲染OIDC登录页，使用带 nonce 的严格CSP
+func renderOIDCPage(c echo.Context, status int, data *oidcPageData) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	data.Nonce = base64.StdEncoding.EncodeToString(b)
+
+	header := c.Response().Header()
+	header.Set("Content-Security-Policy", fmt.Sprintf(
+		"default-src 'none'; script-src 'nonce-%[1]s'; style-src 'nonce-%[1]s'; connect-src 'self'; "+
+			"form-action 'none'; frame-ancestors 'none'; base-uri 'none'", data.Nonce))
+	header.Set("Cache-Control", "no-store")
+	return c.Render(status, "oidc.html", data)
+}
+
+// OIDCDiscovery OpenID Provider 元数据
+func OIDCDiscovery(c echo.Context) error {
+	return c.JSON(http.StatusOK, service.GetOIDCDiscovery())
+}
+
+// OIDCJWKS ID Token 签名公钥
+func OIDCJWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, service.GetOIDCJWKS())
+}
+
+// OIDCAuthorize 授权端点：校验授权请求并展示登录页
+// 客户端或回调地址无效时只在页面上提示，其余错误按协议重定向回客户端
+func OIDCAuthorize(c echo.Context) error {
+	var req service.OIDCAuthorizeRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return renderOIDCPage(c, http.StatusBadRequest, &oidcPageData{Error: err.Error()})
+	}
+
+	client, err := service.ValidateOIDCAuthorizeRequest(&req)
+	if err != nil {
+		var oidcErr *service.OIDCError
+		if errors.As(err, &oidcErr) {
+			return c.Redirect(http.StatusFound, service.OIDCErrorRedirect(&req, oidcErr))
+		}
+		return renderOIDCPage(c, http.StatusBadRequest, &oidcPageData{Error: err.Error()})
+	}
+
+	return renderOIDCPage(c, http.StatusOK, &oidcPageData{ClientName: client.Name, Request: &req})
+}
+
+// OIDCStartLogin 登录页提交用户名，向用户的U盘推送认证请求
+func OIDCStartLogin(c echo.Context) error {
+	var req service.OIDCAuthorizeRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Username == "" {
+		return errs.ErrMissingUsername
+	}
+
+	loginID, session, err := service.StartOIDCLogin(&req, c.RealIP())
+	if err != nil {
+		var oidcErr *service.OIDCError
+		if errors.As(err, &oidcErr) {
+			return errs.ErrInvalidRequest
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "已向U盘发送登录确认请求",
+		Data: &response.OIDCLoginResponse{
+			LoginID:   loginID,
+			SessionID: session.ID,
+			ExpiresAt: session.ExpiresAt,
+		},
+	})
+}
+
+// OIDCLoginStatus 查询登录进度，结束后返回浏览器需跳转的回调地址
+func OIDCLoginStatus(c echo.Context) error {
+	var req struct {
+		LoginID string `json:"login_id"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.LoginID == "" {
+		return errs.ErrOIDCAuthorizationInvalid
+	}
+
+	status, redirectURL, err := service.CheckOIDCLogin(req.LoginID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Data: &response.OIDCLoginStatusResponse{
+			Status:      status,
+			RedirectURL: redirectURL,
+		},
+	})
+}
+
+// OIDCToken 令牌端点：使用授权码换取 ID Token 和访问令牌
+// 支持 client_secret_basic 和 client_secret_post 两种客户端认证方式
+func OIDCToken(c echo.Context) error {
+	var req service.OIDCTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return writeOIDCError(c, &service.OIDCError{Code: service.OIDCErrInvalidRequest, Description: "请求参数格式错误"})
+	}
+
+	if id, secret, ok := c.Request().BasicAuth(); ok {
+		// RFC 6749 2.3.1：Basic 认证中的凭据需先经过 form 编码
+		id, idErr := url.QueryUnescape(id)
+		secret, secretErr := url.QueryUnescape(secret)
+		if idErr != nil || secretErr != nil || req.ClientSecret != "" || (req.ClientID != "" && req.ClientID != id) {
+			return writeOIDCError(c, &service.OIDCError{Code: service.OIDCErrInvalidRequest, Description: "客户端凭据不一致"})
+		}
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	token, err := service.ExchangeOIDCCode(&req)
+	if err != nil {
+		return writeOIDCError(c, err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	return c.JSON(http.StatusOK, token)
+}
+
+// OIDCUserInfo 用户信息端点，使用 Bearer 访问令牌
+func OIDCUserInfo(c echo.Context) error {
+	accessToken, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		accessToken = ""
+	}
+
+	info, err := service.GetOIDCUserInfo(strings.TrimSpace(accessToken))
+	if err != nil {
+		return writeOIDCError(c, err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, info)
+}
+
+// writeOIDCError 按 OAuth 2.0 格式返回协议错误，其他错误交给全局错误处理
+func writeOIDCError(c echo.Context, err error) error {
+	var oidcErr *service.OIDCError
+	if !errors.As(err, &oidcErr) {
+		return err
+	}
+
+	status := http.StatusBadRequest
+	switch oidcErr.Code {
+	case service.OIDCErrInvalidClient:
+		status = http.StatusUnauthorized
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="easyukey"`)
+	case service.OIDCErrInvalidToken:
+		status = http.StatusUnauthorized
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="%s"`, oidcErr.Code))
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(status, oidcErr)
+}
+
+// CreateOIDCClient 注册OIDC客户端
+func CreateOIDCClient(c echo.Context) error {
+	var req request.CreateOIDCClientRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	client, err := service.CreateOIDCClient(&req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, &response.Response{Success: true, Message: "OIDC客户端创建成功", Data: client})
+}
+
+// GetOIDCClients 获取OIDC客户端列表
+func GetOIDCClients(c echo.Context) error {
+	clients, err := service.GetOIDCClients()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{Success: true, Data: clients})
+}
+
+// UpdateOIDCClient 更新OIDC客户端
+func UpdateOIDCClient(c echo.Context) error {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var req request.UpdateOIDCClientRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	client, err := service.UpdateOIDCClient(id, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{Success: true, Message: "OIDC客户端更新成功", Data: client})
+}
+
+// DeleteOIDCClient 删除OIDC客户端
+func DeleteOIDCClient(c echo.Context) error {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := service.DeleteOIDCClient(id); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{Success: true, Message: "OIDC客户端删除成功"})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/service"
+)
+
+// GetSecurityEvents 获取安全事件列表
+func GetSecurityEvents(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(c.QueryParam("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// 获取过滤参数
+	filter := &request.SecurityEventFilter{
+		Type: c.QueryParam("type"),
+	}
+
+	if groupIDStr := c.QueryParam("device_group_id"); groupIDStr != "" {
+		if groupID, err := strconv.ParseUint(groupIDStr, 10, 32); err == nil {
+			groupIDUint := uint(groupID)
+			filter.DeviceGroupID = &groupIDUint
+		}
+	}
+
+	events, total, err := service.GetSecurityEvents(page, pageSize, filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &response.Response{
+		Success: true,
+		Message: "获取安全事件列表成功",
+		Data:    &events,
+		Total:   &total,
+	})
+}
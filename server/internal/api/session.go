@@ -1,16 +1,30 @@
 package api
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/hang666/EasyUKey/sdk/request"
 	"github.com/hang666/EasyUKey/sdk/response"
 	"github.com/hang666/EasyUKey/server/internal/service"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
 )
 
-// GetAuthSessions 获取认证会话列表
+// authSessionCSVHeader 认证会话CSV导出的表头
+var authSessionCSVHeader = []string{
+	"id", "status", "result", "action", "message", "challenge",
+	"user_id", "username", "api_key_id", "api_key_name",
+	"responding_device_id", "device_serial_number", "device_group_id",
+	"client_ip", "created_at", "updated_at", "expires_at",
+}
+
+// GetAuthSessions 获取认证会话列表，format=csv 或 jsonl 时以流的形式导出全部匹配的会话
 func GetAuthSessions(c echo.Context) error {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	if page < 1 {
@@ -22,11 +36,22 @@ func GetAuthSessions(c echo.Context) error {
 		pageSize = 20
 	}
 
-	// 获取过滤参数
-	status := c.QueryParam("status")
-	username := c.QueryParam("username")
+	filter, err := parseAuthSessionFilter(c)
+	if err != nil {
+		return err
+	}
 
-	sessions, total, err := service.GetAuthSessions(page, pageSize, status, username)
+	switch format := c.QueryParam("format"); format {
+	case "", "json":
+	case "csv":
+		return exportAuthSessionsCSV(c, filter)
+	case "jsonl":
+		return exportAuthSessionsJSONL(c, filter)
+	default:
+		return errs.ErrExportFormatInvalid
+	}
+
+	sessions, total, err := service.GetAuthSessions(page, pageSize, filter)
 	if err != nil {
 		return err
 	}
@@ -38,3 +63,125 @@ func GetAuthSessions(c echo.Context) error {
 		Total:   &total,
 	})
 }
+
+// parseAuthSessionFilter 解析认证会话过滤参数
+func parseAuthSessionFilter(c echo.Context) (*request.AuthSessionFilter, error) {
+	filter := &request.AuthSessionFilter{
+		Status:    c.QueryParam("status"),
+		Username:  c.QueryParam("username"),
+		Action:    c.QueryParam("action"),
+		ClientIP:  c.QueryParam("client_ip"),
+		Search:    c.QueryParam("search"),
+		SortBy:    c.QueryParam("sort_by"),
+		SortOrder: c.QueryParam("sort_order"),
+	}
+
+	// ID格式错误时返回错误而不是忽略，避免导出时静默扩大范围
+	for name, target := range map[string]**uint{
+		"api_key_id":      &filter.APIKeyID,
+		"device_id":       &filter.DeviceID,
+		"device_group_id": &filter.DeviceGroupID,
+	} {
+		id, err := parseUintQuery(c, name)
+		if err != nil {
+			return nil, err
+		}
+		*target = id
+	}
+
+	for name, target := range map[string]**time.Time{
+		"start_time": &filter.StartTime,
+		"end_time":   &filter.EndTime,
+	} {
+		if value := c.QueryParam(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errs.ErrSessionTimeRangeInvalid
+			}
+			*target = &t
+		}
+	}
+
+	// 导出时响应头写出后无法再返回错误状态码，需先校验参数
+	if err := service.ValidateAuthSessionFilter(filter); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// parseUintQuery 解析可选的无符号整数查询参数，未提供时返回 nil
+func parseUintQuery(c echo.Context, name string) (*uint, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, errs.ErrSessionFilterInvalid
+	}
+	idUint := uint(id)
+	return &idUint, nil
+}
+
+// exportAuthSessionsCSV 以CSV格式导出认证会话
+func exportAuthSessionsCSV(c echo.Context, filter *request.AuthSessionFilter) error {
+	setExportHeaders(c, "text/csv; charset=utf-8", "csv")
+
+	w := csv.NewWriter(c.Response())
+	if err := w.Write(authSessionCSVHeader); err != nil {
+		return err
+	}
+
+	err := service.ExportAuthSessions(filter, func(r *response.AuthSessionRecord) error {
+		row := []string{
+			r.ID, r.Status, r.Result, r.Action, r.Message, r.Challenge,
+			strconv.FormatUint(uint64(r.UserID), 10), r.Username, formatOptionalUint(r.APIKeyID), r.APIKeyName,
+			formatOptionalUint(r.RespondingDeviceID), r.DeviceSerialNumber, formatOptionalUint(r.DeviceGroupID),
+			r.ClientIP, r.CreatedAt.Format(time.RFC3339), r.UpdatedAt.Format(time.RFC3339), r.ExpiresAt.Format(time.RFC3339),
+		}
+		for i := range row {
+			row[i] = escapeCSVFormula(row[i])
+		}
+		return w.Write(row)
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// exportAuthSessionsJSONL 以 JSON Lines 格式导出认证会话
+func exportAuthSessionsJSONL(c echo.Context, filter *request.AuthSessionFilter) error {
+	setExportHeaders(c, "application/x-ndjson; charset=utf-8", "jsonl")
+
+	enc := json.NewEncoder(c.Response())
+	return service.ExportAuthSessions(filter, func(r *response.AuthSessionRecord) error {
+		return enc.Encode(r)
+	})
+}
+
+// setExportHeaders 设置导出文件的响应头并开始输出响应
+func setExportHeaders(c echo.Context, contentType, ext string) {
+	setAttachment(c, "auth_sessions", ext)
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	c.Response().WriteHeader(http.StatusOK)
+}
+
+// escapeCSVFormula 在可能被电子表格当作公式执行的单元格前加单引号
+// 说明、挑战码、用户名等字段由集成方或用户提供，导出文件通常直接用电子表格打开
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// formatOptionalUint 格式化可为空的ID，为空时返回空字符串
+func formatOptionalUint(v *uint) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*v), 10)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// createTestAuthSession 为用户名为 username 的新用户创建认证会话
+func createTestAuthSession(t *testing.T, username, status, message, challenge string) *entity.AuthSession {
+	t.Helper()
+	user := &entity.User{Username: username, IsActive: true}
+	if err := global.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	session := &entity.AuthSession{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Status:    status,
+		Action:    "login",
+		Message:   message,
+		Challenge: challenge,
+		ClientIP:  "192.0.2.1",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err := global.DB.Create(session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestGetAuthSessionsRejectsInvalidFilter(t *testing.T) {
+	setupTestDB(t)
+	cases := map[string]struct {
+		query string
+		want  error
+	}{
+		"ID不是整数":    {"api_key_id=abc", errs.ErrSessionFilterInvalid},
+		"ID为负数":     {"device_group_id=-1", errs.ErrSessionFilterInvalid},
+		"时间格式错误":    {"start_time=2024-01-01", errs.ErrSessionTimeRangeInvalid},
+		"时间范围为空":    {"start_time=2024-01-02T00:00:00Z&end_time=2024-01-01T00:00:00Z", errs.ErrSessionTimeRangeInvalid},
+		"不允许的排序字段":  {"sort_by=challenge", errs.ErrSessionSortInvalid},
+		"无效的排序方向":   {"sort_order=random", errs.ErrSessionSortInvalid},
+		"不支持的导出格式":  {"format=xml", errs.ErrExportFormatInvalid},
+		"导出前校验过滤条件": {"format=csv&device_id=x", errs.ErrSessionFilterInvalid},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, rec := newTestContext(http.MethodGet, "/api/v1/admin/sessions?"+tc.query, nil)
+			if err := GetAuthSessions(c); !errors.Is(err, tc.want) {
+				t.Fatalf("返回 %v，应为 %v", err, tc.want)
+			}
+			if rec.Body.Len() != 0 || c.Response().Committed {
+				t.Error("参数错误时不应开始输出导出内容")
+			}
+		})
+	}
+}
+
+func TestExportAuthSessionsCSVEscapesFormulas(t *testing.T) {
+	setupTestDB(t)
+	session := createTestAuthSession(t, "@admin", consts.AuthStatusCompleted, `=HYPERLINK("http://evil","x")`, "+cmd")
+
+	c, rec := newTestContext(http.MethodGet, "/api/v1/admin/sessions?format=csv", nil)
+	if err := GetAuthSessions(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, ".csv") {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("导出 %d 行，应为表头和一条会话", len(rows))
+	}
+	row := make(map[string]string, len(authSessionCSVHeader))
+	for i, name := range authSessionCSVHeader {
+		row[name] = rows[1][i]
+	}
+
+	want := map[string]string{
+		"id":        session.ID,
+		"message":   `'=HYPERLINK("http://evil","x")`,
+		"challenge": "'+cmd",
+		"username":  "'@admin",
+		"client_ip": "192.0.2.1",
+	}
+	for name, value := range want {
+		if row[name] != value {
+			t.Errorf("%s = %q，应为 %q", name, row[name], value)
+		}
+	}
+}
+
+func TestEscapeCSVFormula(t *testing.T) {
+	cases := map[string]string{
+		"":           "",
+		"normal":     "normal",
+		"=1+1":       "'=1+1",
+		"+1":         "'+1",
+		"-1":         "'-1",
+		"@SUM(A1)":   "'@SUM(A1)",
+		"\tcmd":      "'\tcmd",
+		"\rcmd":      "'\rcmd",
+		"a=b":        "a=b",
+		"2024-01-01": "2024-01-01",
+	}
+	for value, want := range cases {
+		if got := escapeCSVFormula(value); got != want {
+			t.Errorf("escapeCSVFormula(%q) = %q，应为 %q", value, got, want)
+		}
+	}
+}
+
+func TestExportAuthSessionsJSONL(t *testing.T) {
+	setupTestDB(t)
+	completed := createTestAuthSession(t, "alice", consts.AuthStatusCompleted, "登录\n第二行", "c1")
+	createTestAuthSession(t, "bob", consts.AuthStatusRejected, "登录", "c2")
+
+	c, rec := newTestContext(http.MethodGet, "/api/v1/admin/sessions?format=jsonl&status=completed", nil)
+	if err := GetAuthSessions(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/x-ndjson") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	var records []response.AuthSessionRecord
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var record response.AuthSessionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("第 %d 行不是有效的JSON: %v", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 1 {
+		t.Fatalf("导出 %d 条记录，应只导出符合过滤条件的 1 条", len(records))
+	}
+	if r := records[0]; r.ID != completed.ID || r.Username != "alice" || r.Message != "登录\n第二行" {
+		t.Errorf("导出记录 = %+v", r)
+	}
+}
@@ -0,0 +1,373 @@
+// Package backup 导出和恢复服务端的全部数据
+//
+// 备份内容为 gob 编码的数据流，经 gzip 压缩后写入 shared/pkg/backup 的加密容器。
+// 数据流依次为内容清单、各表的数据批次和记录各表行数的结束段，
+// 读取时逐表核对行数，结束段缺失或行数不一致都视为备份损坏。
+//
+// 设备组认证密钥以密钥环加密后的形式备份，内容清单记录所用的主密钥ID。
+// 校验和恢复时要求目标密钥环持有这些主密钥，并逐条确认能解开数据密钥，避免恢复后设备无法认证。
+package backup
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/backup"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/keyring"
+)
+
+// PayloadVersion 备份内容的版本，实体结构发生不兼容的变化时递增
+const PayloadVersion = 1
+
+const batchSize = 500
+
+// tables 备份的数据表，恢复时按此顺序写入，新增实体时需要同步添加
+var tables = []table{
+	tableOf[entity.User](),
+	tableOf[entity.DeviceGroup](),
+	tableOf[entity.Device](),
+	tableOf[entity.RecoveryCode](),
+	tableOf[entity.APIKey](),
+	tableOf[entity.AuthSession](),
+	tableOf[entity.SecurityEvent](),
+	tableOf[entity.NotificationSubscription](),
+	tableOf[entity.EventSubscription](),
+	tableOf[entity.AdminAccount](),
+	tableOf[entity.AdminSession](),
+	tableOf[entity.AdminAuditLog](),
+	tableOf[entity.OIDCClient](),
+	tableOf[entity.OIDCAuthorization](),
+}
+
+// Summary 备份内容概要
+type Summary struct {
+	CreatedAt time.Time
+	KeyIDs    []string     // 设备组认证密钥使用的密钥环主密钥ID
+	Tables    []TableCount // 按恢复顺序排列
+}
+
+// TableCount 数据表行数
+type TableCount struct {
+	Name string
+	Rows int
+}
+
+// manifest 数据流开头的内容清单
+type manifest struct {
+	PayloadVersion int
+	CreatedAt      time.Time
+	KeyIDs         []string // 设备组认证密钥使用的密钥环主密钥ID，早期的备份中为空
+}
+
+// section 数据段头，Table 为空表示数据结束，此时 Counts 记录各表的行数
+type section struct {
+	Table  string
+	Rows   int
+	Counts map[string]int
+}
+
+// table 单张数据表的导出、读取、写入和清空操作
+type table struct {
+	name   string
+	dump   func(tx *gorm.DB, enc *gob.Encoder) (int, error)
+	decode func(dec *gob.Decoder) (rows interface{}, n int, err error)
+	insert func(tx *gorm.DB, rows interface{}) error
+	count  func(tx *gorm.DB) (int64, error)
+	clear  func(tx *gorm.DB) error
+}
+
+// tableOf 为实体类型生成数据表操作，包括已软删除的记录
+func tableOf[T interface{ TableName() string }]() table {
+	var zero T
+	name := zero.TableName()
+
+	return table{
+		name: name,
+		dump: func(tx *gorm.DB, enc *gob.Encoder) (int, error) {
+			total := 0
+			var batch []T
+			err := tx.Unscoped().FindInBatches(&batch, batchSize, func(*gorm.DB, int) error {
+				if err := enc.Encode(section{Table: name, Rows: len(batch)}); err != nil {
+					return err
+				}
+				total += len(batch)
+				return enc.Encode(batch)
+			}).Error
+			return total, err
+		},
+		decode: func(dec *gob.Decoder) (interface{}, int, error) {
+			var batch []T
+			if err := dec.Decode(&batch); err != nil {
+				return nil, 0, err
+			}
+			return batch, len(batch), nil
+		},
+		insert: func(tx *gorm.DB, rows interface{}) error {
+			batch := rows.([]T)
+			if len(batch) == 0 {
+				return nil
+			}
+			// Select("*") 保证 false、0 等零值按原样写入，而不是使用字段的数据库默认值
+			return tx.Select("*").Omit(clause.Associations).Create(&batch).Error
+		},
+		count: func(tx *gorm.DB) (int64, error) {
+			var n int64
+			err := tx.Unscoped().Model(new(T)).Count(&n).Error
+			return n, err
+		},
+		clear: func(tx *gorm.DB) error {
+			return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(new(T)).Error
+		},
+	}
+}
+
+// Create 在只读的可重复读事务中导出全部数据并加密写入 w，保证各表数据来自同一时刻
+func Create(db *gorm.DB, w io.Writer, passphrase string) (*Summary, error) {
+	aw, err := backup.NewWriter(w, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	zw := gzip.NewWriter(aw)
+	enc := gob.NewEncoder(zw)
+
+	summary := &Summary{CreatedAt: time.Now().UTC()}
+	counts := make(map[string]int, len(tables))
+	err = db.Transaction(func(tx *gorm.DB) error {
+		keyIDs, err := sealedKeyIDs(tx)
+		if err != nil {
+			return fmt.Errorf("统计密钥环主密钥失败: %w", err)
+		}
+		summary.KeyIDs = keyIDs
+		if err := enc.Encode(manifest{PayloadVersion: PayloadVersion, CreatedAt: summary.CreatedAt, KeyIDs: keyIDs}); err != nil {
+			return err
+		}
+
+		for _, t := range tables {
+			n, err := t.dump(tx, enc)
+			if err != nil {
+				return fmt.Errorf("导出 %s 失败: %w", t.name, err)
+			}
+			counts[t.name] = n
+			summary.Tables = append(summary.Tables, TableCount{Name: t.name, Rows: n})
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := enc.Encode(section{Counts: counts}); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Verify 解密并完整读取备份，校验完整性、各表行数以及 ring 能否解密设备组认证密钥，不访问数据库
+func Verify(r io.Reader, passphrase string, ring *keyring.Keyring) (*Summary, error) {
+	return read(r, passphrase, ring, nil)
+}
+
+// Restore 在一个事务中将备份写入数据库，任何错误都会回滚
+// 数据库中已有数据时，force 为 false 返回 ErrRestoreDatabaseNotEmpty，为 true 时先清空全部数据表；
+// ring 无法解密备份中的设备组认证密钥时返回 ErrBackupKeyringMismatch
+func Restore(db *gorm.DB, r io.Reader, passphrase string, ring *keyring.Keyring, force bool) (*Summary, error) {
+	var summary *Summary
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range tables {
+			n, err := t.count(tx)
+			if err != nil {
+				return err
+			}
+			if n > 0 && !force {
+				return fmt.Errorf("%w: %s 表中已有 %d 行数据", errs.ErrRestoreDatabaseNotEmpty, t.name, n)
+			}
+		}
+		if force {
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tables[i].clear(tx); err != nil {
+					return fmt.Errorf("清空 %s 失败: %w", tables[i].name, err)
+				}
+			}
+		}
+
+		var err error
+		summary, err = read(r, passphrase, ring, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// read 读取备份数据流并校验 ring 能否解密设备组认证密钥，tx 不为空时写入数据库
+func read(r io.Reader, passphrase string, ring *keyring.Keyring, tx *gorm.DB) (*Summary, error) {
+	ar, err := backup.NewReader(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(ar)
+	if err != nil {
+		return nil, contentError(err)
+	}
+	dec := gob.NewDecoder(zr)
+
+	var m manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, contentError(err)
+	}
+	if m.PayloadVersion != PayloadVersion {
+		return nil, fmt.Errorf("%w: 备份内容版本 %d，当前支持版本 %d", errs.ErrBackupVersionUnsupported, m.PayloadVersion, PayloadVersion)
+	}
+	for _, id := range m.KeyIDs {
+		if !ring.HasKey(id) {
+			return nil, fmt.Errorf("%w: 缺少主密钥 %s", errs.ErrBackupKeyringMismatch, id)
+		}
+	}
+
+	byName := make(map[string]*table, len(tables))
+	for i := range tables {
+		byName[tables[i].name] = &tables[i]
+	}
+
+	counts := make(map[string]int, len(tables))
+	for {
+		var s section
+		if err := dec.Decode(&s); err != nil {
+			return nil, contentError(err)
+		}
+		if s.Table == "" {
+			if err := checkCounts(s.Counts, counts); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		t, ok := byName[s.Table]
+		if !ok {
+			return nil, fmt.Errorf("%w: 未知的数据表 %s", errs.ErrBackupContentInvalid, s.Table)
+		}
+		rows, n, err := t.decode(dec)
+		if err != nil {
+			return nil, contentError(err)
+		}
+		if n != s.Rows {
+			return nil, fmt.Errorf("%w: %s 数据批次行数不一致", errs.ErrBackupContentInvalid, s.Table)
+		}
+		if err := checkSealed(rows, ring); err != nil {
+			return nil, err
+		}
+		if tx != nil {
+			if err := t.insert(tx, rows); err != nil {
+				return nil, fmt.Errorf("写入 %s 失败: %w", t.name, err)
+			}
+		}
+		counts[s.Table] += n
+	}
+
+	// 结束段之后不应再有数据，同时读到容器末尾以校验结束块
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return nil, contentError(err)
+	}
+	if err := zr.Close(); err != nil {
+		return nil, contentError(err)
+	}
+
+	summary := &Summary{CreatedAt: m.CreatedAt, KeyIDs: m.KeyIDs}
+	for _, t := range tables {
+		summary.Tables = append(summary.Tables, TableCount{Name: t.name, Rows: counts[t.name]})
+	}
+	return summary, nil
+}
+
+// sealedValues 返回设备组中由密钥环加密的认证密钥，旧版本遗留的明文不包含在内
+func sealedValues(group *entity.DeviceGroup) []string {
+	var values []string
+	for _, v := range []string{group.TOTPSecret, group.OnceKey, group.LastUsedOnceKey, group.AuthSecret} {
+		if keyring.IsSealed(v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// sealedKeyIDs 返回设备组认证密钥使用的全部主密钥ID
+func sealedKeyIDs(tx *gorm.DB) ([]string, error) {
+	var keyIDs []string
+	var batch []entity.DeviceGroup
+	err := tx.Unscoped().Select("id", "totp_secret", "once_key", "last_used_once_key", "auth_secret").
+		FindInBatches(&batch, batchSize, func(*gorm.DB, int) error {
+			for i := range batch {
+				for _, v := range sealedValues(&batch[i]) {
+					id, err := keyring.KeyID(v)
+					if err != nil {
+						return fmt.Errorf("设备组 %d 的认证密钥格式无效: %w", batch[i].ID, err)
+					}
+					if !slices.Contains(keyIDs, id) {
+						keyIDs = append(keyIDs, id)
+					}
+				}
+			}
+			return nil
+		}).Error
+	slices.Sort(keyIDs)
+	return keyIDs, err
+}
+
+// checkSealed 确认 ring 能解开数据批次中每个设备组认证密钥的数据密钥
+func checkSealed(rows interface{}, ring *keyring.Keyring) error {
+	groups, ok := rows.([]entity.DeviceGroup)
+	if !ok {
+		return nil
+	}
+	for i := range groups {
+		for _, v := range sealedValues(&groups[i]) {
+			if err := ring.CheckKey(v); err != nil {
+				return fmt.Errorf("%w: 设备组 %d: %v", errs.ErrBackupKeyringMismatch, groups[i].ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkCounts 核对结束段记录的行数与实际读取的行数
+func checkCounts(want, got map[string]int) error {
+	for _, t := range tables {
+		if want[t.name] != got[t.name] {
+			return fmt.Errorf("%w: %s 应有 %d 行，实际 %d 行", errs.ErrBackupContentInvalid, t.name, want[t.name], got[t.name])
+		}
+	}
+	for name := range want {
+		if _, ok := got[name]; !ok && want[name] != 0 {
+			return fmt.Errorf("%w: 缺少数据表 %s", errs.ErrBackupContentInvalid, name)
+		}
+	}
+	return nil
+}
+
+// contentError 容器层的错误原样返回，其余解码错误视为内容损坏
+func contentError(err error) error {
+	for _, containerErr := range []error{errs.ErrBackupAuthFailed, errs.ErrBackupTruncated, errs.ErrBackupFormatInvalid} {
+		if errors.Is(err, containerErr) {
+			return containerErr
+		}
+	}
+	return fmt.Errorf("%w: %v", errs.ErrBackupContentInvalid, err)
+}
@@ -1,24 +1,35 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
 )
 
 // Config 应用配置结构
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	Database  DatabaseConfig  `mapstructure:"database"`
-	Security  SecurityConfig  `mapstructure:"security"`
-	Log       LogConfig       `mapstructure:"log"`
-	WebSocket WebSocketConfig `mapstructure:"websocket"`
-	HTTP      HTTPConfig      `mapstructure:"http"`
+	Server       ServerConfig       `mapstructure:"server"`
+	Database     DatabaseConfig     `mapstructure:"database"`
+	Security     SecurityConfig     `mapstructure:"security"`
+	Log          LogConfig          `mapstructure:"log"`
+	WebSocket    WebSocketConfig    `mapstructure:"websocket"`
+	HTTP         HTTPConfig         `mapstructure:"http"`
+	Fingerprint  FingerprintConfig  `mapstructure:"fingerprint"`
+	Notification NotificationConfig `mapstructure:"notification"`
+	Admin        AdminConfig        `mapstructure:"admin"`
+	OIDC         OIDCConfig         `mapstructure:"oidc"`
+	RADIUS       RADIUSConfig       `mapstructure:"radius"`
 }
 
 // ServerConfig 服务器配置
@@ -43,7 +54,39 @@ type DatabaseConfig struct {
 
 // SecurityConfig 安全配置
 type SecurityConfig struct {
-	EncryptionKey string `mapstructure:"encryption_key"` // 数据加密密钥
+	EncryptionKey   string `mapstructure:"encryption_key"`    // 数据加密密钥
+	IdentityKey     string `mapstructure:"identity_key"`      // 服务端身份私钥种子（Base64），优先于身份密钥文件
+	IdentityKeyFile string `mapstructure:"identity_key_file"` // 服务端身份私钥文件，不存在时自动生成
+	RecoveryCodeKey string `mapstructure:"recovery_code_key"` // 恢复码摘要的HMAC密钥，修改后已发放的恢复码全部失效，不随其他密钥轮换
+
+	Keyring KeyringConfig `mapstructure:"keyring"` // 设备组认证密钥的静态加密主密钥
+}
+
+// KeyringConfig 静态加密密钥环配置
+type KeyringConfig struct {
+	ActiveKeyID        string   `mapstructure:"active_key_id"`        // 加密新数据使用的主密钥ID
+	Keys               []string `mapstructure:"keys"`                 // 全部主密钥，格式为"主密钥ID:Base64编码的32字节密钥"，轮换后旧主密钥需保留到重新加密完成
+	ReencryptBatchSize int      `mapstructure:"reencrypt_batch_size"` // 后台重新加密每批处理的设备组数
+}
+
+// KeyMap 解析主密钥，返回主密钥ID到密钥的映射
+func (c *KeyringConfig) KeyMap() (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(c.Keys))
+	for _, entry := range c.Keys {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || encoded == "" {
+			return nil, fmt.Errorf("密钥环主密钥格式应为\"主密钥ID:Base64密钥\"")
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("密钥环主密钥ID重复: %s", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("密钥环主密钥 %s 不是有效的Base64", id)
+		}
+		keys[id] = key
+	}
+	return keys, nil
 }
 
 // LogConfig 日志配置
@@ -73,8 +116,17 @@ type WebSocketConfig struct {
 
 	// 连接限制
 	MaxConnections    int           `mapstructure:"max_connections"`    // 最大连接数
-	ConnectionTimeout time.Duration `mapstructure:"connection_timeout"` // 连接超时
+	ConnectionTimeout time.Duration `mapstructure:"connection_timeout"` // 连接超时，未在此时间内完成设备注册的连接将被关闭
+	HandshakeTimeout  time.Duration `mapstructure:"handshake_timeout"`  // 握手超时，未在此时间内完成密钥交换的连接将被关闭
 	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // 心跳间隔
+	ResumeWindow      time.Duration `mapstructure:"resume_window"`      // 会话恢复窗口，断线后在此时间内可凭恢复令牌重连
+
+	// 版本协商
+	MinClientVersion string `mapstructure:"min_client_version"` // 最低客户端版本，为空表示不限制
+
+	// 会话密钥轮换
+	RekeyAfterMessages int           `mapstructure:"rekey_after_messages"` // 每轮密钥最多加密的消息数
+	RekeyInterval      time.Duration `mapstructure:"rekey_interval"`       // 每轮密钥的最长使用时间
 }
 
 // HTTPConfig HTTP服务配置
@@ -84,6 +136,90 @@ type HTTPConfig struct {
 	RequestBodySize string        `mapstructure:"request_body_size"` // 请求体大小限制
 }
 
+// FingerprintConfig 设备硬件指纹配置，相似度取值0-100
+type FingerprintConfig struct {
+	AcceptThreshold int `mapstructure:"accept_threshold"` // 相似度不低于该值时直接接受连接
+	ReviewThreshold int `mapstructure:"review_threshold"` // 相似度不低于该值但低于接受阈值时需要管理员重新激活，低于该值拒绝连接
+}
+
+// AdminConfig 管理后台登录配置
+type AdminConfig struct {
+	LoginTimeout       time.Duration `mapstructure:"login_timeout"`        // 等待U盘确认登录的时间
+	SessionIdleTimeout time.Duration `mapstructure:"session_idle_timeout"` // 会话空闲超时
+	SessionMaxLifetime time.Duration `mapstructure:"session_max_lifetime"` // 会话最长有效期，到期后需重新登录
+	EnableKeyVerify    bool          `mapstructure:"enable_key_verify"`    // 是否提供 /api/v1/admin/verify 校验管理员API密钥，仅兼容旧版本脚本
+}
+
+// OIDCConfig OpenID Connect 提供方配置
+type OIDCConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`          // 是否启用OIDC提供方
+	Issuer         string        `mapstructure:"issuer"`           // 签发者标识，即浏览器和客户端访问服务端使用的根地址，如 https://ukey.example.com
+	SigningKeyFile string        `mapstructure:"signing_key_file"` // ID Token 签名私钥文件（PKCS#8 PEM），不存在时自动生成
+	LoginTimeout   time.Duration `mapstructure:"login_timeout"`    // 等待U盘批准登录的时间
+	CodeTTL        time.Duration `mapstructure:"code_ttl"`         // 授权码有效期
+	TokenTTL       time.Duration `mapstructure:"token_ttl"`        // ID Token 和访问令牌有效期
+}
+
+// RADIUSConfig RADIUS服务配置，供VPN和网络设备把用户名交给U盘做二次认证
+type RADIUSConfig struct {
+	Enabled    bool                 `mapstructure:"enabled"`     // 是否启用RADIUS服务
+	Address    string               `mapstructure:"address"`     // UDP监听地址
+	Action     string               `mapstructure:"action"`      // 认证请求的操作，设备组需具备该权限
+	Timeout    time.Duration        `mapstructure:"timeout"`     // 等待U盘批准的时间，需小于NAS的重试超时，超时后回复Access-Reject
+	MaxPending int                  `mapstructure:"max_pending"` // 同时等待U盘批准的请求数上限，超出的请求直接丢弃
+	Clients    []RADIUSClientConfig `mapstructure:"clients"`     // 允许访问的NAS客户端
+}
+
+// minRADIUSSecretLength 共享密钥最短长度，过短的密钥可被离线猜测
+const minRADIUSSecretLength = 16
+
+// RADIUSClientConfig RADIUS客户端（NAS）配置
+type RADIUSClientConfig struct {
+	Name                             string        `mapstructure:"name"`                                // 客户端名称，显示在U盘确认信息和日志中
+	Address                          string        `mapstructure:"address"`                             // 客户端IP地址或CIDR网段
+	Secret                           string        `mapstructure:"secret"`                              // 共享密钥
+	Action                           string        `mapstructure:"action"`                              // 认证请求的操作，为空时使用全局配置
+	Timeout                          time.Duration `mapstructure:"timeout"`                             // 等待U盘批准的时间，为0时使用全局配置
+	AllowMissingMessageAuthenticator bool          `mapstructure:"allow_missing_message_authenticator"` // 是否接受不带Message-Authenticator的请求，仅用于不支持该属性的旧设备
+}
+
+// NotificationConfig 管理员通知配置
+type NotificationConfig struct {
+	Channels             []NotificationChannelConfig      `mapstructure:"channels"`               // 通知渠道
+	Subscriptions        []NotificationSubscriptionConfig `mapstructure:"subscriptions"`          // 配置文件中定义的订阅，管理后台可另行添加
+	AuthFailureThreshold int                              `mapstructure:"auth_failure_threshold"` // 同一用户在统计窗口内认证失败达到该次数时通知
+	AuthFailureWindow    time.Duration                    `mapstructure:"auth_failure_window"`    // 认证失败统计窗口
+	APIKeyExpiryWarning  time.Duration                    `mapstructure:"apikey_expiry_warning"`  // API密钥在该时间内过期时通知
+	APIKeyCheckInterval  time.Duration                    `mapstructure:"apikey_check_interval"`  // API密钥过期检查周期
+}
+
+// NotificationChannelConfig 通知渠道配置
+type NotificationChannelConfig struct {
+	Name string `mapstructure:"name"` // 渠道名称，订阅通过名称引用
+	Type string `mapstructure:"type"` // 渠道类型: webhook, smtp
+
+	// webhook
+	URL    string `mapstructure:"url"`    // 接收通知的地址
+	Secret string `mapstructure:"secret"` // 请求签名密钥，为空时不签名
+
+	// smtp
+	Host        string   `mapstructure:"host"`
+	Port        int      `mapstructure:"port"`
+	Username    string   `mapstructure:"username"` // 为空时不进行认证
+	Password    string   `mapstructure:"password"`
+	From        string   `mapstructure:"from"`
+	To          []string `mapstructure:"to"`
+	ImplicitTLS bool     `mapstructure:"implicit_tls"` // 直接使用TLS连接（如465端口），否则在服务器支持时使用STARTTLS
+}
+
+// NotificationSubscriptionConfig 通知订阅配置
+type NotificationSubscriptionConfig struct {
+	Channel        string        `mapstructure:"channel"`         // 渠道名称
+	Categories     []string      `mapstructure:"categories"`      // 订阅的通知类别，为空表示全部类别
+	Throttle       time.Duration `mapstructure:"throttle"`        // 同一类别两次即时发送的最小间隔，间隔内的通知合并到下次发送
+	DigestInterval time.Duration `mapstructure:"digest_interval"` // 大于0时按该间隔汇总发送
+}
+
 var GlobalConfig *Config
 
 // InitConfig 初始化配置
@@ -175,7 +311,43 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("websocket.enable_compression", false)
 	v.SetDefault("websocket.max_connections", 1000)
 	v.SetDefault("websocket.connection_timeout", "30s")
+	v.SetDefault("websocket.handshake_timeout", "10s")
 	v.SetDefault("websocket.heartbeat_interval", "30s")
+	v.SetDefault("websocket.resume_window", "2m")
+	v.SetDefault("websocket.min_client_version", "")
+	v.SetDefault("websocket.rekey_after_messages", 1000)
+	v.SetDefault("websocket.rekey_interval", "10m")
+
+	// 硬件指纹默认配置
+	v.SetDefault("fingerprint.accept_threshold", 80)
+	v.SetDefault("fingerprint.review_threshold", 50)
+
+	// 通知默认配置
+	v.SetDefault("notification.auth_failure_threshold", 5)
+	v.SetDefault("notification.auth_failure_window", "10m")
+	v.SetDefault("notification.apikey_expiry_warning", "168h")
+	v.SetDefault("notification.apikey_check_interval", "1h")
+
+	// 管理后台登录默认配置
+	v.SetDefault("admin.login_timeout", "2m")
+	v.SetDefault("admin.session_idle_timeout", "30m")
+	v.SetDefault("admin.session_max_lifetime", "12h")
+	v.SetDefault("admin.enable_key_verify", false)
+
+	// OIDC提供方默认配置
+	v.SetDefault("oidc.enabled", false)
+	v.SetDefault("oidc.issuer", "")
+	v.SetDefault("oidc.signing_key_file", "oidc_signing_key.pem")
+	v.SetDefault("oidc.login_timeout", "2m")
+	v.SetDefault("oidc.code_ttl", "1m")
+	v.SetDefault("oidc.token_ttl", "1h")
+
+	// RADIUS服务默认配置
+	v.SetDefault("radius.enabled", false)
+	v.SetDefault("radius.address", ":1812")
+	v.SetDefault("radius.action", consts.AuthActionRADIUSLogin)
+	v.SetDefault("radius.timeout", "25s")
+	v.SetDefault("radius.max_pending", 100)
 
 	// 日志默认配置
 	v.SetDefault("log.level", "info")
@@ -184,6 +356,12 @@ func setDefaults(v *viper.Viper) {
 
 	// 安全默认配置
 	v.SetDefault("security.encryption_key", "")
+	v.SetDefault("security.identity_key", "")
+	v.SetDefault("security.identity_key_file", "identity.key")
+	v.SetDefault("security.recovery_code_key", "")
+	v.SetDefault("security.keyring.active_key_id", "")
+	v.SetDefault("security.keyring.keys", []string{})
+	v.SetDefault("security.keyring.reencrypt_batch_size", 100)
 }
 
 // GetDatabaseDSN 获取数据库连接字符串
@@ -257,6 +435,25 @@ func (c *Config) Validate() error {
 	if c.Security.EncryptionKey == "" {
 		return fmt.Errorf("加密密钥不能为空")
 	}
+	if c.Security.IdentityKey == "" && c.Security.IdentityKeyFile == "" {
+		return fmt.Errorf("服务端身份密钥和身份密钥文件不能同时为空")
+	}
+	if c.Security.RecoveryCodeKey == "" {
+		return fmt.Errorf("恢复码密钥不能为空")
+	}
+	if c.Security.Keyring.ActiveKeyID == "" {
+		return fmt.Errorf("密钥环当前主密钥ID不能为空")
+	}
+	keys, err := c.Security.Keyring.KeyMap()
+	if err != nil {
+		return err
+	}
+	if _, ok := keys[c.Security.Keyring.ActiveKeyID]; !ok {
+		return fmt.Errorf("密钥环中没有当前主密钥: %s", c.Security.Keyring.ActiveKeyID)
+	}
+	if c.Security.Keyring.ReencryptBatchSize <= 0 {
+		return fmt.Errorf("密钥环重新加密批大小必须大于0")
+	}
 
 	// 验证HTTP配置
 	if c.HTTP.RequestTimeout <= 0 {
@@ -300,9 +497,197 @@ func (c *Config) Validate() error {
 	if c.WebSocket.ConnectionTimeout <= 0 {
 		return fmt.Errorf("WebSocket连接超时时间必须大于0")
 	}
+	if c.WebSocket.HandshakeTimeout <= 0 {
+		return fmt.Errorf("WebSocket握手超时时间必须大于0")
+	}
+	if c.WebSocket.HandshakeTimeout > c.WebSocket.ConnectionTimeout {
+		return fmt.Errorf("WebSocket握手超时时间不能大于连接超时时间")
+	}
 	if c.WebSocket.HeartbeatInterval <= 0 {
 		return fmt.Errorf("WebSocket心跳间隔必须大于0")
 	}
+	if c.WebSocket.ResumeWindow <= 0 {
+		return fmt.Errorf("WebSocket会话恢复窗口必须大于0")
+	}
+	if c.WebSocket.MinClientVersion != "" {
+		if err := messages.ValidateVersion(c.WebSocket.MinClientVersion); err != nil {
+			return fmt.Errorf("WebSocket最低客户端版本格式无效: %s", c.WebSocket.MinClientVersion)
+		}
+	}
+	if c.WebSocket.RekeyAfterMessages <= 0 {
+		return fmt.Errorf("WebSocket密钥轮换消息数必须大于0")
+	}
+	if c.WebSocket.RekeyInterval <= 0 {
+		return fmt.Errorf("WebSocket密钥轮换间隔必须大于0")
+	}
+
+	// 验证硬件指纹配置
+	if c.Fingerprint.AcceptThreshold < 0 || c.Fingerprint.AcceptThreshold > 100 {
+		return fmt.Errorf("硬件指纹接受阈值必须在0-100范围内")
+	}
+	if c.Fingerprint.ReviewThreshold < 0 || c.Fingerprint.ReviewThreshold > c.Fingerprint.AcceptThreshold {
+		return fmt.Errorf("硬件指纹复核阈值必须在0到接受阈值之间")
+	}
+
+	// 验证通知配置
+	if err := c.Notification.Validate(); err != nil {
+		return err
+	}
+
+	// 验证管理后台登录配置
+	if c.Admin.LoginTimeout <= 0 {
+		return fmt.Errorf("管理后台登录等待时间必须大于0")
+	}
+	if c.Admin.SessionIdleTimeout <= 0 {
+		return fmt.Errorf("管理后台会话空闲超时必须大于0")
+	}
+	if c.Admin.SessionMaxLifetime < c.Admin.SessionIdleTimeout {
+		return fmt.Errorf("管理后台会话最长有效期不能小于空闲超时")
+	}
+
+	// 验证OIDC配置
+	if c.OIDC.Enabled {
+		if err := c.OIDC.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// 验证RADIUS配置
+	if c.RADIUS.Enabled {
+		if err := c.RADIUS.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate 验证RADIUS配置有效性
+func (c *RADIUSConfig) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("RADIUS监听地址不能为空")
+	}
+	if c.Timeout < time.Second {
+		return fmt.Errorf("RADIUS等待时间不能小于1秒")
+	}
+	if c.MaxPending <= 0 {
+		return fmt.Errorf("RADIUS等待中的请求数上限必须大于0")
+	}
+	if len(c.Clients) == 0 {
+		return fmt.Errorf("RADIUS至少需要配置一个客户端")
+	}
+
+	names := make(map[string]bool, len(c.Clients))
+	for _, client := range c.Clients {
+		if client.Name == "" {
+			return fmt.Errorf("RADIUS客户端名称不能为空")
+		}
+		if names[client.Name] {
+			return fmt.Errorf("RADIUS客户端名称重复: %s", client.Name)
+		}
+		names[client.Name] = true
+
+		if _, err := client.Network(); err != nil {
+			return fmt.Errorf("RADIUS客户端 %s 的地址无效: %s", client.Name, client.Address)
+		}
+		if len(client.Secret) < minRADIUSSecretLength {
+			return fmt.Errorf("RADIUS客户端 %s 的共享密钥至少需要%d个字符", client.Name, minRADIUSSecretLength)
+		}
+		if client.Timeout != 0 && client.Timeout < time.Second {
+			return fmt.Errorf("RADIUS客户端 %s 的等待时间不能小于1秒", client.Name)
+		}
+	}
+	return nil
+}
+
+// Network 解析客户端地址，单个IP视为只包含该地址的网段
+func (c *RADIUSClientConfig) Network() (*net.IPNet, error) {
+	if strings.Contains(c.Address, "/") {
+		_, network, err := net.ParseCIDR(c.Address)
+		return network, err
+	}
+	ip := net.ParseIP(c.Address)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的IP地址: %s", c.Address)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+}
+
+// Validate 验证OIDC配置有效性
+func (c *OIDCConfig) Validate() error {
+	issuer, err := url.Parse(c.Issuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" ||
+		issuer.RawQuery != "" || issuer.Fragment != "" || strings.HasSuffix(c.Issuer, "/") {
+		return fmt.Errorf("OIDC签发者必须是不以/结尾、不含查询参数的http(s)地址")
+	}
+	if c.SigningKeyFile == "" {
+		return fmt.Errorf("OIDC签名密钥文件不能为空")
+	}
+	if c.LoginTimeout <= 0 {
+		return fmt.Errorf("OIDC登录等待时间必须大于0")
+	}
+	if c.CodeTTL <= 0 {
+		return fmt.Errorf("OIDC授权码有效期必须大于0")
+	}
+	if c.TokenTTL <= 0 {
+		return fmt.Errorf("OIDC令牌有效期必须大于0")
+	}
+	return nil
+}
+
+// Validate 验证通知配置有效性
+func (c *NotificationConfig) Validate() error {
+	if c.AuthFailureThreshold <= 0 {
+		return fmt.Errorf("认证失败通知阈值必须大于0")
+	}
+	if c.AuthFailureWindow <= 0 {
+		return fmt.Errorf("认证失败统计窗口必须大于0")
+	}
+	if c.APIKeyExpiryWarning <= 0 {
+		return fmt.Errorf("API密钥过期提醒时间必须大于0")
+	}
+	if c.APIKeyCheckInterval <= 0 {
+		return fmt.Errorf("API密钥过期检查周期必须大于0")
+	}
+
+	names := make(map[string]bool, len(c.Channels))
+	for _, ch := range c.Channels {
+		if ch.Name == "" {
+			return fmt.Errorf("通知渠道名称不能为空")
+		}
+		if names[ch.Name] {
+			return fmt.Errorf("通知渠道名称重复: %s", ch.Name)
+		}
+		names[ch.Name] = true
+
+		switch ch.Type {
+		case "webhook":
+			if ch.URL == "" {
+				return fmt.Errorf("通知渠道 %s 的地址不能为空", ch.Name)
+			}
+		case "smtp":
+			if ch.Host == "" || ch.Port <= 0 || ch.Port > 65535 {
+				return fmt.Errorf("通知渠道 %s 的SMTP服务器地址无效", ch.Name)
+			}
+			if ch.From == "" || len(ch.To) == 0 {
+				return fmt.Errorf("通知渠道 %s 的发件人和收件人不能为空", ch.Name)
+			}
+		default:
+			return fmt.Errorf("通知渠道 %s 的类型无效: %s", ch.Name, ch.Type)
+		}
+	}
+
+	for _, sub := range c.Subscriptions {
+		if !names[sub.Channel] {
+			return fmt.Errorf("通知订阅引用的渠道不存在: %s", sub.Channel)
+		}
+		if sub.Throttle < 0 || sub.DigestInterval < 0 {
+			return fmt.Errorf("通知订阅的节流和摘要间隔不能为负数")
+		}
+	}
 
 	return nil
 }
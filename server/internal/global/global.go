@@ -4,6 +4,10 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/shared/pkg/identity"
+	"github.com/hang666/EasyUKey/shared/pkg/keyring"
+	"github.com/hang666/EasyUKey/shared/pkg/notify"
+	"github.com/hang666/EasyUKey/shared/pkg/oidc"
 )
 
 var (
@@ -12,4 +16,16 @@ var (
 
 	// Config 全局配置
 	Config *config.Config
+
+	// ServerIdentity 服务端长期身份密钥，用于密钥交换签名
+	ServerIdentity *identity.ServerIdentity
+
+	// Keyring 设备组认证密钥的静态加密密钥环
+	Keyring *keyring.Keyring
+
+	// Notifier 管理员通知分发器
+	Notifier *notify.Dispatcher
+
+	// OIDCSigningKey OIDC ID Token 签名密钥，未启用OIDC提供方时为空
+	OIDCSigningKey *oidc.SigningKey
 )
@@ -85,6 +85,15 @@ func AutoMigrate() error {
 		&entity.DeviceGroup{},
 		&entity.AuthSession{},
 		&entity.APIKey{},
+		&entity.RecoveryCode{},
+		&entity.SecurityEvent{},
+		&entity.NotificationSubscription{},
+		&entity.EventSubscription{},
+		&entity.AdminAccount{},
+		&entity.AdminSession{},
+		&entity.AdminAuditLog{},
+		&entity.OIDCClient{},
+		&entity.OIDCAuthorization{},
 	}
 
 	// 执行自动迁移
@@ -94,6 +103,15 @@ func AutoMigrate() error {
 		}
 	}
 
+	// 认证密钥改为加密存储后，旧版本在密钥列上建立的索引不再使用
+	for _, index := range []string{"idx_device_groups_totp_secret", "idx_device_groups_once_key", "idx_device_groups_last_used_once_key"} {
+		if global.DB.Migrator().HasIndex(&entity.DeviceGroup{}, index) {
+			if err := global.DB.Migrator().DropIndex(&entity.DeviceGroup{}, index); err != nil {
+				return fmt.Errorf("删除索引失败 %s: %w", index, err)
+			}
+		}
+	}
+
 	logger.Logger.Info("数据库表结构迁移完成")
 	return nil
 }
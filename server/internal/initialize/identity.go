@@ -0,0 +1,42 @@
+package initialize
+
+import (
+	"fmt"
+
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/shared/pkg/identity"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+// InitServerIdentity 加载服务端身份密钥，密钥文件不存在时自动生成
+func InitServerIdentity(cfg *config.SecurityConfig) error {
+	var (
+		serverIdentity *identity.ServerIdentity
+		created        bool
+		err            error
+	)
+
+	if cfg.IdentityKey != "" {
+		serverIdentity, err = identity.ParseServerIdentity(cfg.IdentityKey)
+	} else {
+		serverIdentity, created, err = identity.LoadOrCreateServerIdentity(cfg.IdentityKeyFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	global.ServerIdentity = serverIdentity
+
+	if created {
+		// 在命令行输出新生成的身份公钥
+		fmt.Printf("🔐 系统已自动生成服务端身份密钥：%s\n", cfg.IdentityKeyFile)
+		fmt.Printf("📋 身份公钥: %s\n", serverIdentity.PublicKeyBase64())
+		fmt.Printf("💡 使用说明：\n")
+		fmt.Printf("   - 编译客户端时通过 SERVER_PUBLIC_KEY 嵌入此公钥，客户端据此验证服务端身份\n")
+		fmt.Printf("   - 请备份身份密钥文件，更换身份密钥后所有客户端都需要重新编译\n")
+	}
+
+	logger.Logger.Info("服务端身份密钥已加载", "public_key", serverIdentity.PublicKeyBase64())
+	return nil
+}
@@ -10,40 +10,97 @@ import (
 
 // InitAll 初始化所有组件
 func InitAll(configPath string) error {
-	// 1. 初始化配置
-	if err := config.InitConfig(configPath); err != nil {
-		return fmt.Errorf("配置初始化失败: %w", err)
+	// 1. 初始化配置和日志系统
+	if err := initConfigAndLogger(configPath); err != nil {
+		return err
 	}
-	global.Config = config.GlobalConfig
 
-	// 2. 初始化日志系统
-	loggerConfig := &logger.LogConfig{
-		Level:   global.Config.Log.Level,
-		Format:  global.Config.Log.Format,
-		Output:  global.Config.Log.Output,
-		Console: "true", // 默认启用控制台输出
+	// 2. 加载服务端身份密钥
+	if err := InitServerIdentity(&global.Config.Security); err != nil {
+		return fmt.Errorf("服务端身份密钥初始化失败: %w", err)
 	}
-	log, err := logger.InitLogger(loggerConfig)
-	if err != nil {
-		return fmt.Errorf("日志系统初始化失败: %w", err)
+
+	// 3. 加载静态加密密钥环
+	if err := InitKeyring(&global.Config.Security.Keyring); err != nil {
+		return fmt.Errorf("密钥环初始化失败: %w", err)
 	}
-	_ = log // 日志实例已设置为全局变量
 
-	// 3. 初始化数据库连接
+	// 4. 加载OIDC签名密钥
+	if global.Config.OIDC.Enabled {
+		if err := InitOIDC(&global.Config.OIDC); err != nil {
+			return fmt.Errorf("OIDC签名密钥初始化失败: %w", err)
+		}
+	}
+
+	// 5. 初始化数据库连接
 	if err := InitDatabase(&global.Config.Database); err != nil {
 		return fmt.Errorf("数据库初始化失败: %w", err)
 	}
 
-	// 4. 自动迁移数据库表结构
+	// 6. 自动迁移数据库表结构
 	if err := AutoMigrate(); err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
 	}
 
-	// 5. 创建默认数据
+	// 7. 创建默认数据
 	if err := CreateDefaultData(); err != nil {
 		return fmt.Errorf("创建默认数据失败: %w", err)
 	}
 
+	// 8. 初始化管理员通知
+	InitNotifier(&global.Config.Notification)
+
 	logger.Logger.Info("服务器初始化完成")
 	return nil
 }
+
+// InitForMaintenance 初始化备份、恢复等维护命令所需的组件，不加载身份密钥也不创建默认数据
+func InitForMaintenance(configPath string) error {
+	if err := InitForVerify(configPath); err != nil {
+		return err
+	}
+	return InitMaintenanceDatabase()
+}
+
+// InitForVerify 初始化校验备份所需的配置、日志和密钥环，不连接数据库
+func InitForVerify(configPath string) error {
+	if err := initConfigAndLogger(configPath); err != nil {
+		return err
+	}
+	if err := InitKeyring(&global.Config.Security.Keyring); err != nil {
+		return fmt.Errorf("密钥环初始化失败: %w", err)
+	}
+	return nil
+}
+
+// InitMaintenanceDatabase 连接数据库并迁移表结构，需在 InitForVerify 之后调用
+func InitMaintenanceDatabase() error {
+	if err := InitDatabase(&global.Config.Database); err != nil {
+		return fmt.Errorf("数据库初始化失败: %w", err)
+	}
+	if err := AutoMigrate(); err != nil {
+		return fmt.Errorf("数据库迁移失败: %w", err)
+	}
+	return nil
+}
+
+// initConfigAndLogger 加载配置并初始化日志系统
+func initConfigAndLogger(configPath string) error {
+	if err := config.InitConfig(configPath); err != nil {
+		return fmt.Errorf("配置初始化失败: %w", err)
+	}
+	global.Config = config.GlobalConfig
+
+	loggerConfig := &logger.LogConfig{
+		Level:   global.Config.Log.Level,
+		Format:  global.Config.Log.Format,
+		Output:  global.Config.Log.Output,
+		Console: "true", // 默认启用控制台输出
+	}
+	log, err := logger.InitLogger(loggerConfig)
+	if err != nil {
+		return fmt.Errorf("日志系统初始化失败: %w", err)
+	}
+	_ = log // 日志实例已设置为全局变量
+	return nil
+}
@@ -0,0 +1,24 @@
+package initialize
+
+import (
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/shared/pkg/keyring"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+// InitKeyring 加载设备组认证密钥的静态加密密钥环
+func InitKeyring(cfg *config.KeyringConfig) error {
+	keys, err := cfg.KeyMap()
+	if err != nil {
+		return err
+	}
+	ring, err := keyring.New(cfg.ActiveKeyID, keys)
+	if err != nil {
+		return err
+	}
+
+	global.Keyring = ring
+	logger.Logger.Info("密钥环已加载", "active_key_id", cfg.ActiveKeyID, "keys", len(keys))
+	return nil
+}
@@ -0,0 +1,38 @@
+package initialize
+
+import (
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/notify"
+)
+
+// InitNotifier 按配置创建通知渠道和分发器，订阅在服务启动时加载
+func InitNotifier(cfg *config.NotificationConfig) {
+	channels := make(map[string]notify.Channel, len(cfg.Channels))
+	for _, ch := range cfg.Channels {
+		switch ch.Type {
+		case "webhook":
+			channels[ch.Name] = &notify.WebhookChannel{URL: ch.URL, Secret: ch.Secret}
+		case "smtp":
+			channels[ch.Name] = &notify.SMTPChannel{
+				Host:        ch.Host,
+				Port:        ch.Port,
+				Username:    ch.Username,
+				Password:    ch.Password,
+				From:        ch.From,
+				To:          ch.To,
+				ImplicitTLS: ch.ImplicitTLS,
+			}
+		}
+	}
+
+	dispatcher := notify.NewDispatcher()
+	dispatcher.SetChannels(channels)
+	dispatcher.OnError = func(channel string, err error) {
+		logger.Logger.Error("发送管理员通知失败", "channel", channel, "error", err)
+	}
+
+	global.Notifier = dispatcher
+	logger.Logger.Info("管理员通知已初始化", "channels", len(channels))
+}
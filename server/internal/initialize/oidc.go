@@ -0,0 +1,28 @@
+package initialize
+
+import (
+	"fmt"
+
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/oidc"
+)
+
+// InitOIDC 加载OIDC ID Token 签名密钥，密钥文件不存在时自动生成
+func InitOIDC(cfg *config.OIDCConfig) error {
+	key, created, err := oidc.LoadOrCreateSigningKey(cfg.SigningKeyFile)
+	if err != nil {
+		return err
+	}
+
+	global.OIDCSigningKey = key
+
+	if created {
+		fmt.Printf("🔐 系统已自动生成OIDC签名密钥：%s\n", cfg.SigningKeyFile)
+		fmt.Printf("💡 请备份签名密钥文件，更换后客户端需重新获取JWKS，已签发的ID Token将无法验证\n")
+	}
+
+	logger.Logger.Info("OIDC提供方已启用", "issuer", cfg.Issuer, "kid", key.KeyID())
+	return nil
+}
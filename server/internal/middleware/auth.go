@@ -1,12 +1,16 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/server/internal/service"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
 )
 
 // APIAuth 统一API身份验证中间件
@@ -65,7 +69,126 @@ func APIAuth(requireAdmin bool) echo.MiddlewareFunc {
 	}
 }
 
-// AdminAuth 管理员身份验证中间件（保持向后兼容）
+// AdminSessionCookie 管理后台会话Cookie名称
+const AdminSessionCookie = "easyukey_admin_session"
+
+// AdminAuth 管理员身份验证中间件：浏览器使用管理后台会话Cookie，SDK和脚本使用管理员API密钥
 func AdminAuth() echo.MiddlewareFunc {
-	return APIAuth(true)
+	apiKeyAuth := APIAuth(true)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withAPIKey := apiKeyAuth(next)
+		return func(c echo.Context) error {
+			// 携带API密钥的请求（SDK、脚本）按原方式验证，浏览器发出的请求不接受API密钥
+			if c.Request().Header.Get("X-API-Key") != "" {
+				if isBrowserRequest(c) {
+					return c.JSON(http.StatusForbidden, map[string]interface{}{
+						"error": map[string]interface{}{
+							"code":    "API_KEY_NOT_ALLOWED",
+							"message": "浏览器请求不能使用管理员API密钥，请登录管理后台",
+						},
+					})
+				}
+				return withAPIKey(c)
+			}
+
+			cookie, err := c.Cookie(AdminSessionCookie)
+			if err != nil || cookie.Value == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+					"error": map[string]interface{}{
+						"code":    "MISSING_ADMIN_SESSION",
+						"message": "请先登录管理后台",
+					},
+				})
+			}
+
+			// Cookie认证的修改请求必须来自同源页面，防止跨站请求伪造
+			if !isSafeMethod(c.Request().Method) && !isSameOrigin(c) {
+				return c.JSON(http.StatusForbidden, map[string]interface{}{
+					"error": map[string]interface{}{
+						"code":    "INVALID_ORIGIN",
+						"message": "请求来源无效",
+					},
+				})
+			}
+
+			account, err := service.ValidateAdminSession(cookie.Value)
+			if err != nil {
+				if errors.Is(err, errs.ErrAdminSessionInvalid) {
+					return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+						"error": map[string]interface{}{
+							"code":    "INVALID_ADMIN_SESSION",
+							"message": err.Error(),
+						},
+					})
+				}
+				return err
+			}
+
+			// 将管理员账号存储在上下文中
+			c.Set("admin_account", account)
+
+			return next(c)
+		}
+	}
+}
+
+// AdminAudit 记录管理员修改操作的审计日志，需放在 AdminAuth 之后
+func AdminAudit() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+
+			req := c.Request()
+			if isSafeMethod(req.Method) {
+				return err
+			}
+
+			status := c.Response().Status
+			if err != nil {
+				status = getHTTPStatus(err)
+			}
+			entry := &entity.AdminAuditLog{
+				Method:     req.Method,
+				Path:       req.URL.Path,
+				StatusCode: status,
+				ClientIP:   c.RealIP(),
+			}
+			if account, ok := c.Get("admin_account").(*entity.AdminAccount); ok {
+				entry.AdminAccountID = &account.ID
+				if account.User != nil {
+					entry.Actor = account.User.Username
+				}
+			} else if key, ok := c.Get("api_key").(*entity.APIKey); ok {
+				entry.APIKeyID = &key.ID
+				entry.Actor = key.Name
+			}
+			service.RecordAdminAudit(entry)
+
+			return err
+		}
+	}
+}
+
+// isSafeMethod 判断是否为只读请求方法
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isBrowserRequest 判断请求是否由浏览器发出，浏览器的跨域和修改请求携带 Origin，现代浏览器的所有请求携带 Sec-Fetch-* 头
+func isBrowserRequest(c echo.Context) bool {
+	header := c.Request().Header
+	return header.Get(echo.HeaderOrigin) != "" || header.Get("Sec-Fetch-Mode") != "" || header.Get("Sec-Fetch-Site") != ""
+}
+
+// isSameOrigin 判断请求的 Origin 是否与当前站点一致
+func isSameOrigin(c echo.Context) bool {
+	origin := c.Request().Header.Get(echo.HeaderOrigin)
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == c.Request().Host
 }
@@ -30,15 +30,43 @@ var httpStatusMap = map[error]int{
 	errs.ErrDeviceGroupNotActive:   400,
 	errs.ErrDeviceGroupNameEmpty:   400,
 	errs.ErrDeviceGroupPermissions: 400,
+	errs.ErrRecoveryCodeInvalid:    400,
 	errs.ErrUserAlreadyExists:      400,
 	errs.ErrSessionExpired:         400,
 	errs.ErrSessionCompleted:       400,
 
+	errs.ErrNotificationCategoryInvalid:     400,
+	errs.ErrNotificationSubscriptionInvalid: 400,
+
+	errs.ErrEventSubscriptionURLInvalid: 400,
+	errs.ErrEventSubscriptionLimit:      400,
+	errs.ErrEventTypeInvalid:            400,
+
+	errs.ErrAdminAccountExists: 400,
+
+	errs.ErrSessionTimeRangeInvalid: 400,
+	errs.ErrSessionSortInvalid:      400,
+	errs.ErrExportFormatInvalid:     400,
+	errs.ErrSessionFilterInvalid:    400,
+
+	errs.ErrBulkFormatInvalid:    400,
+	errs.ErrBulkTooManyRows:      400,
+	errs.ErrBulkValidationFailed: 400,
+
+	errs.ErrOIDCClientAPIKeyInvalid: 400,
+	errs.ErrOIDCRedirectURIInvalid:  400,
+
 	// 401 Unauthorized
 	errs.ErrAPIKeyInvalid: 401,
 
+	errs.ErrAdminSessionInvalid: 401,
+	errs.ErrAdminLoginFailed:    401,
+
+	errs.ErrOIDCAuthorizationInvalid: 401,
+
 	// 403 Forbidden
-	errs.ErrPermissionDenied: 403,
+	errs.ErrPermissionDenied:       403,
+	errs.ErrDeviceGroupQuarantined: 403,
 
 	// 404 Not Found
 	errs.ErrUserNotFound:        404,
@@ -46,6 +74,18 @@ var httpStatusMap = map[error]int{
 	errs.ErrDeviceGroupNotFound: 404,
 	errs.ErrSessionNotFound:     404,
 
+	errs.ErrNotificationChannelNotFound:      404,
+	errs.ErrNotificationSubscriptionNotFound: 404,
+
+	errs.ErrEventSubscriptionNotFound: 404,
+
+	errs.ErrAdminAccountNotFound: 404,
+
+	errs.ErrOIDCClientNotFound: 404,
+
+	// 429 Too Many Requests
+	errs.ErrTooManyAttempts: 429,
+
 	// 503 Service Unavailable
 	errs.ErrUserNotOnline: 503,
 }
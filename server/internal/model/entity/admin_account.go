@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// AdminAccount 管理员账号: 关联到用户，通过用户U盘确认登录管理后台
+type AdminAccount struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;uniqueIndex" json:"user_id"` // 关联的用户
+	IsActive    bool       `gorm:"default:true" json:"is_active"`       // 是否允许登录
+	LastLoginAt *time.Time `json:"last_login_at"`                       // 最近一次登录时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TableName 指定表名
+func (AdminAccount) TableName() string {
+	return "admin_accounts"
+}
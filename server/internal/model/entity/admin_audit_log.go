@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// AdminAuditLog 管理操作审计日志: 记录每次修改操作由哪个管理员账号或管理员API密钥执行
+type AdminAuditLog struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	AdminAccountID *uint     `gorm:"index" json:"admin_account_id"`     // 通过管理后台会话操作时的管理员账号
+	APIKeyID       *uint     `gorm:"index" json:"api_key_id"`           // 通过管理员API密钥操作时的密钥
+	Actor          string    `gorm:"type:varchar(255)" json:"actor"`    // 管理员用户名或API密钥名称
+	Method         string    `gorm:"type:varchar(10)" json:"method"`    // 请求方法
+	Path           string    `gorm:"type:varchar(255)" json:"path"`     // 请求路径
+	StatusCode     int       `json:"status_code"`                       // 响应状态码
+	ClientIP       string    `gorm:"type:varchar(45)" json:"client_ip"` // 客户端IP
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
+}
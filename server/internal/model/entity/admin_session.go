@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// AdminSession 管理后台会话: 浏览器Cookie中保存令牌，数据库只保存令牌摘要
+type AdminSession struct {
+	ID             string    `gorm:"primaryKey;type:varchar(64)" json:"-"`     // 会话令牌的SHA-256摘要
+	AdminAccountID uint      `gorm:"not null;index" json:"admin_account_id"`   // 管理员账号
+	AuthSessionID  string    `gorm:"type:varchar(255)" json:"auth_session_id"` // 登录时发起的U盘认证会话
+	Status         string    `gorm:"not null;type:varchar(20)" json:"status"`  // 会话状态：pending 等待U盘确认, active 已登录
+	ClientIP       string    `gorm:"type:varchar(45)" json:"client_ip"`        // 登录IP
+	UserAgent      string    `gorm:"type:varchar(255)" json:"user_agent"`      // 登录浏览器
+	LastActiveAt   time.Time `json:"last_active_at"`                           // 最近一次请求时间，用于空闲超时
+	ExpiresAt      time.Time `gorm:"index" json:"expires_at"`                  // 过期时间，等待确认时为登录截止时间
+	CreatedAt      time.Time `json:"created_at"`
+
+	// 关联关系
+	AdminAccount *AdminAccount `gorm:"foreignKey:AdminAccountID" json:"admin_account,omitempty"`
+}
+
+// TableName 指定表名
+func (AdminSession) TableName() string {
+	return "admin_sessions"
+}
@@ -10,10 +10,11 @@ import (
 type AuthSession struct {
 	ID                 string         `gorm:"primaryKey;type:varchar(255)" json:"id"`      // UUID
 	UserID             uint           `gorm:"not null" json:"user_id"`                     // 发起认证的用户ID
-	APIKeyID           uint           `gorm:"not null" json:"api_key_id"`                  // 调用认证的API密钥ID
+	APIKeyID           *uint          `json:"api_key_id"`                                  // 调用认证的API密钥ID，管理后台登录时为空
 	RespondingDeviceID *uint          `json:"responding_device_id"`                        // 最终响应本次认证的设备主键 (Device.ID)
 	Challenge          string         `gorm:"not null;type:varchar(255)" json:"challenge"` // 挑战码
 	Action             string         `gorm:"type:varchar(255)" json:"action"`             // 本次认证请求的操作/权限
+	Message            string         `gorm:"type:text" json:"message"`                    // 展示给用户的认证说明，设备重连后重新下发请求时使用
 	Status             string         `gorm:"not null;type:varchar(50)" json:"status"`     // 认证状态：pending, processing, processing_oncekey, completed, failed, expired, rejected
 	Result             string         `gorm:"type:varchar(50)" json:"result"`              // 认证结果：success, failure
 	CallbackURL        string         `gorm:"type:text" json:"callback_url"`               // 回调URL
@@ -4,27 +4,33 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
 )
 
 // Device 设备
 type Device struct {
-	ID                 uint           `gorm:"primaryKey" json:"id"`                                                                 // 内部主键，在系统中被称为 DeviceID
-	DeviceGroupID      *uint          `gorm:"index" json:"device_group_id"`                                                         // 外键，关联到DeviceGroup模型
-	Name               string         `gorm:"not null;type:varchar(255)" json:"name"`                                               // 用户为设备设置的别名, 如 "我的主力UKey"
-	SerialNumber       string         `gorm:"not null;type:varchar(255);uniqueIndex:idx_device_serial" json:"serial_number"`        // 硬件序列号 (来自客户端)
-	VolumeSerialNumber string         `gorm:"not null;type:varchar(255);uniqueIndex:idx_device_serial" json:"volume_serial_number"` // 卷序列号 (来自客户端)
-	Vendor             string         `gorm:"type:varchar(255)" json:"vendor"`                                                      // 设备厂商 (来自客户端)
-	Model              string         `gorm:"type:varchar(255)" json:"model"`                                                       // 设备型号 (来自客户端)
-	Remark             string         `gorm:"type:text" json:"remark"`                                                              // 设备备注，如"跨平台自动识别"
-	IsActive           bool           `gorm:"default:false" json:"is_active"`                                                       // 设备是否激活（管理状态）
-	IsOnline           bool           `gorm:"default:false" json:"is_online"`                                                       // 设备是否在线（实时状态）
-	LastHeartbeat      *time.Time     `gorm:"index" json:"last_heartbeat"`                                                          // 最后心跳时间
-	LastOnlineAt       *time.Time     `json:"last_online_at"`                                                                       // 最后上线时间
-	LastOfflineAt      *time.Time     `json:"last_offline_at"`                                                                      // 最后离线时间
-	HeartbeatInterval  int            `gorm:"default:30" json:"heartbeat_interval"`                                                 // 心跳间隔（秒）
-	CreatedAt          time.Time      `json:"created_at"`
-	UpdatedAt          time.Time      `json:"updated_at"`
-	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID                        uint                        `gorm:"primaryKey" json:"id"`                                                                 // 内部主键，在系统中被称为 DeviceID
+	DeviceGroupID             *uint                       `gorm:"index" json:"device_group_id"`                                                         // 外键，关联到DeviceGroup模型
+	Name                      string                      `gorm:"not null;type:varchar(255)" json:"name"`                                               // 用户为设备设置的别名, 如 "我的主力UKey"
+	SerialNumber              string                      `gorm:"not null;type:varchar(255);uniqueIndex:idx_device_serial" json:"serial_number"`        // 硬件序列号 (来自客户端)
+	VolumeSerialNumber        string                      `gorm:"not null;type:varchar(255);uniqueIndex:idx_device_serial" json:"volume_serial_number"` // 卷序列号 (来自客户端)
+	Vendor                    string                      `gorm:"type:varchar(255)" json:"vendor"`                                                      // 设备厂商 (来自客户端)
+	Model                     string                      `gorm:"type:varchar(255)" json:"model"`                                                       // 设备型号 (来自客户端)
+	Remark                    string                      `gorm:"type:text" json:"remark"`                                                              // 设备备注，如"跨平台自动识别"
+	IsActive                  bool                        `gorm:"default:false" json:"is_active"`                                                       // 设备是否激活（管理状态）
+	IsOnline                  bool                        `gorm:"default:false" json:"is_online"`                                                       // 设备是否在线（实时状态）
+	LastHeartbeat             *time.Time                  `gorm:"index" json:"last_heartbeat"`                                                          // 最后心跳时间
+	LastOnlineAt              *time.Time                  `json:"last_online_at"`                                                                       // 最后上线时间
+	LastOfflineAt             *time.Time                  `json:"last_offline_at"`                                                                      // 最后离线时间
+	HeartbeatInterval         int                         `gorm:"default:30" json:"heartbeat_interval"`                                                 // 心跳间隔（秒）
+	Fingerprint               *messages.DeviceFingerprint `gorm:"type:json;serializer:json" json:"fingerprint,omitempty"`                               // 记录的硬件指纹
+	PendingFingerprint        *messages.DeviceFingerprint `gorm:"type:json;serializer:json" json:"pending_fingerprint,omitempty"`                       // 待管理员确认的硬件指纹，重新激活后替换记录的指纹
+	PendingVolumeSerialNumber string                      `gorm:"type:varchar(255)" json:"pending_volume_serial_number,omitempty"`                      // 与待确认指纹一同上报的新卷序列号，重新激活后替换记录的卷序列号
+	FingerprintScore          int                         `gorm:"default:0" json:"fingerprint_score"`                                                   // 最近一次连接的指纹相似度（0-100）
+	CreatedAt                 time.Time                   `json:"created_at"`
+	UpdatedAt                 time.Time                   `json:"updated_at"`
+	DeletedAt                 gorm.DeletedAt              `gorm:"index" json:"deleted_at,omitempty"`
 
 	// 关联关系
 	DeviceGroup  *DeviceGroup  `gorm:"foreignKey:DeviceGroupID;constraint:OnDelete:SET NULL" json:"device_group,omitempty"`
@@ -14,10 +14,20 @@ type DeviceGroup struct {
 	Description string   `gorm:"type:text" json:"description"`                 // 设备组描述
 	Permissions []string `gorm:"type:json;serializer:json" json:"permissions"` // JSON存储权限列表
 
-	// 认证密钥统一管理
-	TOTPSecret      string `gorm:"not null;type:varchar(500);index" json:"-"` // TOTP密钥
-	OnceKey         string `gorm:"not null;type:varchar(255);index" json:"-"` // 当前有效的一次性密钥
-	LastUsedOnceKey string `gorm:"type:varchar(255);index" json:"-"`          // 上次使用的一次性密钥
+	// 认证密钥统一管理，密钥使用密钥环加密存储，按值查询一次性密钥时使用索引列
+	TOTPSecret          string `gorm:"not null;type:varchar(500)" json:"-"`            // TOTP密钥（加密）
+	OnceKey             string `gorm:"not null;type:varchar(255)" json:"-"`            // 当前有效的一次性密钥（加密）
+	OnceKeyHash         string `gorm:"type:varchar(64);index" json:"-"`                // 当前一次性密钥的索引
+	LastUsedOnceKey     string `gorm:"type:varchar(255)" json:"-"`                     // 上次使用的一次性密钥（加密）
+	LastUsedOnceKeyHash string `gorm:"type:varchar(64);index" json:"-"`                // 上次使用的一次性密钥的索引
+	OnceKeyPending      bool   `gorm:"default:false" json:"-"`                         // 新的一次性密钥已下发但设备尚未确认保存
+	AuthSecret          string `gorm:"not null;default:'';type:varchar(255)" json:"-"` // 认证token的HMAC密钥（加密），为空表示仍使用全局密钥
+	AuthSecretPending   bool   `gorm:"default:false" json:"-"`                         // HMAC密钥已下发但设备尚未使用，期间仍接受全局密钥
+
+	// 克隆隔离，隔离期间不允许认证和连接，管理员重置密钥后解除
+	Quarantined      bool       `gorm:"default:false;index" json:"quarantined"`
+	QuarantinedAt    *time.Time `json:"quarantined_at"`
+	QuarantineReason string     `gorm:"type:varchar(255)" json:"quarantine_reason"`
 
 	IsActive  bool           `gorm:"default:false;index" json:"is_active"` // 设备组是否激活
 	CreatedAt time.Time      `json:"created_at"`
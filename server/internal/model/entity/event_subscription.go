@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// EventSubscription 集成方事件订阅：设备和用户关联状态变化时向集成方推送事件，使用所属API密钥签名
+type EventSubscription struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	APIKeyID   uint      `gorm:"not null;index" json:"api_key_id"`             // 所属API密钥
+	APIKey     *APIKey   `gorm:"foreignKey:APIKeyID" json:"-"`                 // 关联的API密钥
+	URL        string    `gorm:"not null;type:varchar(1024)" json:"url"`       // 接收事件的地址
+	EventTypes []string  `gorm:"type:json;serializer:json" json:"event_types"` // 订阅的事件类型，为空表示全部类型
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (EventSubscription) TableName() string {
+	return "event_subscriptions"
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// NotificationSubscription 管理员通知订阅：将指定类别的通知发送到配置文件中定义的渠道
+type NotificationSubscription struct {
+	ID                    uint      `gorm:"primaryKey" json:"id"`
+	Channel               string    `gorm:"not null;type:varchar(100)" json:"channel"`   // 渠道名称
+	Categories            []string  `gorm:"type:json;serializer:json" json:"categories"` // 订阅的通知类别，为空表示全部类别
+	ThrottleSeconds       int       `gorm:"default:0" json:"throttle_seconds"`           // 同一类别两次即时发送的最小间隔（秒）
+	DigestIntervalSeconds int       `gorm:"default:0" json:"digest_interval_seconds"`    // 大于0时按该间隔（秒）汇总发送
+	IsActive              bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (NotificationSubscription) TableName() string {
+	return "notification_subscriptions"
+}
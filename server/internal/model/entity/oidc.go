@@ -0,0 +1,55 @@
+package entity
+
+import "time"
+
+// OIDCClient OIDC客户端: 通过EasyUKey登录的Web应用，以关联的API密钥作为客户端密钥
+type OIDCClient struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"not null;type:varchar(255)" json:"name"`            // 应用名称，展示在登录页和U盘确认信息中
+	ClientID     string    `gorm:"unique;not null;type:varchar(64)" json:"client_id"` // OIDC client_id
+	APIKeyID     uint      `gorm:"not null;index" json:"api_key_id"`                  // 关联的API密钥，其值即 client_secret
+	APIKey       *APIKey   `gorm:"foreignKey:APIKeyID" json:"-"`                      // 关联的API密钥
+	RedirectURIs []string  `gorm:"type:json;serializer:json" json:"redirect_uris"`    // 允许的回调地址，需完全匹配
+	Action       string    `gorm:"type:varchar(255)" json:"action"`                   // 登录时请求的操作，设备组需具备该权限，为空表示不限制
+	IsActive     bool      `gorm:"default:true" json:"is_active"`                     // 是否启用
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OIDCClient) TableName() string {
+	return "oidc_clients"
+}
+
+// OIDCAuthorization OIDC授权: 记录一次授权码流程，从登录页发起U盘确认到签发令牌
+// 登录页、授权码和访问令牌都只保存SHA-256摘要
+type OIDCAuthorization struct {
+	ID              string     `gorm:"primaryKey;type:varchar(64)" json:"-"`     // 登录页轮询令牌的摘要
+	OIDCClientID    uint       `gorm:"not null;index" json:"oidc_client_id"`     // 发起授权的客户端
+	UserID          uint       `gorm:"not null;index" json:"user_id"`            // 登录的用户
+	AuthSessionID   string     `gorm:"type:varchar(255)" json:"auth_session_id"` // 推送到U盘的认证会话
+	DeviceGroupID   *uint      `json:"device_group_id"`                          // 批准登录的设备组
+	Status          string     `gorm:"not null;type:varchar(20)" json:"status"`  // 授权状态：pending 等待U盘确认, code_issued 已签发授权码, token_issued 已签发令牌
+	RedirectURI     string     `gorm:"type:varchar(1024)" json:"redirect_uri"`   // 授权请求中的回调地址，换取令牌时需一致
+	Scope           string     `gorm:"type:varchar(255)" json:"scope"`           // 授权范围
+	State           string     `gorm:"type:varchar(1024)" json:"-"`              // 客户端的 state，回调时原样返回
+	Nonce           string     `gorm:"type:varchar(255)" json:"-"`               // 客户端的 nonce，写入 ID Token
+	CodeChallenge   string     `gorm:"type:varchar(128)" json:"-"`               // PKCE code_challenge（S256）
+	Action          string     `gorm:"type:varchar(255)" json:"action"`          // U盘批准的操作
+	CodeHash        string     `gorm:"type:varchar(64);index" json:"-"`          // 授权码摘要
+	AccessTokenHash string     `gorm:"type:varchar(64);index" json:"-"`          // 访问令牌摘要
+	ClientIP        string     `gorm:"type:varchar(45)" json:"client_ip"`        // 登录页所在浏览器的IP
+	ApprovedAt      *time.Time `json:"approved_at"`                              // U盘批准时间
+	ExpiresAt       time.Time  `gorm:"index" json:"expires_at"`                  // 当前阶段的截止时间：登录、授权码或访问令牌的过期时间
+	CreatedAt       time.Time  `json:"created_at"`
+
+	// 关联关系
+	OIDCClient  *OIDCClient  `gorm:"foreignKey:OIDCClientID" json:"oidc_client,omitempty"`
+	User        *User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	DeviceGroup *DeviceGroup `gorm:"foreignKey:DeviceGroupID" json:"device_group,omitempty"`
+}
+
+// TableName 指定表名
+func (OIDCAuthorization) TableName() string {
+	return "oidc_authorizations"
+}
@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+)
+
+// RecoveryCode 恢复码: 设备初始化时为设备组生成，用于丢失U盘后在新设备上接管原设备组
+type RecoveryCode struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	DeviceGroupID  uint       `gorm:"not null;index" json:"device_group_id"`          // 所属设备组
+	CodeHash       string     `gorm:"not null;type:varchar(64);uniqueIndex" json:"-"` // 恢复码的HMAC摘要，服务端不保存明文
+	UsedAt         *time.Time `json:"used_at"`                                        // 使用时间，非空表示已失效
+	UsedByDeviceID *uint      `json:"used_by_device_id"`                              // 使用该恢复码接管设备组的新设备
+	CreatedAt      time.Time  `json:"created_at"`
+
+	// 关联关系
+	DeviceGroup *DeviceGroup `gorm:"foreignKey:DeviceGroupID;constraint:OnDelete:CASCADE" json:"device_group,omitempty"`
+}
+
+// TableName 指定表名
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
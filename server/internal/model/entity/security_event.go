@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// SecurityEvent 安全事件，记录克隆检测、设备组隔离和密钥重置等需要管理员关注的情况
+type SecurityEvent struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Type          string    `gorm:"not null;type:varchar(50);index" json:"type"` // 事件类型
+	DeviceGroupID *uint     `gorm:"index" json:"device_group_id"`                // 相关设备组
+	DeviceID      *uint     `gorm:"index" json:"device_id"`                      // 触发事件的设备
+	SerialNumber  string    `gorm:"type:varchar(255)" json:"serial_number"`      // 触发事件的设备序列号
+	Detail        string    `gorm:"type:text" json:"detail"`                     // 事件详情
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (SecurityEvent) TableName() string {
+	return "security_events"
+}
@@ -0,0 +1,254 @@
+// Package radiusd 提供RADIUS认证服务
+//
+// VPN网关和网络设备（NAS）以 Access-Request 发送用户名，服务端向该用户的U盘推送认证请求，
+// 批准后回复 Access-Accept，拒绝、失败或超时回复 Access-Reject。User-Password 不参与认证。
+// 等待U盘批准期间NAS会重传请求，同一请求的重传不会再次推送，回复后的重传直接重发缓存的响应。
+package radiusd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/service"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/radius"
+)
+
+// responseCacheTTL 回复后保留响应的时间，期间收到的重传请求直接重发该响应
+const responseCacheTTL = 30 * time.Second
+
+// authenticateRADIUS 向用户的U盘推送认证请求并等待结果，测试中替换为模拟实现
+var authenticateRADIUS = service.AuthenticateRADIUS
+
+// nasClient 允许访问的NAS客户端
+type nasClient struct {
+	name                        string
+	network                     *net.IPNet
+	secret                      []byte
+	action                      string
+	timeout                     time.Duration
+	requireMessageAuthenticator bool
+}
+
+// requestKey 来源地址、标识和请求认证码都相同的报文视为同一请求的重传
+type requestKey struct {
+	addr          string
+	identifier    byte
+	authenticator [16]byte
+}
+
+// Server RADIUS认证服务
+type Server struct {
+	conn       net.PacketConn
+	clients    []*nasClient
+	maxPending int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	pending  int
+	requests map[requestKey][]byte // 已编码的响应，nil 表示仍在等待U盘批准
+}
+
+// Start 监听UDP地址并开始处理认证请求
+func Start(cfg *config.RADIUSConfig) (*Server, error) {
+	clients := make([]*nasClient, 0, len(cfg.Clients))
+	for _, c := range cfg.Clients {
+		network, err := c.Network()
+		if err != nil {
+			return nil, err
+		}
+		client := &nasClient{
+			name:                        c.Name,
+			network:                     network,
+			secret:                      []byte(c.Secret),
+			action:                      c.Action,
+			timeout:                     c.Timeout,
+			requireMessageAuthenticator: !c.AllowMissingMessageAuthenticator,
+		}
+		if client.action == "" {
+			client.action = cfg.Action
+		}
+		if client.timeout == 0 {
+			client.timeout = cfg.Timeout
+		}
+		clients = append(clients, client)
+	}
+
+	conn, err := net.ListenPacket("udp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		conn:       conn,
+		clients:    clients,
+		maxPending: cfg.MaxPending,
+		ctx:        ctx,
+		cancel:     cancel,
+		requests:   make(map[requestKey][]byte),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	logger.Logger.Info("RADIUS服务已启动", "address", conn.LocalAddr().String(), "clients", len(clients))
+	return s, nil
+}
+
+// Shutdown 停止接收请求，等待中的认证不再回复
+func (s *Server) Shutdown() {
+	s.cancel()
+	s.conn.Close()
+	s.wg.Wait()
+}
+
+// serve 读取报文直到连接关闭
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	buf := make([]byte, radius.MaxPacketSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if s.ctx.Err() == nil {
+				logger.Logger.Error("RADIUS服务读取失败", "error", err)
+			}
+			return
+		}
+		s.handlePacket(append([]byte(nil), buf[:n]...), addr)
+	}
+}
+
+// handlePacket 校验请求并为新请求启动认证
+func (s *Server) handlePacket(data []byte, addr net.Addr) {
+	client := s.findClient(addr)
+	if client == nil {
+		logger.Logger.Warn("忽略未配置的RADIUS客户端的请求", "address", addr.String())
+		return
+	}
+
+	// RFC 2865：无效或无法验证的报文直接丢弃，不回复
+	req, err := radius.Parse(data)
+	if err != nil || req.Code != radius.CodeAccessRequest {
+		logger.Logger.Warn("忽略无效的RADIUS报文", "client", client.name, "address", addr.String())
+		return
+	}
+	if err := radius.VerifyRequest(req, client.secret, client.requireMessageAuthenticator); err != nil {
+		logger.Logger.Warn("RADIUS请求校验失败", "client", client.name, "address", addr.String(), "error", err)
+		return
+	}
+
+	key := requestKey{addr: addr.String(), identifier: req.Identifier, authenticator: req.Authenticator}
+
+	s.mu.Lock()
+	if resp, ok := s.requests[key]; ok {
+		s.mu.Unlock()
+		if resp != nil {
+			s.conn.WriteTo(resp, addr)
+		}
+		return
+	}
+	if s.pending >= s.maxPending {
+		s.mu.Unlock()
+		logger.Logger.Warn("等待U盘批准的RADIUS请求过多，丢弃请求", "client", client.name)
+		return
+	}
+	s.requests[key] = nil
+	s.pending++
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.authenticate(client, req, key, addr)
+}
+
+// authenticate 等待U盘批准并回复 Access-Accept 或 Access-Reject
+func (s *Server) authenticate(client *nasClient, req *radius.Packet, key requestKey, addr net.Addr) {
+	defer s.wg.Done()
+
+	username := req.GetString(radius.AttrUserName)
+	resp := radius.NewResponse(req, radius.CodeAccessReject)
+
+	err := errs.ErrMissingUsername
+	if username != "" {
+		err = authenticateRADIUS(s.ctx, &service.RADIUSAuthRequest{
+			Username:  username,
+			Action:    client.action,
+			NASName:   client.name,
+			NASIP:     addr.(*net.UDPAddr).IP.String(),
+			CallingID: req.GetString(radius.AttrCallingStationID),
+			Timeout:   client.timeout,
+		})
+	}
+
+	if err == nil {
+		resp.Code = radius.CodeAccessAccept
+		logger.Logger.Info("RADIUS认证通过", "client", client.name, "username", username)
+	} else {
+		resp.AddString(radius.AttrReplyMessage, replyMessage(err))
+		logger.Logger.Info("RADIUS认证未通过", "client", client.name, "username", username, "error", err)
+	}
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	data, encodeErr := radius.EncodeResponse(resp, req, client.secret)
+	if encodeErr != nil {
+		logger.Logger.Error("编码RADIUS响应失败", "client", client.name, "error", encodeErr)
+	}
+
+	s.mu.Lock()
+	s.pending--
+	if data != nil {
+		s.requests[key] = data
+	} else {
+		delete(s.requests, key)
+	}
+	s.mu.Unlock()
+
+	if data == nil {
+		return
+	}
+	time.AfterFunc(responseCacheTTL, func() {
+		s.mu.Lock()
+		delete(s.requests, key)
+		s.mu.Unlock()
+	})
+
+	if _, err := s.conn.WriteTo(data, addr); err != nil {
+		logger.Logger.Error("发送RADIUS响应失败", "client", client.name, "error", err)
+	}
+}
+
+// findClient 按来源IP查找NAS客户端
+func (s *Server) findClient(addr net.Addr) *nasClient {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	for _, client := range s.clients {
+		if client.network.Contains(udpAddr.IP) {
+			return client
+		}
+	}
+	return nil
+}
+
+// replyMessage 返回给NAS展示的拒绝原因，不区分用户是否存在
+func replyMessage(err error) string {
+	switch {
+	case errors.Is(err, errs.ErrUserRejected), errors.Is(err, errs.ErrSessionExpired), errors.Is(err, errs.ErrMissingUsername):
+		return err.Error()
+	default:
+		return errs.ErrRADIUSAuthFailed.Error()
+	}
+}
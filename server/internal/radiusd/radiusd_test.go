@@ -0,0 +1,317 @@
+package radiusd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/service"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/radius"
+)
+
+var testSecret = []byte("0123456789abcdef")
+
+// authFunc 模拟U盘认证的实现
+type authFunc func(ctx context.Context, req *service.RADIUSAuthRequest) error
+
+// startTestServer 在 127.0.0.1 的随机端口启动服务，认证由 auth 模拟，测试结束时关闭
+func startTestServer(t *testing.T, maxPending int, timeout time.Duration, auth authFunc) string {
+	t.Helper()
+	if logger.Logger == nil {
+		logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	original := authenticateRADIUS
+	authenticateRADIUS = auth
+
+	s, err := Start(&config.RADIUSConfig{
+		Address:    "127.0.0.1:0",
+		Action:     "radius:login",
+		Timeout:    timeout,
+		MaxPending: maxPending,
+		Clients:    []config.RADIUSClientConfig{{Name: "vpn", Address: "127.0.0.1", Secret: string(testSecret)}},
+	})
+	if err != nil {
+		authenticateRADIUS = original
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Shutdown()
+		authenticateRADIUS = original
+	})
+	return s.conn.LocalAddr().String()
+}
+
+// nas 模拟NAS，从固定的本地端口发送请求，重传时来源地址不变
+type nas struct {
+	t    *testing.T
+	conn *net.UDPConn
+	addr *net.UDPAddr
+}
+
+func newNAS(t *testing.T, serverAddr string) *nas {
+	t.Helper()
+	addr, err := net.ResolveUDPAddr("udp", serverAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &nas{t: t, conn: conn, addr: addr}
+}
+
+// request 编码用户名为 username 的 Access-Request
+func (n *nas) request(identifier byte, username string) (*radius.Packet, []byte) {
+	n.t.Helper()
+	req, err := radius.NewAccessRequest(identifier, username, "", testSecret)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	data, err := radius.EncodeRequest(req, testSecret)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	return req, data
+}
+
+func (n *nas) send(data []byte) {
+	n.t.Helper()
+	if _, err := n.conn.WriteToUDP(data, n.addr); err != nil {
+		n.t.Fatal(err)
+	}
+}
+
+// receive 等待一个响应报文，超时返回 nil
+func (n *nas) receive(wait time.Duration) []byte {
+	n.t.Helper()
+	n.conn.SetReadDeadline(time.Now().Add(wait))
+	buf := make([]byte, radius.MaxPacketSize)
+	size, _, err := n.conn.ReadFromUDP(buf)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
+		n.t.Fatal(err)
+	}
+	return buf[:size]
+}
+
+// response 解析并校验对 req 的响应
+func (n *nas) response(req *radius.Packet, data []byte) *radius.Packet {
+	n.t.Helper()
+	if data == nil {
+		n.t.Fatal("未收到响应")
+	}
+	resp, err := radius.Parse(data)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	if err := radius.VerifyResponse(resp, req, testSecret); err != nil {
+		n.t.Fatalf("响应校验失败: %v", err)
+	}
+	return resp
+}
+
+// blockingAuth 阻塞到 release 关闭后批准，started 接收每次调用
+func blockingAuth(calls *atomic.Int32, started chan<- string, release <-chan struct{}) authFunc {
+	return func(ctx context.Context, req *service.RADIUSAuthRequest) error {
+		calls.Add(1)
+		started <- req.Username
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return errs.ErrSessionExpired
+		}
+	}
+}
+
+func TestAccessAccept(t *testing.T) {
+	received := make(chan *service.RADIUSAuthRequest, 1)
+	addr := startTestServer(t, 10, 5*time.Second, func(ctx context.Context, req *service.RADIUSAuthRequest) error {
+		received <- req
+		return nil
+	})
+
+	req, _ := radius.NewAccessRequest(1, "alice", "ignored", testSecret)
+	req.AddString(radius.AttrCallingStationID, "203.0.113.7")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := radius.Exchange(ctx, addr, req, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != radius.CodeAccessAccept {
+		t.Fatalf("响应代码 = %d，应为 Access-Accept", resp.Code)
+	}
+
+	want := service.RADIUSAuthRequest{
+		Username:  "alice",
+		Action:    "radius:login",
+		NASName:   "vpn",
+		NASIP:     "127.0.0.1",
+		CallingID: "203.0.113.7",
+		Timeout:   5 * time.Second,
+	}
+	if got := <-received; *got != want {
+		t.Errorf("认证请求 = %+v，应为 %+v", got, want)
+	}
+}
+
+func TestAccessReject(t *testing.T) {
+	cases := map[string]struct {
+		err     error
+		message string
+	}{
+		"用户拒绝":    {errs.ErrUserRejected, errs.ErrUserRejected.Error()},
+		"用户不存在":   {errs.ErrUserNotFound, errs.ErrRADIUSAuthFailed.Error()},
+		"U盘不在线":   {errs.ErrUserNotOnline, errs.ErrRADIUSAuthFailed.Error()},
+		"没有操作权限":  {errs.ErrPermissionDenied, errs.ErrRADIUSAuthFailed.Error()},
+		"缺少用户名":   {nil, errs.ErrMissingUsername.Error()},
+		"内部错误不外泄": {errors.New("数据库连接失败"), errs.ErrRADIUSAuthFailed.Error()},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			addr := startTestServer(t, 10, 5*time.Second, func(ctx context.Context, req *service.RADIUSAuthRequest) error {
+				return tc.err
+			})
+
+			username := "alice"
+			if tc.err == nil {
+				username = ""
+			}
+			req, _ := radius.NewAccessRequest(1, username, "", testSecret)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			resp, err := radius.Exchange(ctx, addr, req, testSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != radius.CodeAccessReject {
+				t.Fatalf("响应代码 = %d，应为 Access-Reject", resp.Code)
+			}
+			if got := resp.GetString(radius.AttrReplyMessage); got != tc.message {
+				t.Errorf("Reply-Message = %q，应为 %q", got, tc.message)
+			}
+		})
+	}
+}
+
+func TestTimeoutRejects(t *testing.T) {
+	addr := startTestServer(t, 10, 200*time.Millisecond, func(ctx context.Context, req *service.RADIUSAuthRequest) error {
+		// 与 service.AuthenticateRADIUS 一致，等待超时返回 ErrSessionExpired
+		select {
+		case <-time.After(req.Timeout):
+		case <-ctx.Done():
+		}
+		return errs.ErrSessionExpired
+	})
+
+	n := newNAS(t, addr)
+	req, data := n.request(1, "alice")
+	start := time.Now()
+	n.send(data)
+	resp := n.response(req, n.receive(2*time.Second))
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("应等待配置的超时时间后回复，实际 %v", elapsed)
+	}
+	if resp.Code != radius.CodeAccessReject {
+		t.Fatalf("响应代码 = %d，应为 Access-Reject", resp.Code)
+	}
+	if got := resp.GetString(radius.AttrReplyMessage); got != errs.ErrSessionExpired.Error() {
+		t.Errorf("Reply-Message = %q", got)
+	}
+}
+
+func TestRetransmitIsDeduplicated(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	addr := startTestServer(t, 10, 5*time.Second, blockingAuth(&calls, started, release))
+
+	n := newNAS(t, addr)
+	req, data := n.request(7, "alice")
+	n.send(data)
+	<-started
+
+	// 等待批准期间的重传不再推送，也不回复
+	n.send(data)
+	n.send(data)
+	if resp := n.receive(300 * time.Millisecond); resp != nil {
+		t.Fatal("等待批准期间不应回复重传")
+	}
+
+	close(release)
+	first := n.receive(2 * time.Second)
+	if resp := n.response(req, first); resp.Code != radius.CodeAccessAccept {
+		t.Fatalf("响应代码 = %d，应为 Access-Accept", resp.Code)
+	}
+
+	// 回复后的重传直接重发缓存的响应
+	n.send(data)
+	second := n.receive(2 * time.Second)
+	if !bytes.Equal(first, second) {
+		t.Error("重传收到的响应应与缓存的响应相同")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("认证次数 = %d，应为 1", got)
+	}
+
+	// 标识不同的请求是新请求
+	req, data = n.request(8, "alice")
+	n.send(data)
+	<-started
+	n.response(req, n.receive(2*time.Second))
+	if got := calls.Load(); got != 2 {
+		t.Errorf("认证次数 = %d，应为 2", got)
+	}
+}
+
+func TestMaxPendingDropsRequests(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	addr := startTestServer(t, 1, 5*time.Second, blockingAuth(&calls, started, release))
+
+	n := newNAS(t, addr)
+	first, firstData := n.request(1, "alice")
+	n.send(firstData)
+	<-started
+
+	// 等待中的请求达到上限，新请求被丢弃且不回复
+	second, secondData := n.request(2, "bob")
+	n.send(secondData)
+	if resp := n.receive(300 * time.Millisecond); resp != nil {
+		t.Fatal("超过 max_pending 的请求不应回复")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("认证次数 = %d，应为 1", got)
+	}
+
+	close(release)
+	if resp := n.response(first, n.receive(2*time.Second)); resp.Code != radius.CodeAccessAccept {
+		t.Fatalf("响应代码 = %d，应为 Access-Accept", resp.Code)
+	}
+
+	// 名额释放后NAS的重传按新请求处理
+	n.send(secondData)
+	if username := <-started; username != "bob" {
+		t.Errorf("认证用户 = %q，应为 bob", username)
+	}
+	if resp := n.response(second, n.receive(2*time.Second)); resp.Code != radius.CodeAccessAccept {
+		t.Fatalf("响应代码 = %d，应为 Access-Accept", resp.Code)
+	}
+}
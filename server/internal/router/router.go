@@ -7,6 +7,7 @@ import (
 
 	"github.com/hang666/EasyUKey/sdk/response"
 	"github.com/hang666/EasyUKey/server/internal/api"
+	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/middleware"
 	"github.com/hang666/EasyUKey/server/internal/ws"
 )
@@ -27,6 +28,20 @@ func SetupRoutes(e *echo.Echo) {
 	// 管理员面板页面（无需认证）
 	e.GET("/admin", api.AdminPanel)
 
+	// OIDC提供方（未启用时不注册）
+	if global.Config.OIDC.Enabled {
+		e.GET("/.well-known/openid-configuration", api.OIDCDiscovery)
+
+		oidc := e.Group("/oidc")
+		oidc.GET("/authorize", api.OIDCAuthorize)
+		oidc.POST("/authorize", api.OIDCStartLogin)
+		oidc.POST("/authorize/status", api.OIDCLoginStatus)
+		oidc.POST("/token", api.OIDCToken)
+		oidc.GET("/userinfo", api.OIDCUserInfo)
+		oidc.POST("/userinfo", api.OIDCUserInfo)
+		oidc.GET("/jwks", api.OIDCJWKS)
+	}
+
 	// API路由组
 	apiV1 := e.Group("/api/v1")
 
@@ -37,12 +52,40 @@ func SetupRoutes(e *echo.Echo) {
 		auth.POST("/auth/verify", api.VerifyAuth)
 	}
 
-	// 管理员验证路由（无需认证）
-	apiV1.POST("/admin/verify", api.VerifyAdminKey)
+	// 事件订阅路由（需要普通API密钥，订阅归属于请求使用的密钥）
+	events := apiV1.Group("/event-subscriptions", middleware.APIAuth(false))
+	{
+		events.GET("", api.GetEventSubscriptions)
+		events.POST("", api.CreateEventSubscription)
+		events.DELETE("/:id", api.DeleteEventSubscription)
+	}
+
+	// 管理员登录路由（无需认证）
+	// 浏览器只通过U盘确认登录，直接提交管理员API密钥的验证接口默认不注册
+	if global.Config.Admin.EnableKeyVerify {
+		apiV1.POST("/admin/verify", api.VerifyAdminKey)
+	}
+	apiV1.POST("/admin/login", api.AdminLogin)
+	apiV1.GET("/admin/login/status", api.AdminLoginStatus)
+	apiV1.POST("/admin/logout", api.AdminLogout)
 
-	// 管理员路由组（需要管理员API密钥）
-	admin := apiV1.Group("/admin", middleware.APIAuth(true))
+	// 管理员路由组（需要管理后台会话或管理员API密钥，修改操作记录审计日志）
+	admin := apiV1.Group("/admin", middleware.AdminAuth(), middleware.AdminAudit())
 	{
+		// 管理员账号
+		admin.GET("/me", api.GetCurrentAdmin)
+		admin.GET("/accounts", api.GetAdminAccounts)
+		admin.POST("/accounts", api.CreateAdminAccount)
+		admin.PUT("/accounts/:id", api.UpdateAdminAccount)
+		admin.DELETE("/accounts/:id", api.DeleteAdminAccount)
+		admin.GET("/audit-logs", api.GetAdminAuditLogs)
+
+		// 批量导入导出
+		admin.GET("/bulk/users", api.ExportUsers)
+		admin.POST("/bulk/users", api.ImportUsers)
+		admin.GET("/bulk/device-groups", api.ExportDeviceGroups)
+		admin.POST("/bulk/device-groups", api.ImportDeviceGroups)
+
 		// 用户管理
 		admin.POST("/users", api.CreateUser)
 		admin.GET("/users", api.GetUsers)
@@ -65,13 +108,31 @@ func SetupRoutes(e *echo.Echo) {
 		admin.GET("/device-groups/:id", api.GetDeviceGroup)
 		admin.PUT("/device-groups/:id", api.UpdateDeviceGroup)
 		admin.PUT("/device-groups/:id/user", api.LinkDeviceGroupUser)
+		admin.POST("/device-groups/:id/rekey", api.RekeyDeviceGroup)
 
 		// API密钥管理
 		admin.POST("/apikeys", api.CreateAPIKey)
 		admin.GET("/apikeys", api.GetAPIKeys)
 		admin.DELETE("/apikeys/:id", api.DeleteAPIKey)
 
+		// OIDC客户端管理
+		admin.GET("/oidc-clients", api.GetOIDCClients)
+		admin.POST("/oidc-clients", api.CreateOIDCClient)
+		admin.PUT("/oidc-clients/:id", api.UpdateOIDCClient)
+		admin.DELETE("/oidc-clients/:id", api.DeleteOIDCClient)
+
 		// 认证会话管理
 		admin.GET("/sessions", api.GetAuthSessions)
+
+		// 安全事件
+		admin.GET("/security-events", api.GetSecurityEvents)
+
+		// 管理员通知
+		admin.GET("/notifications/channels", api.GetNotificationChannels)
+		admin.POST("/notifications/channels/:name/test", api.TestNotificationChannel)
+		admin.GET("/notifications/subscriptions", api.GetNotificationSubscriptions)
+		admin.POST("/notifications/subscriptions", api.CreateNotificationSubscription)
+		admin.PUT("/notifications/subscriptions/:id", api.UpdateNotificationSubscription)
+		admin.DELETE("/notifications/subscriptions/:id", api.DeleteNotificationSubscription)
 	}
 }
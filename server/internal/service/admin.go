@@ -0,0 +1,388 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+// 管理后台会话状态
+const (
+	adminSessionPending = "pending" // 等待U盘确认
+	adminSessionActive  = "active"  // 已登录
+)
+
+// adminSessionTouchInterval 会话活跃时间的最小更新间隔，避免每个请求都写库
+const adminSessionTouchInterval = time.Minute
+
+var (
+	// adminLoginLimiter 按用户名和来源IP限制管理后台登录推送
+	adminLoginLimiter = newAttemptLimiter(pushLoginAttemptWindow)
+	// adminLoginDecoys 管理员账号不存在或无法推送时的占位登录
+	adminLoginDecoys = newLoginDecoys()
+)
+
+// hashAdminToken 计算会话令牌摘要，数据库只保存摘要
+func hashAdminToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ConvertToAdminAccountResponse 将管理员账号转换为响应结构
+func ConvertToAdminAccountResponse(account *entity.AdminAccount) *response.AdminAccountResponse {
+	if account == nil {
+		return nil
+	}
+
+	resp := &response.AdminAccountResponse{
+		ID:          account.ID,
+		UserID:      account.UserID,
+		IsActive:    account.IsActive,
+		LastLoginAt: account.LastLoginAt,
+		CreatedAt:   account.CreatedAt,
+	}
+	if account.User != nil {
+		resp.Username = account.User.Username
+	}
+	return resp
+}
+
+// StartAdminLogin 向管理员的U盘发起 admin:login 认证，返回写入浏览器Cookie的会话令牌
+// 会话在U盘确认前处于等待状态，不能用于访问管理接口。
+// 同一用户名和来源IP的登录次数受限；账号不存在、U盘不在线或没有 admin:login 权限时返回占位登录，响应与正常发起时相同
+func StartAdminLogin(username, clientIP, userAgent string) (string, *entity.AuthSession, error) {
+	if !adminLoginLimiter.Allow(pushLoginAttemptKeys(username, clientIP)...) {
+		logger.Logger.Warn("管理后台登录尝试次数过多", "username", username, "client_ip", clientIP)
+		return "", nil, errs.ErrTooManyAttempts
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("生成会话令牌失败: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	var account entity.AdminAccount
+	err := global.DB.Joins("JOIN users ON users.id = admin_accounts.user_id AND users.deleted_at IS NULL").
+		Where("users.username = ? AND admin_accounts.is_active = ?", username, true).
+		First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Logger.Warn("管理后台登录失败：管理员账号不存在", "username", username, "client_ip", clientIP)
+			return token, adminLoginDecoys.add(hashAdminToken(token), global.Config.Admin.LoginTimeout, ""), nil
+		}
+		return "", nil, fmt.Errorf("查询管理员账号失败: %w", err)
+	}
+
+	challenge := make([]byte, 16)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", nil, fmt.Errorf("生成挑战码失败: %w", err)
+	}
+
+	authSession, err := StartAuth(&request.AuthRequest{
+		Username:  username,
+		Challenge: hex.EncodeToString(challenge),
+		Action:    consts.AuthActionAdminLogin,
+		Message:   fmt.Sprintf("登录 EasyUKey 管理后台（IP: %s）", clientIP),
+		Timeout:   int(global.Config.Admin.LoginTimeout / time.Second),
+	}, nil, clientIP)
+	if err != nil {
+		if errors.Is(err, errs.ErrUserNotFound) || errors.Is(err, errs.ErrUserNotOnline) || errors.Is(err, errs.ErrPermissionDenied) {
+			logger.Logger.Warn("管理后台登录失败：无法推送认证请求", "username", username, "client_ip", clientIP, "error", err)
+			return token, adminLoginDecoys.add(hashAdminToken(token), global.Config.Admin.LoginTimeout, ""), nil
+		}
+		return "", nil, err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	now := time.Now()
+	session := entity.AdminSession{
+		ID:             hashAdminToken(token),
+		AdminAccountID: account.ID,
+		AuthSessionID:  authSession.ID,
+		Status:         adminSessionPending,
+		ClientIP:       clientIP,
+		UserAgent:      userAgent,
+		LastActiveAt:   now,
+		ExpiresAt:      authSession.ExpiresAt,
+	}
+	if err := global.DB.Create(&session).Error; err != nil {
+		return "", nil, fmt.Errorf("创建管理员会话失败: %w", err)
+	}
+
+	purgeAdminSessions()
+
+	return token, authSession, nil
+}
+
+// CheckAdminLogin 查询登录进度，U盘确认后将会话转为已登录
+// 返回认证状态；认证失败、被拒绝或过期时等待中的会话被删除
+func CheckAdminLogin(token string) (string, *entity.AdminAccount, error) {
+	var session entity.AdminSession
+	if err := global.DB.Preload("AdminAccount.User").
+		Where("id = ?", hashAdminToken(token)).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if status, decoy := adminLoginDecoys.check(hashAdminToken(token)); decoy != nil {
+				return status, nil, nil
+			}
+			return "", nil, errs.ErrAdminSessionInvalid
+		}
+		return "", nil, fmt.Errorf("查询管理员会话失败: %w", err)
+	}
+	if session.AdminAccount == nil || session.AdminAccount.User == nil {
+		global.DB.Delete(&session)
+		return "", nil, errs.ErrAdminSessionInvalid
+	}
+	if session.Status == adminSessionActive {
+		return consts.AuthStatusCompleted, session.AdminAccount, nil
+	}
+
+	var authSession entity.AuthSession
+	if err := global.DB.Preload("RespondingDevice.DeviceGroup").
+		Where("id = ?", session.AuthSessionID).First(&authSession).Error; err != nil {
+		return "", nil, fmt.Errorf("查询认证会话失败: %w", err)
+	}
+
+	status := authSession.Status
+	if status != consts.AuthStatusCompleted && authSession.ExpiresAt.Before(time.Now()) {
+		status = consts.AuthStatusExpired
+	}
+
+	switch status {
+	case consts.AuthStatusCompleted:
+		// 再次确认响应的U盘属于该管理员，避免其他用户的设备代为确认
+		device := authSession.RespondingDevice
+		if authSession.Result != consts.AuthResultSuccess || device == nil || device.DeviceGroup == nil ||
+			device.DeviceGroup.UserID == nil || *device.DeviceGroup.UserID != session.AdminAccount.UserID ||
+			!session.AdminAccount.IsActive {
+			global.DB.Delete(&session)
+			return "", nil, errs.ErrAdminLoginFailed
+		}
+		if err := activateAdminSession(&session); err != nil {
+			return "", nil, err
+		}
+		return consts.AuthStatusCompleted, session.AdminAccount, nil
+
+	case consts.AuthStatusFailed, consts.AuthStatusRejected, consts.AuthStatusExpired:
+		global.DB.Delete(&session)
+		return status, nil, nil
+
+	default:
+		return consts.AuthStatusPending, nil, nil
+	}
+}
+
+// activateAdminSession 将等待中的会话转为已登录
+func activateAdminSession(session *entity.AdminSession) error {
+	now := time.Now()
+	result := global.DB.Model(&entity.AdminSession{}).
+		Where("id = ? AND status = ?", session.ID, adminSessionPending).
+		Updates(map[string]interface{}{
+			"status":         adminSessionActive,
+			"last_active_at": now,
+			"expires_at":     now.Add(global.Config.Admin.SessionMaxLifetime),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("更新管理员会话失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// 并发的状态查询已完成激活
+		return nil
+	}
+
+	if err := global.DB.Model(session.AdminAccount).Update("last_login_at", &now).Error; err != nil {
+		logger.Logger.Error("更新管理员登录时间失败", "admin_account_id", session.AdminAccountID, "error", err)
+	}
+	session.AdminAccount.LastLoginAt = &now
+
+	RecordAdminAudit(&entity.AdminAuditLog{
+		AdminAccountID: &session.AdminAccountID,
+		Actor:          session.AdminAccount.User.Username,
+		Method:         "LOGIN",
+		Path:           "/admin",
+		ClientIP:       session.ClientIP,
+	})
+	logger.Logger.Info("管理员登录成功", "admin_account_id", session.AdminAccountID, "client_ip", session.ClientIP)
+	return nil
+}
+
+// ValidateAdminSession 校验已登录的管理后台会话，返回对应的管理员账号
+func ValidateAdminSession(token string) (*entity.AdminAccount, error) {
+	if token == "" {
+		return nil, errs.ErrAdminSessionInvalid
+	}
+
+	var session entity.AdminSession
+	if err := global.DB.Preload("AdminAccount.User").
+		Where("id = ? AND status = ?", hashAdminToken(token), adminSessionActive).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrAdminSessionInvalid
+		}
+		return nil, fmt.Errorf("查询管理员会话失败: %w", err)
+	}
+
+	now := time.Now()
+	account := session.AdminAccount
+	if now.After(session.ExpiresAt) || now.Sub(session.LastActiveAt) > global.Config.Admin.SessionIdleTimeout ||
+		account == nil || !account.IsActive || account.User == nil || !account.User.IsActive {
+		global.DB.Delete(&session)
+		return nil, errs.ErrAdminSessionInvalid
+	}
+
+	if now.Sub(session.LastActiveAt) > adminSessionTouchInterval {
+		if err := global.DB.Model(&session).Update("last_active_at", now).Error; err != nil {
+			logger.Logger.Error("更新管理员会话活跃时间失败", "admin_account_id", account.ID, "error", err)
+		}
+	}
+
+	return account, nil
+}
+
+// AdminLogout 退出管理后台，删除会话
+func AdminLogout(token string) error {
+	if token == "" {
+		return nil
+	}
+	if err := global.DB.Where("id = ?", hashAdminToken(token)).Delete(&entity.AdminSession{}).Error; err != nil {
+		return fmt.Errorf("删除管理员会话失败: %w", err)
+	}
+	return nil
+}
+
+// purgeAdminSessions 清理已过期和空闲超时的会话
+func purgeAdminSessions() {
+	now := time.Now()
+	if err := global.DB.Where("expires_at < ? OR (status = ? AND last_active_at < ?)",
+		now, adminSessionActive, now.Add(-global.Config.Admin.SessionIdleTimeout)).
+		Delete(&entity.AdminSession{}).Error; err != nil {
+		logger.Logger.Error("清理管理员会话失败", "error", err)
+	}
+}
+
+// GetAdminAccounts 获取管理员账号列表
+func GetAdminAccounts() ([]entity.AdminAccount, error) {
+	var accounts []entity.AdminAccount
+	if err := global.DB.Preload("User").Order("id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("查询管理员账号失败: %w", err)
+	}
+	return accounts, nil
+}
+
+// CreateAdminAccount 将用户设为管理员
+func CreateAdminAccount(req *request.CreateAdminAccountRequest) (*entity.AdminAccount, error) {
+	user, err := GetUser(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := global.DB.Model(&entity.AdminAccount{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询管理员账号失败: %w", err)
+	}
+	if count > 0 {
+		return nil, errs.ErrAdminAccountExists
+	}
+
+	account := entity.AdminAccount{UserID: user.ID, IsActive: true, User: user}
+	if err := global.DB.Omit("User").Create(&account).Error; err != nil {
+		return nil, fmt.Errorf("创建管理员账号失败: %w", err)
+	}
+	return &account, nil
+}
+
+// UpdateAdminAccount 启用或停用管理员账号，停用时已登录的会话立即失效
+func UpdateAdminAccount(accountID uint, req *request.UpdateAdminAccountRequest) (*entity.AdminAccount, error) {
+	var account entity.AdminAccount
+	if err := global.DB.Preload("User").Where("id = ?", accountID).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrAdminAccountNotFound
+		}
+		return nil, fmt.Errorf("查询管理员账号失败: %w", err)
+	}
+
+	if req.IsActive != nil {
+		if err := global.DB.Model(&account).Update("is_active", *req.IsActive).Error; err != nil {
+			return nil, fmt.Errorf("更新管理员账号失败: %w", err)
+		}
+		if !*req.IsActive {
+			if err := global.DB.Where("admin_account_id = ?", account.ID).Delete(&entity.AdminSession{}).Error; err != nil {
+				return nil, fmt.Errorf("删除管理员会话失败: %w", err)
+			}
+		}
+	}
+
+	return &account, nil
+}
+
+// DeleteAdminAccount 删除管理员账号及其会话
+func DeleteAdminAccount(accountID uint) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", accountID).Delete(&entity.AdminAccount{})
+		if result.Error != nil {
+			return fmt.Errorf("删除管理员账号失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errs.ErrAdminAccountNotFound
+		}
+		if err := tx.Where("admin_account_id = ?", accountID).Delete(&entity.AdminSession{}).Error; err != nil {
+			return fmt.Errorf("删除管理员会话失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// RecordAdminAudit 记录管理操作审计日志，失败只记录日志
+func RecordAdminAudit(entry *entity.AdminAuditLog) {
+	if len(entry.Path) > 255 {
+		entry.Path = entry.Path[:255]
+	}
+	if err := global.DB.Create(entry).Error; err != nil {
+		logger.Logger.Error("记录管理操作审计日志失败", "actor", entry.Actor, "path", entry.Path, "error", err)
+	}
+}
+
+// GetAdminAuditLogs 获取管理操作审计日志
+func GetAdminAuditLogs(page, pageSize int, adminAccountID *uint) ([]entity.AdminAuditLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	offset := (page - 1) * pageSize
+	query := global.DB.Model(&entity.AdminAuditLog{})
+	if adminAccountID != nil {
+		query = query.Where("admin_account_id = ?", *adminAccountID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取审计日志总数失败: %w", err)
+	}
+
+	var logs []entity.AdminAuditLog
+	if err := query.Offset(offset).Limit(pageSize).
+		Order("created_at DESC").
+		Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取审计日志列表失败: %w", err)
+	}
+
+	return logs, total, nil
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+func resetAdminLogin(t *testing.T) {
+	t.Helper()
+	adminLoginLimiter = newAttemptLimiter(pushLoginAttemptWindow)
+	adminLoginDecoys = newLoginDecoys()
+	t.Cleanup(func() {
+		adminLoginLimiter = newAttemptLimiter(pushLoginAttemptWindow)
+		adminLoginDecoys = newLoginDecoys()
+	})
+}
+
+// createTestAdmin 创建管理员账号，online 为 true 时管理员的U盘在线并具备 admin:login 权限
+func createTestAdmin(t *testing.T, hub *fakeHub, username string, online bool) (*entity.AdminAccount, *entity.Device) {
+	t.Helper()
+	user := createTestUser(t, username)
+	_, device, _ := createTestDeviceGroup(t, user, "SN-"+username, consts.AuthActionAdminLogin)
+	if online {
+		if err := global.DB.Model(device).Update("is_online", true).Error; err != nil {
+			t.Fatal(err)
+		}
+		hub.connect(user.ID, device.ID)
+	}
+	account, err := CreateAdminAccount(&request.CreateAdminAccountRequest{UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account, device
+}
+
+// createActiveAdminSession 直接创建已登录的管理后台会话，返回会话令牌
+func createActiveAdminSession(t *testing.T, account *entity.AdminAccount) string {
+	t.Helper()
+	token := uuid.New().String()
+	now := time.Now()
+	session := &entity.AdminSession{
+		ID:             hashAdminToken(token),
+		AdminAccountID: account.ID,
+		Status:         adminSessionActive,
+		LastActiveAt:   now,
+		ExpiresAt:      now.Add(global.Config.Admin.SessionMaxLifetime),
+	}
+	if err := global.DB.Create(session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// completeAuthSession 模拟U盘确认认证会话
+func completeAuthSession(t *testing.T, sessionID string, device *entity.Device) {
+	t.Helper()
+	if err := global.DB.Model(&entity.AuthSession{}).Where("id = ?", sessionID).Updates(map[string]interface{}{
+		"status":               consts.AuthStatusCompleted,
+		"result":               consts.AuthResultSuccess,
+		"responding_device_id": device.ID,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartAdminLoginDoesNotRevealAccount(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+	resetAdminLogin(t)
+
+	createTestAdmin(t, hub, "admin", true)
+	createTestAdmin(t, hub, "offline-admin", false)
+	createTestUser(t, "plain")
+
+	for i, username := range []string{"admin", "offline-admin", "plain", "unknown"} {
+		t.Run(username, func(t *testing.T) {
+			start := time.Now()
+			token, session, err := StartAdminLogin(username, fmt.Sprintf("192.0.2.%d", i+1), "test")
+			if err != nil {
+				t.Fatalf("发起登录返回错误 %v，不应区分账号是否存在", err)
+			}
+			if token == "" || session == nil || session.Status != consts.AuthStatusPending {
+				t.Fatalf("发起登录的响应应与正常登录相同，令牌 %q，会话 %+v", token, session)
+			}
+			if _, err := uuid.Parse(session.ID); err != nil {
+				t.Errorf("会话ID %q 不是UUID", session.ID)
+			}
+			if timeout := session.ExpiresAt.Sub(start); timeout < global.Config.Admin.LoginTimeout-time.Second ||
+				timeout > global.Config.Admin.LoginTimeout+time.Second {
+				t.Errorf("会话有效期 %v，应为配置的登录超时", timeout)
+			}
+
+			status, account, err := CheckAdminLogin(token)
+			if err != nil || status != consts.AuthStatusPending || account != nil {
+				t.Errorf("登录进度 = %q, %v, %v，应为等待确认", status, account, err)
+			}
+		})
+	}
+
+	// 只有真实的管理员登录会向U盘推送认证请求
+	var count int64
+	global.DB.Model(&entity.AuthSession{}).Count(&count)
+	if count != 1 {
+		t.Errorf("认证会话 %d 个，应只为在线的管理员创建", count)
+	}
+}
+
+func TestStartAdminLoginCountsDeniedAttemptsAgainstIP(t *testing.T) {
+	setupTestDB(t)
+	setupFakeHub(t)
+	resetAdminLogin(t)
+
+	const clientIP = "192.0.2.1"
+	for i := 0; i < pushLoginAttemptsPerIP; i++ {
+		_, _, err := StartAdminLogin("victim", clientIP, "test")
+		if allowed := i < pushLoginAttemptsPerUser; allowed != (err == nil) {
+			t.Fatalf("第 %d 次登录返回 %v", i+1, err)
+		}
+	}
+
+	// 被用户名限制拒绝的尝试同样计入来源IP
+	if _, _, err := StartAdminLogin("another", clientIP, "test"); !errors.Is(err, errs.ErrTooManyAttempts) {
+		t.Fatalf("来源IP超过次数后返回 %v，应为 ErrTooManyAttempts", err)
+	}
+	if _, _, err := StartAdminLogin("another", "192.0.2.2", "test"); err != nil {
+		t.Fatalf("其他来源IP不应受影响: %v", err)
+	}
+}
+
+func TestCheckAdminLoginRequiresOwnDevice(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+	resetAdminLogin(t)
+	_, device := createTestAdmin(t, hub, "admin", true)
+	_, otherDevice := createTestAdmin(t, hub, "other", true)
+
+	// 其他用户的U盘代为确认时登录失败
+	token, session, err := StartAdminLogin("admin", "192.0.2.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	completeAuthSession(t, session.ID, otherDevice)
+	if _, _, err := CheckAdminLogin(token); !errors.Is(err, errs.ErrAdminLoginFailed) {
+		t.Fatalf("登录进度返回 %v，应为 ErrAdminLoginFailed", err)
+	}
+	if _, err := ValidateAdminSession(token); !errors.Is(err, errs.ErrAdminSessionInvalid) {
+		t.Fatalf("登录失败的会话应无效，实际 %v", err)
+	}
+
+	token, session, err = StartAdminLogin("admin", "192.0.2.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateAdminSession(token); !errors.Is(err, errs.ErrAdminSessionInvalid) {
+		t.Fatal("等待U盘确认的会话不能访问管理接口")
+	}
+	completeAuthSession(t, session.ID, device)
+	status, account, err := CheckAdminLogin(token)
+	if err != nil || status != consts.AuthStatusCompleted || account == nil {
+		t.Fatalf("登录进度 = %q, %v, %v，应登录成功", status, account, err)
+	}
+	if _, err := ValidateAdminSession(token); err != nil {
+		t.Fatalf("登录成功的会话应有效: %v", err)
+	}
+}
+
+func TestValidateAdminSessionRefusesExpiredAndRevokedSessions(t *testing.T) {
+	cases := map[string]func(t *testing.T, account *entity.AdminAccount, token string){
+		"超过最长有效期": func(t *testing.T, account *entity.AdminAccount, token string) {
+			global.DB.Model(&entity.AdminSession{}).Where("id = ?", hashAdminToken(token)).
+				Update("expires_at", time.Now().Add(-time.Second))
+		},
+		"空闲超时": func(t *testing.T, account *entity.AdminAccount, token string) {
+			global.DB.Model(&entity.AdminSession{}).Where("id = ?", hashAdminToken(token)).
+				Update("last_active_at", time.Now().Add(-global.Config.Admin.SessionIdleTimeout-time.Second))
+		},
+		"退出登录": func(t *testing.T, account *entity.AdminAccount, token string) {
+			if err := AdminLogout(token); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"停用管理员账号": func(t *testing.T, account *entity.AdminAccount, token string) {
+			inactive := false
+			if _, err := UpdateAdminAccount(account.ID, &request.UpdateAdminAccountRequest{IsActive: &inactive}); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"删除管理员账号": func(t *testing.T, account *entity.AdminAccount, token string) {
+			if err := DeleteAdminAccount(account.ID); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"停用用户": func(t *testing.T, account *entity.AdminAccount, token string) {
+			global.DB.Model(&entity.User{}).Where("id = ?", account.UserID).Update("is_active", false)
+		},
+	}
+	for name, revoke := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestDB(t)
+			hub := setupFakeHub(t)
+			account, _ := createTestAdmin(t, hub, "admin", false)
+			token := createActiveAdminSession(t, account)
+			if _, err := ValidateAdminSession(token); err != nil {
+				t.Fatalf("有效的会话被拒绝: %v", err)
+			}
+
+			revoke(t, account, token)
+
+			if _, err := ValidateAdminSession(token); !errors.Is(err, errs.ErrAdminSessionInvalid) {
+				t.Fatalf("会话校验返回 %v，应为 ErrAdminSessionInvalid", err)
+			}
+			var count int64
+			global.DB.Model(&entity.AdminSession{}).Where("id = ?", hashAdminToken(token)).Count(&count)
+			if count != 0 {
+				t.Error("无效的会话应被删除")
+			}
+		})
+	}
+}
@@ -122,6 +122,18 @@ func DeleteAPIKey(apiKeyID uint) error {
 		return fmt.Errorf("删除API密钥失败: %w", err)
 	}
 
+	// 删除该密钥的事件订阅
+	if err := tx.Where("api_key_id = ?", key.ID).Delete(&entity.EventSubscription{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("删除事件订阅失败: %w", err)
+	}
+
+	// 删除关联的OIDC客户端
+	if err := deleteOIDCClients(tx, "api_key_id = ?", key.ID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("删除OIDC客户端失败: %w", err)
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		return fmt.Errorf("提交事务失败: %w", err)
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// attemptLimiterSweepSize 记录数超过该值时清理已过期的计数窗口
+const attemptLimiterSweepSize = 10000
+
+const (
+	// pushLoginAttemptsPerUser 每个用户名在窗口内允许发起的推送登录次数，防止反复推送直到用户误点批准
+	pushLoginAttemptsPerUser = 5
+	// pushLoginAttemptsPerIP 每个来源IP在窗口内允许发起的推送登录次数
+	pushLoginAttemptsPerIP = 20
+	// pushLoginAttemptWindow 推送登录的计数窗口
+	pushLoginAttemptWindow = 15 * time.Minute
+)
+
+// attemptLimiter 按键统计固定时间窗口内的尝试次数，用于限制恢复码猜测、推送登录确认等未认证操作
+type attemptLimiter struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]*attemptWindow
+}
+
+// attemptKey 计数键及该键在窗口内允许的尝试次数
+type attemptKey struct {
+	key string
+	max int
+}
+
+// attemptWindow 单个键在当前窗口内的尝试次数
+type attemptWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// newAttemptLimiter 创建按 window 计数的限制器，各键的上限由 Allow 的参数给出
+func newAttemptLimiter(window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		window:   window,
+		attempts: make(map[string]*attemptWindow),
+	}
+}
+
+// Allow 对所有键各记录一次尝试，任一键在窗口内的次数超过上限时返回 false
+// 被拒绝的尝试同样计入每个键，避免某个键先达到上限后其余键不再计数
+func (l *attemptLimiter) Allow(keys ...attemptKey) bool {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.attempts) > attemptLimiterSweepSize {
+		for key, w := range l.attempts {
+			if !now.Before(w.resetAt) {
+				delete(l.attempts, key)
+			}
+		}
+	}
+
+	allowed := true
+	for _, k := range keys {
+		w, ok := l.attempts[k.key]
+		if !ok || !now.Before(w.resetAt) {
+			w = &attemptWindow{resetAt: now.Add(l.window)}
+			l.attempts[k.key] = w
+		}
+		w.count++
+		if w.count > k.max {
+			allowed = false
+		}
+	}
+	return allowed
+}
+
+// pushLoginAttemptKeys 推送登录按用户名和来源IP计数的键
+func pushLoginAttemptKeys(username, clientIP string) []attemptKey {
+	return []attemptKey{
+		{key: "user:" + username, max: pushLoginAttemptsPerUser},
+		{key: "ip:" + clientIP, max: pushLoginAttemptsPerIP},
+	}
+}
+
+// clientSubnet 返回来源IP所在的网段，IPv4 取 /24，IPv6 取 /64，无法解析时原样返回
+func clientSubnet(clientIP string) string {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return clientIP
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiterCountsEveryKey(t *testing.T) {
+	limiter := newAttemptLimiter(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow(attemptKey{"user:alice", 2}, attemptKey{"ip:192.0.2.1", 5}) {
+			t.Fatalf("第 %d 次尝试应被允许", i+1)
+		}
+	}
+	// 用户名达到上限后继续尝试，来源IP仍然计数
+	for i := 0; i < 3; i++ {
+		if limiter.Allow(attemptKey{"user:alice", 2}, attemptKey{"ip:192.0.2.1", 5}) {
+			t.Fatal("超出用户名上限的尝试应被拒绝")
+		}
+	}
+	if limiter.Allow(attemptKey{"user:bob", 2}, attemptKey{"ip:192.0.2.1", 5}) {
+		t.Fatal("来源IP已达到上限，换用户名也应被拒绝")
+	}
+	if !limiter.Allow(attemptKey{"user:bob", 2}, attemptKey{"ip:192.0.2.2", 5}) {
+		t.Fatal("其他来源IP应被允许")
+	}
+}
+
+func TestAttemptLimiterWindowResets(t *testing.T) {
+	limiter := newAttemptLimiter(50 * time.Millisecond)
+	key := attemptKey{"ip:192.0.2.1", 1}
+
+	if !limiter.Allow(key) || limiter.Allow(key) {
+		t.Fatal("窗口内只允许一次尝试")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !limiter.Allow(key) {
+		t.Fatal("窗口结束后应重新计数")
+	}
+}
+
+func TestClientSubnet(t *testing.T) {
+	cases := map[string]string{
+		"192.0.2.10":          "192.0.2.0/24",
+		"2001:db8:1:2:3::4":   "2001:db8:1:2::/64",
+		"::ffff:198.51.100.7": "198.51.100.0/24",
+		"unknown":             "unknown",
+	}
+	for ip, want := range cases {
+		if got := clientSubnet(ip); got != want {
+			t.Errorf("clientSubnet(%q) = %q，应为 %q", ip, got, want)
+		}
+	}
+}
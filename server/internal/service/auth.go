@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,6 +28,9 @@ const (
 	CallbackMaxRetries = 3                // 最大重试次数
 )
 
+// callbackRetryDelays 回调失败后的递增重试间隔
+var callbackRetryDelays = []time.Duration{5 * time.Second, 10 * time.Second, 30 * time.Second}
+
 // ValidateAPIKey 验证API密钥
 func ValidateAPIKey(apiKey string) (*entity.APIKey, error) {
 	var key entity.APIKey
@@ -70,20 +74,47 @@ func ValidateAuthKey(authKey string, deviceID uint, challenge string) (*entity.D
 	if !device.DeviceGroup.IsActive {
 		return nil, fmt.Errorf("设备组未激活")
 	}
+	if device.DeviceGroup.Quarantined {
+		return nil, errs.ErrDeviceGroupQuarantined
+	}
+
+	secrets, err := OpenDeviceGroupSecrets(device.DeviceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	// 优先使用设备组独立的HMAC密钥，U盘尚未保存该密钥时兼容旧版本的全局密钥
+	hmacKeys := make([]string, 0, 2)
+	if secrets.AuthSecret != "" {
+		hmacKeys = append(hmacKeys, secrets.AuthSecret)
+	}
+	if secrets.AuthSecret == "" || device.DeviceGroup.AuthSecretPending {
+		hmacKeys = append(hmacKeys, global.Config.Security.EncryptionKey)
+	}
 
-	// 使用设备组的认证密钥进行验证
-	if err := auth.ValidateAuthToken(
+	matched, err := auth.ValidateAuthToken(
 		authKey,
 		challenge,
-		device.DeviceGroup.OnceKey,
-		device.DeviceGroup.TOTPSecret,
+		secrets.OnceKey,
+		secrets.TOTPSecret,
 		device.SerialNumber,
 		device.VolumeSerialNumber,
-		global.Config.Security.EncryptionKey,
-	); err != nil {
+		hmacKeys...,
+	)
+	if err != nil {
 		return nil, fmt.Errorf("认证token验证失败: %w", err)
 	}
 
+	// U盘已使用独立密钥签名，此后不再接受全局密钥
+	if matched == 0 && secrets.AuthSecret != "" && device.DeviceGroup.AuthSecretPending {
+		if err := global.DB.Model(&entity.DeviceGroup{}).Where("id = ?", device.DeviceGroup.ID).
+			Update("auth_secret_pending", false).Error; err != nil {
+			logger.Logger.Error("更新设备组HMAC密钥状态失败", "device_group_id", device.DeviceGroup.ID, "error", err)
+		} else {
+			device.DeviceGroup.AuthSecretPending = false
+		}
+	}
+
 	return &device, nil
 }
 
@@ -141,6 +172,14 @@ func ProcessAuthResponse(sessionID string, authResp *messages.AuthResponseMessag
 			"result":               consts.AuthResultFailure,
 		}
 		global.DB.Model(&session).Updates(updates) // 尝试更新，忽略错误
+		recordAuthFailure(session.UserID, "认证密钥验证失败")
+
+		// 使用已轮换掉的旧OnceKey签名说明密钥已被复制
+		if device.DeviceGroupID != nil {
+			if qErr := quarantineOnReplayedOnceKey(*device.DeviceGroupID, device.ID, device.SerialNumber, authResp.UsedKey); qErr != nil {
+				logger.Logger.Error("检查OnceKey重放失败", "session_id", sessionID, "error", qErr)
+			}
+		}
 
 		return fmt.Errorf("认证密钥验证失败: %w", err)
 	}
@@ -169,6 +208,7 @@ func ProcessAuthResponse(sessionID string, authResp *messages.AuthResponseMessag
 			if err := global.DB.Model(&session).Updates(updates).Error; err != nil {
 				return fmt.Errorf("更新认证会话失败: %w", err)
 			}
+			recordAuthFailure(session.UserID, "设备权限与请求的操作不匹配")
 			return fmt.Errorf("设备权限与请求的操作不匹配")
 		}
 	}
@@ -192,6 +232,7 @@ func ProcessAuthResponse(sessionID string, authResp *messages.AuthResponseMessag
 			updates["status"] = consts.AuthStatusFailed
 			logger.Logger.Info("认证失败", "session_id", sessionID, "error", authResp.Error)
 		}
+		recordAuthFailure(session.UserID, authResp.Error)
 	}
 
 	// 更新数据库
@@ -217,8 +258,8 @@ func StartAuth(req *request.AuthRequest, apiKey *entity.APIKey, clientIP string)
 	// 查找用户所有激活的在线设备（通过设备组）
 	var onlineDevices []entity.Device
 	err := global.DB.Preload("DeviceGroup").Joins("JOIN device_groups ON devices.device_group_id = device_groups.id").
-		Where("device_groups.user_id = ? AND devices.is_active = ? AND devices.is_online = ? AND device_groups.is_active = ?",
-			user.ID, true, true, true).Find(&onlineDevices).Error
+		Where("device_groups.user_id = ? AND devices.is_active = ? AND devices.is_online = ? AND device_groups.is_active = ? AND device_groups.quarantined = ?",
+			user.ID, true, true, true, false).Find(&onlineDevices).Error
 	if err != nil {
 		return nil, fmt.Errorf("查询在线设备失败: %w", err)
 	}
@@ -248,6 +289,12 @@ func StartAuth(req *request.AuthRequest, apiKey *entity.APIKey, clientIP string)
 		}
 	}
 
+	// 管理后台登录由服务端自身发起，不关联API密钥
+	var apiKeyID *uint
+	if apiKey != nil {
+		apiKeyID = &apiKey.ID
+	}
+
 	// 生成会话ID
 	sessionID := uuid.New().String()
 
@@ -262,9 +309,10 @@ func StartAuth(req *request.AuthRequest, apiKey *entity.APIKey, clientIP string)
 	session := entity.AuthSession{
 		ID:          sessionID,
 		UserID:      user.ID,
-		APIKeyID:    apiKey.ID,
+		APIKeyID:    apiKeyID,
 		Challenge:   req.Challenge,
 		Action:      req.Action,
+		Message:     req.Message,
 		Status:      consts.AuthStatusPending,
 		ExpiresAt:   expiresAt,
 		CallbackURL: req.CallbackURL,
@@ -301,6 +349,88 @@ func StartAuth(req *request.AuthRequest, apiKey *entity.APIKey, clientIP string)
 	return &session, nil
 }
 
+// PendingAuthRequests 获取用户仍在等待设备响应的认证请求，设备重连后重新下发
+func PendingAuthRequests(userID uint) ([]messages.AuthRequestMessage, error) {
+	var sessions []entity.AuthSession
+	err := global.DB.Preload("User").
+		Where("user_id = ? AND status = ? AND expires_at > ?", userID, consts.AuthStatusPending, time.Now()).
+		Order("created_at").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询待处理认证会话失败: %w", err)
+	}
+
+	requests := make([]messages.AuthRequestMessage, 0, len(sessions))
+	for _, session := range sessions {
+		remaining := int(time.Until(session.ExpiresAt).Seconds())
+		if remaining <= 0 {
+			continue
+		}
+
+		var username string
+		if session.User != nil {
+			username = session.User.Username
+		}
+		requests = append(requests, messages.AuthRequestMessage{
+			RequestID: session.ID,
+			Username:  username,
+			Challenge: session.Challenge,
+			Action:    session.Action,
+			Message:   session.Message,
+			Timeout:   remaining, // 按剩余有效期下发
+		})
+	}
+	return requests, nil
+}
+
+// FailAuthSessionsForDevices 设备被拔出或隔离时，将无法再完成的认证会话标记为失败并发送回调
+// 这些设备正在处理的会话直接失败；等待响应的会话只在用户没有其他已连接的设备时失败，否则留给其他设备响应
+func FailAuthSessionsForDevices(userID uint, deviceIDs ...uint) (int64, error) {
+	failPending := true
+	if hub := GetWSHub(); hub != nil {
+		if deviceID, ok := hub.GetUserDeviceID(userID); ok && !slices.Contains(deviceIDs, deviceID) {
+			failPending = false
+		}
+	}
+
+	query := global.DB.Where("status IN ? AND responding_device_id IN ?",
+		[]string{consts.AuthStatusProcessing, consts.AuthStatusProcessingOnceKey}, deviceIDs)
+	if failPending {
+		query = query.Or("status = ?", consts.AuthStatusPending)
+	}
+
+	var sessions []entity.AuthSession
+	if err := global.DB.Where("user_id = ? AND expires_at > ?", userID, time.Now()).Where(query).
+		Find(&sessions).Error; err != nil {
+		return 0, fmt.Errorf("查询认证会话失败: %w", err)
+	}
+
+	var failed int64
+	for _, session := range sessions {
+		// 按查询时的状态更新，期间已完成的会话不受影响
+		result := global.DB.Model(&entity.AuthSession{}).
+			Where("id = ? AND status = ?", session.ID, session.Status).
+			Updates(map[string]interface{}{
+				"status": consts.AuthStatusFailed,
+				"result": consts.AuthResultFailure,
+			})
+		if result.Error != nil {
+			return failed, fmt.Errorf("更新认证会话失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		failed++
+
+		if session.CallbackURL != "" {
+			session.Status = consts.AuthStatusFailed
+			session.Result = consts.AuthResultFailure
+			go sendAuthCallback(&session, "")
+		}
+	}
+	return failed, nil
+}
+
 // sendAuthCallback 发送认证回调
 func sendAuthCallback(session *entity.AuthSession, serialNumber string) {
 	// 查找对应的API密钥作为签名密钥
@@ -341,17 +471,15 @@ func sendAuthCallback(session *entity.AuthSession, serialNumber string) {
 		}
 
 		if i < CallbackMaxRetries-1 {
-			// 递增重试间隔: 5s, 10s, 30s
-			delays := []time.Duration{5 * time.Second, 10 * time.Second, 30 * time.Second}
-			time.Sleep(delays[i])
+			time.Sleep(callbackRetryDelays[i])
 		}
 	}
 
 	logger.Logger.Error("回调失败，已达到最大重试次数", "session_id", session.ID, "url", session.CallbackURL)
 }
 
-// sendHTTPCallback 发送HTTP回调请求
-func sendHTTPCallback(url string, req *messages.CallbackRequest) bool {
+// sendHTTPCallback 发送HTTP回调请求，用于认证回调和事件推送
+func sendHTTPCallback(url string, req interface{}) bool {
 	data, err := json.Marshal(req)
 	if err != nil {
 		logger.Logger.Error("序列化回调请求失败", "error", err)
@@ -403,6 +531,12 @@ func CompleteOnceKeyUpdateAuth(requestID string, success bool, errorMessage stri
 		updates["status"] = consts.AuthStatusCompleted
 		updates["result"] = consts.AuthResultSuccess
 		logger.Logger.Info("OnceKey更新确认成功，认证完成", "session_id", requestID)
+
+		if session.RespondingDeviceID != nil {
+			if err := confirmOnceKeyUpdate(*session.RespondingDeviceID); err != nil {
+				logger.Logger.Error("记录OnceKey确认状态失败", "session_id", requestID, "error", err)
+			}
+		}
 	} else {
 		// OnceKey更新确认失败，认证失败
 		updates["status"] = consts.AuthStatusFailed
@@ -444,8 +578,12 @@ func VerifyAuth(req *request.VerifyAuthRequest) (*entity.AuthSession, error) {
 
 // getAPIKeyBySession 通过会话获取API密钥
 func getAPIKeyBySession(session *entity.AuthSession) (string, error) {
+	if session.APIKeyID == nil {
+		return "", fmt.Errorf("认证会话未关联API密钥")
+	}
+
 	var apiKey entity.APIKey
-	err := global.DB.Where("id = ?", session.APIKeyID).First(&apiKey).Error
+	err := global.DB.Where("id = ?", *session.APIKeyID).First(&apiKey).Error
 	if err != nil {
 		return "", fmt.Errorf("查找API密钥失败: %w", err)
 	}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// createTestAuthSession 创建认证会话，respondingDeviceID 为 0 时不关联响应设备
+func createTestAuthSession(t *testing.T, userID uint, status string, respondingDeviceID uint, apiKey *entity.APIKey, callbackURL string) *entity.AuthSession {
+	t.Helper()
+	session := &entity.AuthSession{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Challenge:   "challenge",
+		Action:      "login",
+		Status:      status,
+		CallbackURL: callbackURL,
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}
+	if respondingDeviceID != 0 {
+		session.RespondingDeviceID = &respondingDeviceID
+	}
+	if apiKey != nil {
+		session.APIKeyID = &apiKey.ID
+	}
+	if err := global.DB.Create(session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+// createTestAPIKey 创建启用的普通API密钥
+func createTestAPIKey(t *testing.T, name string) *entity.APIKey {
+	t.Helper()
+	key := &entity.APIKey{Name: name, APIKey: "key-" + name + "-" + uuid.New().String(), IsActive: true}
+	if err := global.DB.Create(key).Error; err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// sessionStatus 返回认证会话当前的状态
+func sessionStatus(t *testing.T, id string) string {
+	t.Helper()
+	var session entity.AuthSession
+	if err := global.DB.First(&session, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return session.Status
+}
+
+// callbackRecorder 接收认证回调的测试服务器
+func callbackRecorder(t *testing.T) (string, <-chan messages.CallbackRequest) {
+	t.Helper()
+	received := make(chan messages.CallbackRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req messages.CallbackRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received <- req
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+func TestFailAuthSessionsForDevicesWithoutOtherDevice(t *testing.T) {
+	setupTestDB(t)
+	setupFakeHub(t)
+
+	user := createTestUser(t, "alice")
+	_, pulled, _ := createTestDeviceGroup(t, user, "SERIAL-PULLED")
+	_, other, _ := createTestDeviceGroup(t, user, "SERIAL-OTHER")
+	apiKey := createTestAPIKey(t, "app")
+	url, callbacks := callbackRecorder(t)
+
+	pending := createTestAuthSession(t, user.ID, consts.AuthStatusPending, 0, apiKey, url)
+	processing := createTestAuthSession(t, user.ID, consts.AuthStatusProcessing, pulled.ID, apiKey, url)
+	otherProcessing := createTestAuthSession(t, user.ID, consts.AuthStatusProcessing, other.ID, apiKey, url)
+
+	failed, err := FailAuthSessionsForDevices(user.ID, pulled.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 2 {
+		t.Errorf("失败的会话数 = %d，应为 2", failed)
+	}
+	if got := sessionStatus(t, pending.ID); got != consts.AuthStatusFailed {
+		t.Errorf("等待中的会话状态 = %s", got)
+	}
+	if got := sessionStatus(t, processing.ID); got != consts.AuthStatusFailed {
+		t.Errorf("拔出设备处理中的会话状态 = %s", got)
+	}
+	if got := sessionStatus(t, otherProcessing.ID); got != consts.AuthStatusProcessing {
+		t.Errorf("其他设备处理中的会话状态 = %s", got)
+	}
+
+	// 每个失败的会话都发送回调
+	notified := map[string]string{}
+	for range 2 {
+		select {
+		case req := <-callbacks:
+			notified[req.SessionID] = req.Status
+		case <-time.After(2 * time.Second):
+			t.Fatalf("只收到 %d 个回调", len(notified))
+		}
+	}
+	if notified[pending.ID] != "failed" || notified[processing.ID] != "failed" {
+		t.Errorf("回调 = %v", notified)
+	}
+}
+
+func TestFailAuthSessionsForDevicesKeepsPendingForOnlineDevice(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+
+	user := createTestUser(t, "alice")
+	_, pulled, _ := createTestDeviceGroup(t, user, "SERIAL-PULLED")
+	_, other, _ := createTestDeviceGroup(t, user, "SERIAL-OTHER")
+	hub.connect(user.ID, other.ID)
+
+	pending := createTestAuthSession(t, user.ID, consts.AuthStatusPending, 0, nil, "")
+	processing := createTestAuthSession(t, user.ID, consts.AuthStatusProcessing, pulled.ID, nil, "")
+
+	if _, err := FailAuthSessionsForDevices(user.ID, pulled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionStatus(t, pending.ID); got != consts.AuthStatusPending {
+		t.Errorf("用户仍有在线设备时等待中的会话状态 = %s", got)
+	}
+	if got := sessionStatus(t, processing.ID); got != consts.AuthStatusFailed {
+		t.Errorf("拔出设备处理中的会话状态 = %s", got)
+	}
+}
+
+func TestQuarantineFailsPendingSessionsOfGroupDevices(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+
+	user := createTestUser(t, "alice")
+	group, device, _ := createTestDeviceGroup(t, user, "SERIAL-CLONED")
+	hub.connect(user.ID, device.ID)
+	url, callbacks := callbackRecorder(t)
+	pending := createTestAuthSession(t, user.ID, consts.AuthStatusPending, 0, createTestAPIKey(t, "app"), url)
+
+	// 用户当前连接的设备属于被隔离的设备组，等待中的会话无法再完成
+	if err := QuarantineDeviceGroup(group.ID, "test", device.ID, device.SerialNumber, "测试"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionStatus(t, pending.ID); got != consts.AuthStatusFailed {
+		t.Errorf("等待中的会话状态 = %s", got)
+	}
+	select {
+	case req := <-callbacks:
+		if req.SessionID != pending.ID || req.Status != "failed" {
+			t.Errorf("回调 = %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到回调")
+	}
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// MaxBulkImportRows 单次批量导入允许的最大行数
+const MaxBulkImportRows = 10000
+
+// bulkUserChange 批量导入中单个用户的变更
+type bulkUserChange struct {
+	record *request.BulkUserRecord
+	user   *entity.User // 已有用户，为空表示新建
+}
+
+// bulkDeviceGroupChange 批量导入中单个设备组的变更
+type bulkDeviceGroupChange struct {
+	group     *entity.DeviceGroup
+	updates   map[string]interface{}
+	oldUserID uint
+	newUserID uint
+}
+
+// ImportUsers 批量导入用户，按用户名新建或更新；存在行级错误或 dryRun 时不写入数据库
+func ImportUsers(records []request.BulkUserRecord, dryRun bool) (*response.BulkImportResult, error) {
+	if len(records) > MaxBulkImportRows {
+		return nil, errs.ErrBulkTooManyRows
+	}
+
+	result := &response.BulkImportResult{DryRun: dryRun, Total: len(records)}
+
+	usernames := make([]string, 0, len(records))
+	for _, record := range records {
+		usernames = append(usernames, record.Username)
+	}
+
+	// 已删除的用户仍占用用户名，需要一并查询
+	var existing []entity.User
+	if err := global.DB.Unscoped().Where("username IN ?", usernames).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	existingByName := make(map[string]*entity.User, len(existing))
+	for i := range existing {
+		existingByName[existing[i].Username] = &existing[i]
+	}
+
+	seen := make(map[string]int, len(records))
+	changes := make([]bulkUserChange, 0, len(records))
+	for i := range records {
+		record := &records[i]
+		row := i + 1
+
+		if record.Username == "" || strings.TrimSpace(record.Username) != record.Username || len(record.Username) > 255 {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "username", Message: "用户名不能为空、不能包含首尾空格且不超过255个字符"})
+			continue
+		}
+		if first, ok := seen[record.Username]; ok {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "username", Message: fmt.Sprintf("用户名与第%d行重复", first)})
+			continue
+		}
+		seen[record.Username] = row
+
+		if msg := validateBulkPermissions(record.Permissions); msg != "" {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "permissions", Message: msg})
+			continue
+		}
+
+		user, ok := existingByName[record.Username]
+		if !ok {
+			result.Created++
+			changes = append(changes, bulkUserChange{record: record})
+			continue
+		}
+		if user.DeletedAt.Valid {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "username", Message: "用户名已被已删除的用户占用"})
+			continue
+		}
+
+		if (record.Permissions == nil || slices.Equal(record.Permissions, user.Permissions)) &&
+			(record.IsActive == nil || *record.IsActive == user.IsActive) {
+			result.Unchanged++
+			continue
+		}
+		result.Updated++
+		changes = append(changes, bulkUserChange{record: record, user: user})
+	}
+
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+
+	var deactivated []uint
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			record := change.record
+
+			if change.user == nil {
+				user := entity.User{
+					Username:    record.Username,
+					Permissions: record.Permissions,
+					IsActive:    true,
+				}
+				if user.Permissions == nil {
+					user.Permissions = []string{}
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return fmt.Errorf("创建用户 %s 失败: %w", record.Username, err)
+				}
+				// is_active 带有默认值，创建时零值会被忽略，需单独更新
+				if record.IsActive != nil && !*record.IsActive {
+					if err := tx.Model(&user).Update("is_active", false).Error; err != nil {
+						return fmt.Errorf("停用用户 %s 失败: %w", record.Username, err)
+					}
+				}
+				continue
+			}
+
+			updates := make(map[string]interface{})
+			if record.Permissions != nil {
+				jsonData, err := json.Marshal(record.Permissions)
+				if err != nil {
+					return fmt.Errorf("序列化权限失败: %w", err)
+				}
+				updates["permissions"] = string(jsonData)
+			}
+			if record.IsActive != nil {
+				updates["is_active"] = *record.IsActive
+				if change.user.IsActive && !*record.IsActive {
+					deactivated = append(deactivated, change.user.ID)
+				}
+			}
+			if err := tx.Model(change.user).Updates(updates).Error; err != nil {
+				return fmt.Errorf("更新用户 %s 失败: %w", record.Username, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 停用的用户强制断开其设备组下设备的连接
+	if hub := GetWSHub(); hub != nil && len(deactivated) > 0 {
+		var devices []entity.Device
+		if err := global.DB.Joins("JOIN device_groups ON devices.device_group_id = device_groups.id").
+			Where("device_groups.user_id IN ?", deactivated).Find(&devices).Error; err != nil {
+			return nil, fmt.Errorf("查询停用用户的设备失败: %w", err)
+		}
+		for _, device := range devices {
+			hub.OnDeviceDisconnect(device.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// ImportDeviceGroups 批量导入设备组的用户关联、名称和权限；存在行级错误或 dryRun 时不写入数据库
+func ImportDeviceGroups(records []request.BulkDeviceGroupRecord, dryRun bool) (*response.BulkImportResult, error) {
+	if len(records) > MaxBulkImportRows {
+		return nil, errs.ErrBulkTooManyRows
+	}
+
+	result := &response.BulkImportResult{DryRun: dryRun, Total: len(records)}
+
+	groupIDs := make([]uint, 0, len(records))
+	usernames := make([]string, 0, len(records))
+	for _, record := range records {
+		groupIDs = append(groupIDs, record.DeviceGroupID)
+		if record.Username != "" {
+			usernames = append(usernames, record.Username)
+		}
+	}
+
+	var groups []entity.DeviceGroup
+	if err := global.DB.Where("id IN ?", groupIDs).Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("查询设备组失败: %w", err)
+	}
+	groupByID := make(map[uint]*entity.DeviceGroup, len(groups))
+	for i := range groups {
+		groupByID[groups[i].ID] = &groups[i]
+	}
+
+	var users []entity.User
+	if len(usernames) > 0 {
+		if err := global.DB.Where("username IN ?", usernames).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("查询用户失败: %w", err)
+		}
+	}
+	userIDByName := make(map[string]uint, len(users))
+	for _, user := range users {
+		userIDByName[user.Username] = user.ID
+	}
+
+	seen := make(map[uint]int, len(records))
+	changes := make([]bulkDeviceGroupChange, 0, len(records))
+	for i := range records {
+		record := &records[i]
+		row := i + 1
+
+		group, ok := groupByID[record.DeviceGroupID]
+		if !ok {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "device_group_id", Message: "设备组不存在"})
+			continue
+		}
+		if first, ok := seen[record.DeviceGroupID]; ok {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "device_group_id", Message: fmt.Sprintf("设备组与第%d行重复", first)})
+			continue
+		}
+		seen[record.DeviceGroupID] = row
+
+		if len(record.Name) > 255 {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "name", Message: "设备组名称不能超过255个字符"})
+			continue
+		}
+		if msg := validateBulkPermissions(record.Permissions); msg != "" {
+			result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "permissions", Message: msg})
+			continue
+		}
+
+		var newUserID uint
+		if record.Username != "" {
+			if newUserID, ok = userIDByName[record.Username]; !ok {
+				result.Errors = append(result.Errors, response.BulkRowError{Row: row, Field: "username", Message: "用户不存在"})
+				continue
+			}
+		}
+
+		change := bulkDeviceGroupChange{group: group, updates: make(map[string]interface{}), newUserID: newUserID}
+		if group.UserID != nil {
+			change.oldUserID = *group.UserID
+		}
+		if change.oldUserID != newUserID {
+			if newUserID > 0 {
+				change.updates["user_id"] = newUserID
+			} else {
+				change.updates["user_id"] = nil
+			}
+		}
+		if record.Name != "" && record.Name != group.Name {
+			change.updates["name"] = record.Name
+		}
+		if record.Permissions != nil && !slices.Equal(record.Permissions, group.Permissions) {
+			jsonData, err := json.Marshal(record.Permissions)
+			if err != nil {
+				return nil, errs.ErrDeviceGroupPermissions
+			}
+			change.updates["permissions"] = string(jsonData)
+		}
+
+		if len(change.updates) == 0 {
+			result.Unchanged++
+			continue
+		}
+		result.Updated++
+		changes = append(changes, change)
+	}
+
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			if err := tx.Model(change.group).Updates(change.updates).Error; err != nil {
+				return fmt.Errorf("更新设备组 %d 失败: %w", change.group.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 事务提交后再推送事件和更新在线设备，避免回滚后状态不一致
+	for _, change := range changes {
+		if change.oldUserID != change.newUserID {
+			if err := notifyDeviceGroupUserChange(change.group.ID, change.oldUserID, change.newUserID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ExportUsers 导出全部用户，格式与 ImportUsers 一致
+func ExportUsers() ([]request.BulkUserRecord, error) {
+	var users []entity.User
+	if err := global.DB.Order("username").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	records := make([]request.BulkUserRecord, 0, len(users))
+	for _, user := range users {
+		permissions := user.Permissions
+		if permissions == nil {
+			permissions = []string{}
+		}
+		isActive := user.IsActive
+		records = append(records, request.BulkUserRecord{
+			Username:    user.Username,
+			Permissions: permissions,
+			IsActive:    &isActive,
+		})
+	}
+	return records, nil
+}
+
+// ExportDeviceGroups 导出全部设备组的用户关联、名称和权限，格式与 ImportDeviceGroups 一致
+func ExportDeviceGroups() ([]request.BulkDeviceGroupRecord, error) {
+	var groups []entity.DeviceGroup
+	if err := global.DB.Preload("User").Order("id").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("查询设备组失败: %w", err)
+	}
+
+	records := make([]request.BulkDeviceGroupRecord, 0, len(groups))
+	for _, group := range groups {
+		record := request.BulkDeviceGroupRecord{
+			DeviceGroupID: group.ID,
+			Name:          group.Name,
+			Permissions:   group.Permissions,
+		}
+		if record.Permissions == nil {
+			record.Permissions = []string{}
+		}
+		if group.User != nil {
+			record.Username = group.User.Username
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// validateBulkPermissions 校验权限列表，返回错误说明
+// CSV 中权限以分号分隔，因此权限本身不能包含分号，保证导出后可以原样导入
+func validateBulkPermissions(permissions []string) string {
+	for _, p := range permissions {
+		if p == "" || strings.TrimSpace(p) != p || strings.Contains(p, ";") {
+			return "权限不能为空、不能包含首尾空格和分号"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+)
+
+// countUsers 返回未删除的用户数量
+func countUsers(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	if err := global.DB.Model(&entity.User{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// loadUserByName 按用户名读取用户
+func loadUserByName(t *testing.T, username string) *entity.User {
+	t.Helper()
+	var user entity.User
+	if err := global.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// failOnWrite 注册在写入满足 match 的记录时返回错误的回调，用于模拟事务中途失败
+func failOnWrite(t *testing.T, match func(db *gorm.DB) bool) {
+	t.Helper()
+	fail := func(db *gorm.DB) {
+		if match(db) {
+			db.AddError(errors.New("模拟写入失败"))
+		}
+	}
+	if err := global.DB.Callback().Create().Before("gorm:create").Register("test:fail_create", fail); err != nil {
+		t.Fatal(err)
+	}
+	if err := global.DB.Callback().Update().Before("gorm:update").Register("test:fail_update", fail); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rowErrors 返回导入结果中的行号和字段
+func rowErrors(result *response.BulkImportResult) []response.BulkRowError {
+	got := make([]response.BulkRowError, 0, len(result.Errors))
+	for _, e := range result.Errors {
+		got = append(got, response.BulkRowError{Row: e.Row, Field: e.Field})
+	}
+	return got
+}
+
+func TestImportUsersReportsRowErrorsWithoutWriting(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "existing")
+	deleted := createTestUser(t, "deleted")
+	if err := global.DB.Delete(deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	records := []request.BulkUserRecord{
+		{Username: "alice", Permissions: []string{"login"}},
+		{Username: ""},
+		{Username: " bob"},
+		{Username: "alice"},
+		{Username: "carol", Permissions: []string{"a;b"}},
+		{Username: "deleted"},
+		{Username: "existing", Permissions: []string{"login"}},
+	}
+	want := []response.BulkRowError{
+		{Row: 2, Field: "username"},
+		{Row: 3, Field: "username"},
+		{Row: 4, Field: "username"},
+		{Row: 5, Field: "permissions"},
+		{Row: 6, Field: "username"},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		result, err := ImportUsers(records, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rowErrors(result); !slices.Equal(got, want) {
+			t.Fatalf("dryRun = %v 时行级错误 = %v，应为 %v", dryRun, got, want)
+		}
+		if result.Total != len(records) || result.Created != 1 || result.Updated != 1 {
+			t.Errorf("dryRun = %v 时导入结果 = %+v", dryRun, result)
+		}
+	}
+
+	if count := countUsers(t); count != 1 {
+		t.Errorf("用户 %d 个，存在行级错误时不应写入任何数据", count)
+	}
+	if got := loadUserByName(t, "existing"); len(got.Permissions) != 0 {
+		t.Errorf("已有用户的权限被修改为 %v", got.Permissions)
+	}
+}
+
+func TestImportUsersDryRunDoesNotWrite(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "existing")
+
+	inactive := false
+	records := []request.BulkUserRecord{
+		{Username: "alice"},
+		{Username: "existing", IsActive: &inactive},
+	}
+	result, err := ImportUsers(records, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || result.Created != 1 || result.Updated != 1 || len(result.Errors) != 0 {
+		t.Fatalf("试运行结果 = %+v", result)
+	}
+	if count := countUsers(t); count != 1 {
+		t.Errorf("用户 %d 个，试运行不应创建用户", count)
+	}
+	if !loadUserByName(t, "existing").IsActive {
+		t.Error("试运行不应停用用户")
+	}
+}
+
+func TestImportUsersRollsBackOnWriteFailure(t *testing.T) {
+	setupTestDB(t)
+	setupFakeHub(t)
+	createTestUser(t, "existing")
+	failOnWrite(t, func(db *gorm.DB) bool {
+		user, ok := db.Statement.Dest.(*entity.User)
+		return ok && user.Username == "boom"
+	})
+
+	inactive := false
+	records := []request.BulkUserRecord{
+		{Username: "alice"},
+		{Username: "existing", IsActive: &inactive},
+		{Username: "boom"},
+	}
+	if _, err := ImportUsers(records, false); err == nil {
+		t.Fatal("写入失败时应返回错误")
+	}
+	if count := countUsers(t); count != 1 {
+		t.Errorf("用户 %d 个，写入失败时应回滚已创建的用户", count)
+	}
+	if !loadUserByName(t, "existing").IsActive {
+		t.Error("写入失败时应回滚对已有用户的修改")
+	}
+}
+
+func TestImportDeviceGroupsReportsRowErrorsWithoutWriting(t *testing.T) {
+	setupTestDB(t)
+	setupFakeHub(t)
+	alice := createTestUser(t, "alice")
+	deleted := createTestUser(t, "deleted")
+	if err := global.DB.Delete(deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	group, _, _ := createTestDeviceGroup(t, alice, "SN1", "login")
+	other, _, _ := createTestDeviceGroup(t, alice, "SN2")
+
+	records := []request.BulkDeviceGroupRecord{
+		{DeviceGroupID: group.ID, Name: "renamed", Username: "", Permissions: []string{}},
+		{DeviceGroupID: 9999, Username: "alice"},
+		{DeviceGroupID: group.ID, Username: "alice"},
+		{DeviceGroupID: other.ID, Username: "nobody"},
+		{DeviceGroupID: other.ID, Username: "alice", Permissions: []string{" login"}},
+	}
+	// 已删除的用户不能再关联设备组
+	deletedRecords := []request.BulkDeviceGroupRecord{{DeviceGroupID: other.ID, Username: "deleted"}}
+	want := []response.BulkRowError{
+		{Row: 2, Field: "device_group_id"},
+		{Row: 3, Field: "device_group_id"},
+		{Row: 4, Field: "username"},
+		{Row: 5, Field: "device_group_id"},
+	}
+
+	result, err := ImportDeviceGroups(records, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rowErrors(result); !slices.Equal(got, want) {
+		t.Fatalf("行级错误 = %v，应为 %v", got, want)
+	}
+	result, err = ImportDeviceGroups(deletedRecords, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rowErrors(result); !slices.Equal(got, []response.BulkRowError{{Row: 1, Field: "username"}}) {
+		t.Fatalf("关联已删除用户的行级错误 = %v", got)
+	}
+
+	got := loadDeviceGroup(t, group.ID)
+	if got.Name != group.Name || got.UserID == nil || *got.UserID != alice.ID || !slices.Equal(got.Permissions, []string{"login"}) {
+		t.Errorf("存在行级错误时设备组被修改: %+v", got)
+	}
+}
+
+func TestImportDeviceGroupsRollsBackOnWriteFailure(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	first, firstDevice, _ := createTestDeviceGroup(t, alice, "SN1")
+	second, _, _ := createTestDeviceGroup(t, alice, "SN2")
+	hub.connect(alice.ID, firstDevice.ID)
+	failOnWrite(t, func(db *gorm.DB) bool {
+		group, ok := db.Statement.Model.(*entity.DeviceGroup)
+		return ok && group.ID == second.ID
+	})
+
+	records := []request.BulkDeviceGroupRecord{
+		{DeviceGroupID: first.ID, Username: "bob"},
+		{DeviceGroupID: second.ID, Username: "bob"},
+	}
+	if _, err := ImportDeviceGroups(records, false); err == nil {
+		t.Fatal("写入失败时应返回错误")
+	}
+	if got := loadDeviceGroup(t, first.ID); got.UserID == nil || *got.UserID != alice.ID {
+		t.Errorf("写入失败时应回滚设备组 %d 的用户关联，实际关联用户 %v，而不是 %d", first.ID, got.UserID, bob.ID)
+	}
+	if len(hub.disconnected) != 0 {
+		t.Error("回滚后不应断开在线设备")
+	}
+}
+
+func TestBulkExportImportRoundTrip(t *testing.T) {
+	setupTestDB(t)
+	setupFakeHub(t)
+	alice := createTestUser(t, "alice")
+	if err := global.DB.Model(alice).Select("permissions").Updates(&entity.User{Permissions: []string{"login", "sign"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	bob := createTestUser(t, "bob")
+	if err := global.DB.Model(bob).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	createTestDeviceGroup(t, alice, "SN1", "login")
+	unlinked, _, _ := createTestDeviceGroup(t, bob, "SN2")
+	if err := global.DB.Model(unlinked).Update("user_id", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := ExportUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := ExportDeviceGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || len(groups) != 2 {
+		t.Fatalf("导出 %d 个用户和 %d 个设备组", len(users), len(groups))
+	}
+
+	// 原样导入已导出的数据不产生任何变更
+	userResult, err := ImportUsers(users, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userResult.Unchanged != 2 || userResult.Created+userResult.Updated != 0 || len(userResult.Errors) != 0 {
+		t.Errorf("导入导出的用户结果 = %+v，应全部不变", userResult)
+	}
+	groupResult, err := ImportDeviceGroups(groups, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupResult.Unchanged != 2 || groupResult.Updated != 0 || len(groupResult.Errors) != 0 {
+		t.Errorf("导入导出的设备组结果 = %+v，应全部不变", groupResult)
+	}
+
+	// 导入到空库后再次导出，用户数据保持一致
+	setupTestDB(t)
+	if _, err := ImportUsers(users, false); err != nil {
+		t.Fatal(err)
+	}
+	reexported, err := ExportUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reexported) != len(users) {
+		t.Fatalf("再次导出 %d 个用户，应为 %d 个", len(reexported), len(users))
+	}
+	for i, user := range users {
+		got := reexported[i]
+		if got.Username != user.Username || !slices.Equal(got.Permissions, user.Permissions) || *got.IsActive != *user.IsActive {
+			t.Errorf("再次导出的用户 = %+v，应为 %+v", got, user)
+		}
+	}
+}
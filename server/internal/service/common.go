@@ -2,8 +2,11 @@ package service
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
+	"gorm.io/gorm"
+
 	"github.com/hang666/EasyUKey/shared/pkg/messages"
 )
 
@@ -12,6 +15,7 @@ var globalWSHub WSHubInterface
 // WSHubInterface WebSocket Hub接口
 type WSHubInterface interface {
 	IsUserOnline(userID uint) bool
+	GetUserDeviceID(userID uint) (uint, bool)
 	IsDeviceOnline(deviceID uint) bool
 	SendToUser(userID uint, data []byte) error
 	OnDeviceConnect(deviceID uint) error
@@ -40,3 +44,16 @@ func SendWSMessage(msgType string, data interface{}) ([]byte, error) {
 
 	return json.Marshal(msg)
 }
+
+// likeEscaper 转义 LIKE 模式中的转义字符和通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeContains 返回 column 包含 value 的 LIKE 条件和参数，value 中的 % 和 _ 按普通字符匹配
+func likeContains(db *gorm.DB, column, value string) (string, string) {
+	// MySQL 的字符串字面量中反斜杠本身需要转义
+	escape := `'\'`
+	if db.Dialector.Name() == "mysql" {
+		escape = `'\\'`
+	}
+	return column + " LIKE ? ESCAPE " + escape, "%" + likeEscaper.Replace(value) + "%"
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"github.com/hang666/EasyUKey/sdk/consts"
 	"github.com/hang666/EasyUKey/sdk/request"
 	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/model/entity"
@@ -18,18 +19,24 @@ import (
 )
 
 // InitDevice 初始化设备 - 简化版，创建设备和设备组
-func InitDevice(initReq *messages.DeviceInitRequestMessage) (string, string, error) {
+// 返回下发给U盘的认证密钥以及仅此一次下发的恢复码；携带恢复码时改为接管原设备组
+func InitDevice(initReq *messages.DeviceInitRequestMessage, clientIP string) (*DeviceGroupSecrets, []string, error) {
+	if initReq.RecoveryCode != "" {
+		secrets, err := RecoverDevice(initReq, clientIP)
+		return secrets, nil, err
+	}
+
 	// 检查设备是否已存在（同平台重复注册）
 	var existingDevice entity.Device
 	result := global.DB.Where("serial_number = ? AND volume_serial_number = ?",
 		initReq.SerialNumber, initReq.VolumeSerialNumber).First(&existingDevice)
 
 	if result.Error == nil {
-		return "", "", errs.ErrDeviceAlreadyExists
+		return nil, nil, errs.ErrDeviceAlreadyExists
 	}
 
 	if result.Error != gorm.ErrRecordNotFound {
-		return "", "", fmt.Errorf("查询设备失败: %w", result.Error)
+		return nil, nil, fmt.Errorf("查询设备失败: %w", result.Error)
 	}
 
 	// 创建新设备和设备组
@@ -37,17 +44,11 @@ func InitDevice(initReq *messages.DeviceInitRequestMessage) (string, string, err
 }
 
 // createNewDeviceWithGroup 创建新设备和对应的设备组
-func createNewDeviceWithGroup(initReq *messages.DeviceInitRequestMessage) (string, string, error) {
+func createNewDeviceWithGroup(initReq *messages.DeviceInitRequestMessage) (*DeviceGroupSecrets, []string, error) {
 	// 生成认证密钥
-	totpAccount := fmt.Sprintf("%s_%s", initReq.SerialNumber, uuid.New().String()[:6])
-	totpSecret, err := identity.GenerateTOTPSecretURI("EasyUKey", totpAccount)
-	if err != nil {
-		return "", "", fmt.Errorf("生成TOTP密钥失败: %w", err)
-	}
-
-	onceKey, err := GenerateOnceKey()
+	secrets, err := newDeviceGroupSecrets(fmt.Sprintf("%s_%s", initReq.SerialNumber, uuid.New().String()[:6]))
 	if err != nil {
-		return "", "", fmt.Errorf("生成OnceKey失败: %w", err)
+		return nil, nil, err
 	}
 
 	tx := global.DB.Begin()
@@ -58,33 +59,36 @@ func createNewDeviceWithGroup(initReq *messages.DeviceInitRequestMessage) (strin
 	}()
 
 	if tx.Error != nil {
-		return "", "", fmt.Errorf("开始事务失败: %w", tx.Error)
+		return nil, nil, fmt.Errorf("开始事务失败: %w", tx.Error)
 	}
 
 	// 生成随机后缀
 	randomSuffix, err := GenerateRandomSuffix()
 	if err != nil {
-		return "", "", fmt.Errorf("生成随机后缀失败: %w", err)
+		return nil, nil, fmt.Errorf("生成随机后缀失败: %w", err)
 	}
 
 	// 创建设备组
 	deviceGroup := entity.DeviceGroup{
-		Name:        fmt.Sprintf("设备组_%s_%s", initReq.SerialNumber[len(initReq.SerialNumber)-6:], randomSuffix),
+		Name:        fmt.Sprintf("设备组_%s_%s", SerialSuffix(initReq.SerialNumber, 6), randomSuffix),
 		Description: "设备初始化时自动创建",
-		TOTPSecret:  totpSecret,
-		OnceKey:     onceKey,
 		Permissions: []string{},
 		IsActive:    false, // 等待管理员激活
+		// U盘使用独立HMAC密钥签名前兼容全局密钥
+		AuthSecretPending: true,
 	}
-
 	if err := tx.Create(&deviceGroup).Error; err != nil {
 		tx.Rollback()
-		return "", "", fmt.Errorf("创建设备组失败: %w", err)
+		return nil, nil, fmt.Errorf("创建设备组失败: %w", err)
+	}
+	if err := secrets.apply(tx, &deviceGroup); err != nil {
+		tx.Rollback()
+		return nil, nil, err
 	}
 
 	// 创建设备记录
 	device := entity.Device{
-		Name:               fmt.Sprintf("设备_%s_%s", initReq.SerialNumber[len(initReq.SerialNumber)-6:], randomSuffix),
+		Name:               fmt.Sprintf("设备_%s_%s", SerialSuffix(initReq.SerialNumber, 6), randomSuffix),
 		DeviceGroupID:      &deviceGroup.ID,
 		SerialNumber:       initReq.SerialNumber,
 		VolumeSerialNumber: initReq.VolumeSerialNumber,
@@ -94,18 +98,30 @@ func createNewDeviceWithGroup(initReq *messages.DeviceInitRequestMessage) (strin
 		IsActive:           false,
 		IsOnline:           false,
 		HeartbeatInterval:  30,
+		Fingerprint:        initReq.Fingerprint,
 	}
 
 	if err := tx.Create(&device).Error; err != nil {
 		tx.Rollback()
-		return "", "", fmt.Errorf("创建设备记录失败: %w", err)
+		return nil, nil, fmt.Errorf("创建设备记录失败: %w", err)
+	}
+
+	// 生成恢复码，服务端只保存摘要
+	recoveryCodes, err := GenerateRecoveryCodes(tx, deviceGroup.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		return "", "", fmt.Errorf("提交事务失败: %w", err)
+		return nil, nil, fmt.Errorf("提交事务失败: %w", err)
 	}
 
-	return onceKey, totpSecret, nil
+	Notify(consts.NotifyDevicePendingActivation,
+		fmt.Sprintf("新设备 %s 等待激活", device.Name),
+		fmt.Sprintf("序列号为 %s 的设备完成初始化，已创建设备组 %s，需管理员激活并关联用户。", initReq.SerialNumber, deviceGroup.Name))
+
+	return secrets, recoveryCodes, nil
 }
 
 // UpdateDevice 更新设备信息
@@ -156,8 +172,26 @@ func UpdateDevice(deviceID uint, req *request.UpdateDeviceRequest) (*entity.Devi
 		}
 	}
 
+	// 管理员重新激活硬件指纹发生变化的设备，确认新的指纹和卷序列号
+	if req.IsActive != nil && *req.IsActive && device.PendingFingerprint != nil {
+		confirmed := &entity.Device{Fingerprint: device.PendingFingerprint, VolumeSerialNumber: device.VolumeSerialNumber}
+		if device.PendingVolumeSerialNumber != "" {
+			confirmed.VolumeSerialNumber = device.PendingVolumeSerialNumber
+		}
+		if err := global.DB.Model(&device).Select("fingerprint", "volume_serial_number", "pending_fingerprint", "pending_volume_serial_number").
+			Updates(confirmed).Error; err != nil {
+			return nil, fmt.Errorf("更新设备指纹失败: %w", err)
+		}
+	}
+
 	// 处理设备激活状态变化的Hub更新
 	if req.IsActive != nil && *req.IsActive != oldIsActive {
+		if *req.IsActive {
+			PublishDeviceEvent(consts.EventDeviceActivated, deviceID, 0)
+		} else {
+			PublishDeviceEvent(consts.EventDeviceDeactivated, deviceID, 0)
+		}
+
 		if hub := GetWSHub(); hub != nil && hub.IsDeviceOnline(deviceID) {
 			if !*req.IsActive {
 				// 设备被停用，断开WebSocket连接
@@ -203,6 +237,8 @@ func DeleteDevice(deviceID uint) error {
 		return fmt.Errorf("删除设备失败: %w", err)
 	}
 
+	PublishDeviceEvent(consts.EventDeviceRevoked, deviceID, 0)
+
 	return nil
 }
 
@@ -255,7 +291,7 @@ func GetDevices(page, pageSize int, filter *request.DeviceFilter) ([]entity.Devi
 			query = query.Where("device_group_id = ?", *filter.DeviceGroupID)
 		}
 		if filter.Name != "" {
-			query = query.Where("name LIKE ?", "%"+filter.Name+"%")
+			query = query.Where(likeContains(global.DB, "name", filter.Name))
 		}
 	}
 
@@ -351,6 +387,35 @@ func GenerateOnceKey() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// GenerateAuthSecret 生成设备组的认证token HMAC密钥
+func GenerateAuthSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// newDeviceGroupSecrets 生成设备组的全部认证密钥，totpAccount 为TOTP账户名
+func newDeviceGroupSecrets(totpAccount string) (*DeviceGroupSecrets, error) {
+	totpSecret, err := identity.GenerateTOTPSecretURI("EasyUKey", totpAccount)
+	if err != nil {
+		return nil, fmt.Errorf("生成TOTP密钥失败: %w", err)
+	}
+
+	onceKey, err := GenerateOnceKey()
+	if err != nil {
+		return nil, fmt.Errorf("生成OnceKey失败: %w", err)
+	}
+
+	authSecret, err := GenerateAuthSecret()
+	if err != nil {
+		return nil, fmt.Errorf("生成认证密钥失败: %w", err)
+	}
+
+	return &DeviceGroupSecrets{TOTPSecret: totpSecret, OnceKey: onceKey, AuthSecret: authSecret}, nil
+}
+
 // GenerateRandomSuffix 生成随机后缀字符串
 func GenerateRandomSuffix() (string, error) {
 	bytes := make([]byte, 3) // 3字节生成6位十六进制字符
@@ -361,21 +426,29 @@ func GenerateRandomSuffix() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// SerialSuffix 返回序列号末尾的 n 个字符，用于生成设备名称，序列号较短时返回完整序列号
+func SerialSuffix(serial string, n int) string {
+	if len(serial) <= n {
+		return serial
+	}
+	return serial[len(serial)-n:]
+}
+
 // UpdateDeviceOnceKey 更新设备组的OnceKey（通过设备ID）
-func UpdateDeviceOnceKey(deviceID uint, oldOnceKey string) (string, error) {
+func UpdateDeviceOnceKey(deviceID uint, oldOnceKey string) (string, string, error) {
 	// 查找设备及其设备组
 	var device entity.Device
 	result := global.DB.Preload("DeviceGroup").Where("id = ?", deviceID).First(&device)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			return "", errs.ErrDeviceNotFound
+			return "", "", errs.ErrDeviceNotFound
 		}
-		return "", fmt.Errorf("查询设备失败: %w", result.Error)
+		return "", "", fmt.Errorf("查询设备失败: %w", result.Error)
 	}
 
 	// 检查设备是否关联设备组
 	if device.DeviceGroup == nil {
-		return "", fmt.Errorf("设备未关联设备组")
+		return "", "", fmt.Errorf("设备未关联设备组")
 	}
 
 	// 使用设备组服务更新OnceKey
@@ -8,6 +8,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/hang666/EasyUKey/sdk/consts"
 	"github.com/hang666/EasyUKey/sdk/response"
 	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/model/entity"
@@ -22,14 +23,17 @@ func ConvertToDeviceGroupResponse(group *entity.DeviceGroup) *response.DeviceGro
 	}
 
 	resp := &response.DeviceGroupResponse{
-		ID:          group.ID,
-		UserID:      group.UserID,
-		Name:        group.Name,
-		Description: group.Description,
-		Permissions: group.Permissions,
-		IsActive:    group.IsActive,
-		CreatedAt:   group.CreatedAt,
-		UpdatedAt:   group.UpdatedAt,
+		ID:               group.ID,
+		UserID:           group.UserID,
+		Name:             group.Name,
+		Description:      group.Description,
+		Permissions:      group.Permissions,
+		IsActive:         group.IsActive,
+		Quarantined:      group.Quarantined,
+		QuarantinedAt:    group.QuarantinedAt,
+		QuarantineReason: group.QuarantineReason,
+		CreatedAt:        group.CreatedAt,
+		UpdatedAt:        group.UpdatedAt,
 	}
 
 	// 转换关联的用户信息
@@ -72,6 +76,8 @@ func ConvertToDeviceResponse(device *entity.Device) *response.DeviceResponse {
 		LastOnlineAt:       device.LastOnlineAt,
 		LastOfflineAt:      device.LastOfflineAt,
 		HeartbeatInterval:  device.HeartbeatInterval,
+		FingerprintScore:   device.FingerprintScore,
+		FingerprintPending: device.PendingFingerprint != nil,
 		CreatedAt:          device.CreatedAt,
 		UpdatedAt:          device.UpdatedAt,
 	}
@@ -79,14 +85,17 @@ func ConvertToDeviceResponse(device *entity.Device) *response.DeviceResponse {
 	// 转换关联的设备组信息
 	if device.DeviceGroup != nil {
 		resp.DeviceGroup = &response.DeviceGroupResponse{
-			ID:          device.DeviceGroup.ID,
-			UserID:      device.DeviceGroup.UserID,
-			Name:        device.DeviceGroup.Name,
-			Description: device.DeviceGroup.Description,
-			Permissions: device.DeviceGroup.Permissions,
-			IsActive:    device.DeviceGroup.IsActive,
-			CreatedAt:   device.DeviceGroup.CreatedAt,
-			UpdatedAt:   device.DeviceGroup.UpdatedAt,
+			ID:               device.DeviceGroup.ID,
+			UserID:           device.DeviceGroup.UserID,
+			Name:             device.DeviceGroup.Name,
+			Description:      device.DeviceGroup.Description,
+			Permissions:      device.DeviceGroup.Permissions,
+			IsActive:         device.DeviceGroup.IsActive,
+			Quarantined:      device.DeviceGroup.Quarantined,
+			QuarantinedAt:    device.DeviceGroup.QuarantinedAt,
+			QuarantineReason: device.DeviceGroup.QuarantineReason,
+			CreatedAt:        device.DeviceGroup.CreatedAt,
+			UpdatedAt:        device.DeviceGroup.UpdatedAt,
 		}
 	}
 
@@ -192,9 +201,13 @@ func UpdateDeviceGroup(groupID uint, name, description string, permissions []str
 
 // FindDeviceGroupByAuth 通过认证密钥查找设备组
 func FindDeviceGroupByAuth(totpCode, onceKey string) (*entity.DeviceGroup, error) {
-	// 直接通过onceKey查找激活的设备组
+	if onceKey == "" {
+		return nil, nil
+	}
+
+	// 通过onceKey的索引查找激活的设备组，主密钥轮换期间索引可能由任一主密钥计算
 	var group entity.DeviceGroup
-	result := global.DB.Where("is_active = ? AND once_key = ?", true, onceKey).First(&group)
+	result := global.DB.Where("is_active = ? AND once_key_hash IN ?", true, global.Keyring.Hashes(onceKey)).First(&group)
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -203,8 +216,13 @@ func FindDeviceGroupByAuth(totpCode, onceKey string) (*entity.DeviceGroup, error
 		return nil, fmt.Errorf("查询设备组失败: %w", result.Error)
 	}
 
+	secrets, err := OpenDeviceGroupSecrets(&group)
+	if err != nil {
+		return nil, err
+	}
+
 	// 验证TOTP - 先解析TOTP URI获取密钥
-	totpConfig, err := identity.ParseTOTPURI(group.TOTPSecret)
+	totpConfig, err := identity.ParseTOTPURI(secrets.TOTPSecret)
 	if err != nil {
 		return nil, fmt.Errorf("解析TOTP密钥失败: %w", err)
 	}
@@ -258,23 +276,39 @@ func LinkDeviceGroupUser(groupID uint, userID *uint) error {
 	}
 
 	if newUserID != oldUserID {
-		// 获取该设备组下的所有在线设备，更新其用户归属
-		var devices []entity.Device
-		if err := global.DB.Where("device_group_id = ?", groupID).Find(&devices).Error; err != nil {
-			return fmt.Errorf("查询设备组关联设备失败: %w", err)
+		return notifyDeviceGroupUserChange(groupID, oldUserID, newUserID)
+	}
+
+	return nil
+}
+
+// notifyDeviceGroupUserChange 设备组关联用户变化后推送设备事件，并更新在线设备的用户归属
+func notifyDeviceGroupUserChange(groupID, oldUserID, newUserID uint) error {
+	// 获取该设备组下的所有在线设备，更新其用户归属
+	var devices []entity.Device
+	if err := global.DB.Where("device_group_id = ?", groupID).Find(&devices).Error; err != nil {
+		return fmt.Errorf("查询设备组关联设备失败: %w", err)
+	}
+
+	for _, device := range devices {
+		if oldUserID > 0 {
+			PublishDeviceEvent(consts.EventDeviceUnlinked, device.ID, oldUserID)
+		}
+		if newUserID > 0 {
+			PublishDeviceEvent(consts.EventDeviceLinked, device.ID, newUserID)
 		}
+	}
 
-		hub := GetWSHub()
-		if hub != nil {
-			for _, device := range devices {
-				if hub.IsDeviceOnline(device.ID) {
-					if newUserID > 0 {
-						// 绑定新用户
-						hub.LinkDeviceToUser(device.ID, newUserID)
-					} else {
-						// 取消绑定，通过Hub强制断开WebSocket连接
-						hub.OnDeviceDisconnect(device.ID)
-					}
+	hub := GetWSHub()
+	if hub != nil {
+		for _, device := range devices {
+			if hub.IsDeviceOnline(device.ID) {
+				if newUserID > 0 {
+					// 绑定新用户
+					hub.LinkDeviceToUser(device.ID, newUserID)
+				} else {
+					// 取消绑定，通过Hub强制断开WebSocket连接
+					hub.OnDeviceDisconnect(device.ID)
 				}
 			}
 		}
@@ -284,12 +318,13 @@ func LinkDeviceGroupUser(groupID uint, userID *uint) error {
 }
 
 // UpdateDeviceGroupOnceKey 更新设备组的OnceKey
-func UpdateDeviceGroupOnceKey(groupID uint, oldOnceKey string) (string, error) {
+// 设备组尚无独立HMAC密钥或U盘尚未使用该密钥时，一并返回需要下发的HMAC密钥
+func UpdateDeviceGroupOnceKey(groupID uint, oldOnceKey string) (newOnceKey, authSecret string, err error) {
 	if groupID == 0 {
-		return "", errs.ErrInvalidDeviceID
+		return "", "", errs.ErrInvalidDeviceID
 	}
 	if oldOnceKey == "" {
-		return "", errs.ErrInvalidKey
+		return "", "", errs.ErrInvalidKey
 	}
 
 	// 查找设备组
@@ -297,31 +332,56 @@ func UpdateDeviceGroupOnceKey(groupID uint, oldOnceKey string) (string, error) {
 	result := global.DB.Where("id = ?", groupID).First(&group)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return "", errs.ErrDeviceGroupNotFound
+			return "", "", errs.ErrDeviceGroupNotFound
 		}
-		return "", fmt.Errorf("查询设备组失败: %w", result.Error)
+		return "", "", fmt.Errorf("查询设备组失败: %w", result.Error)
 	}
 
 	// 验证旧的OnceKey
-	if group.OnceKey != oldOnceKey {
-		return "", errs.ErrInvalidKey
+	if !global.Keyring.MatchHash(group.OnceKeyHash, oldOnceKey) {
+		return "", "", errs.ErrInvalidKey
 	}
 
 	// 生成新的OnceKey
-	newOnceKey, err := GenerateOnceKey()
+	newOnceKey, err = GenerateOnceKey()
 	if err != nil {
-		return "", fmt.Errorf("生成新的一次性密钥失败: %w", err)
+		return "", "", fmt.Errorf("生成新的一次性密钥失败: %w", err)
+	}
+
+	columns := map[string]string{
+		columnOnceKey:         newOnceKey,
+		columnLastUsedOnceKey: oldOnceKey,
+	}
+
+	// 旧版本初始化的设备组生成独立HMAC密钥，已生成但U盘未使用的重新下发
+	switch {
+	case group.AuthSecret == "":
+		authSecret, err = GenerateAuthSecret()
+		if err != nil {
+			return "", "", fmt.Errorf("生成HMAC密钥失败: %w", err)
+		}
+		columns[columnAuthSecret] = authSecret
+	case group.AuthSecretPending:
+		secrets, err := OpenDeviceGroupSecrets(&group)
+		if err != nil {
+			return "", "", err
+		}
+		authSecret = secrets.AuthSecret
 	}
 
 	// 更新数据库
-	updates := map[string]interface{}{
-		"once_key":           newOnceKey,
-		"last_used_once_key": oldOnceKey,
+	updates, err := sealSecretColumns(group.ID, columns)
+	if err != nil {
+		return "", "", err
+	}
+	updates["once_key_pending"] = true
+	if group.AuthSecret == "" {
+		updates["auth_secret_pending"] = true
 	}
 
 	if err := global.DB.Model(&group).Updates(updates).Error; err != nil {
-		return "", fmt.Errorf("更新设备组OnceKey失败: %w", err)
+		return "", "", fmt.Errorf("更新设备组OnceKey失败: %w", err)
 	}
 
-	return newOnceKey, nil
+	return newOnceKey, authSecret, nil
 }
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/callback"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// MaxEventSubscriptionsPerKey 每个API密钥最多可注册的事件订阅数
+const MaxEventSubscriptionsPerKey = 10
+
+// ConvertToEventSubscriptionResponse 将事件订阅转换为响应结构
+func ConvertToEventSubscriptionResponse(sub *entity.EventSubscription) *response.EventSubscriptionResponse {
+	return &response.EventSubscriptionResponse{
+		ID:         sub.ID,
+		URL:        sub.URL,
+		EventTypes: sub.EventTypes,
+		CreatedAt:  sub.CreatedAt,
+	}
+}
+
+// CreateEventSubscription 为API密钥注册事件订阅
+func CreateEventSubscription(apiKeyID uint, req *request.EventSubscriptionRequest) (*entity.EventSubscription, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errs.ErrEventSubscriptionURLInvalid
+	}
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(consts.EventTypes, eventType) {
+			return nil, fmt.Errorf("%w: %s", errs.ErrEventTypeInvalid, eventType)
+		}
+	}
+
+	var count int64
+	if err := global.DB.Model(&entity.EventSubscription{}).Where("api_key_id = ?", apiKeyID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询事件订阅数量失败: %w", err)
+	}
+	if count >= MaxEventSubscriptionsPerKey {
+		return nil, errs.ErrEventSubscriptionLimit
+	}
+
+	sub := entity.EventSubscription{
+		APIKeyID:   apiKeyID,
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+	}
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	if err := global.DB.Create(&sub).Error; err != nil {
+		return nil, fmt.Errorf("创建事件订阅失败: %w", err)
+	}
+
+	return &sub, nil
+}
+
+// GetEventSubscriptions 获取API密钥的事件订阅列表
+func GetEventSubscriptions(apiKeyID uint) ([]entity.EventSubscription, error) {
+	var subs []entity.EventSubscription
+	if err := global.DB.Where("api_key_id = ?", apiKeyID).Order("id").Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("查询事件订阅失败: %w", err)
+	}
+	return subs, nil
+}
+
+// DeleteEventSubscription 删除API密钥的事件订阅
+func DeleteEventSubscription(apiKeyID, subID uint) error {
+	result := global.DB.Where("id = ? AND api_key_id = ?", subID, apiKeyID).Delete(&entity.EventSubscription{})
+	if result.Error != nil {
+		return fmt.Errorf("删除事件订阅失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errs.ErrEventSubscriptionNotFound
+	}
+	return nil
+}
+
+// PublishDeviceEvent 向订阅了该事件类型的集成方推送设备事件（异步）
+// userID 为0时使用设备组当前关联的用户；取消关联等用户已变化的场景需传入原用户ID
+// 普通API密钥只能收到曾通过该密钥完成认证的用户的事件，管理员密钥可以收到全部事件
+func PublishDeviceEvent(eventType string, deviceID uint, userID uint) {
+	db := global.DB
+	if db == nil {
+		return
+	}
+
+	// 在调用时记录事件时间，避免异步查询的延迟影响事件先后顺序
+	timestamp := time.Now().Unix()
+
+	go func() {
+		event, err := buildDeviceEvent(db, eventType, deviceID, userID, timestamp)
+		if err != nil {
+			logger.Logger.Error("构建设备事件失败", "type", eventType, "device_id", deviceID, "error", err)
+			return
+		}
+
+		subs, err := findEventSubscriptions(db, eventType, event.UserID)
+		if err != nil {
+			logger.Logger.Error("查询事件订阅失败", "type", eventType, "error", err)
+			return
+		}
+
+		for _, sub := range subs {
+			req := *event
+			req.Signature = callback.GenerateEventSignature(&req, sub.APIKey.APIKey)
+			go deliverEvent(sub.URL, &req)
+		}
+	}()
+}
+
+// buildDeviceEvent 查询设备及关联用户构建事件，已删除的设备同样可以查询
+func buildDeviceEvent(db *gorm.DB, eventType string, deviceID, userID uint, timestamp int64) (*messages.EventRequest, error) {
+	var device entity.Device
+	if err := db.Unscoped().Select("id", "device_group_id").Where("id = ?", deviceID).First(&device).Error; err != nil {
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+
+	event := &messages.EventRequest{
+		EventID:   uuid.New().String(),
+		Type:      eventType,
+		DeviceID:  device.ID,
+		UserID:    userID,
+		Timestamp: timestamp,
+	}
+
+	if device.DeviceGroupID != nil {
+		event.DeviceGroupID = *device.DeviceGroupID
+		if event.UserID == 0 {
+			var group entity.DeviceGroup
+			if err := db.Unscoped().Select("id", "user_id").Where("id = ?", *device.DeviceGroupID).First(&group).Error; err != nil {
+				return nil, fmt.Errorf("查询设备组失败: %w", err)
+			}
+			if group.UserID != nil {
+				event.UserID = *group.UserID
+			}
+		}
+	}
+
+	if event.UserID != 0 {
+		var user entity.User
+		err := db.Unscoped().Select("id", "username").Where("id = ?", event.UserID).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("查询用户失败: %w", err)
+		}
+		event.Username = user.Username
+	}
+
+	return event, nil
+}
+
+// findEventSubscriptions 查询有效API密钥下订阅了该事件类型、且可以查看该用户的订阅
+// 普通API密钥可以查看曾通过该密钥完成认证的用户，未关联用户的设备事件只推送给管理员密钥
+func findEventSubscriptions(db *gorm.DB, eventType string, userID uint) ([]entity.EventSubscription, error) {
+	var subs []entity.EventSubscription
+	err := db.Preload("APIKey").
+		Joins("JOIN api_keys ON api_keys.id = event_subscriptions.api_key_id").
+		Where("api_keys.deleted_at IS NULL AND api_keys.is_active = ? AND (api_keys.expires_at IS NULL OR api_keys.expires_at > ?)",
+			true, time.Now()).
+		Find(&subs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var visibleKeyIDs []uint
+	if userID != 0 {
+		if err := db.Model(&entity.AuthSession{}).
+			Where("user_id = ? AND status = ? AND api_key_id IS NOT NULL", userID, consts.AuthStatusCompleted).
+			Distinct().Pluck("api_key_id", &visibleKeyIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	matched := subs[:0]
+	for _, sub := range subs {
+		if sub.APIKey == nil {
+			continue
+		}
+		if !sub.APIKey.IsAdmin && !slices.Contains(visibleKeyIDs, sub.APIKeyID) {
+			continue
+		}
+		if len(sub.EventTypes) == 0 || slices.Contains(sub.EventTypes, eventType) {
+			matched = append(matched, sub)
+		}
+	}
+	return matched, nil
+}
+
+// deliverEvent 推送事件，失败时按认证回调的间隔重试
+func deliverEvent(url string, req *messages.EventRequest) {
+	for i := 0; i < CallbackMaxRetries; i++ {
+		if sendHTTPCallback(url, req) {
+			return
+		}
+
+		if i < CallbackMaxRetries-1 {
+			time.Sleep(callbackRetryDelays[i])
+		}
+	}
+
+	logger.Logger.Error("事件推送失败，已达到最大重试次数", "event_id", req.EventID, "type", req.Type, "url", url)
+}
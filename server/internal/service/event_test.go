@@ -0,0 +1,122 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// eventRecorder 启动接收事件推送的测试服务，返回地址和收到的事件
+func eventRecorder(t *testing.T) (string, <-chan messages.EventRequest) {
+	t.Helper()
+	received := make(chan messages.EventRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req messages.EventRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received <- req
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+// subscribeTestEvents 为API密钥注册订阅全部事件类型的订阅
+func subscribeTestEvents(t *testing.T, key *entity.APIKey, url string) {
+	t.Helper()
+	if _, err := CreateEventSubscription(key.ID, &request.EventSubscriptionRequest{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// subscribedKeyIDs 返回会收到该用户事件的API密钥
+func subscribedKeyIDs(t *testing.T, userID uint) []uint {
+	t.Helper()
+	subs, err := findEventSubscriptions(global.DB, consts.EventDeviceOnline, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]uint, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.APIKeyID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestEventSubscriptionsAreScopedToAuthenticatedUsers(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	keyA := createTestAPIKey(t, "app-a")
+	keyB := createTestAPIKey(t, "app-b")
+	admin := &entity.APIKey{Name: "admin", APIKey: "key-admin", IsActive: true, IsAdmin: true}
+	if err := global.DB.Create(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []*entity.APIKey{keyA, keyB, admin} {
+		subscribeTestEvents(t, key, "https://example.com/events")
+	}
+
+	createTestAuthSession(t, alice.ID, consts.AuthStatusCompleted, 0, keyA, "")
+	createTestAuthSession(t, bob.ID, consts.AuthStatusCompleted, 0, keyB, "")
+	// 未完成的认证不能让密钥看到用户
+	createTestAuthSession(t, bob.ID, consts.AuthStatusRejected, 0, keyA, "")
+	createTestAuthSession(t, bob.ID, consts.AuthStatusPending, 0, keyA, "")
+
+	cases := []struct {
+		name   string
+		userID uint
+		want   []uint
+	}{
+		{"alice", alice.ID, []uint{keyA.ID, admin.ID}},
+		{"bob", bob.ID, []uint{keyB.ID, admin.ID}},
+		{"未关联用户", 0, []uint{admin.ID}},
+	}
+	for _, tc := range cases {
+		if got := subscribedKeyIDs(t, tc.userID); !slices.Equal(got, tc.want) {
+			t.Errorf("%s 的事件推送给密钥 %v，应为 %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPublishDeviceEventSkipsOtherUsersDevices(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	_, aliceDevice, _ := createTestDeviceGroup(t, alice, "SN-ALICE")
+	_, bobDevice, _ := createTestDeviceGroup(t, bob, "SN-BOB")
+	keyA := createTestAPIKey(t, "app-a")
+	createTestAuthSession(t, alice.ID, consts.AuthStatusCompleted, 0, keyA, "")
+	url, events := eventRecorder(t)
+	subscribeTestEvents(t, keyA, url)
+
+	PublishDeviceEvent(consts.EventDeviceOnline, bobDevice.ID, 0)
+	PublishDeviceEvent(consts.EventDeviceOnline, aliceDevice.ID, 0)
+
+	deadline := time.After(2 * time.Second)
+	for received := false; !received; {
+		select {
+		case event := <-events:
+			if event.UserID != alice.ID || event.DeviceID != aliceDevice.ID {
+				t.Fatalf("密钥A收到了其他用户的事件: %+v", event)
+			}
+			received = true
+		case <-deadline:
+			t.Fatal("密钥A未收到 alice 的设备事件")
+		}
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("密钥A收到了其他用户的事件: %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// FingerprintVerdict 硬件指纹比对结论
+type FingerprintVerdict int
+
+const (
+	FingerprintAccepted FingerprintVerdict = iota // 相似度达到接受阈值，正常连接
+	FingerprintReview                             // 需要管理员重新激活
+	FingerprintRejected                           // 拒绝连接
+)
+
+// 各项硬件属性的权重，总和为100
+// 锚点文件内容只能通过复制获得，权重最高；文件标识在跨平台使用或部分文件系统重新挂载时会变化，权重较低
+const (
+	weightUSBID           = 15
+	weightCapacity        = 20
+	weightFilesystemUUID  = 20
+	weightPartitionLayout = 20
+	weightAnchorSignature = 15
+	weightAnchorModTime   = 5
+	weightAnchorFileID    = 5
+)
+
+// capacityTolerance 容量比较允许的相对误差，Windows 按磁盘几何计算的容量略小于实际容量
+const capacityTolerance = 0.01
+
+// ScoreFingerprint 计算上报的指纹与记录的指纹的相似度（0-100）
+// 只比较记录中存在的属性，上报中缺少的属性按不匹配处理；记录中没有任何属性时返回100
+func ScoreFingerprint(stored, presented *messages.DeviceFingerprint) int {
+	if stored == nil {
+		return 100
+	}
+	if presented == nil {
+		presented = &messages.DeviceFingerprint{}
+	}
+
+	total, matched := 0, 0
+	check := func(weight int, present, equal bool) {
+		if !present {
+			return
+		}
+		total += weight
+		if equal {
+			matched += weight
+		}
+	}
+
+	check(weightUSBID, stored.VendorID != "" || stored.ProductID != "",
+		strings.EqualFold(stored.VendorID, presented.VendorID) && strings.EqualFold(stored.ProductID, presented.ProductID))
+	check(weightCapacity, stored.Capacity > 0, capacityMatches(stored.Capacity, presented.Capacity))
+	check(weightFilesystemUUID, stored.FilesystemUUID != "",
+		normalizeFilesystemUUID(stored.FilesystemUUID) == normalizeFilesystemUUID(presented.FilesystemUUID))
+	check(weightPartitionLayout, stored.PartitionLayout != "", stored.PartitionLayout == presented.PartitionLayout)
+
+	if anchor := stored.Anchor; anchor != nil {
+		presentedAnchor := presented.Anchor
+		if presentedAnchor == nil {
+			presentedAnchor = &messages.AnchorFile{}
+		}
+		check(weightAnchorSignature, anchor.Signature != "", anchor.Signature == presentedAnchor.Signature)
+		check(weightAnchorModTime, anchor.ModTime != 0, anchor.ModTime == presentedAnchor.ModTime)
+		check(weightAnchorFileID, anchor.FileID != "", anchor.FileID == presentedAnchor.FileID)
+	}
+
+	if total == 0 {
+		return 100
+	}
+	return matched * 100 / total
+}
+
+// capacityMatches 判断两个容量是否在允许误差内相等
+func capacityMatches(stored, presented uint64) bool {
+	if presented == 0 {
+		return false
+	}
+	diff := float64(stored) - float64(presented)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= float64(stored)*capacityTolerance
+}
+
+// normalizeFilesystemUUID 统一文件系统UUID格式，Windows 上报的卷序列号不含连字符（如 1234ABCD 与 1234-ABCD）
+func normalizeFilesystemUUID(uuid string) string {
+	return strings.ToUpper(strings.ReplaceAll(uuid, "-", ""))
+}
+
+// hasFingerprint 判断记录中是否有可用于比较的硬件属性
+func hasFingerprint(fp *messages.DeviceFingerprint) bool {
+	return fp != nil && (fp.VendorID != "" || fp.ProductID != "" || fp.Capacity > 0 ||
+		fp.FilesystemUUID != "" || fp.PartitionLayout != "" || fp.Anchor != nil)
+}
+
+// EvaluateFingerprint 按配置的阈值得出比对结论
+func EvaluateFingerprint(score int) FingerprintVerdict {
+	switch {
+	case score >= global.Config.Fingerprint.AcceptThreshold:
+		return FingerprintAccepted
+	case score >= global.Config.Fingerprint.ReviewThreshold:
+		return FingerprintReview
+	default:
+		return FingerprintRejected
+	}
+}
+
+// VerifyDeviceFingerprint 比对设备连接时上报的硬件指纹和卷序列号，返回相似度和结论
+// 设备还没有记录指纹时直接记录（首次使用即信任）。记录的指纹作为固定基线，接受时不随上报更新，
+// 避免多次小幅变化累积成另一台设备；卷序列号变化（如重新格式化）只在接受时更新。
+// 需要复核时停用设备并暂存上报的指纹和卷序列号，管理员重新激活设备后生效；
+// 已记录指纹的设备不再上报指纹（如旧版客户端）时无法比较，同样交给管理员复核，重新激活后清空基线并在下次上报时重新记录。
+// 等待重新激活的设备始终返回需要复核，只能由管理员解除
+func VerifyDeviceFingerprint(deviceID uint, volumeSerialNumber string, presented *messages.DeviceFingerprint) (int, FingerprintVerdict, error) {
+	var device entity.Device
+	if err := global.DB.Select("id", "is_active", "volume_serial_number", "fingerprint", "pending_fingerprint", "fingerprint_score").
+		Where("id = ?", deviceID).First(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, FingerprintRejected, errs.ErrDeviceNotFound
+		}
+		return 0, FingerprintRejected, fmt.Errorf("查询设备失败: %w", err)
+	}
+
+	if IsDevicePendingActivation(&device) {
+		return device.FingerprintScore, FingerprintReview, nil
+	}
+
+	if !hasFingerprint(device.Fingerprint) {
+		if presented != nil {
+			if err := global.DB.Model(&device).Select("fingerprint", "fingerprint_score").
+				Updates(&entity.Device{Fingerprint: presented, FingerprintScore: 100}).Error; err != nil {
+				return 0, FingerprintRejected, fmt.Errorf("记录设备指纹失败: %w", err)
+			}
+		}
+		return 100, FingerprintAccepted, nil
+	}
+
+	score, verdict := 0, FingerprintReview
+	if presented != nil {
+		score = ScoreFingerprint(device.Fingerprint, presented)
+		verdict = EvaluateFingerprint(score)
+	} else {
+		presented = &messages.DeviceFingerprint{}
+	}
+
+	var err error
+	switch verdict {
+	case FingerprintAccepted:
+		err = global.DB.Model(&device).Select("volume_serial_number", "pending_fingerprint", "pending_volume_serial_number", "fingerprint_score").
+			Updates(&entity.Device{VolumeSerialNumber: volumeSerialNumber, FingerprintScore: score}).Error
+	case FingerprintReview:
+		err = global.DB.Model(&device).Select("is_active", "pending_fingerprint", "pending_volume_serial_number", "fingerprint_score").
+			Updates(&entity.Device{
+				IsActive:                  false,
+				PendingFingerprint:        presented,
+				PendingVolumeSerialNumber: volumeSerialNumber,
+				FingerprintScore:          score,
+			}).Error
+		if err == nil {
+			Notify(consts.NotifyDevicePendingActivation,
+				fmt.Sprintf("设备 %d 硬件指纹发生变化，等待重新激活", deviceID),
+				fmt.Sprintf("设备 %d 的硬件指纹相似度为 %d，设备已停用，确认无误后请在管理后台重新激活。", deviceID, score))
+		}
+	default:
+		err = global.DB.Model(&device).Update("fingerprint_score", score).Error
+	}
+	if err != nil {
+		return score, verdict, fmt.Errorf("更新设备指纹失败: %w", err)
+	}
+
+	return score, verdict, nil
+}
+
+// IsDevicePendingActivation 判断设备是否因硬件指纹变化被停用，正在等待管理员重新激活
+func IsDevicePendingActivation(device *entity.Device) bool {
+	return !device.IsActive && device.PendingFingerprint != nil
+}
+
+// FindDeviceByFingerprint 按序列号和硬件指纹识别卷序列号已变化（如重新格式化）的设备
+// 相似度不低于复核阈值时返回设备，未找到时返回 nil
+// 卷序列号由随后的 VerifyDeviceFingerprint 按比对结论更新或暂存，这里不修改设备记录
+func FindDeviceByFingerprint(connMsg *messages.DeviceConnectionMessage) (*entity.Device, error) {
+	// 没有序列号的设备无法区分，不做指纹识别
+	if connMsg.Fingerprint == nil || connMsg.SerialNumber == "" || connMsg.SerialNumber == "null" {
+		return nil, nil
+	}
+
+	var candidates []entity.Device
+	if err := global.DB.Where("serial_number = ?", connMsg.SerialNumber).Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+
+	var best *entity.Device
+	bestScore := -1
+	for i := range candidates {
+		if !hasFingerprint(candidates[i].Fingerprint) {
+			continue
+		}
+		if score := ScoreFingerprint(candidates[i].Fingerprint, connMsg.Fingerprint); score > bestScore {
+			best, bestScore = &candidates[i], score
+		}
+	}
+	if best == nil || EvaluateFingerprint(bestScore) == FingerprintRejected {
+		return nil, nil
+	}
+
+	logger.Logger.Info("按硬件指纹识别到卷序列号已变化的设备",
+		"device_id", best.ID,
+		"score", bestScore,
+		"serial_number", connMsg.SerialNumber,
+		"old_volume_serial_number", best.VolumeSerialNumber,
+		"volume_serial_number", connMsg.VolumeSerialNumber)
+
+	return best, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// testFingerprint 返回完整的测试硬件指纹
+func testFingerprint() *messages.DeviceFingerprint {
+	return &messages.DeviceFingerprint{
+		VendorID:        "0781",
+		ProductID:       "5581",
+		Capacity:        32 << 30,
+		FilesystemUUID:  "1234-ABCD",
+		PartitionLayout: "1048576:34358689792",
+		Anchor:          &messages.AnchorFile{FileID: "42", Size: 64, ModTime: 1700000000, Signature: "sig"},
+	}
+}
+
+// createFingerprintedDevice 创建已记录硬件指纹的设备
+func createFingerprintedDevice(t *testing.T, serial string) *entity.Device {
+	t.Helper()
+	_, device, _ := createTestDeviceGroup(t, createTestUser(t, "user-"+serial), serial)
+	if err := global.DB.Model(device).Select("fingerprint").Updates(&entity.Device{Fingerprint: testFingerprint()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return device
+}
+
+// loadDevice 重新读取设备记录
+func loadDevice(t *testing.T, deviceID uint) *entity.Device {
+	t.Helper()
+	var device entity.Device
+	if err := global.DB.First(&device, deviceID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &device
+}
+
+func TestVerifyDeviceFingerprintEnrollsFirstFingerprint(t *testing.T) {
+	setupTestDB(t)
+	_, device, _ := createTestDeviceGroup(t, createTestUser(t, "alice"), "SN1")
+
+	score, verdict, err := VerifyDeviceFingerprint(device.ID, device.VolumeSerialNumber, testFingerprint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict != FingerprintAccepted || score != 100 {
+		t.Fatalf("结论 = %v，相似度 = %d，应直接接受", verdict, score)
+	}
+	if got := loadDevice(t, device.ID); !hasFingerprint(got.Fingerprint) {
+		t.Error("首次上报的指纹应被记录")
+	}
+}
+
+func TestVerifyDeviceFingerprintRejectsDifferentDevice(t *testing.T) {
+	setupTestDB(t)
+	device := createFingerprintedDevice(t, "SN1")
+
+	other := &messages.DeviceFingerprint{VendorID: "1111", ProductID: "2222", Capacity: 8 << 30, FilesystemUUID: "FFFF-0000"}
+	_, verdict, err := VerifyDeviceFingerprint(device.ID, device.VolumeSerialNumber, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict != FingerprintRejected {
+		t.Fatalf("结论 = %v，完全不同的指纹应被拒绝", verdict)
+	}
+	if got := loadDevice(t, device.ID); !got.IsActive {
+		t.Error("拒绝连接不应停用设备")
+	}
+}
+
+func TestVerifyDeviceFingerprintWithoutPresentedFingerprintNeedsReview(t *testing.T) {
+	setupTestDB(t)
+	device := createFingerprintedDevice(t, "SN1")
+
+	// 旧版客户端不上报指纹，不能按克隆拒绝
+	_, verdict, err := VerifyDeviceFingerprint(device.ID, device.VolumeSerialNumber, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict != FingerprintReview {
+		t.Fatalf("结论 = %v，未上报指纹时应等待管理员复核", verdict)
+	}
+	got := loadDevice(t, device.ID)
+	if got.IsActive || got.PendingFingerprint == nil {
+		t.Fatalf("设备应被停用并暂存空指纹，is_active = %v", got.IsActive)
+	}
+
+	// 管理员重新激活后清空基线，下次上报时重新记录
+	active := true
+	if _, err := UpdateDevice(device.ID, &request.UpdateDeviceRequest{IsActive: &active}); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadDevice(t, device.ID); hasFingerprint(got.Fingerprint) || got.PendingFingerprint != nil {
+		t.Fatal("重新激活后应清空记录的指纹和暂存的指纹")
+	}
+	if _, verdict, _ := VerifyDeviceFingerprint(device.ID, device.VolumeSerialNumber, testFingerprint()); verdict != FingerprintAccepted {
+		t.Fatalf("结论 = %v，重新激活后的首次上报应被记录", verdict)
+	}
+}
+
+func TestVerifyDeviceFingerprintKeepsPendingDeviceInReview(t *testing.T) {
+	setupTestDB(t)
+	device := createFingerprintedDevice(t, "SN1")
+
+	changed := testFingerprint()
+	changed.FilesystemUUID = "9999-9999"
+	changed.Anchor.FileID = "43"
+	score, verdict, err := VerifyDeviceFingerprint(device.ID, "vol-new", changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict != FingerprintReview {
+		t.Fatalf("结论 = %v，相似度 = %d，应等待管理员复核", verdict, score)
+	}
+
+	// 等待复核期间出示原来的指纹也不能自行解除停用
+	_, verdict, err = VerifyDeviceFingerprint(device.ID, device.VolumeSerialNumber, testFingerprint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict != FingerprintReview {
+		t.Fatalf("结论 = %v，等待重新激活的设备应保持复核状态", verdict)
+	}
+	got := loadDevice(t, device.ID)
+	if got.IsActive || got.PendingVolumeSerialNumber != "vol-new" {
+		t.Errorf("暂存的复核信息不应被覆盖，is_active = %v，pending_volume_serial_number = %q", got.IsActive, got.PendingVolumeSerialNumber)
+	}
+}
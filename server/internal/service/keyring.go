@@ -0,0 +1,277 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/keyring"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+// 设备组认证密钥列
+const (
+	columnTOTPSecret      = "totp_secret"
+	columnOnceKey         = "once_key"
+	columnLastUsedOnceKey = "last_used_once_key"
+	columnAuthSecret      = "auth_secret"
+)
+
+// secretHashColumns 需要按值查询的密钥列及其索引列
+var secretHashColumns = map[string]string{
+	columnOnceKey:         "once_key_hash",
+	columnLastUsedOnceKey: "last_used_once_key_hash",
+}
+
+// reencryptRetryInterval 后台重新加密失败后的重试间隔
+const reencryptRetryInterval = time.Minute
+
+// resealAttempts 重新加密时密钥被并发修改后重新读取的最大次数
+const resealAttempts = 3
+
+var (
+	// keyringStop 停止后台重新加密任务
+	keyringStop chan struct{}
+
+	// errReencryptionStopped 服务关闭时中止重新加密
+	errReencryptionStopped = errors.New("重新加密已停止")
+
+	// secretContextsBound 后台重新加密完成后置为 true，此后不再接受旧版本仅以列名作为用途标识的密文
+	secretContextsBound atomic.Bool
+)
+
+// DeviceGroupSecrets 解密后的设备组认证密钥
+type DeviceGroupSecrets struct {
+	TOTPSecret      string
+	OnceKey         string
+	LastUsedOnceKey string
+	AuthSecret      string // 认证token的HMAC密钥，旧设备组迁移前为空
+}
+
+// secretContext 返回设备组认证密钥的加密用途标识，包含列名和设备组ID，密文不能在列之间或设备组之间挪用
+func secretContext(column string, groupID uint) string {
+	return fmt.Sprintf("device_groups.%s:%d", column, groupID)
+}
+
+// OpenDeviceGroupSecrets 解密设备组的认证密钥
+func OpenDeviceGroupSecrets(group *entity.DeviceGroup) (*DeviceGroupSecrets, error) {
+	secrets, _, err := openDeviceGroupSecrets(group, false)
+	return secrets, err
+}
+
+// openDeviceGroupSecrets 解密设备组的认证密钥，allowPlaintext 为 true 时接受旧版本以明文保存的值
+// legacy 表示存在明文或旧版本用途标识加密的值，需要重新加密
+func openDeviceGroupSecrets(group *entity.DeviceGroup, allowPlaintext bool) (secrets *DeviceGroupSecrets, legacy bool, err error) {
+	secrets = &DeviceGroupSecrets{}
+	for _, field := range []struct {
+		column string
+		stored string
+		plain  *string
+	}{
+		{columnTOTPSecret, group.TOTPSecret, &secrets.TOTPSecret},
+		{columnOnceKey, group.OnceKey, &secrets.OnceKey},
+		{columnLastUsedOnceKey, group.LastUsedOnceKey, &secrets.LastUsedOnceKey},
+		{columnAuthSecret, group.AuthSecret, &secrets.AuthSecret},
+	} {
+		if allowPlaintext && !keyring.IsSealed(field.stored) {
+			*field.plain = field.stored
+			legacy = legacy || field.stored != ""
+			continue
+		}
+		plain, err := global.Keyring.Open(field.stored, secretContext(field.column, group.ID))
+		if err != nil && !secretContextsBound.Load() {
+			// 旧版本仅以列名作为用途标识，后台重新加密完成前仍可解密
+			if legacyPlain, legacyErr := global.Keyring.Open(field.stored, field.column); legacyErr == nil {
+				plain, err, legacy = legacyPlain, nil, true
+			}
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("解密设备组 %d 的 %s 失败: %w", group.ID, field.column, err)
+		}
+		*field.plain = plain
+	}
+	return secrets, legacy, nil
+}
+
+// columns 加密设备组 groupID 的全部认证密钥，返回可直接用于 Updates 的列值
+func (s *DeviceGroupSecrets) columns(groupID uint) (map[string]interface{}, error) {
+	return sealSecretColumns(groupID, map[string]string{
+		columnTOTPSecret:      s.TOTPSecret,
+		columnOnceKey:         s.OnceKey,
+		columnLastUsedOnceKey: s.LastUsedOnceKey,
+		columnAuthSecret:      s.AuthSecret,
+	})
+}
+
+// apply 加密认证密钥并写入刚创建的设备组，密文与设备组ID绑定，因此需在创建记录后调用
+func (s *DeviceGroupSecrets) apply(tx *gorm.DB, group *entity.DeviceGroup) error {
+	columns, err := s.columns(group.ID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(group).UpdateColumns(columns).Error; err != nil {
+		return fmt.Errorf("保存设备组 %d 的认证密钥失败: %w", group.ID, err)
+	}
+	group.TOTPSecret = columns[columnTOTPSecret].(string)
+	group.OnceKey = columns[columnOnceKey].(string)
+	group.OnceKeyHash = columns[secretHashColumns[columnOnceKey]].(string)
+	group.LastUsedOnceKey = columns[columnLastUsedOnceKey].(string)
+	group.LastUsedOnceKeyHash = columns[secretHashColumns[columnLastUsedOnceKey]].(string)
+	group.AuthSecret = columns[columnAuthSecret].(string)
+	return nil
+}
+
+// sealSecretColumns 使用当前主密钥加密设备组 groupID 的认证密钥并计算索引，values 的键为密钥列名
+func sealSecretColumns(groupID uint, values map[string]string) (map[string]interface{}, error) {
+	columns := make(map[string]interface{}, len(values)*2)
+	for column, value := range values {
+		sealed, err := global.Keyring.Seal(value, secretContext(column, groupID))
+		if err != nil {
+			return nil, fmt.Errorf("加密 %s 失败: %w", column, err)
+		}
+		columns[column] = sealed
+		if hashColumn, ok := secretHashColumns[column]; ok {
+			columns[hashColumn] = global.Keyring.Hash(value)
+		}
+	}
+	return columns, nil
+}
+
+// SealPlaintextDeviceGroupSecrets 加密旧版本以明文保存的设备组认证密钥
+// 明文数据没有索引，无法通过一次性密钥查到，需在处理认证请求前完成
+func SealPlaintextDeviceGroupSecrets() error {
+	pattern := keyring.SealedPrefix + "%"
+	var groups []entity.DeviceGroup
+	if err := global.DB.Unscoped().
+		Where("totp_secret NOT LIKE ? OR once_key NOT LIKE ? OR (last_used_once_key <> '' AND last_used_once_key NOT LIKE ?)",
+			pattern, pattern, pattern).
+		Find(&groups).Error; err != nil {
+		return fmt.Errorf("查询明文保存的设备组密钥失败: %w", err)
+	}
+
+	for i := range groups {
+		if _, err := resealDeviceGroup(&groups[i]); err != nil {
+			return err
+		}
+	}
+	if len(groups) > 0 {
+		logger.Logger.Info("已加密明文保存的设备组认证密钥", "device_groups", len(groups))
+	}
+	return nil
+}
+
+// StartKeyringReencryption 在后台将旧主密钥加密的设备组认证密钥重新加密为当前主密钥，并迁移旧版本的用途标识
+// 重新加密期间旧主密钥仍可解密和查询，服务无需停机；全部完成后即可从配置中移除旧主密钥
+func StartKeyringReencryption() {
+	keyringStop = make(chan struct{})
+	go runKeyringReencryption(keyringStop)
+}
+
+// StopKeyringReencryption 停止后台重新加密任务，未完成的部分在下次启动时继续
+func StopKeyringReencryption() {
+	if keyringStop != nil {
+		close(keyringStop)
+		keyringStop = nil
+	}
+}
+
+// runKeyringReencryption 重新加密全部设备组，失败时定期重试直到完成
+func runKeyringReencryption(stop <-chan struct{}) {
+	for {
+		resealed, err := reencryptDeviceGroups(stop)
+		if err == nil {
+			if resealed > 0 {
+				logger.Logger.Info("设备组认证密钥已重新加密", "active_key_id", global.Keyring.ActiveID(), "device_groups", resealed)
+			}
+			return
+		}
+		logger.Logger.Error("重新加密设备组认证密钥失败，稍后重试", "error", err)
+
+		select {
+		case <-time.After(reencryptRetryInterval):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// reencryptDeviceGroups 分批检查全部设备组，重新加密不是由当前主密钥加密或未绑定设备组ID的密钥
+// 全部完成后不再接受旧版本的用途标识
+func reencryptDeviceGroups(stop <-chan struct{}) (int, error) {
+	resealed := 0
+	var batch []entity.DeviceGroup
+	err := global.DB.Unscoped().
+		Select("id", columnTOTPSecret, columnOnceKey, columnLastUsedOnceKey, columnAuthSecret).
+		FindInBatches(&batch, global.Config.Security.Keyring.ReencryptBatchSize, func(*gorm.DB, int) error {
+			for i := range batch {
+				select {
+				case <-stop:
+					return errReencryptionStopped
+				default:
+				}
+
+				ok, err := resealDeviceGroup(&batch[i])
+				if err != nil {
+					return err
+				}
+				if ok {
+					resealed++
+				}
+			}
+			return nil
+		}).Error
+	if errors.Is(err, errReencryptionStopped) {
+		return resealed, nil
+	}
+	if err == nil {
+		secretContextsBound.Store(true)
+	}
+	return resealed, err
+}
+
+// resealDeviceGroup 使用当前主密钥和绑定设备组ID的用途标识重新加密设备组的认证密钥并更新索引，无需迁移时不做修改
+// 以读取到的密文为条件更新，期间密钥被并发轮换时重新读取，轮换可能只写入了部分列
+func resealDeviceGroup(group *entity.DeviceGroup) (bool, error) {
+	for attempt := 0; attempt < resealAttempts; attempt++ {
+		secrets, legacy, err := openDeviceGroupSecrets(group, true)
+		if err != nil {
+			return false, err
+		}
+		ring := global.Keyring
+		if !legacy && !ring.NeedsReseal(group.TOTPSecret) && !ring.NeedsReseal(group.OnceKey) &&
+			!ring.NeedsReseal(group.LastUsedOnceKey) && !ring.NeedsReseal(group.AuthSecret) {
+			return false, nil
+		}
+
+		columns, err := secrets.columns(group.ID)
+		if err != nil {
+			return false, err
+		}
+		result := global.DB.Model(&entity.DeviceGroup{}).Unscoped().
+			Where("id = ? AND totp_secret = ? AND once_key = ? AND last_used_once_key = ? AND auth_secret = ?",
+				group.ID, group.TOTPSecret, group.OnceKey, group.LastUsedOnceKey, group.AuthSecret).
+			UpdateColumns(columns)
+		if result.Error != nil {
+			return false, fmt.Errorf("更新设备组 %d 的认证密钥失败: %w", group.ID, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return true, nil
+		}
+
+		err = global.DB.Unscoped().
+			Select("id", columnTOTPSecret, columnOnceKey, columnLastUsedOnceKey, columnAuthSecret).
+			First(group, group.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("查询设备组 %d 的认证密钥失败: %w", group.ID, err)
+		}
+	}
+	return false, fmt.Errorf("设备组 %d 的认证密钥被并发修改，稍后重试", group.ID)
+}
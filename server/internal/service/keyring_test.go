@@ -0,0 +1,110 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+)
+
+// resetSecretContextsBound 模拟尚未完成迁移的旧版本数据
+func resetSecretContextsBound(t *testing.T) {
+	t.Helper()
+	prev := secretContextsBound.Load()
+	secretContextsBound.Store(false)
+	t.Cleanup(func() { secretContextsBound.Store(prev) })
+}
+
+// copySecretColumns 将设备组 from 的认证密钥密文原样写入设备组 to
+func copySecretColumns(t *testing.T, from, to uint) {
+	t.Helper()
+	src := loadDeviceGroup(t, from)
+	if err := global.DB.Model(&entity.DeviceGroup{}).Where("id = ?", to).UpdateColumns(map[string]interface{}{
+		columnTOTPSecret: src.TOTPSecret,
+		columnOnceKey:    src.OnceKey,
+		"once_key_hash":  src.OnceKeyHash,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceGroupSecretsAreBoundToGroup(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	source, _, secrets := createTestDeviceGroup(t, user, "SN1")
+	target, _, _ := createTestDeviceGroup(t, user, "SN2")
+
+	opened, err := OpenDeviceGroupSecrets(loadDeviceGroup(t, source.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.TOTPSecret != secrets.TOTPSecret || opened.OnceKey != secrets.OnceKey {
+		t.Fatal("解密结果与原始密钥不一致")
+	}
+
+	copySecretColumns(t, source.ID, target.ID)
+	if _, err := OpenDeviceGroupSecrets(loadDeviceGroup(t, target.ID)); err == nil {
+		t.Fatal("从其他设备组复制的密文不应能解密")
+	}
+}
+
+func TestReencryptDeviceGroupsMigratesLegacyContexts(t *testing.T) {
+	setupTestDB(t)
+	resetSecretContextsBound(t)
+	global.Config.Security.Keyring.ReencryptBatchSize = 10
+	user := createTestUser(t, "alice")
+	group, _, secrets := createTestDeviceGroup(t, user, "SN1")
+	other, _, _ := createTestDeviceGroup(t, user, "SN2")
+
+	// 旧版本仅以列名作为用途标识
+	legacy := make(map[string]interface{})
+	for column, value := range map[string]string{
+		columnTOTPSecret:      secrets.TOTPSecret,
+		columnOnceKey:         secrets.OnceKey,
+		columnLastUsedOnceKey: secrets.LastUsedOnceKey,
+		columnAuthSecret:      secrets.AuthSecret,
+	} {
+		sealed, err := global.Keyring.Seal(value, column)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacy[column] = sealed
+	}
+	if err := global.DB.Model(group).UpdateColumns(legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDeviceGroupSecrets(loadDeviceGroup(t, group.ID)); err != nil {
+		t.Fatalf("迁移完成前应能解密旧版本的密文: %v", err)
+	}
+
+	resealed, err := reencryptDeviceGroups(make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resealed != 1 {
+		t.Errorf("重新加密 %d 个设备组，应只迁移旧版本的设备组", resealed)
+	}
+	if !secretContextsBound.Load() {
+		t.Fatal("全部迁移完成后应不再接受旧版本的用途标识")
+	}
+
+	migrated := loadDeviceGroup(t, group.ID)
+	if migrated.TOTPSecret == legacy[columnTOTPSecret] || migrated.OnceKeyHash != group.OnceKeyHash {
+		t.Fatal("迁移后应重新加密密文并保持索引不变")
+	}
+	opened, err := OpenDeviceGroupSecrets(migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.TOTPSecret != secrets.TOTPSecret || opened.OnceKey != secrets.OnceKey || opened.AuthSecret != secrets.AuthSecret {
+		t.Fatal("迁移后的解密结果与原始密钥不一致")
+	}
+
+	// 迁移完成后，旧版本的密文复制到其他设备组也不能解密
+	if err := global.DB.Model(other).UpdateColumns(map[string]interface{}{columnTOTPSecret: legacy[columnTOTPSecret]}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDeviceGroupSecrets(loadDeviceGroup(t, other.ID)); err == nil {
+		t.Fatal("迁移完成后不应再接受旧版本的密文")
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+)
+
+// loginDecoys 用户名不存在或无法推送时返回的占位登录
+// 占位登录与真实登录的响应一致，截止前查询状态始终为等待确认，之后为过期，调用方无法据此枚举用户名
+type loginDecoys struct {
+	mu     sync.Mutex
+	logins map[string]*loginDecoy
+}
+
+// loginDecoy 占位登录
+type loginDecoy struct {
+	expiresAt   time.Time
+	redirectURL string // 过期后浏览器需跳转的地址，OIDC登录使用
+}
+
+// newLoginDecoys 创建占位登录集合
+func newLoginDecoys() *loginDecoys {
+	return &loginDecoys{logins: make(map[string]*loginDecoy)}
+}
+
+// add 记录令牌摘要对应的占位登录，返回与真实登录形式相同的认证会话
+func (d *loginDecoys) add(tokenHash string, timeout time.Duration, redirectURL string) *entity.AuthSession {
+	now := time.Now()
+	decoy := &loginDecoy{expiresAt: now.Add(timeout), redirectURL: redirectURL}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.logins) > attemptLimiterSweepSize {
+		for key, l := range d.logins {
+			if !now.Before(l.expiresAt) {
+				delete(d.logins, key)
+			}
+		}
+	}
+	d.logins[tokenHash] = decoy
+
+	return &entity.AuthSession{ID: uuid.New().String(), Status: consts.AuthStatusPending, ExpiresAt: decoy.expiresAt}
+}
+
+// check 返回占位登录的状态，令牌不是占位登录时返回 nil，过期后占位登录被删除
+func (d *loginDecoys) check(tokenHash string) (string, *loginDecoy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	decoy, ok := d.logins[tokenHash]
+	if !ok {
+		return "", nil
+	}
+	if time.Now().Before(decoy.expiresAt) {
+		return consts.AuthStatusPending, decoy
+	}
+	delete(d.logins, tokenHash)
+	return consts.AuthStatusExpired, decoy
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/initialize"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/keyring"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	os.Exit(m.Run())
+}
+
+// setupTestDB 使用临时目录中的SQLite数据库替换 global.DB 并迁移全部表结构，同时设置测试所需的配置和密钥环
+// 测试结束时恢复原来的全局状态
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)"),
+		&gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := keyring.New("test", map[string][]byte{"test": bytes.Repeat([]byte{7}, keyring.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevDB, prevConfig, prevKeyring := global.DB, global.Config, global.Keyring
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		global.DB, global.Config, global.Keyring = prevDB, prevConfig, prevKeyring
+	})
+
+	global.DB = db
+	global.Keyring = ring
+	global.Config = &config.Config{
+		Security: config.SecurityConfig{
+			EncryptionKey:   "test-encryption-key",
+			RecoveryCodeKey: "test-recovery-code-key",
+		},
+		Fingerprint: config.FingerprintConfig{AcceptThreshold: 80, ReviewThreshold: 50},
+		Admin: config.AdminConfig{
+			LoginTimeout:       2 * time.Minute,
+			SessionIdleTimeout: 30 * time.Minute,
+			SessionMaxLifetime: 12 * time.Hour,
+		},
+		OIDC: config.OIDCConfig{LoginTimeout: 2 * time.Minute},
+	}
+
+	if err := initialize.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createTestUser 创建启用的用户
+func createTestUser(t *testing.T, username string) *entity.User {
+	t.Helper()
+	user := &entity.User{Username: username, IsActive: true}
+	if err := global.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createTestDeviceGroup 为用户创建带认证密钥的设备组，并在组内创建一个已激活的设备
+func createTestDeviceGroup(t *testing.T, user *entity.User, serial string, permissions ...string) (*entity.DeviceGroup, *entity.Device, *DeviceGroupSecrets) {
+	t.Helper()
+	secrets, err := newDeviceGroupSecrets(serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := &entity.DeviceGroup{
+		Name:        "group-" + serial,
+		UserID:      &user.ID,
+		Permissions: permissions,
+		IsActive:    true,
+	}
+	if err := global.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.apply(global.DB, group); err != nil {
+		t.Fatal(err)
+	}
+	device := &entity.Device{
+		Name:               "device-" + serial,
+		DeviceGroupID:      &group.ID,
+		SerialNumber:       serial,
+		VolumeSerialNumber: "vol-" + serial,
+		IsActive:           true,
+		HeartbeatInterval:  30,
+	}
+	if err := global.DB.Create(device).Error; err != nil {
+		t.Fatal(err)
+	}
+	return group, device, secrets
+}
+
+// fakeHub 记录在线设备和下发消息的 WSHubInterface 实现
+type fakeHub struct {
+	mu           sync.Mutex
+	userDevices  map[uint]uint
+	sent         map[uint][][]byte
+	disconnected []uint
+}
+
+// setupFakeHub 替换全局Hub，测试结束时恢复
+func setupFakeHub(t *testing.T) *fakeHub {
+	t.Helper()
+	hub := &fakeHub{userDevices: make(map[uint]uint), sent: make(map[uint][][]byte)}
+	prev := GetWSHub()
+	SetWSHub(hub)
+	t.Cleanup(func() { SetWSHub(prev) })
+	return hub
+}
+
+// connect 将设备登记为用户当前连接的设备
+func (h *fakeHub) connect(userID, deviceID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.userDevices[userID] = deviceID
+}
+
+func (h *fakeHub) IsUserOnline(userID uint) bool {
+	_, ok := h.GetUserDeviceID(userID)
+	return ok
+}
+
+func (h *fakeHub) GetUserDeviceID(userID uint) (uint, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	deviceID, ok := h.userDevices[userID]
+	return deviceID, ok
+}
+
+func (h *fakeHub) IsDeviceOnline(deviceID uint) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, id := range h.userDevices {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fakeHub) SendToUser(userID uint, data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.userDevices[userID]; !ok {
+		return fmt.Errorf("用户 %d 未在线", userID)
+	}
+	h.sent[userID] = append(h.sent[userID], data)
+	return nil
+}
+
+func (h *fakeHub) OnDeviceConnect(deviceID uint) error { return nil }
+
+func (h *fakeHub) OnDeviceDisconnect(deviceID uint) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.disconnected = append(h.disconnected, deviceID)
+	for userID, id := range h.userDevices {
+		if id == deviceID {
+			delete(h.userDevices, userID)
+		}
+	}
+	return nil
+}
+
+func (h *fakeHub) GetOnlineDevicesCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.userDevices)
+}
+
+func (h *fakeHub) LinkDeviceToUser(deviceID uint, userID uint) error {
+	h.connect(userID, deviceID)
+	return nil
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/notify"
+)
+
+const (
+	// SubscriptionSourceConfig 配置文件中定义的订阅
+	SubscriptionSourceConfig = "config"
+	// SubscriptionSourceAdmin 管理后台添加的订阅
+	SubscriptionSourceAdmin = "admin"
+)
+
+// notificationStop 停止后台通知任务
+var notificationStop chan struct{}
+
+// Notify 向订阅了该类别的管理员发送通知，未初始化通知时忽略
+func Notify(category, title, body string) {
+	if global.Notifier == nil {
+		return
+	}
+	global.Notifier.Publish(notify.Notification{
+		Category: category,
+		Title:    title,
+		Body:     body,
+	})
+}
+
+// StartNotifications 加载订阅并启动通知分发和API密钥过期检查
+func StartNotifications() error {
+	if global.Notifier == nil {
+		return nil
+	}
+	if err := ReloadNotificationSubscriptions(); err != nil {
+		return err
+	}
+
+	global.Notifier.Start()
+
+	notificationStop = make(chan struct{})
+	go runAPIKeyExpiryCheck(notificationStop)
+
+	logger.Logger.Info("管理员通知已启动")
+	return nil
+}
+
+// StopNotifications 停止后台通知任务并发送剩余的暂存通知
+func StopNotifications() {
+	if global.Notifier == nil {
+		return
+	}
+	if notificationStop != nil {
+		close(notificationStop)
+		notificationStop = nil
+	}
+	global.Notifier.Stop()
+}
+
+// ReloadNotificationSubscriptions 重新加载配置文件和管理后台定义的订阅
+func ReloadNotificationSubscriptions() error {
+	if global.Notifier == nil {
+		return nil
+	}
+
+	var records []entity.NotificationSubscription
+	if err := global.DB.Where("is_active = ?", true).Find(&records).Error; err != nil {
+		return fmt.Errorf("查询通知订阅失败: %w", err)
+	}
+
+	configured := global.Config.Notification.Subscriptions
+	subs := make([]notify.Subscription, 0, len(configured)+len(records))
+	for i, sub := range configured {
+		subs = append(subs, notify.Subscription{
+			ID:             fmt.Sprintf("%s-%d", SubscriptionSourceConfig, i),
+			Channel:        sub.Channel,
+			Categories:     sub.Categories,
+			Throttle:       sub.Throttle,
+			DigestInterval: sub.DigestInterval,
+		})
+	}
+	for _, record := range records {
+		subs = append(subs, notify.Subscription{
+			ID:             fmt.Sprintf("%s-%d", SubscriptionSourceAdmin, record.ID),
+			Channel:        record.Channel,
+			Categories:     record.Categories,
+			Throttle:       time.Duration(record.ThrottleSeconds) * time.Second,
+			DigestInterval: time.Duration(record.DigestIntervalSeconds) * time.Second,
+		})
+	}
+
+	global.Notifier.SetSubscriptions(subs)
+	return nil
+}
+
+// GetNotificationChannels 获取配置的通知渠道
+func GetNotificationChannels() []response.NotificationChannelResponse {
+	channels := make([]response.NotificationChannelResponse, 0, len(global.Config.Notification.Channels))
+	for _, ch := range global.Config.Notification.Channels {
+		channels = append(channels, response.NotificationChannelResponse{Name: ch.Name, Type: ch.Type})
+	}
+	return channels
+}
+
+// GetNotificationSubscriptions 获取全部通知订阅，配置文件中的订阅排在前面
+func GetNotificationSubscriptions() ([]response.NotificationSubscriptionResponse, error) {
+	var records []entity.NotificationSubscription
+	if err := global.DB.Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询通知订阅失败: %w", err)
+	}
+
+	configured := global.Config.Notification.Subscriptions
+	subs := make([]response.NotificationSubscriptionResponse, 0, len(configured)+len(records))
+	for _, sub := range configured {
+		subs = append(subs, response.NotificationSubscriptionResponse{
+			Source:                SubscriptionSourceConfig,
+			Channel:               sub.Channel,
+			Categories:            sub.Categories,
+			ThrottleSeconds:       int(sub.Throttle / time.Second),
+			DigestIntervalSeconds: int(sub.DigestInterval / time.Second),
+			IsActive:              true,
+		})
+	}
+	for i := range records {
+		subs = append(subs, *ConvertToNotificationSubscriptionResponse(&records[i]))
+	}
+	return subs, nil
+}
+
+// ConvertToNotificationSubscriptionResponse 将管理后台添加的订阅转换为响应结构
+func ConvertToNotificationSubscriptionResponse(sub *entity.NotificationSubscription) *response.NotificationSubscriptionResponse {
+	return &response.NotificationSubscriptionResponse{
+		ID:                    sub.ID,
+		Source:                SubscriptionSourceAdmin,
+		Channel:               sub.Channel,
+		Categories:            sub.Categories,
+		ThrottleSeconds:       sub.ThrottleSeconds,
+		DigestIntervalSeconds: sub.DigestIntervalSeconds,
+		IsActive:              sub.IsActive,
+		CreatedAt:             sub.CreatedAt,
+		UpdatedAt:             sub.UpdatedAt,
+	}
+}
+
+// CreateNotificationSubscription 添加通知订阅
+func CreateNotificationSubscription(req *request.NotificationSubscriptionRequest) (*entity.NotificationSubscription, error) {
+	if err := validateNotificationSubscription(req); err != nil {
+		return nil, err
+	}
+
+	sub := entity.NotificationSubscription{
+		Channel:               req.Channel,
+		Categories:            req.Categories,
+		ThrottleSeconds:       req.ThrottleSeconds,
+		DigestIntervalSeconds: req.DigestIntervalSeconds,
+		IsActive:              true,
+	}
+	if sub.Categories == nil {
+		sub.Categories = []string{}
+	}
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+
+	// IsActive 为 false 时需显式写入，避免被数据库默认值覆盖
+	if err := global.DB.Select("*").Create(&sub).Error; err != nil {
+		return nil, fmt.Errorf("创建通知订阅失败: %w", err)
+	}
+
+	if err := ReloadNotificationSubscriptions(); err != nil {
+		logger.Logger.Error("重新加载通知订阅失败", "error", err)
+	}
+	return &sub, nil
+}
+
+// UpdateNotificationSubscription 更新通知订阅
+func UpdateNotificationSubscription(id uint, req *request.NotificationSubscriptionRequest) (*entity.NotificationSubscription, error) {
+	var sub entity.NotificationSubscription
+	if err := global.DB.Where("id = ?", id).First(&sub).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrNotificationSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("查询通知订阅失败: %w", err)
+	}
+
+	if err := validateNotificationSubscription(req); err != nil {
+		return nil, err
+	}
+
+	sub.Channel = req.Channel
+	sub.Categories = req.Categories
+	if sub.Categories == nil {
+		sub.Categories = []string{}
+	}
+	sub.ThrottleSeconds = req.ThrottleSeconds
+	sub.DigestIntervalSeconds = req.DigestIntervalSeconds
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+
+	if err := global.DB.Select("channel", "categories", "throttle_seconds", "digest_interval_seconds", "is_active").
+		Updates(&sub).Error; err != nil {
+		return nil, fmt.Errorf("更新通知订阅失败: %w", err)
+	}
+
+	if err := ReloadNotificationSubscriptions(); err != nil {
+		logger.Logger.Error("重新加载通知订阅失败", "error", err)
+	}
+	return &sub, nil
+}
+
+// DeleteNotificationSubscription 删除通知订阅
+func DeleteNotificationSubscription(id uint) error {
+	result := global.DB.Where("id = ?", id).Delete(&entity.NotificationSubscription{})
+	if result.Error != nil {
+		return fmt.Errorf("删除通知订阅失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errs.ErrNotificationSubscriptionNotFound
+	}
+
+	if err := ReloadNotificationSubscriptions(); err != nil {
+		logger.Logger.Error("重新加载通知订阅失败", "error", err)
+	}
+	return nil
+}
+
+// TestNotificationChannel 向渠道发送一条测试通知并等待结果
+func TestNotificationChannel(name string) error {
+	if !notificationChannelExists(name) || global.Notifier == nil {
+		return errs.ErrNotificationChannelNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notify.DefaultSendTimeout)
+	defer cancel()
+
+	return global.Notifier.Send(ctx, name, []notify.Notification{{
+		Category: "test",
+		Title:    "测试通知",
+		Body:     "这是一条来自 EasyUKey 管理后台的测试通知。",
+		Time:     time.Now(),
+	}})
+}
+
+// validateNotificationSubscription 校验订阅的渠道、类别和间隔
+func validateNotificationSubscription(req *request.NotificationSubscriptionRequest) error {
+	if !notificationChannelExists(req.Channel) {
+		return errs.ErrNotificationChannelNotFound
+	}
+	for _, category := range req.Categories {
+		if !slices.Contains(consts.NotifyCategories, category) {
+			return errs.ErrNotificationCategoryInvalid
+		}
+	}
+	if req.ThrottleSeconds < 0 || req.DigestIntervalSeconds < 0 {
+		return errs.ErrNotificationSubscriptionInvalid
+	}
+	return nil
+}
+
+func notificationChannelExists(name string) bool {
+	for _, ch := range global.Config.Notification.Channels {
+		if ch.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// authFailureTracker 统计各用户在窗口内的认证失败次数
+type authFailureTracker struct {
+	mu       sync.Mutex
+	failures map[uint][]time.Time
+}
+
+var authFailures = &authFailureTracker{failures: make(map[uint][]time.Time)}
+
+// record 记录一次失败，窗口内失败次数达到阈值时返回次数并清零，否则返回0
+func (t *authFailureTracker) record(userID uint, now time.Time, window time.Duration, threshold int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recent := t.failures[userID][:0]
+	for _, at := range t.failures[userID] {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+
+	if len(recent) >= threshold {
+		delete(t.failures, userID)
+		return len(recent)
+	}
+	t.failures[userID] = recent
+	return 0
+}
+
+// recordAuthFailure 记录用户认证失败（包括密钥校验失败、权限不足和用户拒绝），短时间内多次失败时通知管理员
+// 用户反复拒绝可能是有人在持续发起认证请求，同样需要关注
+func recordAuthFailure(userID uint, reason string) {
+	cfg := global.Config.Notification
+	count := authFailures.record(userID, time.Now(), cfg.AuthFailureWindow, cfg.AuthFailureThreshold)
+	if count == 0 {
+		return
+	}
+
+	var user entity.User
+	username := fmt.Sprintf("ID %d", userID)
+	if err := global.DB.Select("username").Where("id = ?", userID).First(&user).Error; err == nil {
+		username = user.Username
+	}
+
+	Notify(consts.NotifyAuthFailures,
+		fmt.Sprintf("用户 %s 多次认证失败", username),
+		fmt.Sprintf("用户 %s 在 %s 内认证失败 %d 次，最近一次原因：%s", username, cfg.AuthFailureWindow, count, reason))
+}
+
+// runAPIKeyExpiryCheck 定期检查即将过期的API密钥
+func runAPIKeyExpiryCheck(stop <-chan struct{}) {
+	checkAPIKeyExpiry()
+
+	ticker := time.NewTicker(global.Config.Notification.APIKeyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			checkAPIKeyExpiry()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkAPIKeyExpiry 通知即将过期的API密钥，每个密钥只通知一次
+func checkAPIKeyExpiry() {
+	now := time.Now()
+	var keys []entity.APIKey
+	if err := global.DB.Where("is_active = ? AND expires_at > ? AND expires_at <= ? AND expiry_notified_at IS NULL",
+		true, now, now.Add(global.Config.Notification.APIKeyExpiryWarning)).Find(&keys).Error; err != nil {
+		logger.Logger.Error("查询即将过期的API密钥失败", "error", err)
+		return
+	}
+
+	for _, key := range keys {
+		Notify(consts.NotifyAPIKeyExpiring,
+			fmt.Sprintf("API密钥 %s 即将过期", key.Name),
+			fmt.Sprintf("API密钥 %s（ID %d）将于 %s 过期", key.Name, key.ID, key.ExpiresAt.Format("2006-01-02 15:04:05")))
+
+		if err := global.DB.Model(&key).Update("expiry_notified_at", &now).Error; err != nil {
+			logger.Logger.Error("记录API密钥过期通知失败", "api_key_id", key.ID, "error", err)
+		}
+	}
+}
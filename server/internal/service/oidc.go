@@ -0,0 +1,736 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/oidc"
+)
+
+// OIDC授权状态
+const (
+	oidcAuthorizationPending     = "pending"      // 等待U盘确认
+	oidcAuthorizationCodeIssued  = "code_issued"  // 已签发授权码
+	oidcAuthorizationTokenIssued = "token_issued" // 已签发令牌
+)
+
+// OAuth 2.0 / OIDC 协议错误码
+const (
+	OIDCErrInvalidRequest          = "invalid_request"
+	OIDCErrInvalidClient           = "invalid_client"
+	OIDCErrInvalidGrant            = "invalid_grant"
+	OIDCErrInvalidToken            = "invalid_token"
+	OIDCErrUnsupportedGrantType    = "unsupported_grant_type"
+	OIDCErrUnsupportedResponseType = "unsupported_response_type"
+	OIDCErrInvalidScope            = "invalid_scope"
+	OIDCErrAccessDenied            = "access_denied"
+	OIDCErrLoginRequired           = "login_required"
+)
+
+// oidcScopeOpenID 授权请求必须包含的 scope
+const oidcScopeOpenID = "openid"
+
+var (
+	// oidcLoginLimiter 按用户名和来源IP限制OIDC登录推送
+	oidcLoginLimiter = newAttemptLimiter(pushLoginAttemptWindow)
+	// oidcLoginDecoys 用户不存在或无法推送时的占位登录
+	oidcLoginDecoys = newLoginDecoys()
+)
+
+// OIDCError OAuth 2.0 协议错误，按规范以 error 和 error_description 返回给客户端
+type OIDCError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OIDCError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// newOIDCError 创建协议错误
+func newOIDCError(code, description string) *OIDCError {
+	return &OIDCError{Code: code, Description: description}
+}
+
+// OIDCAuthorizeRequest 授权请求参数，登录页发起U盘确认时附带 username
+type OIDCAuthorizeRequest struct {
+	ClientID            string `query:"client_id" json:"client_id"`
+	RedirectURI         string `query:"redirect_uri" json:"redirect_uri"`
+	ResponseType        string `query:"response_type" json:"response_type"`
+	Scope               string `query:"scope" json:"scope"`
+	State               string `query:"state" json:"state"`
+	Nonce               string `query:"nonce" json:"nonce"`
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method"`
+	Prompt              string `query:"prompt" json:"prompt"`
+	LoginHint           string `query:"login_hint" json:"login_hint"`
+	Username            string `json:"username"`
+}
+
+// OIDCTokenRequest 令牌请求参数
+type OIDCTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OIDCTokenResponse 令牌响应
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// OIDCUserInfo 用户信息，ID Token 在此基础上增加令牌相关声明
+type OIDCUserInfo struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username"`
+	DeviceGroupID     uint   `json:"device_group_id"`
+	DeviceGroup       string `json:"device_group"`
+	Action            string `json:"action,omitempty"`
+	AuthTime          int64  `json:"auth_time"` // U盘批准登录的时间
+}
+
+// oidcIDTokenClaims ID Token 声明
+type oidcIDTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Audience  string   `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Nonce     string   `json:"nonce,omitempty"`
+	AtHash    string   `json:"at_hash"`
+	AMR       []string `json:"amr"`
+	OIDCUserInfo
+}
+
+// OIDCDiscovery OpenID Provider 元数据
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	AuthorizationResponseISSSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+// GetOIDCDiscovery 获取 OpenID Provider 元数据
+func GetOIDCDiscovery() *OIDCDiscovery {
+	issuer := global.Config.OIDC.Issuer
+	return &OIDCDiscovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oidc/authorize",
+		TokenEndpoint:                     issuer + "/oidc/token",
+		UserinfoEndpoint:                  issuer + "/oidc/userinfo",
+		JWKSURI:                           issuer + "/oidc/jwks",
+		ScopesSupported:                   []string{oidcScopeOpenID},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{oidc.SigningAlgorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{oidc.CodeChallengeMethod},
+		ClaimsSupported: []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "amr",
+			"preferred_username", "device_group_id", "device_group", "action"},
+		AuthorizationResponseISSSupported: true,
+	}
+}
+
+// GetOIDCJWKS 获取 ID Token 签名公钥
+func GetOIDCJWKS() *oidc.JWKSet {
+	return global.OIDCSigningKey.JWKS()
+}
+
+// hashOIDCToken 计算登录页令牌、授权码和访问令牌的摘要，数据库只保存摘要
+func hashOIDCToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateOIDCToken 生成随机令牌
+func generateOIDCToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ValidateOIDCAuthorizeRequest 校验授权请求
+// 客户端或回调地址无效时返回 ErrOIDCClientNotFound 或 ErrOIDCRedirectURIInvalid，此时不能重定向到回调地址；
+// 其余错误返回 *OIDCError，应通过 OIDCErrorRedirect 告知客户端
+func ValidateOIDCAuthorizeRequest(req *OIDCAuthorizeRequest) (*entity.OIDCClient, error) {
+	client, err := findActiveOIDCClient(req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, errs.ErrOIDCRedirectURIInvalid
+	}
+
+	switch {
+	case req.ResponseType != "code":
+		return client, newOIDCError(OIDCErrUnsupportedResponseType, "仅支持授权码模式")
+	case !slices.Contains(strings.Fields(req.Scope), oidcScopeOpenID):
+		return client, newOIDCError(OIDCErrInvalidScope, "scope 必须包含 openid")
+	case req.CodeChallenge == "" || req.CodeChallengeMethod != oidc.CodeChallengeMethod:
+		return client, newOIDCError(OIDCErrInvalidRequest, "必须使用 S256 方式的 PKCE")
+	case slices.Contains(strings.Fields(req.Prompt), "none"):
+		// 每次登录都需要在U盘上确认，无法静默授权
+		return client, newOIDCError(OIDCErrLoginRequired, "需要在U盘上确认登录")
+	}
+	return client, nil
+}
+
+// OIDCErrorRedirect 构造携带错误信息的回调地址
+func OIDCErrorRedirect(req *OIDCAuthorizeRequest, oidcErr *OIDCError) string {
+	params := url.Values{}
+	params.Set("error", oidcErr.Code)
+	params.Set("error_description", oidcErr.Description)
+	return oidcRedirect(req.RedirectURI, req.State, params)
+}
+
+// oidcRedirect 在回调地址上附加授权响应参数
+func oidcRedirect(redirectURI, state string, params url.Values) string {
+	if state != "" {
+		params.Set("state", state)
+	}
+	params.Set("iss", global.Config.OIDC.Issuer)
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// StartOIDCLogin 登录页提交用户名后向用户的U盘发起认证，返回登录页轮询进度使用的令牌
+// 同一用户名和来源IP的登录次数受限；用户不存在、U盘不在线或没有客户端操作权限时返回占位登录，响应与正常发起时相同
+func StartOIDCLogin(req *OIDCAuthorizeRequest, clientIP string) (string, *entity.AuthSession, error) {
+	client, err := ValidateOIDCAuthorizeRequest(req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !oidcLoginLimiter.Allow(pushLoginAttemptKeys(req.Username, clientIP)...) {
+		logger.Logger.Warn("OIDC登录尝试次数过多", "username", req.Username, "client_ip", clientIP)
+		return "", nil, errs.ErrTooManyAttempts
+	}
+
+	token, err := generateOIDCToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("生成登录令牌失败: %w", err)
+	}
+
+	var user entity.User
+	if err := global.DB.Where("username = ? AND is_active = ?", req.Username, true).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Logger.Warn("OIDC登录失败：用户不存在", "client", client.Name, "username", req.Username, "client_ip", clientIP)
+			return token, addOIDCLoginDecoy(token, req), nil
+		}
+		return "", nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	challenge := make([]byte, 16)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", nil, fmt.Errorf("生成挑战码失败: %w", err)
+	}
+
+	authSession, err := StartAuth(&request.AuthRequest{
+		Username:  user.Username,
+		Challenge: hex.EncodeToString(challenge),
+		Action:    client.Action,
+		Message:   fmt.Sprintf("登录 %s（IP: %s）", client.Name, clientIP),
+		Timeout:   int(global.Config.OIDC.LoginTimeout / time.Second),
+	}, client.APIKey, clientIP)
+	if err != nil {
+		if errors.Is(err, errs.ErrUserNotFound) || errors.Is(err, errs.ErrUserNotOnline) || errors.Is(err, errs.ErrPermissionDenied) {
+			logger.Logger.Warn("OIDC登录失败：无法推送认证请求", "client", client.Name, "username", req.Username, "client_ip", clientIP, "error", err)
+			return token, addOIDCLoginDecoy(token, req), nil
+		}
+		return "", nil, err
+	}
+
+	authorization := entity.OIDCAuthorization{
+		ID:            hashOIDCToken(token),
+		OIDCClientID:  client.ID,
+		UserID:        user.ID,
+		AuthSessionID: authSession.ID,
+		Status:        oidcAuthorizationPending,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		State:         req.State,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		Action:        client.Action,
+		ClientIP:      clientIP,
+		ExpiresAt:     authSession.ExpiresAt,
+	}
+	if err := global.DB.Create(&authorization).Error; err != nil {
+		return "", nil, fmt.Errorf("创建OIDC授权失败: %w", err)
+	}
+
+	purgeOIDCAuthorizations()
+
+	return token, authSession, nil
+}
+
+// addOIDCLoginDecoy 记录占位登录，过期后与真实登录一样带 access_denied 错误跳回客户端
+func addOIDCLoginDecoy(token string, req *OIDCAuthorizeRequest) *entity.AuthSession {
+	redirectURL := oidcRedirect(req.RedirectURI, req.State, url.Values{
+		"error":             {OIDCErrAccessDenied},
+		"error_description": {errs.ErrOIDCAuthorizationRejected.Error()},
+	})
+	return oidcLoginDecoys.add(hashOIDCToken(token), global.Config.OIDC.LoginTimeout, redirectURL)
+}
+
+// CheckOIDCLogin 查询登录进度，U盘批准后签发授权码
+// 返回认证状态和需要浏览器跳转的回调地址，等待确认时回调地址为空；
+// 认证失败、被拒绝或过期时授权被删除，回调地址携带 access_denied 错误
+func CheckOIDCLogin(token string) (string, string, error) {
+	var authorization entity.OIDCAuthorization
+	if err := global.DB.Where("id = ? AND status = ?", hashOIDCToken(token), oidcAuthorizationPending).
+		First(&authorization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if status, decoy := oidcLoginDecoys.check(hashOIDCToken(token)); decoy != nil {
+				if status == consts.AuthStatusPending {
+					return status, "", nil
+				}
+				return status, decoy.redirectURL, nil
+			}
+			return "", "", errs.ErrOIDCAuthorizationInvalid
+		}
+		return "", "", fmt.Errorf("查询OIDC授权失败: %w", err)
+	}
+
+	var authSession entity.AuthSession
+	if err := global.DB.Preload("RespondingDevice.DeviceGroup").
+		Where("id = ?", authorization.AuthSessionID).First(&authSession).Error; err != nil {
+		return "", "", fmt.Errorf("查询认证会话失败: %w", err)
+	}
+
+	status := authSession.Status
+	if status != consts.AuthStatusCompleted && authSession.ExpiresAt.Before(time.Now()) {
+		status = consts.AuthStatusExpired
+	}
+
+	switch status {
+	case consts.AuthStatusCompleted:
+		// 再次确认响应的U盘属于发起登录的用户
+		device := authSession.RespondingDevice
+		if authSession.Result != consts.AuthResultSuccess || device == nil || device.DeviceGroup == nil ||
+			device.DeviceGroup.UserID == nil || *device.DeviceGroup.UserID != authorization.UserID {
+			global.DB.Delete(&authorization)
+			return consts.AuthStatusFailed, oidcRedirect(authorization.RedirectURI, authorization.State, url.Values{
+				"error":             {OIDCErrAccessDenied},
+				"error_description": {"U盘认证失败"},
+			}), nil
+		}
+
+		code, err := generateOIDCToken()
+		if err != nil {
+			return "", "", fmt.Errorf("生成授权码失败: %w", err)
+		}
+		// 以认证会话完成的时间作为批准时间
+		approvedAt := authSession.UpdatedAt
+		result := global.DB.Model(&entity.OIDCAuthorization{}).
+			Where("id = ? AND status = ?", authorization.ID, oidcAuthorizationPending).
+			Updates(map[string]interface{}{
+				"status":          oidcAuthorizationCodeIssued,
+				"code_hash":       hashOIDCToken(code),
+				"device_group_id": device.DeviceGroup.ID,
+				"approved_at":     &approvedAt,
+				"expires_at":      time.Now().Add(global.Config.OIDC.CodeTTL),
+			})
+		if result.Error != nil {
+			return "", "", fmt.Errorf("更新OIDC授权失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// 并发的状态查询已签发授权码
+			return "", "", errs.ErrOIDCAuthorizationInvalid
+		}
+
+		logger.Logger.Info("OIDC登录已批准",
+			"oidc_client_id", authorization.OIDCClientID,
+			"user_id", authorization.UserID,
+			"device_group_id", device.DeviceGroup.ID)
+		return consts.AuthStatusCompleted, oidcRedirect(authorization.RedirectURI, authorization.State, url.Values{
+			"code": {code},
+		}), nil
+
+	case consts.AuthStatusFailed, consts.AuthStatusRejected, consts.AuthStatusExpired:
+		global.DB.Delete(&authorization)
+		return status, oidcRedirect(authorization.RedirectURI, authorization.State, url.Values{
+			"error":             {OIDCErrAccessDenied},
+			"error_description": {errs.ErrOIDCAuthorizationRejected.Error()},
+		}), nil
+
+	default:
+		return consts.AuthStatusPending, "", nil
+	}
+}
+
+// ExchangeOIDCCode 使用授权码换取 ID Token 和访问令牌
+func ExchangeOIDCCode(req *OIDCTokenRequest) (*OIDCTokenResponse, error) {
+	if req.GrantType != "authorization_code" {
+		return nil, newOIDCError(OIDCErrUnsupportedGrantType, "仅支持 authorization_code")
+	}
+
+	client, err := authenticateOIDCClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Code == "" {
+		return nil, newOIDCError(OIDCErrInvalidRequest, "缺少授权码")
+	}
+	var authorization entity.OIDCAuthorization
+	if err := global.DB.Preload("User").Preload("DeviceGroup").
+		Where("code_hash = ?", hashOIDCToken(req.Code)).First(&authorization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newOIDCError(OIDCErrInvalidGrant, "授权码无效")
+		}
+		return nil, fmt.Errorf("查询OIDC授权失败: %w", err)
+	}
+
+	if authorization.Status == oidcAuthorizationTokenIssued {
+		// 授权码被重复使用，吊销已签发的访问令牌
+		global.DB.Delete(&authorization)
+		logger.Logger.Warn("OIDC授权码被重复使用，已吊销对应令牌",
+			"oidc_client_id", authorization.OIDCClientID, "user_id", authorization.UserID)
+		return nil, newOIDCError(OIDCErrInvalidGrant, "授权码已使用")
+	}
+	if authorization.Status != oidcAuthorizationCodeIssued || authorization.ExpiresAt.Before(time.Now()) ||
+		authorization.OIDCClientID != client.ID {
+		return nil, newOIDCError(OIDCErrInvalidGrant, "授权码无效或已过期")
+	}
+	if authorization.RedirectURI != req.RedirectURI {
+		return nil, newOIDCError(OIDCErrInvalidGrant, "redirect_uri 与授权请求不一致")
+	}
+	if !oidc.VerifyCodeChallenge(req.CodeVerifier, authorization.CodeChallenge) {
+		return nil, newOIDCError(OIDCErrInvalidGrant, "code_verifier 校验失败")
+	}
+	if authorization.User == nil || !authorization.User.IsActive || authorization.DeviceGroup == nil || authorization.ApprovedAt == nil {
+		return nil, newOIDCError(OIDCErrInvalidGrant, "用户或设备组已失效")
+	}
+
+	accessToken, err := generateOIDCToken()
+	if err != nil {
+		return nil, fmt.Errorf("生成访问令牌失败: %w", err)
+	}
+	now := time.Now()
+	expiresAt := now.Add(global.Config.OIDC.TokenTTL)
+
+	result := global.DB.Model(&entity.OIDCAuthorization{}).
+		Where("id = ? AND status = ?", authorization.ID, oidcAuthorizationCodeIssued).
+		Updates(map[string]interface{}{
+			"status":            oidcAuthorizationTokenIssued,
+			"access_token_hash": hashOIDCToken(accessToken),
+			"expires_at":        expiresAt,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新OIDC授权失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, newOIDCError(OIDCErrInvalidGrant, "授权码已使用")
+	}
+
+	idToken, err := global.OIDCSigningKey.Sign(&oidcIDTokenClaims{
+		Issuer:       global.Config.OIDC.Issuer,
+		Audience:     client.ClientID,
+		ExpiresAt:    expiresAt.Unix(),
+		IssuedAt:     now.Unix(),
+		Nonce:        authorization.Nonce,
+		AtHash:       oidc.AccessTokenHash(accessToken),
+		AMR:          []string{"hwk"},
+		OIDCUserInfo: *oidcUserInfo(&authorization),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("签发ID Token失败: %w", err)
+	}
+
+	return &OIDCTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(global.Config.OIDC.TokenTTL / time.Second),
+		IDToken:     idToken,
+		Scope:       authorization.Scope,
+	}, nil
+}
+
+// GetOIDCUserInfo 根据访问令牌获取用户信息
+func GetOIDCUserInfo(accessToken string) (*OIDCUserInfo, error) {
+	if accessToken == "" {
+		return nil, newOIDCError(OIDCErrInvalidToken, "缺少访问令牌")
+	}
+
+	var authorization entity.OIDCAuthorization
+	if err := global.DB.Preload("User").Preload("DeviceGroup").
+		Where("access_token_hash = ? AND status = ? AND expires_at > ?",
+			hashOIDCToken(accessToken), oidcAuthorizationTokenIssued, time.Now()).
+		First(&authorization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newOIDCError(OIDCErrInvalidToken, "访问令牌无效或已过期")
+		}
+		return nil, fmt.Errorf("查询OIDC授权失败: %w", err)
+	}
+	if authorization.User == nil || !authorization.User.IsActive || authorization.DeviceGroup == nil || authorization.ApprovedAt == nil {
+		return nil, newOIDCError(OIDCErrInvalidToken, "用户或设备组已失效")
+	}
+
+	return oidcUserInfo(&authorization), nil
+}
+
+// oidcUserInfo 由授权记录生成用户信息，调用方需预加载用户和设备组
+func oidcUserInfo(authorization *entity.OIDCAuthorization) *OIDCUserInfo {
+	return &OIDCUserInfo{
+		Subject:           strconv.FormatUint(uint64(authorization.UserID), 10),
+		PreferredUsername: authorization.User.Username,
+		DeviceGroupID:     authorization.DeviceGroup.ID,
+		DeviceGroup:       authorization.DeviceGroup.Name,
+		Action:            authorization.Action,
+		AuthTime:          authorization.ApprovedAt.Unix(),
+	}
+}
+
+// authenticateOIDCClient 校验客户端凭据，client_secret 为关联API密钥的值
+func authenticateOIDCClient(clientID, clientSecret string) (*entity.OIDCClient, error) {
+	client, err := findActiveOIDCClient(clientID)
+	if err != nil {
+		if errors.Is(err, errs.ErrOIDCClientNotFound) {
+			return nil, newOIDCError(OIDCErrInvalidClient, "客户端认证失败")
+		}
+		return nil, err
+	}
+	if clientSecret == "" || subtle.ConstantTimeCompare([]byte(client.APIKey.APIKey), []byte(clientSecret)) != 1 {
+		return nil, newOIDCError(OIDCErrInvalidClient, "客户端认证失败")
+	}
+	return client, nil
+}
+
+// findActiveOIDCClient 查找启用的客户端，关联的API密钥失效时视为客户端不存在
+func findActiveOIDCClient(clientID string) (*entity.OIDCClient, error) {
+	if clientID == "" {
+		return nil, errs.ErrOIDCClientNotFound
+	}
+
+	var client entity.OIDCClient
+	if err := global.DB.Preload("APIKey").Where("client_id = ? AND is_active = ?", clientID, true).
+		First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrOIDCClientNotFound
+		}
+		return nil, fmt.Errorf("查询OIDC客户端失败: %w", err)
+	}
+
+	key := client.APIKey
+	if key == nil || !key.IsActive || (key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now())) {
+		return nil, errs.ErrOIDCClientNotFound
+	}
+	return &client, nil
+}
+
+// purgeOIDCAuthorizations 清理已过期的授权
+func purgeOIDCAuthorizations() {
+	if err := global.DB.Where("expires_at < ?", time.Now()).Delete(&entity.OIDCAuthorization{}).Error; err != nil {
+		logger.Logger.Error("清理过期OIDC授权失败", "error", err)
+	}
+}
+
+// CreateOIDCClient 注册OIDC客户端
+func CreateOIDCClient(req *request.CreateOIDCClientRequest) (*entity.OIDCClient, error) {
+	if req.Name == "" {
+		return nil, errs.ErrMissingName
+	}
+	if err := validateOIDCRedirectURIs(req.RedirectURIs); err != nil {
+		return nil, err
+	}
+
+	var key entity.APIKey
+	if err := global.DB.Where("id = ?", req.APIKeyID).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrOIDCClientAPIKeyInvalid
+		}
+		return nil, fmt.Errorf("查询API密钥失败: %w", err)
+	}
+	// 管理员密钥不能作为客户端密钥，避免Web应用泄露密钥后获得管理权限
+	if key.IsAdmin {
+		return nil, errs.ErrOIDCClientAPIKeyInvalid
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("生成client_id失败: %w", err)
+	}
+
+	client := entity.OIDCClient{
+		Name:         req.Name,
+		ClientID:     hex.EncodeToString(b),
+		APIKeyID:     key.ID,
+		RedirectURIs: req.RedirectURIs,
+		Action:       req.Action,
+		IsActive:     true,
+	}
+	if err := global.DB.Create(&client).Error; err != nil {
+		return nil, fmt.Errorf("创建OIDC客户端失败: %w", err)
+	}
+
+	return &client, nil
+}
+
+// GetOIDCClients 获取OIDC客户端列表
+func GetOIDCClients() ([]entity.OIDCClient, error) {
+	var clients []entity.OIDCClient
+	if err := global.DB.Order("id").Find(&clients).Error; err != nil {
+		return nil, fmt.Errorf("查询OIDC客户端失败: %w", err)
+	}
+	return clients, nil
+}
+
+// UpdateOIDCClient 更新OIDC客户端，停用后等待中的授权和已签发的令牌随之失效
+func UpdateOIDCClient(id uint, req *request.UpdateOIDCClientRequest) (*entity.OIDCClient, error) {
+	var client entity.OIDCClient
+	if err := global.DB.Where("id = ?", id).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrOIDCClientNotFound
+		}
+		return nil, fmt.Errorf("查询OIDC客户端失败: %w", err)
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.RedirectURIs != nil {
+		if err := validateOIDCRedirectURIs(req.RedirectURIs); err != nil {
+			return nil, err
+		}
+		// map更新不经过序列化器，手动赋值后整体保存
+		client.RedirectURIs = req.RedirectURIs
+	}
+	if req.Action != nil {
+		updates["action"] = *req.Action
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if req.RedirectURIs != nil {
+			if err := tx.Model(&client).Select("redirect_uris").Updates(&client).Error; err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&client).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.IsActive != nil && !*req.IsActive {
+			return tx.Where("oidc_client_id = ?", client.ID).Delete(&entity.OIDCAuthorization{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("更新OIDC客户端失败: %w", err)
+	}
+
+	if err := global.DB.Where("id = ?", id).First(&client).Error; err != nil {
+		return nil, fmt.Errorf("查询OIDC客户端失败: %w", err)
+	}
+	return &client, nil
+}
+
+// DeleteOIDCClient 删除OIDC客户端及其授权
+func DeleteOIDCClient(id uint) error {
+	var client entity.OIDCClient
+	if err := global.DB.Where("id = ?", id).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrOIDCClientNotFound
+		}
+		return fmt.Errorf("查询OIDC客户端失败: %w", err)
+	}
+
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteOIDCClients(tx, "id = ?", client.ID)
+	})
+	if err != nil {
+		return fmt.Errorf("删除OIDC客户端失败: %w", err)
+	}
+	return nil
+}
+
+// deleteOIDCClients 删除符合条件的客户端，先删除其授权以满足外键约束
+func deleteOIDCClients(tx *gorm.DB, query interface{}, args ...interface{}) error {
+	var ids []uint
+	if err := tx.Model(&entity.OIDCClient{}).Where(query, args...).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("oidc_client_id IN ?", ids).Delete(&entity.OIDCAuthorization{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&entity.OIDCClient{}).Error
+}
+
+// validateOIDCRedirectURIs 校验回调地址：必须为不含片段的绝对地址，本机地址以外必须使用 https
+func validateOIDCRedirectURIs(uris []string) error {
+	if len(uris) == 0 {
+		return errs.ErrOIDCRedirectURIInvalid
+	}
+	for _, raw := range uris {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || strings.Contains(raw, "#") {
+			return errs.ErrOIDCRedirectURIInvalid
+		}
+		switch u.Scheme {
+		case "https":
+		case "http":
+			host := u.Hostname()
+			if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+				return errs.ErrOIDCRedirectURIInvalid
+			}
+		default:
+			return errs.ErrOIDCRedirectURIInvalid
+		}
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/identity"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+// IsReplayedOnceKey 判断设备出示的是否为已被轮换掉的旧OnceKey
+// 新密钥已下发但设备尚未确认保存时，设备仍持有旧密钥属于正常情况，不视为重放
+func IsReplayedOnceKey(group *entity.DeviceGroup, onceKey string) bool {
+	if group == nil || group.OnceKeyPending {
+		return false
+	}
+	return global.Keyring.MatchHash(group.LastUsedOnceKeyHash, onceKey)
+}
+
+// MatchConnectionOnceKey 判断设备连接时出示的OnceKey是否属于设备组
+// 新密钥已下发但设备尚未确认保存时，设备可能仍持有上次使用的密钥
+func MatchConnectionOnceKey(group *entity.DeviceGroup, onceKey string) bool {
+	if group == nil || onceKey == "" {
+		return false
+	}
+	if global.Keyring.MatchHash(group.OnceKeyHash, onceKey) {
+		return true
+	}
+	return group.OnceKeyPending && global.Keyring.MatchHash(group.LastUsedOnceKeyHash, onceKey)
+}
+
+// quarantineOnReplayedOnceKey 设备出示旧OnceKey时隔离其设备组
+func quarantineOnReplayedOnceKey(groupID, deviceID uint, serialNumber, onceKey string) error {
+	var group entity.DeviceGroup
+	if err := global.DB.Where("id = ?", groupID).First(&group).Error; err != nil {
+		return fmt.Errorf("查询设备组失败: %w", err)
+	}
+	if group.Quarantined || !IsReplayedOnceKey(&group, onceKey) {
+		return nil
+	}
+	return QuarantineDeviceGroup(group.ID, consts.SecurityEventOnceKeyReplay, deviceID, serialNumber, "认证时出示了已轮换的OnceKey")
+}
+
+// confirmOnceKeyUpdate 设备确认保存新的OnceKey后，旧密钥再出现即视为克隆
+func confirmOnceKeyUpdate(deviceID uint) error {
+	var device entity.Device
+	if err := global.DB.Select("id", "device_group_id").Where("id = ?", deviceID).First(&device).Error; err != nil {
+		return fmt.Errorf("查询设备失败: %w", err)
+	}
+	if device.DeviceGroupID == nil {
+		return nil
+	}
+
+	if err := global.DB.Model(&entity.DeviceGroup{}).Where("id = ?", *device.DeviceGroupID).
+		Update("once_key_pending", false).Error; err != nil {
+		return fmt.Errorf("更新设备组OnceKey状态失败: %w", err)
+	}
+	return nil
+}
+
+// FindReplayedDeviceGroup 通过上次使用的OnceKey和TOTP码查找被重放密钥的设备组，未找到时返回 nil
+// 用于识别携带旧密钥、但序列号未登记的克隆U盘
+func FindReplayedDeviceGroup(totpCode, onceKey string) (*entity.DeviceGroup, error) {
+	if onceKey == "" {
+		return nil, nil
+	}
+
+	var group entity.DeviceGroup
+	result := global.DB.Where("last_used_once_key_hash IN ? AND once_key_pending = ?", global.Keyring.Hashes(onceKey), false).First(&group)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询设备组失败: %w", result.Error)
+	}
+
+	secrets, err := OpenDeviceGroupSecrets(&group)
+	if err != nil {
+		return nil, err
+	}
+
+	// TOTP同样来自设备组密钥，校验通过才能确认是该设备组的密钥副本
+	totpConfig, err := identity.ParseTOTPURI(secrets.TOTPSecret)
+	if err != nil {
+		return nil, fmt.Errorf("解析TOTP密钥失败: %w", err)
+	}
+	valid, err := identity.VerifyTOTPCode(totpConfig, totpCode, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("验证TOTP码失败: %w", err)
+	}
+	if !valid {
+		return nil, nil
+	}
+
+	return &group, nil
+}
+
+// FindConcurrentGroupDevice 查找设备组中以不同序列号同时在线的其他设备，未找到时返回 nil
+// 同一设备组的密钥同一时间只应出现在一个U盘上，两个不同的U盘同时在线说明密钥已被复制
+func FindConcurrentGroupDevice(groupID, deviceID uint, serialNumber string) (*entity.Device, error) {
+	hub := GetWSHub()
+	if hub == nil {
+		return nil, nil
+	}
+
+	var devices []entity.Device
+	if err := global.DB.Select("id", "serial_number").
+		Where("device_group_id = ? AND id <> ? AND serial_number <> ?", groupID, deviceID, serialNumber).
+		Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("查询设备组关联设备失败: %w", err)
+	}
+
+	for i := range devices {
+		if hub.IsDeviceOnline(devices[i].ID) {
+			return &devices[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// RecordSecurityEvent 记录安全事件，tx 为空时使用全局数据库连接
+func RecordSecurityEvent(tx *gorm.DB, event *entity.SecurityEvent) error {
+	if tx == nil {
+		tx = global.DB
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return fmt.Errorf("记录安全事件失败: %w", err)
+	}
+	return nil
+}
+
+// QuarantineDeviceGroup 因疑似克隆隔离设备组
+// 隔离后设备组不能再认证，组内所有设备被强制下线，未完成的认证会话直接失败，需管理员重置密钥后恢复
+func QuarantineDeviceGroup(groupID uint, eventType string, deviceID uint, serialNumber, detail string) error {
+	var group entity.DeviceGroup
+	if err := global.DB.Where("id = ?", groupID).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrDeviceGroupNotFound
+		}
+		return fmt.Errorf("查询设备组失败: %w", err)
+	}
+
+	var deviceIDs []uint
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&group).Updates(map[string]interface{}{
+			"quarantined":       true,
+			"quarantined_at":    &now,
+			"quarantine_reason": detail,
+		}).Error; err != nil {
+			return fmt.Errorf("隔离设备组失败: %w", err)
+		}
+
+		if err := tx.Model(&entity.Device{}).Where("device_group_id = ?", group.ID).
+			Pluck("id", &deviceIDs).Error; err != nil {
+			return fmt.Errorf("查询设备组关联设备失败: %w", err)
+		}
+
+		event := &entity.SecurityEvent{
+			Type:          eventType,
+			DeviceGroupID: &group.ID,
+			SerialNumber:  serialNumber,
+			Detail:        detail,
+		}
+		if deviceID != 0 {
+			event.DeviceID = &deviceID
+		}
+		return RecordSecurityEvent(tx, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	if group.UserID != nil && len(deviceIDs) > 0 {
+		if _, err := FailAuthSessionsForDevices(*group.UserID, deviceIDs...); err != nil {
+			logger.Logger.Error("隔离设备组时终止认证会话失败", "device_group_id", group.ID, "error", err)
+		}
+	}
+
+	if hub := GetWSHub(); hub != nil {
+		for _, id := range deviceIDs {
+			hub.OnDeviceDisconnect(id)
+		}
+	}
+
+	logger.Logger.Warn("设备组疑似被克隆，已隔离",
+		"device_group_id", group.ID,
+		"event_type", eventType,
+		"device_id", deviceID,
+		"serial_number", serialNumber,
+		"detail", detail,
+		"devices", deviceIDs)
+
+	Notify(consts.NotifyCloneDetected,
+		fmt.Sprintf("设备组 %s 疑似被克隆，已隔离", group.Name),
+		fmt.Sprintf("%s。设备组 %d 的全部设备已下线，需在管理后台重置密钥后恢复。", detail, group.ID))
+
+	return nil
+}
+
+// RekeyDeviceGroup 重置被隔离设备组的全部认证密钥并解除隔离
+// 组内设备全部吊销，用户关联和权限保持不变；返回新的恢复码，设备持有人需用恢复码重新初始化U盘
+func RekeyDeviceGroup(groupID uint) ([]string, error) {
+	secrets, err := newDeviceGroupSecrets(fmt.Sprintf("group%d_%s", groupID, uuid.New().String()[:6]))
+	if err != nil {
+		return nil, err
+	}
+
+	var revokedDeviceIDs []uint
+	var recoveryCodes []string
+
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		var group entity.DeviceGroup
+		if err := tx.Where("id = ?", groupID).First(&group).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrDeviceGroupNotFound
+			}
+			return fmt.Errorf("查询设备组失败: %w", err)
+		}
+
+		updates, err := secrets.columns(group.ID)
+		if err != nil {
+			return err
+		}
+		updates["once_key_pending"] = false
+		updates["auth_secret_pending"] = true
+		updates["quarantined"] = false
+		updates["quarantined_at"] = nil
+		updates["quarantine_reason"] = ""
+		if err := tx.Model(&group).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新设备组密钥失败: %w", err)
+		}
+
+		// 无法区分原U盘和副本，组内设备全部吊销
+		if err := tx.Model(&entity.Device{}).Where("device_group_id = ?", group.ID).
+			Pluck("id", &revokedDeviceIDs).Error; err != nil {
+			return fmt.Errorf("查询设备组关联设备失败: %w", err)
+		}
+		if len(revokedDeviceIDs) > 0 {
+			if err := tx.Where("id IN ?", revokedDeviceIDs).Delete(&entity.Device{}).Error; err != nil {
+				return fmt.Errorf("吊销设备失败: %w", err)
+			}
+		}
+
+		codes, err := GenerateRecoveryCodes(tx, group.ID)
+		if err != nil {
+			return err
+		}
+		recoveryCodes = codes
+
+		return RecordSecurityEvent(tx, &entity.SecurityEvent{
+			Type:          consts.SecurityEventGroupRekeyed,
+			DeviceGroupID: &group.ID,
+			Detail:        fmt.Sprintf("管理员重置设备组密钥，吊销设备 %d 个", len(revokedDeviceIDs)),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if hub := GetWSHub(); hub != nil {
+		for _, deviceID := range revokedDeviceIDs {
+			hub.OnDeviceDisconnect(deviceID)
+		}
+	}
+	for _, deviceID := range revokedDeviceIDs {
+		PublishDeviceEvent(consts.EventDeviceRevoked, deviceID, 0)
+	}
+
+	logger.Logger.Warn("设备组密钥已重置",
+		"device_group_id", groupID,
+		"revoked_devices", revokedDeviceIDs)
+
+	return recoveryCodes, nil
+}
+
+// GetSecurityEvents 获取安全事件列表
+func GetSecurityEvents(page, pageSize int, filter *request.SecurityEventFilter) ([]entity.SecurityEvent, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	offset := (page - 1) * pageSize
+	query := global.DB.Model(&entity.SecurityEvent{})
+
+	if filter != nil {
+		if filter.Type != "" {
+			query = query.Where("type = ?", filter.Type)
+		}
+		if filter.DeviceGroupID != nil {
+			query = query.Where("device_group_id = ?", *filter.DeviceGroupID)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取安全事件总数失败: %w", err)
+	}
+
+	var events []entity.SecurityEvent
+	if err := query.Offset(offset).Limit(pageSize).
+		Order("created_at DESC").
+		Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取安全事件列表失败: %w", err)
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+)
+
+// loadDeviceGroup 重新读取设备组记录
+func loadDeviceGroup(t *testing.T, groupID uint) *entity.DeviceGroup {
+	t.Helper()
+	var group entity.DeviceGroup
+	if err := global.DB.First(&group, groupID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &group
+}
+
+// securityEventTypes 返回设备组已记录的安全事件类型
+func securityEventTypes(t *testing.T, groupID uint) []string {
+	t.Helper()
+	var types []string
+	if err := global.DB.Model(&entity.SecurityEvent{}).Where("device_group_id = ?", groupID).
+		Order("id").Pluck("type", &types).Error; err != nil {
+		t.Fatal(err)
+	}
+	return types
+}
+
+// rotateOnceKey 模拟一次认证后的OnceKey轮换，confirm 为 true 时设备确认保存新密钥
+func rotateOnceKey(t *testing.T, group *entity.DeviceGroup, device *entity.Device, oldOnceKey string, confirm bool) string {
+	t.Helper()
+	newOnceKey, _, err := UpdateDeviceGroupOnceKey(group.ID, oldOnceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirm {
+		if err := confirmOnceKeyUpdate(device.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newOnceKey
+}
+
+func TestMatchConnectionOnceKey(t *testing.T) {
+	setupTestDB(t)
+	group, device, secrets := createTestDeviceGroup(t, createTestUser(t, "alice"), "SN1")
+
+	if !MatchConnectionOnceKey(loadDeviceGroup(t, group.ID), secrets.OnceKey) {
+		t.Fatal("当前的OnceKey应匹配")
+	}
+	for _, key := range []string{"", "not-the-key"} {
+		if MatchConnectionOnceKey(loadDeviceGroup(t, group.ID), key) {
+			t.Errorf("OnceKey %q 不应匹配", key)
+		}
+	}
+
+	// 新密钥下发后设备确认保存前，新旧密钥都可以连接
+	newOnceKey := rotateOnceKey(t, group, device, secrets.OnceKey, false)
+	pending := loadDeviceGroup(t, group.ID)
+	if !MatchConnectionOnceKey(pending, newOnceKey) || !MatchConnectionOnceKey(pending, secrets.OnceKey) {
+		t.Fatal("确认保存前新旧OnceKey都应匹配")
+	}
+	if IsReplayedOnceKey(pending, secrets.OnceKey) {
+		t.Fatal("确认保存前出示旧OnceKey不应视为重放")
+	}
+
+	// 确认保存后旧密钥再出现即为重放
+	if err := confirmOnceKeyUpdate(device.ID); err != nil {
+		t.Fatal(err)
+	}
+	confirmed := loadDeviceGroup(t, group.ID)
+	if MatchConnectionOnceKey(confirmed, secrets.OnceKey) {
+		t.Fatal("确认保存后旧OnceKey不应匹配")
+	}
+	if !IsReplayedOnceKey(confirmed, secrets.OnceKey) {
+		t.Fatal("确认保存后出示旧OnceKey应视为重放")
+	}
+}
+
+func TestReplayedOnceKeyQuarantinesGroup(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+	user := createTestUser(t, "alice")
+	group, device, secrets := createTestDeviceGroup(t, user, "SN1")
+	hub.connect(user.ID, device.ID)
+	rotateOnceKey(t, group, device, secrets.OnceKey, true)
+	pending := createTestAuthSession(t, user.ID, consts.AuthStatusPending, 0, nil, "")
+
+	// 出示不属于设备组的密钥不触发隔离
+	if err := quarantineOnReplayedOnceKey(group.ID, device.ID, "SN-COPY", "not-the-key"); err != nil {
+		t.Fatal(err)
+	}
+	if loadDeviceGroup(t, group.ID).Quarantined {
+		t.Fatal("出示未知OnceKey不应触发隔离")
+	}
+
+	if err := quarantineOnReplayedOnceKey(group.ID, device.ID, "SN-COPY", secrets.OnceKey); err != nil {
+		t.Fatal(err)
+	}
+
+	quarantined := loadDeviceGroup(t, group.ID)
+	if !quarantined.Quarantined || quarantined.QuarantinedAt == nil || quarantined.QuarantineReason == "" {
+		t.Fatalf("设备组应被隔离: %+v", quarantined)
+	}
+	if got := securityEventTypes(t, group.ID); !slices.Equal(got, []string{consts.SecurityEventOnceKeyReplay}) {
+		t.Errorf("安全事件 = %v，应记录一次OnceKey重放", got)
+	}
+	if !slices.Contains(hub.disconnected, device.ID) {
+		t.Error("设备组内的设备应被强制下线")
+	}
+	if got := sessionStatus(t, pending.ID); got != consts.AuthStatusFailed {
+		t.Errorf("待处理认证会话状态 = %s，应为 failed", got)
+	}
+
+	// 已隔离的设备组不重复隔离
+	if err := quarantineOnReplayedOnceKey(group.ID, device.ID, "SN-COPY", secrets.OnceKey); err != nil {
+		t.Fatal(err)
+	}
+	if got := securityEventTypes(t, group.ID); len(got) != 1 {
+		t.Errorf("安全事件 = %v，已隔离的设备组不应重复记录", got)
+	}
+}
+
+func TestFindConcurrentGroupDevice(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+	user := createTestUser(t, "alice")
+	group, device, _ := createTestDeviceGroup(t, user, "SN1")
+	copied := &entity.Device{Name: "copy", DeviceGroupID: &group.ID, SerialNumber: "SN2", VolumeSerialNumber: "vol-SN2", IsActive: true}
+	if err := global.DB.Create(copied).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := FindConcurrentGroupDevice(group.ID, copied.ID, copied.SerialNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != nil {
+		t.Fatal("组内没有其他在线设备时不应视为并发连接")
+	}
+
+	hub.connect(user.ID, device.ID)
+	other, err = FindConcurrentGroupDevice(group.ID, copied.ID, copied.SerialNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == nil || other.ID != device.ID {
+		t.Fatalf("应发现以序列号 SN1 在线的设备，实际 %+v", other)
+	}
+
+	// 同一U盘重新连接不视为克隆
+	other, err = FindConcurrentGroupDevice(group.ID, 0, device.SerialNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != nil {
+		t.Fatal("相同序列号的设备重新连接不应视为并发连接")
+	}
+}
+
+func TestRekeyDeviceGroupLiftsQuarantine(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+	user := createTestUser(t, "alice")
+	group, device, secrets := createTestDeviceGroup(t, user, "SN1", "login")
+	if err := QuarantineDeviceGroup(group.ID, consts.SecurityEventConcurrentSerials, device.ID, "SN2", "测试"); err != nil {
+		t.Fatal(err)
+	}
+
+	codes, err := RekeyDeviceGroup(group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) == 0 {
+		t.Fatal("重置密钥应返回新的恢复码")
+	}
+
+	rekeyed := loadDeviceGroup(t, group.ID)
+	if rekeyed.Quarantined || rekeyed.QuarantinedAt != nil || rekeyed.QuarantineReason != "" {
+		t.Fatalf("重置密钥后应解除隔离: %+v", rekeyed)
+	}
+	if rekeyed.UserID == nil || *rekeyed.UserID != user.ID || !slices.Equal(rekeyed.Permissions, []string{"login"}) {
+		t.Error("重置密钥不应改变用户关联和权限")
+	}
+	if MatchConnectionOnceKey(rekeyed, secrets.OnceKey) || IsReplayedOnceKey(rekeyed, secrets.OnceKey) {
+		t.Error("重置密钥后旧OnceKey既不应匹配，也不应再触发隔离")
+	}
+
+	var remaining int64
+	if err := global.DB.Model(&entity.Device{}).Where("device_group_id = ?", group.ID).Count(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Errorf("组内剩余设备 %d 个，应全部吊销", remaining)
+	}
+	if !slices.Contains(hub.disconnected, device.ID) {
+		t.Error("吊销的设备应被强制下线")
+	}
+	want := []string{consts.SecurityEventConcurrentSerials, consts.SecurityEventGroupRekeyed}
+	if got := securityEventTypes(t, group.ID); !slices.Equal(got, want) {
+		t.Errorf("安全事件 = %v，应为 %v", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+)
+
+// radiusPollInterval 等待U盘批准时查询认证会话的间隔
+const radiusPollInterval = 500 * time.Millisecond
+
+// RADIUSAuthRequest RADIUS认证请求
+type RADIUSAuthRequest struct {
+	Username  string        // User-Name
+	Action    string        // 认证请求的操作
+	NASName   string        // 配置中的NAS客户端名称
+	NASIP     string        // NAS的来源IP，记录为认证会话的客户端IP
+	CallingID string        // Calling-Station-Id，通常是VPN用户的地址，展示在U盘确认信息中
+	Timeout   time.Duration // 等待U盘批准的时间
+}
+
+// AuthenticateRADIUS 向用户的U盘推送认证请求并等待结果，批准时返回 nil
+// ctx 取消或等待超时时返回 errs.ErrSessionExpired，用户不存在或无法推送时同样等待超时，响应时间不泄露用户是否存在
+func AuthenticateRADIUS(ctx context.Context, req *RADIUSAuthRequest) error {
+	challenge := make([]byte, 16)
+	if _, err := rand.Read(challenge); err != nil {
+		return fmt.Errorf("生成挑战码失败: %w", err)
+	}
+
+	message := fmt.Sprintf("登录 %s", req.NASName)
+	if req.CallingID != "" {
+		message = fmt.Sprintf("登录 %s（来源: %s）", req.NASName, req.CallingID)
+	}
+
+	authSession, err := StartAuth(&request.AuthRequest{
+		Username:  req.Username,
+		Challenge: hex.EncodeToString(challenge),
+		Action:    req.Action,
+		Message:   message,
+		Timeout:   int(req.Timeout / time.Second),
+	}, nil, req.NASIP)
+	if err != nil {
+		if errors.Is(err, errs.ErrUserNotFound) || errors.Is(err, errs.ErrUserNotOnline) || errors.Is(err, errs.ErrPermissionDenied) {
+			logger.Logger.Warn("RADIUS认证失败：无法推送认证请求", "nas", req.NASName, "username", req.Username, "error", err)
+			timer := time.NewTimer(req.Timeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			return errs.ErrSessionExpired
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, authSession.ExpiresAt)
+	defer cancel()
+	ticker := time.NewTicker(radiusPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			expireRADIUSSession(authSession.ID)
+			return errs.ErrSessionExpired
+		case <-ticker.C:
+		}
+
+		var session entity.AuthSession
+		if err := global.DB.Preload("RespondingDevice.DeviceGroup").
+			Where("id = ?", authSession.ID).First(&session).Error; err != nil {
+			return fmt.Errorf("查询认证会话失败: %w", err)
+		}
+
+		switch session.Status {
+		case consts.AuthStatusCompleted:
+			// 确认响应的U盘属于发起认证的用户
+			device := session.RespondingDevice
+			if session.Result != consts.AuthResultSuccess || device == nil || device.DeviceGroup == nil ||
+				device.DeviceGroup.UserID == nil || *device.DeviceGroup.UserID != session.UserID {
+				return errs.ErrRADIUSAuthFailed
+			}
+			return nil
+		case consts.AuthStatusRejected:
+			return errs.ErrUserRejected
+		case consts.AuthStatusFailed:
+			return errs.ErrRADIUSAuthFailed
+		case consts.AuthStatusExpired:
+			return errs.ErrSessionExpired
+		}
+	}
+}
+
+// expireRADIUSSession 将停止等待的认证会话标记为过期，NAS 已拒绝的登录不能再被U盘批准
+func expireRADIUSSession(sessionID string) {
+	if err := global.DB.Model(&entity.AuthSession{}).
+		Where("id = ? AND status = ?", sessionID, consts.AuthStatusPending).
+		Update("status", consts.AuthStatusExpired).Error; err != nil {
+		logger.Logger.Error("标记RADIUS认证会话过期失败", "session_id", sessionID, "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// createOnlineRADIUSUser 创建U盘在线的用户，设备组具备 permissions 权限
+func createOnlineRADIUSUser(t *testing.T, hub *fakeHub, username string, permissions ...string) *entity.User {
+	t.Helper()
+	user := createTestUser(t, username)
+	_, device, _ := createTestDeviceGroup(t, user, "SN-"+username, permissions...)
+	if err := global.DB.Model(device).Update("is_online", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	hub.connect(user.ID, device.ID)
+	return user
+}
+
+// radiusRequest 返回等待 timeout 的RADIUS认证请求
+func radiusRequest(username string, timeout time.Duration) *RADIUSAuthRequest {
+	return &RADIUSAuthRequest{
+		Username: username,
+		Action:   consts.AuthActionRADIUSLogin,
+		NASName:  "vpn",
+		NASIP:    "192.0.2.1",
+		Timeout:  timeout,
+	}
+}
+
+func TestAuthenticateRADIUSDelaysRejectWhenPushFails(t *testing.T) {
+	setupTestDB(t)
+	hub := setupFakeHub(t)
+	createTestUser(t, "offline")
+	createOnlineRADIUSUser(t, hub, "no-permission", consts.AuthActionAdminLogin)
+
+	const timeout = 300 * time.Millisecond
+	for _, username := range []string{"unknown", "offline", "no-permission"} {
+		t.Run(username, func(t *testing.T) {
+			start := time.Now()
+			err := AuthenticateRADIUS(context.Background(), radiusRequest(username, timeout))
+			if !errors.Is(err, errs.ErrSessionExpired) {
+				t.Fatalf("返回 %v，应与等待超时一样返回 ErrSessionExpired", err)
+			}
+			if elapsed := time.Since(start); elapsed < timeout {
+				t.Errorf("%v 后即拒绝，应等待配置的超时时间", elapsed)
+			}
+		})
+	}
+
+	// NAS 放弃等待时立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := AuthenticateRADIUS(ctx, radiusRequest("unknown", time.Minute)); !errors.Is(err, errs.ErrSessionExpired) {
+		t.Fatalf("返回 %v，应为 ErrSessionExpired", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ctx 取消后 %v 才返回", elapsed)
+	}
+}
+
+func TestAuthenticateRADIUSExpiresSessionWhenWaitEnds(t *testing.T) {
+	cases := map[string]func() (context.Context, context.CancelFunc, time.Duration){
+		"等待超时": func() (context.Context, context.CancelFunc, time.Duration) {
+			ctx, cancel := context.WithCancel(context.Background())
+			return ctx, cancel, time.Second
+		},
+		"ctx取消": func() (context.Context, context.CancelFunc, time.Duration) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			return ctx, cancel, time.Minute
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestDB(t)
+			hub := setupFakeHub(t)
+			user := createOnlineRADIUSUser(t, hub, "alice", consts.AuthActionRADIUSLogin)
+
+			ctx, cancel, timeout := setup()
+			defer cancel()
+			if err := AuthenticateRADIUS(ctx, radiusRequest("alice", timeout)); !errors.Is(err, errs.ErrSessionExpired) {
+				t.Fatalf("返回 %v，应为 ErrSessionExpired", err)
+			}
+
+			var session entity.AuthSession
+			if err := global.DB.Where("user_id = ?", user.ID).First(&session).Error; err != nil {
+				t.Fatal(err)
+			}
+			if session.Status != consts.AuthStatusExpired {
+				t.Errorf("认证会话状态 = %s，NAS 已拒绝的登录应标记为过期", session.Status)
+			}
+		})
+	}
+}
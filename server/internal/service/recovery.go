@@ -0,0 +1,254 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// RecoveryCodeCount 每个设备组生成的恢复码数量
+const RecoveryCodeCount = 8
+
+const (
+	// recoveryAttemptsPerIP 每个来源IP在窗口内允许的恢复尝试次数
+	recoveryAttemptsPerIP = 5
+	// recoveryAttemptsPerSubnet 每个来源网段在窗口内允许的恢复尝试次数
+	recoveryAttemptsPerSubnet = 20
+	// recoveryAttemptsTotal 全部来源在窗口内允许的恢复尝试次数
+	recoveryAttemptsTotal = 100
+	// recoveryAttemptWindow 恢复尝试的计数窗口
+	recoveryAttemptWindow = 15 * time.Minute
+)
+
+// recoveryLimiter 限制恢复码猜测
+// 序列号由客户端上报，可以随意更换，因此只按服务端确定的来源IP、网段和全局次数计数
+var recoveryLimiter = newAttemptLimiter(recoveryAttemptWindow)
+
+// GenerateRecoveryCodes 为设备组重新生成恢复码，旧的未使用恢复码全部作废
+func GenerateRecoveryCodes(tx *gorm.DB, groupID uint) ([]string, error) {
+	if err := tx.Where("device_group_id = ? AND used_at IS NULL", groupID).
+		Delete(&entity.RecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("作废旧恢复码失败: %w", err)
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]entity.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+		codes = append(codes, code)
+		records = append(records, entity.RecoveryCode{
+			DeviceGroupID: groupID,
+			CodeHash:      hashRecoveryCode(code),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("保存恢复码失败: %w", err)
+	}
+
+	return codes, nil
+}
+
+// RecoverDevice 使用恢复码将新设备接管到原设备组
+// 原设备组的用户关联和权限保持不变，认证密钥全部轮换，原设备组下的旧设备被吊销
+// 同一来源IP、网段和全部来源的尝试次数受限，超出时返回 errs.ErrTooManyAttempts
+func RecoverDevice(initReq *messages.DeviceInitRequestMessage, clientIP string) (*DeviceGroupSecrets, error) {
+	if initReq.SerialNumber == "" {
+		return nil, errs.ErrMissingDeviceInfo
+	}
+	if !recoveryLimiter.Allow(
+		attemptKey{key: "ip:" + clientIP, max: recoveryAttemptsPerIP},
+		attemptKey{key: "subnet:" + clientSubnet(clientIP), max: recoveryAttemptsPerSubnet},
+		attemptKey{key: "total", max: recoveryAttemptsTotal},
+	) {
+		logger.Logger.Warn("恢复尝试次数过多", "serial_number", initReq.SerialNumber, "client_ip", clientIP)
+		return nil, errs.ErrTooManyAttempts
+	}
+
+	// 新设备不能是已登记的设备
+	var existingCount int64
+	if err := global.DB.Model(&entity.Device{}).
+		Where("serial_number = ? AND volume_serial_number = ?", initReq.SerialNumber, initReq.VolumeSerialNumber).
+		Count(&existingCount).Error; err != nil {
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	if existingCount > 0 {
+		return nil, errs.ErrDeviceAlreadyExists
+	}
+
+	secrets, err := newDeviceGroupSecrets(fmt.Sprintf("%s_%s", initReq.SerialNumber, uuid.New().String()[:6]))
+	if err != nil {
+		return nil, err
+	}
+
+	var revokedDeviceIDs []uint
+	var group entity.DeviceGroup
+	var newDevice entity.Device
+
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定恢复码，防止并发重复使用
+		var recoveryCode entity.RecoveryCode
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash IN ? AND used_at IS NULL", recoveryCodeHashes(initReq.RecoveryCode)).
+			First(&recoveryCode).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrRecoveryCodeInvalid
+			}
+			return fmt.Errorf("查询恢复码失败: %w", err)
+		}
+
+		if err := tx.Where("id = ?", recoveryCode.DeviceGroupID).First(&group).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrDeviceGroupNotFound
+			}
+			return fmt.Errorf("查询设备组失败: %w", err)
+		}
+
+		// 轮换设备组认证密钥，旧U盘上的密钥随之失效
+		updates, err := secrets.columns(group.ID)
+		if err != nil {
+			return err
+		}
+		updates["auth_secret_pending"] = true
+		if err := tx.Model(&group).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新设备组密钥失败: %w", err)
+		}
+
+		// 吊销设备组下的旧设备
+		if err := tx.Model(&entity.Device{}).Where("device_group_id = ?", group.ID).
+			Pluck("id", &revokedDeviceIDs).Error; err != nil {
+			return fmt.Errorf("查询设备组关联设备失败: %w", err)
+		}
+		if len(revokedDeviceIDs) > 0 {
+			if err := tx.Where("id IN ?", revokedDeviceIDs).Delete(&entity.Device{}).Error; err != nil {
+				return fmt.Errorf("吊销旧设备失败: %w", err)
+			}
+		}
+
+		// 创建新设备并直接沿用设备组的激活状态
+		randomSuffix, err := GenerateRandomSuffix()
+		if err != nil {
+			return fmt.Errorf("生成随机后缀失败: %w", err)
+		}
+		newDevice = entity.Device{
+			Name:               fmt.Sprintf("设备_%s_%s", SerialSuffix(initReq.SerialNumber, 6), randomSuffix),
+			DeviceGroupID:      &group.ID,
+			SerialNumber:       initReq.SerialNumber,
+			VolumeSerialNumber: initReq.VolumeSerialNumber,
+			Vendor:             initReq.Vendor,
+			Model:              initReq.Model,
+			Remark:             "通过恢复码接管设备组",
+			IsActive:           group.IsActive,
+			IsOnline:           false,
+			HeartbeatInterval:  30,
+			Fingerprint:        initReq.Fingerprint,
+		}
+
+		// 被吊销的U盘重新初始化时（如设备组重置密钥后），恢复原设备记录，避免与已删除记录的唯一索引冲突
+		var revoked entity.Device
+		err = tx.Unscoped().Where("serial_number = ? AND volume_serial_number = ? AND deleted_at IS NOT NULL",
+			initReq.SerialNumber, initReq.VolumeSerialNumber).First(&revoked).Error
+		switch {
+		case err == nil:
+			newDevice.ID = revoked.ID
+			if err := tx.Unscoped().Model(&revoked).Select("name", "device_group_id", "vendor", "model", "remark",
+				"is_active", "is_online", "heartbeat_interval", "fingerprint", "pending_fingerprint", "pending_volume_serial_number", "fingerprint_score", "deleted_at").
+				Updates(&newDevice).Error; err != nil {
+				return fmt.Errorf("恢复设备记录失败: %w", err)
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(&newDevice).Error; err != nil {
+				return fmt.Errorf("创建设备记录失败: %w", err)
+			}
+		default:
+			return fmt.Errorf("查询已吊销设备失败: %w", err)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&recoveryCode).Updates(map[string]interface{}{
+			"used_at":           &now,
+			"used_by_device_id": newDevice.ID,
+		}).Error; err != nil {
+			return fmt.Errorf("标记恢复码失败: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 强制断开已吊销设备的连接
+	if hub := GetWSHub(); hub != nil {
+		for _, deviceID := range revokedDeviceIDs {
+			hub.OnDeviceDisconnect(deviceID)
+		}
+	}
+
+	logger.Logger.Warn("设备组已通过恢复码被新设备接管",
+		"device_group_id", group.ID,
+		"new_device_id", newDevice.ID,
+		"serial_number", initReq.SerialNumber,
+		"revoked_devices", revokedDeviceIDs)
+
+	return secrets, nil
+}
+
+// generateRecoveryCode 生成形如 XXXX-XXXX-XXXX-XXXX 的恢复码
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes)
+
+	parts := make([]string, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		parts = append(parts, raw[i:i+4])
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// hashRecoveryCode 使用 security.recovery_code_key 计算恢复码的HMAC摘要
+// 恢复码只在生成时下发，服务端无法重新计算摘要，因此不使用会轮换的加密密钥或密钥环
+func hashRecoveryCode(code string) string {
+	return hmacRecoveryCode(global.Config.Security.RecoveryCodeKey, code)
+}
+
+// recoveryCodeHashes 返回查找恢复码时需匹配的摘要
+// 旧版本使用 security.encryption_key 计算摘要，这些恢复码在重新生成前仍然有效
+func recoveryCodeHashes(code string) []string {
+	return []string{
+		hashRecoveryCode(code),
+		hmacRecoveryCode(global.Config.Security.EncryptionKey, code),
+	}
+}
+
+// hmacRecoveryCode 计算恢复码的HMAC摘要，忽略大小写、空格和分隔符
+func hmacRecoveryCode(key, code string) string {
+	normalized := strings.ToUpper(code)
+	normalized = strings.NewReplacer("-", "", " ", "").Replace(normalized)
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
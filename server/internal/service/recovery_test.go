@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// resetRecoveryLimiter 清空恢复尝试计数，避免测试之间互相影响
+func resetRecoveryLimiter(t *testing.T) {
+	t.Helper()
+	recoveryLimiter = newAttemptLimiter(recoveryAttemptWindow)
+	t.Cleanup(func() { recoveryLimiter = newAttemptLimiter(recoveryAttemptWindow) })
+}
+
+func TestRecoverDeviceTakesOverGroup(t *testing.T) {
+	setupTestDB(t)
+	resetRecoveryLimiter(t)
+
+	user := createTestUser(t, "alice")
+	group, oldDevice, oldSecrets := createTestDeviceGroup(t, user, "OLD-SERIAL-0001", "login")
+	codes, err := GenerateRecoveryCodes(global.DB, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("恢复码数量 = %d", len(codes))
+	}
+
+	// 恢复码忽略大小写和分隔符
+	code := strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))
+	secrets, err := RecoverDevice(&messages.DeviceInitRequestMessage{
+		SerialNumber:       "NEW-SERIAL-0002",
+		VolumeSerialNumber: "vol-new",
+		RecoveryCode:       code,
+	}, "192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧设备被吊销
+	var count int64
+	global.DB.Model(&entity.Device{}).Where("id = ?", oldDevice.ID).Count(&count)
+	if count != 0 {
+		t.Error("旧设备应被吊销")
+	}
+
+	// 新设备加入原设备组并沿用激活状态
+	var newDevice entity.Device
+	if err := global.DB.Where("serial_number = ?", "NEW-SERIAL-0002").First(&newDevice).Error; err != nil {
+		t.Fatal(err)
+	}
+	if newDevice.DeviceGroupID == nil || *newDevice.DeviceGroupID != group.ID || !newDevice.IsActive {
+		t.Errorf("新设备 = %+v", newDevice)
+	}
+
+	// 认证密钥已轮换，用户关联和权限保持不变
+	var updated entity.DeviceGroup
+	if err := global.DB.First(&updated, group.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	stored, err := OpenDeviceGroupSecrets(&updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.OnceKey != secrets.OnceKey || stored.OnceKey == oldSecrets.OnceKey || stored.TOTPSecret == oldSecrets.TOTPSecret {
+		t.Error("设备组认证密钥应轮换为返回的新密钥")
+	}
+	if updated.UserID == nil || *updated.UserID != user.ID || len(updated.Permissions) != 1 || updated.Permissions[0] != "login" {
+		t.Errorf("设备组关联 = %+v", updated)
+	}
+
+	var used entity.RecoveryCode
+	if err := global.DB.Where("code_hash = ?", hashRecoveryCode(codes[0])).First(&used).Error; err != nil {
+		t.Fatal(err)
+	}
+	if used.UsedAt == nil || used.UsedByDeviceID == nil || *used.UsedByDeviceID != newDevice.ID {
+		t.Errorf("恢复码应标记为已被新设备使用: %+v", used)
+	}
+}
+
+func TestRecoverDeviceRejectsReusedCode(t *testing.T) {
+	setupTestDB(t)
+	resetRecoveryLimiter(t)
+
+	user := createTestUser(t, "alice")
+	group, _, _ := createTestDeviceGroup(t, user, "OLD-SERIAL-0001")
+	codes, err := GenerateRecoveryCodes(global.DB, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RecoverDevice(&messages.DeviceInitRequestMessage{
+		SerialNumber: "NEW-SERIAL-0002", VolumeSerialNumber: "vol-2", RecoveryCode: codes[0],
+	}, "192.0.2.10"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = RecoverDevice(&messages.DeviceInitRequestMessage{
+		SerialNumber: "NEW-SERIAL-0003", VolumeSerialNumber: "vol-3", RecoveryCode: codes[0],
+	}, "192.0.2.10")
+	if !errors.Is(err, errs.ErrRecoveryCodeInvalid) {
+		t.Fatalf("重复使用恢复码 err = %v", err)
+	}
+
+	var count int64
+	global.DB.Model(&entity.Device{}).Where("serial_number = ?", "NEW-SERIAL-0003").Count(&count)
+	if count != 0 {
+		t.Error("重复使用恢复码不应创建设备")
+	}
+
+	// 同一设备组的其他恢复码仍然有效
+	if _, err := RecoverDevice(&messages.DeviceInitRequestMessage{
+		SerialNumber: "NEW-SERIAL-0003", VolumeSerialNumber: "vol-3", RecoveryCode: codes[1],
+	}, "192.0.2.10"); err != nil {
+		t.Fatalf("其他恢复码 err = %v", err)
+	}
+}
+
+func TestRecoverDeviceAcceptsLegacyCodeHash(t *testing.T) {
+	setupTestDB(t)
+	resetRecoveryLimiter(t)
+
+	user := createTestUser(t, "alice")
+	group, _, _ := createTestDeviceGroup(t, user, "OLD-SERIAL-0001")
+	code := "ABCD-EFGH-IJKL-MNOP"
+	legacy := entity.RecoveryCode{
+		DeviceGroupID: group.ID,
+		CodeHash:      hmacRecoveryCode(global.Config.Security.EncryptionKey, code),
+	}
+	if err := global.DB.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RecoverDevice(&messages.DeviceInitRequestMessage{
+		SerialNumber: "NEW-SERIAL-0002", VolumeSerialNumber: "vol-2", RecoveryCode: code,
+	}, "192.0.2.10"); err != nil {
+		t.Fatalf("旧版本摘要的恢复码 err = %v", err)
+	}
+}
+
+func TestRecoverDeviceLimitsBySourceNotSerial(t *testing.T) {
+	setupTestDB(t)
+	resetRecoveryLimiter(t)
+
+	// 每次更换序列号也不能绕过来源IP的限制
+	for i := 0; i < recoveryAttemptsPerIP; i++ {
+		_, err := RecoverDevice(&messages.DeviceInitRequestMessage{
+			SerialNumber: "SERIAL-" + string(rune('A'+i)), RecoveryCode: "WRONG-CODE",
+		}, "192.0.2.10")
+		if !errors.Is(err, errs.ErrRecoveryCodeInvalid) {
+			t.Fatalf("第 %d 次尝试 err = %v", i+1, err)
+		}
+	}
+	_, err := RecoverDevice(&messages.DeviceInitRequestMessage{SerialNumber: "SERIAL-Z", RecoveryCode: "WRONG-CODE"}, "192.0.2.10")
+	if !errors.Is(err, errs.ErrTooManyAttempts) {
+		t.Fatalf("超出来源IP上限 err = %v", err)
+	}
+
+	// 同一网段的其他IP共用网段上限，被拒绝的尝试同样计数
+	for attempts := recoveryAttemptsPerIP + 1; attempts < recoveryAttemptsPerSubnet; attempts++ {
+		ip := fmt.Sprintf("192.0.2.%d", 11+attempts/recoveryAttemptsPerIP)
+		RecoverDevice(&messages.DeviceInitRequestMessage{SerialNumber: "SERIAL", RecoveryCode: "WRONG-CODE"}, ip)
+	}
+	_, err = RecoverDevice(&messages.DeviceInitRequestMessage{SerialNumber: "SERIAL", RecoveryCode: "WRONG-CODE"}, "192.0.2.99")
+	if !errors.Is(err, errs.ErrTooManyAttempts) {
+		t.Fatalf("超出网段上限 err = %v", err)
+	}
+	_, err = RecoverDevice(&messages.DeviceInitRequestMessage{SerialNumber: "SERIAL", RecoveryCode: "WRONG-CODE"}, "198.51.100.1")
+	if !errors.Is(err, errs.ErrRecoveryCodeInvalid) {
+		t.Fatalf("其他网段 err = %v", err)
+	}
+}
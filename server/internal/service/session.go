@@ -1,14 +1,63 @@
 package service
 
 import (
+	"database/sql"
 	"fmt"
 
+	"gorm.io/gorm"
+
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
 	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
 )
 
+// authSessionExportBatchSize 导出认证会话时每批查询的数量
+const authSessionExportBatchSize = 500
+
+// authSessionSortField 认证会话的排序字段，value 取出记录中该字段的值用于导出时的键集分页
+type authSessionSortField struct {
+	column string
+	value  func(*entity.AuthSession) interface{}
+}
+
+// authSessionSortFields 允许排序的认证会话字段
+var authSessionSortFields = map[string]authSessionSortField{
+	"created_at": {"auth_sessions.created_at", func(s *entity.AuthSession) interface{} { return s.CreatedAt }},
+	"updated_at": {"auth_sessions.updated_at", func(s *entity.AuthSession) interface{} { return s.UpdatedAt }},
+	"expires_at": {"auth_sessions.expires_at", func(s *entity.AuthSession) interface{} { return s.ExpiresAt }},
+	"status":     {"auth_sessions.status", func(s *entity.AuthSession) interface{} { return s.Status }},
+	"action":     {"auth_sessions.action", func(s *entity.AuthSession) interface{} { return s.Action }},
+}
+
+// authSessionOrder 认证会话的排序方式，相同排序值按ID排序以保证顺序唯一
+type authSessionOrder struct {
+	field authSessionSortField
+	desc  bool
+}
+
+// clause 返回排序子句
+func (o *authSessionOrder) clause() string {
+	if o.desc {
+		return o.field.column + " DESC, auth_sessions.id DESC"
+	}
+	return o.field.column + " ASC, auth_sessions.id ASC"
+}
+
+// after 限定查询只返回排在 last 之后的记录
+func (o *authSessionOrder) after(query *gorm.DB, last *entity.AuthSession) *gorm.DB {
+	op := ">"
+	if o.desc {
+		op = "<"
+	}
+	value := o.field.value(last)
+	return query.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND auth_sessions.id %[2]s ?))", o.field.column, op),
+		value, value, last.ID)
+}
+
 // GetAuthSessions 获取认证会话列表
-func GetAuthSessions(page, pageSize int, status, username string) ([]entity.AuthSession, int64, error) {
+func GetAuthSessions(page, pageSize int, filter *request.AuthSessionFilter) ([]entity.AuthSession, int64, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -17,16 +66,9 @@ func GetAuthSessions(page, pageSize int, status, username string) ([]entity.Auth
 	}
 
 	offset := (page - 1) * pageSize
-	query := global.DB.Model(&entity.AuthSession{}).Preload("User").Preload("APIKey").Preload("RespondingDevice")
-
-	// 应用过滤条件
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-
-	if username != "" {
-		query = query.Joins("LEFT JOIN users ON auth_sessions.user_id = users.id").
-			Where("users.username LIKE ?", "%"+username+"%")
+	query, order, err := buildAuthSessionQuery(global.DB, filter)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	var total int64
@@ -35,11 +77,163 @@ func GetAuthSessions(page, pageSize int, status, username string) ([]entity.Auth
 	}
 
 	var sessions []entity.AuthSession
-	if err := query.Offset(offset).Limit(pageSize).
-		Order("created_at DESC").
+	if err := preloadAuthSession(query).Offset(offset).Limit(pageSize).
+		Order(order.clause()).
 		Find(&sessions).Error; err != nil {
 		return nil, 0, fmt.Errorf("获取认证会话列表失败: %w", err)
 	}
 
 	return sessions, total, nil
 }
+
+// ExportAuthSessions 按过滤条件分批查询全部认证会话，逐条交给 fn 处理
+// 整个导出在一个只读事务中进行，导出期间新建或更新的会话不会造成记录重复或遗漏；
+// 按排序字段和ID做键集分页，每批查询的开销不随已导出的数量增长
+func ExportAuthSessions(filter *request.AuthSessionFilter, fn func(*response.AuthSessionRecord) error) error {
+	if filter == nil {
+		filter = &request.AuthSessionFilter{}
+	}
+	if err := ValidateAuthSessionFilter(filter); err != nil {
+		return err
+	}
+
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		query, order, err := buildAuthSessionQuery(tx, filter)
+		if err != nil {
+			return err
+		}
+
+		var last *entity.AuthSession
+		for {
+			batch := preloadAuthSession(query.Session(&gorm.Session{}))
+			if last != nil {
+				batch = order.after(batch, last)
+			}
+			var sessions []entity.AuthSession
+			if err := batch.Limit(authSessionExportBatchSize).Order(order.clause()).Find(&sessions).Error; err != nil {
+				return fmt.Errorf("导出认证会话失败: %w", err)
+			}
+
+			for i := range sessions {
+				if err := fn(ConvertToAuthSessionRecord(&sessions[i])); err != nil {
+					return err
+				}
+			}
+
+			if len(sessions) < authSessionExportBatchSize {
+				return nil
+			}
+			last = &sessions[len(sessions)-1]
+		}
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// ConvertToAuthSessionRecord 将认证会话转换为导出记录
+func ConvertToAuthSessionRecord(session *entity.AuthSession) *response.AuthSessionRecord {
+	record := &response.AuthSessionRecord{
+		ID:                 session.ID,
+		Status:             session.Status,
+		Result:             session.Result,
+		Action:             session.Action,
+		Message:            session.Message,
+		Challenge:          session.Challenge,
+		UserID:             session.UserID,
+		APIKeyID:           session.APIKeyID,
+		RespondingDeviceID: session.RespondingDeviceID,
+		ClientIP:           session.ClientIP,
+		CreatedAt:          session.CreatedAt,
+		UpdatedAt:          session.UpdatedAt,
+		ExpiresAt:          session.ExpiresAt,
+	}
+	if session.User != nil {
+		record.Username = session.User.Username
+	}
+	if session.APIKey != nil {
+		record.APIKeyName = session.APIKey.Name
+	}
+	if session.RespondingDevice != nil {
+		record.DeviceSerialNumber = session.RespondingDevice.SerialNumber
+		record.DeviceGroupID = session.RespondingDevice.DeviceGroupID
+	}
+	return record
+}
+
+// ValidateAuthSessionFilter 校验认证会话的时间范围和排序参数
+func ValidateAuthSessionFilter(filter *request.AuthSessionFilter) error {
+	if filter.StartTime != nil && filter.EndTime != nil && !filter.StartTime.Before(*filter.EndTime) {
+		return errs.ErrSessionTimeRangeInvalid
+	}
+	if _, ok := authSessionSortFields[filter.SortBy]; filter.SortBy != "" && !ok {
+		return errs.ErrSessionSortInvalid
+	}
+	if filter.SortOrder != "" && filter.SortOrder != "asc" && filter.SortOrder != "desc" {
+		return errs.ErrSessionSortInvalid
+	}
+	return nil
+}
+
+// buildAuthSessionQuery 在 db 上根据过滤条件构建认证会话查询，返回查询和排序方式
+func buildAuthSessionQuery(db *gorm.DB, filter *request.AuthSessionFilter) (*gorm.DB, *authSessionOrder, error) {
+	if filter == nil {
+		filter = &request.AuthSessionFilter{}
+	}
+	if err := ValidateAuthSessionFilter(filter); err != nil {
+		return nil, nil, err
+	}
+
+	order := &authSessionOrder{field: authSessionSortFields["created_at"], desc: filter.SortOrder != "asc"}
+	if filter.SortBy != "" {
+		order.field = authSessionSortFields[filter.SortBy]
+	}
+
+	query := db.Model(&entity.AuthSession{})
+
+	// 应用过滤条件
+	if filter.Status != "" {
+		query = query.Where("auth_sessions.status = ?", filter.Status)
+	}
+	if filter.Action != "" {
+		query = query.Where("auth_sessions.action = ?", filter.Action)
+	}
+	if filter.APIKeyID != nil {
+		query = query.Where("auth_sessions.api_key_id = ?", *filter.APIKeyID)
+	}
+	if filter.DeviceID != nil {
+		query = query.Where("auth_sessions.responding_device_id = ?", *filter.DeviceID)
+	}
+	if filter.ClientIP != "" {
+		query = query.Where("auth_sessions.client_ip = ?", filter.ClientIP)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("auth_sessions.created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("auth_sessions.created_at < ?", *filter.EndTime)
+	}
+	if filter.Search != "" {
+		challenge, pattern := likeContains(db, "auth_sessions.challenge", filter.Search)
+		message, _ := likeContains(db, "auth_sessions.message", filter.Search)
+		query = query.Where(challenge+" OR "+message, pattern, pattern)
+	}
+
+	if filter.Username != "" {
+		condition, pattern := likeContains(db, "users.username", filter.Username)
+		query = query.Joins("LEFT JOIN users ON auth_sessions.user_id = users.id").
+			Where(condition, pattern)
+	}
+
+	if filter.DeviceGroupID != nil {
+		query = query.Joins("JOIN devices ON auth_sessions.responding_device_id = devices.id").
+			Where("devices.device_group_id = ?", *filter.DeviceGroupID)
+	}
+
+	return query, order, nil
+}
+
+// preloadAuthSession 预加载认证会话的关联数据，已删除的用户和设备同样加载，API密钥只加载名称
+func preloadAuthSession(query *gorm.DB) *gorm.DB {
+	return query.
+		Preload("User", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("APIKey", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Select("id", "name", "is_admin") }).
+		Preload("RespondingDevice", func(db *gorm.DB) *gorm.DB { return db.Unscoped() })
+}
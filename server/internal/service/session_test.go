@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/sdk/request"
+	"github.com/hang666/EasyUKey/sdk/response"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// exportedSessionIDs 导出符合过滤条件的认证会话，返回会话ID
+func exportedSessionIDs(t *testing.T, filter *request.AuthSessionFilter) []string {
+	t.Helper()
+	var ids []string
+	if err := ExportAuthSessions(filter, func(r *response.AuthSessionRecord) error {
+		ids = append(ids, r.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestAuthSessionFilterEscapesLikeWildcards(t *testing.T) {
+	setupTestDB(t)
+	sessions := make(map[string]string)
+	for _, username := range []string{"alice", "a_b", "100%", `back\slash`} {
+		user := createTestUser(t, username)
+		session := createTestAuthSession(t, user.ID, consts.AuthStatusCompleted, 0, nil, "")
+		if err := global.DB.Model(session).Update("message", "登录 "+username).Error; err != nil {
+			t.Fatal(err)
+		}
+		sessions[username] = session.ID
+	}
+
+	cases := []struct {
+		name   string
+		filter request.AuthSessionFilter
+		want   string
+	}{
+		{"用户名中的下划线", request.AuthSessionFilter{Username: "_"}, "a_b"},
+		{"用户名中的百分号", request.AuthSessionFilter{Username: "%"}, "100%"},
+		{"用户名中的反斜杠", request.AuthSessionFilter{Username: `\`}, `back\slash`},
+		{"搜索中的下划线", request.AuthSessionFilter{Search: "_"}, "a_b"},
+		{"搜索中的百分号", request.AuthSessionFilter{Search: "0%"}, "100%"},
+		{"普通子串", request.AuthSessionFilter{Username: "lic"}, "alice"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, total, err := GetAuthSessions(1, 100, &tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 1 || len(got) != 1 || got[0].ID != sessions[tc.want] {
+				t.Fatalf("匹配到 %d 个会话，应只匹配 %s 的会话", total, tc.want)
+			}
+			if ids := exportedSessionIDs(t, &tc.filter); !slices.Equal(ids, []string{sessions[tc.want]}) {
+				t.Fatalf("导出 %v，应只导出 %s 的会话", ids, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateAuthSessionFilter(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	cases := []struct {
+		name   string
+		filter request.AuthSessionFilter
+		want   error
+	}{
+		{"空条件", request.AuthSessionFilter{}, nil},
+		{"有效的时间范围和排序", request.AuthSessionFilter{StartTime: &earlier, EndTime: &now, SortBy: "status", SortOrder: "asc"}, nil},
+		{"开始时间等于结束时间", request.AuthSessionFilter{StartTime: &now, EndTime: &now}, errs.ErrSessionTimeRangeInvalid},
+		{"开始时间晚于结束时间", request.AuthSessionFilter{StartTime: &now, EndTime: &earlier}, errs.ErrSessionTimeRangeInvalid},
+		{"不允许的排序字段", request.AuthSessionFilter{SortBy: "challenge"}, errs.ErrSessionSortInvalid},
+		{"无效的排序方向", request.AuthSessionFilter{SortOrder: "up"}, errs.ErrSessionSortInvalid},
+	}
+	for _, tc := range cases {
+		if err := ValidateAuthSessionFilter(&tc.filter); !errors.Is(err, tc.want) {
+			t.Errorf("%s: 校验返回 %v，应为 %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestExportAuthSessionsPagesThroughAllSessions(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+
+	// 超过一批的数量，状态只有两种，相同排序值靠ID区分
+	sessions := make([]entity.AuthSession, authSessionExportBatchSize+10)
+	base := time.Now().Add(-time.Hour)
+	for i := range sessions {
+		status := consts.AuthStatusCompleted
+		if i%2 == 0 {
+			status = consts.AuthStatusRejected
+		}
+		sessions[i] = entity.AuthSession{
+			ID:        uuidFromIndex(i),
+			UserID:    user.ID,
+			Status:    status,
+			CreatedAt: base.Add(time.Duration(i%7) * time.Second),
+			ExpiresAt: base.Add(time.Hour),
+		}
+	}
+	if err := global.DB.CreateInBatches(sessions, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sortBy := range []string{"status", "created_at"} {
+		for _, sortOrder := range []string{"asc", "desc"} {
+			ids := exportedSessionIDs(t, &request.AuthSessionFilter{SortBy: sortBy, SortOrder: sortOrder})
+			if len(ids) != len(sessions) {
+				t.Fatalf("按 %s %s 导出 %d 个会话，应为 %d 个", sortBy, sortOrder, len(ids), len(sessions))
+			}
+			seen := make(map[string]bool, len(ids))
+			for _, id := range ids {
+				if seen[id] {
+					t.Fatalf("按 %s %s 导出时会话 %s 重复", sortBy, sortOrder, id)
+				}
+				seen[id] = true
+			}
+		}
+	}
+
+	// 过滤条件同样作用于导出
+	ids := exportedSessionIDs(t, &request.AuthSessionFilter{Status: consts.AuthStatusRejected})
+	if len(ids) != len(sessions)/2 {
+		t.Errorf("按状态导出 %d 个会话，应为 %d 个", len(ids), len(sessions)/2)
+	}
+}
+
+// uuidFromIndex 生成按序号排序的会话ID
+func uuidFromIndex(i int) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+}
@@ -187,6 +187,23 @@ func DeleteUser(userID uint) error {
 		}
 	}
 
+	// 删除用户的管理员账号，已登录的管理后台会话随之失效
+	var adminAccountIDs []uint
+	if err := tx.Model(&entity.AdminAccount{}).Where("user_id = ?", userID).Pluck("id", &adminAccountIDs).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("查询管理员账号失败: %w", err)
+	}
+	if len(adminAccountIDs) > 0 {
+		if err := tx.Where("admin_account_id IN ?", adminAccountIDs).Delete(&entity.AdminSession{}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("删除管理员会话失败: %w", err)
+		}
+		if err := tx.Where("id IN ?", adminAccountIDs).Delete(&entity.AdminAccount{}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("删除管理员账号失败: %w", err)
+		}
+	}
+
 	// 删除用户
 	if err := tx.Delete(&user).Error; err != nil {
 		tx.Rollback()
@@ -23,6 +23,7 @@ func HandleWebSocket(c echo.Context) error {
 
 	client := &Client{
 		Conn:            conn,
+		RemoteIP:        c.RealIP(),
 		Send:            make(chan []byte, config.GlobalConfig.WebSocket.SendChannelBuffer),
 		ConnectedAt:     time.Now(),
 		LastPongAt:      time.Now(),
@@ -34,6 +35,7 @@ func HandleWebSocket(c echo.Context) error {
 	// 启动客户端处理goroutines
 	go client.writePump()
 	go client.readPump()
+	go client.enforceHandshakeDeadline()
 
 	return nil
 }
@@ -129,12 +131,43 @@ func (c *Client) readPump() {
 		}
 
 		// 使用简化的消息分派机制
-		if err := dispatchMessage(c, &wsMsg); err != nil {
+		if err := dispatchMessage(c, &wsMsg, false); err != nil {
 			logger.Logger.Error("处理WebSocket消息失败", "error", err, "type", wsMsg.Type, "device_id", c.DeviceID)
 		}
 	}
 }
 
+// enforceHandshakeDeadline 未在期限内完成握手或设备注册的连接将被关闭
+func (c *Client) enforceHandshakeDeadline() {
+	handshakeTimer := time.NewTimer(config.GlobalConfig.WebSocket.HandshakeTimeout)
+	defer handshakeTimer.Stop()
+	registerTimer := time.NewTimer(config.GlobalConfig.WebSocket.ConnectionTimeout)
+	defer registerTimer.Stop()
+
+	for {
+		select {
+		case <-handshakeTimer.C:
+			c.mu.RLock()
+			completed := c.HandshakeStatus == messages.HandshakeStatusCompleted
+			c.mu.RUnlock()
+			if !completed {
+				logger.Logger.Warn("连接未在期限内完成握手，关闭连接", "remote_addr", c.Conn.RemoteAddr().String())
+				c.Conn.Close()
+				return
+			}
+		case <-registerTimer.C:
+			c.mu.RLock()
+			registered := c.IsRegistered
+			c.mu.RUnlock()
+			if !registered {
+				logger.Logger.Warn("连接未在期限内完成设备注册，关闭连接", "remote_addr", c.Conn.RemoteAddr().String())
+				c.Conn.Close()
+			}
+			return
+		}
+	}
+}
+
 // resetReadDeadline 重置读取超时时间
 func (c *Client) resetReadDeadline() {
 	c.Conn.SetReadDeadline(time.Now().Add(config.GlobalConfig.WebSocket.PongWait))
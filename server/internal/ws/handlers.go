@@ -2,12 +2,15 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/hang666/EasyUKey/sdk/consts"
 	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/model/entity"
 	"github.com/hang666/EasyUKey/server/internal/service"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
 	"github.com/hang666/EasyUKey/shared/pkg/identity"
 	"github.com/hang666/EasyUKey/shared/pkg/logger"
 	"github.com/hang666/EasyUKey/shared/pkg/messages"
@@ -34,37 +37,88 @@ func handleDeviceConnection(client *Client, wsMsg *messages.WSMessage) error {
 		First(&device)
 
 	if result.Error == nil {
-		// 找到现有设备，正常连接
-		return handleExistingDeviceConnection(client, &connMsg, device.ID, device.DeviceGroupID)
+		// 找到现有设备，校验硬件指纹后连接
+		return handleFingerprintedDeviceConnection(client, &connMsg, device.ID, device.DeviceGroupID, messages.DeviceStatusConnected)
 	}
 
-	// 2. 没有找到现有设备，尝试跨平台匹配
+	// 2. 重新格式化会改变卷序列号，按序列号和硬件指纹识别原设备
+	matched, err := service.FindDeviceByFingerprint(&connMsg)
+	if err != nil {
+		logger.Logger.Error("按硬件指纹识别设备失败", "error", err, "serial_number", connMsg.SerialNumber)
+	} else if matched != nil {
+		return handleFingerprintedDeviceConnection(client, &connMsg, matched.ID, matched.DeviceGroupID, messages.DeviceStatusConnected)
+	}
+
+	// 3. 没有找到现有设备，尝试跨平台匹配
 	return handleCrossPlatformDeviceConnection(client, &connMsg)
 }
 
-// handleExistingDeviceConnection 处理现有设备连接
-func handleExistingDeviceConnection(client *Client, connMsg *messages.DeviceConnectionMessage, deviceID uint, deviceGroupID *uint) error {
+// handleFingerprintedDeviceConnection 比对硬件指纹后处理现有设备连接，status 为连接或会话恢复
+// 相似度低于复核阈值时拒绝连接，介于复核阈值和接受阈值之间时设备被停用并等待管理员重新激活
+func handleFingerprintedDeviceConnection(client *Client, connMsg *messages.DeviceConnectionMessage, deviceID uint, deviceGroupID *uint, status string) error {
+	score, verdict, err := service.VerifyDeviceFingerprint(deviceID, connMsg.VolumeSerialNumber, connMsg.Fingerprint)
+	if err != nil {
+		logger.Logger.Error("校验设备硬件指纹失败", "error", err, "device_id", deviceID)
+		return sendErrorToClient(client, "device_connection", "fingerprint_error", "设备硬件指纹校验失败")
+	}
+
+	switch verdict {
+	case service.FingerprintRejected:
+		logger.Logger.Warn("设备硬件指纹不匹配，拒绝连接",
+			"device_id", deviceID,
+			"score", score,
+			"serial_number", connMsg.SerialNumber,
+			"volume_serial_number", connMsg.VolumeSerialNumber)
+		return sendErrorToClient(client, "device_connection", "fingerprint_mismatch", "设备硬件指纹不匹配，连接被拒绝")
+	case service.FingerprintReview:
+		logger.Logger.Warn("设备硬件指纹发生变化，设备已停用，等待管理员重新激活",
+			"device_id", deviceID,
+			"score", score,
+			"serial_number", connMsg.SerialNumber)
+		return respondPendingActivation(client, deviceID, "设备硬件指纹发生变化，等待管理员重新激活")
+	}
+
+	return handleExistingDeviceConnection(client, connMsg, deviceID, deviceGroupID, status)
+}
+
+// respondPendingActivation 通知客户端设备等待管理员激活
+// 设备不注册到Hub，也不签发会话恢复令牌，连接在注册期限到达后关闭，客户端重连时重新校验
+func respondPendingActivation(client *Client, deviceID uint, message string) error {
+	if deviceID != 0 {
+		resumeTokens.RevokeDevice(deviceID)
+	}
+
+	connResp := &messages.DeviceConnectionResponseMessage{
+		Success: true,
+		Status:  messages.DeviceStatusPendingActivation,
+		Message: message,
+	}
+	return sendMessageToClient(client, "device_connection_response", connResp)
+}
+
+// handleExistingDeviceConnection 处理现有设备连接，status 为连接或会话恢复
+func handleExistingDeviceConnection(client *Client, connMsg *messages.DeviceConnectionMessage, deviceID uint, deviceGroupID *uint, status string) error {
 	// 如果设备关联了设备组，获取设备组的用户信息
 	if deviceGroupID != nil {
 		var deviceGroup entity.DeviceGroup
-		if err := global.DB.Where("id = ?", *deviceGroupID).First(&deviceGroup).Error; err == nil {
-			// 如果提供了认证信息但OnceKey不匹配，记录警告
-			if connMsg.OnceKey != "" && deviceGroup.OnceKey != connMsg.OnceKey {
-				logger.Logger.Warn("检测到可疑设备：现有设备连接时OnceKey不匹配",
-					"device_id", deviceID,
-					"device_group_id", deviceGroup.ID,
-					"device_group_name", deviceGroup.Name,
-					"provided_once_key", connMsg.OnceKey,
-					"expected_once_key", deviceGroup.OnceKey,
-					"serial_number", connMsg.SerialNumber,
-				)
-			}
+		if err := global.DB.Where("id = ?", *deviceGroupID).First(&deviceGroup).Error; err != nil {
+			logger.Logger.Error("查询设备组失败", "error", err, "device_id", deviceID, "device_group_id", *deviceGroupID)
+			return sendErrorToClient(client, "device_connection", "device_group_error", "查询设备组失败")
+		}
 
-			if deviceGroup.UserID != nil {
-				client.mu.Lock()
-				client.UserID = *deviceGroup.UserID
-				client.mu.Unlock()
-			}
+		if rejected, err := rejectSuspectedClone(client, connMsg, deviceID, &deviceGroup); rejected {
+			return err
+		}
+
+		// 完整连接必须出示设备组的OnceKey，恢复会话由上次连接签发的令牌证明身份
+		if status != messages.DeviceStatusResumed && !service.MatchConnectionOnceKey(&deviceGroup, connMsg.OnceKey) {
+			return rejectOnceKeyMismatch(client, connMsg, deviceID, &deviceGroup)
+		}
+
+		if deviceGroup.UserID != nil {
+			client.mu.Lock()
+			client.UserID = *deviceGroup.UserID
+			client.mu.Unlock()
 		}
 	}
 
@@ -84,14 +138,115 @@ func handleExistingDeviceConnection(client *Client, connMsg *messages.DeviceConn
 		}
 	}
 
-	// 发送连接成功响应
+	// 发送连接成功响应，附带下次断线重连使用的会话恢复令牌
 	connResp := &messages.DeviceConnectionResponseMessage{
 		Success: true,
-		Status:  "connected",
+		Status:  status,
 		Message: "设备连接成功",
 	}
+	if status == messages.DeviceStatusResumed {
+		connResp.Message = "会话已恢复"
+	}
 
-	return sendMessageToClient(client, "device_connection_response", connResp)
+	connResp.ResumeToken, connResp.ResumeTTL = issueResumeToken(deviceID, connMsg.SerialNumber, connMsg.VolumeSerialNumber)
+
+	if err := sendMessageToClient(client, "device_connection_response", connResp); err != nil {
+		return err
+	}
+
+	// 重新下发断线期间仍在等待响应的认证请求
+	redeliverPendingAuth(client)
+	return nil
+}
+
+// rejectSuspectedClone 检查设备组是否已被隔离或出现克隆迹象，需要拒绝连接时返回 true
+// 出示已轮换掉的旧OnceKey，或同一设备组已有不同序列号的设备在线，均视为U盘被克隆，隔离设备组并强制组内设备下线
+func rejectSuspectedClone(client *Client, connMsg *messages.DeviceConnectionMessage, deviceID uint, group *entity.DeviceGroup) (bool, error) {
+	reject := func() (bool, error) {
+		return true, sendErrorToClient(client, "device_connection", "device_quarantined", errs.ErrDeviceGroupQuarantined.Error())
+	}
+
+	if group.Quarantined {
+		logger.Logger.Warn("设备组已被隔离，拒绝连接",
+			"device_id", deviceID,
+			"device_group_id", group.ID,
+			"serial_number", connMsg.SerialNumber)
+		return reject()
+	}
+
+	var eventType, detail string
+	if service.IsReplayedOnceKey(group, connMsg.OnceKey) {
+		eventType = consts.SecurityEventOnceKeyReplay
+		detail = "设备连接时出示了已轮换的OnceKey"
+	} else {
+		other, err := service.FindConcurrentGroupDevice(group.ID, deviceID, connMsg.SerialNumber)
+		if err != nil {
+			logger.Logger.Error("检查设备组并发连接失败", "error", err, "device_group_id", group.ID)
+			return false, nil
+		}
+		if other == nil {
+			return false, nil
+		}
+		eventType = consts.SecurityEventConcurrentSerials
+		detail = fmt.Sprintf("设备组已有序列号为 %s 的设备在线时，序列号为 %s 的设备发起连接", other.SerialNumber, connMsg.SerialNumber)
+	}
+
+	if err := service.QuarantineDeviceGroup(group.ID, eventType, deviceID, connMsg.SerialNumber, detail); err != nil {
+		logger.Logger.Error("隔离设备组失败", "error", err, "device_group_id", group.ID)
+	}
+	return reject()
+}
+
+// rejectOnceKeyMismatch 拒绝未出示或出示了错误OnceKey的现有设备连接并记录安全事件
+// 单次不匹配无法区分克隆和伪造的序列号，只拒绝连接，不隔离设备组，避免任何知道序列号的人都能让设备组停用
+func rejectOnceKeyMismatch(client *Client, connMsg *messages.DeviceConnectionMessage, deviceID uint, group *entity.DeviceGroup) error {
+	logger.Logger.Warn("检测到可疑设备：现有设备连接时OnceKey不匹配，拒绝连接",
+		"device_id", deviceID,
+		"device_group_id", group.ID,
+		"device_group_name", group.Name,
+		"serial_number", connMsg.SerialNumber,
+		"once_key_present", connMsg.OnceKey != "")
+
+	detail := "现有设备连接时出示的OnceKey与设备组不匹配"
+	if connMsg.OnceKey == "" {
+		detail = "现有设备连接时未出示OnceKey"
+	}
+	if err := service.RecordSecurityEvent(nil, &entity.SecurityEvent{
+		Type:          consts.SecurityEventOnceKeyMismatch,
+		DeviceGroupID: &group.ID,
+		DeviceID:      &deviceID,
+		SerialNumber:  connMsg.SerialNumber,
+		Detail:        detail,
+	}); err != nil {
+		logger.Logger.Error("记录安全事件失败", "error", err, "device_group_id", group.ID)
+	}
+
+	return sendErrorToClient(client, "device_connection", "once_key_mismatch", errs.ErrOnceKeyMismatch.Error())
+}
+
+// redeliverPendingAuth 向刚连接的设备重新下发用户仍在等待响应的认证请求
+func redeliverPendingAuth(client *Client) {
+	client.mu.RLock()
+	userID := client.UserID
+	client.mu.RUnlock()
+
+	if userID == 0 {
+		return
+	}
+
+	requests, err := service.PendingAuthRequests(userID)
+	if err != nil {
+		logger.Logger.Error("查询待处理认证请求失败", "user_id", userID, "error", err)
+		return
+	}
+
+	for _, req := range requests {
+		if err := sendMessageToClient(client, "auth_request", req); err != nil {
+			logger.Logger.Error("重新下发认证请求失败", "request_id", req.RequestID, "error", err)
+			return
+		}
+		logger.Logger.Info("已重新下发待处理的认证请求", "request_id", req.RequestID, "user_id", userID)
+	}
 }
 
 // handleCrossPlatformDeviceConnection 处理跨平台设备连接
@@ -104,10 +259,24 @@ func handleCrossPlatformDeviceConnection(client *Client, connMsg *messages.Devic
 	}
 
 	if matchedGroup == nil {
+		// 携带旧OnceKey的未登记U盘是设备组密钥的副本
+		replayedGroup, err := service.FindReplayedDeviceGroup(connMsg.TOTPCode, connMsg.OnceKey)
+		if err != nil {
+			logger.Logger.Error("检查OnceKey重放失败", "error", err, "serial_number", connMsg.SerialNumber)
+		} else if replayedGroup != nil {
+			if rejected, err := rejectSuspectedClone(client, connMsg, 0, replayedGroup); rejected {
+				return err
+			}
+		}
+
 		// 无法匹配到现有设备组，可能是全新设备
 		return sendErrorToClient(client, "device_connection", "no_match", "无法识别的设备，请先进行设备初始化")
 	}
 
+	if rejected, err := rejectSuspectedClone(client, connMsg, 0, matchedGroup); rejected {
+		return err
+	}
+
 	// 匹配成功，创建新的设备记录并关联到现有设备组
 	deviceID, err := createCrossPlatformDevice(connMsg, matchedGroup)
 	if err != nil {
@@ -115,33 +284,7 @@ func handleCrossPlatformDeviceConnection(client *Client, connMsg *messages.Devic
 		return sendErrorToClient(client, "device_connection", "create_error", fmt.Sprintf("创建跨平台设备失败: %v", err))
 	}
 
-	// 更新客户端信息
-	client.mu.Lock()
-	if matchedGroup.UserID != nil {
-		client.UserID = *matchedGroup.UserID
-	}
-	client.DeviceID = deviceID
-	client.SerialNumber = connMsg.SerialNumber
-	client.VolumeSerialNumber = connMsg.VolumeSerialNumber
-	client.IsRegistered = true
-	client.mu.Unlock()
-
-	// 注册到Hub
-	if hub := service.GetWSHub(); hub != nil {
-		if h, ok := hub.(*Hub); ok {
-			h.register <- client
-			hub.OnDeviceConnect(deviceID)
-		}
-	}
-
-	// 发送连接响应
-	connResp := &messages.DeviceConnectionResponseMessage{
-		Success: true,
-		Status:  "pending_activation",
-		Message: "跨平台设备识别成功，等待管理员激活",
-	}
-
-	return sendMessageToClient(client, "device_connection_response", connResp)
+	return respondPendingActivation(client, deviceID, "跨平台设备识别成功，等待管理员激活")
 }
 
 // handleDeviceReconnect 处理设备重连
@@ -164,7 +307,24 @@ func handleDeviceReconnect(client *Client, wsMsg *messages.WSMessage) error {
 		Model:              reconnectMsg.Model,
 	}
 
-	// 查找现有设备
+	// 重连必须携带上次连接时签发的会话恢复令牌和硬件指纹，否则需要重新进行完整的设备连接
+	if reconnectMsg.Fingerprint == nil {
+		logger.Logger.Warn("设备重连失败：未上报硬件指纹",
+			"serial_number", connMsg.SerialNumber,
+			"volume_serial_number", connMsg.VolumeSerialNumber)
+		return rejectResume(client)
+	}
+	connMsg.Fingerprint = reconnectMsg.Fingerprint
+
+	deviceID, err := resumeTokens.Consume(reconnectMsg.ResumeToken, connMsg.SerialNumber, connMsg.VolumeSerialNumber)
+	if err != nil {
+		logger.Logger.Warn("设备重连失败：会话恢复令牌无效",
+			"serial_number", connMsg.SerialNumber,
+			"volume_serial_number", connMsg.VolumeSerialNumber)
+		return rejectResume(client)
+	}
+
+	// 确认设备仍然存在
 	var device struct {
 		ID            uint
 		DeviceGroupID *uint
@@ -172,25 +332,47 @@ func handleDeviceReconnect(client *Client, wsMsg *messages.WSMessage) error {
 	}
 	result := global.DB.Table("devices").
 		Select("id, device_group_id, is_active").
-		Where("serial_number = ? AND volume_serial_number = ? AND deleted_at IS NULL", connMsg.SerialNumber, connMsg.VolumeSerialNumber).
+		Where("id = ? AND deleted_at IS NULL", deviceID).
 		First(&device)
 
 	if result.Error != nil {
-		// 没有找到设备，记录日志
 		logger.Logger.Warn("设备重连失败：未找到对应设备",
-			"serial_number", connMsg.SerialNumber,
-			"volume_serial_number", connMsg.VolumeSerialNumber)
-		return nil
+			"device_id", deviceID,
+			"serial_number", connMsg.SerialNumber)
+		return rejectResume(client)
+	}
+
+	// 恢复会话同样比对硬件指纹，等待管理员重新激活的设备不能通过恢复会话保持连接
+	return handleFingerprintedDeviceConnection(client, &connMsg, device.ID, device.DeviceGroupID, messages.DeviceStatusResumed)
+}
+
+// issueResumeToken 为设备签发会话恢复令牌，返回令牌和有效期（秒），签发失败时客户端只能完整重连
+func issueResumeToken(deviceID uint, serialNumber, volumeSerialNumber string) (string, int) {
+	resumeWindow := global.Config.WebSocket.ResumeWindow
+	token, err := resumeTokens.Issue(deviceID, serialNumber, volumeSerialNumber, resumeWindow)
+	if err != nil {
+		logger.Logger.Error("签发会话恢复令牌失败", "device_id", deviceID, "error", err)
+		return "", 0
 	}
+	return token, int(resumeWindow.Seconds())
+}
 
-	return handleExistingDeviceConnection(client, &connMsg, device.ID, device.DeviceGroupID)
+// rejectResume 通知客户端会话无法恢复，客户端需要重新进行完整的设备连接
+func rejectResume(client *Client) error {
+	resp := &messages.DeviceConnectionResponseMessage{
+		Success: false,
+		Status:  messages.DeviceStatusResumeRejected,
+		Message: "会话无法恢复，请重新连接设备",
+		Error:   errs.ErrResumeTokenInvalid.Error(),
+	}
+	return sendMessageToClient(client, "device_connection_response", resp)
 }
 
 // createCrossPlatformDevice 创建跨平台设备记录
 func createCrossPlatformDevice(connMsg *messages.DeviceConnectionMessage, group *entity.DeviceGroup) (uint, error) {
 	// 创建新的设备记录，关联到现有设备组
 	device := entity.Device{
-		Name:               fmt.Sprintf("设备_%s_%s", group.Name, connMsg.SerialNumber[len(connMsg.SerialNumber)-4:]),
+		Name:               fmt.Sprintf("设备_%s_%s", group.Name, service.SerialSuffix(connMsg.SerialNumber, 4)),
 		DeviceGroupID:      &group.ID,
 		SerialNumber:       connMsg.SerialNumber,
 		VolumeSerialNumber: connMsg.VolumeSerialNumber,
@@ -200,6 +382,7 @@ func createCrossPlatformDevice(connMsg *messages.DeviceConnectionMessage, group
 		IsActive:           false, // 重要：设为非激活状态
 		IsOnline:           true,
 		HeartbeatInterval:  30,
+		Fingerprint:        connMsg.Fingerprint,
 	}
 
 	if err := global.DB.Create(&device).Error; err != nil {
@@ -212,6 +395,10 @@ func createCrossPlatformDevice(connMsg *messages.DeviceConnectionMessage, group
 		"serial_number", connMsg.SerialNumber,
 		"status", "待管理员激活")
 
+	service.Notify(consts.NotifyDevicePendingActivation,
+		fmt.Sprintf("跨平台设备 %s 等待激活", device.Name),
+		fmt.Sprintf("设备组 %s 识别到新的跨平台设备（序列号 %s），需管理员激活。", group.Name, connMsg.SerialNumber))
+
 	return device.ID, nil
 }
 
@@ -224,56 +411,69 @@ func handleDeviceInit(client *Client, wsMsg *messages.WSMessage) error {
 	}
 
 	// 调用设备服务处理初始化
-	onceKey, totpURI, err := service.InitDevice(&initMsg)
+	secrets, recoveryCodes, err := service.InitDevice(&initMsg, client.RemoteIP)
+	recovered := initMsg.RecoveryCode != ""
 
 	// 构造响应
 	var initResp *messages.DeviceInitResponseMessage
 	if err != nil {
-		logger.Logger.Error("设备初始化失败", "error", err, "serial_number", initMsg.SerialNumber)
+		logger.Logger.Error("设备初始化失败", "error", err, "serial_number", initMsg.SerialNumber, "recovery", recovered)
 		initResp = &messages.DeviceInitResponseMessage{
 			Success: false,
 			Error:   err.Error(),
 			Message: "设备初始化失败",
 		}
+	} else if recovered {
+		initResp = &messages.DeviceInitResponseMessage{
+			Success:    true,
+			OnceKey:    secrets.OnceKey,
+			TOTPURI:    secrets.TOTPSecret,
+			AuthSecret: secrets.AuthSecret,
+			Recovered:  true,
+			Message:    "恢复成功，新设备已接管原设备组，旧设备已被吊销",
+		}
 	} else {
 		initResp = &messages.DeviceInitResponseMessage{
-			Success: true,
-			OnceKey: onceKey,
-			TOTPURI: totpURI,
-			Message: "设备初始化成功，请联系管理员绑定用户",
+			Success:       true,
+			OnceKey:       secrets.OnceKey,
+			TOTPURI:       secrets.TOTPSecret,
+			AuthSecret:    secrets.AuthSecret,
+			RecoveryCodes: recoveryCodes,
+			Message:       "设备初始化成功，请联系管理员绑定用户",
 		}
 	}
 
-	// 发送初始化响应
-	if err := sendMessageToClient(client, "device_init_response", initResp); err != nil {
-		return err
-	}
-
 	// 初始化成功后自动注册设备为在线
 	if initResp.Success {
-		var deviceID uint
-		result := global.DB.Table("devices").
-			Select("id").
-			Where("serial_number = ? AND volume_serial_number = ? AND deleted_at IS NULL", initMsg.SerialNumber, initMsg.VolumeSerialNumber).
-			Pluck("id", &deviceID)
-		if result.Error == nil && deviceID > 0 {
+		var device entity.Device
+		result := global.DB.Preload("DeviceGroup").
+			Where("serial_number = ? AND volume_serial_number = ?", initMsg.SerialNumber, initMsg.VolumeSerialNumber).
+			First(&device)
+		if result.Error == nil && device.ID > 0 {
 			client.mu.Lock()
-			client.DeviceID = deviceID
+			client.DeviceID = device.ID
 			client.SerialNumber = initMsg.SerialNumber
 			client.VolumeSerialNumber = initMsg.VolumeSerialNumber
 			client.IsRegistered = true
+			// 接管原设备组时沿用原有的用户关联
+			if device.IsActive && device.DeviceGroup != nil && device.DeviceGroup.UserID != nil {
+				client.UserID = *device.DeviceGroup.UserID
+			}
 			client.mu.Unlock()
 
 			if hub := service.GetWSHub(); hub != nil {
 				if h, ok := hub.(*Hub); ok {
 					h.register <- client
-					hub.OnDeviceConnect(deviceID)
+					hub.OnDeviceConnect(device.ID)
 				}
 			}
+
+			initResp.ResumeToken, initResp.ResumeTTL = issueResumeToken(device.ID, initMsg.SerialNumber, initMsg.VolumeSerialNumber)
 		}
 	}
 
-	return nil
+	// 发送初始化响应
+	return sendMessageToClient(client, "device_init_response", initResp)
 }
 
 // handleAuthResponse 处理认证响应
@@ -297,7 +497,7 @@ func handleAuthResponse(client *Client, wsMsg *messages.WSMessage) error {
 
 	// 只有在服务端验证成功且客户端同意认证时，才生成新的OnceKey
 	if authResp.Success {
-		newOnceKey, err := service.UpdateDeviceOnceKey(client.DeviceID, authResp.UsedKey)
+		newOnceKey, authSecret, err := service.UpdateDeviceOnceKey(client.DeviceID, authResp.UsedKey)
 		if err != nil {
 			logger.Logger.Error("OnceKey更新失败", "request_id", authResp.RequestID, "error", err)
 			service.CompleteOnceKeyUpdateAuth(authResp.RequestID, false, fmt.Sprintf("OnceKey更新失败: %v", err))
@@ -309,6 +509,7 @@ func handleAuthResponse(client *Client, wsMsg *messages.WSMessage) error {
 			RequestID:  authResp.RequestID,
 			Success:    true,
 			NewOnceKey: newOnceKey,
+			AuthSecret: authSecret,
 		}
 
 		if err := sendMessageToClient(client, "auth_success_response", successResp); err != nil {
@@ -356,6 +557,49 @@ func handleDeviceStatus(client *Client, wsMsg *messages.WSMessage) error {
 	return nil
 }
 
+// handleDeviceStatusReport 处理客户端主动上报的设备状态
+func handleDeviceStatusReport(client *Client, wsMsg *messages.WSMessage) error {
+	status, err := wsutil.ParseMessage[messages.DeviceStatusMessage](wsMsg)
+	if err != nil {
+		return err
+	}
+
+	if status.Status != messages.DeviceStatusRemoved {
+		logger.Logger.Debug("忽略未知设备状态", "status", status.Status, "device_id", client.DeviceID)
+		return nil
+	}
+
+	client.mu.RLock()
+	deviceID, userID := client.DeviceID, client.UserID
+	client.mu.RUnlock()
+	if deviceID == 0 {
+		// 未完成设备连接，没有需要清理的状态
+		client.Conn.Close()
+		return nil
+	}
+
+	logger.Logger.Info("设备已被拔出，标记离线", "device_id", deviceID, "user_id", userID)
+
+	if hub := service.GetWSHub(); hub != nil {
+		if h, ok := hub.(*Hub); ok {
+			h.removeDevice(client)
+		}
+	}
+
+	if userID > 0 {
+		failed, err := service.FailAuthSessionsForDevices(userID, deviceID)
+		if err != nil {
+			logger.Logger.Error("设备拔出后终止认证会话失败", "device_id", deviceID, "error", err)
+		} else if failed > 0 {
+			logger.Logger.Info("设备拔出，已终止未完成的认证会话", "device_id", deviceID, "count", failed)
+		}
+	}
+
+	// 客户端随后会主动断开，这里也关闭连接以便立即注销
+	client.Conn.Close()
+	return nil
+}
+
 // handlePing 处理Ping消息
 func handlePing(client *Client, wsMsg *messages.WSMessage) error {
 	hub := service.GetWSHub()
@@ -378,8 +622,67 @@ func handlePong(client *Client, wsMsg *messages.WSMessage) error {
 	return nil
 }
 
+// handleHello 处理客户端版本与能力声明
+func handleHello(client *Client, wsMsg *messages.WSMessage) error {
+	hello, err := wsutil.ParseMessage[messages.HelloMessage](wsMsg)
+	if err != nil {
+		return sendErrorToClient(client, "hello_response", "parse_error", "版本协商消息解析失败")
+	}
+
+	clientVersion := hello.ClientVersion
+	if clientVersion == "" {
+		clientVersion = wsMsg.ClientVersion
+	}
+
+	// 检查最低客户端版本，无法解析的版本号视为过低
+	accepted := true
+	minVersion := global.Config.WebSocket.MinClientVersion
+	if minVersion != "" {
+		cmp, err := messages.CompareVersions(clientVersion, minVersion)
+		accepted = err == nil && cmp >= 0
+	}
+
+	capabilities := messages.NegotiateCapabilities(messages.DefaultCapabilities(), hello.Capabilities)
+	protocolVersion := messages.NegotiateProtocolVersion(hello.ProtocolVersion)
+
+	client.mu.Lock()
+	client.HelloReceived = true
+	client.VersionAccepted = accepted
+	client.ClientVersion = clientVersion
+	client.ProtocolVersion = protocolVersion
+	client.Capabilities = capabilities
+	client.mu.Unlock()
+
+	resp := &messages.HelloResponseMessage{
+		Success:         accepted,
+		ProtocolVersion: protocolVersion,
+		Capabilities:    capabilities,
+	}
+	if !accepted {
+		logger.Logger.Warn("拒绝过低版本的客户端", "client_version", clientVersion, "min_client_version", minVersion,
+			"remote_addr", client.Conn.RemoteAddr().String())
+		resp.UpgradeRequired = true
+		resp.MinClientVersion = minVersion
+		resp.Message = upgradeMessage(clientVersion, minVersion)
+	} else {
+		logger.Logger.Debug("客户端版本协商完成", "client_version", clientVersion, "protocol_version", protocolVersion,
+			"encryption_suites", capabilities.EncryptionSuites, "auth_token_formats", capabilities.AuthTokenFormats)
+	}
+
+	return sendMessageToClient(client, "hello_response", resp)
+}
+
 // handleKeyExchangeRequest 处理密钥交换请求
 func handleKeyExchangeRequest(client *Client, wsMsg *messages.WSMessage) error {
+	// 每个连接只允许握手一次，密钥轮换在加密会话内完成，拒绝重新握手以防止会话被劫持
+	client.mu.RLock()
+	handshakeStatus := client.HandshakeStatus
+	client.mu.RUnlock()
+	if handshakeStatus != messages.HandshakeStatusPending {
+		logger.Logger.Warn("拒绝重复的密钥交换请求", "device_id", client.DeviceID, "remote_addr", client.Conn.RemoteAddr().String())
+		return errs.ErrInvalidHandshake
+	}
+
 	// 解析密钥交换请求
 	keyExchReq, err := wsutil.ParseMessage[messages.KeyExchangeRequestMessage](wsMsg)
 	if err != nil {
@@ -393,27 +696,45 @@ func handleKeyExchangeRequest(client *Client, wsMsg *messages.WSMessage) error {
 		return sendErrorToClient(client, "key_exchange_response", "server_error", "服务端密钥交换器创建失败")
 	}
 
+	// 构造握手记录，绑定双方临时公钥和随机数
+	transcript, err := identity.BuildHandshakeTranscript(
+		keyExchReq.PublicKey, keyExchReq.Nonce,
+		keyExchange.GetPublicKeyBase64(), keyExchange.GetNonceBase64(),
+	)
+	if err != nil {
+		logger.Logger.Warn("密钥交换请求参数无效", "error", err, "remote_addr", client.Conn.RemoteAddr().String())
+		return sendErrorToClient(client, "key_exchange_response", "parse_error", "密钥交换请求参数无效")
+	}
+
 	// 计算共享密钥
-	if err := keyExchange.ComputeSharedKey(keyExchReq.PublicKey); err != nil {
+	if err := keyExchange.ComputeSharedKey(keyExchReq.PublicKey, transcript); err != nil {
 		return sendErrorToClient(client, "key_exchange_response", "compute_error", "共享密钥计算失败")
 	}
 
-	// 创建加密器
-	encryptor, err := keyExchange.CreateEncryptor()
+	// 创建加密会话
+	session, err := keyExchange.CreateSecureSession(identity.RoleServer, identity.RekeyPolicy{
+		MaxMessages: uint64(global.Config.WebSocket.RekeyAfterMessages),
+		MaxAge:      global.Config.WebSocket.RekeyInterval,
+	})
 	if err != nil {
-		return sendErrorToClient(client, "key_exchange_response", "encryptor_error", "加密器创建失败")
+		return sendErrorToClient(client, "key_exchange_response", "encryptor_error", "加密会话创建失败")
 	}
 
+	// 使用服务端身份密钥签名握手记录，供客户端验证服务端身份
+	signature := global.ServerIdentity.SignTranscript(transcript)
+
 	// 更新客户端状态
 	client.mu.Lock()
 	client.KeyExchange = keyExchange
-	client.Encryptor = encryptor
+	client.Session = session
 	client.HandshakeStatus = messages.HandshakeStatusCompleted
 	client.mu.Unlock()
 
 	// 发送密钥交换响应
 	keyExchResp := &messages.KeyExchangeResponseMessage{
 		PublicKey: keyExchange.GetPublicKeyBase64(),
+		Nonce:     keyExchange.GetNonceBase64(),
+		Signature: signature,
 		Success:   true,
 	}
 
@@ -429,7 +750,7 @@ func handleEncryptedMessage(client *Client, wsMsg *messages.WSMessage) error {
 	// 检查握手状态
 	client.mu.RLock()
 	handshakeStatus := client.HandshakeStatus
-	encryptor := client.Encryptor
+	session := client.Session
 	client.mu.RUnlock()
 
 	if handshakeStatus != messages.HandshakeStatusCompleted {
@@ -437,8 +758,8 @@ func handleEncryptedMessage(client *Client, wsMsg *messages.WSMessage) error {
 		return sendErrorToClient(client, "encrypted", "handshake_error", "握手未完成")
 	}
 
-	if encryptor == nil {
-		return sendErrorToClient(client, "encrypted", "encryptor_error", "加密器未初始化")
+	if session == nil {
+		return sendErrorToClient(client, "encrypted", "encryptor_error", "加密会话未初始化")
 	}
 
 	// 解析加密消息
@@ -447,9 +768,14 @@ func handleEncryptedMessage(client *Client, wsMsg *messages.WSMessage) error {
 		return sendErrorToClient(client, "encrypted", "parse_error", "加密消息解析失败")
 	}
 
-	// 解密消息
-	decryptedData, err := encryptor.DecryptMessage(encryptedMsg.Payload, encryptedMsg.Nonce)
+	// 解密消息，重复或超出窗口的消息直接丢弃
+	decryptedData, err := session.Open(&encryptedMsg)
 	if err != nil {
+		if errors.Is(err, errs.ErrReplayDetected) || errors.Is(err, errs.ErrMessageOutOfWindow) || errors.Is(err, errs.ErrInvalidEpoch) {
+			logger.Logger.Warn("丢弃重放或乱序的加密消息", "error", err, "device_id", client.DeviceID,
+				"seq", encryptedMsg.Seq, "epoch", encryptedMsg.Epoch)
+			return nil
+		}
 		logger.Logger.Error("解密消息失败", "error", err, "device_id", client.DeviceID)
 		return sendErrorToClient(client, "encrypted", "decrypt_error", "消息解密失败")
 	}
@@ -461,13 +787,13 @@ func handleEncryptedMessage(client *Client, wsMsg *messages.WSMessage) error {
 	}
 
 	// 递归处理解密后的消息
-	return dispatchMessage(client, &decryptedWSMsg)
+	return dispatchMessage(client, &decryptedWSMsg, true)
 }
 
 // sendEncryptedMessage 发送加密消息
 func sendEncryptedMessage(client *Client, msgType string, data interface{}) error {
 	client.mu.RLock()
-	encryptor := client.Encryptor
+	session := client.Session
 	handshakeStatus := client.HandshakeStatus
 	client.mu.RUnlock()
 
@@ -476,8 +802,8 @@ func sendEncryptedMessage(client *Client, msgType string, data interface{}) erro
 		return fmt.Errorf("握手未完成")
 	}
 
-	if encryptor == nil {
-		return fmt.Errorf("加密器未初始化")
+	if session == nil {
+		return fmt.Errorf("加密会话未初始化")
 	}
 
 	// 创建原始消息
@@ -493,35 +819,37 @@ func sendEncryptedMessage(client *Client, msgType string, data interface{}) erro
 		return err
 	}
 
-	// 加密消息
-	encryptedPayload, nonce, err := encryptor.EncryptMessage(originalData)
+	// 加密消息，分配序列号并在需要时轮换密钥
+	encryptedMsg, err := session.Seal(originalData)
 	if err != nil {
 		return err
 	}
 
-	// 创建加密消息
-	encryptedMsg := &messages.EncryptedMessage{
-		Payload: encryptedPayload,
-		Nonce:   nonce,
-	}
-
 	// 发送加密消息
 	return wsutil.SendMessageToChannel(client.Send, "encrypted", encryptedMsg)
 }
 
-// sendMessageToClient 发送消息到客户端（支持加密）
+// sendMessageToClient 按加密策略发送消息到客户端，需要加密的消息在握手完成前不会以明文发出
 func sendMessageToClient(client *Client, msgType string, data interface{}) error {
 	client.mu.RLock()
-	encryptor := client.Encryptor
+	session := client.Session
 	handshakeStatus := client.HandshakeStatus
 	client.mu.RUnlock()
 
-	// 检查是否需要加密
-	if handshakeStatus == messages.HandshakeStatusCompleted && encryptor != nil {
-		return sendEncryptedMessage(client, msgType, data)
+	encrypted := handshakeStatus == messages.HandshakeStatusCompleted && session != nil
+
+	switch messages.GetEncryptionPolicy(msgType) {
+	case messages.PlaintextOnly:
+		return wsutil.SendMessageToChannel(client.Send, msgType, data)
+	case messages.EncryptionRequired:
+		if !encrypted {
+			return fmt.Errorf("%w: %s", errs.ErrMessageNotEncrypted, msgType)
+		}
 	}
 
-	// 直接发送未加密消息
+	if encrypted {
+		return sendEncryptedMessage(client, msgType, data)
+	}
 	return wsutil.SendMessageToChannel(client.Send, msgType, data)
 }
 
@@ -0,0 +1,159 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/hang666/EasyUKey/sdk/consts"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+	"github.com/hang666/EasyUKey/shared/pkg/wsutil"
+)
+
+const testOnceKey = "test-once-key"
+
+// createTestDevice 创建已绑定用户的设备组和组内已激活的设备，设备组的OnceKey为 testOnceKey
+func createTestDevice(t *testing.T) (*entity.DeviceGroup, *entity.Device) {
+	t.Helper()
+	user := &entity.User{Username: "alice", IsActive: true}
+	if err := global.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	group := &entity.DeviceGroup{
+		Name:        "group",
+		UserID:      &user.ID,
+		IsActive:    true,
+		OnceKeyHash: global.Keyring.Hash(testOnceKey),
+	}
+	if err := global.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	device := &entity.Device{
+		Name:               "device",
+		DeviceGroupID:      &group.ID,
+		SerialNumber:       "SN1",
+		VolumeSerialNumber: "VOL1",
+		IsActive:           true,
+	}
+	if err := global.DB.Create(device).Error; err != nil {
+		t.Fatal(err)
+	}
+	return group, device
+}
+
+// connectionMessage 返回设备的连接消息
+func connectionMessage(device *entity.Device, onceKey string) messages.DeviceConnectionMessage {
+	return messages.DeviceConnectionMessage{
+		SerialNumber:       device.SerialNumber,
+		VolumeSerialNumber: device.VolumeSerialNumber,
+		OnceKey:            onceKey,
+		Fingerprint:        &messages.DeviceFingerprint{VendorID: "0781", ProductID: "5581", Capacity: 32 << 30},
+	}
+}
+
+// connectionResponse 读取设备连接成功的响应
+func connectionResponse(t *testing.T, peer *testPeer) messages.DeviceConnectionResponseMessage {
+	t.Helper()
+	msg := peer.receive()
+	if msg.Type != "device_connection_response" {
+		t.Fatalf("消息类型 = %s，应为 device_connection_response，内容 %v", msg.Type, msg.Data)
+	}
+	resp, err := wsutil.ParseMessage[messages.DeviceConnectionResponseMessage](msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// connectionErrorCode 读取设备连接失败的错误码
+func connectionErrorCode(t *testing.T, peer *testPeer) string {
+	t.Helper()
+	msg := peer.receive()
+	if msg.Type != "device_connection" {
+		t.Fatalf("消息类型 = %s，应为 device_connection", msg.Type)
+	}
+	data, err := wsutil.ParseMessage[map[string]interface{}](msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := data["error_code"].(string)
+	return code
+}
+
+func TestDeviceConnectionRequiresGroupOnceKey(t *testing.T) {
+	cases := map[string]struct {
+		onceKey string
+		detail  string
+	}{
+		"OnceKey错误":  {"wrong-once-key", "现有设备连接时出示的OnceKey与设备组不匹配"},
+		"未出示OnceKey": {"", "现有设备连接时未出示OnceKey"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestDB(t)
+			group, device := createTestDevice(t)
+
+			peer := newTestPeer(t)
+			peer.send("device_connection", connectionMessage(device, tc.onceKey))
+
+			if code := connectionErrorCode(t, peer); code != "once_key_mismatch" {
+				t.Fatalf("错误码 = %q，应为 once_key_mismatch", code)
+			}
+			if peer.registered() {
+				t.Fatal("OnceKey不匹配的连接不应完成注册")
+			}
+
+			var events []entity.SecurityEvent
+			if err := global.DB.Where("device_group_id = ?", group.ID).Find(&events).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || events[0].Type != consts.SecurityEventOnceKeyMismatch || events[0].Detail != tc.detail {
+				t.Errorf("安全事件 = %+v", events)
+			}
+			var quarantined entity.DeviceGroup
+			global.DB.First(&quarantined, group.ID)
+			if quarantined.Quarantined {
+				t.Error("单次OnceKey不匹配不应隔离设备组")
+			}
+		})
+	}
+}
+
+func TestDeviceConnectionAndResume(t *testing.T) {
+	setupTestDB(t)
+	_, device := createTestDevice(t)
+
+	peer := newTestPeer(t)
+	peer.send("device_connection", connectionMessage(device, testOnceKey))
+	resp := connectionResponse(t, peer)
+	if !resp.Success || resp.Status != messages.DeviceStatusConnected || resp.ResumeToken == "" {
+		t.Fatalf("连接响应 = %+v，应连接成功并附带会话恢复令牌", resp)
+	}
+	if !peer.registered() {
+		t.Fatal("出示正确OnceKey的连接应完成注册")
+	}
+
+	// 恢复会话由令牌证明身份，不需要OnceKey，但必须上报硬件指纹
+	connMsg := connectionMessage(device, "")
+	reconnect := messages.DeviceReconnectMessage{
+		SerialNumber:       connMsg.SerialNumber,
+		VolumeSerialNumber: connMsg.VolumeSerialNumber,
+		ResumeToken:        resp.ResumeToken,
+	}
+	peer = newTestPeer(t)
+	peer.send("device_reconnect", reconnect)
+	if resp := connectionResponse(t, peer); resp.Status != messages.DeviceStatusResumeRejected {
+		t.Fatalf("未上报指纹的恢复响应状态 = %s，应为 resume_rejected", resp.Status)
+	}
+
+	reconnect.Fingerprint = connMsg.Fingerprint
+	peer = newTestPeer(t)
+	peer.send("device_reconnect", reconnect)
+	resumed := connectionResponse(t, peer)
+	if !resumed.Success || resumed.Status != messages.DeviceStatusResumed {
+		t.Fatalf("恢复响应 = %+v，应恢复会话", resumed)
+	}
+	if !peer.registered() {
+		t.Fatal("恢复会话的连接应完成注册")
+	}
+}
@@ -19,6 +19,7 @@ import (
 type Client struct {
 	// 连接基本信息
 	Conn     *websocket.Conn
+	RemoteIP string // 客户端IP，经过反向代理时取自转发头
 	UserID   uint
 	DeviceID uint
 	Send     chan []byte
@@ -32,9 +33,16 @@ type Client struct {
 	ConnectedAt  time.Time
 	LastPongAt   time.Time
 
+	// 版本协商
+	HelloReceived   bool
+	VersionAccepted bool
+	ClientVersion   string
+	ProtocolVersion int
+	Capabilities    messages.Capabilities
+
 	// 加密相关
 	KeyExchange     *identity.KeyExchange
-	Encryptor       *identity.Encryptor
+	Session         *identity.SecureSession
 	HandshakeStatus messages.HandshakeStatus
 
 	// 锁
@@ -175,22 +183,10 @@ func (h *Hub) forceCloseClient(client *Client) {
 		Message: "设备被强制下线",
 	}
 
-	wsMsg := &messages.WSMessage{
-		Type:      "force_logout",
-		Data:      forceLogoutMsg,
-		Timestamp: time.Now(),
-	}
-
-	msgBytes, err := json.Marshal(wsMsg)
-	if err == nil {
-		// 尝试发送强制下线消息，给客户端1秒时间处理
-		select {
-		case client.Send <- msgBytes:
-			// 给客户端一点时间处理强制下线消息
-			time.Sleep(1 * time.Second)
-		default:
-			// 如果发送通道已满，直接断开连接
-		}
+	// 强制下线消息同样需要加密，握手未完成或发送通道已满时直接断开连接
+	if err := sendMessageToClient(client, "force_logout", forceLogoutMsg); err == nil {
+		// 给客户端一点时间处理强制下线消息
+		time.Sleep(1 * time.Second)
 	}
 
 	// 发送WebSocket关闭消息
@@ -274,31 +270,7 @@ func (h *Hub) SendToUser(userID uint, message []byte) error {
 		return fmt.Errorf("用户 %d 未在线", userID)
 	}
 
-	// 检查是否需要加密
-	client.mu.RLock()
-	encryptor := client.Encryptor
-	handshakeStatus := client.HandshakeStatus
-	client.mu.RUnlock()
-
-	if handshakeStatus == messages.HandshakeStatusCompleted && encryptor != nil {
-		// 需要加密发送
-		// 先解析原始消息
-		var wsMsg messages.WSMessage
-		if err := json.Unmarshal(message, &wsMsg); err != nil {
-			return fmt.Errorf("解析消息失败: %v", err)
-		}
-
-		// 使用加密发送
-		return sendEncryptedMessage(client, wsMsg.Type, wsMsg.Data)
-	}
-
-	// 直接发送未加密消息
-	select {
-	case client.Send <- message:
-		return nil
-	default:
-		return fmt.Errorf("发送消息失败，客户端发送通道已满")
-	}
+	return sendRawMessage(client, message)
 }
 
 // SendToDevice 向指定设备发送消息
@@ -311,31 +283,17 @@ func (h *Hub) SendToDevice(deviceID uint, message []byte) error {
 		return fmt.Errorf("设备 %d 未在线", deviceID)
 	}
 
-	// 检查是否需要加密
-	client.mu.RLock()
-	encryptor := client.Encryptor
-	handshakeStatus := client.HandshakeStatus
-	client.mu.RUnlock()
-
-	if handshakeStatus == messages.HandshakeStatusCompleted && encryptor != nil {
-		// 需要加密发送
-		// 先解析原始消息
-		var wsMsg messages.WSMessage
-		if err := json.Unmarshal(message, &wsMsg); err != nil {
-			return fmt.Errorf("解析消息失败: %v", err)
-		}
+	return sendRawMessage(client, message)
+}
 
-		// 使用加密发送
-		return sendEncryptedMessage(client, wsMsg.Type, wsMsg.Data)
+// sendRawMessage 解析已序列化的消息并按加密策略发送，握手未完成时不会回退为明文
+func sendRawMessage(client *Client, message []byte) error {
+	var wsMsg messages.WSMessage
+	if err := json.Unmarshal(message, &wsMsg); err != nil {
+		return fmt.Errorf("解析消息失败: %v", err)
 	}
 
-	// 直接发送未加密消息
-	select {
-	case client.Send <- message:
-		return nil
-	default:
-		return fmt.Errorf("发送消息失败，客户端发送通道已满")
-	}
+	return sendMessageToClient(client, wsMsg.Type, wsMsg.Data)
 }
 
 // GetUserClient 获取用户的客户端连接
@@ -372,6 +330,18 @@ func (h *Hub) IsUserOnline(userID uint) bool {
 	return exists
 }
 
+// GetUserDeviceID 获取用户当前连接的设备ID
+func (h *Hub) GetUserDeviceID(userID uint) (uint, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	client, exists := h.userClients[userID]
+	if !exists {
+		return 0, false
+	}
+	return client.DeviceID, true
+}
+
 // 实现WSHubInterface接口的新方法
 
 // IsDeviceOnline 检查设备是否在线
@@ -430,6 +400,9 @@ func (h *Hub) OnDeviceConnect(deviceID uint) error {
 
 // OnDeviceDisconnect 设备断开连接时的回调
 func (h *Hub) OnDeviceDisconnect(deviceID uint) error {
+	// 设备被禁用、删除或强制下线后不允许凭旧令牌恢复会话
+	resumeTokens.RevokeDevice(deviceID)
+
 	h.mu.RLock()
 	client, exists := h.deviceClients[deviceID]
 	h.mu.RUnlock()
@@ -450,6 +423,22 @@ func (h *Hub) OnDeviceDisconnect(deviceID uint) error {
 	return nil
 }
 
+// removeDevice 设备被拔出时立即解除设备和用户的连接映射，后续请求不会再路由到该连接
+// 连接本身由读取循环结束时注销
+func (h *Hub) removeDevice(client *Client) {
+	h.mu.Lock()
+	if c, ok := h.deviceClients[client.DeviceID]; ok && c == client {
+		delete(h.deviceClients, client.DeviceID)
+	}
+	if c, ok := h.userClients[client.UserID]; ok && c == client {
+		delete(h.userClients, client.UserID)
+	}
+	h.mu.Unlock()
+
+	resumeTokens.RevokeDevice(client.DeviceID)
+	GlobalStatusSync.SetDeviceOffline(client.DeviceID)
+}
+
 func (h *Hub) syncHeartbeat(deviceID uint) error {
 	// 使用新的状态同步管理器
 	GlobalStatusSync.UpdateHeartbeat(deviceID)
@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/hang666/EasyUKey/server/internal/config"
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/server/internal/initialize"
+	"github.com/hang666/EasyUKey/shared/pkg/identity"
+	"github.com/hang666/EasyUKey/shared/pkg/keyring"
+	"github.com/hang666/EasyUKey/shared/pkg/logger"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+	"github.com/hang666/EasyUKey/shared/pkg/wsutil"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	os.Exit(m.Run())
+}
+
+// setupTestDB 使用临时目录中的SQLite数据库替换 global.DB 并迁移全部表结构，同时设置测试所需的配置和密钥环
+// 测试结束时恢复原来的全局状态
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)"),
+		&gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := keyring.New("test", map[string][]byte{"test": bytes.Repeat([]byte{7}, keyring.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevDB, prevConfig, prevKeyring := global.DB, global.Config, global.Keyring
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		global.DB, global.Config, global.Keyring = prevDB, prevConfig, prevKeyring
+	})
+
+	global.DB = db
+	global.Keyring = ring
+	global.Config = &config.Config{
+		Security:    config.SecurityConfig{EncryptionKey: "test-encryption-key"},
+		Fingerprint: config.FingerprintConfig{AcceptThreshold: 80, ReviewThreshold: 50},
+		WebSocket:   config.WebSocketConfig{ResumeWindow: time.Minute},
+	}
+
+	if err := initialize.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testPeer 模拟已完成握手的客户端，读取服务端发送到 Client.Send 的加密消息
+type testPeer struct {
+	t       *testing.T
+	client  *Client
+	session *identity.SecureSession
+}
+
+// newTestPeer 创建已完成握手的服务端连接和对应的客户端加密会话
+func newTestPeer(t *testing.T) *testPeer {
+	t.Helper()
+	key := bytes.Repeat([]byte{9}, 32)
+	serverSession, err := identity.NewSecureSession(key, identity.RoleServer, identity.RekeyPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientSession, err := identity.NewSecureSession(key, identity.RoleClient, identity.RekeyPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{
+		Send:            make(chan []byte, 16),
+		Session:         serverSession,
+		HandshakeStatus: messages.HandshakeStatusCompleted,
+	}
+	return &testPeer{t: t, client: client, session: clientSession}
+}
+
+// send 将消息交给服务端处理
+func (p *testPeer) send(msgType string, data interface{}) {
+	p.t.Helper()
+	if err := dispatchMessage(p.client, &messages.WSMessage{Type: msgType, Data: data}, true); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// receive 解密服务端发送的下一条消息
+func (p *testPeer) receive() *messages.WSMessage {
+	p.t.Helper()
+	var raw []byte
+	select {
+	case raw = <-p.client.Send:
+	default:
+		p.t.Fatal("服务端未发送消息")
+	}
+
+	var outer messages.WSMessage
+	if err := json.Unmarshal(raw, &outer); err != nil {
+		p.t.Fatal(err)
+	}
+	encrypted, err := wsutil.ParseMessage[messages.EncryptedMessage](&outer)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	plain, err := p.session.Open(&encrypted)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	var msg messages.WSMessage
+	if err := json.Unmarshal(plain, &msg); err != nil {
+		p.t.Fatal(err)
+	}
+	return &msg
+}
+
+// registered 返回连接是否已完成设备注册
+func (p *testPeer) registered() bool {
+	p.client.mu.RLock()
+	defer p.client.mu.RUnlock()
+	return p.client.IsRegistered
+}
@@ -3,22 +3,39 @@ package ws
 import (
 	"fmt"
 
+	"github.com/hang666/EasyUKey/server/internal/global"
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
 	"github.com/hang666/EasyUKey/shared/pkg/logger"
 	"github.com/hang666/EasyUKey/shared/pkg/messages"
 )
 
-// dispatchMessage 分派消息到对应的处理函数
-func dispatchMessage(client *Client, wsMsg *messages.WSMessage) error {
+// dispatchMessage 分派消息到对应的处理函数，encrypted表示消息是否来自加密信封
+func dispatchMessage(client *Client, wsMsg *messages.WSMessage, encrypted bool) error {
+	if err := checkMessagePolicy(client, wsMsg.Type, encrypted); err != nil {
+		return err
+	}
+
 	switch wsMsg.Type {
 	case "key_exchange_request":
 		return handleKeyExchangeRequest(client, wsMsg)
 	case "encrypted":
 		return handleEncryptedMessage(client, wsMsg)
+	case "hello":
+		return handleHello(client, wsMsg)
 	case "device_connection":
+		if err := checkClientVersion(client, wsMsg.Type); err != nil {
+			return err
+		}
 		return handleDeviceConnection(client, wsMsg)
 	case "device_reconnect":
+		if err := checkClientVersion(client, wsMsg.Type); err != nil {
+			return err
+		}
 		return handleDeviceReconnect(client, wsMsg)
 	case "device_init_request":
+		if err := checkClientVersion(client, wsMsg.Type); err != nil {
+			return err
+		}
 		return handleDeviceInit(client, wsMsg)
 	case "auth_response":
 		return handleAuthResponse(client, wsMsg)
@@ -26,12 +43,62 @@ func dispatchMessage(client *Client, wsMsg *messages.WSMessage) error {
 		return handleOnceKeyUpdate(client, wsMsg)
 	case "device_status_response":
 		return handleDeviceStatus(client, wsMsg)
+	case "device_status":
+		return handleDeviceStatusReport(client, wsMsg)
 	case "ping":
 		return handlePing(client, wsMsg)
 	case "pong":
 		return handlePong(client, wsMsg)
 	default:
-		logger.Logger.Warn("收到未知消息类型", "type", wsMsg.Type, "device_id", client.DeviceID)
-		return sendErrorToClient(client, wsMsg.Type, "unknown_message", fmt.Sprintf("未知的消息类型: %s", wsMsg.Type))
+		// 忽略未知消息类型，以兼容更新版本的客户端
+		logger.Logger.Debug("忽略未知消息类型", "type", wsMsg.Type, "device_id", client.DeviceID)
+		return nil
+	}
+}
+
+// checkMessagePolicy 按加密策略表检查消息，握手完成前只允许密钥交换和心跳
+func checkMessagePolicy(client *Client, msgType string, encrypted bool) error {
+	client.mu.RLock()
+	handshakeStatus := client.HandshakeStatus
+	client.mu.RUnlock()
+
+	if handshakeStatus != messages.HandshakeStatusCompleted && !messages.AllowedBeforeHandshake(msgType) {
+		logger.Logger.Warn("握手完成前收到不允许的消息，关闭连接",
+			"type", msgType, "remote_addr", client.Conn.RemoteAddr().String())
+		client.Conn.Close()
+		return fmt.Errorf("%w: %s", errs.ErrHandshakeRequired, msgType)
+	}
+
+	if err := messages.CheckEncryptionPolicy(msgType, encrypted); err != nil {
+		logger.Logger.Warn("丢弃违反加密策略的消息", "type", msgType, "encrypted", encrypted, "device_id", client.DeviceID)
+		return fmt.Errorf("%w: %s", err, msgType)
+	}
+
+	return nil
+}
+
+// checkClientVersion 配置了最低客户端版本时，未通过版本协商的连接不能注册设备
+func checkClientVersion(client *Client, msgType string) error {
+	minVersion := global.Config.WebSocket.MinClientVersion
+	if minVersion == "" {
+		return nil
+	}
+
+	client.mu.RLock()
+	accepted := client.VersionAccepted
+	client.mu.RUnlock()
+	if accepted {
+		return nil
+	}
+
+	sendErrorToClient(client, msgType, "upgrade_required", upgradeMessage(client.ClientVersion, minVersion))
+	return fmt.Errorf("%w: %s", errs.ErrHelloRequired, msgType)
+}
+
+// upgradeMessage 生成提示客户端升级的消息
+func upgradeMessage(clientVersion, minVersion string) string {
+	if clientVersion == "" {
+		clientVersion = "未知"
 	}
+	return fmt.Sprintf("客户端版本过低（当前 %s，最低要求 %s），请升级客户端后重试", clientVersion, minVersion)
 }
@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// resumeSession 会话恢复令牌对应的设备
+type resumeSession struct {
+	deviceID           uint
+	serialNumber       string
+	volumeSerialNumber string
+	expiresAt          time.Time
+}
+
+// resumeStore 会话恢复令牌存储
+// 设备连接成功后签发，短暂断线后客户端凭令牌重连即可恢复会话，令牌只能使用一次
+type resumeStore struct {
+	mu       sync.Mutex
+	sessions map[string]resumeSession
+}
+
+var resumeTokens = &resumeStore{sessions: make(map[string]resumeSession)}
+
+// Issue 为设备签发新的会话恢复令牌，同一设备之前的令牌作废
+func (s *resumeStore) Issue(deviceID uint, serialNumber, volumeSerialNumber string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeLocked()
+	s.revokeLocked(deviceID)
+	s.sessions[token] = resumeSession{
+		deviceID:           deviceID,
+		serialNumber:       serialNumber,
+		volumeSerialNumber: volumeSerialNumber,
+		expiresAt:          time.Now().Add(ttl),
+	}
+	return token, nil
+}
+
+// Consume 校验并消费令牌，令牌必须属于同一设备且在有效期内
+func (s *resumeStore) Consume(token, serialNumber, volumeSerialNumber string) (uint, error) {
+	if token == "" {
+		return 0, errs.ErrResumeTokenInvalid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[token]
+	if !ok {
+		return 0, errs.ErrResumeTokenInvalid
+	}
+	delete(s.sessions, token)
+
+	if time.Now().After(session.expiresAt) ||
+		subtle.ConstantTimeCompare([]byte(session.serialNumber), []byte(serialNumber)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(session.volumeSerialNumber), []byte(volumeSerialNumber)) != 1 {
+		return 0, errs.ErrResumeTokenInvalid
+	}
+	return session.deviceID, nil
+}
+
+// RevokeDevice 作废设备的所有令牌，设备被禁用、删除或强制下线时调用
+func (s *resumeStore) RevokeDevice(deviceID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeLocked(deviceID)
+}
+
+func (s *resumeStore) revokeLocked(deviceID uint) {
+	for token, session := range s.sessions {
+		if session.deviceID == deviceID {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// purgeLocked 清理过期令牌
+func (s *resumeStore) purgeLocked() {
+	now := time.Now()
+	for token, session := range s.sessions {
+		if now.After(session.expiresAt) {
+			delete(s.sessions, token)
+		}
+	}
+}
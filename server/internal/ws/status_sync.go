@@ -6,9 +6,11 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/hang666/EasyUKey/sdk/consts"
 	"github.com/hang666/EasyUKey/server/internal/config"
 	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/model/entity"
+	"github.com/hang666/EasyUKey/server/internal/service"
 	"github.com/hang666/EasyUKey/shared/pkg/logger"
 )
 
@@ -21,6 +23,10 @@ type StatusSyncManager struct {
 	updateBuffer map[uint]*DeviceStatusUpdate
 	mu           sync.RWMutex
 
+	// 已推送上线事件的设备，用于只在在线状态变化时推送事件
+	onlineDevices map[uint]struct{}
+	onlineMu      sync.Mutex
+
 	// 同步配置
 	batchSize    int
 	syncInterval time.Duration
@@ -50,6 +56,8 @@ func NewStatusSyncManager() *StatusSyncManager {
 		updates:      make(chan *DeviceStatusUpdate, 100), // 默认值，将在初始化时从配置更新
 		stop:         make(chan struct{}),
 		done:         make(chan struct{}),
+
+		onlineDevices: make(map[uint]struct{}),
 	}
 }
 
@@ -100,6 +108,47 @@ func (sm *StatusSyncManager) UpdateDeviceStatus(deviceID uint, isOnline bool) {
 		sm.syncSingleUpdate(update)
 		logger.Logger.Error("状态更新通道已满，执行直接同步", "device_id", deviceID)
 	}
+
+	sm.publishStatusChange(deviceID, isOnline)
+}
+
+// SetDeviceOffline 立即将设备标记为离线（同步写库），用于设备被拔出等需要马上生效的场景
+func (sm *StatusSyncManager) SetDeviceOffline(deviceID uint) {
+	now := time.Now()
+	update := &DeviceStatusUpdate{
+		DeviceID:      deviceID,
+		IsOnline:      false,
+		LastOfflineAt: &now,
+		UpdatedAt:     now,
+	}
+
+	// 合并到缓冲区，避免之前缓存的在线状态在批量刷新时覆盖离线状态
+	sm.addToBuffer(update)
+	sm.syncSingleUpdate(update)
+
+	sm.publishStatusChange(deviceID, false)
+}
+
+// publishStatusChange 设备在线状态发生变化时向集成方推送上线或下线事件
+// 同一次连接或断开可能多次更新状态，重复的状态不再推送
+func (sm *StatusSyncManager) publishStatusChange(deviceID uint, isOnline bool) {
+	sm.onlineMu.Lock()
+	_, wasOnline := sm.onlineDevices[deviceID]
+	if isOnline {
+		sm.onlineDevices[deviceID] = struct{}{}
+	} else {
+		delete(sm.onlineDevices, deviceID)
+	}
+	sm.onlineMu.Unlock()
+
+	if isOnline == wasOnline {
+		return
+	}
+	if isOnline {
+		service.PublishDeviceEvent(consts.EventDeviceOnline, deviceID, 0)
+	} else {
+		service.PublishDeviceEvent(consts.EventDeviceOffline, deviceID, 0)
+	}
 }
 
 // UpdateHeartbeat 更新设备心跳（异步）
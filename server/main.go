@@ -15,6 +15,7 @@ import (
 	"github.com/hang666/EasyUKey/server/internal/global"
 	"github.com/hang666/EasyUKey/server/internal/initialize"
 	"github.com/hang666/EasyUKey/server/internal/middleware"
+	"github.com/hang666/EasyUKey/server/internal/radiusd"
 	"github.com/hang666/EasyUKey/server/internal/router"
 	"github.com/hang666/EasyUKey/server/internal/service"
 	"github.com/hang666/EasyUKey/server/internal/ws"
@@ -25,6 +26,15 @@ import (
 var TemplateFS embed.FS
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			os.Exit(runBackup(os.Args[2:]))
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		}
+	}
+
 	var configPath string
 	flag.StringVar(&configPath, "config", "", "配置文件路径")
 	flag.Parse()
@@ -52,6 +62,25 @@ func main() {
 
 	go wsHub.Run()
 
+	// 认证请求依赖加密索引查找设备组，旧版本的明文密钥需在启动服务前完成加密
+	if err := service.SealPlaintextDeviceGroupSecrets(); err != nil {
+		panic("加密设备组认证密钥失败: " + err.Error())
+	}
+	service.StartKeyringReencryption()
+
+	if err := service.StartNotifications(); err != nil {
+		logger.Logger.Error("启动管理员通知失败", "error", err)
+	}
+
+	var radiusServer *radiusd.Server
+	if global.Config.RADIUS.Enabled {
+		var err error
+		radiusServer, err = radiusd.Start(&global.Config.RADIUS)
+		if err != nil {
+			panic("启动RADIUS服务失败: " + err.Error())
+		}
+	}
+
 	serverAddr := global.Config.GetServerAddr()
 	logger.Logger.Info("正在启动EasyUKey认证服务器", "address", serverAddr)
 
@@ -77,6 +106,12 @@ func main() {
 		logger.Logger.Error("服务器关闭失败", "error", err)
 	}
 
+	if radiusServer != nil {
+		radiusServer.Shutdown()
+	}
+	service.StopNotifications()
+	service.StopKeyringReencryption()
+
 	if global.DB != nil {
 		sqlDB, err := global.DB.DB()
 		if err == nil {
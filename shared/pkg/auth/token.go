@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -13,7 +14,8 @@ import (
 
 // GenerateAuthToken 生成新格式的认证token
 // 格式: {challenge}:{totpCode}:{authToken}
-// authToken = HMAC-SHA256(challenge + onceKey + serialNumber + volumeSerialNumber, encryptionKey)
+// authToken = HMAC-SHA256(challenge + onceKey + serialNumber + volumeSerialNumber, authSecret)
+// authSecret 为设备组初始化时下发的独立HMAC密钥，旧版本初始化的U盘没有该密钥时使用编译时嵌入的 encryptKeyStr
 func GenerateAuthToken(challenge, pin, encryptKeyStr, serialNumber, volumeSerialNumber, basePath string) (string, error) {
 	// 获取OnceKey
 	onceKey, err := identity.GetOnceKey(pin, encryptKeyStr, basePath)
@@ -21,6 +23,14 @@ func GenerateAuthToken(challenge, pin, encryptKeyStr, serialNumber, volumeSerial
 		return "", fmt.Errorf("获取OnceKey失败: %w", err)
 	}
 
+	// 获取HMAC密钥
+	hmacKey, err := identity.GetAuthSecret(pin, encryptKeyStr, basePath)
+	if os.IsNotExist(err) {
+		hmacKey = encryptKeyStr
+	} else if err != nil {
+		return "", fmt.Errorf("获取认证密钥失败: %w", err)
+	}
+
 	// 获取TOTP密钥并生成代码
 	totpURI, err := identity.GetTOTPSecret(pin, encryptKeyStr, basePath)
 	if err != nil {
@@ -38,17 +48,18 @@ func GenerateAuthToken(challenge, pin, encryptKeyStr, serialNumber, volumeSerial
 	}
 
 	// 生成authToken（不包含totpCode）
-	authToken := generateHMACToken(challenge, onceKey, serialNumber, volumeSerialNumber, encryptKeyStr)
+	authToken := generateHMACToken(challenge, onceKey, serialNumber, volumeSerialNumber, hmacKey)
 
 	return fmt.Sprintf("%s:%s:%s", challenge, totpCode, authToken), nil
 }
 
-// ValidateAuthToken 验证认证token
-func ValidateAuthToken(fullToken, expectedChallenge, onceKey, totpSecret, serialNumber, volumeSerialNumber, encryptKeyStr string) error {
+// ValidateAuthToken 验证认证token，依次使用 hmacKeys 中的密钥校验签名，返回匹配的密钥序号
+// 设备组的独立HMAC密钥下发后、设备确认使用前，服务端需同时接受旧的全局密钥
+func ValidateAuthToken(fullToken, expectedChallenge, onceKey, totpSecret, serialNumber, volumeSerialNumber string, hmacKeys ...string) (int, error) {
 	// 解析token格式 challenge:totpCode:authToken
 	parts := strings.Split(fullToken, ":")
 	if len(parts) != 3 {
-		return fmt.Errorf("认证token格式无效，期望3段，实际%d段", len(parts))
+		return -1, fmt.Errorf("认证token格式无效，期望3段，实际%d段", len(parts))
 	}
 
 	challenge := parts[0]
@@ -57,40 +68,41 @@ func ValidateAuthToken(fullToken, expectedChallenge, onceKey, totpSecret, serial
 
 	// 验证挑战码
 	if challenge != expectedChallenge {
-		return fmt.Errorf("挑战码不匹配")
+		return -1, fmt.Errorf("挑战码不匹配")
 	}
 
 	// 验证TOTP代码
 	totpConfig, err := identity.ParseTOTPURI(totpSecret)
 	if err != nil {
-		return fmt.Errorf("解析TOTP配置失败: %w", err)
+		return -1, fmt.Errorf("解析TOTP配置失败: %w", err)
 	}
 
 	isValidTOTP, err := identity.VerifyTOTPCode(totpConfig, totpCode, time.Now())
 	if err != nil {
-		return fmt.Errorf("验证TOTP代码失败: %w", err)
+		return -1, fmt.Errorf("验证TOTP代码失败: %w", err)
 	}
 
 	if !isValidTOTP {
-		return fmt.Errorf("TOTP验证码无效")
+		return -1, fmt.Errorf("TOTP验证码无效")
 	}
 
 	// 验证authToken
-	expectedToken := generateHMACToken(challenge, onceKey, serialNumber, volumeSerialNumber, encryptKeyStr)
-	if !hmac.Equal([]byte(authToken), []byte(expectedToken)) {
-		return fmt.Errorf("认证token验证失败")
+	for i, key := range hmacKeys {
+		expectedToken := generateHMACToken(challenge, onceKey, serialNumber, volumeSerialNumber, key)
+		if hmac.Equal([]byte(authToken), []byte(expectedToken)) {
+			return i, nil
+		}
 	}
 
-	return nil
+	return -1, fmt.Errorf("认证token验证失败")
 }
 
 // generateHMACToken 生成HMAC-SHA256 token
-func generateHMACToken(challenge, onceKey, serialNumber, volumeSerialNumber, encryptKeyStr string) string {
+func generateHMACToken(challenge, onceKey, serialNumber, volumeSerialNumber, hmacKey string) string {
 	// 组合认证数据
 	data := challenge + onceKey + serialNumber + volumeSerialNumber
 
-	// 使用encryptKeyStr作为HMAC密钥
-	h := hmac.New(sha256.New, []byte(encryptKeyStr))
+	h := hmac.New(sha256.New, []byte(hmacKey))
 	h.Write([]byte(data))
 
 	return hex.EncodeToString(h.Sum(nil))
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/identity"
+)
+
+const (
+	testPIN        = "123456"
+	testEncryptKey = "compiled-in-key"
+	testSerial     = "SN0001"
+	testVolume     = "VOL0001"
+	testOnceKey    = "once-key"
+)
+
+// initStorage 在临时目录中保存U盘密钥，返回目录和TOTP URI
+func initStorage(t *testing.T, authSecret string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	totpURI, err := identity.GenerateTOTPSecretURI("EasyUKey", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := identity.SaveInitialKeys(testPIN, testEncryptKey, testOnceKey, totpURI, authSecret, dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir, totpURI
+}
+
+func TestAuthTokenWithGroupSecret(t *testing.T) {
+	dir, totpURI := initStorage(t, "group-secret")
+
+	token, err := GenerateAuthToken("challenge", testPIN, testEncryptKey, testSerial, testVolume, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i, err := ValidateAuthToken(token, "challenge", testOnceKey, totpURI, testSerial, testVolume, "group-secret", testEncryptKey); err != nil || i != 0 {
+		t.Errorf("ValidateAuthToken = %d, %v, want 0", i, err)
+	}
+	if _, err := ValidateAuthToken(token, "challenge", testOnceKey, totpURI, testSerial, testVolume, testEncryptKey); err == nil {
+		t.Error("使用独立密钥签名的token不应通过全局密钥校验")
+	}
+}
+
+func TestAuthTokenLegacyKey(t *testing.T) {
+	// 旧版本初始化的U盘没有独立密钥，使用编译时嵌入的密钥签名
+	dir, totpURI := initStorage(t, "")
+
+	token, err := GenerateAuthToken("challenge", testPIN, testEncryptKey, testSerial, testVolume, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i, err := ValidateAuthToken(token, "challenge", testOnceKey, totpURI, testSerial, testVolume, "group-secret", testEncryptKey); err != nil || i != 1 {
+		t.Errorf("ValidateAuthToken = %d, %v, want 1", i, err)
+	}
+	if _, err := ValidateAuthToken(token, "challenge", testOnceKey, totpURI, testSerial, testVolume, "group-secret"); err == nil {
+		t.Error("迁移完成后不应再接受全局密钥签名的token")
+	}
+}
+
+func TestSaveInitialKeysClearsStaleSecret(t *testing.T) {
+	dir, _ := initStorage(t, "group-secret")
+	totpURI, _ := identity.GenerateTOTPSecretURI("EasyUKey", "test")
+	if err := identity.SaveInitialKeys(testPIN, testEncryptKey, testOnceKey, totpURI, "", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := GenerateAuthToken("challenge", testPIN, testEncryptKey, testSerial, testVolume, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := ValidateAuthToken(token, "challenge", testOnceKey, totpURI, testSerial, testVolume, testEncryptKey); err != nil || i != 0 {
+		t.Errorf("重新初始化后应使用全局密钥: %d, %v", i, err)
+	}
+}
+
+func TestValidateAuthTokenChallenge(t *testing.T) {
+	dir, totpURI := initStorage(t, "group-secret")
+	token, err := GenerateAuthToken("challenge", testPIN, testEncryptKey, testSerial, testVolume, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateAuthToken(token, "other", testOnceKey, totpURI, testSerial, testVolume, "group-secret"); err == nil {
+		t.Error("挑战码不匹配时应校验失败")
+	}
+	if _, err := ValidateAuthToken(token, "challenge", "other-once-key", totpURI, testSerial, testVolume, "group-secret"); err == nil {
+		t.Error("OnceKey不匹配时应校验失败")
+	}
+}
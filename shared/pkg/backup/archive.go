@@ -0,0 +1,325 @@
+// Package backup 实现服务端备份文件的加密容器格式
+//
+// 文件结构：魔数、长度前缀的 JSON 文件头，之后是若干加密数据块。
+// 加密密钥由运维口令经 PBKDF2-SHA256 派生，数据块使用 AES-256-GCM 加密，
+// 文件头作为每个数据块的附加认证数据，块序号和结束标记编码在 nonce 中，
+// 因此修改文件头、调换或截断数据块都会导致解密失败。
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+const (
+	// FormatVersion 当前的容器格式版本
+	FormatVersion = 1
+
+	// MinPassphraseLength 创建备份时口令的最小长度（字符数）
+	MinPassphraseLength = 12
+
+	magic = "EASYUKEY-BACKUP\n"
+
+	kdfPBKDF2SHA256 = "pbkdf2-sha256"
+	cipherAES256GCM = "aes-256-gcm"
+
+	chunkSize       = 64 * 1024
+	maxHeaderSize   = 64 * 1024
+	maxIterations   = 10_000_000
+	noncePrefixSize = 7
+	flagFinal       = 1
+)
+
+// kdfIterations 创建备份时使用的 PBKDF2 迭代次数，读取时以文件头为准
+var kdfIterations = 600_000
+
+// Header 备份文件头，不含任何敏感数据
+type Header struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	KDF         string    `json:"kdf"`
+	Iterations  int       `json:"iterations"`
+	Salt        []byte    `json:"salt"`
+	Cipher      string    `json:"cipher"`
+	ChunkSize   int       `json:"chunk_size"`
+	NoncePrefix []byte    `json:"nonce_prefix"`
+}
+
+// Writer 加密写入备份数据，必须调用 Close 写入结束块，否则文件无法通过校验
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewWriter 写入文件头并返回加密写入器
+func NewWriter(w io.Writer, passphrase string) (*Writer, error) {
+	if utf8.RuneCountInString(passphrase) < MinPassphraseLength {
+		return nil, errs.ErrBackupPassphraseTooShort
+	}
+
+	header := Header{
+		Version:     FormatVersion,
+		CreatedAt:   time.Now().UTC(),
+		KDF:         kdfPBKDF2SHA256,
+		Iterations:  kdfIterations,
+		Salt:        make([]byte, 16),
+		Cipher:      cipherAES256GCM,
+		ChunkSize:   chunkSize,
+		NoncePrefix: make([]byte, noncePrefixSize),
+	}
+	if _, err := rand.Read(header.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(header.NoncePrefix); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(passphrase, &header)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := encodeHeader(&header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		aad:    raw,
+		prefix: header.NoncePrefix,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Write 缓冲数据，满一个数据块时加密写出
+func (bw *Writer) Write(p []byte) (int, error) {
+	if bw.closed {
+		return 0, errors.New("备份写入器已关闭")
+	}
+
+	n := 0
+	for len(p) > 0 {
+		room := chunkSize - len(bw.buf)
+		take := min(room, len(p))
+		bw.buf = append(bw.buf, p[:take]...)
+		p = p[take:]
+		n += take
+
+		// 缓冲区满且还有数据时才写出，保证结束块总在 Close 中写出
+		if len(bw.buf) == chunkSize && len(p) > 0 {
+			if err := bw.flush(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close 写出剩余数据和结束块，不关闭底层写入器
+func (bw *Writer) Close() error {
+	if bw.closed {
+		return nil
+	}
+	bw.closed = true
+	return bw.flush(true)
+}
+
+// flush 加密写出缓冲区中的数据块，块格式为 标记(1) + 密文长度(4) + 密文
+func (bw *Writer) flush(final bool) error {
+	if bw.counter == ^uint32(0) {
+		return errors.New("备份数据超过容量上限")
+	}
+
+	var flag byte
+	if final {
+		flag = flagFinal
+	}
+	sealed := bw.aead.Seal(nil, chunkNonce(bw.prefix, bw.counter, flag), bw.buf, bw.aad)
+	bw.counter++
+	bw.buf = bw.buf[:0]
+
+	frame := make([]byte, 5, 5+len(sealed))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(sealed)))
+	_, err := bw.w.Write(append(frame, sealed...))
+	return err
+}
+
+// Reader 解密读取备份数据，读到结束块后返回 io.EOF，结束块之前遇到文件末尾返回 ErrBackupTruncated
+type Reader struct {
+	r       io.Reader
+	header  Header
+	aead    cipher.AEAD
+	aad     []byte
+	counter uint32
+	buf     []byte
+	final   bool
+}
+
+// NewReader 读取文件头、派生密钥并校验第一个数据块，口令错误时立即返回 ErrBackupAuthFailed
+func NewReader(r io.Reader, passphrase string) (*Reader, error) {
+	raw, header, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+
+	br := &Reader{r: r, header: *header, aead: aead, aad: raw}
+	if err := br.readChunk(); err != nil {
+		return nil, err
+	}
+	return br, nil
+}
+
+// Header 返回备份文件头
+func (br *Reader) Header() Header {
+	return br.header
+}
+
+// Read 读取解密后的数据
+func (br *Reader) Read(p []byte) (int, error) {
+	for len(br.buf) == 0 {
+		if br.final {
+			return 0, io.EOF
+		}
+		if err := br.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, br.buf)
+	br.buf = br.buf[n:]
+	return n, nil
+}
+
+// readChunk 读取并解密下一个数据块，结束块之后不允许再有数据
+func (br *Reader) readChunk() error {
+	var frame [5]byte
+	if _, err := io.ReadFull(br.r, frame[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errs.ErrBackupTruncated
+		}
+		return err
+	}
+	flag := frame[0]
+	size := binary.BigEndian.Uint32(frame[1:])
+	if flag > flagFinal || int(size) > br.header.ChunkSize+br.aead.Overhead() {
+		return errs.ErrBackupFormatInvalid
+	}
+
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(br.r, sealed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errs.ErrBackupTruncated
+		}
+		return err
+	}
+
+	plain, err := br.aead.Open(sealed[:0], chunkNonce(br.header.NoncePrefix, br.counter, flag), sealed, br.aad)
+	if err != nil {
+		return errs.ErrBackupAuthFailed
+	}
+	br.counter++
+	br.buf = plain
+
+	if flag == flagFinal {
+		br.final = true
+		var extra [1]byte
+		if n, _ := br.r.Read(extra[:]); n > 0 {
+			return errs.ErrBackupFormatInvalid
+		}
+	}
+	return nil
+}
+
+// encodeHeader 编码文件头：魔数 + 长度(4) + JSON
+func encodeHeader(header *Header) ([]byte, error) {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 0, len(magic)+4+len(data))
+	raw = append(raw, magic...)
+	raw = binary.BigEndian.AppendUint32(raw, uint32(len(data)))
+	return append(raw, data...), nil
+}
+
+// readHeader 读取并校验文件头，返回文件头原始字节用作附加认证数据
+func readHeader(r io.Reader) ([]byte, *Header, error) {
+	prefix := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, nil, errs.ErrBackupFormatInvalid
+	}
+	if !bytes.Equal(prefix[:len(magic)], []byte(magic)) {
+		return nil, nil, errs.ErrBackupFormatInvalid
+	}
+	size := binary.BigEndian.Uint32(prefix[len(magic):])
+	if size == 0 || size > maxHeaderSize {
+		return nil, nil, errs.ErrBackupFormatInvalid
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, nil, errs.ErrBackupFormatInvalid
+	}
+	var header Header
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, nil, errs.ErrBackupFormatInvalid
+	}
+
+	if header.Version != FormatVersion {
+		return nil, nil, errs.ErrBackupVersionUnsupported
+	}
+	if header.KDF != kdfPBKDF2SHA256 || header.Cipher != cipherAES256GCM ||
+		header.Iterations < 1 || header.Iterations > maxIterations ||
+		len(header.Salt) == 0 || len(header.NoncePrefix) != noncePrefixSize ||
+		header.ChunkSize < 1 || header.ChunkSize > chunkSize {
+		return nil, nil, errs.ErrBackupFormatInvalid
+	}
+
+	return append(prefix, data...), &header, nil
+}
+
+// newAEAD 由口令派生 AES-256-GCM 密钥
+func newAEAD(passphrase string, header *Header) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, header.Salt, header.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 数据块 nonce：随机前缀(7) + 块序号(4) + 结束标记(1)
+func chunkNonce(prefix []byte, counter uint32, flag byte) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	return append(nonce, flag)
+}
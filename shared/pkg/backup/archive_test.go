@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func init() {
+	// 测试中降低迭代次数，格式与正式备份一致
+	kdfIterations = 1000
+}
+
+func seal(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分多次写入，覆盖跨数据块边界的情况
+	for len(data) > 0 {
+		n := min(len(data), 10_000)
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func open(archive []byte, passphrase string) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(archive), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 123} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		archive := seal(t, data)
+		if bytes.Contains(archive, data[:min(size, 64)]) && size >= 16 {
+			t.Errorf("size %d: 备份文件中包含明文", size)
+		}
+
+		got, err := open(archive, testPassphrase)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: 解密数据不一致", size)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(seal(t, []byte("data"))), testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := r.Header()
+	if h.Version != FormatVersion || h.KDF != kdfPBKDF2SHA256 || h.Cipher != cipherAES256GCM || h.Iterations != kdfIterations {
+		t.Errorf("文件头不正确: %+v", h)
+	}
+	if h.CreatedAt.IsZero() {
+		t.Error("文件头缺少创建时间")
+	}
+}
+
+func TestPassphraseTooShort(t *testing.T) {
+	if _, err := NewWriter(io.Discard, "short"); !errors.Is(err, errs.ErrBackupPassphraseTooShort) {
+		t.Errorf("err = %v, want ErrBackupPassphraseTooShort", err)
+	}
+	// 按字符而不是字节计算长度
+	if _, err := NewWriter(io.Discard, "备份口令备份口令"); !errors.Is(err, errs.ErrBackupPassphraseTooShort) {
+		t.Errorf("err = %v, want ErrBackupPassphraseTooShort", err)
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	archive := seal(t, []byte("secret"))
+	if _, err := open(archive, "wrong passphrase!!"); !errors.Is(err, errs.ErrBackupAuthFailed) {
+		t.Errorf("err = %v, want ErrBackupAuthFailed", err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	data := make([]byte, 2*chunkSize+100)
+	rand.Read(data)
+	archive := seal(t, data)
+	headerLen := bytes.Index(archive, []byte("}")) + 1
+
+	cases := map[string]struct {
+		archive []byte
+		want    error
+	}{
+		"修改文件头":   {bytes.Replace(archive, []byte(`"created_at":"2`), []byte(`"created_at":"3`), 1), errs.ErrBackupAuthFailed},
+		"修改魔数":    {flip(archive, 0), errs.ErrBackupFormatInvalid},
+		"修改密文":    {flip(archive, len(archive)-20), errs.ErrBackupAuthFailed},
+		"截断结束块":   {archive[:len(archive)-10], errs.ErrBackupTruncated},
+		"删除结束块":   {archive[:headerLen+2*(5+chunkSize+16)], errs.ErrBackupTruncated},
+		"结束块后有数据": {append(bytes.Clone(archive), 0), errs.ErrBackupFormatInvalid},
+		"调换数据块": {
+			swapChunks(archive, headerLen),
+			errs.ErrBackupAuthFailed,
+		},
+		"空文件": {nil, errs.ErrBackupFormatInvalid},
+	}
+	for name, tc := range cases {
+		if _, err := open(tc.archive, testPassphrase); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	archive := seal(t, []byte("data"))
+	archive = bytes.Replace(archive, []byte(`"version":1`), []byte(`"version":9`), 1)
+	if _, err := open(archive, testPassphrase); !errors.Is(err, errs.ErrBackupVersionUnsupported) {
+		t.Errorf("err = %v, want ErrBackupVersionUnsupported", err)
+	}
+}
+
+func flip(data []byte, i int) []byte {
+	out := bytes.Clone(data)
+	out[i] ^= 0x01
+	return out
+}
+
+// swapChunks 交换前两个完整数据块
+func swapChunks(archive []byte, headerLen int) []byte {
+	frame := 5 + chunkSize + 16
+	out := bytes.Clone(archive)
+	first := archive[headerLen : headerLen+frame]
+	second := archive[headerLen+frame : headerLen+2*frame]
+	copy(out[headerLen:], second)
+	copy(out[headerLen+frame:], first)
+	return out
+}
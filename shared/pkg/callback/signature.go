@@ -25,6 +25,26 @@ func GenerateSignature(req *messages.CallbackRequest, secret string) string {
 		"timestamp":  strconv.FormatInt(req.Timestamp, 10),
 	}
 
+	return signFields(data, secret)
+}
+
+// GenerateEventSignature 生成事件推送签名
+func GenerateEventSignature(req *messages.EventRequest, secret string) string {
+	data := map[string]string{
+		"event_id":        req.EventID,
+		"type":            req.Type,
+		"device_id":       strconv.FormatUint(uint64(req.DeviceID), 10),
+		"device_group_id": strconv.FormatUint(uint64(req.DeviceGroupID), 10),
+		"user_id":         strconv.FormatUint(uint64(req.UserID), 10),
+		"username":        req.Username,
+		"timestamp":       strconv.FormatInt(req.Timestamp, 10),
+	}
+
+	return signFields(data, secret)
+}
+
+// signFields 按键名排序拼接字段后计算HMAC-SHA256
+func signFields(data map[string]string, secret string) string {
 	// 按字母顺序排序键值对
 	var keys []string
 	for k := range data {
@@ -56,6 +76,12 @@ func VerifySignature(req *messages.CallbackRequest, secret string) bool {
 	return hmac.Equal([]byte(originalSignature), []byte(expectedSignature))
 }
 
+// VerifyEventSignature 验证事件推送签名
+func VerifyEventSignature(req *messages.EventRequest, secret string) bool {
+	expectedSignature := GenerateEventSignature(req, secret)
+	return hmac.Equal([]byte(req.Signature), []byte(expectedSignature))
+}
+
 // ValidateCallbackRequest 验证回调请求
 func ValidateCallbackRequest(req *messages.CallbackRequest, secret string) error {
 	if req.SessionID == "" {
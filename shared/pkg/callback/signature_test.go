@@ -0,0 +1,47 @@
+package callback
+
+import (
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+func TestEventSignature(t *testing.T) {
+	req := &messages.EventRequest{
+		EventID:       "evt-1",
+		Type:          "device.online",
+		DeviceID:      7,
+		DeviceGroupID: 3,
+		UserID:        2,
+		Username:      "alice",
+		Timestamp:     1700000000,
+	}
+	req.Signature = GenerateEventSignature(req, "secret")
+
+	if !VerifyEventSignature(req, "secret") {
+		t.Fatal("签名校验失败")
+	}
+	if VerifyEventSignature(req, "other") {
+		t.Fatal("使用错误密钥时签名不应通过")
+	}
+
+	req.Type = "device.offline"
+	if VerifyEventSignature(req, "secret") {
+		t.Fatal("事件类型被篡改后签名不应通过")
+	}
+}
+
+func TestCallbackSignatureUnchanged(t *testing.T) {
+	req := &messages.CallbackRequest{
+		SessionID: "s1",
+		Username:  "1",
+		Status:    "success",
+		Challenge: "c",
+		Timestamp: 1700000000,
+	}
+	// 与拆分签名函数前计算的结果一致，保证已有集成方的回调校验不受影响
+	const want = "970a875f70b477b54bf073dc8cf8e1317e8ccd977913f3603669e4bf0d5091d4"
+	if got := GenerateSignature(req, "secret"); got != want {
+		t.Fatalf("回调签名变化: %s", got)
+	}
+}
@@ -10,9 +10,11 @@ var (
 	ErrWSChannelFull      = errors.New("发送通道已满")
 	ErrWSNotConnected     = errors.New("WebSocket未连接")
 	ErrWSConnectFailed    = errors.New("WebSocket连接失败")
+	ErrResumeTokenInvalid = errors.New("会话恢复令牌无效或已过期")
 
 	// 认证错误
-	ErrUserRejected = errors.New("用户拒绝认证")
+	ErrUserRejected    = errors.New("用户拒绝认证")
+	ErrTooManyAttempts = errors.New("尝试次数过多，请稍后再试")
 
 	// 设备错误
 	ErrDeviceNotActive     = errors.New("设备未激活")
@@ -26,6 +28,9 @@ var (
 	ErrDeviceGroupNotActive   = errors.New("设备组未激活")
 	ErrDeviceGroupNameEmpty   = errors.New("设备组名称不能为空")
 	ErrDeviceGroupPermissions = errors.New("设备组权限格式错误")
+	ErrRecoveryCodeInvalid    = errors.New("恢复码无效或已被使用")
+	ErrDeviceGroupQuarantined = errors.New("设备组疑似被克隆已被隔离，需管理员重置密钥后恢复")
+	ErrOnceKeyMismatch        = errors.New("OnceKey与设备组不匹配，连接被拒绝")
 
 	// 用户错误
 	ErrUserNotFound      = errors.New("用户不存在")
@@ -45,6 +50,13 @@ var (
 	ErrWSParse              = errors.New("消息解析失败")
 	ErrWSValidation         = errors.New("消息验证失败")
 	ErrDeviceNotFoundClient = errors.New("设备未找到")
+	ErrMessageNotEncrypted  = errors.New("该类型消息必须加密传输")
+	ErrMessageMustPlaintext = errors.New("该类型消息不能在加密通道内传输")
+	ErrHandshakeRequired    = errors.New("握手完成前不允许发送该类型消息")
+	ErrHandshakeTimeout     = errors.New("握手超时")
+	ErrInvalidVersion       = errors.New("版本号格式无效")
+	ErrClientVersionTooOld  = errors.New("客户端版本过低，请升级客户端")
+	ErrHelloRequired        = errors.New("未完成版本协商")
 
 	// 参数错误
 	ErrMissingAPIKey     = errors.New("缺少API密钥")
@@ -69,6 +81,13 @@ var (
 	ErrInvalidPaddingContent = errors.New("无效的填充内容")
 	ErrSharedKeyNotComputed  = errors.New("共享密钥未计算")
 	ErrPINOrKeyEmpty         = errors.New("PIN或密钥为空")
+	ErrInvalidHandshake      = errors.New("握手参数无效")
+	ErrServerIdentityInvalid = errors.New("服务端身份公钥无效")
+	ErrServerSignature       = errors.New("服务端握手签名验证失败")
+	ErrReplayDetected        = errors.New("检测到重复消息")
+	ErrMessageOutOfWindow    = errors.New("消息序列号超出接收窗口")
+	ErrInvalidEpoch          = errors.New("消息密钥轮次无效")
+	ErrSessionClosed         = errors.New("加密会话已关闭")
 
 	// 回调错误
 	ErrCallbackSessionIDMissing = errors.New("session_id is required")
@@ -79,6 +98,66 @@ var (
 	ErrCallbackSignatureMissing = errors.New("signature is required")
 	ErrCallbackInvalidSignature = errors.New("invalid signature")
 
+	// 通知错误
+	ErrNotificationChannelNotFound      = errors.New("通知渠道不存在")
+	ErrNotificationCategoryInvalid      = errors.New("通知类别无效")
+	ErrNotificationSubscriptionNotFound = errors.New("通知订阅不存在")
+	ErrNotificationSubscriptionInvalid  = errors.New("通知订阅的节流和摘要间隔不能为负数")
+
+	// 事件订阅错误
+	ErrEventSubscriptionNotFound   = errors.New("事件订阅不存在")
+	ErrEventSubscriptionURLInvalid = errors.New("事件接收地址无效")
+	ErrEventSubscriptionLimit      = errors.New("事件订阅数量已达上限")
+	ErrEventTypeInvalid            = errors.New("事件类型无效")
+
+	// 管理员账号错误
+	ErrAdminAccountNotFound = errors.New("管理员账号不存在")
+	ErrAdminAccountExists   = errors.New("该用户已是管理员")
+	ErrAdminSessionInvalid  = errors.New("管理员会话无效或已过期")
+	ErrAdminLoginFailed     = errors.New("管理员登录失败")
+
+	// 认证会话查询错误
+	ErrSessionTimeRangeInvalid = errors.New("时间范围无效，请使用RFC3339格式")
+	ErrSessionSortInvalid      = errors.New("不支持的排序字段")
+	ErrExportFormatInvalid     = errors.New("不支持的导出格式")
+	ErrSessionFilterInvalid    = errors.New("过滤参数格式错误，ID必须为非负整数")
+
+	// 批量导入错误
+	ErrBulkFormatInvalid    = errors.New("批量导入数据格式错误")
+	ErrBulkTooManyRows      = errors.New("批量导入数据行数超过上限")
+	ErrBulkValidationFailed = errors.New("批量导入数据校验失败，未写入任何数据")
+
+	// 备份错误
+	ErrBackupFormatInvalid      = errors.New("备份文件格式无效")
+	ErrBackupVersionUnsupported = errors.New("不支持的备份文件版本")
+	ErrBackupAuthFailed         = errors.New("备份口令错误或备份文件已被篡改")
+	ErrBackupTruncated          = errors.New("备份文件不完整")
+	ErrBackupPassphraseTooShort = errors.New("备份口令至少需要12个字符")
+	ErrBackupContentInvalid     = errors.New("备份内容校验失败")
+	ErrRestoreDatabaseNotEmpty  = errors.New("数据库不为空，拒绝覆盖")
+	ErrBackupKeyringMismatch    = errors.New("当前密钥环无法解密备份中的设备组密钥，请配置创建备份时使用的主密钥")
+
+	// 密钥环错误
+	ErrKeyringKeyInvalid    = errors.New("主密钥无效")
+	ErrKeyringKeyNotFound   = errors.New("未找到加密数据使用的主密钥")
+	ErrKeyringValueInvalid  = errors.New("加密数据格式无效")
+	ErrKeyringDecryptFailed = errors.New("解密失败，数据已损坏或主密钥不匹配")
+
+	// OIDC错误
+	ErrOIDCSigningKeyInvalid     = errors.New("OIDC签名密钥无效")
+	ErrOIDCTokenInvalid          = errors.New("令牌无效")
+	ErrOIDCClientNotFound        = errors.New("OIDC客户端不存在")
+	ErrOIDCClientAPIKeyInvalid   = errors.New("OIDC客户端必须关联有效的非管理员API密钥")
+	ErrOIDCRedirectURIInvalid    = errors.New("回调地址无效")
+	ErrOIDCAuthorizationInvalid  = errors.New("授权请求无效或已过期")
+	ErrOIDCAuthorizationRejected = errors.New("用户未批准授权请求")
+
+	// RADIUS错误
+	ErrRADIUSPacketInvalid               = errors.New("RADIUS报文格式无效")
+	ErrRADIUSAuthenticatorInvalid        = errors.New("RADIUS报文认证码校验失败")
+	ErrRADIUSMessageAuthenticatorMissing = errors.New("RADIUS请求缺少Message-Authenticator属性")
+	ErrRADIUSAuthFailed                  = errors.New("U盘认证失败")
+
 	// 其他常用错误
 	ErrConvertWSURLFailed = errors.New("转换WebSocket URL失败")
 	ErrKeyExchangeFailed  = errors.New("密钥协商失败")
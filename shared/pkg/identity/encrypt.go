@@ -6,7 +6,6 @@ import (
 	"crypto/cipher"
 	"crypto/ecdh"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
 	"io"
 
@@ -109,6 +108,7 @@ type ECDHKeyPair struct {
 // KeyExchange 密钥交换器
 type KeyExchange struct {
 	keyPair   *ECDHKeyPair
+	nonce     []byte
 	sharedKey []byte
 }
 
@@ -118,11 +118,27 @@ func NewKeyExchange() (*KeyExchange, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	// 每次握手生成新的随机数，绑定到握手记录中防止重放
+	nonce := make([]byte, HandshakeNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
 	return &KeyExchange{
 		keyPair: keyPair,
+		nonce:   nonce,
 	}, nil
 }
 
+// Destroy 清零共享密钥并丢弃临时私钥，握手结束或会话作废后调用
+func (kx *KeyExchange) Destroy() {
+	clear(kx.sharedKey)
+	clear(kx.nonce)
+	kx.sharedKey = nil
+	kx.keyPair = nil
+}
+
 // GenerateECDHKeyPair 生成 ECDH 密钥对 (使用 P-256 曲线)
 func GenerateECDHKeyPair() (*ECDHKeyPair, error) {
 	curve := ecdh.P256()
@@ -151,8 +167,13 @@ func (kx *KeyExchange) GetPublicKeyBase64() string {
 	return kx.keyPair.GetPublicKeyBase64()
 }
 
-// ComputeSharedKey 计算共享密钥
-func (kx *KeyExchange) ComputeSharedKey(peerPublicKeyBase64 string) error {
+// GetNonceBase64 获取本地握手随机数的Base64编码
+func (kx *KeyExchange) GetNonceBase64() string {
+	return base64.StdEncoding.EncodeToString(kx.nonce)
+}
+
+// ComputeSharedKey 计算共享密钥，会话密钥通过HKDF派生并绑定握手记录
+func (kx *KeyExchange) ComputeSharedKey(peerPublicKeyBase64 string, transcript []byte) error {
 	// 解码对方的公钥
 	peerPublicKeyBytes, err := base64.StdEncoding.DecodeString(peerPublicKeyBase64)
 	if err != nil {
@@ -166,15 +187,22 @@ func (kx *KeyExchange) ComputeSharedKey(peerPublicKeyBase64 string) error {
 		return err
 	}
 
+	if kx.keyPair == nil {
+		return errs.ErrInvalidHandshake
+	}
+
 	// 计算共享密钥
 	sharedSecret, err := kx.keyPair.PrivateKey.ECDH(peerPublicKey)
 	if err != nil {
 		return err
 	}
 
-	// 使用 SHA-256 派生会话密钥
-	hash := sha256.Sum256(sharedSecret)
-	kx.sharedKey = hash[:]
+	// 使用 HKDF-SHA256 派生会话密钥，盐值为握手记录摘要
+	sessionKey, err := deriveSessionKey(sharedSecret, transcript)
+	if err != nil {
+		return err
+	}
+	kx.sharedKey = sessionKey
 
 	return nil
 }
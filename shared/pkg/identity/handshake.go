@@ -0,0 +1,146 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+const (
+	// HandshakeNonceSize 握手随机数长度
+	HandshakeNonceSize = 32
+
+	// handshakeLabel 握手记录的协议标签，协议变更时需要同步修改
+	handshakeLabel = "EasyUKey-KX-v1"
+	// sessionKeyInfo HKDF派生会话密钥时使用的上下文信息
+	sessionKeyInfo = "EasyUKey session key"
+)
+
+// ServerIdentity 服务端长期身份密钥（Ed25519），用于对握手记录签名
+type ServerIdentity struct {
+	privateKey ed25519.PrivateKey
+}
+
+// GenerateServerIdentity 生成新的服务端身份密钥
+func GenerateServerIdentity() (*ServerIdentity, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &ServerIdentity{privateKey: privateKey}, nil
+}
+
+// ParseServerIdentity 从Base64编码的32字节种子解析服务端身份密钥
+func ParseServerIdentity(seedBase64 string) (*ServerIdentity, error) {
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(seedBase64))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errs.ErrServerIdentityInvalid
+	}
+	return &ServerIdentity{privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// LoadOrCreateServerIdentity 从文件加载服务端身份密钥，文件不存在时生成并保存
+// 返回值 created 表示是否为新生成的密钥
+func LoadOrCreateServerIdentity(path string) (*ServerIdentity, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id, err := ParseServerIdentity(string(data))
+		return id, false, err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+
+	id, err := GenerateServerIdentity()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := os.WriteFile(path, []byte(id.SeedBase64()+"\n"), 0600); err != nil {
+		return nil, false, err
+	}
+
+	return id, true, nil
+}
+
+// SeedBase64 获取私钥种子的Base64编码，用于持久化
+func (s *ServerIdentity) SeedBase64() string {
+	return base64.StdEncoding.EncodeToString(s.privateKey.Seed())
+}
+
+// PublicKeyBase64 获取身份公钥的Base64编码，需在客户端编译时嵌入
+func (s *ServerIdentity) PublicKeyBase64() string {
+	return base64.StdEncoding.EncodeToString(s.privateKey.Public().(ed25519.PublicKey))
+}
+
+// SignTranscript 对握手记录签名并返回Base64编码的签名
+func (s *ServerIdentity) SignTranscript(transcript []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, transcript))
+}
+
+// ParseServerPublicKey 解析Base64编码的服务端身份公钥
+func ParseServerPublicKey(publicKeyBase64 string) (ed25519.PublicKey, error) {
+	publicKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKeyBase64))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, errs.ErrServerIdentityInvalid
+	}
+	return ed25519.PublicKey(publicKey), nil
+}
+
+// VerifyTranscriptSignature 使用预置的服务端身份公钥验证握手记录签名
+func VerifyTranscriptSignature(publicKey ed25519.PublicKey, transcript []byte, signatureBase64 string) error {
+	signature, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return errs.ErrServerSignature
+	}
+	if !ed25519.Verify(publicKey, transcript, signature) {
+		return errs.ErrServerSignature
+	}
+	return nil
+}
+
+// BuildHandshakeTranscript 构造握手记录：协议标签 + 双方临时公钥和随机数
+// 各字段带长度前缀，任一字段被篡改都会导致签名验证失败和会话密钥不一致
+func BuildHandshakeTranscript(clientPublicKey, clientNonce, serverPublicKey, serverNonce string) ([]byte, error) {
+	fields := []string{clientPublicKey, clientNonce, serverPublicKey, serverNonce}
+
+	transcript := []byte(handshakeLabel)
+	for i, field := range fields {
+		raw, err := base64.StdEncoding.DecodeString(field)
+		if err != nil || len(raw) == 0 {
+			return nil, fmt.Errorf("%w: 第%d个字段解码失败", errs.ErrInvalidHandshake, i+1)
+		}
+		// 随机数长度固定
+		if (i == 1 || i == 3) && len(raw) != HandshakeNonceSize {
+			return nil, fmt.Errorf("%w: 随机数长度错误", errs.ErrInvalidHandshake)
+		}
+		transcript = binary.BigEndian.AppendUint32(transcript, uint32(len(raw)))
+		transcript = append(transcript, raw...)
+	}
+
+	return transcript, nil
+}
+
+// deriveSessionKey 使用HKDF-SHA256从ECDH共享秘密派生会话密钥
+func deriveSessionKey(sharedSecret, transcript []byte) ([]byte, error) {
+	if len(transcript) == 0 {
+		return nil, errs.ErrInvalidHandshake
+	}
+	salt := sha256.Sum256(transcript)
+	return hkdf.Key(sha256.New, sharedSecret, salt[:], sessionKeyInfo, 32)
+}
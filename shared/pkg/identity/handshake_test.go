@@ -0,0 +1,191 @@
+package identity
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// serverRespond 模拟服务端处理密钥交换请求
+func serverRespond(t *testing.T, id *ServerIdentity, req messages.KeyExchangeRequestMessage) (*KeyExchange, messages.KeyExchangeResponseMessage) {
+	t.Helper()
+
+	kx, err := NewKeyExchange()
+	if err != nil {
+		t.Fatalf("创建服务端密钥交换器失败: %v", err)
+	}
+	transcript, err := BuildHandshakeTranscript(req.PublicKey, req.Nonce, kx.GetPublicKeyBase64(), kx.GetNonceBase64())
+	if err != nil {
+		t.Fatalf("构造握手记录失败: %v", err)
+	}
+	if err := kx.ComputeSharedKey(req.PublicKey, transcript); err != nil {
+		t.Fatalf("服务端计算共享密钥失败: %v", err)
+	}
+
+	return kx, messages.KeyExchangeResponseMessage{
+		PublicKey: kx.GetPublicKeyBase64(),
+		Nonce:     kx.GetNonceBase64(),
+		Signature: id.SignTranscript(transcript),
+		Success:   true,
+	}
+}
+
+// clientFinish 模拟客户端验证服务端响应并派生会话密钥
+func clientFinish(kx *KeyExchange, pinned string, resp messages.KeyExchangeResponseMessage) error {
+	publicKey, err := ParseServerPublicKey(pinned)
+	if err != nil {
+		return err
+	}
+	transcript, err := BuildHandshakeTranscript(kx.GetPublicKeyBase64(), kx.GetNonceBase64(), resp.PublicKey, resp.Nonce)
+	if err != nil {
+		return err
+	}
+	if err := VerifyTranscriptSignature(publicKey, transcript, resp.Signature); err != nil {
+		return err
+	}
+	return kx.ComputeSharedKey(resp.PublicKey, transcript)
+}
+
+func newClientRequest(t *testing.T) (*KeyExchange, messages.KeyExchangeRequestMessage) {
+	t.Helper()
+
+	kx, err := NewKeyExchange()
+	if err != nil {
+		t.Fatalf("创建客户端密钥交换器失败: %v", err)
+	}
+	return kx, messages.KeyExchangeRequestMessage{
+		PublicKey: kx.GetPublicKeyBase64(),
+		Nonce:     kx.GetNonceBase64(),
+	}
+}
+
+func mustIdentity(t *testing.T) *ServerIdentity {
+	t.Helper()
+
+	id, err := GenerateServerIdentity()
+	if err != nil {
+		t.Fatalf("生成服务端身份失败: %v", err)
+	}
+	return id
+}
+
+func TestHandshakeSuccess(t *testing.T) {
+	server := mustIdentity(t)
+	clientKX, req := newClientRequest(t)
+	serverKX, resp := serverRespond(t, server, req)
+
+	if err := clientFinish(clientKX, server.PublicKeyBase64(), resp); err != nil {
+		t.Fatalf("正常握手失败: %v", err)
+	}
+
+	clientKey, _ := clientKX.GetSharedKey()
+	serverKey, _ := serverKX.GetSharedKey()
+	if !bytes.Equal(clientKey, serverKey) {
+		t.Fatal("双方会话密钥不一致")
+	}
+
+	// 双方可以互相解密
+	clientEnc, _ := clientKX.CreateEncryptor()
+	serverEnc, _ := serverKX.CreateEncryptor()
+	payload, nonce, err := clientEnc.EncryptMessage([]byte("hello"))
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	plain, err := serverEnc.DecryptMessage(payload, nonce)
+	if err != nil || string(plain) != "hello" {
+		t.Fatalf("解密失败: %v", err)
+	}
+}
+
+// 中间人使用自己的身份密钥签名，客户端预置的公钥无法验证
+func TestHandshakeRejectsImpostorServer(t *testing.T) {
+	server := mustIdentity(t)
+	attacker := mustIdentity(t)
+
+	clientKX, req := newClientRequest(t)
+	_, resp := serverRespond(t, attacker, req)
+
+	err := clientFinish(clientKX, server.PublicKeyBase64(), resp)
+	if !errors.Is(err, errs.ErrServerSignature) {
+		t.Fatalf("期望签名验证失败，实际: %v", err)
+	}
+}
+
+// 中间人转发真实服务端签名，但把服务端临时公钥替换成自己的
+func TestHandshakeRejectsSubstitutedServerKey(t *testing.T) {
+	server := mustIdentity(t)
+	clientKX, req := newClientRequest(t)
+	_, resp := serverRespond(t, server, req)
+
+	mitmKX, err := NewKeyExchange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.PublicKey = mitmKX.GetPublicKeyBase64()
+
+	if err := clientFinish(clientKX, server.PublicKeyBase64(), resp); !errors.Is(err, errs.ErrServerSignature) {
+		t.Fatalf("期望签名验证失败，实际: %v", err)
+	}
+}
+
+// 中间人把客户端公钥替换成自己的再转发给服务端，再把服务端的真实响应转发给客户端
+func TestHandshakeRejectsSubstitutedClientKey(t *testing.T) {
+	server := mustIdentity(t)
+	clientKX, req := newClientRequest(t)
+
+	mitmKX, err := NewKeyExchange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarded := messages.KeyExchangeRequestMessage{
+		PublicKey: mitmKX.GetPublicKeyBase64(),
+		Nonce:     req.Nonce,
+	}
+	_, resp := serverRespond(t, server, forwarded)
+
+	if err := clientFinish(clientKX, server.PublicKeyBase64(), resp); !errors.Is(err, errs.ErrServerSignature) {
+		t.Fatalf("期望签名验证失败，实际: %v", err)
+	}
+}
+
+// 重放旧的服务端响应（随机数不同）无法通过验证
+func TestHandshakeRejectsReplayedResponse(t *testing.T) {
+	server := mustIdentity(t)
+
+	_, oldReq := newClientRequest(t)
+	_, oldResp := serverRespond(t, server, oldReq)
+
+	clientKX, _ := newClientRequest(t)
+	if err := clientFinish(clientKX, server.PublicKeyBase64(), oldResp); !errors.Is(err, errs.ErrServerSignature) {
+		t.Fatalf("期望签名验证失败，实际: %v", err)
+	}
+}
+
+func TestHandshakeRejectsMalformedNonce(t *testing.T) {
+	kx, req := newClientRequest(t)
+	_, err := BuildHandshakeTranscript(req.PublicKey, "c2hvcnQ=", kx.GetPublicKeyBase64(), req.Nonce)
+	if !errors.Is(err, errs.ErrInvalidHandshake) {
+		t.Fatalf("期望握手参数无效，实际: %v", err)
+	}
+}
+
+func TestLoadOrCreateServerIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "identity.key")
+
+	created, isNew, err := LoadOrCreateServerIdentity(path)
+	if err != nil || !isNew {
+		t.Fatalf("首次生成身份失败: %v, created=%v", err, isNew)
+	}
+
+	loaded, isNew, err := LoadOrCreateServerIdentity(path)
+	if err != nil || isNew {
+		t.Fatalf("加载身份失败: %v, created=%v", err, isNew)
+	}
+	if created.PublicKeyBase64() != loaded.PublicKeyBase64() {
+		t.Fatal("重新加载后的身份公钥不一致")
+	}
+}
@@ -73,6 +73,11 @@ func SetOnceKey(pin, encryptKey, key, basePath string) error {
 	return Store(pin, encryptKey, "once", []byte(key), basePath)
 }
 
+// SetAuthSecret 存储设备组的认证token HMAC密钥
+func SetAuthSecret(pin, encryptKey, secret, basePath string) error {
+	return Store(pin, encryptKey, "auth", []byte(secret), basePath)
+}
+
 // GetTOTPSecret 获取TOTP密钥
 func GetTOTPSecret(pin, encryptKey, basePath string) (string, error) {
 	data, err := Load(pin, encryptKey, "totp", basePath)
@@ -91,12 +96,30 @@ func GetOnceKey(pin, encryptKey, basePath string) (string, error) {
 	return string(data), nil
 }
 
-// SaveInitialKeys 保存初始化密钥
-func SaveInitialKeys(pin, encryptKey, onceKey, totpURI, basePath string) error {
+// GetAuthSecret 获取设备组的认证token HMAC密钥，旧版本初始化的设备没有该密钥，返回的错误满足 os.IsNotExist
+func GetAuthSecret(pin, encryptKey, basePath string) (string, error) {
+	data, err := Load(pin, encryptKey, "auth", basePath)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SaveInitialKeys 保存初始化密钥，authSecret 为空时（服务端未下发）清除之前保存的HMAC密钥
+func SaveInitialKeys(pin, encryptKey, onceKey, totpURI, authSecret, basePath string) error {
 	if err := SetOnceKey(pin, encryptKey, onceKey, basePath); err != nil {
 		return err
 	}
-	return SetTOTPSecret(pin, encryptKey, totpURI, basePath)
+	if err := SetTOTPSecret(pin, encryptKey, totpURI, basePath); err != nil {
+		return err
+	}
+	if authSecret == "" {
+		if err := os.Remove(getKeyFilePath("auth", basePath)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return SetAuthSecret(pin, encryptKey, authSecret, basePath)
 }
 
 // IsInitialized 检查设备是否已经初始化
@@ -112,6 +135,8 @@ func getKeyFilePath(keyType string, basePath string) string {
 		filename = "t.dat" + EncryptedFileExt
 	case "once":
 		filename = "o.dat" + EncryptedFileExt
+	case "auth":
+		filename = "a.dat" + EncryptedFileExt
 	default:
 		filename = fmt.Sprintf("%s.dat%s", keyType, EncryptedFileExt)
 	}
@@ -0,0 +1,316 @@
+package identity
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+// SessionRole 加密会话中的角色，决定发送和接收方向使用的密钥
+type SessionRole int
+
+const (
+	// RoleClient 客户端
+	RoleClient SessionRole = iota
+	// RoleServer 服务端
+	RoleServer
+)
+
+const (
+	// ReplayWindowSize 接收窗口大小，允许在该范围内乱序到达
+	ReplayWindowSize = 64
+
+	directionClientToServer = "c2s"
+	directionServerToClient = "s2c"
+	rekeyInfo               = "EasyUKey rekey"
+)
+
+// RekeyPolicy 会话密钥轮换策略，任一条件满足时发送方切换到下一轮密钥
+type RekeyPolicy struct {
+	MaxMessages uint64        // 每轮密钥最多加密的消息数，0表示不限制
+	MaxAge      time.Duration // 每轮密钥的最长使用时间，0表示不限制
+}
+
+// DefaultRekeyPolicy 默认密钥轮换策略
+var DefaultRekeyPolicy = RekeyPolicy{
+	MaxMessages: 1000,
+	MaxAge:      10 * time.Minute,
+}
+
+// SecureSession 加密会话
+// 每个方向使用独立的密钥和递增序列号，方向、密钥轮次和序列号作为GCM附加数据参与认证，
+// 接收方通过滑动窗口拒绝重复或超出窗口的消息
+type SecureSession struct {
+	mu     sync.Mutex
+	policy RekeyPolicy
+
+	// 发送方向
+	sendLabel      string
+	sendAEAD       cipher.AEAD
+	sendKey        []byte
+	sendEpoch      uint32
+	sendSeq        uint64
+	sendEpochCount uint64
+	sendEpochStart time.Time
+
+	// 接收方向
+	recvLabel    string
+	recvAEAD     cipher.AEAD
+	recvKey      []byte
+	recvEpoch    uint32
+	prevRecvAEAD cipher.AEAD // 上一轮密钥，用于解密轮换前发出但乱序到达的消息
+	window       replayWindow
+}
+
+// NewSecureSession 基于握手派生的会话密钥创建加密会话
+func NewSecureSession(sessionKey []byte, role SessionRole, policy RekeyPolicy) (*SecureSession, error) {
+	if len(sessionKey) != 32 {
+		return nil, errs.ErrKeyTooShort
+	}
+
+	c2sKey, err := hkdf.Key(sha256.New, sessionKey, nil, directionClientToServer, 32)
+	if err != nil {
+		return nil, err
+	}
+	s2cKey, err := hkdf.Key(sha256.New, sessionKey, nil, directionServerToClient, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SecureSession{policy: policy, sendEpochStart: time.Now()}
+	if role == RoleClient {
+		s.sendLabel, s.sendKey = directionClientToServer, c2sKey
+		s.recvLabel, s.recvKey = directionServerToClient, s2cKey
+	} else {
+		s.sendLabel, s.sendKey = directionServerToClient, s2cKey
+		s.recvLabel, s.recvKey = directionClientToServer, c2sKey
+	}
+
+	if s.sendAEAD, err = newAEAD(s.sendKey); err != nil {
+		return nil, err
+	}
+	if s.recvAEAD, err = newAEAD(s.recvKey); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CreateSecureSession 基于共享密钥创建加密会话
+func (kx *KeyExchange) CreateSecureSession(role SessionRole, policy RekeyPolicy) (*SecureSession, error) {
+	sharedKey, err := kx.GetSharedKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewSecureSession(sharedKey, role, policy)
+}
+
+// Close 清零会话密钥，关闭后的会话不能再加密或解密
+func (s *SecureSession) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.sendKey)
+	clear(s.recvKey)
+	s.sendKey, s.recvKey = nil, nil
+	s.sendAEAD, s.recvAEAD, s.prevRecvAEAD = nil, nil, nil
+}
+
+// Seal 加密一条消息，分配下一个序列号，必要时先轮换发送密钥
+func (s *SecureSession) Seal(plainText []byte) (*messages.EncryptedMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sendAEAD == nil {
+		return nil, errs.ErrSessionClosed
+	}
+
+	if s.needRekey() {
+		if err := s.rekeySend(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.sendSeq++
+	s.sendEpochCount++
+
+	nonce := make([]byte, s.sendAEAD.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	aad := additionalData(s.sendLabel, s.sendEpoch, s.sendSeq)
+	cipherData := s.sendAEAD.Seal(nil, nonce, plainText, aad)
+
+	return &messages.EncryptedMessage{
+		Payload: base64.StdEncoding.EncodeToString(cipherData),
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Seq:     s.sendSeq,
+		Epoch:   s.sendEpoch,
+	}, nil
+}
+
+// Open 解密一条消息，拒绝重复、超出窗口或密钥轮次不匹配的消息
+func (s *SecureSession) Open(msg *messages.EncryptedMessage) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.recvAEAD == nil {
+		return nil, errs.ErrSessionClosed
+	}
+
+	if err := s.window.check(msg.Seq); err != nil {
+		return nil, err
+	}
+
+	cipherData, err := base64.StdEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(msg.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// 根据密钥轮次选择解密密钥，对端每次只会前进一轮
+	var aead cipher.AEAD
+	var nextKey []byte
+	switch {
+	case msg.Epoch == s.recvEpoch:
+		aead = s.recvAEAD
+	case msg.Epoch+1 == s.recvEpoch && s.prevRecvAEAD != nil:
+		aead = s.prevRecvAEAD
+	case msg.Epoch == s.recvEpoch+1:
+		if nextKey, err = nextEpochKey(s.recvKey); err != nil {
+			return nil, err
+		}
+		if aead, err = newAEAD(nextKey); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errs.ErrInvalidEpoch
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, errs.ErrCipherTextTooShort
+	}
+
+	aad := additionalData(s.recvLabel, msg.Epoch, msg.Seq)
+	plainText, err := aead.Open(nil, nonce, cipherData, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只有通过认证的消息才能推进接收窗口和密钥轮次
+	s.window.accept(msg.Seq)
+	if nextKey != nil {
+		s.prevRecvAEAD = s.recvAEAD
+		s.recvAEAD = aead
+		s.recvKey = nextKey
+		s.recvEpoch = msg.Epoch
+	}
+
+	return plainText, nil
+}
+
+// needRekey 判断当前发送密钥是否已用尽预算
+func (s *SecureSession) needRekey() bool {
+	if s.policy.MaxMessages > 0 && s.sendEpochCount >= s.policy.MaxMessages {
+		return true
+	}
+	if s.policy.MaxAge > 0 && time.Since(s.sendEpochStart) >= s.policy.MaxAge {
+		return true
+	}
+	return false
+}
+
+// rekeySend 轮换发送密钥，新密钥由旧密钥单向派生，对端可独立推导
+func (s *SecureSession) rekeySend() error {
+	nextKey, err := nextEpochKey(s.sendKey)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(nextKey)
+	if err != nil {
+		return err
+	}
+
+	s.sendKey = nextKey
+	s.sendAEAD = aead
+	s.sendEpoch++
+	s.sendEpochCount = 0
+	s.sendEpochStart = time.Now()
+	return nil
+}
+
+// nextEpochKey 派生下一轮密钥
+func nextEpochKey(key []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, key, nil, rekeyInfo, 32)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData 构造GCM附加数据：方向 + 密钥轮次 + 序列号
+func additionalData(direction string, epoch uint32, seq uint64) []byte {
+	aad := make([]byte, 0, len(direction)+12)
+	aad = append(aad, direction...)
+	aad = binary.BigEndian.AppendUint32(aad, epoch)
+	aad = binary.BigEndian.AppendUint64(aad, seq)
+	return aad
+}
+
+// replayWindow 滑动接收窗口，记录最近收到的序列号
+type replayWindow struct {
+	highest uint64
+	bitmap  uint64 // 第i位表示序列号 highest-i 是否已收到
+}
+
+// check 检查序列号是否可以接收
+func (w *replayWindow) check(seq uint64) error {
+	if seq == 0 {
+		return errs.ErrMessageOutOfWindow
+	}
+	if seq > w.highest {
+		return nil
+	}
+	diff := w.highest - seq
+	if diff >= ReplayWindowSize {
+		return errs.ErrMessageOutOfWindow
+	}
+	if w.bitmap&(1<<diff) != 0 {
+		return errs.ErrReplayDetected
+	}
+	return nil
+}
+
+// accept 记录已接收的序列号
+func (w *replayWindow) accept(seq uint64) {
+	if seq > w.highest {
+		shift := seq - w.highest
+		if shift >= ReplayWindowSize {
+			w.bitmap = 0
+		} else {
+			w.bitmap <<= shift
+		}
+		w.bitmap |= 1
+		w.highest = seq
+		return
+	}
+	w.bitmap |= 1 << (w.highest - seq)
+}
@@ -0,0 +1,164 @@
+package identity
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+	"github.com/hang666/EasyUKey/shared/pkg/messages"
+)
+
+func newSessionPair(t *testing.T, policy RekeyPolicy) (*SecureSession, *SecureSession) {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSecureSession(key, RoleClient, policy)
+	if err != nil {
+		t.Fatalf("创建客户端会话失败: %v", err)
+	}
+	server, err := NewSecureSession(key, RoleServer, policy)
+	if err != nil {
+		t.Fatalf("创建服务端会话失败: %v", err)
+	}
+	return client, server
+}
+
+func mustSeal(t *testing.T, s *SecureSession, text string) *messages.EncryptedMessage {
+	t.Helper()
+
+	msg, err := s.Seal([]byte(text))
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	return msg
+}
+
+func TestSessionRejectsReplay(t *testing.T) {
+	client, server := newSessionPair(t, RekeyPolicy{})
+
+	msg := mustSeal(t, client, "auth_response")
+	if _, err := server.Open(msg); err != nil {
+		t.Fatalf("首次解密失败: %v", err)
+	}
+	if _, err := server.Open(msg); !errors.Is(err, errs.ErrReplayDetected) {
+		t.Fatalf("期望检测到重放，实际: %v", err)
+	}
+}
+
+func TestSessionAcceptsReorderWithinWindow(t *testing.T) {
+	client, server := newSessionPair(t, RekeyPolicy{})
+
+	first := mustSeal(t, client, "1")
+	second := mustSeal(t, client, "2")
+
+	if _, err := server.Open(second); err != nil {
+		t.Fatalf("解密第二条消息失败: %v", err)
+	}
+	if _, err := server.Open(first); err != nil {
+		t.Fatalf("窗口内乱序消息应被接受: %v", err)
+	}
+	if _, err := server.Open(first); !errors.Is(err, errs.ErrReplayDetected) {
+		t.Fatalf("期望检测到重放，实际: %v", err)
+	}
+}
+
+func TestSessionRejectsOutOfWindow(t *testing.T) {
+	client, server := newSessionPair(t, RekeyPolicy{})
+
+	old := mustSeal(t, client, "old")
+	var latest *messages.EncryptedMessage
+	for i := 0; i < ReplayWindowSize; i++ {
+		latest = mustSeal(t, client, "filler")
+	}
+	if _, err := server.Open(latest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Open(old); !errors.Is(err, errs.ErrMessageOutOfWindow) {
+		t.Fatalf("期望超出窗口，实际: %v", err)
+	}
+}
+
+func TestSessionRejectsTamperedSequence(t *testing.T) {
+	client, server := newSessionPair(t, RekeyPolicy{})
+
+	msg := mustSeal(t, client, "payload")
+	msg.Seq += 10
+	if _, err := server.Open(msg); err == nil {
+		t.Fatal("篡改序列号的消息不应通过认证")
+	}
+}
+
+func TestSessionRejectsReflectedMessage(t *testing.T) {
+	client, _ := newSessionPair(t, RekeyPolicy{})
+
+	// 客户端发出的消息被反射回客户端，方向不同无法解密
+	msg := mustSeal(t, client, "reflected")
+	if _, err := client.Open(msg); err == nil {
+		t.Fatal("反射的消息不应通过认证")
+	}
+}
+
+func TestSessionRekeysAfterMessageBudget(t *testing.T) {
+	client, server := newSessionPair(t, RekeyPolicy{MaxMessages: 3})
+
+	var late *messages.EncryptedMessage
+	for i := 0; i < 10; i++ {
+		msg := mustSeal(t, client, "m")
+		// 保留轮换前的最后一条消息，模拟跨轮次乱序
+		if i == 2 {
+			late = msg
+			continue
+		}
+		if _, err := server.Open(msg); err != nil {
+			t.Fatalf("第%d条消息解密失败: %v", i+1, err)
+		}
+	}
+	if client.sendEpoch != 3 || server.recvEpoch != 3 {
+		t.Fatalf("密钥轮次不一致: client=%d server=%d", client.sendEpoch, server.recvEpoch)
+	}
+	if _, err := server.Open(late); !errors.Is(err, errs.ErrInvalidEpoch) {
+		t.Fatalf("过旧轮次的消息应被拒绝，实际: %v", err)
+	}
+}
+
+func TestSessionRekeysAfterTimeBudget(t *testing.T) {
+	client, server := newSessionPair(t, RekeyPolicy{MaxAge: time.Millisecond})
+
+	first := mustSeal(t, client, "first")
+	time.Sleep(5 * time.Millisecond)
+	second := mustSeal(t, client, "second")
+
+	if second.Epoch != first.Epoch+1 {
+		t.Fatalf("超时后应轮换密钥: %d -> %d", first.Epoch, second.Epoch)
+	}
+	// 轮换后先到达新轮次消息，旧轮次消息仍可在窗口内解密
+	if _, err := server.Open(second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Open(first); err != nil {
+		t.Fatalf("上一轮次的乱序消息应被接受: %v", err)
+	}
+}
+
+func TestSessionClose(t *testing.T) {
+	client, server := newSessionPair(t, RekeyPolicy{})
+	msg := mustSeal(t, client, "auth_response")
+
+	client.Close()
+	server.Close()
+
+	if _, err := client.Seal([]byte("auth_response")); !errors.Is(err, errs.ErrSessionClosed) {
+		t.Fatalf("关闭后加密应失败，实际: %v", err)
+	}
+	if _, err := server.Open(msg); !errors.Is(err, errs.ErrSessionClosed) {
+		t.Fatalf("关闭后解密应失败，实际: %v", err)
+	}
+	if client.sendKey != nil || client.recvKey != nil {
+		t.Fatal("关闭后会话密钥应被清除")
+	}
+}
@@ -0,0 +1,307 @@
+// Package keyring 实现服务端敏感字段的静态加密
+//
+// 采用信封加密：每个值使用随机生成的数据密钥以 AES-256-GCM 加密，
+// 数据密钥再由密钥环中的主密钥加密后与密文一同保存，并标记所用主密钥的ID。
+// 密钥环可同时持有多个主密钥，新数据总是使用当前主密钥加密，
+// 旧主密钥只用于解密，轮换主密钥时可在服务运行期间逐条重新加密。
+//
+// 加密后的值格式为 ek1:<主密钥ID>:<加密的数据密钥>:<密文>，后两段为无填充的 base64url 编码。
+// 需要按值查询的字段另存由主密钥派生的 HMAC-SHA256 索引，索引不可逆且不泄露原值。
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+const (
+	// KeySize 主密钥长度（字节）
+	KeySize = 32
+
+	// SealedPrefix 加密值的前缀，可用于在数据库中筛选尚未加密的旧数据
+	SealedPrefix = sealedPrefix + ":"
+
+	sealedPrefix = "ek1"
+
+	wrapInfo  = "easyukey keyring wrap"
+	indexInfo = "easyukey keyring index"
+)
+
+// keyIDPattern 主密钥ID只允许字母、数字、点、下划线和短横线，长度不超过32
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+var encoding = base64.RawURLEncoding
+
+// Keyring 主密钥集合，并发安全
+type Keyring struct {
+	active string
+	keys   map[string]*masterKey
+	order  []string // 当前主密钥在前，其余按ID排序
+}
+
+// masterKey 由主密钥派生的加密密钥和索引密钥
+type masterKey struct {
+	wrap  cipher.AEAD
+	index []byte
+}
+
+// New 创建密钥环，activeID 为加密新数据使用的主密钥，必须包含在 keys 中
+func New(activeID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("%w: 当前主密钥 %q 不在密钥环中", errs.ErrKeyringKeyInvalid, activeID)
+	}
+
+	k := &Keyring{active: activeID, keys: make(map[string]*masterKey, len(keys))}
+	for id, secret := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("%w: 主密钥ID %q 只能包含字母、数字、点、下划线和短横线，且不超过32个字符", errs.ErrKeyringKeyInvalid, id)
+		}
+		if len(secret) != KeySize {
+			return nil, fmt.Errorf("%w: 主密钥 %s 的长度必须为%d字节", errs.ErrKeyringKeyInvalid, id, KeySize)
+		}
+
+		mk, err := deriveMasterKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = mk
+		if id != activeID {
+			k.order = append(k.order, id)
+		}
+	}
+	sort.Strings(k.order)
+	k.order = append([]string{activeID}, k.order...)
+	return k, nil
+}
+
+// deriveMasterKey 由主密钥派生数据密钥的加密密钥和索引密钥，两者互不相关
+func deriveMasterKey(secret []byte) (*masterKey, error) {
+	wrapKey, err := hkdf.Key(sha256.New, secret, nil, wrapInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	indexKey, err := hkdf.Key(sha256.New, secret, nil, indexInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	wrap, err := newGCM(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return &masterKey{wrap: wrap, index: indexKey}, nil
+}
+
+// ActiveID 返回当前主密钥ID
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// Seal 使用当前主密钥加密 plaintext，context 标明数据用途（如表名、列名和记录ID），解密时必须一致
+// 空字符串原样返回，以便区分未设置的字段
+func (k *Keyring) Seal(plaintext, context string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	data, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(k.keys[k.active].wrap, dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(data, []byte(plaintext), []byte(context))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		sealedPrefix,
+		k.active,
+		encoding.EncodeToString(wrappedKey),
+		encoding.EncodeToString(ciphertext),
+	}, ":"), nil
+}
+
+// Open 解密 Seal 生成的值，context 必须与加密时一致
+func (k *Keyring) Open(sealed, context string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+
+	keyID, wrappedKey, ciphertext, err := parse(sealed)
+	if err != nil {
+		return "", err
+	}
+	mk, ok := k.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", errs.ErrKeyringKeyNotFound, keyID)
+	}
+
+	// 主密钥ID作为附加认证数据，篡改ID会导致解密失败
+	dataKey, err := open(mk.wrap, wrappedKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	data, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, ciphertext, []byte(context))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// HasKey 判断密钥环中是否有指定ID的主密钥
+func (k *Keyring) HasKey(id string) bool {
+	_, ok := k.keys[id]
+	return ok
+}
+
+// CheckKey 校验密钥环持有 sealed 使用的主密钥，且该主密钥与加密时一致
+// 只解开数据密钥，不解密数据本身，因此不需要知道数据用途
+func (k *Keyring) CheckKey(sealed string) error {
+	keyID, wrappedKey, _, err := parse(sealed)
+	if err != nil {
+		return err
+	}
+	mk, ok := k.keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", errs.ErrKeyringKeyNotFound, keyID)
+	}
+	if _, err := open(mk.wrap, wrappedKey, []byte(keyID)); err != nil {
+		return fmt.Errorf("%w: %s", err, keyID)
+	}
+	return nil
+}
+
+// NeedsReseal 判断值是否需要使用当前主密钥重新加密，包括未加密的旧数据
+func (k *Keyring) NeedsReseal(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !IsSealed(value) {
+		return true
+	}
+	keyID, _ := KeyID(value)
+	return keyID != k.active
+}
+
+// IsSealed 判断值是否为 Seal 生成的加密格式
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, SealedPrefix)
+}
+
+// KeyID 返回加密值使用的主密钥ID
+func KeyID(sealed string) (string, error) {
+	keyID, _, _, err := parse(sealed)
+	return keyID, err
+}
+
+// Hash 使用当前主密钥计算 value 的索引，空字符串返回空索引
+func (k *Keyring) Hash(value string) string {
+	if value == "" {
+		return ""
+	}
+	return hash(k.keys[k.active].index, value)
+}
+
+// Hashes 返回 value 在各主密钥下的索引，当前主密钥在前
+// 轮换期间部分数据的索引仍由旧主密钥计算，按值查询时需匹配全部索引
+func (k *Keyring) Hashes(value string) []string {
+	if value == "" {
+		return nil
+	}
+	hashes := make([]string, 0, len(k.order))
+	for _, id := range k.order {
+		hashes = append(hashes, hash(k.keys[id].index, value))
+	}
+	return hashes
+}
+
+// MatchHash 判断 value 在任一主密钥下的索引是否等于 stored
+func (k *Keyring) MatchHash(stored, value string) bool {
+	if stored == "" || value == "" {
+		return false
+	}
+	matched := false
+	for _, h := range k.Hashes(value) {
+		if hmac.Equal([]byte(h), []byte(stored)) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// hash 计算 HMAC-SHA256 索引
+func hash(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parse 拆分加密值
+func parse(sealed string) (keyID string, wrappedKey, ciphertext []byte, err error) {
+	parts := strings.Split(sealed, ":")
+	if len(parts) != 4 || parts[0] != sealedPrefix || !keyIDPattern.MatchString(parts[1]) {
+		return "", nil, nil, errs.ErrKeyringValueInvalid
+	}
+	if wrappedKey, err = encoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, errs.ErrKeyringValueInvalid
+	}
+	if ciphertext, err = encoding.DecodeString(parts[3]); err != nil {
+		return "", nil, nil, errs.ErrKeyringValueInvalid
+	}
+	return parts[1], wrappedKey, ciphertext, nil
+}
+
+// newGCM 创建 AES-256-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密并返回 nonce + 密文
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open 解密 nonce + 密文
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, errs.ErrKeyringValueInvalid
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, errs.ErrKeyringDecryptFailed
+	}
+	return plaintext, nil
+}
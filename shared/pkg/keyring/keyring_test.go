@@ -0,0 +1,183 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func mustNew(t *testing.T, activeID string, keys map[string][]byte) *Keyring {
+	t.Helper()
+	k, err := New(activeID, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSealOpen(t *testing.T) {
+	k := mustNew(t, "k1", map[string][]byte{"k1": testKey(1)})
+
+	sealed, err := k.Seal("secret-once-key", "once_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "ek1:k1:") || strings.Contains(sealed, "secret") {
+		t.Errorf("加密格式不正确: %s", sealed)
+	}
+
+	again, _ := k.Seal("secret-once-key", "once_key")
+	if again == sealed {
+		t.Error("相同明文两次加密结果相同")
+	}
+
+	got, err := k.Open(sealed, "once_key")
+	if err != nil || got != "secret-once-key" {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+
+	if _, err := k.Open(sealed, "totp_secret"); !errors.Is(err, errs.ErrKeyringDecryptFailed) {
+		t.Errorf("用途不一致时 err = %v, want ErrKeyringDecryptFailed", err)
+	}
+}
+
+func TestEmptyValue(t *testing.T) {
+	k := mustNew(t, "k1", map[string][]byte{"k1": testKey(1)})
+	if sealed, err := k.Seal("", "once_key"); sealed != "" || err != nil {
+		t.Errorf("Seal(\"\") = %q, %v", sealed, err)
+	}
+	if got, err := k.Open("", "once_key"); got != "" || err != nil {
+		t.Errorf("Open(\"\") = %q, %v", got, err)
+	}
+	if k.Hash("") != "" || k.Hashes("") != nil || k.MatchHash("", "") {
+		t.Error("空值不应产生索引")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := mustNew(t, "k1", map[string][]byte{"k1": testKey(1)})
+	sealed, _ := old.Seal("value", "ctx")
+	oldHash := old.Hash("value")
+
+	// 新主密钥生效后旧数据仍可解密和查询
+	rotated := mustNew(t, "k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)})
+	if got, err := rotated.Open(sealed, "ctx"); err != nil || got != "value" {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+	if !rotated.NeedsReseal(sealed) {
+		t.Error("旧主密钥加密的数据应需要重新加密")
+	}
+	if !rotated.MatchHash(oldHash, "value") {
+		t.Error("旧主密钥计算的索引应仍可匹配")
+	}
+	if hashes := rotated.Hashes("value"); len(hashes) != 2 || hashes[0] != rotated.Hash("value") || hashes[1] != oldHash {
+		t.Errorf("Hashes = %v", hashes)
+	}
+
+	resealed, _ := rotated.Seal("value", "ctx")
+	if id, _ := KeyID(resealed); id != "k2" || rotated.NeedsReseal(resealed) {
+		t.Errorf("重新加密后主密钥ID = %s", id)
+	}
+
+	// 移除旧主密钥后无法再解密旧数据
+	removed := mustNew(t, "k2", map[string][]byte{"k2": testKey(2)})
+	if _, err := removed.Open(sealed, "ctx"); !errors.Is(err, errs.ErrKeyringKeyNotFound) {
+		t.Errorf("err = %v, want ErrKeyringKeyNotFound", err)
+	}
+	if removed.MatchHash(oldHash, "value") {
+		t.Error("移除旧主密钥后不应匹配旧索引")
+	}
+}
+
+func TestNeedsReseal(t *testing.T) {
+	k := mustNew(t, "k1", map[string][]byte{"k1": testKey(1)})
+	sealed, _ := k.Seal("value", "ctx")
+	cases := map[string]bool{"": false, "plaintext": true, sealed: false}
+	for value, want := range cases {
+		if got := k.NeedsReseal(value); got != want {
+			t.Errorf("NeedsReseal(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	k := mustNew(t, "k1", map[string][]byte{"k1": testKey(1), "k2": testKey(2)})
+	sealed, _ := k.Seal("value", "ctx")
+	parts := strings.Split(sealed, ":")
+
+	cases := map[string]struct {
+		value string
+		want  error
+	}{
+		"修改主密钥ID": {strings.Join([]string{parts[0], "k2", parts[2], parts[3]}, ":"), errs.ErrKeyringDecryptFailed},
+		"未知主密钥ID": {strings.Join([]string{parts[0], "k9", parts[2], parts[3]}, ":"), errs.ErrKeyringKeyNotFound},
+		"修改密文":    {sealed[:len(sealed)-2] + "AA", errs.ErrKeyringDecryptFailed},
+		"缺少字段":    {strings.Join(parts[:3], ":"), errs.ErrKeyringValueInvalid},
+		"未加密的值":   {"plaintext", errs.ErrKeyringValueInvalid},
+		"密文过短":    {strings.Join([]string{parts[0], parts[1], parts[2], "AA"}, ":"), errs.ErrKeyringValueInvalid},
+	}
+	for name, tc := range cases {
+		if _, err := k.Open(tc.value, "ctx"); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	k := mustNew(t, "k1", map[string][]byte{"k1": testKey(1)})
+	sealed, _ := k.Seal("value", "ctx")
+	if err := k.CheckKey(sealed); err != nil {
+		t.Fatalf("CheckKey = %v", err)
+	}
+
+	// 主密钥ID相同但密钥不同时无法解开数据密钥
+	other := mustNew(t, "k1", map[string][]byte{"k1": testKey(2)})
+	if err := other.CheckKey(sealed); !errors.Is(err, errs.ErrKeyringDecryptFailed) {
+		t.Errorf("err = %v, want ErrKeyringDecryptFailed", err)
+	}
+	missing := mustNew(t, "k2", map[string][]byte{"k2": testKey(2)})
+	if !k.HasKey("k1") || missing.HasKey("k1") {
+		t.Error("HasKey 结果错误")
+	}
+	if err := missing.CheckKey(sealed); !errors.Is(err, errs.ErrKeyringKeyNotFound) {
+		t.Errorf("err = %v, want ErrKeyringKeyNotFound", err)
+	}
+	if err := k.CheckKey("plaintext"); !errors.Is(err, errs.ErrKeyringValueInvalid) {
+		t.Errorf("err = %v, want ErrKeyringValueInvalid", err)
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	cases := map[string]struct {
+		active string
+		keys   map[string][]byte
+	}{
+		"当前主密钥不存在": {"k2", map[string][]byte{"k1": testKey(1)}},
+		"密钥长度错误":   {"k1", map[string][]byte{"k1": testKey(1)[:16]}},
+		"ID包含冒号":   {"k:1", map[string][]byte{"k:1": testKey(1)}},
+		"ID为空":     {"", map[string][]byte{"": testKey(1)}},
+	}
+	for name, tc := range cases {
+		if _, err := New(tc.active, tc.keys); !errors.Is(err, errs.ErrKeyringKeyInvalid) {
+			t.Errorf("%s: err = %v, want ErrKeyringKeyInvalid", name, err)
+		}
+	}
+}
+
+func TestHashIndependentOfEncryption(t *testing.T) {
+	k1 := mustNew(t, "k1", map[string][]byte{"k1": testKey(1)})
+	k2 := mustNew(t, "k2", map[string][]byte{"k2": testKey(2)})
+	if k1.Hash("value") == k2.Hash("value") {
+		t.Error("不同主密钥的索引不应相同")
+	}
+	if k1.Hash("value") != k1.Hash("value") || len(k1.Hash("value")) != 64 {
+		t.Error("索引应为确定的64位十六进制字符串")
+	}
+}
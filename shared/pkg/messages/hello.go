@@ -0,0 +1,139 @@
+package messages
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// ProtocolVersion 当前WebSocket协议版本
+const ProtocolVersion = 1
+
+// 加密套件
+const (
+	// EncryptionSuiteV1 X25519密钥交换 + Ed25519服务端签名 + AES-256-GCM带序列号会话
+	EncryptionSuiteV1 = "x25519-ed25519-aes256gcm-v1"
+)
+
+// 认证令牌格式
+const (
+	// AuthTokenFormatHMACV1 {challenge}:{totpCode}:{HMAC-SHA256}
+	AuthTokenFormatHMACV1 = "hmac-sha256-v1"
+)
+
+// Capabilities 客户端或服务端支持的功能
+type Capabilities struct {
+	EncryptionSuites []string `json:"encryption_suites"`     // 支持的加密套件，按优先级排列
+	AuthTokenFormats []string `json:"auth_token_formats"`    // 支持的认证令牌格式，按优先级排列
+	Cancel           bool     `json:"cancel"`                // 是否支持取消认证请求
+	Compression      []string `json:"compression,omitempty"` // 支持的压缩算法，为空表示不压缩
+}
+
+// DefaultCapabilities 当前版本支持的功能
+func DefaultCapabilities() Capabilities {
+	return Capabilities{
+		EncryptionSuites: []string{EncryptionSuiteV1},
+		AuthTokenFormats: []string{AuthTokenFormatHMACV1},
+	}
+}
+
+// HelloMessage 客户端在握手完成后发送的版本与能力声明
+type HelloMessage struct {
+	ProtocolVersion int          `json:"protocol_version"`
+	ClientVersion   string       `json:"client_version"`
+	Capabilities    Capabilities `json:"capabilities"`
+}
+
+// HelloResponseMessage 服务端的版本协商结果
+type HelloResponseMessage struct {
+	Success          bool         `json:"success"`
+	ProtocolVersion  int          `json:"protocol_version"`             // 协商后的协议版本
+	Capabilities     Capabilities `json:"capabilities"`                 // 双方都支持的功能
+	UpgradeRequired  bool         `json:"upgrade_required,omitempty"`   // 客户端版本过低，需要升级
+	MinClientVersion string       `json:"min_client_version,omitempty"` // 服务端要求的最低客户端版本
+	Message          string       `json:"message,omitempty"`
+}
+
+// NegotiateCapabilities 计算双方都支持的功能，列表按本端的优先级排列
+func NegotiateCapabilities(local, remote Capabilities) Capabilities {
+	return Capabilities{
+		EncryptionSuites: intersect(local.EncryptionSuites, remote.EncryptionSuites),
+		AuthTokenFormats: intersect(local.AuthTokenFormats, remote.AuthTokenFormats),
+		Cancel:           local.Cancel && remote.Cancel,
+		Compression:      intersect(local.Compression, remote.Compression),
+	}
+}
+
+// NegotiateProtocolVersion 协商双方都支持的协议版本
+func NegotiateProtocolVersion(remote int) int {
+	if remote <= 0 || remote > ProtocolVersion {
+		return ProtocolVersion
+	}
+	return remote
+}
+
+func intersect(local, remote []string) []string {
+	var result []string
+	for _, l := range local {
+		for _, r := range remote {
+			if l == r {
+				result = append(result, l)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// CompareVersions 比较两个形如 1.2.3 的版本号，允许v前缀，忽略预发布和构建后缀
+// a < b 返回-1，a == b 返回0，a > b 返回1
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// ValidateVersion 检查版本号格式
+func ValidateVersion(version string) error {
+	_, err := parseVersion(version)
+	return err
+}
+
+func parseVersion(version string) ([3]int, error) {
+	var parts [3]int
+
+	v := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if v == "" || len(fields) > 3 {
+		return parts, fmt.Errorf("%w: %q", errs.ErrInvalidVersion, version)
+	}
+
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, fmt.Errorf("%w: %q", errs.ErrInvalidVersion, version)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
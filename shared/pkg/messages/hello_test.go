@@ -0,0 +1,69 @@
+package messages
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"0.0.1", "0.0.1", 0},
+		{"v1.2.0", "1.2", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-beta.1", "1.0.0", 0},
+	}
+	for _, c := range cases {
+		got, err := CompareVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("%s vs %s: %v", c.a, c.b, err)
+		}
+		if got != c.want {
+			t.Errorf("%s vs %s: 期望 %d，实际 %d", c.a, c.b, c.want, got)
+		}
+	}
+
+	for _, bad := range []string{"", "dev", "1.x", "1.2.3.4"} {
+		if _, err := CompareVersions(bad, "1.0.0"); !errors.Is(err, errs.ErrInvalidVersion) {
+			t.Errorf("%q 应为无效版本号，实际: %v", bad, err)
+		}
+	}
+}
+
+func TestNegotiateCapabilities(t *testing.T) {
+	local := Capabilities{
+		EncryptionSuites: []string{"suite-b", EncryptionSuiteV1},
+		AuthTokenFormats: []string{AuthTokenFormatHMACV1},
+		Cancel:           true,
+		Compression:      []string{"deflate"},
+	}
+	remote := Capabilities{
+		EncryptionSuites: []string{EncryptionSuiteV1, "suite-b", "suite-c"},
+		AuthTokenFormats: []string{"future-format", AuthTokenFormatHMACV1},
+		Cancel:           false,
+	}
+
+	got := NegotiateCapabilities(local, remote)
+	want := Capabilities{
+		EncryptionSuites: []string{"suite-b", EncryptionSuiteV1},
+		AuthTokenFormats: []string{AuthTokenFormatHMACV1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("协商结果不符: %+v", got)
+	}
+}
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	if v := NegotiateProtocolVersion(ProtocolVersion + 1); v != ProtocolVersion {
+		t.Errorf("更高版本的对端应协商为本端版本，实际 %d", v)
+	}
+	if v := NegotiateProtocolVersion(0); v != ProtocolVersion {
+		t.Errorf("未声明版本时应使用本端版本，实际 %d", v)
+	}
+}
@@ -0,0 +1,80 @@
+package messages
+
+import "github.com/hang666/EasyUKey/shared/pkg/errs"
+
+// EncryptionPolicy 消息类型的加密要求
+type EncryptionPolicy int
+
+const (
+	// EncryptionRequired 必须在加密通道内传输，未在策略表中登记的类型默认使用该策略
+	EncryptionRequired EncryptionPolicy = iota
+	// PlaintextOnly 只能明文传输，用于握手消息和加密信封本身
+	PlaintextOnly
+	// PlaintextAllowed 明文和加密均可，仅用于心跳
+	PlaintextAllowed
+)
+
+// messagePolicies WebSocket消息类型的加密策略表，服务端和客户端共用
+var messagePolicies = map[string]EncryptionPolicy{
+	// 握手及加密信封
+	"key_exchange_request":  PlaintextOnly,
+	"key_exchange_response": PlaintextOnly,
+	"encrypted":             PlaintextOnly,
+
+	// 心跳
+	"ping": PlaintextAllowed,
+	"pong": PlaintextAllowed,
+
+	// 版本协商
+	"hello":          EncryptionRequired,
+	"hello_response": EncryptionRequired,
+
+	// 客户端 -> 服务端
+	"device_connection":       EncryptionRequired,
+	"device_reconnect":        EncryptionRequired,
+	"device_init_request":     EncryptionRequired,
+	"auth_response":           EncryptionRequired,
+	"once_key_update_confirm": EncryptionRequired,
+	"device_status_response":  EncryptionRequired,
+	"device_status":           EncryptionRequired,
+
+	// 服务端 -> 客户端
+	"device_connection_response": EncryptionRequired,
+	"device_init_response":       EncryptionRequired,
+	"auth_request":               EncryptionRequired,
+	"auth_success_response":      EncryptionRequired,
+	"device_status_check":        EncryptionRequired,
+	"force_logout":               EncryptionRequired,
+}
+
+// GetEncryptionPolicy 获取消息类型的加密策略
+func GetEncryptionPolicy(msgType string) EncryptionPolicy {
+	if policy, ok := messagePolicies[msgType]; ok {
+		return policy
+	}
+	return EncryptionRequired
+}
+
+// CheckEncryptionPolicy 检查消息的传输方式是否符合加密策略
+func CheckEncryptionPolicy(msgType string, encrypted bool) error {
+	switch GetEncryptionPolicy(msgType) {
+	case PlaintextOnly:
+		if encrypted {
+			return errs.ErrMessageMustPlaintext
+		}
+	case EncryptionRequired:
+		if !encrypted {
+			return errs.ErrMessageNotEncrypted
+		}
+	}
+	return nil
+}
+
+// AllowedBeforeHandshake 判断消息类型是否允许在握手完成前传输
+func AllowedBeforeHandshake(msgType string) bool {
+	switch msgType {
+	case "key_exchange_request", "key_exchange_response", "ping", "pong":
+		return true
+	}
+	return false
+}
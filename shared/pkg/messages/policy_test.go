@@ -0,0 +1,46 @@
+package messages
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+func TestCheckEncryptionPolicy(t *testing.T) {
+	cases := []struct {
+		msgType   string
+		encrypted bool
+		want      error
+	}{
+		{"device_connection", false, errs.ErrMessageNotEncrypted},
+		{"device_connection", true, nil},
+		{"auth_response", false, errs.ErrMessageNotEncrypted},
+		{"device_init_request", false, errs.ErrMessageNotEncrypted},
+		{"key_exchange_request", false, nil},
+		{"key_exchange_request", true, errs.ErrMessageMustPlaintext},
+		{"encrypted", true, errs.ErrMessageMustPlaintext},
+		{"ping", false, nil},
+		{"ping", true, nil},
+		{"unknown_type", false, errs.ErrMessageNotEncrypted},
+	}
+
+	for _, c := range cases {
+		if err := CheckEncryptionPolicy(c.msgType, c.encrypted); !errors.Is(err, c.want) {
+			t.Errorf("%s (encrypted=%v): 期望 %v，实际 %v", c.msgType, c.encrypted, c.want, err)
+		}
+	}
+}
+
+func TestAllowedBeforeHandshake(t *testing.T) {
+	for _, msgType := range []string{"key_exchange_request", "ping", "pong"} {
+		if !AllowedBeforeHandshake(msgType) {
+			t.Errorf("%s 应允许在握手前传输", msgType)
+		}
+	}
+	for _, msgType := range []string{"device_connection", "device_init_request", "encrypted", "auth_response"} {
+		if AllowedBeforeHandshake(msgType) {
+			t.Errorf("%s 不应允许在握手前传输", msgType)
+		}
+	}
+}
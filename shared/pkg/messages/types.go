@@ -41,15 +41,23 @@ type DeviceInitRequestMessage struct {
 	DevicePath         string `json:"device_path"`
 	Vendor             string `json:"vendor"`
 	Model              string `json:"model"`
+	RecoveryCode       string `json:"recovery_code,omitempty"` // 使用恢复码接管原设备组
+
+	Fingerprint *DeviceFingerprint `json:"fingerprint,omitempty"` // 硬件指纹，初始化时记录
 }
 
 // DeviceInitResponseMessage 设备初始化响应消息
 type DeviceInitResponseMessage struct {
-	Success bool   `json:"success"`
-	OnceKey string `json:"once_key,omitempty"`
-	TOTPURI string `json:"totp_uri,omitempty"`
-	Error   string `json:"error,omitempty"`
-	Message string `json:"message,omitempty"`
+	Success       bool     `json:"success"`
+	OnceKey       string   `json:"once_key,omitempty"`
+	TOTPURI       string   `json:"totp_uri,omitempty"`
+	AuthSecret    string   `json:"auth_secret,omitempty"`    // 设备组的认证token HMAC密钥
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // 仅在首次初始化时下发，客户端只展示一次
+	Recovered     bool     `json:"recovered,omitempty"`      // 是否通过恢复码接管了原设备组
+	Error         string   `json:"error,omitempty"`
+	Message       string   `json:"message,omitempty"`
+	ResumeToken   string   `json:"resume_token,omitempty"` // 会话恢复令牌，同 DeviceConnectionResponseMessage
+	ResumeTTL     int      `json:"resume_ttl,omitempty"`
 }
 
 // AuthSuccessResponseMessage 认证成功响应消息
@@ -57,6 +65,7 @@ type AuthSuccessResponseMessage struct {
 	RequestID  string `json:"request_id"`
 	Success    bool   `json:"success"`
 	NewOnceKey string `json:"new_once_key,omitempty"`
+	AuthSecret string `json:"auth_secret,omitempty"` // 设备组的认证token HMAC密钥，仅在设备尚未使用该密钥认证时下发
 	Error      string `json:"error,omitempty"`
 }
 
@@ -76,17 +85,51 @@ type DeviceConnectionMessage struct {
 	DevicePath         string `json:"device_path"`
 	Vendor             string `json:"vendor"`
 	Model              string `json:"model"`
+
+	Fingerprint *DeviceFingerprint `json:"fingerprint,omitempty"` // 硬件指纹，旧版客户端不发送
+}
+
+// DeviceFingerprint 设备硬件指纹
+// 序列号和卷序列号容易伪造，且重新格式化会改变卷序列号，服务端结合这些属性计算相似度来识别设备
+type DeviceFingerprint struct {
+	VendorID        string      `json:"vendor_id,omitempty"`        // USB厂商ID（VID）
+	ProductID       string      `json:"product_id,omitempty"`       // USB产品ID（PID）
+	Capacity        uint64      `json:"capacity,omitempty"`         // 磁盘总容量（字节）
+	FilesystemUUID  string      `json:"filesystem_uuid,omitempty"`  // 文件系统UUID
+	PartitionLayout string      `json:"partition_layout,omitempty"` // 分区布局，每个分区为"起始偏移:大小"（字节），以分号分隔
+	Anchor          *AnchorFile `json:"anchor,omitempty"`           // 锚点文件元数据
+}
+
+// AnchorFile 客户端在U盘上创建的锚点文件的元数据，文件被复制到其他U盘后文件ID会变化
+type AnchorFile struct {
+	FileID    string `json:"file_id"`   // 文件系统中的文件标识（inode 或 Windows 文件索引）
+	Size      int64  `json:"size"`      // 文件大小
+	ModTime   int64  `json:"mod_time"`  // 修改时间（Unix秒）
+	Signature string `json:"signature"` // 文件内容的SHA-256摘要
 }
 
 // DeviceConnectionResponseMessage 设备连接响应消息
 type DeviceConnectionResponseMessage struct {
 	Success bool   `json:"success"`
-	Status  string `json:"status"` // connected, pending_activation
+	Status  string `json:"status"` // connected, resumed, pending_activation, resume_rejected
 	Message string `json:"message"`
 	Error   string `json:"error,omitempty"`
+
+	// 会话恢复令牌，短暂断线后通过 device_reconnect 携带该令牌即可恢复会话，无需重新进行完整的设备连接
+	ResumeToken string `json:"resume_token,omitempty"`
+	ResumeTTL   int    `json:"resume_ttl,omitempty"` // 令牌有效期（秒）
 }
 
-// DeviceStatusMessage 设备状态消息
+// 设备连接状态
+const (
+	DeviceStatusConnected         = "connected"          // 设备连接成功
+	DeviceStatusResumed           = "resumed"            // 通过会话恢复令牌重连成功
+	DeviceStatusPendingActivation = "pending_activation" // 跨平台设备等待管理员激活
+	DeviceStatusResumeRejected    = "resume_rejected"    // 会话恢复令牌无效，需要重新进行设备连接
+	DeviceStatusRemoved           = "removed"            // U盘已拔出，客户端已锁定并即将断开连接
+)
+
+// DeviceStatusMessage 设备状态消息，用于 device_status_response 和客户端主动上报的 device_status
 type DeviceStatusMessage struct {
 	Status             string `json:"status"`
 	SerialNumber       string `json:"serial_number"`
@@ -102,6 +145,9 @@ type DeviceReconnectMessage struct {
 	DevicePath         string `json:"device_path"`
 	Vendor             string `json:"vendor"`
 	Model              string `json:"model"`
+	ResumeToken        string `json:"resume_token"` // 上次连接时服务端下发的会话恢复令牌
+
+	Fingerprint *DeviceFingerprint `json:"fingerprint,omitempty"` // 硬件指纹，恢复会话时同样需要比对
 }
 
 // PingMessage 心跳请求消息
@@ -121,12 +167,15 @@ type ForceLogoutMessage struct {
 
 // KeyExchangeRequestMessage 密钥交换请求消息
 type KeyExchangeRequestMessage struct {
-	PublicKey string `json:"public_key"` // Base64编码的客户端公钥
+	PublicKey string `json:"public_key"` // Base64编码的客户端临时公钥
+	Nonce     string `json:"nonce"`      // Base64编码的客户端握手随机数
 }
 
 // KeyExchangeResponseMessage 密钥交换响应消息
 type KeyExchangeResponseMessage struct {
-	PublicKey string `json:"public_key"` // Base64编码的服务端公钥
+	PublicKey string `json:"public_key"`          // Base64编码的服务端临时公钥
+	Nonce     string `json:"nonce,omitempty"`     // Base64编码的服务端握手随机数
+	Signature string `json:"signature,omitempty"` // 服务端身份密钥对握手记录的Ed25519签名
 	Success   bool   `json:"success"`
 	Error     string `json:"error,omitempty"`
 }
@@ -135,6 +184,8 @@ type KeyExchangeResponseMessage struct {
 type EncryptedMessage struct {
 	Payload string `json:"payload"` // Base64编码的加密数据
 	Nonce   string `json:"nonce"`   // Base64编码的nonce
+	Seq     uint64 `json:"seq"`     // 发送方向的递增序列号，作为附加数据参与认证
+	Epoch   uint32 `json:"epoch"`   // 发送方向的密钥轮次，作为附加数据参与认证
 }
 
 // HandshakeStatus 握手状态
@@ -160,3 +211,15 @@ type CallbackRequest struct {
 	Timestamp int64  `json:"timestamp"`  // 回调时间戳
 	Signature string `json:"signature"`  // HMAC-SHA256签名
 }
+
+// EventRequest 集成方事件推送数据结构
+type EventRequest struct {
+	EventID       string `json:"event_id"`        // 事件ID，重试时保持不变，可用于去重
+	Type          string `json:"type"`            // 事件类型
+	DeviceID      uint   `json:"device_id"`       // 设备ID
+	DeviceGroupID uint   `json:"device_group_id"` // 设备组ID
+	UserID        uint   `json:"user_id"`         // 设备组关联的用户ID，未关联时为0
+	Username      string `json:"username"`        // 设备组关联的用户名
+	Timestamp     int64  `json:"timestamp"`       // 事件发生时间戳
+	Signature     string `json:"signature"`       // HMAC-SHA256签名
+}
@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNotifications = []Notification{
+	{Category: "clone", Title: "设备组疑似被克隆", Body: "设备组 1 已隔离", Time: time.Now()},
+	{Category: "pending", Title: "新设备等待激活", Time: time.Now()},
+}
+
+func TestWebhookChannelSignsPayload(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	ch := &WebhookChannel{URL: srv.URL, Secret: "secret"}
+	if err := ch.Send(context.Background(), testNotifications); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	r, body := <-received, <-bodies
+	if !VerifyPayload("secret", r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+		t.Fatal("签名校验失败")
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("解析请求体失败: %v", err)
+	}
+	if len(payload.Notifications) != 2 || payload.Subject != Subject(testNotifications) {
+		t.Fatalf("请求体不正确: %+v", payload)
+	}
+}
+
+func TestWebhookChannelFailsOnErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	ch := &WebhookChannel{URL: srv.URL}
+	if err := ch.Send(context.Background(), testNotifications[:1]); err == nil {
+		t.Fatal("期望非2xx响应返回错误")
+	}
+}
+
+// smtpMessage SMTP测试服务器收到的邮件
+type smtpMessage struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer 启动只支持基本命令的本地SMTP测试服务器
+func startSMTPServer(t *testing.T) (string, int, <-chan smtpMessage) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var msg smtpMessage
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "AUTH PLAIN"):
+				msg.auth = strings.TrimSpace(line[len("AUTH PLAIN"):])
+				reply("235 OK")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				msg.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				messages <- msg
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return host, port, messages
+}
+
+func TestSMTPChannelSendsMail(t *testing.T) {
+	host, port, messages := startSMTPServer(t)
+
+	ch := &SMTPChannel{
+		Host:     host,
+		Port:     port,
+		Username: "admin",
+		Password: "pass",
+		From:     "easyukey@example.com",
+		To:       []string{"ops@example.com", "sec@example.com"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ch.Send(ctx, testNotifications); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+
+	var msg smtpMessage
+	select {
+	case msg = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP测试服务器未收到邮件")
+	}
+
+	if auth, _ := base64.StdEncoding.DecodeString(msg.auth); string(auth) != "\x00admin\x00pass" {
+		t.Fatalf("认证信息不正确: %q", auth)
+	}
+	if msg.from != ch.From || len(msg.to) != 2 {
+		t.Fatalf("发件人或收件人不正确: %+v", msg)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(msg.data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != Subject(testNotifications) {
+		t.Fatalf("邮件标题不正确: %q, %v", subject, err)
+	}
+	body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, parsed.Body))
+	if err != nil {
+		t.Fatalf("解码邮件正文失败: %v", err)
+	}
+	for _, n := range testNotifications {
+		if !strings.Contains(string(body), n.Title) {
+			t.Fatalf("邮件正文缺少通知 %q: %s", n.Title, body)
+		}
+	}
+}
+
+func TestSMTPChannelRequiresRecipients(t *testing.T) {
+	ch := &SMTPChannel{Host: "127.0.0.1", Port: 25, From: "easyukey@example.com"}
+	if err := ch.Send(context.Background(), testNotifications); err == nil {
+		t.Fatal("期望未配置收件人时返回错误")
+	}
+}
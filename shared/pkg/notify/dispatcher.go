@@ -0,0 +1,288 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSendTimeout 单次发送的超时时间
+	DefaultSendTimeout = 30 * time.Second
+	// flushInterval 检查节流和摘要到期的周期
+	flushInterval = time.Second
+	// maxPending 每个订阅最多暂存的通知数，超出时丢弃最早的通知
+	maxPending = 500
+)
+
+// Subscription 订阅：把指定类别的通知发送到指定渠道
+type Subscription struct {
+	ID             string        // 唯一标识，更新订阅时据此保留节流和暂存状态
+	Channel        string        // 渠道名称
+	Categories     []string      // 订阅的类别，为空表示全部类别
+	Throttle       time.Duration // 同一类别两次即时发送的最小间隔，间隔内的通知暂存后合并发送
+	DigestInterval time.Duration // 大于0时不即时发送，按该间隔汇总发送
+}
+
+// matches 判断订阅是否包含该类别
+func (s *Subscription) matches(category string) bool {
+	return len(s.Categories) == 0 || slices.Contains(s.Categories, category)
+}
+
+// subscriptionState 订阅的发送状态
+type subscriptionState struct {
+	Subscription
+	lastSent  map[string]time.Time // 各类别上次即时发送的时间
+	lastFlush time.Time            // 上次发送摘要的时间
+	pending   []Notification
+}
+
+// batch 待发送到某个渠道的一批通知
+type batch struct {
+	channel       string
+	notifications []Notification
+}
+
+// Dispatcher 通知分发器，按订阅将通知发送到各渠道，并负责节流和摘要
+type Dispatcher struct {
+	mu            sync.Mutex
+	channels      map[string]Channel
+	subscriptions []*subscriptionState
+
+	// OnError 发送失败时调用，为空时忽略错误
+	OnError func(channel string, err error)
+	// SendTimeout 单次发送的超时时间，为0时使用 DefaultSendTimeout
+	SendTimeout time.Duration
+
+	now  func() time.Time
+	wg   sync.WaitGroup
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDispatcher 创建通知分发器
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		channels: make(map[string]Channel),
+		now:      time.Now,
+	}
+}
+
+// SetChannels 替换全部渠道
+func (d *Dispatcher) SetChannels(channels map[string]Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels = channels
+}
+
+// ChannelNames 返回已配置的渠道名称
+func (d *Dispatcher) ChannelNames() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	names := make([]string, 0, len(d.channels))
+	for name := range d.channels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SetSubscriptions 替换全部订阅，ID 不变的订阅保留节流和暂存状态
+func (d *Dispatcher) SetSubscriptions(subscriptions []Subscription) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	existing := make(map[string]*subscriptionState, len(d.subscriptions))
+	for _, state := range d.subscriptions {
+		existing[state.ID] = state
+	}
+
+	now := d.now()
+	states := make([]*subscriptionState, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		if state, ok := existing[sub.ID]; ok {
+			state.Subscription = sub
+			states = append(states, state)
+			continue
+		}
+		states = append(states, &subscriptionState{
+			Subscription: sub,
+			lastSent:     make(map[string]time.Time),
+			lastFlush:    now,
+		})
+	}
+	d.subscriptions = states
+}
+
+// Publish 发布通知，即时发送的部分异步投递，不会阻塞调用方
+func (d *Dispatcher) Publish(n Notification) {
+	d.mu.Lock()
+	now := d.now()
+	if n.Time.IsZero() {
+		n.Time = now
+	}
+
+	var batches []batch
+	for _, state := range d.subscriptions {
+		if !state.matches(n.Category) {
+			continue
+		}
+		if state.DigestInterval > 0 {
+			state.hold(n)
+			continue
+		}
+		if last, ok := state.lastSent[n.Category]; ok && state.Throttle > 0 && now.Sub(last) < state.Throttle {
+			state.hold(n)
+			continue
+		}
+		state.lastSent[n.Category] = now
+		batches = append(batches, batch{channel: state.Channel, notifications: []Notification{n}})
+	}
+	d.mu.Unlock()
+
+	d.deliver(batches)
+}
+
+// Send 直接发送到指定渠道并等待结果，不经过订阅，用于测试渠道配置
+func (d *Dispatcher) Send(ctx context.Context, channel string, notifications []Notification) error {
+	d.mu.Lock()
+	ch, ok := d.channels[channel]
+	d.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("通知渠道 %s 不存在", channel)
+	}
+	return ch.Send(ctx, notifications)
+}
+
+// Flush 发送已到期的暂存通知：摘要订阅按摘要间隔发送，节流订阅在类别的节流间隔结束后发送
+func (d *Dispatcher) Flush() {
+	d.mu.Lock()
+	now := d.now()
+
+	var batches []batch
+	for _, state := range d.subscriptions {
+		if len(state.pending) == 0 {
+			continue
+		}
+
+		if state.DigestInterval > 0 {
+			if now.Sub(state.lastFlush) < state.DigestInterval {
+				continue
+			}
+			batches = append(batches, batch{channel: state.Channel, notifications: state.pending})
+			state.pending = nil
+			state.lastFlush = now
+			continue
+		}
+
+		var due, held []Notification
+		for _, n := range state.pending {
+			if now.Sub(state.lastSent[n.Category]) >= state.Throttle {
+				due = append(due, n)
+			} else {
+				held = append(held, n)
+			}
+		}
+		if len(due) == 0 {
+			continue
+		}
+		for _, n := range due {
+			state.lastSent[n.Category] = now
+		}
+		state.pending = held
+		batches = append(batches, batch{channel: state.Channel, notifications: due})
+	}
+	d.mu.Unlock()
+
+	d.deliver(batches)
+}
+
+// Start 启动定期发送暂存通知的后台任务
+func (d *Dispatcher) Start() {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.run()
+}
+
+// Stop 停止后台任务，发送剩余的暂存通知并等待所有发送完成
+func (d *Dispatcher) Stop() {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+	}
+
+	d.mu.Lock()
+	var batches []batch
+	for _, state := range d.subscriptions {
+		if len(state.pending) > 0 {
+			batches = append(batches, batch{channel: state.Channel, notifications: state.pending})
+			state.pending = nil
+		}
+	}
+	d.mu.Unlock()
+
+	d.deliver(batches)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.Flush()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// deliver 异步发送各批通知
+func (d *Dispatcher) deliver(batches []batch) {
+	for _, b := range batches {
+		d.mu.Lock()
+		ch, ok := d.channels[b.channel]
+		d.mu.Unlock()
+		if !ok {
+			d.reportError(b.channel, fmt.Errorf("通知渠道 %s 不存在", b.channel))
+			continue
+		}
+
+		d.wg.Add(1)
+		go func(name string, ch Channel, notifications []Notification) {
+			defer d.wg.Done()
+
+			timeout := d.SendTimeout
+			if timeout <= 0 {
+				timeout = DefaultSendTimeout
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := ch.Send(ctx, notifications); err != nil {
+				d.reportError(name, err)
+			}
+		}(b.channel, ch, b.notifications)
+	}
+}
+
+func (d *Dispatcher) reportError(channel string, err error) {
+	if d.OnError != nil {
+		d.OnError(channel, err)
+	}
+}
+
+// hold 暂存通知，超出上限时丢弃最早的通知
+func (s *subscriptionState) hold(n Notification) {
+	if len(s.pending) >= maxPending {
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, n)
+}
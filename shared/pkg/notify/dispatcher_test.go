@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingChannel 记录每次发送的通知
+type recordingChannel struct {
+	mu      sync.Mutex
+	batches [][]Notification
+}
+
+func (r *recordingChannel) Send(_ context.Context, notifications []Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, notifications)
+	return nil
+}
+
+func (r *recordingChannel) sent() [][]Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]Notification(nil), r.batches...)
+}
+
+// newTestDispatcher 创建使用可控时钟的分发器
+func newTestDispatcher(subs ...Subscription) (*Dispatcher, *recordingChannel, *time.Time) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ch := &recordingChannel{}
+
+	d := NewDispatcher()
+	d.now = func() time.Time { return clock }
+	d.SetChannels(map[string]Channel{"test": ch})
+	d.SetSubscriptions(subs)
+	return d, ch, &clock
+}
+
+// settle 等待异步发送完成
+func settle(d *Dispatcher) {
+	d.wg.Wait()
+}
+
+func TestDispatcherFiltersByCategory(t *testing.T) {
+	d, ch, _ := newTestDispatcher(Subscription{ID: "1", Channel: "test", Categories: []string{"clone"}})
+
+	d.Publish(Notification{Category: "auth_failure", Title: "a"})
+	d.Publish(Notification{Category: "clone", Title: "b"})
+	settle(d)
+
+	batches := ch.sent()
+	if len(batches) != 1 || batches[0][0].Title != "b" {
+		t.Fatalf("期望只发送订阅类别的通知，实际: %+v", batches)
+	}
+}
+
+func TestDispatcherThrottleHoldsAndFlushes(t *testing.T) {
+	d, ch, clock := newTestDispatcher(Subscription{ID: "1", Channel: "test", Throttle: time.Minute})
+
+	d.Publish(Notification{Category: "clone", Title: "1"})
+	d.Publish(Notification{Category: "clone", Title: "2"})
+	d.Publish(Notification{Category: "clone", Title: "3"})
+	d.Publish(Notification{Category: "pending", Title: "other"})
+	settle(d)
+
+	if got := len(ch.sent()); got != 2 {
+		t.Fatalf("节流期内同类别只应即时发送一次，实际发送 %d 批", got)
+	}
+
+	*clock = clock.Add(30 * time.Second)
+	d.Flush()
+	settle(d)
+	if got := len(ch.sent()); got != 2 {
+		t.Fatalf("节流间隔未结束不应发送，实际发送 %d 批", got)
+	}
+
+	*clock = clock.Add(31 * time.Second)
+	d.Flush()
+	settle(d)
+	batches := ch.sent()
+	if len(batches) != 3 {
+		t.Fatalf("节流间隔结束后应合并发送暂存通知，实际发送 %d 批", len(batches))
+	}
+	if last := batches[2]; len(last) != 2 || last[0].Title != "2" || last[1].Title != "3" {
+		t.Fatalf("合并发送的通知不正确: %+v", last)
+	}
+}
+
+func TestDispatcherDigest(t *testing.T) {
+	d, ch, clock := newTestDispatcher(Subscription{ID: "1", Channel: "test", DigestInterval: time.Hour})
+
+	d.Publish(Notification{Category: "clone", Title: "1"})
+	d.Publish(Notification{Category: "pending", Title: "2"})
+	d.Flush()
+	settle(d)
+	if got := len(ch.sent()); got != 0 {
+		t.Fatalf("摘要订阅不应即时发送，实际发送 %d 批", got)
+	}
+
+	*clock = clock.Add(time.Hour)
+	d.Flush()
+	settle(d)
+	batches := ch.sent()
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("期望发送一份包含2条通知的摘要，实际: %+v", batches)
+	}
+}
+
+func TestDispatcherKeepsStateAcrossResubscribe(t *testing.T) {
+	sub := Subscription{ID: "1", Channel: "test", Throttle: time.Minute}
+	d, ch, _ := newTestDispatcher(sub)
+
+	d.Publish(Notification{Category: "clone", Title: "1"})
+	d.SetSubscriptions([]Subscription{sub})
+	d.Publish(Notification{Category: "clone", Title: "2"})
+	settle(d)
+
+	if got := len(ch.sent()); got != 1 {
+		t.Fatalf("重新设置订阅后节流状态应保留，实际发送 %d 批", got)
+	}
+}
+
+func TestDispatcherStopFlushesPending(t *testing.T) {
+	d, ch, _ := newTestDispatcher(Subscription{ID: "1", Channel: "test", DigestInterval: time.Hour})
+
+	d.Publish(Notification{Category: "clone", Title: "1"})
+	d.Stop()
+
+	if got := len(ch.sent()); got != 1 {
+		t.Fatalf("停止时应发送暂存通知，实际发送 %d 批", got)
+	}
+}
+
+func TestDispatcherReportsUnknownChannel(t *testing.T) {
+	d, _, _ := newTestDispatcher(Subscription{ID: "1", Channel: "missing"})
+
+	var reported string
+	d.OnError = func(channel string, err error) { reported = channel }
+	d.Publish(Notification{Category: "clone", Title: "1"})
+	settle(d)
+
+	if reported != "missing" {
+		t.Fatalf("期望报告不存在的渠道，实际: %q", reported)
+	}
+}
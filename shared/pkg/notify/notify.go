@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Notification 一条管理员通知
+type Notification struct {
+	Category string    `json:"category"` // 通知类别，订阅按类别过滤
+	Title    string    `json:"title"`
+	Body     string    `json:"body"`
+	Time     time.Time `json:"time"`
+}
+
+// Channel 通知渠道
+// 节流或摘要合并的多条通知在一次调用中发送
+type Channel interface {
+	Send(ctx context.Context, notifications []Notification) error
+}
+
+// Subject 生成一批通知的标题
+func Subject(notifications []Notification) string {
+	if len(notifications) == 1 {
+		return "[EasyUKey] " + notifications[0].Title
+	}
+	return fmt.Sprintf("[EasyUKey] 安全通知摘要（%d 条）", len(notifications))
+}
+
+// FormatText 将一批通知格式化为纯文本正文
+func FormatText(notifications []Notification) string {
+	var b strings.Builder
+	for i, n := range notifications {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s] %s\n", n.Time.Format("2006-01-02 15:04:05"), n.Title)
+		if n.Body != "" {
+			b.WriteString(n.Body)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
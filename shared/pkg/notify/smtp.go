@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPChannel 以邮件发送通知的渠道
+// 服务器支持 STARTTLS 时自动升级加密；ImplicitTLS 用于 465 等直接 TLS 端口
+type SMTPChannel struct {
+	Host        string
+	Port        int
+	Username    string // 为空时不进行认证
+	Password    string
+	From        string
+	To          []string
+	ImplicitTLS bool
+}
+
+// Send 发送通知邮件
+func (s *SMTPChannel) Send(ctx context.Context, notifications []Notification) error {
+	if len(s.To) == 0 {
+		return errors.New("未配置收件人")
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if s.ImplicitTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: s.Host})
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP握手失败: %w", err)
+	}
+	defer client.Close()
+
+	if !s.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+				return fmt.Errorf("SMTP启用TLS失败: %w", err)
+			}
+		}
+	}
+
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return fmt.Errorf("SMTP设置发件人失败: %w", err)
+	}
+	for _, to := range s.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP设置收件人 %s 失败: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP发送数据失败: %w", err)
+	}
+	if _, err := w.Write(s.buildMessage(notifications)); err != nil {
+		w.Close()
+		return fmt.Errorf("SMTP发送数据失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP发送数据失败: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// buildMessage 构建 UTF-8 纯文本邮件，正文使用 base64 编码
+func (s *SMTPChannel) buildMessage(notifications []Notification) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + s.From + "\r\n")
+	b.WriteString("To: " + strings.Join(s.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("utf-8", Subject(notifications)) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	b.WriteString("\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(FormatText(notifications)))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+
+	return b.Bytes()
+}
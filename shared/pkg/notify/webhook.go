@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader 通知请求签名头，内容为 HMAC-SHA256(secret, timestamp + "." + body) 的十六进制
+	SignatureHeader = "X-EasyUKey-Signature"
+	// TimestampHeader 通知请求时间戳头（Unix秒）
+	TimestampHeader = "X-EasyUKey-Timestamp"
+)
+
+// WebhookPayload Webhook渠道发送的请求体
+type WebhookPayload struct {
+	Subject       string         `json:"subject"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// WebhookChannel 以JSON POST发送通知的渠道，配置了密钥时对请求签名
+type WebhookChannel struct {
+	URL    string
+	Secret string
+	Client *http.Client // 为空时使用 http.DefaultClient
+}
+
+// Send 发送通知，非2xx响应视为失败
+func (w *WebhookChannel) Send(ctx context.Context, notifications []Notification) error {
+	body, err := json.Marshal(&WebhookPayload{
+		Subject:       Subject(notifications),
+		Notifications: notifications,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建通知请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "EasyUKey-Notifier/1.0")
+
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, SignPayload(w.Secret, timestamp, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送通知请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("通知接收方返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignPayload 计算通知请求签名
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPayload 校验通知请求签名，供接收方使用
+func VerifyPayload(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignPayload(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,232 @@
+// Package oidc 实现 OpenID Connect 提供方所需的签名与校验工具
+//
+// ID Token 使用 RS256 签名，签名密钥以 PKCS#8 PEM 格式保存，公钥通过 JWKS 发布，
+// 密钥ID（kid）取公钥的 RFC 7638 指纹，更换签名密钥后 kid 随之变化。
+// 授权码流程要求客户端使用 PKCE（S256）。
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+const (
+	// SigningAlgorithm ID Token 签名算法
+	SigningAlgorithm = "RS256"
+
+	// CodeChallengeMethod 支持的 PKCE 校验方式
+	CodeChallengeMethod = "S256"
+
+	// signingKeyBits 生成签名密钥的长度
+	signingKeyBits = 2048
+)
+
+// codeVerifierPattern PKCE code_verifier 允许的字符和长度（RFC 7636 4.1）
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+var encoding = base64.RawURLEncoding
+
+// SigningKey ID Token 签名密钥
+type SigningKey struct {
+	id         string
+	privateKey *rsa.PrivateKey
+}
+
+// JWK 公钥的 JSON Web Key 表示
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// JWKSet JWKS 端点返回的公钥集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// GenerateSigningKey 生成新的签名密钥
+func GenerateSigningKey() (*SigningKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(privateKey), nil
+}
+
+// ParseSigningKey 解析 PKCS#8 PEM 格式的 RSA 私钥
+func ParseSigningKey(pemData []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errs.ErrOIDCSigningKeyInvalid
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errs.ErrOIDCSigningKeyInvalid
+	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok || privateKey.N.BitLen() < signingKeyBits {
+		return nil, errs.ErrOIDCSigningKeyInvalid
+	}
+	return newSigningKey(privateKey), nil
+}
+
+// LoadOrCreateSigningKey 从文件加载签名密钥，文件不存在时生成并保存
+// 返回值 created 表示是否为新生成的密钥
+func LoadOrCreateSigningKey(path string) (*SigningKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := ParseSigningKey(data)
+		return key, false, err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+
+	key, err := GenerateSigningKey()
+	if err != nil {
+		return nil, false, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.privateKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, false, err
+	}
+
+	return key, true, nil
+}
+
+// newSigningKey 计算公钥指纹作为密钥ID
+func newSigningKey(privateKey *rsa.PrivateKey) *SigningKey {
+	// RFC 7638：按字典序排列必需成员的 JSON 的 SHA-256
+	pub := &privateKey.PublicKey
+	thumbprint, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: encodeExponent(pub.E), Kty: "RSA", N: encoding.EncodeToString(pub.N.Bytes())})
+	sum := sha256.Sum256(thumbprint)
+	return &SigningKey{id: encoding.EncodeToString(sum[:]), privateKey: privateKey}
+}
+
+// KeyID 返回密钥ID
+func (k *SigningKey) KeyID() string {
+	return k.id
+}
+
+// PublicKey 返回签名公钥
+func (k *SigningKey) PublicKey() *rsa.PublicKey {
+	return &k.privateKey.PublicKey
+}
+
+// JWKS 返回包含签名公钥的 JWKS
+func (k *SigningKey) JWKS() *JWKSet {
+	return &JWKSet{Keys: []JWK{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: SigningAlgorithm,
+		KeyID:     k.id,
+		N:         encoding.EncodeToString(k.privateKey.N.Bytes()),
+		E:         encodeExponent(k.privateKey.E),
+	}}}
+}
+
+// Sign 将 claims 序列化为 JSON 并生成 JWS Compact 格式的 JWT
+func (k *SigningKey) Sign(claims interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": SigningAlgorithm, "typ": "JWT", "kid": k.id})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encoding.EncodeToString(header) + "." + encoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+// Verify 校验 JWT 签名并将载荷解析到 claims，不检查过期时间等声明
+func Verify(token string, publicKey *rsa.PublicKey, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errs.ErrOIDCTokenInvalid
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	headerJSON, err := encoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerJSON, &header) != nil || header.Algorithm != SigningAlgorithm {
+		return errs.ErrOIDCTokenInvalid
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return errs.ErrOIDCTokenInvalid
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return errs.ErrOIDCTokenInvalid
+	}
+
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, claims) != nil {
+		return errs.ErrOIDCTokenInvalid
+	}
+	return nil
+}
+
+// VerifyCodeChallenge 校验 PKCE code_verifier 与授权请求中的 S256 code_challenge 是否匹配
+func VerifyCodeChallenge(verifier, challenge string) bool {
+	if !codeVerifierPattern.MatchString(verifier) || challenge == "" {
+		return false
+	}
+	return CodeChallenge(verifier) == challenge
+}
+
+// CodeChallenge 计算 code_verifier 的 S256 code_challenge
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return encoding.EncodeToString(sum[:])
+}
+
+// AccessTokenHash 计算 ID Token 的 at_hash 声明：访问令牌 SHA-256 摘要左半部分的 base64url 编码
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return encoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// encodeExponent 按 JWK 格式编码 RSA 公钥指数
+func encodeExponent(e int) string {
+	return encoding.EncodeToString(big.NewInt(int64(e)).Bytes())
+}
@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+func TestLoadOrCreateSigningKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "oidc.pem")
+
+	key, created, err := LoadOrCreateSigningKey(path)
+	if err != nil || !created {
+		t.Fatalf("首次加载应生成密钥: created=%v, err=%v", created, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("密钥文件权限应为0600: %v", err)
+	}
+
+	loaded, created, err := LoadOrCreateSigningKey(path)
+	if err != nil || created {
+		t.Fatalf("再次加载应读取已有密钥: created=%v, err=%v", created, err)
+	}
+	if loaded.KeyID() != key.KeyID() {
+		t.Error("重新加载后的密钥ID不一致")
+	}
+}
+
+func TestParseSigningKeyInvalid(t *testing.T) {
+	if _, err := ParseSigningKey([]byte("not a pem")); !errors.Is(err, errs.ErrOIDCSigningKeyInvalid) {
+		t.Errorf("err = %v, want ErrOIDCSigningKeyInvalid", err)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := key.Sign(map[string]interface{}{"sub": "42", "aud": "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := Verify(token, key.PublicKey(), &claims); err != nil {
+		t.Fatalf("签名校验失败: %v", err)
+	}
+	if claims.Subject != "42" {
+		t.Errorf("sub = %q, want 42", claims.Subject)
+	}
+
+	// 篡改载荷
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + encoding.EncodeToString([]byte(`{"sub":"1"}`)) + "." + parts[2]
+	if err := Verify(forged, key.PublicKey(), &claims); !errors.Is(err, errs.ErrOIDCTokenInvalid) {
+		t.Errorf("篡改后的令牌应校验失败: %v", err)
+	}
+
+	other, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(token, other.PublicKey(), &claims); err == nil {
+		t.Error("其他密钥不应通过校验")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := key.JWKS()
+	if len(set.Keys) != 1 {
+		t.Fatalf("JWKS 应包含1个公钥，实际 %d", len(set.Keys))
+	}
+	jwk := set.Keys[0]
+	if jwk.KeyID != key.KeyID() || jwk.Algorithm != SigningAlgorithm || jwk.KeyType != "RSA" || jwk.E != "AQAB" {
+		t.Errorf("JWK 内容不正确: %+v", jwk)
+	}
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// RFC 7636 附录B 示例
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := CodeChallenge(verifier); got != challenge {
+		t.Errorf("CodeChallenge = %q, want %q", got, challenge)
+	}
+	if !VerifyCodeChallenge(verifier, challenge) {
+		t.Error("正确的 code_verifier 应通过校验")
+	}
+	if VerifyCodeChallenge(verifier[:42], CodeChallenge(verifier[:42])) {
+		t.Error("长度不足43的 code_verifier 应被拒绝")
+	}
+	if VerifyCodeChallenge("x"+verifier[1:], challenge) {
+		t.Error("错误的 code_verifier 不应通过校验")
+	}
+}
@@ -0,0 +1,355 @@
+// Package radius 实现 RADIUS 认证（RFC 2865）所需的报文编解码和校验
+//
+// 仅包含 Access-Request/Accept/Reject 流程用到的部分：报文解析与编码、
+// User-Password 隐藏（RFC 2865 5.2）、响应认证码以及 Message-Authenticator（RFC 3579 3.2）。
+// 为防范针对 RADIUS/UDP 的响应伪造攻击（BlastRADIUS），编码的请求和响应都携带 Message-Authenticator，
+// 并将其作为第一个属性。
+package radius
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"net"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+// Code 报文类型
+type Code byte
+
+// 报文类型
+const (
+	CodeAccessRequest Code = 1
+	CodeAccessAccept  Code = 2
+	CodeAccessReject  Code = 3
+)
+
+// AttributeType 属性类型
+type AttributeType byte
+
+// 常用属性类型
+const (
+	AttrUserName             AttributeType = 1
+	AttrUserPassword         AttributeType = 2
+	AttrNASIPAddress         AttributeType = 4
+	AttrReplyMessage         AttributeType = 18
+	AttrCallingStationID     AttributeType = 31
+	AttrNASIdentifier        AttributeType = 32
+	AttrMessageAuthenticator AttributeType = 80
+)
+
+const (
+	// MaxPacketSize 报文最大长度
+	MaxPacketSize = 4096
+
+	headerSize               = 20
+	authenticatorSize        = 16
+	maxAttributeValueSize    = 253
+	messageAuthenticatorSize = md5.Size
+)
+
+// Attribute 报文属性
+type Attribute struct {
+	Type  AttributeType
+	Value []byte
+}
+
+// Packet RADIUS 报文
+type Packet struct {
+	Code          Code
+	Identifier    byte
+	Authenticator [authenticatorSize]byte
+	Attributes    []Attribute
+}
+
+// Parse 解析报文，忽略长度字段之后的填充数据
+func Parse(data []byte) (*Packet, error) {
+	if len(data) < headerSize {
+		return nil, errs.ErrRADIUSPacketInvalid
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < headerSize || length > MaxPacketSize || length > len(data) {
+		return nil, errs.ErrRADIUSPacketInvalid
+	}
+
+	p := &Packet{Code: Code(data[0]), Identifier: data[1]}
+	copy(p.Authenticator[:], data[4:headerSize])
+
+	for rest := data[headerSize:length]; len(rest) > 0; {
+		if len(rest) < 2 || int(rest[1]) < 2 || int(rest[1]) > len(rest) {
+			return nil, errs.ErrRADIUSPacketInvalid
+		}
+		value := make([]byte, int(rest[1])-2)
+		copy(value, rest[2:rest[1]])
+		p.Attributes = append(p.Attributes, Attribute{Type: AttributeType(rest[0]), Value: value})
+		rest = rest[rest[1]:]
+	}
+	return p, nil
+}
+
+// Get 返回第一个指定类型属性的值，不存在时返回 nil
+func (p *Packet) Get(t AttributeType) []byte {
+	for _, attr := range p.Attributes {
+		if attr.Type == t {
+			return attr.Value
+		}
+	}
+	return nil
+}
+
+// GetString 以字符串返回第一个指定类型属性的值
+func (p *Packet) GetString(t AttributeType) string {
+	return string(p.Get(t))
+}
+
+// Add 追加属性
+func (p *Packet) Add(t AttributeType, value []byte) {
+	p.Attributes = append(p.Attributes, Attribute{Type: t, Value: value})
+}
+
+// AddString 追加字符串属性
+func (p *Packet) AddString(t AttributeType, value string) {
+	p.Add(t, []byte(value))
+}
+
+// NewAccessRequest 创建 Access-Request，随机生成请求认证码，password 为空时不携带 User-Password
+func NewAccessRequest(identifier byte, username, password string, secret []byte) (*Packet, error) {
+	p := &Packet{Code: CodeAccessRequest, Identifier: identifier}
+	if _, err := rand.Read(p.Authenticator[:]); err != nil {
+		return nil, err
+	}
+	p.AddString(AttrUserName, username)
+	if password != "" {
+		hidden, err := HidePassword([]byte(password), secret, p.Authenticator)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(AttrUserPassword, hidden)
+	}
+	return p, nil
+}
+
+// NewResponse 创建对请求的响应报文
+func NewResponse(req *Packet, code Code) *Packet {
+	return &Packet{Code: code, Identifier: req.Identifier}
+}
+
+// EncodeRequest 编码请求报文，使用报文自身的请求认证码计算 Message-Authenticator
+func EncodeRequest(p *Packet, secret []byte) ([]byte, error) {
+	return encodeWithMessageAuthenticator(p, p.Authenticator, secret)
+}
+
+// EncodeResponse 编码响应报文：先以请求认证码计算 Message-Authenticator，再计算响应认证码
+func EncodeResponse(resp, req *Packet, secret []byte) ([]byte, error) {
+	data, err := encodeWithMessageAuthenticator(resp, req.Authenticator, secret)
+	if err != nil {
+		return nil, err
+	}
+	// 响应认证码 = MD5(Code+Identifier+Length+请求认证码+属性+共享密钥)
+	h := md5.New()
+	h.Write(data)
+	h.Write(secret)
+	copy(data[4:headerSize], h.Sum(nil))
+	copy(resp.Authenticator[:], data[4:headerSize])
+	return data, nil
+}
+
+// VerifyRequest 校验请求报文的 Message-Authenticator，require 为 true 时缺少该属性视为无效
+func VerifyRequest(p *Packet, secret []byte, require bool) error {
+	return verifyMessageAuthenticator(p, p.Authenticator, secret, require)
+}
+
+// VerifyResponse 校验响应报文的标识、响应认证码和 Message-Authenticator
+func VerifyResponse(resp, req *Packet, secret []byte) error {
+	if resp.Identifier != req.Identifier {
+		return errs.ErrRADIUSAuthenticatorInvalid
+	}
+
+	expected := *resp
+	expected.Authenticator = req.Authenticator
+	data, err := expected.encode()
+	if err != nil {
+		return err
+	}
+	h := md5.New()
+	h.Write(data)
+	h.Write(secret)
+	if subtle.ConstantTimeCompare(h.Sum(nil), resp.Authenticator[:]) != 1 {
+		return errs.ErrRADIUSAuthenticatorInvalid
+	}
+
+	return verifyMessageAuthenticator(resp, req.Authenticator, secret, true)
+}
+
+// HidePassword 按 RFC 2865 5.2 隐藏 User-Password
+func HidePassword(password, secret []byte, authenticator [authenticatorSize]byte) ([]byte, error) {
+	if len(password) > 128 {
+		return nil, errs.ErrRADIUSPacketInvalid
+	}
+	size := (len(password) + 15) / 16 * 16
+	if size == 0 {
+		size = 16
+	}
+	hidden := make([]byte, size)
+	copy(hidden, password)
+
+	prev := authenticator[:]
+	for i := 0; i < size; i += 16 {
+		b := md5.Sum(append(append([]byte{}, secret...), prev...))
+		for j := 0; j < 16; j++ {
+			hidden[i+j] ^= b[j]
+		}
+		prev = hidden[i : i+16]
+	}
+	return hidden, nil
+}
+
+// RevealPassword 还原 HidePassword 隐藏的 User-Password
+func RevealPassword(hidden, secret []byte, authenticator [authenticatorSize]byte) ([]byte, error) {
+	if len(hidden) == 0 || len(hidden)%16 != 0 || len(hidden) > 128 {
+		return nil, errs.ErrRADIUSPacketInvalid
+	}
+	password := make([]byte, len(hidden))
+
+	prev := authenticator[:]
+	for i := 0; i < len(hidden); i += 16 {
+		b := md5.Sum(append(append([]byte{}, secret...), prev...))
+		for j := 0; j < 16; j++ {
+			password[i+j] = hidden[i+j] ^ b[j]
+		}
+		prev = hidden[i : i+16]
+	}
+	return bytes.TrimRight(password, "\x00"), nil
+}
+
+// Exchange 向服务端发送请求并等待响应，用于测试和命令行工具
+// 超时由 ctx 控制；收到标识不匹配或校验失败的报文时继续等待
+func Exchange(ctx context.Context, addr string, req *Packet, secret []byte) (*Packet, error) {
+	data, err := EncodeRequest(req, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// ctx 结束时关闭连接以中断读取
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, MaxPacketSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		resp, err := Parse(buf[:n])
+		if err != nil || VerifyResponse(resp, req, secret) != nil {
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// encodeWithMessageAuthenticator 把 Message-Authenticator 放在第一个属性并计算其值后编码
+// 返回的报文头中是计算所用的认证码，响应报文随后还需替换为响应认证码
+func encodeWithMessageAuthenticator(p *Packet, authenticator [authenticatorSize]byte, secret []byte) ([]byte, error) {
+	attrs := make([]Attribute, 0, len(p.Attributes)+1)
+	attrs = append(attrs, Attribute{Type: AttrMessageAuthenticator, Value: make([]byte, messageAuthenticatorSize)})
+	for _, attr := range p.Attributes {
+		if attr.Type != AttrMessageAuthenticator {
+			attrs = append(attrs, attr)
+		}
+	}
+	p.Attributes = attrs
+
+	signed := *p
+	signed.Authenticator = authenticator
+	data, err := signed.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(md5.New, secret)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	copy(p.Attributes[0].Value, sum)
+	copy(data[headerSize+2:], sum)
+	return data, nil
+}
+
+// verifyMessageAuthenticator 将 Message-Authenticator 置零后重新计算并比较
+func verifyMessageAuthenticator(p *Packet, authenticator [authenticatorSize]byte, secret []byte, require bool) error {
+	index := -1
+	for i, attr := range p.Attributes {
+		if attr.Type != AttrMessageAuthenticator {
+			continue
+		}
+		if index >= 0 || len(attr.Value) != messageAuthenticatorSize {
+			return errs.ErrRADIUSAuthenticatorInvalid
+		}
+		index = i
+	}
+	if index < 0 {
+		if require {
+			return errs.ErrRADIUSMessageAuthenticatorMissing
+		}
+		return nil
+	}
+
+	zeroed := *p
+	zeroed.Authenticator = authenticator
+	zeroed.Attributes = append([]Attribute(nil), p.Attributes...)
+	zeroed.Attributes[index] = Attribute{Type: AttrMessageAuthenticator, Value: make([]byte, messageAuthenticatorSize)}
+	data, err := zeroed.encode()
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(md5.New, secret)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), p.Attributes[index].Value) {
+		return errs.ErrRADIUSAuthenticatorInvalid
+	}
+	return nil
+}
+
+// encode 按报文当前内容编码，不计算任何认证码
+func (p *Packet) encode() ([]byte, error) {
+	length := headerSize
+	for _, attr := range p.Attributes {
+		if len(attr.Value) > maxAttributeValueSize {
+			return nil, errs.ErrRADIUSPacketInvalid
+		}
+		length += 2 + len(attr.Value)
+	}
+	if length > MaxPacketSize {
+		return nil, errs.ErrRADIUSPacketInvalid
+	}
+
+	data := make([]byte, headerSize, length)
+	data[0] = byte(p.Code)
+	data[1] = p.Identifier
+	binary.BigEndian.PutUint16(data[2:4], uint16(length))
+	copy(data[4:headerSize], p.Authenticator[:])
+	for _, attr := range p.Attributes {
+		data = append(data, byte(attr.Type), byte(2+len(attr.Value)))
+		data = append(data, attr.Value...)
+	}
+	return data, nil
+}
@@ -0,0 +1,180 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hang666/EasyUKey/shared/pkg/errs"
+)
+
+var testSecret = []byte("xyzzy5461")
+
+func TestHidePasswordRFC2865Vector(t *testing.T) {
+	// RFC 2865 7.1 示例
+	var authenticator [16]byte
+	auth, _ := hex.DecodeString("0f403f9473978057bd83d5cb98f4227a")
+	copy(authenticator[:], auth)
+
+	hidden, err := HidePassword([]byte("arctangent"), testSecret, authenticator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(hidden); got != "0dbe708d93d413ce3196e43f782a0aee" {
+		t.Errorf("隐藏后的密码 = %s", got)
+	}
+
+	password, err := RevealPassword(hidden, testSecret, authenticator)
+	if err != nil || string(password) != "arctangent" {
+		t.Errorf("还原密码 = %q, %v", password, err)
+	}
+
+	long := bytes.Repeat([]byte("p"), 40)
+	hidden, _ = HidePassword(long, testSecret, authenticator)
+	if password, _ := RevealPassword(hidden, testSecret, authenticator); !bytes.Equal(password, long) {
+		t.Errorf("多个分块的密码还原失败: %q", password)
+	}
+}
+
+func TestParseRejectsMalformedPackets(t *testing.T) {
+	req, _ := NewAccessRequest(1, "alice", "", testSecret)
+	data, err := EncodeRequest(req, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Parse(append(append([]byte{}, data...), 0, 0, 0)); err != nil {
+		t.Errorf("长度字段之后的填充应被忽略: %v", err)
+	}
+
+	cases := map[string][]byte{
+		"过短":     data[:10],
+		"长度超出报文": data[:len(data)-1],
+		"属性长度无效": append(append([]byte{}, data[:len(data)-7]...), 1, 0, 0, 0, 0, 0, 0),
+	}
+	for name, b := range cases {
+		if _, err := Parse(b); !errors.Is(err, errs.ErrRADIUSPacketInvalid) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestRequestMessageAuthenticator(t *testing.T) {
+	req, _ := NewAccessRequest(7, "alice", "secret-pass", testSecret)
+	data, err := EncodeRequest(req, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Attributes[0].Type != AttrMessageAuthenticator {
+		t.Error("Message-Authenticator 应为第一个属性")
+	}
+	if parsed.GetString(AttrUserName) != "alice" {
+		t.Errorf("User-Name = %q", parsed.GetString(AttrUserName))
+	}
+	if err := VerifyRequest(parsed, testSecret, true); err != nil {
+		t.Errorf("校验请求失败: %v", err)
+	}
+	if err := VerifyRequest(parsed, []byte("wrong"), true); !errors.Is(err, errs.ErrRADIUSAuthenticatorInvalid) {
+		t.Errorf("错误的共享密钥应校验失败: %v", err)
+	}
+
+	data[len(data)-1] ^= 1
+	tampered, _ := Parse(data)
+	if err := VerifyRequest(tampered, testSecret, true); !errors.Is(err, errs.ErrRADIUSAuthenticatorInvalid) {
+		t.Errorf("篡改后的请求应校验失败: %v", err)
+	}
+
+	legacy := &Packet{Code: CodeAccessRequest, Identifier: 1}
+	legacy.AddString(AttrUserName, "alice")
+	if err := VerifyRequest(legacy, testSecret, true); !errors.Is(err, errs.ErrRADIUSMessageAuthenticatorMissing) {
+		t.Errorf("缺少 Message-Authenticator: err = %v", err)
+	}
+	if err := VerifyRequest(legacy, testSecret, false); err != nil {
+		t.Errorf("允许缺少 Message-Authenticator 时应通过: %v", err)
+	}
+}
+
+func TestResponseAuthenticator(t *testing.T) {
+	req, _ := NewAccessRequest(9, "alice", "", testSecret)
+	if _, err := EncodeRequest(req, testSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := NewResponse(req, CodeAccessAccept)
+	resp.AddString(AttrReplyMessage, "认证成功")
+	data, err := EncodeResponse(resp, req, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, _ := Parse(data)
+	if err := VerifyResponse(parsed, req, testSecret); err != nil {
+		t.Fatalf("校验响应失败: %v", err)
+	}
+	if parsed.Code != CodeAccessAccept || parsed.GetString(AttrReplyMessage) != "认证成功" {
+		t.Errorf("响应内容 = %+v", parsed)
+	}
+
+	// 伪造的 Accept：修改报文类型后响应认证码不再匹配
+	data[0] = byte(CodeAccessReject)
+	forged, _ := Parse(data)
+	if err := VerifyResponse(forged, req, testSecret); !errors.Is(err, errs.ErrRADIUSAuthenticatorInvalid) {
+		t.Errorf("篡改后的响应应校验失败: %v", err)
+	}
+	if err := VerifyResponse(parsed, req, []byte("wrong")); !errors.Is(err, errs.ErrRADIUSAuthenticatorInvalid) {
+		t.Errorf("错误的共享密钥应校验失败: %v", err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, MaxPacketSize)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := Parse(buf[:n])
+		if err != nil || VerifyRequest(req, testSecret, true) != nil {
+			return
+		}
+
+		// 先发送一个使用错误密钥的响应，客户端应忽略并继续等待
+		forged, _ := EncodeResponse(NewResponse(req, CodeAccessAccept), req, []byte("wrong"))
+		conn.WriteTo(forged, addr)
+
+		resp, _ := EncodeResponse(NewResponse(req, CodeAccessReject), req, testSecret)
+		conn.WriteTo(resp, addr)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := NewAccessRequest(3, "alice", "", testSecret)
+	resp, err := Exchange(ctx, conn.LocalAddr().String(), req, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != CodeAccessReject {
+		t.Errorf("响应类型 = %d, want %d", resp.Code, CodeAccessReject)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelShort()
+	if _, err := Exchange(short, conn.LocalAddr().String(), req, testSecret); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("无响应时 err = %v, want DeadlineExceeded", err)
+	}
+}
@@ -11,10 +11,16 @@ import (
 
 // SendMessage 发送WebSocket消息的通用函数
 func SendMessage(conn interface{ WriteJSON(v interface{}) error }, msgType string, data interface{}) error {
+	return SendVersionedMessage(conn, msgType, data, "")
+}
+
+// SendVersionedMessage 发送携带客户端版本号的WebSocket消息（用于client）
+func SendVersionedMessage(conn interface{ WriteJSON(v interface{}) error }, msgType string, data interface{}, clientVersion string) error {
 	message := messages.WSMessage{
-		Type:      msgType,
-		Data:      data,
-		Timestamp: time.Now(),
+		Type:          msgType,
+		Data:          data,
+		Timestamp:     time.Now(),
+		ClientVersion: clientVersion,
 	}
 	return conn.WriteJSON(message)
 }